@@ -6,25 +6,52 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/klog"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
 	prometheusutil "kubevirt.io/containerized-data-importer/pkg/util/prometheus"
 )
 
+const (
+	// filesystemCloneContentType is the content type of a tar stream of the files of a filesystem volume
+	filesystemCloneContentType = "filesystem-clone"
+	// blockdeviceCloneContentType is the content type of the stream of a block device
+	blockdeviceCloneContentType = "blockdevice-clone"
+	// sourceSizeHeader declares the size of the source block device, so that the upload server refuses a clone to a
+	// smaller device before it is transferred
+	sourceSizeHeader = "x-cdi-source-size"
+)
+
 var (
-	contentType string
-	uploadBytes uint64
+	contentType  string
+	uploadBytes  uint64
+	estimateOnly bool
+
+	// how often and how long to wait for the upload server to be ready, may be overridden in tests
+	readyAttempts = 30
+	readyInterval = 2 * time.Second
+
+	// the size of the chunks the stream is sent in, and how often and how long apart a chunk is sent before the
+	// clone fails, may be overridden in tests
+	chunkSize          = 8 << 20
+	chunkAttempts      = 5
+	chunkRetryInterval = time.Second
 )
 
 func init() {
 	flag.StringVar(&contentType, "content_type", "", "archive|kubevirt")
 	flag.Uint64Var(&uploadBytes, "upload_bytes", 0, "approx number of bytes in input")
+	flag.BoolVar(&estimateOnly, "estimate", false, "estimate a clone of the source instead of cloning it")
 	klog.InitFlags(nil)
 }
 
@@ -63,7 +90,19 @@ func startPrometheus() {
 		klog.Fatalf("Error %s creating temp dir", err)
 	}
 
-	prometheusutil.StartPrometheusEndpoint(certsDirectory)
+	metricsKey := os.Getenv("METRICS_KEY")
+	metricsCert := os.Getenv("METRICS_CERT")
+	metricsClientCA := os.Getenv("METRICS_CLIENT_CA_CERT")
+	if metricsKey == "" || metricsCert == "" || metricsClientCA == "" {
+		// created by a controller that didn't sign the metrics endpoint yet
+		prometheusutil.StartPrometheusEndpoint(certsDirectory)
+		return
+	}
+
+	err = prometheusutil.StartMutualTLSPrometheusEndpoint(certsDirectory, []byte(metricsCert), []byte(metricsKey), []byte(metricsClientCA))
+	if err != nil {
+		klog.Fatalf("Error %s starting prometheus endpoint", err)
+	}
 }
 
 func createProgressReader(readCloser io.ReadCloser, ownerUID string, totalBytes uint64) io.ReadCloser {
@@ -76,15 +115,19 @@ func createProgressReader(readCloser io.ReadCloser, ownerUID string, totalBytes
 	)
 	prometheus.MustRegister(progress)
 
-	promReader := prometheusutil.NewProgressReader(readCloser, totalBytes, progress, ownerUID)
+	promReader := prometheusutil.NewProgressReader(prometheusutil.NewHeartbeatReader(readCloser), totalBytes, progress, ownerUID)
 	promReader.StartTimedUpdate()
+	prometheusutil.StartHeartbeat(ownerUID)
 
 	return promReader
 }
 
-func pipeToGzip(reader io.ReadCloser) io.ReadCloser {
+func pipeToGzip(reader io.ReadCloser, level int) (io.ReadCloser, error) {
 	pr, pw := io.Pipe()
-	gzw := gzip.NewWriter(pw)
+	gzw, err := gzip.NewWriterLevel(pw, level)
+	if err != nil {
+		return nil, err
+	}
 
 	go func() {
 		n, err := io.Copy(gzw, reader)
@@ -96,13 +139,180 @@ func pipeToGzip(reader io.ReadCloser) io.ReadCloser {
 		klog.Infof("Wrote %d bytes\n", n)
 	}()
 
-	return pr
+	return pr, nil
+}
+
+// checksumReader computes the checksums of the data read through it, and passes them to done at the end of the data
+type checksumReader struct {
+	reader   io.Reader
+	tarPipe  *io.PipeWriter
+	manifest func() (checksum.Manifest, error)
+	done     func(checksum.Manifest)
+	finished bool
+}
+
+// newChecksumReader returns a reader of reader that passes the checksums of the data to done once it is read to the
+// end. The checksums are of the files of a filesystem clone, or of the whole block device otherwise.
+func newChecksumReader(reader io.Reader, contentType string, done func(checksum.Manifest)) io.Reader {
+	if contentType != filesystemCloneContentType {
+		h := checksum.NewHasher()
+		return &checksumReader{
+			reader: io.TeeReader(reader, h),
+			manifest: func() (checksum.Manifest, error) {
+				return checksum.Manifest{checksum.BlockDevice: h.File()}, nil
+			},
+			done: done,
+		}
+	}
+
+	pr, pw := io.Pipe()
+	type result struct {
+		manifest checksum.Manifest
+		err      error
+	}
+	results := make(chan result, 1)
+	go func() {
+		m, err := checksum.TarManifest(pr)
+		// Fail the writes of a stream that is not a valid tar archive
+		pr.CloseWithError(err)
+		results <- result{m, err}
+	}()
+	return &checksumReader{
+		reader:  io.TeeReader(reader, pw),
+		tarPipe: pw,
+		manifest: func() (checksum.Manifest, error) {
+			r := <-results
+			return r.manifest, r.err
+		},
+		done: done,
+	}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF && !r.finished {
+		r.finished = true
+		if r.tarPipe != nil {
+			r.tarPipe.Close()
+		}
+		m, merr := r.manifest()
+		if merr != nil {
+			return n, merr
+		}
+		r.done(m)
+	}
+	return n, err
+}
+
+// waitForReady polls the ready endpoint of the upload server until it can write to its destination, so that a
+// destination that cannot be written fails the clone with its cause before any data is streamed. It returns the
+// header of the answer, which tells the encodings of the streams the server takes.
+func waitForReady(client *http.Client, url string) (http.Header, error) {
+	var lastErr error
+	for i := 0; i < readyAttempts; i++ {
+		if i > 0 {
+			time.Sleep(readyInterval)
+		}
+		response, err := client.Get(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode == http.StatusOK {
+			return response.Header, nil
+		}
+		lastErr = fmt.Errorf("upload server not ready, status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+		if response.StatusCode == http.StatusConflict {
+			// The upload is done already, waiting does not help
+			break
+		}
+		klog.V(1).Infof("%v", lastErr)
+	}
+	return nil, lastErr
+}
+
+// sendChunks posts the stream read from reader to the chunked upload endpoint at url, in chunks of chunkSize with
+// header. The last chunk, which may be empty, also has the header lastHeader returns once the stream is read.
+func sendChunks(client *http.Client, url string, reader io.Reader, header http.Header, lastHeader func() http.Header) error {
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(reader, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+		chunkHeader := http.Header{}
+		for name, values := range header {
+			chunkHeader[name] = values
+		}
+		if last {
+			for name, values := range lastHeader() {
+				chunkHeader[name] = values
+			}
+		}
+		if err := sendChunk(client, url, buf[:n], offset, last, chunkHeader); err != nil {
+			return err
+		}
+		offset += int64(n)
+		if last {
+			klog.Infof("Sent %d bytes\n", offset)
+			return nil
+		}
+	}
+}
+
+// sendChunk posts the chunk data at offset until the upload server takes it, sending it again if it was corrupted
+// in transit or the connection failed
+func sendChunk(client *http.Client, url string, data []byte, offset int64, last bool, header http.Header) error {
+	header.Set(checksum.ChunkHeader, checksum.ChunkSum(data))
+	var lastErr error
+	for i := 0; i < chunkAttempts; i++ {
+		if i > 0 {
+			klog.Warningf("Sending chunk at offset %d again: %v", offset, lastErr)
+			time.Sleep(chunkRetryInterval)
+		}
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s?offset=%d&last=%t", url, offset, last), bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header = header
+		response, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		switch {
+		case response.StatusCode >= 200 && response.StatusCode < 300:
+			if last {
+				klog.V(1).Infof("Response body:\n%s", body)
+			}
+			return nil
+		case response.StatusCode == http.StatusUnprocessableEntity:
+			lastErr = fmt.Errorf("chunk at offset %d corrupted in transit", offset)
+		case response.StatusCode == http.StatusConflict && last && i > 0:
+			// The upload server took the last chunk, but its answer was lost
+			return nil
+		default:
+			return fmt.Errorf("unexpected status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+		}
+	}
+	return lastErr
 }
 
 func main() {
 	flag.Parse()
 	defer klog.Flush()
 
+	if estimateOnly {
+		estimate()
+		return
+	}
+
 	klog.Infof("content_type is %q\n", contentType)
 	klog.Infof("upload_bytes is %d", uploadBytes)
 
@@ -116,35 +326,61 @@ func main() {
 
 	klog.V(1).Infoln("Starting cloner target")
 
-	reader := pipeToGzip(createProgressReader(os.Stdin, ownerUID, uploadBytes))
-
-	startPrometheus()
-
 	client := createHTTPClient(clientKey, clientCert, serverCert)
 
-	req, _ := http.NewRequest("POST", url, reader)
-
-	if contentType != "" {
-		req.Header.Set("x-cdi-content-type", contentType)
-		klog.Infof("Set header to %s", contentType)
+	ready, err := endpointURL(url, common.UploadPathReady)
+	if err != nil {
+		klog.Fatalf("Error %s parsing upload url %s", err, url)
 	}
-
-	response, err := client.Do(req)
+	readyHeader, err := waitForReady(client, ready)
 	if err != nil {
-		klog.Fatalf("Error %s POSTing to %s", err, url)
+		klog.Fatalf("Error %s waiting for %s", err, ready)
 	}
+	encoding := negotiateEncoding(os.Getenv(common.CloneCompression), readyHeader.Get("Accept-Encoding"))
+	klog.Infof("Sending the stream with Content-Encoding %s", encoding)
+
+	body := createProgressReader(os.Stdin, ownerUID, uploadBytes)
+	lastHeader := http.Header{}
+	if os.Getenv(common.CloneVerifyContent) == "true" {
+		// The upload server verifies what it wrote against the checksums, sent with the last chunk
+		body = ioutil.NopCloser(newChecksumReader(body, contentType, func(m checksum.Manifest) {
+			klog.V(1).Infof("Checksums of %d bytes: %s", m.Size(), m)
+			lastHeader.Set(checksum.Header, m.String())
+		}))
+	}
+
+	startPrometheus()
 
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		klog.Fatalf("Unexpected status code %d", response.StatusCode)
+	if os.Getenv(common.CloneDelta) == "true" && contentType == blockdeviceCloneContentType {
+		// The destination holds an earlier copy of the source, send only the blocks that differ
+		delta, err := cloneDelta(client, url, body, uploadBytes, func() http.Header { return lastHeader })
+		if err != nil {
+			klog.Fatalf("Error %s cloning by delta to %s", err, url)
+		}
+		if delta {
+			klog.V(1).Infoln("clone complete")
+			return
+		}
 	}
 
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, response.Body)
+	reader, err := compressStream(body, encoding, compressionLevel())
 	if err != nil {
-		klog.Fatalf("Error %s copying response body", err)
+		klog.Fatalf("Error %s compressing the stream", err)
 	}
 
-	klog.V(1).Infof("Response body:\n%s", buf.String())
+	header := http.Header{}
+	header.Set("Content-Encoding", encoding)
+	if contentType != "" {
+		header.Set("x-cdi-content-type", contentType)
+		klog.Infof("Set header to %s", contentType)
+	}
+	if contentType == blockdeviceCloneContentType && uploadBytes > 0 {
+		header.Set(sourceSizeHeader, strconv.FormatUint(uploadBytes, 10))
+	}
+
+	if err := sendChunks(client, url, reader, header, func() http.Header { return lastHeader }); err != nil {
+		klog.Fatalf("Error %s POSTing to %s", err, url)
+	}
 
 	klog.V(1).Infoln("clone complete")
 }
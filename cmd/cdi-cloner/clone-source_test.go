@@ -1,14 +1,20 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
 	prometheusutil "kubevirt.io/containerized-data-importer/pkg/util/prometheus"
 )
 
@@ -29,6 +35,47 @@ var _ = Describe("Prometheus Endpoint", func() {
 	})
 })
 
+var _ = Describe("Upload server readiness", func() {
+	BeforeEach(func() {
+		readyInterval = time.Millisecond
+	})
+
+	AfterEach(func() {
+		readyInterval = 2 * time.Second
+	})
+
+	It("Should use the ready endpoint of the upload server", func() {
+		url, err := endpointURL("https://cdi-upload-target.default.svc/v1alpha1/upload", common.UploadPathReady)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal("https://cdi-upload-target.default.svc" + common.UploadPathReady))
+	})
+
+	It("Should wait until the upload server is ready", func() {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		_, err := waitForReady(server.Client(), server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(Equal(3))
+	})
+
+	It("Should fail with the cause if the upload server can not write its destination", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("read-only file system"))
+		}))
+		defer server.Close()
+		_, err := waitForReady(server.Client(), server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("read-only file system"))
+	})
+})
+
 func isDirEmpty(dirName string) (bool, error) {
 	f, err := os.Open(dirName)
 	if err != nil {
@@ -42,3 +89,136 @@ func isDirEmpty(dirName string) (bool, error) {
 	}
 	return false, err
 }
+
+var _ = Describe("Clone checksums", func() {
+	hash := func(data string) checksum.File {
+		h := checksum.NewHasher()
+		h.Write([]byte(data))
+		return h.File()
+	}
+
+	It("Should checksum the whole stream of a block device", func() {
+		var manifest checksum.Manifest
+		reader := newChecksumReader(bytes.NewBufferString("device"), "blockdevice-clone", func(m checksum.Manifest) {
+			manifest = m
+		})
+		data, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("device"))
+		Expect(manifest).To(Equal(checksum.Manifest{checksum.BlockDevice: hash("device")}))
+	})
+
+	It("Should checksum the files of a filesystem", func() {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		Expect(tw.WriteHeader(&tar.Header{Name: "./disk.img", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})).To(Succeed())
+		_, err := tw.Write([]byte("disk"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tw.Close()).To(Succeed())
+		expected := archive.Bytes()
+
+		var manifest checksum.Manifest
+		reader := newChecksumReader(bytes.NewReader(expected), filesystemCloneContentType, func(m checksum.Manifest) {
+			manifest = m
+		})
+		data, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(expected))
+		Expect(manifest).To(Equal(checksum.Manifest{"disk.img": hash("disk")}))
+	})
+
+	It("Should fail a filesystem stream that is not a tar archive", func() {
+		reader := newChecksumReader(bytes.NewBufferString("not a tar archive, but long enough to have a header of 512 bytes"+string(make([]byte, 512))), filesystemCloneContentType, func(m checksum.Manifest) {
+			Fail("Unexpected checksums")
+		})
+		_, err := ioutil.ReadAll(reader)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Chunked upload", func() {
+	type chunk struct {
+		offset string
+		last   string
+		data   string
+	}
+
+	var (
+		chunks  []chunk
+		corrupt map[string]bool
+		server  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		chunkSize = 4
+		chunkRetryInterval = time.Millisecond
+		chunks = nil
+		corrupt = map[string]bool{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := ioutil.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.Header.Get("x-cdi-content-type")).To(Equal("blockdevice-clone"))
+			offset := r.URL.Query().Get("offset")
+			if corrupt[offset] || checksum.ChunkSum(data) != r.Header.Get(checksum.ChunkHeader) {
+				delete(corrupt, offset)
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			if r.URL.Query().Get("last") == "true" {
+				Expect(r.Header.Get(checksum.Header)).To(Equal("checksums"))
+			}
+			chunks = append(chunks, chunk{offset, r.URL.Query().Get("last"), string(data)})
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		chunkSize = 8 << 20
+		chunkRetryInterval = time.Second
+	})
+
+	send := func(data string) error {
+		header := http.Header{}
+		header.Set("x-cdi-content-type", "blockdevice-clone")
+		lastHeader := http.Header{}
+		reader := newChecksumReader(bytes.NewBufferString(data), "blockdevice-clone", func(checksum.Manifest) {
+			lastHeader.Set(checksum.Header, "checksums")
+		})
+		return sendChunks(server.Client(), server.URL, reader, header, func() http.Header { return lastHeader })
+	}
+
+	It("Should send the stream in chunks", func() {
+		Expect(send("abcdefghij")).To(Succeed())
+		Expect(chunks).To(Equal([]chunk{{"0", "false", "abcd"}, {"4", "false", "efgh"}, {"8", "true", "ij"}}))
+	})
+
+	It("Should end a stream of whole chunks with an empty chunk", func() {
+		Expect(send("abcd")).To(Succeed())
+		Expect(chunks).To(Equal([]chunk{{"0", "false", "abcd"}, {"4", "true", ""}}))
+	})
+
+	It("Should send a corrupted chunk again", func() {
+		corrupt["4"] = true
+		Expect(send("abcdefghij")).To(Succeed())
+		Expect(chunks).To(Equal([]chunk{{"0", "false", "abcd"}, {"4", "false", "efgh"}, {"8", "true", "ij"}}))
+	})
+
+	It("Should give up on a chunk that is corrupted every time", func() {
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		})
+		err := send("abcdefghij")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("corrupted in transit"))
+	})
+
+	It("Should fail with the cause of a failed upload", func() {
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("no space left on device"))
+		})
+		err := send("abcdefghij")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("no space left on device"))
+	})
+})
@@ -29,11 +29,21 @@ fi
 echo "VOLUME_MODE=$VOLUME_MODE"
 echo "MOUNT_POINT=$MOUNT_POINT"
 
+if [ "${ESTIMATE:-}" == "true" ]; then
+    exec /usr/bin/cdi-cloner -v=3 -alsologtostderr -estimate
+fi
+
 if [ "$VOLUME_MODE" == "block" ]; then
     UPLOAD_BYTES=$(lsblk -n -b -o SIZE $MOUNT_POINT)
     echo "UPLOAD_BYTES=$UPLOAD_BYTES"
 
     /usr/bin/cdi-cloner -v=3 -alsologtostderr -content_type blockdevice-clone -upload_bytes $UPLOAD_BYTES < $MOUNT_POINT
+elif [ "${TARGET_VOLUME_MODE:-}" == "block" ]; then
+    # The target is a block volume, send the disk image as the content of the device
+    UPLOAD_BYTES=$(stat -c %s $MOUNT_POINT/disk.img)
+    echo "UPLOAD_BYTES=$UPLOAD_BYTES"
+
+    /usr/bin/cdi-cloner -v=3 -alsologtostderr -content_type blockdevice-clone -upload_bytes $UPLOAD_BYTES < $MOUNT_POINT/disk.img
 else
     pushd $MOUNT_POINT
     UPLOAD_BYTES=$(du -sb . | cut -f1)
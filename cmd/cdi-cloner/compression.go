@@ -0,0 +1,76 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	contentEncodingGzip     = "gzip"
+	contentEncodingZstd     = "zstd"
+	contentEncodingIdentity = "identity"
+)
+
+// negotiateEncoding returns the Content-Encoding to send the stream with, preferred if the upload server accepts it,
+// else gzip, else identity. acceptEncoding is the Accept-Encoding of the ready endpoint of the upload server, a server
+// that does not tell it takes gzip streams only.
+func negotiateEncoding(preferred, acceptEncoding string) string {
+	preferred = strings.ToLower(strings.TrimSpace(preferred))
+	switch preferred {
+	case "":
+		preferred = contentEncodingGzip
+	case contentEncodingGzip, contentEncodingIdentity:
+	case contentEncodingZstd:
+		klog.Warningf("No zstd encoder in this cloner, compressing with gzip")
+		preferred = contentEncodingGzip
+	default:
+		klog.Warningf("Ignoring unsupported content encoding %q, compressing with gzip", preferred)
+		preferred = contentEncodingGzip
+	}
+	if acceptEncoding == "" {
+		return contentEncodingGzip
+	}
+
+	accepted := map[string]bool{}
+	for _, encoding := range strings.Split(acceptEncoding, ",") {
+		// Drop the quality value, the server tells no preference
+		encoding = strings.SplitN(encoding, ";", 2)[0]
+		accepted[strings.ToLower(strings.TrimSpace(encoding))] = true
+	}
+	for _, encoding := range []string{preferred, contentEncodingGzip} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return contentEncodingIdentity
+}
+
+// compressionLevel returns the compression level the clone source pod is configured with, the default level of the
+// encoding if it is not
+func compressionLevel() int {
+	value := os.Getenv(common.CloneCompressionLevel)
+	if value == "" {
+		return gzip.DefaultCompression
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil {
+		klog.Warningf("Ignoring invalid compression level %q", value)
+		return gzip.DefaultCompression
+	}
+	return level
+}
+
+// compressStream returns the stream of reader compressed with encoding at level
+func compressStream(reader io.ReadCloser, encoding string, level int) (io.ReadCloser, error) {
+	if encoding == contentEncodingIdentity {
+		return reader, nil
+	}
+	return pipeToGzip(reader, level)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Clone compression", func() {
+	table.DescribeTable("Should negotiate", func(preferred, acceptEncoding, expected string) {
+		Expect(negotiateEncoding(preferred, acceptEncoding)).To(Equal(expected))
+	},
+		table.Entry("gzip by default", "", "gzip, identity", "gzip"),
+		table.Entry("identity if the server takes it", "identity", "gzip, identity", "identity"),
+		table.Entry("gzip for zstd, which the cloner can not encode", "zstd", "zstd, gzip, identity", "gzip"),
+		table.Entry("gzip for an unknown encoding", "br", "gzip, identity", "gzip"),
+		table.Entry("gzip with a server that does not tell", "identity", "", "gzip"),
+		table.Entry("gzip if the server does not take the preferred encoding", "identity", "gzip", "gzip"),
+		table.Entry("identity if the server takes no compression", "gzip", "identity", "identity"),
+		table.Entry("ignoring quality values", "identity", "gzip;q=1.0, identity;q=0.5", "identity"),
+	)
+
+	It("Should compress the stream with gzip at the level", func() {
+		data := bytes.Repeat([]byte("clone "), 1024)
+		reader, err := compressStream(ioutil.NopCloser(bytes.NewReader(data)), contentEncodingGzip, gzip.BestSpeed)
+		Expect(err).NotTo(HaveOccurred())
+		gzr, err := gzip.NewReader(reader)
+		Expect(err).NotTo(HaveOccurred())
+		decompressed, err := ioutil.ReadAll(gzr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decompressed).To(Equal(data))
+	})
+
+	It("Should send the stream as is with identity", func() {
+		data := []byte("clone")
+		reader, err := compressStream(ioutil.NopCloser(bytes.NewReader(data)), contentEncodingIdentity, gzip.DefaultCompression)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadAll(reader)).To(Equal(data))
+	})
+
+	It("Should refuse an invalid gzip level", func() {
+		_, err := compressStream(ioutil.NopCloser(bytes.NewReader(nil)), contentEncodingGzip, 42)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should read the compression level from the env", func() {
+		defer os.Unsetenv(common.CloneCompressionLevel)
+		Expect(compressionLevel()).To(Equal(gzip.DefaultCompression))
+		os.Setenv(common.CloneCompressionLevel, "1")
+		Expect(compressionLevel()).To(Equal(1))
+		os.Setenv(common.CloneCompressionLevel, "fast")
+		Expect(compressionLevel()).To(Equal(gzip.DefaultCompression))
+	})
+})
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+const (
+	// destinationSizeHeader is the header of the block sums with the size of the destination block device
+	destinationSizeHeader = "x-cdi-destination-size"
+)
+
+// deltaBlockSize is the size of the blocks a delta clone compares, may be overridden in tests
+var deltaBlockSize = 1 << 20
+
+// endpointURL returns the url of the endpoint at path of the upload server uploadURL points to
+func endpointURL(uploadURL, path string) (string, error) {
+	u, err := neturl.Parse(uploadURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path
+	return u.String(), nil
+}
+
+// getBlockSums asks the upload server for the checksums of the blocks of deltaBlockSize of its destination, and the
+// size of the destination. It returns nil sums if the server does not clone by delta, so that the whole stream is
+// sent instead.
+func getBlockSums(client *http.Client, url string) ([]byte, int64, error) {
+	response, err := client.Get(fmt.Sprintf("%s?blockSize=%d", url, deltaBlockSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		klog.Infof("Upload server does not clone by delta, status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+		return nil, 0, nil
+	}
+	if len(body)%checksum.BlockSumSize != 0 {
+		return nil, 0, fmt.Errorf("block sums of %d bytes are not a multiple of %d", len(body), checksum.BlockSumSize)
+	}
+	size, err := strconv.ParseInt(response.Header.Get(destinationSizeHeader), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid destination size %q", response.Header.Get(destinationSizeHeader))
+	}
+	return body, size, nil
+}
+
+// sendDelta reads the source from reader in blocks of deltaBlockSize and posts the blocks whose checksum is not the
+// one in sums to the delta endpoint at url, blocks next to each other in chunks of up to chunkSize. The last chunk is
+// empty and has the header lastHeader returns once the source is read. It returns how many bytes it sent.
+func sendDelta(client *http.Client, url string, reader io.Reader, sums []byte, lastHeader func() http.Header) (int64, error) {
+	buf := make([]byte, deltaBlockSize)
+	run := make([]byte, 0, chunkSize)
+	var offset, runOffset, sent int64
+	flush := func() error {
+		if len(run) == 0 {
+			return nil
+		}
+		if err := sendChunk(client, url, run, runOffset, false, http.Header{}); err != nil {
+			return err
+		}
+		sent += int64(len(run))
+		run = run[:0]
+		return nil
+	}
+
+	for block := 0; ; block++ {
+		n, err := io.ReadFull(reader, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return sent, err
+		}
+		if n > 0 {
+			var flushErr error
+			sum := sha256.Sum256(buf[:n])
+			start := block * checksum.BlockSumSize
+			if start+checksum.BlockSumSize > len(sums) || !bytes.Equal(sum[:], sums[start:start+checksum.BlockSumSize]) {
+				if len(run) == 0 {
+					runOffset = offset
+				}
+				run = append(run, buf[:n]...)
+				if len(run)+deltaBlockSize > chunkSize {
+					flushErr = flush()
+				}
+			} else {
+				flushErr = flush()
+			}
+			if flushErr != nil {
+				return sent, flushErr
+			}
+		}
+		offset += int64(n)
+		if last {
+			if err := flush(); err != nil {
+				return sent, err
+			}
+			if err := sendChunk(client, url, nil, offset, true, lastHeader()); err != nil {
+				return sent, err
+			}
+			klog.Infof("Sent %d of %d bytes that differ from the destination\n", sent, offset)
+			return sent, nil
+		}
+	}
+}
+
+// cloneDelta sends only the blocks of the source read from reader that differ from the destination of the upload
+// server at uploadURL. It returns false, having read nothing, if the server can not clone by delta or its destination
+// is smaller than the source of size bytes.
+func cloneDelta(client *http.Client, uploadURL string, reader io.Reader, size uint64, lastHeader func() http.Header) (bool, error) {
+	hashesURL, err := endpointURL(uploadURL, common.UploadPathBlockHashes)
+	if err != nil {
+		return false, err
+	}
+	deltaURL, err := endpointURL(uploadURL, common.UploadPathDelta)
+	if err != nil {
+		return false, err
+	}
+	sums, destinationSize, err := getBlockSums(client, hashesURL)
+	if err != nil || sums == nil {
+		return false, err
+	}
+	if size > uint64(destinationSize) {
+		klog.Infof("Destination of %d bytes is smaller than the source of %d bytes, sending the whole stream", destinationSize, size)
+		return false, nil
+	}
+	klog.Infof("Comparing the source to %d blocks of the destination", len(sums)/checksum.BlockSumSize)
+	if _, err := sendDelta(client, deltaURL, reader, sums, lastHeader); err != nil {
+		return true, err
+	}
+	return true, nil
+}
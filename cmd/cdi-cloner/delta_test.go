@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+// deltaServer is an upload server whose destination holds base, and that writes the delta chunks to it
+type deltaServer struct {
+	destination []byte
+	chunks      int
+	checksums   string
+	noDelta     bool
+}
+
+func (s *deltaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case common.UploadPathBlockHashes:
+		if s.noDelta {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		blockSize, _ := strconv.Atoi(r.URL.Query().Get("blockSize"))
+		sums, _ := checksum.BlockSums(bytes.NewReader(s.destination), blockSize)
+		w.Header().Set(destinationSizeHeader, strconv.Itoa(len(s.destination)))
+		w.Write(sums)
+	case common.UploadPathDelta:
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		data, _ := ioutil.ReadAll(r.Body)
+		if len(data) > 0 {
+			s.chunks++
+			copy(s.destination[offset:], data)
+		}
+		if r.URL.Query().Get("last") == "true" {
+			s.checksums = r.Header.Get(checksum.Header)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("Delta clone", func() {
+	BeforeEach(func() {
+		deltaBlockSize = 4
+		chunkSize = 8
+	})
+
+	AfterEach(func() {
+		deltaBlockSize = 1 << 20
+		chunkSize = 8 << 20
+	})
+
+	clone := func(s *deltaServer, source string) (bool, error) {
+		server := httptest.NewServer(s)
+		defer server.Close()
+		lastHeader := http.Header{}
+		lastHeader.Set(checksum.Header, "sums")
+		return cloneDelta(server.Client(), server.URL+"/v1alpha1/upload", bytes.NewReader([]byte(source)), uint64(len(source)), func() http.Header { return lastHeader })
+	}
+
+	It("Should send only the blocks that differ", func() {
+		s := &deltaServer{destination: []byte("aaaabbbbccccddddeeeeff")}
+		delta, err := clone(s, "aaaaXXXXYYYYZZZZeeeeGG")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(BeTrue())
+		Expect(string(s.destination)).To(Equal("aaaaXXXXYYYYZZZZeeeeGG"))
+		// Three blocks next to each other are sent in two chunks of up to chunkSize, and the short last block in one
+		Expect(s.chunks).To(Equal(3))
+		Expect(s.checksums).To(Equal("sums"))
+	})
+
+	It("Should send nothing but the last chunk if the destination holds the source", func() {
+		s := &deltaServer{destination: []byte("aaaabbbb")}
+		delta, err := clone(s, "aaaabbbb")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(BeTrue())
+		Expect(s.chunks).To(BeZero())
+		Expect(s.checksums).To(Equal("sums"))
+	})
+
+	It("Should send the whole stream if the upload server does not clone by delta", func() {
+		s := &deltaServer{destination: []byte("aaaabbbb"), noDelta: true}
+		delta, err := clone(s, "aaaacccc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(BeFalse())
+	})
+
+	It("Should send the whole stream if the destination is smaller than the source", func() {
+		s := &deltaServer{destination: []byte("aaaa")}
+		delta, err := clone(s, "aaaabbbb")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(BeFalse())
+		Expect(string(s.destination)).To(Equal("aaaa"))
+	})
+
+	It("Should fail with block sums of an invalid size", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(destinationSizeHeader, "8")
+			io.WriteString(w, "short")
+		}))
+		defer server.Close()
+		_, _, err := getBlockSums(server.Client(), server.URL)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var (
+	// how many chunks of the source are read to estimate a clone, and their size, may be overridden in tests
+	estimateSamples    = 256
+	estimateSampleSize = int64(1 << 20)
+)
+
+// sourceExtent is a part of the clone source that is sampled, the block device or a file of the filesystem
+type sourceExtent struct {
+	path string
+	size int64
+}
+
+// sourceSample is what reading chunks of the clone source told
+type sourceSample struct {
+	// bytes is how much of the source was read, nonZero how much of it was not zeros
+	bytes, nonZero int64
+	// compressed is the size of what was read when gzipped like the clone stream, compressedNonZero of the chunks
+	// that were not zeros
+	compressed, compressedNonZero int64
+	// compressTime is how long compressing took
+	compressTime time.Duration
+}
+
+// compressRate returns how many bytes per second the source compresses at
+func (s *sourceSample) compressRate() int64 {
+	if s.compressTime <= 0 {
+		return 0
+	}
+	return int64(float64(s.bytes) / s.compressTime.Seconds())
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sampleSource reads chunks spread evenly over the extents, all of them if they are small, and gzips them like the
+// clone stream is
+func sampleSource(extents []sourceExtent) (*sourceSample, error) {
+	var total int64
+	for _, extent := range extents {
+		total += extent.size
+	}
+	sample := &sourceSample{}
+	if total == 0 {
+		return sample, nil
+	}
+	step := total / int64(estimateSamples)
+	if step < estimateSampleSize {
+		step = estimateSampleSize
+	}
+
+	compressed := &countingWriter{}
+	gzw := gzip.NewWriter(compressed)
+	buf := make([]byte, estimateSampleSize)
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+	i, base := 0, int64(0)
+	for offset := int64(0); offset < total; offset += step {
+		for base+extents[i].size <= offset {
+			base += extents[i].size
+			i++
+			if file != nil {
+				file.Close()
+				file = nil
+			}
+		}
+		if file == nil {
+			var err error
+			if file, err = os.Open(extents[i].path); err != nil {
+				return nil, errors.Wrapf(err, "could not open %s", extents[i].path)
+			}
+		}
+		n := extents[i].size - (offset - base)
+		if n > estimateSampleSize {
+			n = estimateSampleSize
+		}
+		read, err := file.ReadAt(buf[:n], offset-base)
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "could not read %s", extents[i].path)
+		}
+		chunk := buf[:read]
+
+		before := compressed.n
+		start := time.Now()
+		if _, err := gzw.Write(chunk); err != nil {
+			return nil, err
+		}
+		if err := gzw.Flush(); err != nil {
+			return nil, err
+		}
+		sample.compressTime += time.Since(start)
+		sample.bytes += int64(read)
+		sample.compressed += compressed.n - before
+		if !isZero(chunk) {
+			sample.nonZero += int64(read)
+			sample.compressedNonZero += compressed.n - before
+		}
+	}
+	return sample, nil
+}
+
+// scale returns value scaled by the ratio of part to whole
+func scale(value, part, whole int64) int64 {
+	if whole == 0 {
+		return 0
+	}
+	return int64(float64(value) * float64(part) / float64(whole))
+}
+
+// estimateBlockClone estimates a clone of the block device at path. The whole device is streamed, zeros compress to
+// almost nothing.
+func estimateBlockClone(path string) (*util.TerminationMessage, error) {
+	size, err := util.DestinationSize(path)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := sampleSource([]sourceExtent{{path: path, size: size}})
+	if err != nil {
+		return nil, err
+	}
+	return &util.TerminationMessage{
+		Result:         util.TerminationSucceeded,
+		Message:        "Estimated clone",
+		Bytes:          size,
+		AllocatedBytes: scale(size, sample.nonZero, sample.bytes),
+		TransferBytes:  scale(size, sample.compressed, sample.bytes),
+		CompressRate:   sample.compressRate(),
+	}, nil
+}
+
+// estimateFilesystemClone estimates a clone of the filesystem at dir. The files are streamed as a sparse tar archive,
+// so only their allocated blocks are read and sent.
+func estimateFilesystemClone(dir string) (*util.TerminationMessage, error) {
+	var extents []sourceExtent
+	var size, allocated int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		size += info.Size()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			allocated += stat.Blocks * 512
+		} else {
+			allocated += info.Size()
+		}
+		if info.Size() > 0 {
+			extents = append(extents, sourceExtent{path: path, size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list the files of %s", dir)
+	}
+	sample, err := sampleSource(extents)
+	if err != nil {
+		return nil, err
+	}
+	return &util.TerminationMessage{
+		Result:         util.TerminationSucceeded,
+		Message:        "Estimated clone",
+		Bytes:          size,
+		AllocatedBytes: allocated,
+		TransferBytes:  scale(allocated, sample.compressedNonZero, sample.nonZero),
+		CompressRate:   sample.compressRate(),
+	}, nil
+}
+
+// estimate samples the clone source mounted at the MOUNT_POINT and writes the estimate of cloning it to the
+// termination message, instead of cloning it
+func estimate() {
+	mountPoint := getEnvVarOrDie("MOUNT_POINT")
+	var message *util.TerminationMessage
+	var err error
+	if os.Getenv("VOLUME_MODE") == "block" {
+		message, err = estimateBlockClone(mountPoint)
+	} else {
+		message, err = estimateFilesystemClone(mountPoint)
+	}
+	if err != nil {
+		if err := util.WriteTerminationMessage(util.NewTerminationFailure("Unable to estimate the clone", err)); err != nil {
+			klog.Errorf("%+v", err)
+		}
+		klog.Fatalf("Error %s estimating the clone of %s", err, mountPoint)
+	}
+	klog.Infof("Source of %d bytes, %d allocated, estimated to transfer %d bytes, compresses at %d bytes/s",
+		message.Bytes, message.AllocatedBytes, message.TransferBytes, message.CompressRate)
+	if err := util.WriteTerminationMessage(message); err != nil {
+		klog.Errorf("%+v", err)
+	}
+}
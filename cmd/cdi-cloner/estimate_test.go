@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Clone estimate", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "estimate")
+		Expect(err).NotTo(HaveOccurred())
+		estimateSamples = 16
+		estimateSampleSize = 64 * 1024
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+		estimateSamples = 256
+		estimateSampleSize = 1 << 20
+	})
+
+	randomData := func(size int) []byte {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(1)).Read(data)
+		return data
+	}
+
+	It("Should estimate a block device that is half zeros", func() {
+		device := filepath.Join(tmpDir, "disk.img")
+		data := append(randomData(4<<20), make([]byte, 4<<20)...)
+		Expect(ioutil.WriteFile(device, data, 0644)).To(Succeed())
+
+		message, err := estimateBlockClone(device)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message.Result).To(Equal(util.TerminationSucceeded))
+		Expect(message.Bytes).To(Equal(int64(8 << 20)))
+		Expect(message.AllocatedBytes).To(Equal(int64(4 << 20)))
+		// Random data does not compress, zeros compress to almost nothing
+		Expect(message.TransferBytes).To(BeNumerically("~", 4<<20, 64*1024))
+		Expect(message.CompressRate).To(BeNumerically(">", 0))
+	})
+
+	It("Should estimate the allocated files of a filesystem", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "random"), randomData(1<<20), 0644)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(tmpDir, "dir"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "dir", "text"), bytes.Repeat([]byte("compressible "), 80*1024), 0644)).To(Succeed())
+		sparse, err := os.Create(filepath.Join(tmpDir, "sparse"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sparse.Truncate(64 << 20)).To(Succeed())
+		sparse.Close()
+
+		message, err := estimateFilesystemClone(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message.Bytes).To(Equal(int64(1<<20 + 13*80*1024 + 64<<20)))
+		// The hole of the sparse file is not allocated
+		Expect(message.AllocatedBytes).To(BeNumerically("<", 4<<20))
+		Expect(message.AllocatedBytes).To(BeNumerically(">=", 1<<20+13*80*1024))
+		Expect(message.TransferBytes).To(BeNumerically("<", message.AllocatedBytes))
+	})
+
+	It("Should estimate an empty filesystem", func() {
+		message, err := estimateFilesystemClone(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message.Bytes).To(BeZero())
+		Expect(message.TransferBytes).To(BeZero())
+	})
+
+	It("Should fail without the source", func() {
+		_, err := estimateBlockClone(filepath.Join(tmpDir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -2,10 +2,8 @@ package main
 
 import (
 	"context"
-	"crypto/rsa"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strconv"
 
@@ -15,6 +13,7 @@ import (
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	extclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	crdinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -31,6 +30,8 @@ import (
 	"kubevirt.io/containerized-data-importer/pkg/util"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/generator"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/watcher"
+	"kubevirt.io/containerized-data-importer/pkg/util/diagnostics"
 )
 
 const (
@@ -91,6 +92,19 @@ func init() {
 	klog.V(3).Infof("init: complete: cdi controller will create importer using image %q\n", importerImage)
 }
 
+// startDiagnostics serves the diagnostic endpoints if the CDIConfig enables them. The CDIConfig is read once, changes
+// apply when the controller restarts.
+func startDiagnostics(cdiClient clientset.Interface, stopCh <-chan struct{}) {
+	cdiConfig, err := cdiClient.CdiV1alpha1().CDIConfigs().Get(common.ConfigName, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("Unable to get the diagnostics of the CDIConfig, not serving the diagnostic endpoints: %v", err)
+		return
+	}
+	if err := diagnostics.Start(cdiConfig.Spec.Diagnostics, stopCh); err != nil {
+		klog.Errorf("Unable to serve the diagnostic endpoints: %v", err)
+	}
+}
+
 func getRequiredEnvVar(name string) string {
 	val := os.Getenv(name)
 	if val == "" {
@@ -113,6 +127,7 @@ func start(cfg *rest.Config, stopCh <-chan struct{}) {
 	if err != nil {
 		klog.Fatalf("Error building example clientset: %s", err.Error())
 	}
+	startDiagnostics(cdiClient, stopCh)
 
 	extClient, err := extclientset.NewForConfig(cfg)
 	if err != nil {
@@ -142,28 +157,81 @@ func start(cfg *rest.Config, stopCh <-chan struct{}) {
 	}
 	uploadServerCertGenerator := &generator.FetchCertGenerator{Fetcher: uploadServerCAFetcher}
 
+	metricsCAFetcher := &fetcher.FileCertFetcher{Name: "cdi-metrics-signer"}
+	metricsClientBundleFetcher := &fetcher.ConfigMapCertBundleFetcher{
+		Name:   "cdi-metrics-client-signer-bundle",
+		Client: client.CoreV1().ConfigMaps(namespace),
+	}
+	metricsCertGenerator := &generator.FetchCertGenerator{Fetcher: metricsCAFetcher}
+
 	// TODO: Current DV controller had threadiness 3, should we do the same here, defaults to one thread.
 	if _, err := controller.NewDatavolumeController(mgr, cdiClient, client, extClient, log); err != nil {
 		klog.Errorf("Unable to setup datavolume controller: %v", err)
 		os.Exit(1)
 	}
 
-	if _, err := controller.NewImportController(mgr, cdiClient, client, log, importerImage, pullPolicy, verbose); err != nil {
+	if _, err := controller.NewImportController(mgr, cdiClient, client, log, importerImage, pullPolicy, verbose, uploadClientCertGenerator, uploadServerBundleFetcher); err != nil {
 		klog.Errorf("Unable to setup import controller: %v", err)
 		os.Exit(1)
 	}
 
-	if _, err := controller.NewCloneController(mgr, client, log, clonerImage, pullPolicy, verbose, uploadClientCertGenerator, uploadServerBundleFetcher, getAPIServerPublicKey()); err != nil {
+	if _, err := controller.NewExportController(mgr, cdiClient, client, log, importerImage, pullPolicy, verbose); err != nil {
+		klog.Errorf("Unable to setup export controller: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewRegistryCacheController(mgr, log); err != nil {
+		klog.Errorf("Unable to setup registry cache controller: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewRegistryCacheCollector(mgr, log); err != nil {
+		klog.Errorf("Unable to setup registry cache collector: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewCloneController(mgr, client, log, clonerImage, pullPolicy, verbose, uploadClientCertGenerator, uploadServerBundleFetcher, metricsCertGenerator, metricsClientBundleFetcher, getAPIServerKeyWatcher(stopCh)); err != nil {
 		klog.Errorf("Unable to setup clone controller: %v", err)
 		os.Exit(1)
 	}
 
+	if _, err := controller.NewCloneEstimator(mgr, log, clonerImage, pullPolicy); err != nil {
+		klog.Errorf("Unable to setup clone estimator: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewCloneJanitor(mgr, log); err != nil {
+		klog.Errorf("Unable to setup clone janitor: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewTransferPodJanitor(mgr, client, log); err != nil {
+		klog.Errorf("Unable to setup transfer pod janitor: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewActiveTransferTracker(mgr, log); err != nil {
+		klog.Errorf("Unable to setup active transfer tracker: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := controller.NewScratchPool(mgr, namespace, importerImage, pullPolicy, log); err != nil {
+		klog.Errorf("Unable to setup scratch space pool: %v", err)
+		os.Exit(1)
+	}
+
 	startSmartController(extClient, mgr, log)
 
 	if _, err := controller.NewUploadController(mgr, cdiClient, client, log, uploadServerImage, pullPolicy, verbose, uploadServerCertGenerator, uploadClientBundleFetcher); err != nil {
 		klog.Errorf("Unable to setup upload controller: %v", err)
 		os.Exit(1)
 	}
+
+	if _, err := controller.NewUploadJanitor(mgr, log); err != nil {
+		klog.Errorf("Unable to setup upload janitor: %v", err)
+		os.Exit(1)
+	}
+
 	if _, err := controller.NewConfigController(mgr, cdiClient, client, log, uploadProxyServiceName, configName); err != nil {
 		klog.Errorf("Unable to setup config controller: %v", err)
 		os.Exit(1)
@@ -252,19 +320,24 @@ func startSmartController(extclient extclientset.Interface, mgr manager.Manager,
 		if _, err := controller.NewSmartCloneController(mgr, log); err != nil {
 			log.Error(err, "Unable to setup smart clone controller: %v")
 		}
+		if _, err := controller.NewSmartCloneJanitor(mgr, log); err != nil {
+			log.Error(err, "Unable to setup smart clone janitor")
+		}
 	}
 }
 
-func getAPIServerPublicKey() *rsa.PublicKey {
-	keyBytes, err := ioutil.ReadFile(controller.APIServerPublicKeyPath)
+// getAPIServerKeyWatcher returns a watcher of the apiserver public keys, which reloads them when the key is rotated
+func getAPIServerKeyWatcher(stopCh <-chan struct{}) *watcher.KeyWatcher {
+	keyWatcher, err := watcher.NewKeyWatcher(controller.APIServerPublicKeyPath)
 	if err != nil {
-		klog.Fatalf("Error reading apiserver public key")
+		klog.Fatalf("Error reading apiserver public key: %v", err)
 	}
 
-	key, err := controller.DecodePublicKey(keyBytes)
-	if err != nil {
-		klog.Fatalf("Error decoding public key")
-	}
+	go func() {
+		if err := keyWatcher.Start(stopCh); err != nil {
+			klog.Errorf("Error watching apiserver public key: %v", err)
+		}
+	}()
 
-	return key
+	return keyWatcher
 }
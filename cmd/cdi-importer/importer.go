@@ -13,11 +13,14 @@ package main
 //    ImporterSecretKey     Optional. Secret key is the password to your account.
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -30,6 +33,7 @@ import (
 	"kubevirt.io/containerized-data-importer/pkg/image"
 	"kubevirt.io/containerized-data-importer/pkg/importer"
 	"kubevirt.io/containerized-data-importer/pkg/util"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
 	prometheusutil "kubevirt.io/containerized-data-importer/pkg/util/prometheus"
 )
 
@@ -47,6 +51,22 @@ func main() {
 	}
 	defer os.RemoveAll(certsDirectory)
 	prometheusutil.StartPrometheusEndpoint(certsDirectory)
+	ownerUID, _ := util.ParseEnvVar(common.OwnerUID, false)
+	if ownerUID != "" {
+		prometheusutil.StartHeartbeat(ownerUID)
+	}
+	userAgent, _ := util.ParseEnvVar(common.ImporterUserAgent, false)
+	clusterID, _ := util.ParseEnvVar(common.ImporterClusterID, false)
+	importer.SetUserAgent(userAgent, clusterID, ownerUID)
+
+	if destination, _ := util.ParseEnvVar(common.ExporterDestination, false); destination != "" {
+		export(destination)
+		return
+	}
+	if scrub, _ := strconv.ParseBool(os.Getenv(common.ImporterScrubScratch)); scrub {
+		scrubScratch()
+		return
+	}
 
 	klog.V(1).Infoln("Starting importer")
 	ep, _ := util.ParseEnvVar(common.ImporterEndpoint, false)
@@ -57,10 +77,68 @@ func main() {
 	imageSize, _ := util.ParseEnvVar(common.ImporterImageSize, false)
 	certDir, _ := util.ParseEnvVar(common.ImporterCertDirVar, false)
 	insecureTLS, _ := strconv.ParseBool(os.Getenv(common.InsecureTLSVar))
+	insecureSkipHostKeyCheck, _ := strconv.ParseBool(os.Getenv(common.ImporterSSHInsecureSkipHostKeyCheck))
 	diskID, _ := util.ParseEnvVar(common.ImporterDiskID, false)
+	nutanixImageUUID, _ := util.ParseEnvVar(common.ImporterNutanixImageUUID, false)
+	arch, _ := util.ParseEnvVar(common.ImporterArchitecture, false)
+	artifactMediaType, _ := util.ParseEnvVar(common.ImporterArtifactMediaType, false)
+	artifactAnnotation, _ := util.ParseEnvVar(common.ImporterArtifactAnnotation, false)
+	diskPath, _ := util.ParseEnvVar(common.ImporterDiskPath, false)
+	digest, _ := util.ParseEnvVar(common.ImporterChecksum, false)
+	imageCheck, _ := util.ParseEnvVar(common.ImporterImageCheck, false)
+	verification, _ := util.ParseEnvVar(common.ImporterVerification, false)
+	blockWipe, _ := util.ParseEnvVar(common.ImporterBlockWipe, false)
+	imageName, _ := util.ParseEnvVar(common.ImporterImageName, false)
+	var additionalImages []cdiv1.DataVolumeNamedImage
+	if value, _ := util.ParseEnvVar(common.ImporterAdditionalImages, false); value != "" {
+		if err := json.Unmarshal([]byte(value), &additionalImages); err != nil {
+			exitWithError("Invalid additional images", err)
+		}
+	}
+	if value, _ := util.ParseEnvVar(common.ImporterBandwidthLimit, false); value != "" {
+		limit := &cdiv1.DataVolumeBandwidthLimit{}
+		if err := json.Unmarshal([]byte(value), limit); err != nil {
+			exitWithError("Invalid bandwidth limit", err)
+		}
+		importer.SetBandwidthLimit(limit)
+	}
+	// qemu-img reads the passphrases of encrypted images from the mounted secrets
+	if _, err := os.Stat(common.ImporterSourceKeyDir); err == nil {
+		image.SetSourceKeyFile(filepath.Join(common.ImporterSourceKeyDir, common.EncryptionPassphraseKey))
+	}
+	if _, err := os.Stat(common.ImporterTargetKeyDir); err == nil {
+		image.SetTargetKeyFile(filepath.Join(common.ImporterTargetKeyDir, common.EncryptionPassphraseKey))
+	}
+	var blockTarget *importer.BlockTarget
+	if value, _ := util.ParseEnvVar(common.ImporterBlockTargetOffset, false); value != "" {
+		offset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			exitWithError("Invalid block target offset", err)
+		}
+		blockTarget = &importer.BlockTarget{Offset: offset}
+	}
+	if value, _ := util.ParseEnvVar(common.ImporterBlockTargetPartition, false); value != "" {
+		partition, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			exitWithError("Invalid block target partition", err)
+		}
+		blockTarget = &importer.BlockTarget{Partition: int32(partition)}
+	}
+	var backingFileURLs []string
+	if value, _ := util.ParseEnvVar(common.ImporterBackingFileURLs, false); value != "" {
+		backingFileURLs = strings.Split(value, ",")
+	}
+	var mirrors []string
+	if value, _ := util.ParseEnvVar(common.ImporterRegistryMirrors, false); value != "" {
+		mirrors = strings.Split(value, ",")
+	}
+	var insecureMirrors []string
+	if value, _ := util.ParseEnvVar(common.ImporterInsecureRegistryMirrors, false); value != "" {
+		insecureMirrors = strings.Split(value, ",")
+	}
 
 	//Registry import currently support kubevirt content type only
-	if contentType != string(cdiv1.DataVolumeKubeVirt) && (source == controller.SourceRegistry || source == controller.SourceImageio) {
+	if contentType != string(cdiv1.DataVolumeKubeVirt) && (source == controller.SourceRegistry || source == controller.SourceImageio || source == controller.SourceSSH || source == controller.SourceNutanix) {
 		klog.Errorf("Unsupported content type %s when importing from %s", contentType, source)
 		os.Exit(1)
 	}
@@ -70,7 +148,11 @@ func main() {
 		volumeMode = v1.PersistentVolumeFilesystem
 	}
 
-	dest := common.ImporterWritePath
+	imagePath := common.ImporterWritePath
+	if imageName != "" {
+		imagePath = filepath.Join(common.ImporterVolumePath, imageName)
+	}
+	dest := imagePath
 	if contentType == string(cdiv1.DataVolumeArchive) {
 		dest = common.ImporterVolumePath
 	}
@@ -81,17 +163,25 @@ func main() {
 
 	dataDir := common.ImporterDataDir
 	availableDestSpace := util.GetAvailableSpaceByVolumeMode(volumeMode)
+	completeMessage := &util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Import Complete"}
+	var blockTargetWindow *importer.BlockTargetWindow
 	if source == controller.SourceNone && contentType == string(cdiv1.DataVolumeKubeVirt) {
+		if volumeMode == v1.PersistentVolumeBlock && blockWipe != "" {
+			// A blank block volume is only blank once it is wiped
+			if err := importer.WipeBlockDevice(dest, cdiv1.DataVolumeBlockWipe(blockWipe)); err != nil {
+				exitWithError("Unable to wipe the block device", err)
+			}
+		}
 		requestImageSizeQuantity := resource.MustParse(imageSize)
 		minSizeQuantity := util.MinQuantity(resource.NewScaledQuantity(availableDestSpace, 0), &requestImageSizeQuantity)
 		if minSizeQuantity.Cmp(requestImageSizeQuantity) != 0 {
 			// Available dest space is smaller than the size we want to create
 			klog.Warningf("Available space less than requested size, creating blank image sized to available space: %s.\n", minSizeQuantity.String())
 		}
-		err := image.CreateBlankImage(common.ImporterWritePath, minSizeQuantity)
+		err := image.CreateBlankImage(imagePath, minSizeQuantity)
 		if err != nil {
 			klog.Errorf("%+v", err)
-			err = util.WriteTerminationMessage(fmt.Sprintf("Unable to create blank image: %+v", err))
+			err = util.WriteTerminationMessage(util.NewTerminationFailure("Unable to create blank image", err))
 			if err != nil {
 				klog.Errorf("%+v", err)
 			}
@@ -99,50 +189,60 @@ func main() {
 		}
 	} else if source == controller.SourceNone && contentType == string(cdiv1.DataVolumeArchive) {
 		klog.Errorf("%+v", errors.New("Cannot create empty disk with content type archive"))
-		err = util.WriteTerminationMessage("Cannot create empty disk with content type archive")
+		err = util.WriteTerminationMessage(&util.TerminationMessage{Result: util.TerminationFailed, Message: "Cannot create empty disk with content type archive", Retryable: true})
 		if err != nil {
 			klog.Errorf("%+v", err)
 		}
 		os.Exit(1)
 	} else {
 		klog.V(1).Infoln("begin import process")
+		sourceContentType := cdiv1.DataVolumeContentType(contentType)
+		if sourceContentType == cdiv1.DataVolumeImages {
+			// Each of the images is a disk image, kernel or initrd of its own, the additional ones are imported first
+			// so the image at the endpoint is sized to the space they leave
+			sourceContentType = cdiv1.DataVolumeKubeVirt
+			for i, namedImage := range additionalImages {
+				if err := importNamedImage(namedImage, acc, sec, certDir, i > 0); err != nil {
+					exitWithProcessingError(fmt.Sprintf("Unable to import %s", namedImage.Name), err)
+				}
+			}
+		}
 		var dp importer.DataSourceInterface
 		switch source {
 		case controller.SourceHTTP:
-			dp, err = importer.NewHTTPDataSource(ep, acc, sec, certDir, cdiv1.DataVolumeContentType(contentType))
+			dp, err = importer.NewHTTPDataSource(ep, acc, sec, certDir, sourceContentType, backingFileURLs, digest)
 			if err != nil {
-				klog.Errorf("%+v", err)
-				err = util.WriteTerminationMessage(fmt.Sprintf("Unable to connect to http data source: %+v", err))
-				if err != nil {
-					klog.Errorf("%+v", err)
-				}
-				os.Exit(1)
+				exitWithError("Unable to connect to http data source", err)
 			}
 		case controller.SourceImageio:
 			dp, err = importer.NewImageioDataSource(ep, acc, sec, certDir, diskID)
 			if err != nil {
-				klog.Errorf("%+v", err)
-				err = util.WriteTerminationMessage(fmt.Sprintf("Unable to connect to imageio data source: %+v", err))
-				if err != nil {
-					klog.Errorf("%+v", err)
-				}
-				os.Exit(1)
+				exitWithError("Unable to connect to imageio data source", err)
 			}
 		case controller.SourceRegistry:
-			dp = importer.NewRegistryDataSource(ep, acc, sec, certDir, insecureTLS)
+			dp = importer.NewRegistryDataSource(ep, acc, sec, certDir, arch, diskPath, mirrors, insecureMirrors, image.ArtifactSelector{MediaType: artifactMediaType, Annotation: artifactAnnotation}, insecureTLS)
+		case controller.SourceSSH:
+			secretDir := ""
+			if _, err := os.Stat(common.ImporterSSHKeyDir); err == nil {
+				secretDir = common.ImporterSSHKeyDir
+			}
+			dp, err = importer.NewSSHDataSource(ep, secretDir, backingFileURLs, insecureSkipHostKeyCheck)
+			if err != nil {
+				exitWithError("Unable to connect to ssh data source", err)
+			}
+		case controller.SourceNutanix:
+			dp, err = importer.NewNutanixDataSource(ep, acc, sec, certDir, nutanixImageUUID)
+			if err != nil {
+				exitWithError("Unable to connect to nutanix data source", err)
+			}
 		case controller.SourceS3:
-			dp, err = importer.NewS3DataSource(ep, acc, sec)
+			dp, err = importer.NewS3DataSource(ep, acc, sec, digest)
 			if err != nil {
-				klog.Errorf("%+v", err)
-				err = util.WriteTerminationMessage(fmt.Sprintf("Unable to connect to s3 data source: %+v", err))
-				if err != nil {
-					klog.Errorf("%+v", err)
-				}
-				os.Exit(1)
+				exitWithError("Unable to connect to s3 data source", err)
 			}
 		default:
 			klog.Errorf("Unknown source type %s\n", source)
-			err = util.WriteTerminationMessage(fmt.Sprintf("Unknown data source: %s", source))
+			err = util.WriteTerminationMessage(&util.TerminationMessage{Result: util.TerminationFailed, Message: fmt.Sprintf("Unknown data source: %s", source), Retryable: true})
 			if err != nil {
 				klog.Errorf("%+v", err)
 			}
@@ -150,23 +250,179 @@ func main() {
 		}
 		defer dp.Close()
 		processor := importer.NewDataProcessor(dp, dest, dataDir, common.ScratchDataDir, imageSize)
-		err = processor.ProcessData()
+		processor.SetImageCheck(cdiv1.DataVolumeImageCheck(imageCheck))
+		processor.SetVerification(cdiv1.DataVolumeVerification(verification))
+		processor.SetBlockWipe(cdiv1.DataVolumeBlockWipe(blockWipe))
+		if blockTarget != nil {
+			processor.SetBlockTarget(*blockTarget)
+		}
+		processor.SetKeepDataDir(len(additionalImages) > 0 && contentType == string(cdiv1.DataVolumeImages))
+		if err = processor.ProcessData(); err != nil {
+			exitWithProcessingError("Unable to process data", err)
+		}
+		completeMessage.ImageCheck = processor.ImageCheckResult()
+		if blockTargetWindow = processor.BlockTargetWindow(); blockTargetWindow != nil {
+			completeMessage.BlockTargetLayout = blockTargetWindow.Layout()
+		}
+		completeMessage.StageDurations = processor.StageDurations().String()
+		if httpSource, ok := dp.(*importer.HTTPDataSource); ok {
+			completeMessage.ETag, completeMessage.LastModified = httpSource.CacheValidators()
+		}
+	}
+	if value, _ := util.ParseEnvVar(common.ImporterFanOutURLs, false); value != "" {
+		if err := fanOut(dest, strings.Split(value, ",")); err != nil {
+			exitWithError("Unable to write the image to the fan-out targets", err)
+		}
+	}
+	if verification == string(cdiv1.DataVolumeVerificationNone) || verification == string(cdiv1.DataVolumeVerificationFast) {
+		klog.V(1).Infof("Not computing the content digest with %s verification", verification)
+	} else if source == controller.SourceNone {
+		// A blank image is all zeros, which are not part of the digest
+		completeMessage.Digest, _ = checksum.ContentDigest(strings.NewReader(""))
+	} else if blockTargetWindow != nil {
+		// Only the window belongs to the image, the rest of the device is partitioned by the user
+		if digest, err := checksum.ContentDigestSection(dest, blockTargetWindow.Offset, blockTargetWindow.Size); err != nil {
+			klog.Errorf("Unable to compute the content digest: %+v", err)
+		} else {
+			klog.Infof("Content digest %s", digest)
+			completeMessage.Digest = digest
+		}
+	} else if digest, err := checksum.ContentDigestFile(dest); err != nil {
+		klog.Errorf("Unable to compute the content digest: %+v", err)
+	} else if digest != "" {
+		klog.Infof("Content digest %s", digest)
+		completeMessage.Digest = digest
+	}
+	if blockTargetWindow != nil {
+		completeMessage.Bytes = blockTargetWindow.Size
+	} else if contentType != string(cdiv1.DataVolumeArchive) {
+		if completeMessage.Bytes, err = util.DestinationSize(dest); err != nil {
+			klog.Errorf("Unable to size the image: %+v", err)
+		}
+	}
+	err = util.WriteTerminationMessage(completeMessage)
+	if err != nil {
+		klog.Errorf("%+v", err)
+		os.Exit(1)
+	}
+	klog.V(1).Infoln("Import complete")
+}
+
+// exitWithError writes the termination message of err, prefixed with message, and exits. If retrying the import
+// does not resolve err, the message is not retryable and the exit code tells the controller not to retry.
+func exitWithError(message string, err error) {
+	klog.Errorf("%+v", err)
+	exitCode := 1
+	terminationMessage := util.NewTerminationFailure(message, err)
+	if !terminationMessage.Retryable {
+		exitCode = common.PermanentFailureExitCode
+	}
+	if err := util.WriteTerminationMessage(terminationMessage); err != nil {
+		klog.Errorf("%+v", err)
+	}
+	os.Exit(exitCode)
+}
+
+// exitWithProcessingError exits with err of processing data, with the exit codes that have the controller provide or
+// enlarge the scratch space if the data needs more of it.
+func exitWithProcessingError(message string, err error) {
+	if err == importer.ErrRequiresScratchSpace {
+		klog.Errorf("%+v", err)
+		os.Exit(common.ScratchSpaceNeededExitCode)
+	}
+	if _, ok := err.(*importer.ScratchSpaceExhaustedError); ok {
+		klog.Errorf("%+v", err)
+		terminationMessage := &util.TerminationMessage{
+			Result:    util.TerminationFailed,
+			Message:   err.Error(),
+			ErrorCode: util.TerminationErrorScratchSpaceExhausted,
+			Retryable: true,
+		}
+		if err := util.WriteTerminationMessage(terminationMessage); err != nil {
+			klog.Errorf("%+v", err)
+		}
+		os.Exit(common.ScratchSpaceExhaustedExitCode)
+	}
+	exitWithError(message, err)
+}
+
+// fanOut streams the imported image at dest to the upload servers of the fan-out targets at urls
+func fanOut(dest string, urls []string) error {
+	clientCert, _ := util.ParseEnvVar(common.ImporterFanOutClientCert, false)
+	clientKey, _ := util.ParseEnvVar(common.ImporterFanOutClientKey, false)
+	serverCA, _ := util.ParseEnvVar(common.ImporterFanOutServerCACert, false)
+	clients, err := importer.NewFanOutClients([]byte(clientCert), []byte(clientKey), []byte(serverCA))
+	if err != nil {
+		return err
+	}
+	return importer.FanOut(clients, dest, urls)
+}
+
+// importNamedImage imports namedImage, one of the additional images of content type images, to the file of its name.
+// The images imported before it are kept if keepDataDir is set.
+func importNamedImage(namedImage cdiv1.DataVolumeNamedImage, acc, sec, certDir string, keepDataDir bool) error {
+	klog.V(1).Infof("Importing %s to %s", namedImage.URL, namedImage.Name)
+	dp, err := importer.NewHTTPDataSource(namedImage.URL, acc, sec, certDir, cdiv1.DataVolumeKubeVirt, nil, "")
+	if err != nil {
+		return errors.Wrap(err, "Unable to connect to http data source")
+	}
+	defer dp.Close()
+	processor := importer.NewDataProcessor(dp, filepath.Join(common.ImporterVolumePath, namedImage.Name), common.ImporterDataDir, common.ScratchDataDir, "")
+	processor.SetKeepDataDir(keepDataDir)
+	return processor.ProcessData()
+}
+
+// scrubScratch removes the data a transfer left on the volume of the scratch space pool mounted as scratch space.
+func scrubScratch() {
+	klog.V(1).Infoln("Scrubbing scratch space")
+	if err := importer.CleanDir(common.ScratchDataDir); err != nil {
+		klog.Errorf("%+v", err)
+		err = util.WriteTerminationMessage(util.NewTerminationFailure("Unable to scrub scratch space", err))
 		if err != nil {
 			klog.Errorf("%+v", err)
-			if err == importer.ErrRequiresScratchSpace {
-				os.Exit(common.ScratchSpaceNeededExitCode)
-			}
-			err = util.WriteTerminationMessage(fmt.Sprintf("Unable to process data: %+v", err))
-			if err != nil {
-				klog.Errorf("%+v", err)
-			}
-			os.Exit(1)
 		}
+		os.Exit(1)
 	}
-	err = util.WriteTerminationMessage("Import Complete")
+	err := util.WriteTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Scrub Complete"})
 	if err != nil {
 		klog.Errorf("%+v", err)
 		os.Exit(1)
 	}
-	klog.V(1).Infoln("Import complete")
+	klog.V(1).Infoln("Scrub complete")
+}
+
+// export pushes the disk image in the mounted PVC to a registry as a containerDisk.
+func export(destination string) {
+	klog.V(1).Infoln("Starting exporter")
+	acc, _ := util.ParseEnvVar(common.ImporterAccessKeyID, false)
+	sec, _ := util.ParseEnvVar(common.ImporterSecretKey, false)
+	certDir, _ := util.ParseEnvVar(common.ImporterCertDirVar, false)
+	insecureTLS, _ := strconv.ParseBool(os.Getenv(common.InsecureTLSVar))
+
+	src := common.ImporterWritePath
+	if imageName, _ := util.ParseEnvVar(common.ImporterImageName, false); imageName != "" {
+		src = filepath.Join(common.ImporterVolumePath, imageName)
+	}
+	if _, err := os.Stat(common.WriteBlockPath); err == nil {
+		src = common.WriteBlockPath
+	}
+
+	err := importer.ScrubScratchSpace(common.ScratchDataDir)
+	if err == nil {
+		err = image.PushRegistryImage(src, common.ScratchDataDir, destination, acc, sec, certDir, insecureTLS)
+	}
+	if err != nil {
+		klog.Errorf("%+v", err)
+		err = util.WriteTerminationMessage(util.NewTerminationFailure("Unable to export to registry", err))
+		if err != nil {
+			klog.Errorf("%+v", err)
+		}
+		os.Exit(1)
+	}
+	err = util.WriteTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Export Complete"})
+	if err != nil {
+		klog.Errorf("%+v", err)
+		os.Exit(1)
+	}
+	klog.V(1).Infoln("Export complete")
 }
@@ -4,17 +4,24 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
 
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
 	"kubevirt.io/containerized-data-importer/pkg/uploadproxy"
 	"kubevirt.io/containerized-data-importer/pkg/util"
 	certfetcher "kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
 	certwatcher "kubevirt.io/containerized-data-importer/pkg/util/cert/watcher"
+	"kubevirt.io/containerized-data-importer/pkg/util/diagnostics"
 )
 
 const (
@@ -69,7 +76,11 @@ func main() {
 	if err != nil {
 		klog.Fatalf("Unable to get kube client: %v\n", errors.WithStack(err))
 	}
-	apiServerPublicKey, err := getAPIServerPublicKey()
+	cdiClient, err := cdiclient.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Unable to get cdi client: %v\n", errors.WithStack(err))
+	}
+	apiServerKeyWatcher, err := certwatcher.NewKeyWatcher(controller.APIServerPublicKeyPath)
 	if err != nil {
 		klog.Fatalf("Unable to get apiserver public key %v\n", errors.WithStack(err))
 	}
@@ -84,18 +95,30 @@ func main() {
 		Client: client.CoreV1().ConfigMaps(namespace),
 	}
 
+	virtualHostCertWatcher := uploadproxy.NewVirtualHostCertWatcher(client, namespace, getVirtualHostSecrets(), certWatcher)
+
+	cdiConfig := getConfig(cdiClient)
+	stopCh := signals.SetupSignalHandler()
+	if err := diagnostics.Start(cdiConfig.Diagnostics, stopCh); err != nil {
+		klog.Errorf("Unable to serve the diagnostic endpoints: %v", err)
+	}
 	uploadProxy, err := uploadproxy.NewUploadProxy(defaultHost,
 		defaultPort,
-		apiServerPublicKey,
-		certWatcher,
+		apiServerKeyWatcher,
+		virtualHostCertWatcher,
 		clientCertFetcher,
 		serverCAFetcher,
-		client)
+		client,
+		getTrustedProxies(),
+		cdiConfig.UploadTimeouts,
+		stopCh)
 	if err != nil {
 		klog.Fatalf("UploadProxy failed to initialize: %v\n", errors.WithStack(err))
 	}
 
-	go certWatcher.Start(signals.SetupSignalHandler())
+	go certWatcher.Start(stopCh)
+	go apiServerKeyWatcher.Start(stopCh)
+	go virtualHostCertWatcher.Start(stopCh)
 
 	err = uploadProxy.Start()
 	if err != nil {
@@ -103,11 +126,32 @@ func main() {
 	}
 }
 
-func getAPIServerPublicKey() (string, error) {
-	const envName = "APISERVER_PUBLIC_KEY"
-	val, ok := os.LookupEnv(envName)
-	if !ok {
-		return "", errors.Errorf("%s not defined", envName)
+func getVirtualHostSecrets() []string {
+	return splitEnvList(common.UploadProxyVirtualHostSecrets)
+}
+
+func getTrustedProxies() []string {
+	return splitEnvList(common.UploadProxyTrustedProxies)
+}
+
+// getConfig returns the spec of the CDIConfig, an empty spec for the defaults if it cannot be read. It is read once,
+// changes to the upload timeouts and diagnostics apply when the proxy restarts, so uploads in progress are not dropped.
+func getConfig(cdiClient cdiclient.Interface) *cdiv1.CDIConfigSpec {
+	config, err := cdiClient.CdiV1alpha1().CDIConfigs().Get(common.ConfigName, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("Unable to get the CDIConfig, using the defaults: %v", err)
+		return &cdiv1.CDIConfigSpec{}
+	}
+	return &config.Spec
+}
+
+// splitEnvList returns the non-empty values of the comma separated list in the env variable name
+func splitEnvList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
 	}
-	return val, nil
+	return values
 }
@@ -21,17 +21,23 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"time"
 
 	"k8s.io/klog"
 	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/uploadserver"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+	prometheusutil "kubevirt.io/containerized-data-importer/pkg/util/prometheus"
 )
 
 const (
 	defaultListenPort    = 8443
 	defaultListenAddress = "0.0.0.0"
+	// metricsPort serves the heartbeat, the upload itself is served on the default prometheus port
+	metricsPort = 8444
 
 	defaultDestination = common.ImporterWritePath
 )
@@ -44,6 +50,17 @@ func init() {
 func main() {
 	defer klog.Flush()
 
+	// Only uploads report a heartbeat, the source pod of a clone reports the heartbeat of the clone
+	if ownerUID := os.Getenv(common.OwnerUID); ownerUID != "" {
+		certsDirectory, err := ioutil.TempDir("", "certsdir")
+		if err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(certsDirectory)
+		prometheusutil.StartPrometheusEndpointOnPort(certsDirectory, metricsPort)
+		prometheusutil.StartHeartbeat(ownerUID)
+	}
+
 	listenAddress, listenPort := getListenAddressAndPort()
 
 	destination := getDestination()
@@ -57,6 +74,13 @@ func main() {
 		os.Getenv("CLIENT_CERT"),
 		os.Getenv("CLIENT_NAME"),
 		os.Getenv(common.UploadImageSize),
+		os.Getenv(common.UploadVerification),
+		os.Getenv(common.UploadBlockWipe),
+		os.Getenv(common.UploadContentEncodings),
+		os.Getenv(common.UploadContentType),
+		os.Getenv(common.UploadChecksum),
+		getInt(common.UploadMaxConcurrentRequests),
+		getTimeouts(),
 	)
 
 	klog.Infof("Upload destination: %s", destination)
@@ -66,6 +90,30 @@ func main() {
 	err := server.Run()
 	if err != nil {
 		klog.Errorf("UploadServer failed: %s", err)
+		if _, ok := err.(*uploadserver.DestinationNotWritableError); ok {
+			terminationMessage := &util.TerminationMessage{
+				Result:    util.TerminationFailed,
+				Message:   err.Error(),
+				ErrorCode: util.TerminationErrorDestinationNotWritable,
+				Retryable: true,
+			}
+			if err := util.WriteTerminationMessage(terminationMessage); err != nil {
+				klog.Errorf("%+v", err)
+			}
+			os.Exit(common.DestinationNotWritableExitCode)
+		}
+		if _, ok := err.(*uploadserver.CloneVerificationError); ok {
+			terminationMessage := &util.TerminationMessage{
+				Result:    util.TerminationFailed,
+				Message:   err.Error(),
+				ErrorCode: util.TerminationErrorCloneVerificationFailed,
+				Retryable: true,
+			}
+			if err := util.WriteTerminationMessage(terminationMessage); err != nil {
+				klog.Errorf("%+v", err)
+			}
+			os.Exit(common.CloneVerificationFailedExitCode)
+		}
 		os.Exit(1)
 	}
 
@@ -100,3 +148,40 @@ func getDestination() string {
 
 	return destination
 }
+
+func getTimeouts() util.ServerTimeouts {
+	return util.ServerTimeouts{
+		Read:      getDuration(common.UploadReadTimeout),
+		Write:     getDuration(common.UploadWriteTimeout),
+		Idle:      getDuration(common.UploadIdleTimeout),
+		KeepAlive: getDuration(common.UploadKeepAlivePeriod),
+	}
+}
+
+// getDuration returns the duration in the env variable name, zero if it is not set or invalid
+func getDuration(name string) time.Duration {
+	val := os.Getenv(name)
+	if val == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(val)
+	if err != nil {
+		klog.Errorf("Invalid %s %q: %v", name, val, err)
+		return 0
+	}
+	return duration
+}
+
+// getInt returns the number in the env variable name, zero if it is not set or invalid
+func getInt(name string) int {
+	val := os.Getenv(name)
+	if val == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		klog.Errorf("Invalid %s %q: %v", name, val, err)
+		return 0
+	}
+	return n
+}
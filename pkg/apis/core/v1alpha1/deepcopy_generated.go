@@ -23,6 +23,8 @@ package v1alpha1
 import (
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
 	v1 "k8s.io/api/core/v1"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -133,6 +135,63 @@ func (in *CDIConfigSpec) DeepCopyInto(out *CDIConfigSpec) {
 		*out = new(v1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ScratchSpaceMaxSize != nil {
+		in, out := &in.ScratchSpaceMaxSize, &out.ScratchSpaceMaxSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.TransferDeadline != nil {
+		in, out := &in.TransferDeadline, &out.TransferDeadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.CompletedPodRetention != nil {
+		in, out := &in.CompletedPodRetention, &out.CompletedPodRetention
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScratchSpacePool != nil {
+		in, out := &in.ScratchSpacePool, &out.ScratchSpacePool
+		*out = new(ScratchSpacePool)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RegistryCacheRetention != nil {
+		in, out := &in.RegistryCacheRetention, &out.RegistryCacheRetention
+		*out = new(RegistryCacheRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UploadTimeouts != nil {
+		in, out := &in.UploadTimeouts, &out.UploadTimeouts
+		*out = new(UploadTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UploadContentEncodings != nil {
+		in, out := &in.UploadContentEncodings, &out.UploadContentEncodings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityProfiles != nil {
+		in, out := &in.SecurityProfiles, &out.SecurityProfiles
+		*out = new(SecurityProfiles)
+		**out = **in
+	}
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = new(Diagnostics)
+		**out = **in
+	}
+	if in.CloneCompression != nil {
+		in, out := &in.CloneCompression, &out.CloneCompression
+		*out = new(CloneCompression)
+		**out = **in
+	}
+	if in.TransferWindows != nil {
+		in, out := &in.TransferWindows, &out.TransferWindows
+		*out = make([]TransferWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -205,6 +264,99 @@ func (in *CDIList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CDIQuota) DeepCopyInto(out *CDIQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CDIQuota.
+func (in *CDIQuota) DeepCopy() *CDIQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(CDIQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CDIQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CDIQuotaList) DeepCopyInto(out *CDIQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CDIQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CDIQuotaList.
+func (in *CDIQuotaList) DeepCopy() *CDIQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(CDIQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CDIQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CDIQuotaSpec) DeepCopyInto(out *CDIQuotaSpec) {
+	*out = *in
+	if in.MaxTransferPods != nil {
+		in, out := &in.MaxTransferPods, &out.MaxTransferPods
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxScratchSpace != nil {
+		in, out := &in.MaxScratchSpace, &out.MaxScratchSpace
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaxPodRequests != nil {
+		in, out := &in.MaxPodRequests, &out.MaxPodRequests
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CDIQuotaSpec.
+func (in *CDIQuotaSpec) DeepCopy() *CDIQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CDIQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CDISpec) DeepCopyInto(out *CDISpec) {
 	*out = *in
@@ -213,6 +365,21 @@ func (in *CDISpec) DeepCopyInto(out *CDISpec) {
 		*out = new(CDIUninstallStrategy)
 		**out = **in
 	}
+	if in.UploadProxyVirtualHostSecrets != nil {
+		in, out := &in.UploadProxyVirtualHostSecrets, &out.UploadProxyVirtualHostSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UploadProxyIngress != nil {
+		in, out := &in.UploadProxyIngress, &out.UploadProxyIngress
+		*out = new(CDIUploadProxyIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UploadProxyTrustedProxies != nil {
+		in, out := &in.UploadProxyTrustedProxies, &out.UploadProxyTrustedProxies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -249,13 +416,50 @@ func (in *CDIStatus) DeepCopy() *CDIStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CDIUploadProxyIngress) DeepCopyInto(out *CDIUploadProxyIngress) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CDIUploadProxyIngress.
+func (in *CDIUploadProxyIngress) DeepCopy() *CDIUploadProxyIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(CDIUploadProxyIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneCompression) DeepCopyInto(out *CloneCompression) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneCompression.
+func (in *CloneCompression) DeepCopy() *CloneCompression {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneCompression)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataVolume) DeepCopyInto(out *DataVolume) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -278,6 +482,59 @@ func (in *DataVolume) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeBandwidthLimit) DeepCopyInto(out *DataVolumeBandwidthLimit) {
+	*out = *in
+	if in.Rate != nil {
+		in, out := &in.Rate, &out.Rate
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = make([]DataVolumeBandwidthWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeBandwidthLimit.
+func (in *DataVolumeBandwidthLimit) DeepCopy() *DataVolumeBandwidthLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeBandwidthLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeBandwidthWindow) DeepCopyInto(out *DataVolumeBandwidthWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rate != nil {
+		in, out := &in.Rate, &out.Rate
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeBandwidthWindow.
+func (in *DataVolumeBandwidthWindow) DeepCopy() *DataVolumeBandwidthWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeBandwidthWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 func (in *DataVolumeBlankImage) DeepCopyInto(out *DataVolumeBlankImage) {
 	*out = *in
 	return
@@ -293,6 +550,102 @@ func (in *DataVolumeBlankImage) DeepCopy() *DataVolumeBlankImage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeBlockTarget) DeepCopyInto(out *DataVolumeBlockTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeBlockTarget.
+func (in *DataVolumeBlockTarget) DeepCopy() *DataVolumeBlockTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeBlockTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeClaimStatus) DeepCopyInto(out *DataVolumeClaimStatus) {
+	*out = *in
+	if in.VolumeMode != nil {
+		in, out := &in.VolumeMode, &out.VolumeMode
+		*out = new(v1.PersistentVolumeMode)
+		**out = **in
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]v1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.NodeAffinity != nil {
+		in, out := &in.NodeAffinity, &out.NodeAffinity
+		*out = new(v1.VolumeNodeAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeClaimStatus.
+func (in *DataVolumeClaimStatus) DeepCopy() *DataVolumeClaimStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeClaimStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeDNS) DeepCopyInto(out *DataVolumeDNS) {
+	*out = *in
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]v1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeDNS.
+func (in *DataVolumeDNS) DeepCopy() *DataVolumeDNS {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeDNS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeEncryption) DeepCopyInto(out *DataVolumeEncryption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeEncryption.
+func (in *DataVolumeEncryption) DeepCopy() *DataVolumeEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataVolumeList) DeepCopyInto(out *DataVolumeList) {
 	*out = *in
@@ -326,13 +679,46 @@ func (in *DataVolumeList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeNamedImage) DeepCopyInto(out *DataVolumeNamedImage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeNamedImage.
+func (in *DataVolumeNamedImage) DeepCopy() *DataVolumeNamedImage {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeNamedImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumePhaseTransition) DeepCopyInto(out *DataVolumePhaseTransition) {
+	*out = *in
+	in.TransitionTime.DeepCopyInto(&out.TransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumePhaseTransition.
+func (in *DataVolumePhaseTransition) DeepCopy() *DataVolumePhaseTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumePhaseTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataVolumeSource) DeepCopyInto(out *DataVolumeSource) {
 	*out = *in
 	if in.HTTP != nil {
 		in, out := &in.HTTP, &out.HTTP
 		*out = new(DataVolumeSourceHTTP)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.S3 != nil {
 		in, out := &in.S3, &out.S3
@@ -364,6 +750,16 @@ func (in *DataVolumeSource) DeepCopyInto(out *DataVolumeSource) {
 		*out = new(DataVolumeSourceImageIO)
 		**out = **in
 	}
+	if in.SSH != nil {
+		in, out := &in.SSH, &out.SSH
+		*out = new(DataVolumeSourceSSH)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Nutanix != nil {
+		in, out := &in.Nutanix, &out.Nutanix
+		*out = new(DataVolumeSourceNutanix)
+		**out = **in
+	}
 	return
 }
 
@@ -380,6 +776,16 @@ func (in *DataVolumeSource) DeepCopy() *DataVolumeSource {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataVolumeSourceHTTP) DeepCopyInto(out *DataVolumeSourceHTTP) {
 	*out = *in
+	if in.BackingFileURLs != nil {
+		in, out := &in.BackingFileURLs, &out.BackingFileURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalImages != nil {
+		in, out := &in.AdditionalImages, &out.AdditionalImages
+		*out = make([]DataVolumeNamedImage, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -409,6 +815,22 @@ func (in *DataVolumeSourceImageIO) DeepCopy() *DataVolumeSourceImageIO {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeSourceNutanix) DeepCopyInto(out *DataVolumeSourceNutanix) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeSourceNutanix.
+func (in *DataVolumeSourceNutanix) DeepCopy() *DataVolumeSourceNutanix {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeSourceNutanix)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataVolumeSourcePVC) DeepCopyInto(out *DataVolumeSourcePVC) {
 	*out = *in
@@ -458,7 +880,28 @@ func (in *DataVolumeSourceS3) DeepCopy() *DataVolumeSourceS3 {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DataVolumeSourceUpload) DeepCopyInto(out *DataVolumeSourceUpload) {
+func (in *DataVolumeSourceSSH) DeepCopyInto(out *DataVolumeSourceSSH) {
+	*out = *in
+	if in.BackingFileURLs != nil {
+		in, out := &in.BackingFileURLs, &out.BackingFileURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeSourceSSH.
+func (in *DataVolumeSourceSSH) DeepCopy() *DataVolumeSourceSSH {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeSourceSSH)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeSourceUpload) DeepCopyInto(out *DataVolumeSourceUpload) {
 	*out = *in
 	return
 }
@@ -482,6 +925,41 @@ func (in *DataVolumeSpec) DeepCopyInto(out *DataVolumeSpec) {
 		*out = new(v1.PersistentVolumeClaimSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(DataVolumeDNS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlockTarget != nil {
+		in, out := &in.BlockTarget, &out.BlockTarget
+		*out = new(DataVolumeBlockTarget)
+		**out = **in
+	}
+	if in.FanOut != nil {
+		in, out := &in.FanOut, &out.FanOut
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(DataVolumeTemplateRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BandwidthLimit != nil {
+		in, out := &in.BandwidthLimit, &out.BandwidthLimit
+		*out = new(DataVolumeBandwidthLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(DataVolumeEncryption)
+		**out = **in
+	}
 	return
 }
 
@@ -495,9 +973,64 @@ func (in *DataVolumeSpec) DeepCopy() *DataVolumeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeStageDurations) DeepCopyInto(out *DataVolumeStageDurations) {
+	*out = *in
+	if in.Download != nil {
+		in, out := &in.Download, &out.Download
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Convert != nil {
+		in, out := &in.Convert, &out.Convert
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Resize != nil {
+		in, out := &in.Resize, &out.Resize
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeStageDurations.
+func (in *DataVolumeStageDurations) DeepCopy() *DataVolumeStageDurations {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeStageDurations)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataVolumeStatus) DeepCopyInto(out *DataVolumeStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]conditionsv1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Claim != nil {
+		in, out := &in.Claim, &out.Claim
+		*out = new(DataVolumeClaimStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PhaseTransitions != nil {
+		in, out := &in.PhaseTransitions, &out.PhaseTransitions
+		*out = make([]DataVolumePhaseTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StageDurations != nil {
+		in, out := &in.StageDurations, &out.StageDurations
+		*out = new(DataVolumeStageDurations)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -510,3 +1043,368 @@ func (in *DataVolumeStatus) DeepCopy() *DataVolumeStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeTemplate) DeepCopyInto(out *DataVolumeTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeTemplate.
+func (in *DataVolumeTemplate) DeepCopy() *DataVolumeTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DataVolumeTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeTemplateList) DeepCopyInto(out *DataVolumeTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DataVolumeTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeTemplateList.
+func (in *DataVolumeTemplateList) DeepCopy() *DataVolumeTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DataVolumeTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeTemplateParameter) DeepCopyInto(out *DataVolumeTemplateParameter) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeTemplateParameter.
+func (in *DataVolumeTemplateParameter) DeepCopy() *DataVolumeTemplateParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeTemplateParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeTemplateRef) DeepCopyInto(out *DataVolumeTemplateRef) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeTemplateRef.
+func (in *DataVolumeTemplateRef) DeepCopy() *DataVolumeTemplateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeTemplateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataVolumeTemplateSpec) DeepCopyInto(out *DataVolumeTemplateSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]DataVolumeTemplateParameter, len(*in))
+		copy(*out, *in)
+	}
+	in.DataVolume.DeepCopyInto(&out.DataVolume)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataVolumeTemplateSpec.
+func (in *DataVolumeTemplateSpec) DeepCopy() *DataVolumeTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DataVolumeTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Diagnostics) DeepCopyInto(out *Diagnostics) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Diagnostics.
+func (in *Diagnostics) DeepCopy() *Diagnostics {
+	if in == nil {
+		return nil
+	}
+	out := new(Diagnostics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationHistory) DeepCopyInto(out *OperationHistory) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationHistory.
+func (in *OperationHistory) DeepCopy() *OperationHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationHistory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperationHistory) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationHistoryList) DeepCopyInto(out *OperationHistoryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OperationHistory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationHistoryList.
+func (in *OperationHistoryList) DeepCopy() *OperationHistoryList {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationHistoryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperationHistoryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationHistoryStatus) DeepCopyInto(out *OperationHistoryStatus) {
+	*out = *in
+	if in.Operations != nil {
+		in, out := &in.Operations, &out.Operations
+		*out = make([]OperationRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationHistoryStatus.
+func (in *OperationHistoryStatus) DeepCopy() *OperationHistoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationHistoryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationRecord) DeepCopyInto(out *OperationRecord) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.CompletionTime.DeepCopyInto(&out.CompletionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationRecord.
+func (in *OperationRecord) DeepCopy() *OperationRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCacheRetention) DeepCopyInto(out *RegistryCacheRetention) {
+	*out = *in
+	if in.KeepLast != nil {
+		in, out := &in.KeepLast, &out.KeepLast
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KeepFor != nil {
+		in, out := &in.KeepFor, &out.KeepFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCacheRetention.
+func (in *RegistryCacheRetention) DeepCopy() *RegistryCacheRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCacheRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScratchSpacePool) DeepCopyInto(out *ScratchSpacePool) {
+	*out = *in
+	if in.SizeClasses != nil {
+		in, out := &in.SizeClasses, &out.SizeClasses
+		*out = make([]resource.Quantity, len(*in))
+		for i := range *in {
+			(*out)[i] = (*in)[i].DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScratchSpacePool.
+func (in *ScratchSpacePool) DeepCopy() *ScratchSpacePool {
+	if in == nil {
+		return nil
+	}
+	out := new(ScratchSpacePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecurityProfiles) DeepCopyInto(out *SecurityProfiles) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityProfiles.
+func (in *SecurityProfiles) DeepCopy() *SecurityProfiles {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityProfiles)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransferWindow) DeepCopyInto(out *TransferWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransferWindow.
+func (in *TransferWindow) DeepCopy() *TransferWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(TransferWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UploadTimeouts) DeepCopyInto(out *UploadTimeouts) {
+	*out = *in
+	if in.ReadTimeout != nil {
+		in, out := &in.ReadTimeout, &out.ReadTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.WriteTimeout != nil {
+		in, out := &in.WriteTimeout, &out.WriteTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.IdleTimeout != nil {
+		in, out := &in.IdleTimeout, &out.IdleTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.KeepAlivePeriod != nil {
+		in, out := &in.KeepAlivePeriod, &out.KeepAlivePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ProxyRequestTimeout != nil {
+		in, out := &in.ProxyRequestTimeout, &out.ProxyRequestTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UploadTimeouts.
+func (in *UploadTimeouts) DeepCopy() *UploadTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(UploadTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
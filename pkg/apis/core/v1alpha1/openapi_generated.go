@@ -1,3 +1,4 @@
+//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
 
 /*
@@ -29,26 +30,57 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDI":                      schema_pkg_apis_core_v1alpha1_CDI(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfig":                schema_pkg_apis_core_v1alpha1_CDIConfig(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfigList":            schema_pkg_apis_core_v1alpha1_CDIConfigList(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfigSpec":            schema_pkg_apis_core_v1alpha1_CDIConfigSpec(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfigStatus":          schema_pkg_apis_core_v1alpha1_CDIConfigStatus(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIList":                  schema_pkg_apis_core_v1alpha1_CDIList(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDISpec":                  schema_pkg_apis_core_v1alpha1_CDISpec(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIStatus":                schema_pkg_apis_core_v1alpha1_CDIStatus(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolume":               schema_pkg_apis_core_v1alpha1_DataVolume(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlankImage":     schema_pkg_apis_core_v1alpha1_DataVolumeBlankImage(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeList":           schema_pkg_apis_core_v1alpha1_DataVolumeList(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSource":         schema_pkg_apis_core_v1alpha1_DataVolumeSource(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceHTTP":     schema_pkg_apis_core_v1alpha1_DataVolumeSourceHTTP(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceImageIO":  schema_pkg_apis_core_v1alpha1_DataVolumeSourceImageIO(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourcePVC":      schema_pkg_apis_core_v1alpha1_DataVolumeSourcePVC(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceRegistry": schema_pkg_apis_core_v1alpha1_DataVolumeSourceRegistry(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceS3":       schema_pkg_apis_core_v1alpha1_DataVolumeSourceS3(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceUpload":   schema_pkg_apis_core_v1alpha1_DataVolumeSourceUpload(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec":           schema_pkg_apis_core_v1alpha1_DataVolumeSpec(ref),
-		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStatus":         schema_pkg_apis_core_v1alpha1_DataVolumeStatus(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDI":                         schema_pkg_apis_core_v1alpha1_CDI(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfig":                   schema_pkg_apis_core_v1alpha1_CDIConfig(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfigList":               schema_pkg_apis_core_v1alpha1_CDIConfigList(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfigSpec":               schema_pkg_apis_core_v1alpha1_CDIConfigSpec(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIConfigStatus":             schema_pkg_apis_core_v1alpha1_CDIConfigStatus(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIList":                     schema_pkg_apis_core_v1alpha1_CDIList(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuota":                    schema_pkg_apis_core_v1alpha1_CDIQuota(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuotaList":                schema_pkg_apis_core_v1alpha1_CDIQuotaList(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuotaSpec":                schema_pkg_apis_core_v1alpha1_CDIQuotaSpec(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDISpec":                     schema_pkg_apis_core_v1alpha1_CDISpec(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIStatus":                   schema_pkg_apis_core_v1alpha1_CDIStatus(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIUploadProxyIngress":       schema_pkg_apis_core_v1alpha1_CDIUploadProxyIngress(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CloneCompression":            schema_pkg_apis_core_v1alpha1_CloneCompression(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolume":                  schema_pkg_apis_core_v1alpha1_DataVolume(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlankImage":        schema_pkg_apis_core_v1alpha1_DataVolumeBlankImage(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBandwidthLimit":    schema_pkg_apis_core_v1alpha1_DataVolumeBandwidthLimit(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBandwidthWindow":   schema_pkg_apis_core_v1alpha1_DataVolumeBandwidthWindow(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlockTarget":       schema_pkg_apis_core_v1alpha1_DataVolumeBlockTarget(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeClaimStatus":       schema_pkg_apis_core_v1alpha1_DataVolumeClaimStatus(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeDNS":               schema_pkg_apis_core_v1alpha1_DataVolumeDNS(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeEncryption":        schema_pkg_apis_core_v1alpha1_DataVolumeEncryption(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeList":              schema_pkg_apis_core_v1alpha1_DataVolumeList(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeNamedImage":        schema_pkg_apis_core_v1alpha1_DataVolumeNamedImage(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumePhaseTransition":   schema_pkg_apis_core_v1alpha1_DataVolumePhaseTransition(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSource":            schema_pkg_apis_core_v1alpha1_DataVolumeSource(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceHTTP":        schema_pkg_apis_core_v1alpha1_DataVolumeSourceHTTP(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceImageIO":     schema_pkg_apis_core_v1alpha1_DataVolumeSourceImageIO(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceNutanix":     schema_pkg_apis_core_v1alpha1_DataVolumeSourceNutanix(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourcePVC":         schema_pkg_apis_core_v1alpha1_DataVolumeSourcePVC(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceRegistry":    schema_pkg_apis_core_v1alpha1_DataVolumeSourceRegistry(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceS3":          schema_pkg_apis_core_v1alpha1_DataVolumeSourceS3(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceSSH":         schema_pkg_apis_core_v1alpha1_DataVolumeSourceSSH(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceUpload":      schema_pkg_apis_core_v1alpha1_DataVolumeSourceUpload(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec":              schema_pkg_apis_core_v1alpha1_DataVolumeSpec(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStageDurations":    schema_pkg_apis_core_v1alpha1_DataVolumeStageDurations(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStatus":            schema_pkg_apis_core_v1alpha1_DataVolumeStatus(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplate":          schema_pkg_apis_core_v1alpha1_DataVolumeTemplate(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateList":      schema_pkg_apis_core_v1alpha1_DataVolumeTemplateList(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateParameter": schema_pkg_apis_core_v1alpha1_DataVolumeTemplateParameter(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateRef":       schema_pkg_apis_core_v1alpha1_DataVolumeTemplateRef(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateSpec":      schema_pkg_apis_core_v1alpha1_DataVolumeTemplateSpec(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.Diagnostics":                 schema_pkg_apis_core_v1alpha1_Diagnostics(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistory":            schema_pkg_apis_core_v1alpha1_OperationHistory(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistoryList":        schema_pkg_apis_core_v1alpha1_OperationHistoryList(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistoryStatus":      schema_pkg_apis_core_v1alpha1_OperationHistoryStatus(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationRecord":             schema_pkg_apis_core_v1alpha1_OperationRecord(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.RegistryCacheRetention":      schema_pkg_apis_core_v1alpha1_RegistryCacheRetention(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.ScratchSpacePool":            schema_pkg_apis_core_v1alpha1_ScratchSpacePool(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.SecurityProfiles":            schema_pkg_apis_core_v1alpha1_SecurityProfiles(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.TransferWindow":              schema_pkg_apis_core_v1alpha1_TransferWindow(ref),
+		"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.UploadTimeouts":              schema_pkg_apis_core_v1alpha1_UploadTimeouts(ref),
 	}
 }
 
@@ -214,11 +246,134 @@ func schema_pkg_apis_core_v1alpha1_CDIConfigSpec(ref common.ReferenceCallback) c
 							Ref: ref("k8s.io/api/core/v1.ResourceRequirements"),
 						},
 					},
+					"scratchSpaceMaxSize": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ScratchSpaceMaxSize is the largest scratch space an import may be retried with after its scratch space ran out, scratch space is not enlarged if it is not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+					"transferDeadline": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TransferDeadline is how long a transfer pod may run before it is terminated, unless its data volume sets a deadline, transfer pods run until they are done if it is not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"completedPodRetention": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CompletedPodRetention is how long succeeded importer, clone source and upload server pods, and the services of the upload servers, are kept before they are deleted, they are deleted right away if it is not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"preserveTransferLogs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PreserveTransferLogs keeps the end of the log of a transfer pod in an annotation of its PVC when the pod is deleted after the CompletedPodRetention",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"scratchSpacePool": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ScratchSpacePool keeps the volumes of completed scratch spaces for later scratch spaces, so they are not provisioned and deleted for each transfer",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.ScratchSpacePool"),
+						},
+					},
+					"registryCacheRetention": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RegistryCacheRetention decides which versions of an image in the registry cache are deleted, cache entries are kept until they are deleted by hand if it is not set",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.RegistryCacheRetention"),
+						},
+					},
+					"defaultVerification": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DefaultVerification is how thoroughly the data written to a data volume that sets no verification is verified, options: \"none\", \"fast\", \"full\", defaults to full",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"uploadTimeouts": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UploadTimeouts are the timeouts and TCP keep-alive period of the connections of the upload proxy and upload servers, so slow uploads are not dropped, the upload proxy applies changes when it restarts",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.UploadTimeouts"),
+						},
+					},
+					"excludeFromServiceMesh": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExcludeFromServiceMesh keeps the sidecars of service meshes such as Istio and Linkerd out of transfer pods, which would keep running with a sidecar after the transfer and whose TLS connections a sidecar would wrap in its own mTLS",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"uploadContentEncodings": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UploadContentEncodings are the Content-Encodings of uploads the upload servers decompress on the fly, options: \"gzip\", compressed uploads are refused if it is not set",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"uploadMaxConcurrentRequests": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UploadMaxConcurrentRequests is how many requests with upload data an upload server takes at once, to bound its memory, further requests are answered with 429 until one finished, defaults to 4",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"securityProfiles": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecurityProfiles applies the seccomp and AppArmor profiles CDI ships to importer, upload server, clone source and exporter pods, the profiles have to be installed on the nodes",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.SecurityProfiles"),
+						},
+					},
+					"importUserAgent": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImportUserAgent is the product the importer names in the User-Agent of its HTTP and S3 requests, followed by the ID of the cluster and the UID of the data volume, defaults to containerized-data-importer",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"diagnostics": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Diagnostics serves the pprof profiles and the expvar runtime metrics of the CDI controller and the upload proxy on their localhost, to be reached with kubectl port-forward, the endpoints are not served if it is not set, the processes apply changes when they restart",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.Diagnostics"),
+						},
+					},
+					"cloneCompression": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CloneCompression is how the clone source pods compress the data of host-assisted clones on the way to the upload servers, gzip at its default level if it is not set",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CloneCompression"),
+						},
+					},
+					"transferWindows": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TransferWindows are the times of the week low priority imports and host-assisted clones may start in, a namespace can set its own with the cdi.kubevirt.io/transferWindows annotation, they start at any time if it is not set",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.TransferWindow"),
+									},
+								},
+							},
+						},
+					},
+					"annotateTransferNodes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "AnnotateTransferNodes annotates the nodes running importer, upload server and clone source pods with the number of those pods, and keeps the cluster autoscaler from scaling them down until the transfers are done",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/api/core/v1.ResourceRequirements"},
+			"k8s.io/api/core/v1.ResourceRequirements", "k8s.io/apimachinery/pkg/api/resource.Quantity", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CloneCompression", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.Diagnostics", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.RegistryCacheRetention", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.ScratchSpacePool", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.SecurityProfiles", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.TransferWindow", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.UploadTimeouts"},
 	}
 }
 
@@ -302,6 +457,136 @@ func schema_pkg_apis_core_v1alpha1_CDIList(ref common.ReferenceCallback) common.
 	}
 }
 
+func schema_pkg_apis_core_v1alpha1_CDIQuota(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CDIQuota caps the resources the transfer pods of CDI may use in its namespace. A transfer that would exceed a CDIQuota is queued until enough transfers of the namespace complete.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuotaSpec"),
+						},
+					},
+				},
+				Required: []string{"spec"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuotaSpec"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_CDIQuotaList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CDIQuotaList provides the needed parameters to do request a list of CDIQuotas from the system",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"),
+						},
+					},
+					"items": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Items provides a list of CDIQuotas",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuota"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"metadata", "items"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIQuota"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_CDIQuotaSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CDIQuotaSpec defines the limits of a CDIQuota, a limit that is not set does not apply",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"maxTransferPods": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxTransferPods is the number of importer, upload server and clone source pods that may exist at the same time",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"maxScratchSpace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxScratchSpace is the total storage the scratch space PVCs may request",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+					"maxPodRequests": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MaxPodRequests is the total of the resources, such as cpu and memory, the transfer pods may request",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity"},
+	}
+}
+
 func schema_pkg_apis_core_v1alpha1_CDISpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -311,78 +596,1476 @@ func schema_pkg_apis_core_v1alpha1_CDISpec(ref common.ReferenceCallback) common.
 				Properties: map[string]spec.Schema{
 					"imagePullPolicy": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"uninstallStrategy": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"uploadProxyVirtualHostSecrets": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UploadProxyVirtualHostSecrets are the names of the TLS Secrets in the CDI namespace with the serving certificates of additional hostnames of the upload proxy",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"uploadProxyIngress": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UploadProxyIngress is how the operator exposes the upload proxy outside the cluster, with a Route on OpenShift and an Ingress elsewhere",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIUploadProxyIngress"),
+						},
+					},
+					"uploadProxyTrustedProxies": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UploadProxyTrustedProxies are the CIDRs of the load balancers in front of the upload proxy, whose PROXY protocol and X-Forwarded-For and X-Forwarded-Proto headers tell the address of the client",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"tokenSigningAlgorithm": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TokenSigningAlgorithm is the algorithm the apiserver signs the upload and clone tokens with, PS256 with an RSA key, the default, or ES256 with an ECDSA P-256 key. Changing it rotates the signing key",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.CDIUploadProxyIngress"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_CDIStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CDIStatus defines the status of the CDI installation",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"phase": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/openshift/custom-resource-status/conditions/v1.Condition"),
+									},
+								},
+							},
+						},
+					},
+					"operatorVersion": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"targetVersion": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"observedVersion": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/openshift/custom-resource-status/conditions/v1.Condition"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_CDIUploadProxyIngress(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CDIUploadProxyIngress defines the Route or Ingress of the upload proxy",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"host": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Host is the hostname clients upload to, a Route without it gets a generated hostname, an Ingress is only created with it",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tlsTermination": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TLSTermination is where the TLS connection of clients ends, options: \"reencrypt\", \"passthrough\", defaults to \"reencrypt\"",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tlsSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TLSSecret is the name of the TLS Secret in the CDI namespace with the certificate of Host, used to terminate TLS at an Ingress",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"ingressClass": {
+						SchemaProps: spec.SchemaProps{
+							Description: "IngressClass is the class of the Ingress controller that serves the Ingress",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"timeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeout is how long the Route or Ingress waits for the upload proxy to answer, uploads of large images that are converted need a long one, defaults to 60m",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_CloneCompression(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CloneCompression defines the compression of the data stream of host-assisted clones",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"encoding": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Encoding is the Content-Encoding of the stream, options: \"gzip\", \"zstd\", \"identity\" to send it uncompressed, an encoding the upload server does not accept falls back to gzip, then identity, defaults to gzip",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"level": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Level is the compression level, higher levels compress smaller at a higher CPU cost, 1 to 9 for gzip, defaults to the default level of the encoding",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolume(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolume provides a representation of our data volume",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStatus"),
+						},
+					},
+				},
+				Required: []string{"spec"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStatus"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeBlankImage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeBlankImage provides the parameters to create a new raw blank image for the PVC",
+				Type:        []string{"object"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeBandwidthLimit(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeBandwidthLimit provides the rate the importer of a Data Volume reads its source at, at most",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"rate": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Rate is the bytes per second the importer reads its source at, at most, outside of the windows of the schedule, unlimited if not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+					"schedule": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Schedule are windows of the week with a rate of their own, the first window the time is in applies",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBandwidthWindow"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBandwidthWindow"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeBandwidthWindow(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeBandwidthWindow provides a window of the week with a bandwidth limit of its own",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"days": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Days are the days of the week the window starts on, options: \"Mon\", \"Tue\", \"Wed\", \"Thu\", \"Fri\", \"Sat\", \"Sun\", every day if none",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"start": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Start is the time of day in UTC the window starts at, as HH:MM",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"end": {
+						SchemaProps: spec.SchemaProps{
+							Description: "End is the time of day in UTC the window ends at, as HH:MM, a window that ends before it starts ends the next day",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"rate": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Rate is the bytes per second the importer reads its source at, at most, in the window, unlimited if not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+				},
+				Required: []string{"start", "end"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeBlockTarget(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeBlockTarget provides where an image is written to a block volume, at most one of its fields is set",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"offset": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Offset is the byte offset of a block volume the image is written at, a multiple of 512",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"partition": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Partition is the number of the partition of the GPT or MBR partition table of a block volume the image is written into, starting at 1",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeClaimStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeClaimStatus is the effective volume mode, access modes, storage class and node topology of the PVC of a data volume, once defaults, a volume mode fallback and the provisioner filled them in",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"volumeMode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "VolumeMode is the volume mode of the PVC",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"accessModes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "AccessModes are the access modes of the PVC, those of its volume once it is bound",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"storageClassName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StorageClassName is the storage class of the PVC, the default storage class if the data volume names none",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"volumeName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "VolumeName is the name of the persistent volume the PVC is bound to",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"selectedNode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SelectedNode is the node the PVC was provisioned for, with a storage class that waits for the first consumer",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"nodeAffinity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodeAffinity is the node affinity of the bound volume, the nodes the data volume can be used from",
+							Ref:         ref("k8s.io/api/core/v1.VolumeNodeAffinity"),
+						},
+					},
+					"cloneSizing": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CloneSizing tells how the storage request of the PVC of a clone was derived from its source in another volume mode",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.VolumeNodeAffinity"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeDNS(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeDNS provides the host aliases and DNS settings of the importer pod of a Data Volume",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"hostAliases": {
+						SchemaProps: spec.SchemaProps{
+							Description: "HostAliases are added to the hosts file of the importer pod",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.HostAlias"),
+									},
+								},
+							},
+						},
+					},
+					"policy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Policy is the DNS policy of the importer pod, options: \"ClusterFirst\", \"Default\", \"None\", defaults to ClusterFirst",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"config": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Config is merged with the DNS settings the policy generates, it needs nameservers with policy None",
+							Ref:         ref("k8s.io/api/core/v1.PodDNSConfig"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.HostAlias", "k8s.io/api/core/v1.PodDNSConfig"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeEncryption(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeEncryption provides the Secrets with the passphrases of the encrypted images of an import, in the \"passphrase\" key",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"sourceSecretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SourceSecretRef is the name of the Secret with the passphrase that decrypts a LUKS or encrypted qcow2 source image",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"targetSecretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TargetSecretRef is the name of the Secret with the passphrase the imported image is encrypted with as LUKS, the image is written unencrypted if not set",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeList provides the needed parameters to do request a list of Data Volumes from the system",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"),
+						},
+					},
+					"items": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Items provides a list of DataVolumes",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolume"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"metadata", "items"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolume"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeNamedImage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeNamedImage provides an image imported to a file of its own on a file system volume",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is the name of the file the image is written to",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the URL of the http source of the image",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name", "url"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumePhaseTransition(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumePhaseTransition is a phase a data volume entered",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"phase": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Phase is the phase the data volume entered",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"transitionTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TransitionTime is when the data volume entered the phase",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+				Required: []string{"phase", "transitionTime"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSource(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSource represents the source for our Data Volume, this can be HTTP, Imageio, S3, Registry, SSH, Nutanix or an existing PVC",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"http": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceHTTP"),
+						},
+					},
+					"s3": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceS3"),
+						},
+					},
+					"registry": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceRegistry"),
+						},
+					},
+					"pvc": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourcePVC"),
+						},
+					},
+					"upload": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceUpload"),
+						},
+					},
+					"blank": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlankImage"),
+						},
+					},
+					"imageio": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceImageIO"),
+						},
+					},
+					"ssh": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceSSH"),
+						},
+					},
+					"nutanix": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceNutanix"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlankImage", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceHTTP", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceImageIO", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceNutanix", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourcePVC", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceRegistry", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceS3", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceSSH", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceUpload"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceHTTP(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceHTTP provides the parameters to create a Data Volume from an HTTP source",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the URL of the http source",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"secretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretRef provides the secret reference needed to access the HTTP source",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"certConfigMap": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CertConfigMap provides a reference to the Registry certs",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"backingFileURLs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BackingFileURLs are the URL prefixes the backing files of a qcow2 image may be fetched from, the backing chain is then flattened into the imported disk. Images with backing files are rejected when empty",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"checksum": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Checksum is the digest the image at URL is pinned to, in the form sha256:<hex> or sha512:<hex>, the import fails with the ChecksumMismatch condition when the image does not match",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"additionalImages": {
+						SchemaProps: spec.SchemaProps{
+							Description: "AdditionalImages are imported next to the image at URL with content type \"images\", each to the file of its name",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeNamedImage"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeNamedImage"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceImageIO(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceImageIO provides the parameters to create a Data Volume from an imageio source",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the URL of the ovirt-engine",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"diskId": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DiskID provides id of a disk to be imported",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"secretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretRef provides the secret reference needed to access the ovirt-engine",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"certConfigMap": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CertConfigMap provides a reference to the CA cert",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceNutanix(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceNutanix provides the parameters to create a Data Volume from an image of the Nutanix AHV image service",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the URL of the Prism Central API, https://host:9440",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imageUUID": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImageUUID is the uuid of the image to import",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"secretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretRef provides the secret reference needed to access the Prism Central API",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"certConfigMap": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CertConfigMap provides a reference to the CA cert",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourcePVC(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourcePVC provides the parameters to create a Data Volume from an existing PVC",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"namespace": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceRegistry(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceRegistry provides the parameters to create a Data Volume from an registry source",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the url of the Registry source",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"secretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretRef provides the secret reference needed to access the Registry source",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"certConfigMap": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CertConfigMap provides a reference to the Registry certs",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"architecture": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Architecture selects the image to import when the source is a multi-arch manifest list, defaults to the architecture of the node running the import",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"diskPath": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DiskPath is a glob matching the path of the disk image in the image layers, for images that do not follow the containerDisk layout with the disk in /disk",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"artifactMediaType": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ArtifactMediaType selects the disk image blob of an OCI artifact with several blobs by its media type",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"artifactAnnotation": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ArtifactAnnotation selects the disk image blob of an OCI artifact with several blobs by one of its annotations, in the form key or key=value",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceS3(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceS3 provides the parameters to create a Data Volume from an S3 source",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the url of the S3 source",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"secretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretRef provides the secret reference needed to access the S3 source",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"checksum": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Checksum is the digest the S3 object is pinned to, in the form sha256:<hex> or sha512:<hex>, the import fails with the ChecksumMismatch condition when the object does not match",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceSSH(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceSSH provides the parameters to create a Data Volume from a file or block device on a host reachable over SSH",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"url": {
+						SchemaProps: spec.SchemaProps{
+							Description: "URL is the location of the disk, ssh://[user@]host[:port]/path",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"secretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretRef provides the secret holding the ssh-privatekey used to log in, and the known_hosts the key of the host is verified with",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"backingFileURLs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BackingFileURLs are the URL prefixes the backing files of a qcow2 image may be fetched from, the backing chain is then flattened into the imported disk. Images with backing files are rejected when empty",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"insecureSkipHostKeyCheck": {
+						SchemaProps: spec.SchemaProps{
+							Description: "InsecureSkipHostKeyCheck allows importing from a host whose key is not in known_hosts, without verifying the key. The import fails without known_hosts when unset",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSourceUpload(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSourceUpload provides the parameters to create a Data Volume by uploading the source",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"checksum": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Checksum is the digest the state blob of a kubevirt-state upload is verified against, in the form sha256:<hex> or sha512:<hex>, an upload that does not match is refused and can be sent again",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeSpec defines our specification for a DataVolume type",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"source": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Source is the src of the data for the requested DataVolume",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSource"),
+						},
+					},
+					"pvc": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PVC is a pointer to the PVC Spec we want to use",
+							Ref:         ref("k8s.io/api/core/v1.PersistentVolumeClaimSpec"),
+						},
+					},
+					"contentType": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DataVolumeContentType options: \"kubevirt\", \"archive\"",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"priority": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Priority of the transfer to the data volume options: \"low\", \"normal\", \"high\", defaults to normal",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"deadline": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Deadline is how long a transfer pod of the data volume may run before it is terminated, overrides the TransferDeadline of the CDIConfig",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"pvcDeletionPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PVCDeletionPolicy is what happens to the PVC when the data volume is deleted, options: \"Delete\", \"Retain\", defaults to Delete, Retain keeps a populated PVC as a volume of its own",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imageCheck": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImageCheck checks the consistency of an imported qcow2 image before it is converted, options: \"check\", \"repairLeaks\", a corrupt image fails the import",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"verification": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Verification is how thoroughly the data written to the data volume is verified, options: \"none\", \"fast\", \"full\", overrides the DefaultVerification of the CDIConfig",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"blockWipe": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BlockWipe discards or zeroes a block volume before it is populated, so data of an earlier user of the volume cannot show through the regions the image does not write, options: \"discard\", \"zero\"",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"dns": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DNS overrides the host aliases and DNS settings of the importer pod, so sources only resolvable by other name servers can be imported",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeDNS"),
+						},
+					},
+					"blockTarget": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BlockTarget writes the imported image at an offset or into a partition of a block volume, so volumes with partitioning of their own can be populated",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlockTarget"),
+						},
+					},
+					"imageName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImageName is the name of the file the image is written to on a file system volume, defaults to disk.img",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"nodeName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodeName is the node the data volume is populated on, its transfer pod runs there and a volume that waits for its first consumer is provisioned there, for local volumes",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"fanOut": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FanOut are the names of upload data volumes of the namespace the imported image is written to as well, so many identical volumes are populated while the source is read once",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"templateRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TemplateRef instantiates the data volume from a DataVolumeTemplate, whose spec it takes with the parameters substituted, the fields the data volume sets itself take precedence over those of the template",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateRef"),
+						},
+					},
+					"bandwidthLimit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BandwidthLimit limits the rate the importer reads its source at, so large imports do not saturate a shared link, overrides the import bandwidth limit of the namespace",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBandwidthLimit"),
+						},
+					},
+					"encryption": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Encryption provides the keys that decrypt an encrypted source image and encrypt the imported image, so encrypted images do not have to be staged decrypted anywhere",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeEncryption"),
+						},
+					},
+				},
+				Required: []string{"source", "pvc"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.PersistentVolumeClaimSpec", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBandwidthLimit", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlockTarget", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeDNS", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeEncryption", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSource", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateRef"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeStageDurations(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeStageDurations are how long the stages of an import took, the stages the import did not go through are not set",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"download": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Download is how long transferring the data of the source took",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"convert": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Convert is how long extracting the data and converting the image to raw took",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"resize": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Resize is how long resizing the image to the size of the data volume took",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeStatus provides the parameters to store the phase of the Data Volume",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"phase": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Phase is the current phase of the data volume",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"progress": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"restartCount": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"integer"},
+							Format: "int32",
+						},
+					},
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Conditions are the conditions of the data volume, Queued is set while its transfer waits for a CDIQuota, InsufficientScratchSpace when its import ran out of scratch space, Timeout when its transfer ran longer than its deadline, Verified when its clone was checked against the checksums of the source, ChecksumMismatch when its source does not match the digest it is pinned to, ImageChecked when its image was checked for consistency, TransferFailed when a transfer pod failed, Stalled when its transfer pod transfers no data, TokenExpired when its clone token expired before the clone started, NodeUnschedulable while the node it is pinned to can not run its transfer pod, WaitingForTransferWindow while its low priority transfer waits for a transfer window to open",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/openshift/custom-resource-status/conditions/v1.Condition"),
+									},
+								},
+							},
+						},
+					},
+					"claim": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Claim is what was decided for the PVC of the data volume, by CDI and by the defaults of the cluster",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeClaimStatus"),
+						},
+					},
+					"phaseTransitions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PhaseTransitions are the phases the data volume entered and when, oldest first",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumePhaseTransition"),
+									},
+								},
+							},
+						},
+					},
+					"stageDurations": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StageDurations are how long the stages of the import to the data volume took, once it succeeded",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStageDurations"),
+						},
+					},
+				},
+				Required: []string{"restartCount"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/openshift/custom-resource-status/conditions/v1.Condition", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeClaimStatus", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumePhaseTransition", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStageDurations"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeTemplate(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeTemplate is a data volume spec published for reuse, such as an operating system image of a given size and storage class. Data volumes refer to it in their templateRef and are instantiated from it with their parameters.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateSpec"),
+						},
+					},
+				},
+				Required: []string{"spec"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateSpec"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeTemplateList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeTemplateList provides the needed parameters to do request a list of DataVolumeTemplates from the system",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"),
+						},
+					},
+					"items": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Items provides a list of DataVolumeTemplates",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplate"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"metadata", "items"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplate"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeTemplateParameter(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeTemplateParameter is a parameter of a DataVolumeTemplate",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is the name of the parameter",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"description": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Description tells the users of the template what the parameter is for",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"default": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Default is the value of the parameter if a data volume does not set it",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"required": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Required parameters have to be set by every data volume instantiated from the template",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_DataVolumeTemplateRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DataVolumeTemplateRef refers to the DataVolumeTemplate a data volume is instantiated from",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name is the name of the DataVolumeTemplate",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"namespace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Namespace is the namespace of the DataVolumeTemplate, defaults to the namespace of the data volume",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"uninstallStrategy": {
+					"parameters": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Parameters are the values of the parameters of the template, by name",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
 						},
 					},
 				},
+				Required: []string{"name"},
 			},
 		},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_CDIStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_DataVolumeTemplateSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "CDIStatus defines the status of the CDI installation",
+				Description: "DataVolumeTemplateSpec defines the data volume spec of a DataVolumeTemplate and its parameters",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"phase": {
-						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
-						},
-					},
-					"conditions": {
+					"parameters": {
 						SchemaProps: spec.SchemaProps{
-							Type: []string{"array"},
+							Description: "Parameters are the parameters of the template, the string values of the data volume spec refer to them as ${name}",
+							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
-										Ref: ref("github.com/openshift/custom-resource-status/conditions/v1.Condition"),
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateParameter"),
 									},
 								},
 							},
 						},
 					},
-					"operatorVersion": {
-						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
-						},
-					},
-					"targetVersion": {
+					"dataVolume": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "DataVolume is the spec of the data volumes instantiated from the template, the defaults of the fields they do not set themselves",
+							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec"),
 						},
 					},
-					"observedVersion": {
+				},
+				Required: []string{"dataVolume"},
+			},
+		},
+		Dependencies: []string{
+			"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeTemplateParameter"},
+	}
+}
+
+func schema_pkg_apis_core_v1alpha1_Diagnostics(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Diagnostics defines the diagnostic endpoints of the CDI controller and the upload proxy",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"port": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "Port is the port the endpoints are served on, defaults to 6060",
+							Type:        []string{"integer"},
+							Format:      "int32",
 						},
 					},
 				},
 			},
 		},
-		Dependencies: []string{
-			"github.com/openshift/custom-resource-status/conditions/v1.Condition"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolume(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_OperationHistory(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolume provides a representation of our data volume",
+				Description: "OperationHistory records the last data operations of CDI in its namespace, so the users of the namespace can find out how their imports, clones and uploads ended without access to the logs of CDI.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
 					"kind": {
@@ -404,41 +2087,24 @@ func schema_pkg_apis_core_v1alpha1_DataVolume(ref common.ReferenceCallback) comm
 							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
 						},
 					},
-					"spec": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec"),
-						},
-					},
 					"status": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStatus"),
+							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistoryStatus"),
 						},
 					},
 				},
-				Required: []string{"spec"},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSpec", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeStatus"},
-	}
-}
-
-func schema_pkg_apis_core_v1alpha1_DataVolumeBlankImage(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeBlankImage provides the parameters to create a new raw blank image for the PVC",
-				Type:        []string{"object"},
-			},
-		},
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistoryStatus"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeList(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_OperationHistoryList(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeList provides the needed parameters to do request a list of Data Volumes from the system",
+				Description: "OperationHistoryList provides the needed parameters to do request a list of OperationHistories from the system",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
 					"kind": {
@@ -462,12 +2128,12 @@ func schema_pkg_apis_core_v1alpha1_DataVolumeList(ref common.ReferenceCallback)
 					},
 					"items": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Items provides a list of DataVolumes",
+							Description: "Items provides a list of OperationHistories",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
-										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolume"),
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistory"),
 									},
 								},
 							},
@@ -478,212 +2144,204 @@ func schema_pkg_apis_core_v1alpha1_DataVolumeList(ref common.ReferenceCallback)
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolume"},
+			"k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationHistory"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeSource(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_OperationHistoryStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSource represents the source for our Data Volume, this can be HTTP, Imageio, S3, Registry or an existing PVC",
+				Description: "OperationHistoryStatus provides the recorded operations of an OperationHistory",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"http": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceHTTP"),
-						},
-					},
-					"s3": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceS3"),
-						},
-					},
-					"registry": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceRegistry"),
-						},
-					},
-					"pvc": {
+					"operations": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourcePVC"),
-						},
-					},
-					"upload": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceUpload"),
-						},
-					},
-					"blank": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlankImage"),
-						},
-					},
-					"imageio": {
-						SchemaProps: spec.SchemaProps{
-							Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceImageIO"),
+							Description: "Operations are the last operations of the namespace, the most recent first",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationRecord"),
+									},
+								},
+							},
 						},
 					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeBlankImage", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceHTTP", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceImageIO", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourcePVC", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceRegistry", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceS3", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSourceUpload"},
+			"kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.OperationRecord"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeSourceHTTP(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_OperationRecord(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSourceHTTP provides the parameters to create a Data Volume from an HTTP source",
+				Description: "OperationRecord describes a data operation that succeeded or failed",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"url": {
+					"type": {
 						SchemaProps: spec.SchemaProps{
-							Description: "URL is the URL of the http source",
+							Description: "Type is the type of the operation, Import, Clone or Upload",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"secretRef": {
+					"target": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SecretRef provides the secret reference needed to access the HTTP source",
+							Description: "Target is the name of the Data Volume the operation populated",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"certConfigMap": {
+					"initiator": {
 						SchemaProps: spec.SchemaProps{
-							Description: "CertConfigMap provides a reference to the Registry certs",
+							Description: "Initiator is the user that created the Data Volume",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-				},
-			},
-		},
-	}
-}
-
-func schema_pkg_apis_core_v1alpha1_DataVolumeSourceImageIO(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSourceImageIO provides the parameters to create a Data Volume from an imageio source",
-				Type:        []string{"object"},
-				Properties: map[string]spec.Schema{
-					"url": {
+					"startTime": {
 						SchemaProps: spec.SchemaProps{
-							Description: "URL is the URL of the ovirt-engine",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "StartTime is when the Data Volume was created",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
 						},
 					},
-					"diskId": {
+					"completionTime": {
 						SchemaProps: spec.SchemaProps{
-							Description: "DiskID provides id of a disk to be imported",
+							Description: "CompletionTime is when the operation succeeded or failed",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"duration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Duration is the time from StartTime to CompletionTime, such as 1h2m3s",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"secretRef": {
+					"result": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SecretRef provides the secret reference needed to access the ovirt-engine",
+							Description: "Result is the final phase of the Data Volume, Succeeded or Failed",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"certConfigMap": {
+					"message": {
 						SchemaProps: spec.SchemaProps{
-							Description: "CertConfigMap provides a reference to the CA cert",
+							Description: "Message describes the result",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
 				},
+				Required: []string{"type", "target", "startTime", "completionTime", "duration", "result"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeSourcePVC(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_RegistryCacheRetention(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSourcePVC provides the parameters to create a Data Volume from an existing PVC",
+				Description: "RegistryCacheRetention defines which registry cache entries are kept, an entry is deleted when none of the rules keeps it",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"namespace": {
+					"keepLast": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "KeepLast keeps the newest cache entries of each image in a namespace",
+							Type:        []string{"integer"},
+							Format:      "int32",
 						},
 					},
-					"name": {
+					"keepFor": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "KeepFor keeps the cache entries younger than this duration",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"keepReferenced": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KeepReferenced keeps the cache entries of a digest other DataVolumes in the namespace still import",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"dryRun": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DryRun reports the cache entries that would be deleted, with an event on their DataVolume, instead of deleting them",
+							Type:        []string{"boolean"},
+							Format:      "",
 						},
 					},
 				},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeSourceRegistry(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_ScratchSpacePool(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSourceRegistry provides the parameters to create a Data Volume from an registry source",
+				Description: "ScratchSpacePool defines the pool of scratch space volumes",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"url": {
-						SchemaProps: spec.SchemaProps{
-							Description: "URL is the url of the Registry source",
-							Type:        []string{"string"},
-							Format:      "",
-						},
-					},
-					"secretRef": {
+					"sizeClasses": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SecretRef provides the secret reference needed to access the Registry source",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "SizeClasses are the sizes of the volumes in the pool, a scratch space gets a volume of the smallest size class that fits it, scratch spaces larger than all size classes are not pooled",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+									},
+								},
+							},
 						},
 					},
-					"certConfigMap": {
+					"volumesPerSizeClass": {
 						SchemaProps: spec.SchemaProps{
-							Description: "CertConfigMap provides a reference to the Registry certs",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "VolumesPerSizeClass is how many volumes of each size class are kept ready in the pool",
+							Type:        []string{"integer"},
+							Format:      "int32",
 						},
 					},
 				},
+				Required: []string{"sizeClasses", "volumesPerSizeClass"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeSourceS3(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_SecurityProfiles(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSourceS3 provides the parameters to create a Data Volume from an S3 source",
+				Description: "SecurityProfiles defines the security profiles applied to transfer pods",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"url": {
+					"seccomp": {
 						SchemaProps: spec.SchemaProps{
-							Description: "URL is the url of the S3 source",
-							Type:        []string{"string"},
+							Description: "Seccomp applies the seccomp profiles of the transfer pods, cdi-importer.json, cdi-uploadserver.json and cdi-cloner.json in the seccomp directory of the kubelet",
+							Type:        []string{"boolean"},
 							Format:      "",
 						},
 					},
-					"secretRef": {
+					"appArmor": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SecretRef provides the secret reference needed to access the S3 source",
-							Type:        []string{"string"},
+							Description: "AppArmor applies the cdi-transfer AppArmor profile to the transfer pods, which has to be loaded on the nodes",
+							Type:        []string{"boolean"},
 							Format:      "",
 						},
 					},
@@ -693,81 +2351,89 @@ func schema_pkg_apis_core_v1alpha1_DataVolumeSourceS3(ref common.ReferenceCallba
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeSourceUpload(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSourceUpload provides the parameters to create a Data Volume by uploading the source",
-				Type:        []string{"object"},
-			},
-		},
-	}
-}
-
-func schema_pkg_apis_core_v1alpha1_DataVolumeSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_TransferWindow(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeSpec defines our specification for a DataVolume type",
+				Description: "TransferWindow defines a time of the week low priority transfers may start in",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"source": {
+					"days": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Source is the src of the data for the requested DataVolume",
-							Ref:         ref("kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSource"),
+							Description: "Days are the days of the week the window opens on, options: \"Mon\", \"Tue\", \"Wed\", \"Thu\", \"Fri\", \"Sat\", \"Sun\", every day if it is not set",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
 						},
 					},
-					"pvc": {
+					"start": {
 						SchemaProps: spec.SchemaProps{
-							Description: "PVC is a pointer to the PVC Spec we want to use",
-							Ref:         ref("k8s.io/api/core/v1.PersistentVolumeClaimSpec"),
+							Description: "Start is the time of day in UTC the window opens at, as HH:MM",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"contentType": {
+					"end": {
 						SchemaProps: spec.SchemaProps{
-							Description: "DataVolumeContentType options: \"kubevirt\", \"archive\"",
+							Description: "End is the time of day in UTC the window closes at, as HH:MM, the next day if it is before the start, a window that closes when it opens lasts the whole day",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
 				},
-				Required: []string{"source", "pvc"},
+				Required: []string{"start", "end"},
 			},
 		},
-		Dependencies: []string{
-			"k8s.io/api/core/v1.PersistentVolumeClaimSpec", "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1.DataVolumeSource"},
 	}
 }
 
-func schema_pkg_apis_core_v1alpha1_DataVolumeStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_core_v1alpha1_UploadTimeouts(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "DataVolumeStatus provides the parameters to store the phase of the Data Volume",
+				Description: "UploadTimeouts defines the timeouts of the connections of the upload proxy and upload servers",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"phase": {
+					"readTimeout": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Phase is the current phase of the data volume",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "ReadTimeout is how long reading an upload request, with its body, may take, there is no limit if it is not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
 						},
 					},
-					"progress": {
+					"writeTimeout": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "WriteTimeout is how long an upload request may take until its response is written, there is no limit if it is not set",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
 						},
 					},
-					"restartCount": {
+					"idleTimeout": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"integer"},
-							Format: "int32",
+							Description: "IdleTimeout is how long an idle keep-alive connection is kept open for the next request, defaults to the ReadTimeout, there is no limit if neither is set",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"keepAlivePeriod": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KeepAlivePeriod is the period of the TCP keep-alive probes of the connections, which keep middleboxes from dropping connections that are idle while an upload is written, defaults to 15s",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"proxyRequestTimeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ProxyRequestTimeout is how long the upload proxy waits for an upload server to take an upload, defaults to 24h",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
 						},
 					},
 				},
-				Required: []string{"restartCount"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
 	}
 }
@@ -40,6 +40,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&CDIConfigList{},
 		&CDI{},
 		&CDIList{},
+		&CDIQuota{},
+		&CDIQuotaList{},
+		&OperationHistory{},
+		&OperationHistoryList{},
+		&DataVolumeTemplate{},
+		&DataVolumeTemplateList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
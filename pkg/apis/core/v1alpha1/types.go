@@ -22,6 +22,7 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	conditions "github.com/openshift/custom-resource-status/conditions/v1"
@@ -46,6 +47,44 @@ type DataVolumeSpec struct {
 	PVC *corev1.PersistentVolumeClaimSpec `json:"pvc"`
 	//DataVolumeContentType options: "kubevirt", "archive"
 	ContentType DataVolumeContentType `json:"contentType,omitempty"`
+	//Priority of the transfer to the data volume options: "low", "normal", "high", defaults to normal
+	Priority DataVolumePriority `json:"priority,omitempty"`
+	//Deadline is how long a transfer pod of the data volume may run before it is terminated, overrides the TransferDeadline of the CDIConfig
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+	//PVCDeletionPolicy is what happens to the PVC when the data volume is deleted, options: "Delete", "Retain", defaults to Delete, Retain keeps a populated PVC as a volume of its own
+	PVCDeletionPolicy DataVolumePVCDeletionPolicy `json:"pvcDeletionPolicy,omitempty"`
+	//ImageCheck checks the consistency of an imported qcow2 image before it is converted, options: "check", "repairLeaks", a corrupt image fails the import
+	ImageCheck DataVolumeImageCheck `json:"imageCheck,omitempty"`
+	//Verification is how thoroughly the data written to the data volume is verified, options: "none", "fast", "full", overrides the DefaultVerification of the CDIConfig
+	Verification DataVolumeVerification `json:"verification,omitempty"`
+	//BlockWipe discards or zeroes a block volume before it is populated, so data of an earlier user of the volume cannot show through the regions the image does not write, options: "discard", "zero"
+	BlockWipe DataVolumeBlockWipe `json:"blockWipe,omitempty"`
+	//DNS overrides the host aliases and DNS settings of the importer pod, so sources only resolvable by other name servers can be imported
+	DNS *DataVolumeDNS `json:"dns,omitempty"`
+	//BlockTarget writes the imported image at an offset or into a partition of a block volume, so volumes with partitioning of their own can be populated
+	BlockTarget *DataVolumeBlockTarget `json:"blockTarget,omitempty"`
+	//ImageName is the name of the file the image is written to on a file system volume, defaults to disk.img
+	ImageName string `json:"imageName,omitempty"`
+	//NodeName is the node the data volume is populated on, its transfer pod runs there and a volume that waits for its first consumer is provisioned there, for local volumes
+	NodeName string `json:"nodeName,omitempty"`
+	//FanOut are the names of upload data volumes of the namespace the imported image is written to as well, so many identical volumes are populated while the source is read once
+	FanOut []string `json:"fanOut,omitempty"`
+	//TemplateRef instantiates the data volume from a DataVolumeTemplate, whose spec it takes with the parameters substituted, the fields the data volume sets itself take precedence over those of the template
+	TemplateRef *DataVolumeTemplateRef `json:"templateRef,omitempty"`
+	//BandwidthLimit limits the rate the importer reads its source at, so large imports do not saturate a shared link, overrides the import bandwidth limit of the namespace
+	BandwidthLimit *DataVolumeBandwidthLimit `json:"bandwidthLimit,omitempty"`
+	//Encryption provides the keys that decrypt an encrypted source image and encrypt the imported image, so encrypted images do not have to be staged decrypted anywhere
+	Encryption *DataVolumeEncryption `json:"encryption,omitempty"`
+}
+
+// DataVolumeTemplateRef refers to the DataVolumeTemplate a data volume is instantiated from
+type DataVolumeTemplateRef struct {
+	//Name is the name of the DataVolumeTemplate
+	Name string `json:"name"`
+	//Namespace is the namespace of the DataVolumeTemplate, defaults to the namespace of the data volume
+	Namespace string `json:"namespace,omitempty"`
+	//Parameters are the values of the parameters of the template, by name
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 // DataVolumeContentType represents the types of the imported data
@@ -56,9 +95,113 @@ const (
 	DataVolumeKubeVirt DataVolumeContentType = "kubevirt"
 	// DataVolumeArchive is the content-type to specify if there is a need to extract the imported archive
 	DataVolumeArchive DataVolumeContentType = "archive"
+	// DataVolumeImages is the content-type of several named images on a file system volume, like a kernel, an initrd and a disk
+	DataVolumeImages DataVolumeContentType = "images"
+	// DataVolumeKubeVirtState is the content-type of an opaque blob of VM state, like a memory dump, uploaded to a file system volume as it is
+	DataVolumeKubeVirtState DataVolumeContentType = "kubevirt-state"
+)
+
+// DataVolumePriority represents the priority of the transfer to a Data Volume
+type DataVolumePriority string
+
+const (
+	// DataVolumePriorityLow is the priority of background transfers, they give way to other pods
+	DataVolumePriorityLow DataVolumePriority = "low"
+	// DataVolumePriorityNormal is the default priority of transfers
+	DataVolumePriorityNormal DataVolumePriority = "normal"
+	// DataVolumePriorityHigh is the priority of urgent transfers, they preempt pods of lower priority
+	DataVolumePriorityHigh DataVolumePriority = "high"
+)
+
+// DataVolumePVCDeletionPolicy represents what happens to the PVC of a Data Volume when the Data Volume is deleted
+type DataVolumePVCDeletionPolicy string
+
+const (
+	// DataVolumePVCDeletionPolicyDelete deletes the PVC with the Data Volume, this is the default
+	DataVolumePVCDeletionPolicyDelete DataVolumePVCDeletionPolicy = "Delete"
+	// DataVolumePVCDeletionPolicyRetain keeps the PVC of a succeeded Data Volume, without the owner reference and CDI annotations
+	DataVolumePVCDeletionPolicyRetain DataVolumePVCDeletionPolicy = "Retain"
+)
+
+// DataVolumeImageCheck represents how the consistency of an imported qcow2 image is checked
+type DataVolumeImageCheck string
+
+const (
+	// DataVolumeImageCheckOnly checks the image with qemu-img check and reports leaked clusters
+	DataVolumeImageCheckOnly DataVolumeImageCheck = "check"
+	// DataVolumeImageCheckRepairLeaks checks the image and repairs leaked clusters of an image in scratch space
+	DataVolumeImageCheckRepairLeaks DataVolumeImageCheck = "repairLeaks"
+)
+
+// DataVolumeVerification represents how thoroughly the data written to a Data Volume is verified
+type DataVolumeVerification string
+
+const (
+	// DataVolumeVerificationNone skips the checks of the sizes of the received, written and converted data
+	DataVolumeVerificationNone DataVolumeVerification = "none"
+	// DataVolumeVerificationFast checks the sizes of the received, written and converted data
+	DataVolumeVerificationFast DataVolumeVerification = "fast"
+	// DataVolumeVerificationFull also reads back the written image to record its content digest
+	DataVolumeVerificationFull DataVolumeVerification = "full"
 )
 
-// DataVolumeSource represents the source for our Data Volume, this can be HTTP, Imageio, S3, Registry or an existing PVC
+// DataVolumeBlockWipe represents how a block volume is wiped before it is populated
+type DataVolumeBlockWipe string
+
+const (
+	// DataVolumeBlockWipeDiscard discards the blocks of the volume, then zeroes them, since not all storage reads discarded blocks back as zeros
+	DataVolumeBlockWipeDiscard DataVolumeBlockWipe = "discard"
+	// DataVolumeBlockWipeZero zeroes the blocks of the volume
+	DataVolumeBlockWipeZero DataVolumeBlockWipe = "zero"
+)
+
+// DataVolumeBlockTarget provides where an image is written to a block volume, at most one of its fields is set
+type DataVolumeBlockTarget struct {
+	//Offset is the byte offset of a block volume the image is written at, a multiple of 512
+	Offset int64 `json:"offset,omitempty"`
+	//Partition is the number of the partition of the GPT or MBR partition table of a block volume the image is written into, starting at 1
+	Partition int32 `json:"partition,omitempty"`
+}
+
+// DataVolumeDNS provides the host aliases and DNS settings of the importer pod of a Data Volume
+type DataVolumeDNS struct {
+	//HostAliases are added to the hosts file of the importer pod
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+	//Policy is the DNS policy of the importer pod, options: "ClusterFirst", "Default", "None", defaults to ClusterFirst
+	Policy corev1.DNSPolicy `json:"policy,omitempty"`
+	//Config is merged with the DNS settings the policy generates, it needs nameservers with policy None
+	Config *corev1.PodDNSConfig `json:"config,omitempty"`
+}
+
+// DataVolumeBandwidthLimit provides the rate the importer of a Data Volume reads its source at, at most
+type DataVolumeBandwidthLimit struct {
+	//Rate is the bytes per second the importer reads its source at, at most, outside of the windows of the schedule, unlimited if not set
+	Rate *resource.Quantity `json:"rate,omitempty"`
+	//Schedule are windows of the week with a rate of their own, the first window the time is in applies
+	Schedule []DataVolumeBandwidthWindow `json:"schedule,omitempty"`
+}
+
+// DataVolumeBandwidthWindow provides a window of the week with a bandwidth limit of its own
+type DataVolumeBandwidthWindow struct {
+	//Days are the days of the week the window starts on, options: "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun", every day if none
+	Days []string `json:"days,omitempty"`
+	//Start is the time of day in UTC the window starts at, as HH:MM
+	Start string `json:"start"`
+	//End is the time of day in UTC the window ends at, as HH:MM, a window that ends before it starts ends the next day
+	End string `json:"end"`
+	//Rate is the bytes per second the importer reads its source at, at most, in the window, unlimited if not set
+	Rate *resource.Quantity `json:"rate,omitempty"`
+}
+
+// DataVolumeEncryption provides the Secrets with the passphrases of the encrypted images of an import, in the "passphrase" key
+type DataVolumeEncryption struct {
+	//SourceSecretRef is the name of the Secret with the passphrase that decrypts a LUKS or encrypted qcow2 source image
+	SourceSecretRef string `json:"sourceSecretRef,omitempty"`
+	//TargetSecretRef is the name of the Secret with the passphrase the imported image is encrypted with as LUKS, the image is written unencrypted if not set
+	TargetSecretRef string `json:"targetSecretRef,omitempty"`
+}
+
+// DataVolumeSource represents the source for our Data Volume, this can be HTTP, Imageio, S3, Registry, SSH, Nutanix or an existing PVC
 type DataVolumeSource struct {
 	HTTP     *DataVolumeSourceHTTP     `json:"http,omitempty"`
 	S3       *DataVolumeSourceS3       `json:"s3,omitempty"`
@@ -67,6 +210,8 @@ type DataVolumeSource struct {
 	Upload   *DataVolumeSourceUpload   `json:"upload,omitempty"`
 	Blank    *DataVolumeBlankImage     `json:"blank,omitempty"`
 	Imageio  *DataVolumeSourceImageIO  `json:"imageio,omitempty"`
+	SSH      *DataVolumeSourceSSH      `json:"ssh,omitempty"`
+	Nutanix  *DataVolumeSourceNutanix  `json:"nutanix,omitempty"`
 }
 
 // DataVolumeSourcePVC provides the parameters to create a Data Volume from an existing PVC
@@ -81,6 +226,8 @@ type DataVolumeBlankImage struct{}
 // DataVolumeSourceUpload provides the parameters to create a Data Volume by uploading the source
 type DataVolumeSourceUpload struct {
 	//Target string `json:"shouldUpload,omitempty"`
+	//Checksum is the digest the state blob of a kubevirt-state upload is verified against, in the form sha256:<hex> or sha512:<hex>, an upload that does not match is refused and can be sent again
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // DataVolumeSourceS3 provides the parameters to create a Data Volume from an S3 source
@@ -89,6 +236,8 @@ type DataVolumeSourceS3 struct {
 	URL string `json:"url,omitempty"`
 	//SecretRef provides the secret reference needed to access the S3 source
 	SecretRef string `json:"secretRef,omitempty"`
+	//Checksum is the digest the S3 object is pinned to, in the form sha256:<hex> or sha512:<hex>, the import fails with the ChecksumMismatch condition when the object does not match
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // DataVolumeSourceRegistry provides the parameters to create a Data Volume from an registry source
@@ -99,6 +248,14 @@ type DataVolumeSourceRegistry struct {
 	SecretRef string `json:"secretRef,omitempty"`
 	//CertConfigMap provides a reference to the Registry certs
 	CertConfigMap string `json:"certConfigMap,omitempty"`
+	//Architecture selects the image to import when the source is a multi-arch manifest list, defaults to the architecture of the node running the import
+	Architecture string `json:"architecture,omitempty"`
+	//DiskPath is a glob matching the path of the disk image in the image layers, for images that do not follow the containerDisk layout with the disk in /disk
+	DiskPath string `json:"diskPath,omitempty"`
+	//ArtifactMediaType selects the disk image blob of an OCI artifact with several blobs by its media type
+	ArtifactMediaType string `json:"artifactMediaType,omitempty"`
+	//ArtifactAnnotation selects the disk image blob of an OCI artifact with several blobs by one of its annotations, in the form key or key=value
+	ArtifactAnnotation string `json:"artifactAnnotation,omitempty"`
 }
 
 // DataVolumeSourceHTTP provides the parameters to create a Data Volume from an HTTP source
@@ -109,6 +266,20 @@ type DataVolumeSourceHTTP struct {
 	SecretRef string `json:"secretRef,omitempty"`
 	//CertConfigMap provides a reference to the Registry certs
 	CertConfigMap string `json:"certConfigMap,omitempty"`
+	//BackingFileURLs are the URL prefixes the backing files of a qcow2 image may be fetched from, the backing chain is then flattened into the imported disk. Images with backing files are rejected when empty
+	BackingFileURLs []string `json:"backingFileURLs,omitempty"`
+	//Checksum is the digest the image at URL is pinned to, in the form sha256:<hex> or sha512:<hex>, the import fails with the ChecksumMismatch condition when the image does not match
+	Checksum string `json:"checksum,omitempty"`
+	//AdditionalImages are imported next to the image at URL with content type "images", each to the file of its name
+	AdditionalImages []DataVolumeNamedImage `json:"additionalImages,omitempty"`
+}
+
+// DataVolumeNamedImage provides an image imported to a file of its own on a file system volume
+type DataVolumeNamedImage struct {
+	//Name is the name of the file the image is written to
+	Name string `json:"name"`
+	//URL is the URL of the http source of the image
+	URL string `json:"url"`
 }
 
 // DataVolumeSourceImageIO provides the parameters to create a Data Volume from an imageio source
@@ -123,14 +294,111 @@ type DataVolumeSourceImageIO struct {
 	CertConfigMap string `json:"certConfigMap,omitempty"`
 }
 
+// DataVolumeSourceSSH provides the parameters to create a Data Volume from a file or block device on a host reachable over SSH
+type DataVolumeSourceSSH struct {
+	//URL is the location of the disk, ssh://[user@]host[:port]/path
+	URL string `json:"url,omitempty"`
+	//SecretRef provides the secret holding the ssh-privatekey used to log in, and the known_hosts the key of the host is verified with
+	SecretRef string `json:"secretRef,omitempty"`
+	//BackingFileURLs are the URL prefixes the backing files of a qcow2 image may be fetched from, the backing chain is then flattened into the imported disk. Images with backing files are rejected when empty
+	BackingFileURLs []string `json:"backingFileURLs,omitempty"`
+	//InsecureSkipHostKeyCheck allows importing from a host whose key is not in known_hosts, without verifying the key. The import fails without known_hosts when unset
+	InsecureSkipHostKeyCheck bool `json:"insecureSkipHostKeyCheck,omitempty"`
+}
+
+// DataVolumeSourceNutanix provides the parameters to create a Data Volume from an image of the Nutanix AHV image service
+type DataVolumeSourceNutanix struct {
+	//URL is the URL of the Prism Central API, https://host:9440
+	URL string `json:"url,omitempty"`
+	//ImageUUID is the uuid of the image to import
+	ImageUUID string `json:"imageUUID,omitempty"`
+	//SecretRef provides the secret reference needed to access the Prism Central API
+	SecretRef string `json:"secretRef,omitempty"`
+	//CertConfigMap provides a reference to the CA cert
+	CertConfigMap string `json:"certConfigMap,omitempty"`
+}
+
 // DataVolumeStatus provides the parameters to store the phase of the Data Volume
 type DataVolumeStatus struct {
 	//Phase is the current phase of the data volume
 	Phase        DataVolumePhase    `json:"phase,omitempty"`
 	Progress     DataVolumeProgress `json:"progress,omitempty"`
 	RestartCount int32              `json:"restartCount"`
+	//Conditions are the conditions of the data volume, Queued is set while its transfer waits for a CDIQuota, InsufficientScratchSpace when its import ran out of scratch space, Timeout when its transfer ran longer than its deadline, Verified when its clone was checked against the checksums of the source, ChecksumMismatch when its source does not match the digest it is pinned to, ImageChecked when its image was checked for consistency, TransferFailed when a transfer pod failed, Stalled when its transfer pod transfers no data, TokenExpired when its clone token expired before the clone started, NodeUnschedulable while the node it is pinned to can not run its transfer pod, WaitingForTransferWindow while its low priority transfer waits for a transfer window to open
+	Conditions []conditions.Condition `json:"conditions,omitempty" optional:"true"`
+	//Claim is what was decided for the PVC of the data volume, by CDI and by the defaults of the cluster
+	Claim *DataVolumeClaimStatus `json:"claim,omitempty"`
+	//PhaseTransitions are the phases the data volume entered and when, oldest first
+	PhaseTransitions []DataVolumePhaseTransition `json:"phaseTransitions,omitempty"`
+	//StageDurations are how long the stages of the import to the data volume took, once it succeeded
+	StageDurations *DataVolumeStageDurations `json:"stageDurations,omitempty"`
+}
+
+// DataVolumeClaimStatus is the effective volume mode, access modes, storage class and node topology of the PVC of a
+// data volume, once defaults, a volume mode fallback and the provisioner filled them in
+type DataVolumeClaimStatus struct {
+	// VolumeMode is the volume mode of the PVC
+	VolumeMode *corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+	// AccessModes are the access modes of the PVC, those of its volume once it is bound
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// StorageClassName is the storage class of the PVC, the default storage class if the data volume names none
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// VolumeName is the name of the persistent volume the PVC is bound to
+	VolumeName string `json:"volumeName,omitempty"`
+	// SelectedNode is the node the PVC was provisioned for, with a storage class that waits for the first consumer
+	SelectedNode string `json:"selectedNode,omitempty"`
+	// NodeAffinity is the node affinity of the bound volume, the nodes the data volume can be used from
+	NodeAffinity *corev1.VolumeNodeAffinity `json:"nodeAffinity,omitempty"`
+	// CloneSizing tells how the storage request of the PVC of a clone was derived from its source in another volume mode
+	CloneSizing string `json:"cloneSizing,omitempty"`
+}
+
+// DataVolumePhaseTransition is a phase a data volume entered
+type DataVolumePhaseTransition struct {
+	// Phase is the phase the data volume entered
+	Phase DataVolumePhase `json:"phase"`
+	// TransitionTime is when the data volume entered the phase
+	TransitionTime metav1.Time `json:"transitionTime"`
+}
+
+// DataVolumeStageDurations are how long the stages of an import took, the stages the import did not go through are
+// not set
+type DataVolumeStageDurations struct {
+	// Download is how long transferring the data of the source took
+	Download *metav1.Duration `json:"download,omitempty"`
+	// Convert is how long extracting the data and converting the image to raw took
+	Convert *metav1.Duration `json:"convert,omitempty"`
+	// Resize is how long resizing the image to the size of the data volume took
+	Resize *metav1.Duration `json:"resize,omitempty"`
 }
 
+const (
+	// DataVolumeQueued is the condition of a data volume whose transfer pod waits for a CDIQuota of its namespace
+	DataVolumeQueued conditions.ConditionType = "Queued"
+	// DataVolumeInsufficientScratchSpace is the condition of a data volume whose importer ran out of scratch space
+	DataVolumeInsufficientScratchSpace conditions.ConditionType = "InsufficientScratchSpace"
+	// DataVolumeTimeout is the condition of a data volume whose transfer pod ran longer than its deadline
+	DataVolumeTimeout conditions.ConditionType = "Timeout"
+	// DataVolumeVerified is the condition of a data volume whose clone was verified against the checksums of the source
+	DataVolumeVerified conditions.ConditionType = "Verified"
+	// DataVolumeChecksumMismatch is the condition of a data volume whose source does not match the digest it is pinned to
+	DataVolumeChecksumMismatch conditions.ConditionType = "ChecksumMismatch"
+	// DataVolumeImageChecked is the condition of a data volume whose imported image was checked for consistency
+	DataVolumeImageChecked conditions.ConditionType = "ImageChecked"
+	// DataVolumeTransferFailed is the condition of a data volume whose transfer pod failed, with the end of the
+	// termination message of the pod
+	DataVolumeTransferFailed conditions.ConditionType = "TransferFailed"
+	// DataVolumeStalled is the condition of a data volume whose running transfer pod transferred no data for a while
+	DataVolumeStalled conditions.ConditionType = "Stalled"
+	// DataVolumeTokenExpired is the condition of a data volume whose clone token expired before the clone started
+	DataVolumeTokenExpired conditions.ConditionType = "TokenExpired"
+	// DataVolumeNodeUnschedulable is the condition of a data volume pinned to a node that can not run its transfer pod
+	DataVolumeNodeUnschedulable conditions.ConditionType = "NodeUnschedulable"
+	// DataVolumeWaitingForTransferWindow is the condition of a data volume whose low priority transfer waits for a
+	// transfer window to open
+	DataVolumeWaitingForTransferWindow conditions.ConditionType = "WaitingForTransferWindow"
+)
+
 //DataVolumeList provides the needed parameters to do request a list of Data Volumes from the system
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type DataVolumeList struct {
@@ -191,6 +459,9 @@ const (
 // DataVolumeCloneSourceSubresource is the subresource checked for permission to clone
 const DataVolumeCloneSourceSubresource = "source"
 
+// DataVolumeCloneSourceVerb is the verb on the DataVolumeCloneSourceSubresource that allows cloning from a namespace
+const DataVolumeCloneSourceVerb = "clone"
+
 // this has to be here otherwise informer-gen doesn't recognize it
 // see https://github.com/kubernetes/code-generator/issues/59
 // +genclient:nonNamespaced
@@ -211,8 +482,49 @@ type CDISpec struct {
 	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty" valid:"required"`
 
 	UninstallStrategy *CDIUninstallStrategy `json:"uninstallStrategy,omitempty"`
+
+	// UploadProxyVirtualHostSecrets are the names of the TLS Secrets in the CDI namespace with the serving certificates of additional hostnames of the upload proxy
+	UploadProxyVirtualHostSecrets []string `json:"uploadProxyVirtualHostSecrets,omitempty"`
+
+	// UploadProxyIngress is how the operator exposes the upload proxy outside the cluster, with a Route on OpenShift and an Ingress elsewhere
+	UploadProxyIngress *CDIUploadProxyIngress `json:"uploadProxyIngress,omitempty"`
+
+	// UploadProxyTrustedProxies are the CIDRs of the load balancers in front of the upload proxy, whose PROXY protocol and X-Forwarded-For and X-Forwarded-Proto headers tell the address of the client
+	UploadProxyTrustedProxies []string `json:"uploadProxyTrustedProxies,omitempty"`
+
+	// TokenSigningAlgorithm is the algorithm the apiserver signs the upload and clone tokens with, PS256 with an RSA key, the default, or ES256 with an ECDSA P-256 key. Changing it rotates the signing key
+	TokenSigningAlgorithm string `json:"tokenSigningAlgorithm,omitempty"`
 }
 
+// CDIUploadProxyIngress defines the Route or Ingress of the upload proxy
+type CDIUploadProxyIngress struct {
+	// Host is the hostname clients upload to, a Route without it gets a generated hostname, an Ingress is only created with it
+	Host string `json:"host,omitempty"`
+
+	// TLSTermination is where the TLS connection of clients ends, options: "reencrypt", "passthrough", defaults to "reencrypt"
+	TLSTermination CDIUploadProxyTLSTermination `json:"tlsTermination,omitempty"`
+
+	// TLSSecret is the name of the TLS Secret in the CDI namespace with the certificate of Host, used to terminate TLS at an Ingress
+	TLSSecret string `json:"tlsSecret,omitempty"`
+
+	// IngressClass is the class of the Ingress controller that serves the Ingress
+	IngressClass string `json:"ingressClass,omitempty"`
+
+	// Timeout is how long the Route or Ingress waits for the upload proxy to answer, uploads of large images that are converted need a long one, defaults to 60m
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// CDIUploadProxyTLSTermination defines where the TLS connection to the upload proxy ends
+type CDIUploadProxyTLSTermination string
+
+const (
+	// CDIUploadProxyTLSTerminationReencrypt ends the TLS connection of the client at the router, which opens a new one to the upload proxy
+	CDIUploadProxyTLSTerminationReencrypt CDIUploadProxyTLSTermination = "reencrypt"
+
+	// CDIUploadProxyTLSTerminationPassthrough passes the TLS connection of the client through to the upload proxy
+	CDIUploadProxyTLSTerminationPassthrough CDIUploadProxyTLSTermination = "passthrough"
+)
+
 // CDIUninstallStrategy defines the state to leave CDI on uninstall
 type CDIUninstallStrategy string
 
@@ -289,6 +601,106 @@ type CDIConfigSpec struct {
 	UploadProxyURLOverride   *string                      `json:"uploadProxyURLOverride,omitempty"`
 	ScratchSpaceStorageClass *string                      `json:"scratchSpaceStorageClass,omitempty"`
 	PodResourceRequirements  *corev1.ResourceRequirements `json:"podResourceRequirements,omitempty"`
+	//ScratchSpaceMaxSize is the largest scratch space an import may be retried with after its scratch space ran out, scratch space is not enlarged if it is not set
+	ScratchSpaceMaxSize *resource.Quantity `json:"scratchSpaceMaxSize,omitempty"`
+	//TransferDeadline is how long a transfer pod may run before it is terminated, unless its data volume sets a deadline, transfer pods run until they are done if it is not set
+	TransferDeadline *metav1.Duration `json:"transferDeadline,omitempty"`
+	//CompletedPodRetention is how long succeeded importer, clone source and upload server pods, and the services of the upload servers, are kept before they are deleted, they are deleted right away if it is not set
+	CompletedPodRetention *metav1.Duration `json:"completedPodRetention,omitempty"`
+	//PreserveTransferLogs keeps the end of the log of a transfer pod in an annotation of its PVC when the pod is deleted after the CompletedPodRetention
+	PreserveTransferLogs bool `json:"preserveTransferLogs,omitempty"`
+	//ScratchSpacePool keeps the volumes of completed scratch spaces for later scratch spaces, so they are not provisioned and deleted for each transfer
+	ScratchSpacePool *ScratchSpacePool `json:"scratchSpacePool,omitempty"`
+	//RegistryCacheRetention decides which versions of an image in the registry cache are deleted, cache entries are kept until they are deleted by hand if it is not set
+	RegistryCacheRetention *RegistryCacheRetention `json:"registryCacheRetention,omitempty"`
+	//DefaultVerification is how thoroughly the data written to a data volume that sets no verification is verified, options: "none", "fast", "full", defaults to full
+	DefaultVerification DataVolumeVerification `json:"defaultVerification,omitempty"`
+	//UploadTimeouts are the timeouts and TCP keep-alive period of the connections of the upload proxy and upload servers, so slow uploads are not dropped, the upload proxy applies changes when it restarts
+	UploadTimeouts *UploadTimeouts `json:"uploadTimeouts,omitempty"`
+	//ExcludeFromServiceMesh keeps the sidecars of service meshes such as Istio and Linkerd out of transfer pods, which would keep running with a sidecar after the transfer and whose TLS connections a sidecar would wrap in its own mTLS
+	ExcludeFromServiceMesh bool `json:"excludeFromServiceMesh,omitempty"`
+	//UploadContentEncodings are the Content-Encodings of uploads the upload servers decompress on the fly, options: "gzip", compressed uploads are refused if it is not set
+	UploadContentEncodings []string `json:"uploadContentEncodings,omitempty"`
+	//UploadMaxConcurrentRequests is how many requests with upload data an upload server takes at once, to bound its memory, further requests are answered with 429 until one finished, defaults to 4
+	UploadMaxConcurrentRequests int32 `json:"uploadMaxConcurrentRequests,omitempty"`
+	//SecurityProfiles applies the seccomp and AppArmor profiles CDI ships to importer, upload server, clone source and exporter pods, the profiles have to be installed on the nodes
+	SecurityProfiles *SecurityProfiles `json:"securityProfiles,omitempty"`
+	//ImportUserAgent is the product the importer names in the User-Agent of its HTTP and S3 requests, followed by the ID of the cluster and the UID of the data volume, defaults to containerized-data-importer
+	ImportUserAgent string `json:"importUserAgent,omitempty"`
+	//Diagnostics serves the pprof profiles and the expvar runtime metrics of the CDI controller and the upload proxy on their localhost, to be reached with kubectl port-forward, the endpoints are not served if it is not set, the processes apply changes when they restart
+	Diagnostics *Diagnostics `json:"diagnostics,omitempty"`
+	//CloneCompression is how the clone source pods compress the data of host-assisted clones on the way to the upload servers, gzip at its default level if it is not set
+	CloneCompression *CloneCompression `json:"cloneCompression,omitempty"`
+	//TransferWindows are the times of the week low priority imports and host-assisted clones may start in, a namespace can set its own with the cdi.kubevirt.io/transferWindows annotation, they start at any time if it is not set
+	TransferWindows []TransferWindow `json:"transferWindows,omitempty"`
+	//AnnotateTransferNodes annotates the nodes running importer, upload server and clone source pods with the number of those pods, and keeps the cluster autoscaler from scaling them down until the transfers are done
+	AnnotateTransferNodes bool `json:"annotateTransferNodes,omitempty"`
+}
+
+//ScratchSpacePool defines the pool of scratch space volumes
+type ScratchSpacePool struct {
+	//SizeClasses are the sizes of the volumes in the pool, a scratch space gets a volume of the smallest size class that fits it, scratch spaces larger than all size classes are not pooled
+	SizeClasses []resource.Quantity `json:"sizeClasses"`
+	//VolumesPerSizeClass is how many volumes of each size class are kept ready in the pool
+	VolumesPerSizeClass int32 `json:"volumesPerSizeClass"`
+}
+
+//RegistryCacheRetention defines which registry cache entries are kept, an entry is deleted when none of the rules keeps it
+type RegistryCacheRetention struct {
+	//KeepLast keeps the newest cache entries of each image in a namespace
+	KeepLast *int32 `json:"keepLast,omitempty"`
+	//KeepFor keeps the cache entries younger than this duration
+	KeepFor *metav1.Duration `json:"keepFor,omitempty"`
+	//KeepReferenced keeps the cache entries of a digest other DataVolumes in the namespace still import
+	KeepReferenced bool `json:"keepReferenced,omitempty"`
+	//DryRun reports the cache entries that would be deleted, with an event on their DataVolume, instead of deleting them
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+//UploadTimeouts defines the timeouts of the connections of the upload proxy and upload servers
+type UploadTimeouts struct {
+	//ReadTimeout is how long reading an upload request, with its body, may take, there is no limit if it is not set
+	ReadTimeout *metav1.Duration `json:"readTimeout,omitempty"`
+	//WriteTimeout is how long an upload request may take until its response is written, there is no limit if it is not set
+	WriteTimeout *metav1.Duration `json:"writeTimeout,omitempty"`
+	//IdleTimeout is how long an idle keep-alive connection is kept open for the next request, defaults to the ReadTimeout, there is no limit if neither is set
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+	//KeepAlivePeriod is the period of the TCP keep-alive probes of the connections, which keep middleboxes from dropping connections that are idle while an upload is written, defaults to 15s
+	KeepAlivePeriod *metav1.Duration `json:"keepAlivePeriod,omitempty"`
+	//ProxyRequestTimeout is how long the upload proxy waits for an upload server to take an upload, defaults to 24h
+	ProxyRequestTimeout *metav1.Duration `json:"proxyRequestTimeout,omitempty"`
+}
+
+//SecurityProfiles defines the security profiles applied to transfer pods
+type SecurityProfiles struct {
+	//Seccomp applies the seccomp profiles of the transfer pods, cdi-importer.json, cdi-uploadserver.json and cdi-cloner.json in the seccomp directory of the kubelet
+	Seccomp bool `json:"seccomp,omitempty"`
+	//AppArmor applies the cdi-transfer AppArmor profile to the transfer pods, which has to be loaded on the nodes
+	AppArmor bool `json:"appArmor,omitempty"`
+}
+
+//Diagnostics defines the diagnostic endpoints of the CDI controller and the upload proxy
+type Diagnostics struct {
+	//Port is the port the endpoints are served on, defaults to 6060
+	Port int32 `json:"port,omitempty"`
+}
+
+//CloneCompression defines the compression of the data stream of host-assisted clones
+type CloneCompression struct {
+	//Encoding is the Content-Encoding of the stream, options: "gzip", "zstd", "identity" to send it uncompressed, an encoding the upload server does not accept falls back to gzip, then identity, defaults to gzip
+	Encoding string `json:"encoding,omitempty"`
+	//Level is the compression level, higher levels compress smaller at a higher CPU cost, 1 to 9 for gzip, defaults to the default level of the encoding
+	Level int32 `json:"level,omitempty"`
+}
+
+//TransferWindow defines a time of the week low priority transfers may start in
+type TransferWindow struct {
+	//Days are the days of the week the window opens on, options: "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun", every day if it is not set
+	Days []string `json:"days,omitempty"`
+	//Start is the time of day in UTC the window opens at, as HH:MM
+	Start string `json:"start"`
+	//End is the time of day in UTC the window closes at, as HH:MM, the next day if it is before the start, a window that closes when it opens lasts the whole day
+	End string `json:"end"`
 }
 
 //CDIConfigStatus provides
@@ -307,3 +719,133 @@ type CDIConfigList struct {
 	// Items provides a list of CDIConfigs
 	Items []CDIConfig `json:"items"`
 }
+
+// CDIQuota caps the resources the transfer pods of CDI may use in its namespace. A transfer that would
+// exceed a CDIQuota is queued until enough transfers of the namespace complete.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type CDIQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CDIQuotaSpec `json:"spec"`
+}
+
+//CDIQuotaSpec defines the limits of a CDIQuota, a limit that is not set does not apply
+type CDIQuotaSpec struct {
+	//MaxTransferPods is the number of importer, upload server and clone source pods that may exist at the same time
+	MaxTransferPods *int32 `json:"maxTransferPods,omitempty"`
+	//MaxScratchSpace is the total storage the scratch space PVCs may request
+	MaxScratchSpace *resource.Quantity `json:"maxScratchSpace,omitempty"`
+	//MaxPodRequests is the total of the resources, such as cpu and memory, the transfer pods may request
+	MaxPodRequests corev1.ResourceList `json:"maxPodRequests,omitempty"`
+}
+
+//CDIQuotaList provides the needed parameters to do request a list of CDIQuotas from the system
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type CDIQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	// Items provides a list of CDIQuotas
+	Items []CDIQuota `json:"items"`
+}
+
+// OperationHistory records the last data operations of CDI in its namespace, so the users of the namespace can
+// find out how their imports, clones and uploads ended without access to the logs of CDI.
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type OperationHistory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status OperationHistoryStatus `json:"status,omitempty"`
+}
+
+//OperationHistoryStatus provides the recorded operations of an OperationHistory
+type OperationHistoryStatus struct {
+	//Operations are the last operations of the namespace, the most recent first
+	Operations []OperationRecord `json:"operations,omitempty"`
+}
+
+// OperationType is the type of a data operation
+type OperationType string
+
+const (
+	// OperationImport is the population of a Data Volume from an external source, or of a blank Data Volume
+	OperationImport OperationType = "Import"
+	// OperationClone is the population of a Data Volume from an existing PVC
+	OperationClone OperationType = "Clone"
+	// OperationUpload is the population of a Data Volume from an upload
+	OperationUpload OperationType = "Upload"
+)
+
+//OperationRecord describes a data operation that succeeded or failed
+type OperationRecord struct {
+	//Type is the type of the operation, Import, Clone or Upload
+	Type OperationType `json:"type"`
+	//Target is the name of the Data Volume the operation populated
+	Target string `json:"target"`
+	//Initiator is the user that created the Data Volume
+	Initiator string `json:"initiator,omitempty"`
+	//StartTime is when the Data Volume was created
+	StartTime metav1.Time `json:"startTime"`
+	//CompletionTime is when the operation succeeded or failed
+	CompletionTime metav1.Time `json:"completionTime"`
+	//Duration is the time from StartTime to CompletionTime, such as 1h2m3s
+	Duration string `json:"duration"`
+	//Result is the final phase of the Data Volume, Succeeded or Failed
+	Result DataVolumePhase `json:"result"`
+	//Message describes the result
+	Message string `json:"message,omitempty"`
+}
+
+//OperationHistoryList provides the needed parameters to do request a list of OperationHistories from the system
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type OperationHistoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	// Items provides a list of OperationHistories
+	Items []OperationHistory `json:"items"`
+}
+
+// DataVolumeTemplate is a data volume spec published for reuse, such as an operating system image of a given size and
+// storage class. Data volumes refer to it in their templateRef and are instantiated from it with their parameters.
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type DataVolumeTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DataVolumeTemplateSpec `json:"spec"`
+}
+
+//DataVolumeTemplateSpec defines the data volume spec of a DataVolumeTemplate and its parameters
+type DataVolumeTemplateSpec struct {
+	//Parameters are the parameters of the template, the string values of the data volume spec refer to them as ${name}
+	Parameters []DataVolumeTemplateParameter `json:"parameters,omitempty"`
+	//DataVolume is the spec of the data volumes instantiated from the template, the defaults of the fields they do not set themselves
+	DataVolume DataVolumeSpec `json:"dataVolume"`
+}
+
+//DataVolumeTemplateParameter is a parameter of a DataVolumeTemplate
+type DataVolumeTemplateParameter struct {
+	//Name is the name of the parameter
+	Name string `json:"name"`
+	//Description tells the users of the template what the parameter is for
+	Description string `json:"description,omitempty"`
+	//Default is the value of the parameter if a data volume does not set it
+	Default string `json:"default,omitempty"`
+	//Required parameters have to be set by every data volume instantiated from the template
+	Required bool `json:"required,omitempty"`
+}
+
+//DataVolumeTemplateList provides the needed parameters to do request a list of DataVolumeTemplates from the system
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type DataVolumeTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	// Items provides a list of DataVolumeTemplates
+	Items []DataVolumeTemplate `json:"items"`
+}
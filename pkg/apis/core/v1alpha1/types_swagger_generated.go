@@ -10,16 +10,82 @@ func (DataVolume) SwaggerDoc() map[string]string {
 
 func (DataVolumeSpec) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"":            "DataVolumeSpec defines our specification for a DataVolume type",
-		"source":      "Source is the src of the data for the requested DataVolume",
-		"pvc":         "PVC is a pointer to the PVC Spec we want to use",
-		"contentType": "DataVolumeContentType options: \"kubevirt\", \"archive\"",
+		"":                  "DataVolumeSpec defines our specification for a DataVolume type",
+		"source":            "Source is the src of the data for the requested DataVolume",
+		"pvc":               "PVC is a pointer to the PVC Spec we want to use",
+		"contentType":       "DataVolumeContentType options: \"kubevirt\", \"archive\"",
+		"priority":          "Priority of the transfer to the data volume options: \"low\", \"normal\", \"high\", defaults to normal",
+		"deadline":          "Deadline is how long a transfer pod of the data volume may run before it is terminated, overrides the TransferDeadline of the CDIConfig",
+		"pvcDeletionPolicy": "PVCDeletionPolicy is what happens to the PVC when the data volume is deleted, options: \"Delete\", \"Retain\", defaults to Delete, Retain keeps a populated PVC as a volume of its own",
+		"imageCheck":        "ImageCheck checks the consistency of an imported qcow2 image before it is converted, options: \"check\", \"repairLeaks\", a corrupt image fails the import",
+		"verification":      "Verification is how thoroughly the data written to the data volume is verified, options: \"none\", \"fast\", \"full\", overrides the DefaultVerification of the CDIConfig",
+		"blockWipe":         "BlockWipe discards or zeroes a block volume before it is populated, so data of an earlier user of the volume cannot show through the regions the image does not write, options: \"discard\", \"zero\"",
+		"dns":               "DNS overrides the host aliases and DNS settings of the importer pod, so sources only resolvable by other name servers can be imported",
+		"blockTarget":       "BlockTarget writes the imported image at an offset or into a partition of a block volume, so volumes with partitioning of their own can be populated",
+		"imageName":         "ImageName is the name of the file the image is written to on a file system volume, defaults to disk.img",
+		"nodeName":          "NodeName is the node the data volume is populated on, its transfer pod runs there and a volume that waits for its first consumer is provisioned there, for local volumes",
+		"fanOut":            "FanOut are the names of upload data volumes of the namespace the imported image is written to as well, so many identical volumes are populated while the source is read once",
+		"templateRef":       "TemplateRef instantiates the data volume from a DataVolumeTemplate, whose spec it takes with the parameters substituted, the fields the data volume sets itself take precedence over those of the template",
+		"bandwidthLimit":    "BandwidthLimit limits the rate the importer reads its source at, so large imports do not saturate a shared link, overrides the import bandwidth limit of the namespace",
+		"encryption":        "Encryption provides the keys that decrypt an encrypted source image and encrypt the imported image, so encrypted images do not have to be staged decrypted anywhere",
+	}
+}
+
+func (DataVolumeTemplateRef) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":           "DataVolumeTemplateRef refers to the DataVolumeTemplate a data volume is instantiated from",
+		"name":       "Name is the name of the DataVolumeTemplate",
+		"namespace":  "Namespace is the namespace of the DataVolumeTemplate, defaults to the namespace of the data volume",
+		"parameters": "Parameters are the values of the parameters of the template, by name",
+	}
+}
+
+func (DataVolumeBlockTarget) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":          "DataVolumeBlockTarget provides where an image is written to a block volume, at most one of its fields is set",
+		"offset":    "Offset is the byte offset of a block volume the image is written at, a multiple of 512",
+		"partition": "Partition is the number of the partition of the GPT or MBR partition table of a block volume the image is written into, starting at 1",
+	}
+}
+
+func (DataVolumeDNS) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":            "DataVolumeDNS provides the host aliases and DNS settings of the importer pod of a Data Volume",
+		"hostAliases": "HostAliases are added to the hosts file of the importer pod",
+		"policy":      "Policy is the DNS policy of the importer pod, options: \"ClusterFirst\", \"Default\", \"None\", defaults to ClusterFirst",
+		"config":      "Config is merged with the DNS settings the policy generates, it needs nameservers with policy None",
+	}
+}
+
+func (DataVolumeBandwidthLimit) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":         "DataVolumeBandwidthLimit provides the rate the importer of a Data Volume reads its source at, at most",
+		"rate":     "Rate is the bytes per second the importer reads its source at, at most, outside of the windows of the schedule, unlimited if not set",
+		"schedule": "Schedule are windows of the week with a rate of their own, the first window the time is in applies",
+	}
+}
+
+func (DataVolumeBandwidthWindow) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":      "DataVolumeBandwidthWindow provides a window of the week with a bandwidth limit of its own",
+		"days":  "Days are the days of the week the window starts on, options: \"Mon\", \"Tue\", \"Wed\", \"Thu\", \"Fri\", \"Sat\", \"Sun\", every day if none",
+		"start": "Start is the time of day in UTC the window starts at, as HH:MM",
+		"end":   "End is the time of day in UTC the window ends at, as HH:MM, a window that ends before it starts ends the next day",
+		"rate":  "Rate is the bytes per second the importer reads its source at, at most, in the window, unlimited if not set",
+	}
+}
+
+func (DataVolumeEncryption) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":                "DataVolumeEncryption provides the Secrets with the passphrases of the encrypted images of an import, in the \"passphrase\" key",
+		"sourceSecretRef": "SourceSecretRef is the name of the Secret with the passphrase that decrypts a LUKS or encrypted qcow2 source image",
+		"targetSecretRef": "TargetSecretRef is the name of the Secret with the passphrase the imported image is encrypted with as LUKS, the image is written unencrypted if not set",
 	}
 }
 
 func (DataVolumeSource) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"": "DataVolumeSource represents the source for our Data Volume, this can be HTTP, Imageio, S3, Registry or an existing PVC",
+		"": "DataVolumeSource represents the source for our Data Volume, this can be HTTP, Imageio, S3, Registry, SSH, Nutanix or an existing PVC",
 	}
 }
 
@@ -35,9 +101,20 @@ func (DataVolumeBlankImage) SwaggerDoc() map[string]string {
 	}
 }
 
+func (DataVolumeSourceSSH) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":                         "DataVolumeSourceSSH provides the parameters to create a Data Volume from a file or block device on a host reachable over SSH",
+		"url":                      "URL is the location of the disk, ssh://[user@]host[:port]/path",
+		"secretRef":                "SecretRef provides the secret holding the ssh-privatekey used to log in, and the known_hosts the key of the host is verified with",
+		"backingFileURLs":          "BackingFileURLs are the URL prefixes the backing files of a qcow2 image may be fetched from, the backing chain is then flattened into the imported disk. Images with backing files are rejected when empty",
+		"insecureSkipHostKeyCheck": "InsecureSkipHostKeyCheck allows importing from a host whose key is not in known_hosts, without verifying the key. The import fails without known_hosts when unset",
+	}
+}
+
 func (DataVolumeSourceUpload) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"": "DataVolumeSourceUpload provides the parameters to create a Data Volume by uploading the source",
+		"":         "DataVolumeSourceUpload provides the parameters to create a Data Volume by uploading the source",
+		"checksum": "Checksum is the digest the state blob of a kubevirt-state upload is verified against, in the form sha256:<hex> or sha512:<hex>, an upload that does not match is refused and can be sent again",
 	}
 }
 
@@ -46,24 +123,40 @@ func (DataVolumeSourceS3) SwaggerDoc() map[string]string {
 		"":          "DataVolumeSourceS3 provides the parameters to create a Data Volume from an S3 source",
 		"url":       "URL is the url of the S3 source",
 		"secretRef": "SecretRef provides the secret reference needed to access the S3 source",
+		"checksum":  "Checksum is the digest the S3 object is pinned to, in the form sha256:<hex> or sha512:<hex>, the import fails with the ChecksumMismatch condition when the object does not match",
 	}
 }
 
 func (DataVolumeSourceRegistry) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"":              "DataVolumeSourceRegistry provides the parameters to create a Data Volume from an registry source",
-		"url":           "URL is the url of the Registry source",
-		"secretRef":     "SecretRef provides the secret reference needed to access the Registry source",
-		"certConfigMap": "CertConfigMap provides a reference to the Registry certs",
+		"":                   "DataVolumeSourceRegistry provides the parameters to create a Data Volume from an registry source",
+		"url":                "URL is the url of the Registry source",
+		"secretRef":          "SecretRef provides the secret reference needed to access the Registry source",
+		"certConfigMap":      "CertConfigMap provides a reference to the Registry certs",
+		"architecture":       "Architecture selects the image to import when the source is a multi-arch manifest list, defaults to the architecture of the node running the import",
+		"diskPath":           "DiskPath is a glob matching the path of the disk image in the image layers, for images that do not follow the containerDisk layout with the disk in /disk",
+		"artifactMediaType":  "ArtifactMediaType selects the disk image blob of an OCI artifact with several blobs by its media type",
+		"artifactAnnotation": "ArtifactAnnotation selects the disk image blob of an OCI artifact with several blobs by one of its annotations, in the form key or key=value",
 	}
 }
 
 func (DataVolumeSourceHTTP) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"":              "DataVolumeSourceHTTP provides the parameters to create a Data Volume from an HTTP source",
-		"url":           "URL is the URL of the http source",
-		"secretRef":     "SecretRef provides the secret reference needed to access the HTTP source",
-		"certConfigMap": "CertConfigMap provides a reference to the Registry certs",
+		"":                 "DataVolumeSourceHTTP provides the parameters to create a Data Volume from an HTTP source",
+		"url":              "URL is the URL of the http source",
+		"secretRef":        "SecretRef provides the secret reference needed to access the HTTP source",
+		"certConfigMap":    "CertConfigMap provides a reference to the Registry certs",
+		"backingFileURLs":  "BackingFileURLs are the URL prefixes the backing files of a qcow2 image may be fetched from, the backing chain is then flattened into the imported disk. Images with backing files are rejected when empty",
+		"checksum":         "Checksum is the digest the image at URL is pinned to, in the form sha256:<hex> or sha512:<hex>, the import fails with the ChecksumMismatch condition when the image does not match",
+		"additionalImages": "AdditionalImages are imported next to the image at URL with content type \"images\", each to the file of its name",
+	}
+}
+
+func (DataVolumeNamedImage) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":     "DataVolumeNamedImage provides an image imported to a file of its own on a file system volume",
+		"name": "Name is the name of the file the image is written to",
+		"url":  "URL is the URL of the http source of the image",
 	}
 }
 
@@ -77,10 +170,54 @@ func (DataVolumeSourceImageIO) SwaggerDoc() map[string]string {
 	}
 }
 
+func (DataVolumeSourceNutanix) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":              "DataVolumeSourceNutanix provides the parameters to create a Data Volume from an image of the Nutanix AHV image service",
+		"url":           "URL is the URL of the Prism Central API, https://host:9440",
+		"imageUUID":     "ImageUUID is the uuid of the image to import",
+		"secretRef":     "SecretRef provides the secret reference needed to access the Prism Central API",
+		"certConfigMap": "CertConfigMap provides a reference to the CA cert",
+	}
+}
+
 func (DataVolumeStatus) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"":      "DataVolumeStatus provides the parameters to store the phase of the Data Volume",
-		"phase": "Phase is the current phase of the data volume",
+		"":                 "DataVolumeStatus provides the parameters to store the phase of the Data Volume",
+		"phase":            "Phase is the current phase of the data volume",
+		"conditions":       "Conditions are the conditions of the data volume, Queued is set while its transfer waits for a CDIQuota, InsufficientScratchSpace when its import ran out of scratch space, Timeout when its transfer ran longer than its deadline, Verified when its clone was checked against the checksums of the source, ChecksumMismatch when its source does not match the digest it is pinned to, ImageChecked when its image was checked for consistency, TransferFailed when a transfer pod failed, Stalled when its transfer pod transfers no data, TokenExpired when its clone token expired before the clone started, NodeUnschedulable while the node it is pinned to can not run its transfer pod, WaitingForTransferWindow while its low priority transfer waits for a transfer window to open",
+		"claim":            "Claim is what was decided for the PVC of the data volume, by CDI and by the defaults of the cluster",
+		"phaseTransitions": "PhaseTransitions are the phases the data volume entered and when, oldest first",
+		"stageDurations":   "StageDurations are how long the stages of the import to the data volume took, once it succeeded",
+	}
+}
+
+func (DataVolumeClaimStatus) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":                 "DataVolumeClaimStatus is the effective volume mode, access modes, storage class and node topology of the PVC of a\ndata volume, once defaults, a volume mode fallback and the provisioner filled them in",
+		"volumeMode":       "VolumeMode is the volume mode of the PVC",
+		"accessModes":      "AccessModes are the access modes of the PVC, those of its volume once it is bound",
+		"storageClassName": "StorageClassName is the storage class of the PVC, the default storage class if the data volume names none",
+		"volumeName":       "VolumeName is the name of the persistent volume the PVC is bound to",
+		"selectedNode":     "SelectedNode is the node the PVC was provisioned for, with a storage class that waits for the first consumer",
+		"nodeAffinity":     "NodeAffinity is the node affinity of the bound volume, the nodes the data volume can be used from",
+		"cloneSizing":      "CloneSizing tells how the storage request of the PVC of a clone was derived from its source in another volume mode",
+	}
+}
+
+func (DataVolumePhaseTransition) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":               "DataVolumePhaseTransition is a phase a data volume entered",
+		"phase":          "Phase is the phase the data volume entered",
+		"transitionTime": "TransitionTime is when the data volume entered the phase",
+	}
+}
+
+func (DataVolumeStageDurations) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":         "DataVolumeStageDurations are how long the stages of an import took, the stages the import did not go through are\nnot set",
+		"download": "Download is how long transferring the data of the source took",
+		"convert":  "Convert is how long extracting the data and converting the image to raw took",
+		"resize":   "Resize is how long resizing the image to the size of the data volume took",
 	}
 }
 
@@ -99,7 +236,22 @@ func (CDI) SwaggerDoc() map[string]string {
 
 func (CDISpec) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"": "CDISpec defines our specification for the CDI installation",
+		"":                              "CDISpec defines our specification for the CDI installation",
+		"uploadProxyVirtualHostSecrets": "UploadProxyVirtualHostSecrets are the names of the TLS Secrets in the CDI namespace with the serving certificates of additional hostnames of the upload proxy",
+		"uploadProxyIngress":            "UploadProxyIngress is how the operator exposes the upload proxy outside the cluster, with a Route on OpenShift and an Ingress elsewhere",
+		"uploadProxyTrustedProxies":     "UploadProxyTrustedProxies are the CIDRs of the load balancers in front of the upload proxy, whose PROXY protocol and X-Forwarded-For and X-Forwarded-Proto headers tell the address of the client",
+		"tokenSigningAlgorithm":         "TokenSigningAlgorithm is the algorithm the apiserver signs the upload and clone tokens with, PS256 with an RSA key, the default, or ES256 with an ECDSA P-256 key. Changing it rotates the signing key",
+	}
+}
+
+func (CDIUploadProxyIngress) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":               "CDIUploadProxyIngress defines the Route or Ingress of the upload proxy",
+		"host":           "Host is the hostname clients upload to, a Route without it gets a generated hostname, an Ingress is only created with it",
+		"tlsTermination": "TLSTermination is where the TLS connection of clients ends, options: \"reencrypt\", \"passthrough\", defaults to \"reencrypt\"",
+		"tlsSecret":      "TLSSecret is the name of the TLS Secret in the CDI namespace with the certificate of Host, used to terminate TLS at an Ingress",
+		"ingressClass":   "IngressClass is the class of the Ingress controller that serves the Ingress",
+		"timeout":        "Timeout is how long the Route or Ingress waits for the upload proxy to answer, uploads of large images that are converted need a long one, defaults to 60m",
 	}
 }
 
@@ -124,7 +276,85 @@ func (CDIConfig) SwaggerDoc() map[string]string {
 
 func (CDIConfigSpec) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"": "CDIConfigSpec defines specification for user configuration",
+		"":                            "CDIConfigSpec defines specification for user configuration",
+		"scratchSpaceMaxSize":         "ScratchSpaceMaxSize is the largest scratch space an import may be retried with after its scratch space ran out, scratch space is not enlarged if it is not set",
+		"transferDeadline":            "TransferDeadline is how long a transfer pod may run before it is terminated, unless its data volume sets a deadline, transfer pods run until they are done if it is not set",
+		"completedPodRetention":       "CompletedPodRetention is how long succeeded importer, clone source and upload server pods, and the services of the upload servers, are kept before they are deleted, they are deleted right away if it is not set",
+		"preserveTransferLogs":        "PreserveTransferLogs keeps the end of the log of a transfer pod in an annotation of its PVC when the pod is deleted after the CompletedPodRetention",
+		"scratchSpacePool":            "ScratchSpacePool keeps the volumes of completed scratch spaces for later scratch spaces, so they are not provisioned and deleted for each transfer",
+		"registryCacheRetention":      "RegistryCacheRetention decides which versions of an image in the registry cache are deleted, cache entries are kept until they are deleted by hand if it is not set",
+		"defaultVerification":         "DefaultVerification is how thoroughly the data written to a data volume that sets no verification is verified, options: \"none\", \"fast\", \"full\", defaults to full",
+		"uploadTimeouts":              "UploadTimeouts are the timeouts and TCP keep-alive period of the connections of the upload proxy and upload servers, so slow uploads are not dropped, the upload proxy applies changes when it restarts",
+		"excludeFromServiceMesh":      "ExcludeFromServiceMesh keeps the sidecars of service meshes such as Istio and Linkerd out of transfer pods, which would keep running with a sidecar after the transfer and whose TLS connections a sidecar would wrap in its own mTLS",
+		"uploadContentEncodings":      "UploadContentEncodings are the Content-Encodings of uploads the upload servers decompress on the fly, options: \"gzip\", compressed uploads are refused if it is not set",
+		"uploadMaxConcurrentRequests": "UploadMaxConcurrentRequests is how many requests with upload data an upload server takes at once, to bound its memory, further requests are answered with 429 until one finished, defaults to 4",
+		"securityProfiles":            "SecurityProfiles applies the seccomp and AppArmor profiles CDI ships to importer, upload server, clone source and exporter pods, the profiles have to be installed on the nodes",
+		"importUserAgent":             "ImportUserAgent is the product the importer names in the User-Agent of its HTTP and S3 requests, followed by the ID of the cluster and the UID of the data volume, defaults to containerized-data-importer",
+		"diagnostics":                 "Diagnostics serves the pprof profiles and the expvar runtime metrics of the CDI controller and the upload proxy on their localhost, to be reached with kubectl port-forward, the endpoints are not served if it is not set, the processes apply changes when they restart",
+		"cloneCompression":            "CloneCompression is how the clone source pods compress the data of host-assisted clones on the way to the upload servers, gzip at its default level if it is not set",
+		"transferWindows":             "TransferWindows are the times of the week low priority imports and host-assisted clones may start in, a namespace can set its own with the cdi.kubevirt.io/transferWindows annotation, they start at any time if it is not set",
+		"annotateTransferNodes":       "AnnotateTransferNodes annotates the nodes running importer, upload server and clone source pods with the number of those pods, and keeps the cluster autoscaler from scaling them down until the transfers are done",
+	}
+}
+
+func (ScratchSpacePool) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":                    "ScratchSpacePool defines the pool of scratch space volumes",
+		"sizeClasses":         "SizeClasses are the sizes of the volumes in the pool, a scratch space gets a volume of the smallest size class that fits it, scratch spaces larger than all size classes are not pooled",
+		"volumesPerSizeClass": "VolumesPerSizeClass is how many volumes of each size class are kept ready in the pool",
+	}
+}
+
+func (RegistryCacheRetention) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":               "RegistryCacheRetention defines which registry cache entries are kept, an entry is deleted when none of the rules keeps it",
+		"keepLast":       "KeepLast keeps the newest cache entries of each image in a namespace",
+		"keepFor":        "KeepFor keeps the cache entries younger than this duration",
+		"keepReferenced": "KeepReferenced keeps the cache entries of a digest other DataVolumes in the namespace still import",
+		"dryRun":         "DryRun reports the cache entries that would be deleted, with an event on their DataVolume, instead of deleting them",
+	}
+}
+
+func (UploadTimeouts) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":                    "UploadTimeouts defines the timeouts of the connections of the upload proxy and upload servers",
+		"readTimeout":         "ReadTimeout is how long reading an upload request, with its body, may take, there is no limit if it is not set",
+		"writeTimeout":        "WriteTimeout is how long an upload request may take until its response is written, there is no limit if it is not set",
+		"idleTimeout":         "IdleTimeout is how long an idle keep-alive connection is kept open for the next request, defaults to the ReadTimeout, there is no limit if neither is set",
+		"keepAlivePeriod":     "KeepAlivePeriod is the period of the TCP keep-alive probes of the connections, which keep middleboxes from dropping connections that are idle while an upload is written, defaults to 15s",
+		"proxyRequestTimeout": "ProxyRequestTimeout is how long the upload proxy waits for an upload server to take an upload, defaults to 24h",
+	}
+}
+
+func (SecurityProfiles) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":         "SecurityProfiles defines the security profiles applied to transfer pods",
+		"seccomp":  "Seccomp applies the seccomp profiles of the transfer pods, cdi-importer.json, cdi-uploadserver.json and cdi-cloner.json in the seccomp directory of the kubelet",
+		"appArmor": "AppArmor applies the cdi-transfer AppArmor profile to the transfer pods, which has to be loaded on the nodes",
+	}
+}
+
+func (Diagnostics) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":     "Diagnostics defines the diagnostic endpoints of the CDI controller and the upload proxy",
+		"port": "Port is the port the endpoints are served on, defaults to 6060",
+	}
+}
+
+func (CloneCompression) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":         "CloneCompression defines the compression of the data stream of host-assisted clones",
+		"encoding": "Encoding is the Content-Encoding of the stream, options: \"gzip\", \"zstd\", \"identity\" to send it uncompressed, an encoding the upload server does not accept falls back to gzip, then identity, defaults to gzip",
+		"level":    "Level is the compression level, higher levels compress smaller at a higher CPU cost, 1 to 9 for gzip, defaults to the default level of the encoding",
+	}
+}
+
+func (TransferWindow) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":      "TransferWindow defines a time of the week low priority transfers may start in",
+		"days":  "Days are the days of the week the window opens on, options: \"Mon\", \"Tue\", \"Wed\", \"Thu\", \"Fri\", \"Sat\", \"Sun\", every day if it is not set",
+		"start": "Start is the time of day in UTC the window opens at, as HH:MM",
+		"end":   "End is the time of day in UTC the window closes at, as HH:MM, the next day if it is before the start, a window that closes when it opens lasts the whole day",
 	}
 }
 
@@ -140,3 +370,90 @@ func (CDIConfigList) SwaggerDoc() map[string]string {
 		"items": "Items provides a list of CDIConfigs",
 	}
 }
+
+func (CDIQuota) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"": "CDIQuota caps the resources the transfer pods of CDI may use in its namespace. A transfer that would\nexceed a CDIQuota is queued until enough transfers of the namespace complete.\n+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object",
+	}
+}
+
+func (CDIQuotaSpec) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":                "CDIQuotaSpec defines the limits of a CDIQuota, a limit that is not set does not apply",
+		"maxTransferPods": "MaxTransferPods is the number of importer, upload server and clone source pods that may exist at the same time",
+		"maxScratchSpace": "MaxScratchSpace is the total storage the scratch space PVCs may request",
+		"maxPodRequests":  "MaxPodRequests is the total of the resources, such as cpu and memory, the transfer pods may request",
+	}
+}
+
+func (CDIQuotaList) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":      "CDIQuotaList provides the needed parameters to do request a list of CDIQuotas from the system\n+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object",
+		"items": "Items provides a list of CDIQuotas",
+	}
+}
+
+func (OperationHistory) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"": "OperationHistory records the last data operations of CDI in its namespace, so the users of the namespace can\nfind out how their imports, clones and uploads ended without access to the logs of CDI.\n+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object",
+	}
+}
+
+func (OperationHistoryStatus) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":           "OperationHistoryStatus provides the recorded operations of an OperationHistory",
+		"operations": "Operations are the last operations of the namespace, the most recent first",
+	}
+}
+
+func (OperationRecord) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":               "OperationRecord describes a data operation that succeeded or failed",
+		"type":           "Type is the type of the operation, Import, Clone or Upload",
+		"target":         "Target is the name of the Data Volume the operation populated",
+		"initiator":      "Initiator is the user that created the Data Volume",
+		"startTime":      "StartTime is when the Data Volume was created",
+		"completionTime": "CompletionTime is when the operation succeeded or failed",
+		"duration":       "Duration is the time from StartTime to CompletionTime, such as 1h2m3s",
+		"result":         "Result is the final phase of the Data Volume, Succeeded or Failed",
+		"message":        "Message describes the result",
+	}
+}
+
+func (OperationHistoryList) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":      "OperationHistoryList provides the needed parameters to do request a list of OperationHistories from the system\n+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object",
+		"items": "Items provides a list of OperationHistories",
+	}
+}
+
+func (DataVolumeTemplate) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"": "DataVolumeTemplate is a data volume spec published for reuse, such as an operating system image of a given size and\nstorage class. Data volumes refer to it in their templateRef and are instantiated from it with their parameters.\n+genclient\n+genclient:noStatus\n+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object",
+	}
+}
+
+func (DataVolumeTemplateSpec) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":           "DataVolumeTemplateSpec defines the data volume spec of a DataVolumeTemplate and its parameters",
+		"parameters": "Parameters are the parameters of the template, the string values of the data volume spec refer to them as ${name}",
+		"dataVolume": "DataVolume is the spec of the data volumes instantiated from the template, the defaults of the fields they do not set themselves",
+	}
+}
+
+func (DataVolumeTemplateParameter) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":            "DataVolumeTemplateParameter is a parameter of a DataVolumeTemplate",
+		"name":        "Name is the name of the parameter",
+		"description": "Description tells the users of the template what the parameter is for",
+		"default":     "Default is the value of the parameter if a data volume does not set it",
+		"required":    "Required parameters have to be set by every data volume instantiated from the template",
+	}
+}
+
+func (DataVolumeTemplateList) SwaggerDoc() map[string]string {
+	return map[string]string{
+		"":      "DataVolumeTemplateList provides the needed parameters to do request a list of DataVolumeTemplates from the system\n+k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object",
+		"items": "Items provides a list of DataVolumeTemplates",
+	}
+}
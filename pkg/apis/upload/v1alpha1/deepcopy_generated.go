@@ -30,7 +30,7 @@ func (in *UploadTokenRequest) DeepCopyInto(out *UploadTokenRequest) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -104,6 +104,10 @@ func (in *UploadTokenRequestSpec) DeepCopy() *UploadTokenRequestSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UploadTokenRequestStatus) DeepCopyInto(out *UploadTokenRequestStatus) {
 	*out = *in
+	if in.SessionExpires != nil {
+		in, out := &in.SessionExpires, &out.SessionExpires
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -145,6 +145,13 @@ func schema_pkg_apis_upload_v1alpha1_UploadTokenRequestSpec(ref common.Reference
 							Format:      "",
 						},
 					},
+					"session": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Session requests an upload session, which can be resumed from its offset and uploaded over several connections, through any upload proxy",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"pvcName"},
 			},
@@ -166,8 +173,23 @@ func schema_pkg_apis_upload_v1alpha1_UploadTokenRequestStatus(ref common.Referen
 							Format:      "",
 						},
 					},
+					"sessionID": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SessionID identifies the upload session the token is for, if one was requested",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sessionExpires": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SessionExpires is when the upload session and its token expire",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
 				},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
@@ -42,12 +42,22 @@ type UploadTokenRequest struct {
 type UploadTokenRequestSpec struct {
 	// PvcName is the name of the PVC to upload to
 	PvcName string `json:"pvcName"`
+
+	// Session requests an upload session, which can be resumed from its offset and uploaded over several
+	// connections, through any upload proxy
+	Session bool `json:"session,omitempty"`
 }
 
 // UploadTokenRequestStatus stores the status of a token request
 type UploadTokenRequestStatus struct {
 	// Token is a JWT token to be inserted in "Authentication Bearer header"
 	Token string `json:"token,omitempty"`
+
+	// SessionID identifies the upload session the token is for, if one was requested
+	SessionID string `json:"sessionID,omitempty"`
+
+	// SessionExpires is when the upload session and its token expire
+	SessionExpires *metav1.Time `json:"sessionExpires,omitempty"`
 }
 
 // UploadTokenRequestList contains a list of UploadTokenRequests
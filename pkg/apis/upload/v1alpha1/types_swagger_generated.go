@@ -14,13 +14,16 @@ func (UploadTokenRequestSpec) SwaggerDoc() map[string]string {
 	return map[string]string{
 		"":        "UploadTokenRequestSpec defines the parameters of the token request",
 		"pvcName": "PvcName is the name of the PVC to upload to",
+		"session": "Session requests an upload session, which can be resumed from its offset and uploaded over several\nconnections, through any upload proxy",
 	}
 }
 
 func (UploadTokenRequestStatus) SwaggerDoc() map[string]string {
 	return map[string]string{
-		"":      "UploadTokenRequestStatus stores the status of a token request",
-		"token": "Token is a JWT token to be inserted in \"Authentication Bearer header\"",
+		"":               "UploadTokenRequestStatus stores the status of a token request",
+		"token":          "Token is a JWT token to be inserted in \"Authentication Bearer header\"",
+		"sessionID":      "SessionID identifies the upload session the token is for, if one was requested",
+		"sessionExpires": "SessionExpires is when the upload session and its token expire",
 	}
 }
 
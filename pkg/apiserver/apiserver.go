@@ -21,15 +21,17 @@ package apiserver
 
 import (
 	"context"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -38,6 +40,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 	aggregatorclient "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
@@ -88,7 +91,7 @@ type cdiAPIApp struct {
 	aggregatorClient aggregatorclient.Interface
 	cdiClient        cdiclient.Interface
 
-	privateSigningKey *rsa.PrivateKey
+	signingKey *keys.SigningKey
 
 	container *restful.Container
 
@@ -178,8 +181,14 @@ func NewCdiAPIServer(bindAddress string,
 	return app, nil
 }
 
-func newUploadTokenGenerator(key *rsa.PrivateKey) token.Generator {
-	return token.NewGenerator(common.UploadTokenIssuer, key, 5*time.Minute)
+func newUploadTokenGenerator(key *keys.SigningKey) token.Generator {
+	return key.Generator(common.UploadTokenIssuer, 5*time.Minute)
+}
+
+// newUploadSessionTokenGenerator returns a generator of the tokens for upload sessions, which last as long as their
+// session
+func newUploadSessionTokenGenerator(key *keys.SigningKey) token.Generator {
+	return key.Generator(common.UploadTokenIssuer, controller.UploadSessionLifetime)
 }
 
 func (app *cdiAPIApp) Start(ch <-chan struct{}) error {
@@ -189,14 +198,23 @@ func (app *cdiAPIApp) Start(ch <-chan struct{}) error {
 func (app *cdiAPIApp) getKeysAndCerts() error {
 	namespace := util.GetNamespace()
 
-	privateKey, err := keys.GetOrCreatePrivateKey(app.client, namespace, apiSigningKeySecretName)
+	signingKey, err := keys.GetOrCreateSigningKey(app.client, namespace, apiSigningKeySecretName, os.Getenv(common.TokenSigningAlgorithm))
 	if err != nil {
 		return errors.Wrap(err, "Error getting/creating signing key")
 	}
 
-	app.privateSigningKey = privateKey
+	app.signingKey = signingKey
+
+	app.tokenGenerator = newUploadTokenGenerator(signingKey)
 
-	app.tokenGenerator = newUploadTokenGenerator(privateKey)
+	if signingKey.Previous != nil {
+		// Complete the rotation once the tokens of the previous key expired
+		time.AfterFunc(time.Until(signingKey.ActiveAt.Add(keys.SigningKeyRotationPeriod-keys.KeyPropagationDelay)), func() {
+			if err := keys.PruneSigningKey(app.client, namespace, apiSigningKeySecretName); err != nil {
+				klog.Errorf("Unable to remove the previous signing key: %v", err)
+			}
+		})
+	}
 
 	return nil
 }
@@ -257,7 +275,7 @@ func (app *cdiAPIApp) startTLS(stopChan <-chan struct{}) error {
 	}
 
 	server := &http.Server{
-		Addr:      fmt.Sprintf("%s:%d", app.bindAddress, app.bindPort),
+		Addr:      net.JoinHostPort(app.bindAddress, strconv.FormatUint(uint64(app.bindPort), 10)),
 		TLSConfig: tlsConfig,
 		Handler:   app.container,
 	}
@@ -333,7 +351,21 @@ func (app *cdiAPIApp) uploadHandler(request *restful.Request, response *restful.
 		},
 	}
 
-	token, err := app.tokenGenerator.Generate(tokenData)
+	tokenGenerator := app.tokenGenerator
+	if uploadToken.Spec.Session {
+		session, err := app.createUploadSession(pvc)
+		if err != nil {
+			klog.Error(err)
+			response.WriteError(http.StatusInternalServerError, err)
+			return
+		}
+		tokenData.Params = map[string]string{controller.UploadSessionParam: session.ID}
+		tokenGenerator = newUploadSessionTokenGenerator(app.signingKey)
+		uploadToken.Status.SessionID = session.ID
+		uploadToken.Status.SessionExpires = &session.Expires
+	}
+
+	token, err := tokenGenerator.Generate(tokenData)
 	if err != nil {
 		klog.Error(err)
 		response.WriteError(http.StatusInternalServerError, err)
@@ -345,6 +377,23 @@ func (app *cdiAPIApp) uploadHandler(request *restful.Request, response *restful.
 
 }
 
+// createUploadSession starts a new upload session to pvc, which replaces the session it had. The token of the
+// replaced session is no longer accepted.
+func (app *cdiAPIApp) createUploadSession(pvc *v1.PersistentVolumeClaim) (*controller.UploadSession, error) {
+	session := &controller.UploadSession{
+		ID:      string(uuid.NewUUID()),
+		Expires: metav1.NewTime(time.Now().Add(controller.UploadSessionLifetime).Truncate(time.Second)),
+	}
+	pvc = pvc.DeepCopy()
+	if err := controller.SetUploadSession(pvc, session); err != nil {
+		return nil, err
+	}
+	if _, err := app.client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(pvc); err != nil {
+		return nil, errors.Wrap(err, "Error recording the upload session")
+	}
+	return session, nil
+}
+
 func uploadTokenAPIGroup() metav1.APIGroup {
 	apiGroup := metav1.APIGroup{
 		Name: uploadTokenGroup,
@@ -360,6 +409,9 @@ func uploadTokenAPIGroup() metav1.APIGroup {
 	apiGroup.ServerAddressByClientCIDRs = append(apiGroup.ServerAddressByClientCIDRs, metav1.ServerAddressByClientCIDR{
 		ClientCIDR:    "0.0.0.0/0",
 		ServerAddress: "",
+	}, metav1.ServerAddressByClientCIDR{
+		ClientCIDR:    "::/0",
+		ServerAddress: "",
 	})
 	apiGroup.Kind = "APIGroup"
 	apiGroup.APIVersion = "v1"
@@ -487,7 +539,7 @@ func (app *cdiAPIApp) createDataVolumeValidatingWebhook() error {
 }
 
 func (app *cdiAPIApp) createDataVolumeMutatingWebhook() error {
-	app.container.ServeMux.Handle(dvMutatePath, webhooks.NewDataVolumeMutatingWebhook(app.client, app.privateSigningKey))
+	app.container.ServeMux.Handle(dvMutatePath, webhooks.NewDataVolumeMutatingWebhook(app.client, app.cdiClient, app.signingKey))
 	return nil
 }
 
@@ -40,7 +40,11 @@ import (
 	core "k8s.io/client-go/testing"
 
 	cdiuploadv1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/upload/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/keys"
 	"kubevirt.io/containerized-data-importer/pkg/keys/keystest"
+	"kubevirt.io/containerized-data-importer/pkg/token"
 )
 
 type testAuthorizer struct {
@@ -207,7 +211,7 @@ func TestShouldGenerateCertsAndKeyFirstRun(t *testing.T) {
 	actions := []core.Action{}
 	actions = append(actions, signingKeySecretGetAction())
 	actions = append(actions, cdiConfigGetAction())
-	actions = append(actions, signingKeySecretCreateAction(app.privateSigningKey))
+	actions = append(actions, signingKeySecretCreateAction(app.signingKey.Key.(*rsa.PrivateKey)))
 
 	checkActions(actions, client.Actions(), t)
 }
@@ -407,10 +411,10 @@ func TestGetToken(t *testing.T) {
 			client := k8sfake.NewSimpleClientset(kubeobjects...)
 
 			app := &cdiAPIApp{client: client,
-				privateSigningKey: signingKey,
-				authorizer:        test.args.authorizer,
-				uploadPossible:    test.args.uploadPossible,
-				tokenGenerator:    newUploadTokenGenerator(signingKey)}
+				signingKey:     &keys.SigningKey{Key: signingKey},
+				authorizer:     test.args.authorizer,
+				uploadPossible: test.args.uploadPossible,
+				tokenGenerator: newUploadTokenGenerator(&keys.SigningKey{Key: signingKey})}
 			app.composeUploadTokenAPI()
 
 			req, _ := http.NewRequest("POST",
@@ -440,6 +444,80 @@ func TestGetToken(t *testing.T) {
 	}
 }
 
+func TestGetSessionToken(t *testing.T) {
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &cdiuploadv1alpha1.UploadTokenRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-token",
+			Namespace: "default",
+		},
+		Spec: cdiuploadv1alpha1.UploadTokenRequestSpec{
+			PvcName: "test-pvc",
+			Session: true,
+		},
+	}
+	serializedRequest, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pvc",
+			Namespace: "default",
+		},
+	}
+	client := k8sfake.NewSimpleClientset(pvc)
+
+	app := &cdiAPIApp{client: client,
+		signingKey:     &keys.SigningKey{Key: signingKey},
+		authorizer:     &testAuthorizer{allowed: true},
+		uploadPossible: func(*v1.PersistentVolumeClaim) error { return nil },
+		tokenGenerator: newUploadTokenGenerator(&keys.SigningKey{Key: signingKey})}
+	app.composeUploadTokenAPI()
+
+	req, _ := http.NewRequest("POST",
+		"/apis/upload.cdi.kubevirt.io/v1alpha1/namespaces/default/uploadtokenrequests",
+		bytes.NewReader(serializedRequest))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	app.container.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Wrong status code, expected %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	response := &cdiuploadv1alpha1.UploadTokenRequest{}
+	if err := json.Unmarshal(rr.Body.Bytes(), response); err != nil {
+		t.Fatalf("Deserializing UploadTokenRequest failed: %+v", err)
+	}
+	if response.Status.SessionID == "" || response.Status.SessionExpires == nil {
+		t.Fatalf("UploadTokenRequest response does not contain a session: %+v", response.Status)
+	}
+
+	validator := token.NewValidator(common.UploadTokenIssuer, &signingKey.PublicKey, 0)
+	payload, err := validator.Validate(response.Status.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Params[controller.UploadSessionParam] != response.Status.SessionID {
+		t.Errorf("Token is for session %q, not %q", payload.Params[controller.UploadSessionParam], response.Status.SessionID)
+	}
+
+	pvc, err = client.CoreV1().PersistentVolumeClaims("default").Get("test-pvc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := controller.GetUploadSession(pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session == nil || session.ID != response.Status.SessionID || session.Offset != 0 {
+		t.Errorf("Unexpected upload session of the PVC %+v", session)
+	}
+}
+
 func doGetRequest(t *testing.T, url string) *httptest.ResponseRecorder {
 	app := &cdiAPIApp{}
 	app.composeUploadTokenAPI()
@@ -478,6 +556,10 @@ func getExpectedAPIGroup() metav1.APIGroup {
 				ClientCIDR:    "0.0.0.0/0",
 				ServerAddress: "",
 			},
+			{
+				ClientCIDR:    "::/0",
+				ServerAddress: "",
+			},
 		},
 	}
 }
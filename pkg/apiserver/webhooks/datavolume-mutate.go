@@ -21,12 +21,14 @@ package webhooks
 
 import (
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sfield "k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 
 	cdiv1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
 	"kubevirt.io/containerized-data-importer/pkg/clone"
 	"kubevirt.io/containerized-data-importer/pkg/controller"
 	"kubevirt.io/containerized-data-importer/pkg/token"
@@ -34,6 +36,7 @@ import (
 
 type dataVolumeMutatingWebhook struct {
 	client         kubernetes.Interface
+	cdiClient      cdiclient.Interface
 	tokenGenerator token.Generator
 }
 
@@ -59,7 +62,6 @@ func (wh *dataVolumeMutatingWebhook) Admit(ar admissionv1beta1.AdmissionReview)
 		return toAdmissionResponseError(err)
 	}
 
-	pvcSource := dataVolume.Spec.Source.PVC
 	targetNamespace, targetName := dataVolume.Namespace, dataVolume.Name
 	if targetNamespace == "" {
 		targetNamespace = ar.Request.Namespace
@@ -69,8 +71,82 @@ func (wh *dataVolumeMutatingWebhook) Admit(ar admissionv1beta1.AdmissionReview)
 		targetName = ar.Request.Name
 	}
 
+	modifiedDataVolume := dataVolume.DeepCopy()
+	defaulted := false
+	if ar.Request.Operation == admissionv1beta1.Create && dataVolume.Spec.TemplateRef != nil {
+		spec, causes, err := instantiateTemplate(wh.client, wh.cdiClient, targetNamespace, &dataVolume.Spec, ar.Request.UserInfo)
+		if err != nil {
+			return toAdmissionResponseError(err)
+		}
+		if len(causes) > 0 {
+			return toRejectedAdmissionResponse(causes)
+		}
+		klog.V(3).Infof("DataVolume %s/%s is instantiated from DataVolumeTemplate %s", targetNamespace, targetName, dataVolume.Spec.TemplateRef.Name)
+		modifiedDataVolume.Spec = *spec
+		defaulted = true
+	}
+
+	pvcSource := modifiedDataVolume.Spec.Source.PVC
+	if ar.Request.Operation == admissionv1beta1.Create && modifiedDataVolume.Spec.PVC != nil {
+		annotations, err := getNamespaceAnnotations(wh.client, targetNamespace)
+		if err != nil {
+			return toAdmissionResponseError(err)
+		}
+		if modifiedDataVolume.Spec.PVC.VolumeName != "" && modifiedDataVolume.Spec.PVC.StorageClassName == nil {
+			// Neither the default storage class of the namespace nor that of the cluster may be set on a PVC that
+			// binds to a volume of its own
+			pv, err := wh.client.CoreV1().PersistentVolumes().Get(modifiedDataVolume.Spec.PVC.VolumeName, metav1.GetOptions{})
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return toAdmissionResponseError(err)
+			}
+			if err == nil {
+				klog.V(3).Infof("DataVolume %s/%s uses the storage class %q of persistent volume %s", targetNamespace, targetName, pv.Spec.StorageClassName, pv.Name)
+				modifiedDataVolume.Spec.PVC.StorageClassName = &pv.Spec.StorageClassName
+				defaulted = true
+			}
+		}
+		if storageClassName, ok := annotations[AnnDefaultStorageClass]; ok && storageClassName != "" && modifiedDataVolume.Spec.PVC.StorageClassName == nil {
+			klog.V(3).Infof("DataVolume %s/%s uses the default storage class %s of its namespace", targetNamespace, targetName, storageClassName)
+			modifiedDataVolume.Spec.PVC.StorageClassName = &storageClassName
+			defaulted = true
+		}
+		if storageClasses, ok := annotations[AnnDefaultFallbackStorageClasses]; ok && storageClasses != "" {
+			if _, ok := dataVolume.Annotations[controller.AnnFallbackStorageClasses]; !ok {
+				klog.V(3).Infof("DataVolume %s/%s falls back to the storage classes %s of its namespace", targetNamespace, targetName, storageClasses)
+				if modifiedDataVolume.Annotations == nil {
+					modifiedDataVolume.Annotations = make(map[string]string)
+				}
+				modifiedDataVolume.Annotations[controller.AnnFallbackStorageClasses] = storageClasses
+				defaulted = true
+			}
+		}
+	}
+
+	if ar.Request.Operation == admissionv1beta1.Update {
+		if _, _, err := deserializer.Decode(ar.Request.OldObject.Raw, nil, &oldDataVolume); err != nil {
+			return toAdmissionResponseError(err)
+		}
+	}
+
+	// The initiator is recorded in the operation history of the namespace, users may not set or change it
+	initiator := ar.Request.UserInfo.Username
+	if ar.Request.Operation == admissionv1beta1.Update {
+		initiator = oldDataVolume.Annotations[controller.AnnInitiator]
+	}
+	if (ar.Request.Operation == admissionv1beta1.Create || ar.Request.Operation == admissionv1beta1.Update) &&
+		modifiedDataVolume.Annotations[controller.AnnInitiator] != initiator {
+		if modifiedDataVolume.Annotations == nil {
+			modifiedDataVolume.Annotations = make(map[string]string)
+		}
+		modifiedDataVolume.Annotations[controller.AnnInitiator] = initiator
+		defaulted = true
+	}
+
 	if pvcSource == nil {
 		klog.V(3).Infof("DataVolume %s/%s not cloning", targetNamespace, targetName)
+		if defaulted {
+			return toPatchResponse(dataVolume, modifiedDataVolume)
+		}
 		return allowedAdmissionResponse()
 	}
 
@@ -80,13 +156,12 @@ func (wh *dataVolumeMutatingWebhook) Admit(ar admissionv1beta1.AdmissionReview)
 	}
 
 	if ar.Request.Operation == admissionv1beta1.Update {
-		if _, _, err := deserializer.Decode(ar.Request.OldObject.Raw, nil, &oldDataVolume); err != nil {
-			return toAdmissionResponseError(err)
-		}
-
 		_, ok := oldDataVolume.Annotations[controller.AnnCloneToken]
 		if ok {
 			klog.V(3).Infof("DataVolume %s/%s already has clone token", targetNamespace, targetName)
+			if defaulted {
+				return toPatchResponse(dataVolume, modifiedDataVolume)
+			}
 			return allowedAdmissionResponse()
 		}
 	}
@@ -123,7 +198,6 @@ func (wh *dataVolumeMutatingWebhook) Admit(ar admissionv1beta1.AdmissionReview)
 		return toAdmissionResponseError(err)
 	}
 
-	modifiedDataVolume := dataVolume.DeepCopy()
 	if modifiedDataVolume.Annotations == nil {
 		modifiedDataVolume.Annotations = make(map[string]string)
 	}
@@ -32,6 +32,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	authorization "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -39,7 +40,9 @@ import (
 	k8stesting "k8s.io/client-go/testing"
 
 	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
 	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/keys"
 )
 
 var _ = Describe("Mutating DataVolume Webhook", func() {
@@ -108,6 +111,77 @@ var _ = Describe("Mutating DataVolume Webhook", func() {
 			Expect(resp.Patch).To(BeNil())
 		})
 
+		It("should record the initiator of a new DataVolume", func() {
+			dataVolume := newHTTPDataVolume("testDV", "http://www.example.com")
+			dataVolume.Annotations = map[string]string{controller.AnnInitiator: "someone-else"}
+			dvBytes, _ := json.Marshal(&dataVolume)
+
+			ar := &v1beta1.AdmissionReview{
+				Request: &v1beta1.AdmissionRequest{
+					Operation: v1beta1.Create,
+					Resource: metav1.GroupVersionResource{
+						Group:    cdicorev1alpha1.SchemeGroupVersion.Group,
+						Version:  cdicorev1alpha1.SchemeGroupVersion.Version,
+						Resource: "datavolumes",
+					},
+					Object: runtime.RawExtension{
+						Raw: dvBytes,
+					},
+					UserInfo: authenticationv1.UserInfo{
+						Username: "alice",
+					},
+				},
+			}
+
+			resp := mutateDVs(key, ar, true)
+			Expect(resp.Allowed).To(BeTrue())
+
+			var patchObjs []jsonpatch.Operation
+			err := json.Unmarshal(resp.Patch, &patchObjs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(patchObjs).Should(HaveLen(1))
+			Expect(patchObjs[0].Operation).Should(Equal("replace"))
+			Expect(patchObjs[0].Value).Should(Equal("alice"))
+		})
+
+		It("should keep the initiator of an updated DataVolume", func() {
+			dataVolume := newHTTPDataVolume("testDV", "http://www.example.com")
+			dataVolume.Annotations = map[string]string{controller.AnnInitiator: "alice"}
+			dvBytes, _ := json.Marshal(&dataVolume)
+
+			dataVolume.Annotations[controller.AnnInitiator] = "bob"
+			dvBytesUpdated, _ := json.Marshal(&dataVolume)
+
+			ar := &v1beta1.AdmissionReview{
+				Request: &v1beta1.AdmissionRequest{
+					Operation: v1beta1.Update,
+					Resource: metav1.GroupVersionResource{
+						Group:    cdicorev1alpha1.SchemeGroupVersion.Group,
+						Version:  cdicorev1alpha1.SchemeGroupVersion.Version,
+						Resource: "datavolumes",
+					},
+					Object: runtime.RawExtension{
+						Raw: dvBytesUpdated,
+					},
+					OldObject: runtime.RawExtension{
+						Raw: dvBytes,
+					},
+					UserInfo: authenticationv1.UserInfo{
+						Username: "bob",
+					},
+				},
+			}
+
+			resp := mutateDVs(key, ar, true)
+			Expect(resp.Allowed).To(BeTrue())
+
+			var patchObjs []jsonpatch.Operation
+			err := json.Unmarshal(resp.Patch, &patchObjs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(patchObjs).Should(HaveLen(1))
+			Expect(patchObjs[0].Value).Should(Equal("alice"))
+		})
+
 		It("should reject a clone DataVolume", func() {
 			dataVolume := newPVCDataVolume("testDV", "testNamespace", "test")
 			dvBytes, _ := json.Marshal(&dataVolume)
@@ -181,6 +255,6 @@ func mutateDVs(key *rsa.PrivateKey, ar *v1beta1.AdmissionReview, isAuthorized bo
 		}
 		return true, sar, nil
 	})
-	wh := NewDataVolumeMutatingWebhook(client, key)
+	wh := NewDataVolumeMutatingWebhook(client, cdiclient.NewSimpleClientset(), &keys.SigningKey{Key: key})
 	return serve(ar, wh)
 }
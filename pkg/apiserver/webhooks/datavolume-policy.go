@@ -0,0 +1,108 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 Red Hat, Inc.
+ *
+ */
+
+package webhooks
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfield "k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+
+	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+)
+
+const (
+	// AnnDefaultStorageClass is the namespace annotation with the storage class of the DataVolumes that do not name one
+	AnnDefaultStorageClass = controller.AnnAPIGroup + "/defaultStorageClass"
+	// AnnDefaultFallbackStorageClasses is the namespace annotation with the comma separated storage classes the
+	// DataVolumes that do not name their own fall back to
+	AnnDefaultFallbackStorageClasses = controller.AnnAPIGroup + "/fallbackStorageClasses"
+)
+
+// getNamespaceAnnotations returns the annotations of namespace, or none if it does not exist
+func getNamespaceAnnotations(client kubernetes.Interface, namespace string) (map[string]string, error) {
+	ns, err := client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not get namespace %s", namespace)
+	}
+	return ns.GetAnnotations(), nil
+}
+
+// dataVolumeSourceType returns the json name of the source of spec, e.g. http
+func dataVolumeSourceType(spec *cdicorev1alpha1.DataVolumeSpec) string {
+	s := reflect.ValueOf(&spec.Source).Elem()
+	for i := 0; i < s.NumField(); i++ {
+		if !s.Field(i).IsNil() {
+			return strings.Split(s.Type().Field(i).Tag.Get("json"), ",")[0]
+		}
+	}
+	return ""
+}
+
+// dataVolumeSourceURLs returns the URLs the source of spec reads from
+func dataVolumeSourceURLs(spec *cdicorev1alpha1.DataVolumeSpec) []string {
+	source := spec.Source
+	switch {
+	case source.HTTP != nil:
+		return append([]string{source.HTTP.URL}, source.HTTP.BackingFileURLs...)
+	case source.S3 != nil:
+		return []string{source.S3.URL}
+	case source.Registry != nil:
+		return []string{source.Registry.URL}
+	case source.Imageio != nil:
+		return []string{source.Imageio.URL}
+	case source.SSH != nil:
+		return append([]string{source.SSH.URL}, source.SSH.BackingFileURLs...)
+	case source.Nutanix != nil:
+		return []string{source.Nutanix.URL}
+	}
+	return nil
+}
+
+// validateNamespacePolicy checks the source of spec against the AnnAllowedSources and AnnAllowedSourceHosts
+// annotations of namespace, and a high priority against its AnnAllowHighPriority annotation
+func validateNamespacePolicy(annotations map[string]string, namespace string, field *k8sfield.Path, spec *cdicorev1alpha1.DataVolumeSpec) []metav1.StatusCause {
+	if spec.Priority == cdicorev1alpha1.DataVolumePriorityHigh && annotations[controller.AnnAllowHighPriority] != "true" {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("Priority %s is not allowed in namespace %s", spec.Priority, namespace),
+			Field:   field.Child("priority").String(),
+		}}
+	}
+
+	sourceType := dataVolumeSourceType(spec)
+	if err := controller.ValidateSourcePolicy(annotations, namespace, sourceType, dataVolumeSourceURLs(spec)); err != nil {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: err.Error(),
+			Field:   field.Child("source", sourceType).String(),
+		}}
+	}
+	return nil
+}
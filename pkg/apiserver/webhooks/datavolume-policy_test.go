@@ -0,0 +1,159 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 Red Hat, Inc.
+ *
+ */
+
+package webhooks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+
+	"github.com/appscode/jsonpatch"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+
+	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/keys"
+)
+
+func newPolicyNamespace(annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        corev1.NamespaceDefault,
+			Annotations: annotations,
+		},
+	}
+}
+
+func newCreateReview(dataVolume *cdicorev1alpha1.DataVolume) *v1beta1.AdmissionReview {
+	dvBytes, _ := json.Marshal(dataVolume)
+	return &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Operation: v1beta1.Create,
+			Namespace: corev1.NamespaceDefault,
+			Resource: metav1.GroupVersionResource{
+				Group:    cdicorev1alpha1.SchemeGroupVersion.Group,
+				Version:  cdicorev1alpha1.SchemeGroupVersion.Version,
+				Resource: "datavolumes",
+			},
+			Object: runtime.RawExtension{
+				Raw: dvBytes,
+			},
+		},
+	}
+}
+
+var _ = Describe("DataVolume namespace policy", func() {
+	DescribeTable("should validate the source against the namespace annotations", func(annotations map[string]string, dataVolume *cdicorev1alpha1.DataVolume, allowed bool) {
+		wh := NewDataVolumeValidatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(annotations)))
+		resp := serve(newCreateReview(dataVolume), wh)
+		Expect(resp.Allowed).To(Equal(allowed))
+	},
+		Entry("without annotations", nil, newHTTPDataVolume("testDV", "http://www.example.com"), true),
+		Entry("with an allowed source", map[string]string{controller.AnnAllowedSources: "registry, http"}, newHTTPDataVolume("testDV", "http://www.example.com"), true),
+		Entry("with a source not allowed", map[string]string{controller.AnnAllowedSources: "registry,upload"}, newHTTPDataVolume("testDV", "http://www.example.com"), false),
+		Entry("with an empty list of sources", map[string]string{controller.AnnAllowedSources: ""}, newBlankDataVolume("testDV"), false),
+		Entry("with an allowed host", map[string]string{controller.AnnAllowedSourceHosts: "registry.internal"}, newRegistryDataVolume("testDV", "docker://registry.internal:5000/disk"), true),
+		Entry("with a host not allowed", map[string]string{controller.AnnAllowedSourceHosts: "registry.internal"}, newRegistryDataVolume("testDV", "docker://quay.io/disk"), false),
+		Entry("with a subdomain of an allowed domain", map[string]string{controller.AnnAllowedSourceHosts: "*.corp.example"}, newHTTPDataVolume("testDV", "https://images.corp.example/disk.img"), true),
+		Entry("with a host ending like an allowed domain", map[string]string{controller.AnnAllowedSourceHosts: "*.corp.example"}, newHTTPDataVolume("testDV", "https://evilcorp.example/disk.img"), false),
+		Entry("with a source without a host", map[string]string{controller.AnnAllowedSourceHosts: "registry.internal"}, newBlankDataVolume("testDV"), true),
+	)
+
+	It("should only allow high priority in namespaces that allow it", func() {
+		dataVolume := newHTTPDataVolume("testDV", "http://www.example.com")
+		dataVolume.Spec.Priority = cdicorev1alpha1.DataVolumePriorityHigh
+		wh := NewDataVolumeValidatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(nil)))
+		resp := serve(newCreateReview(dataVolume), wh)
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Field).To(Equal("spec.priority"))
+
+		wh = NewDataVolumeValidatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(map[string]string{controller.AnnAllowHighPriority: "true"})))
+		resp = serve(newCreateReview(dataVolume), wh)
+		Expect(resp.Allowed).To(BeTrue())
+	})
+
+	It("should check the hosts of the backing files", func() {
+		dataVolume := newHTTPDataVolume("testDV", "http://images.internal/disk.qcow2")
+		dataVolume.Spec.Source.HTTP.BackingFileURLs = []string{"http://images.example.com/"}
+		wh := NewDataVolumeValidatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(map[string]string{controller.AnnAllowedSourceHosts: "images.internal"})))
+		resp := serve(newCreateReview(dataVolume), wh)
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Message).To(ContainSubstring("http://images.example.com/"))
+	})
+
+	Context("with the mutating webhook", func() {
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+		It("should set the default storage class of the namespace", func() {
+			wh := NewDataVolumeMutatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(map[string]string{AnnDefaultStorageClass: "tenant-storage"})), cdiclient.NewSimpleClientset(), &keys.SigningKey{Key: key})
+			resp := serve(newCreateReview(newBlankDataVolume("testDV")), wh)
+			Expect(resp.Allowed).To(BeTrue())
+
+			var patchObjs []jsonpatch.Operation
+			Expect(json.Unmarshal(resp.Patch, &patchObjs)).To(Succeed())
+			Expect(patchObjs).To(HaveLen(1))
+			Expect(patchObjs[0].Operation).To(Equal("add"))
+			Expect(patchObjs[0].Path).To(Equal("/spec/pvc/storageClassName"))
+			Expect(patchObjs[0].Value).To(Equal("tenant-storage"))
+		})
+
+		It("should keep the storage class of the DataVolume", func() {
+			dataVolume := newBlankDataVolume("testDV")
+			storageClassName := "other-storage"
+			dataVolume.Spec.PVC.StorageClassName = &storageClassName
+			wh := NewDataVolumeMutatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(map[string]string{AnnDefaultStorageClass: "tenant-storage"})), cdiclient.NewSimpleClientset(), &keys.SigningKey{Key: key})
+			resp := serve(newCreateReview(dataVolume), wh)
+			Expect(resp.Allowed).To(BeTrue())
+			Expect(resp.Patch).To(BeNil())
+		})
+
+		It("should set the fallback storage classes of the namespace", func() {
+			wh := NewDataVolumeMutatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(map[string]string{AnnDefaultFallbackStorageClasses: "zone-b,standard"})), cdiclient.NewSimpleClientset(), &keys.SigningKey{Key: key})
+			resp := serve(newCreateReview(newBlankDataVolume("testDV")), wh)
+			Expect(resp.Allowed).To(BeTrue())
+
+			var patchObjs []jsonpatch.Operation
+			Expect(json.Unmarshal(resp.Patch, &patchObjs)).To(Succeed())
+			Expect(patchObjs).To(HaveLen(1))
+			Expect(patchObjs[0].Operation).To(Equal("add"))
+			Expect(patchObjs[0].Path).To(Equal("/metadata/annotations"))
+			Expect(patchObjs[0].Value).To(HaveKeyWithValue(controller.AnnFallbackStorageClasses, "zone-b,standard"))
+		})
+
+		It("should keep the fallback storage classes of the DataVolume", func() {
+			dataVolume := newBlankDataVolume("testDV")
+			dataVolume.Annotations = map[string]string{controller.AnnFallbackStorageClasses: "other-storage"}
+			wh := NewDataVolumeMutatingWebhook(fakeclient.NewSimpleClientset(newPolicyNamespace(map[string]string{AnnDefaultFallbackStorageClasses: "zone-b,standard"})), cdiclient.NewSimpleClientset(), &keys.SigningKey{Key: key})
+			resp := serve(newCreateReview(dataVolume), wh)
+			Expect(resp.Allowed).To(BeTrue())
+			Expect(resp.Patch).To(BeNil())
+		})
+	})
+})
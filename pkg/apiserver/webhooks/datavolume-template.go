@@ -0,0 +1,226 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	authentication "k8s.io/api/authentication/v1"
+	authorization "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfield "k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
+)
+
+// templateParameterRegexp matches the references to the parameters of a DataVolumeTemplate, such as ${release}
+var templateParameterRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// instantiateTemplate returns the spec of a new data volume in namespace that refers to a DataVolumeTemplate: the spec
+// of the template with the parameters substituted, overridden by the fields spec sets itself. If the data volume can not
+// be instantiated, the causes are returned instead.
+func instantiateTemplate(client kubernetes.Interface, cdiClient cdiclient.Interface, namespace string,
+	spec *cdicorev1alpha1.DataVolumeSpec, userInfo authentication.UserInfo) (*cdicorev1alpha1.DataVolumeSpec, []metav1.StatusCause, error) {
+	field := k8sfield.NewPath("spec", "templateRef")
+	ref := spec.TemplateRef
+	if ref.Name == "" {
+		return nil, templateCauses(field.Child("name"), "Missing DataVolumeTemplate name"), nil
+	}
+	templateNamespace := ref.Namespace
+	if templateNamespace == "" {
+		templateNamespace = namespace
+	}
+
+	if templateNamespace != namespace {
+		ok, reason, err := canUserGetTemplate(client, templateNamespace, ref.Name, userInfo)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, templateCauses(field.Child("namespace"), reason), nil
+		}
+	}
+
+	template, err := cdiClient.CdiV1alpha1().DataVolumeTemplates(templateNamespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, templateCauses(field.Child("name"), fmt.Sprintf("DataVolumeTemplate %s/%s does not exist", templateNamespace, ref.Name)), nil
+		}
+		return nil, nil, errors.Wrapf(err, "could not get DataVolumeTemplate %s/%s", templateNamespace, ref.Name)
+	}
+
+	values, reason := templateParameterValues(template, ref.Parameters)
+	if reason != "" {
+		return nil, templateCauses(field.Child("parameters"), reason), nil
+	}
+
+	var instance map[string]interface{}
+	if err := roundTrip(&template.Spec.DataVolume, &instance); err != nil {
+		return nil, nil, err
+	}
+	substituted, err := substituteParameters(instance, values)
+	if err != nil {
+		return nil, templateCauses(field.Child("name"), fmt.Sprintf("DataVolumeTemplate %s/%s: %v", templateNamespace, ref.Name, err)), nil
+	}
+	instance = substituted.(map[string]interface{})
+
+	var overrides map[string]interface{}
+	if err := roundTrip(spec, &overrides); err != nil {
+		return nil, nil, err
+	}
+	for key, value := range overrides {
+		if object, ok := value.(map[string]interface{}); value == nil || ok && len(object) == 0 {
+			continue
+		}
+		instance[key] = value
+	}
+
+	result := &cdicorev1alpha1.DataVolumeSpec{}
+	if err := roundTrip(instance, result); err != nil {
+		return nil, templateCauses(field.Child("name"), fmt.Sprintf("DataVolumeTemplate %s/%s: %v", templateNamespace, ref.Name, err)), nil
+	}
+	klog.V(3).Infof("Instantiated DataVolumeTemplate %s/%s with parameters %v", templateNamespace, ref.Name, values)
+	return result, nil, nil
+}
+
+// templateParameterValues returns the values of the parameters of template, those of parameters or the defaults, or
+// why parameters do not fit the template
+func templateParameterValues(template *cdicorev1alpha1.DataVolumeTemplate, parameters map[string]string) (map[string]string, string) {
+	values := make(map[string]string)
+	for _, parameter := range template.Spec.Parameters {
+		value, ok := parameters[parameter.Name]
+		if !ok {
+			if parameter.Required {
+				return nil, fmt.Sprintf("Parameter %s of DataVolumeTemplate %s/%s is required", parameter.Name, template.Namespace, template.Name)
+			}
+			value = parameter.Default
+		}
+		values[parameter.Name] = value
+	}
+
+	var unknown []string
+	for name := range parameters {
+		if _, ok := values[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Sprintf("DataVolumeTemplate %s/%s has no parameters %s", template.Namespace, template.Name, strings.Join(unknown, ", "))
+	}
+	return values, ""
+}
+
+// substituteParameters replaces the references to parameters in the strings of the decoded json value with their
+// values, a reference to a parameter without a value is an error
+func substituteParameters(value interface{}, values map[string]string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		result := templateParameterRegexp.ReplaceAllStringFunc(v, func(reference string) string {
+			name := templateParameterRegexp.FindStringSubmatch(reference)[1]
+			parameterValue, ok := values[name]
+			if !ok && err == nil {
+				err = errors.Errorf("undeclared parameter %s", name)
+			}
+			return parameterValue
+		})
+		return result, err
+	case map[string]interface{}:
+		for key, element := range v {
+			substituted, err := substituteParameters(element, values)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = substituted
+		}
+	case []interface{}:
+		for i, element := range v {
+			substituted, err := substituteParameters(element, values)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = substituted
+		}
+	}
+	return value, nil
+}
+
+// roundTrip converts in to out through their json representation
+func roundTrip(in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// canUserGetTemplate checks if the user creating a data volume may get the DataVolumeTemplate it is instantiated from
+func canUserGetTemplate(client kubernetes.Interface, namespace, name string, userInfo authentication.UserInfo) (bool, string, error) {
+	var extra map[string]authorization.ExtraValue
+	if len(userInfo.Extra) > 0 {
+		extra = make(map[string]authorization.ExtraValue)
+		for k, v := range userInfo.Extra {
+			extra[k] = authorization.ExtraValue(v)
+		}
+	}
+
+	sar := &authorization.SubjectAccessReview{
+		Spec: authorization.SubjectAccessReviewSpec{
+			User:   userInfo.Username,
+			Groups: userInfo.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorization.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Group:     cdicorev1alpha1.SchemeGroupVersion.Group,
+				Resource:  "datavolumetemplates",
+				Name:      name,
+			},
+		},
+	}
+	response, err := client.AuthorizationV1().SubjectAccessReviews().Create(sar)
+	if err != nil {
+		return false, "", err
+	}
+	if !response.Status.Allowed {
+		return false, fmt.Sprintf("User %s may not get DataVolumeTemplate %s/%s", userInfo.Username, namespace, name), nil
+	}
+	return true, "", nil
+}
+
+func templateCauses(field *k8sfield.Path, message string) []metav1.StatusCause {
+	return []metav1.StatusCause{
+		{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		},
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package webhooks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/api/admission/v1beta1"
+	authorization "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
+	"kubevirt.io/containerized-data-importer/pkg/keys"
+)
+
+func newUbuntuTemplate(namespace string) *cdicorev1alpha1.DataVolumeTemplate {
+	storageClassName := "fast"
+	return &cdicorev1alpha1.DataVolumeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ubuntu-50gi-fast",
+			Namespace: namespace,
+		},
+		Spec: cdicorev1alpha1.DataVolumeTemplateSpec{
+			Parameters: []cdicorev1alpha1.DataVolumeTemplateParameter{
+				{Name: "release", Required: true},
+				{Name: "mirror", Default: "images.example.com"},
+			},
+			DataVolume: cdicorev1alpha1.DataVolumeSpec{
+				Source: cdicorev1alpha1.DataVolumeSource{
+					HTTP: &cdicorev1alpha1.DataVolumeSourceHTTP{URL: "https://${mirror}/ubuntu-${release}.qcow2"},
+				},
+				PVC: &corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("50Gi")},
+					},
+					StorageClassName: &storageClassName,
+				},
+			},
+		},
+	}
+}
+
+func newTemplateDataVolume(namespace string, parameters map[string]string) *cdicorev1alpha1.DataVolume {
+	return &cdicorev1alpha1.DataVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testDV",
+			Namespace: corev1.NamespaceDefault,
+		},
+		Spec: cdicorev1alpha1.DataVolumeSpec{
+			TemplateRef: &cdicorev1alpha1.DataVolumeTemplateRef{
+				Name:       "ubuntu-50gi-fast",
+				Namespace:  namespace,
+				Parameters: parameters,
+			},
+		},
+	}
+}
+
+// instantiateDV has the mutating webhook instantiate dataVolume from the templates, and returns the response and the
+// patched data volume
+func instantiateDV(dataVolume *cdicorev1alpha1.DataVolume, isAuthorized bool, templates ...runtime.Object) (*v1beta1.AdmissionResponse, *cdicorev1alpha1.DataVolume) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	client := fakeclient.NewSimpleClientset(newPolicyNamespace(nil))
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorization.SubjectAccessReview)
+		Expect(sar.Spec.ResourceAttributes.Resource).To(Equal("datavolumetemplates"))
+		Expect(sar.Spec.ResourceAttributes.Verb).To(Equal("get"))
+		return true, &authorization.SubjectAccessReview{Status: authorization.SubjectAccessReviewStatus{Allowed: isAuthorized}}, nil
+	})
+	wh := NewDataVolumeMutatingWebhook(client, cdiclient.NewSimpleClientset(templates...), &keys.SigningKey{Key: key})
+	review := newCreateReview(dataVolume)
+	resp := serve(review, wh)
+	if !resp.Allowed {
+		return resp, nil
+	}
+
+	patch, err := jsonpatch.DecodePatch(resp.Patch)
+	Expect(err).ToNot(HaveOccurred())
+	patched, err := patch.Apply(review.Request.Object.Raw)
+	Expect(err).ToNot(HaveOccurred())
+	result := &cdicorev1alpha1.DataVolume{}
+	Expect(json.Unmarshal(patched, result)).To(Succeed())
+	return resp, result
+}
+
+var _ = Describe("DataVolume templates", func() {
+	It("should instantiate a DataVolume from a template of its namespace", func() {
+		resp, dataVolume := instantiateDV(newTemplateDataVolume("", map[string]string{"release": "22.04"}), false, newUbuntuTemplate(corev1.NamespaceDefault))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(dataVolume.Spec.Source.HTTP.URL).To(Equal("https://images.example.com/ubuntu-22.04.qcow2"))
+		Expect(dataVolume.Spec.PVC.Resources.Requests[corev1.ResourceStorage]).To(Equal(resource.MustParse("50Gi")))
+		Expect(*dataVolume.Spec.PVC.StorageClassName).To(Equal("fast"))
+		Expect(dataVolume.Spec.TemplateRef.Name).To(Equal("ubuntu-50gi-fast"))
+	})
+
+	It("should instantiate a DataVolume from a template of another namespace the user may get", func() {
+		resp, dataVolume := instantiateDV(newTemplateDataVolume("templates", map[string]string{"release": "22.04", "mirror": "mirror.internal"}), true, newUbuntuTemplate("templates"))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(dataVolume.Spec.Source.HTTP.URL).To(Equal("https://mirror.internal/ubuntu-22.04.qcow2"))
+	})
+
+	It("should reject a template of another namespace the user may not get", func() {
+		resp, _ := instantiateDV(newTemplateDataVolume("templates", map[string]string{"release": "22.04"}), false, newUbuntuTemplate("templates"))
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Field).To(Equal("spec.templateRef.namespace"))
+	})
+
+	It("should let the fields of the DataVolume take precedence", func() {
+		dataVolume := newTemplateDataVolume("", map[string]string{"release": "22.04"})
+		dataVolume.Spec.PVC = newPVCSpec(100*1024*1024*1024, resource.BinarySI)
+		dataVolume.Spec.Priority = cdicorev1alpha1.DataVolumePriorityLow
+		resp, dataVolume := instantiateDV(dataVolume, false, newUbuntuTemplate(corev1.NamespaceDefault))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(dataVolume.Spec.Source.HTTP.URL).To(Equal("https://images.example.com/ubuntu-22.04.qcow2"))
+		size := dataVolume.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).To(Equal("100Gi"))
+		Expect(dataVolume.Spec.PVC.StorageClassName).To(BeNil())
+		Expect(dataVolume.Spec.Priority).To(Equal(cdicorev1alpha1.DataVolumePriorityLow))
+	})
+
+	It("should reject a missing template", func() {
+		resp, _ := instantiateDV(newTemplateDataVolume("", map[string]string{"release": "22.04"}), false)
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Field).To(Equal("spec.templateRef.name"))
+		Expect(resp.Result.Details.Causes[0].Message).To(Equal("DataVolumeTemplate default/ubuntu-50gi-fast does not exist"))
+	})
+
+	It("should reject a DataVolume without a required parameter", func() {
+		resp, _ := instantiateDV(newTemplateDataVolume("", nil), false, newUbuntuTemplate(corev1.NamespaceDefault))
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Field).To(Equal("spec.templateRef.parameters"))
+		Expect(resp.Result.Details.Causes[0].Message).To(Equal("Parameter release of DataVolumeTemplate default/ubuntu-50gi-fast is required"))
+	})
+
+	It("should reject parameters the template does not have", func() {
+		resp, _ := instantiateDV(newTemplateDataVolume("", map[string]string{"release": "22.04", "size": "20Gi"}), false, newUbuntuTemplate(corev1.NamespaceDefault))
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Message).To(Equal("DataVolumeTemplate default/ubuntu-50gi-fast has no parameters size"))
+	})
+
+	It("should reject a template that refers to an undeclared parameter", func() {
+		template := newUbuntuTemplate(corev1.NamespaceDefault)
+		template.Spec.DataVolume.Source.HTTP.SecretRef = "${secret}"
+		resp, _ := instantiateDV(newTemplateDataVolume("", map[string]string{"release": "22.04"}), false, template)
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Details.Causes[0].Message).To(ContainSubstring("undeclared parameter secret"))
+	})
+})
@@ -22,19 +22,35 @@ package webhooks
 import (
 	"encoding/json"
 	"fmt"
+	"mime"
+	"net"
 	"net/url"
+	"path"
 	"reflect"
+	"strings"
+	"time"
 
 	"k8s.io/api/admission/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	k8sfield "k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 
 	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+const (
+	// maxDNSNameservers and maxDNSSearches are the limits of the API server on the DNS config of a pod
+	maxDNSNameservers = 3
+	maxDNSSearches    = 6
 )
 
 type dataVolumeValidatingWebhook struct {
@@ -55,6 +71,70 @@ func validateSourceURL(sourceURL string) string {
 	return ""
 }
 
+// validateRegistryURL checks the url of a registry source points to an image in a registry, or to an image archive
+// served over http(s).
+func validateRegistryURL(sourceURL string) string {
+	if sourceURL == "" {
+		return "source URL is empty"
+	}
+	url, err := url.Parse(sourceURL)
+	if err != nil {
+		return fmt.Sprintf("Invalid source URL: %s", sourceURL)
+	}
+	switch url.Scheme {
+	case "docker":
+		if url.Host == "" {
+			return fmt.Sprintf("Invalid source URL, expected docker://registry/image: %s", sourceURL)
+		}
+		return ""
+	case "http", "https":
+		if url.Host == "" {
+			return fmt.Sprintf("Invalid source URL, expected %s://host/archive: %s", url.Scheme, sourceURL)
+		}
+		return validateSourceURL(sourceURL)
+	}
+	return fmt.Sprintf("Invalid source URL scheme: %s", sourceURL)
+}
+
+func validateSSHURL(sourceURL string) string {
+	if sourceURL == "" {
+		return "source URL is empty"
+	}
+	url, err := url.Parse(sourceURL)
+	if err != nil {
+		return fmt.Sprintf("Invalid source URL: %s", sourceURL)
+	}
+	if url.Scheme != "ssh" {
+		return fmt.Sprintf("Invalid source URL scheme: %s", sourceURL)
+	}
+	if url.Hostname() == "" || strings.HasPrefix(url.Hostname(), "-") || url.Path == "" || url.Path == "/" {
+		return fmt.Sprintf("Invalid source URL, expected ssh://[user@]host[:port]/path: %s", sourceURL)
+	}
+	return ""
+}
+
+func validateBackingFileURLs(backingFileURLs []string, schemes ...string) string {
+	for _, backingFileURL := range backingFileURLs {
+		url, err := url.Parse(backingFileURL)
+		if err != nil || url.Host == "" || strings.Contains(backingFileURL, ",") {
+			return fmt.Sprintf("Invalid backing file URL: %s", backingFileURL)
+		}
+		if url.User != nil {
+			return fmt.Sprintf("Backing file URL must not contain credentials: %s", backingFileURL)
+		}
+		validScheme := false
+		for _, scheme := range schemes {
+			if url.Scheme == scheme {
+				validScheme = true
+			}
+		}
+		if !validScheme {
+			return fmt.Sprintf("Invalid backing file URL scheme, expected one of %s: %s", strings.Join(schemes, ", "), backingFileURL)
+		}
+	}
+	return ""
+}
+
 func validateDataVolumeName(name string) []metav1.StatusCause {
 	var causes []metav1.StatusCause
 	// name of data volume cannot be more than 55 characters (not including '-scratch')
@@ -119,17 +199,196 @@ func (wh *dataVolumeValidatingWebhook) validateDataVolumeSpec(request *v1beta1.A
 		}
 	}
 
-	// Make sure contentType is either empty (kubevirt), or kubevirt or archive
-	if spec.ContentType != "" && string(spec.ContentType) != string(cdicorev1alpha1.DataVolumeKubeVirt) && string(spec.ContentType) != string(cdicorev1alpha1.DataVolumeArchive) {
+	if spec.Source.HTTP != nil {
+		if err := validateBackingFileURLs(spec.Source.HTTP.BackingFileURLs, "http", "https"); err != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s %s", field.Child("source").String(), err),
+				Field:   field.Child("source", "HTTP", "backingFileURLs").String(),
+			})
+			return causes
+		}
+	}
+
+	var checksumValue, checksumField string
+	if spec.Source.HTTP != nil {
+		checksumValue, checksumField = spec.Source.HTTP.Checksum, field.Child("source", "HTTP", "checksum").String()
+	} else if spec.Source.S3 != nil {
+		checksumValue, checksumField = spec.Source.S3.Checksum, field.Child("source", "S3", "checksum").String()
+	}
+	if checksumValue != "" {
+		if _, _, err := checksum.ParseDigest(checksumValue); err != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s %v", field.Child("source").String(), err),
+				Field:   checksumField,
+			})
+			return causes
+		}
+	}
+
+	// Make sure contentType is either empty (kubevirt), or kubevirt, archive, images or kubevirt-state
+	if spec.ContentType != "" && spec.ContentType != cdicorev1alpha1.DataVolumeKubeVirt && spec.ContentType != cdicorev1alpha1.DataVolumeArchive && spec.ContentType != cdicorev1alpha1.DataVolumeImages && spec.ContentType != cdicorev1alpha1.DataVolumeKubeVirtState {
 		sourceType = field.Child("contentType").String()
 		causes = append(causes, metav1.StatusCause{
 			Type:    metav1.CauseTypeFieldValueInvalid,
-			Message: fmt.Sprintf("ContentType not one of: %s, %s", cdicorev1alpha1.DataVolumeKubeVirt, cdicorev1alpha1.DataVolumeArchive),
+			Message: fmt.Sprintf("ContentType not one of: %s, %s, %s, %s", cdicorev1alpha1.DataVolumeKubeVirt, cdicorev1alpha1.DataVolumeArchive, cdicorev1alpha1.DataVolumeImages, cdicorev1alpha1.DataVolumeKubeVirtState),
 			Field:   sourceType,
 		})
 		return causes
 	}
 
+	if imageCauses := validateDataVolumeImages(field, spec); len(imageCauses) > 0 {
+		return append(causes, imageCauses...)
+	}
+
+	if stateCauses := validateDataVolumeState(field, spec); len(stateCauses) > 0 {
+		return append(causes, stateCauses...)
+	}
+
+	if spec.NodeName != "" {
+		if errs := validation.IsDNS1123Subdomain(spec.NodeName); len(errs) > 0 {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("NodeName is not a valid node name: %s", strings.Join(errs, ", ")),
+				Field:   field.Child("nodeName").String(),
+			})
+			return causes
+		}
+		if spec.PVC != nil && spec.PVC.VolumeName != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: "NodeName can not be set on a PVC pre-bound to a persistent volume, the node is derived from the volume",
+				Field:   field.Child("nodeName").String(),
+			})
+			return causes
+		}
+	}
+
+	switch spec.Priority {
+	case "", cdicorev1alpha1.DataVolumePriorityLow, cdicorev1alpha1.DataVolumePriorityNormal, cdicorev1alpha1.DataVolumePriorityHigh:
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("Priority not one of: %s, %s, %s", cdicorev1alpha1.DataVolumePriorityLow, cdicorev1alpha1.DataVolumePriorityNormal, cdicorev1alpha1.DataVolumePriorityHigh),
+			Field:   field.Child("priority").String(),
+		})
+		return causes
+	}
+
+	switch spec.PVCDeletionPolicy {
+	case "", cdicorev1alpha1.DataVolumePVCDeletionPolicyDelete, cdicorev1alpha1.DataVolumePVCDeletionPolicyRetain:
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("PVCDeletionPolicy not one of: %s, %s", cdicorev1alpha1.DataVolumePVCDeletionPolicyDelete, cdicorev1alpha1.DataVolumePVCDeletionPolicyRetain),
+			Field:   field.Child("pvcDeletionPolicy").String(),
+		})
+		return causes
+	}
+
+	switch spec.ImageCheck {
+	case "":
+	case cdicorev1alpha1.DataVolumeImageCheckOnly, cdicorev1alpha1.DataVolumeImageCheckRepairLeaks:
+		// Only the importer converts images, the other transfers copy the data as it is
+		if spec.Source.PVC != nil || spec.Source.Upload != nil || spec.Source.Blank != nil || spec.ContentType == cdicorev1alpha1.DataVolumeArchive {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: "ImageCheck is only supported for kubevirt content imported from an http, s3, registry, imageio, ssh or nutanix source",
+				Field:   field.Child("imageCheck").String(),
+			})
+			return causes
+		}
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("ImageCheck not one of: %s, %s", cdicorev1alpha1.DataVolumeImageCheckOnly, cdicorev1alpha1.DataVolumeImageCheckRepairLeaks),
+			Field:   field.Child("imageCheck").String(),
+		})
+		return causes
+	}
+
+	switch spec.Verification {
+	case "", cdicorev1alpha1.DataVolumeVerificationNone, cdicorev1alpha1.DataVolumeVerificationFast, cdicorev1alpha1.DataVolumeVerificationFull:
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("Verification not one of: %s, %s, %s", cdicorev1alpha1.DataVolumeVerificationNone, cdicorev1alpha1.DataVolumeVerificationFast, cdicorev1alpha1.DataVolumeVerificationFull),
+			Field:   field.Child("verification").String(),
+		})
+		return causes
+	}
+
+	switch spec.BlockWipe {
+	case "":
+	case cdicorev1alpha1.DataVolumeBlockWipeDiscard, cdicorev1alpha1.DataVolumeBlockWipeZero:
+		// Files are created empty, only block devices hold what was written to them before
+		if spec.PVC == nil || spec.PVC.VolumeMode == nil || *spec.PVC.VolumeMode != v1.PersistentVolumeBlock {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: "BlockWipe is only supported for block volumes",
+				Field:   field.Child("blockWipe").String(),
+			})
+			return causes
+		}
+	default:
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("BlockWipe not one of: %s, %s", cdicorev1alpha1.DataVolumeBlockWipeDiscard, cdicorev1alpha1.DataVolumeBlockWipeZero),
+			Field:   field.Child("blockWipe").String(),
+		})
+		return causes
+	}
+
+	if spec.DNS != nil {
+		if spec.Source.Upload != nil || spec.Source.PVC != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: "DNS is only supported for imports",
+				Field:   field.Child("dns").String(),
+			})
+			return causes
+		}
+		if dnsCauses := validateDataVolumeDNS(field.Child("dns"), spec.DNS); len(dnsCauses) > 0 {
+			return append(causes, dnsCauses...)
+		}
+	}
+
+	if spec.BandwidthLimit != nil {
+		if spec.Source.Upload != nil || spec.Source.PVC != nil || spec.Source.Blank != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: "BandwidthLimit is only supported for imports",
+				Field:   field.Child("bandwidthLimit").String(),
+			})
+			return causes
+		}
+		if limitCauses := validateDataVolumeBandwidthLimit(field.Child("bandwidthLimit"), spec.BandwidthLimit); len(limitCauses) > 0 {
+			return append(causes, limitCauses...)
+		}
+	}
+
+	if spec.Encryption != nil {
+		if encryptionCauses := validateDataVolumeEncryption(field.Child("encryption"), spec); len(encryptionCauses) > 0 {
+			return append(causes, encryptionCauses...)
+		}
+	}
+
+	if spec.BlockTarget != nil {
+		if targetCauses := validateDataVolumeBlockTarget(field.Child("blockTarget"), spec); len(targetCauses) > 0 {
+			return append(causes, targetCauses...)
+		}
+	}
+
+	if spec.Deadline != nil && spec.Deadline.Duration <= 0 {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: "Deadline must be positive",
+			Field:   field.Child("deadline").String(),
+		})
+		return causes
+	}
+
 	if spec.Source.Blank != nil && string(spec.ContentType) == string(cdicorev1alpha1.DataVolumeArchive) {
 		sourceType = field.Child("contentType").String()
 		causes = append(causes, metav1.StatusCause{
@@ -150,6 +409,83 @@ func (wh *dataVolumeValidatingWebhook) validateDataVolumeSpec(request *v1beta1.A
 		return causes
 	}
 
+	if spec.Source.Registry != nil {
+		if err := validateRegistryURL(spec.Source.Registry.URL); err != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s %s", field.Child("source").String(), err),
+				Field:   field.Child("source", "Registry", "url").String(),
+			})
+			return causes
+		}
+	}
+
+	if spec.Source.Registry != nil && spec.Source.Registry.DiskPath != "" {
+		if _, err := path.Match(spec.Source.Registry.DiskPath, ""); err != nil {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s is not a valid glob", field.Child("source", "Registry", "diskPath").String()),
+				Field:   field.Child("source", "Registry", "diskPath").String(),
+			})
+			return causes
+		}
+	}
+
+	if spec.Source.Registry != nil && spec.Source.Registry.ArtifactMediaType != "" {
+		if mediaType, _, err := mime.ParseMediaType(spec.Source.Registry.ArtifactMediaType); err != nil || !strings.Contains(mediaType, "/") {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s is not a valid media type", field.Child("source", "Registry", "artifactMediaType").String()),
+				Field:   field.Child("source", "Registry", "artifactMediaType").String(),
+			})
+			return causes
+		}
+	}
+
+	if spec.Source.Registry != nil && strings.HasPrefix(spec.Source.Registry.ArtifactAnnotation, "=") {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: fmt.Sprintf("%s must be in the form key or key=value", field.Child("source", "Registry", "artifactAnnotation").String()),
+			Field:   field.Child("source", "Registry", "artifactAnnotation").String(),
+		})
+		return causes
+	}
+
+	if spec.Source.SSH != nil {
+		if err := validateSSHURL(spec.Source.SSH.URL); err != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s %s", field.Child("source").String(), err),
+				Field:   field.Child("source", "SSH", "url").String(),
+			})
+			return causes
+		}
+		if err := validateBackingFileURLs(spec.Source.SSH.BackingFileURLs, "ssh"); err != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s %s", field.Child("source").String(), err),
+				Field:   field.Child("source", "SSH", "backingFileURLs").String(),
+			})
+			return causes
+		}
+		if spec.Source.SSH.SecretRef == "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s is required to log in to the host", field.Child("source", "SSH", "secretRef").String()),
+				Field:   field.Child("source", "SSH", "secretRef").String(),
+			})
+			return causes
+		}
+		if spec.ContentType != "" && string(spec.ContentType) != string(cdicorev1alpha1.DataVolumeKubeVirt) {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("ContentType must be " + string(cdicorev1alpha1.DataVolumeKubeVirt) + " when Source is SSH"),
+				Field:   field.Child("contentType").String(),
+			})
+			return causes
+		}
+	}
+
 	if spec.Source.Imageio != nil {
 		if spec.Source.Imageio.SecretRef == "" || spec.Source.Imageio.CertConfigMap == "" || spec.Source.Imageio.DiskID == "" {
 			causes = append(causes, metav1.StatusCause{
@@ -161,6 +497,34 @@ func (wh *dataVolumeValidatingWebhook) validateDataVolumeSpec(request *v1beta1.A
 		}
 	}
 
+	if spec.Source.Nutanix != nil {
+		if err := validateSourceURL(spec.Source.Nutanix.URL); err != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s %s", field.Child("source").String(), err),
+				Field:   field.Child("source", "Nutanix", "url").String(),
+			})
+			return causes
+		}
+		imageUUID := spec.Source.Nutanix.ImageUUID
+		if spec.Source.Nutanix.SecretRef == "" || imageUUID == "" || strings.Trim(imageUUID, "0123456789abcdefABCDEF-") != "" {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("%s source Nutanix is not valid, imageUUID must be a uuid and secretRef is required", field.Child("source", "Nutanix").String()),
+				Field:   field.Child("source", "Nutanix").String(),
+			})
+			return causes
+		}
+		if spec.ContentType != "" && string(spec.ContentType) != string(cdicorev1alpha1.DataVolumeKubeVirt) {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("ContentType must be " + string(cdicorev1alpha1.DataVolumeKubeVirt) + " when Source is Nutanix"),
+				Field:   field.Child("contentType").String(),
+			})
+			return causes
+		}
+	}
+
 	if spec.Source.PVC != nil {
 		if spec.Source.PVC.Namespace == "" || spec.Source.PVC.Name == "" {
 			causes = append(causes, metav1.StatusCause{
@@ -212,6 +576,20 @@ func (wh *dataVolumeValidatingWebhook) validateDataVolumeSpec(request *v1beta1.A
 			})
 			return causes
 		}
+		// The size of the image is not known until it is transferred, but no image fits below the minimum
+		volumeMode := "filesystem"
+		if spec.PVC.VolumeMode != nil && *spec.PVC.VolumeMode == v1.PersistentVolumeBlock {
+			volumeMode = "block"
+		}
+		minimum := resource.NewQuantity(util.MinimumTargetSize(0, volumeMode == "filesystem"), resource.BinarySI)
+		if pvcSize.Cmp(*minimum) < 0 {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("PVC size %s is smaller than %s, the minimum size of a %s volume", pvcSize.String(), minimum.String(), volumeMode),
+				Field:   field.Child("PVC", "resources", "requests", "size").String(),
+			})
+			return causes
+		}
 	} else {
 		causes = append(causes, metav1.StatusCause{
 			Type:    metav1.CauseTypeFieldValueInvalid,
@@ -262,6 +640,8 @@ func (wh *dataVolumeValidatingWebhook) Admit(ar v1beta1.AdmissionReview) *v1beta
 			return toAdmissionResponseError(err)
 		}
 
+		// The deletion policy may change until the DataVolume is deleted
+		oldDV.Spec.PVCDeletionPolicy = dv.Spec.PVCDeletionPolicy
 		if !reflect.DeepEqual(dv.Spec, oldDV.Spec) {
 			klog.Errorf("Cannot update spec for DataVolume %s/%s", dv.GetNamespace(), dv.GetName())
 			var causes []metav1.StatusCause
@@ -280,12 +660,28 @@ func (wh *dataVolumeValidatingWebhook) Admit(ar v1beta1.AdmissionReview) *v1beta
 		return toRejectedAdmissionResponse(causes)
 	}
 
+	if ar.Request.Operation == v1beta1.Create {
+		causes = validateFallbackStorageClasses(k8sfield.NewPath("metadata", "annotations"), dv.Annotations)
+		if len(causes) > 0 {
+			klog.Infof("rejected DataVolume admission")
+			return toRejectedAdmissionResponse(causes)
+		}
+	}
+
 	if wh.client != nil && ar.Request.Operation == v1beta1.Create {
 		pvc, err := wh.client.CoreV1().PersistentVolumeClaims(dv.GetNamespace()).Get(dv.GetName(), metav1.GetOptions{})
 		if err != nil && !k8serrors.IsNotFound(err) {
 			return toAdmissionResponseError(err)
 		}
-		if pvc != nil && pvc.Name != "" {
+		if pvc != nil && pvc.Name != "" && dv.Annotations[controller.AnnAdoptPVC] == "true" {
+			if err := controller.CheckPVCAdoptable(pvc); err != nil {
+				return toRejectedAdmissionResponse([]metav1.StatusCause{{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Message: fmt.Sprintf("Destination PVC can not be adopted: %v", err),
+					Field:   k8sfield.NewPath("metadata", "annotations").Key(controller.AnnAdoptPVC).String(),
+				}})
+			}
+		} else if pvc != nil && pvc.Name != "" {
 			klog.Errorf("destination PVC %s/%s already exists", dv.GetNamespace(), dv.GetName())
 			var causes []metav1.StatusCause
 			causes = append(causes, metav1.StatusCause{
@@ -303,7 +699,347 @@ func (wh *dataVolumeValidatingWebhook) Admit(ar v1beta1.AdmissionReview) *v1beta
 		return toRejectedAdmissionResponse(causes)
 	}
 
+	causes = validateFanOut(k8sfield.NewPath("spec", "fanOut"), dv.Name, &dv.Spec)
+	if len(causes) > 0 {
+		klog.Infof("rejected DataVolume admission")
+		return toRejectedAdmissionResponse(causes)
+	}
+
+	if wh.client != nil && ar.Request.Operation == v1beta1.Create && dv.Spec.PVC != nil && dv.Spec.PVC.VolumeName != "" {
+		causes, err = wh.validatePreBoundVolume(k8sfield.NewPath("spec", "pvc", "volumeName"), &dv)
+		if err != nil {
+			return toAdmissionResponseError(err)
+		}
+		if len(causes) > 0 {
+			klog.Infof("rejected DataVolume admission")
+			return toRejectedAdmissionResponse(causes)
+		}
+	}
+
+	if wh.client != nil && ar.Request.Operation == v1beta1.Create {
+		annotations, err := getNamespaceAnnotations(wh.client, dv.GetNamespace())
+		if err != nil {
+			return toAdmissionResponseError(err)
+		}
+		causes = validateNamespacePolicy(annotations, dv.GetNamespace(), k8sfield.NewPath("spec"), &dv.Spec)
+		if len(causes) > 0 {
+			klog.Infof("rejected DataVolume admission")
+			return toRejectedAdmissionResponse(causes)
+		}
+	}
+
 	reviewResponse := v1beta1.AdmissionResponse{}
 	reviewResponse.Allowed = true
 	return &reviewResponse
 }
+
+// validatePreBoundVolume checks the persistent volume the PVC of dv is pre-bound to exists and the PVC can bind to it
+func (wh *dataVolumeValidatingWebhook) validatePreBoundVolume(field *k8sfield.Path, dv *cdicorev1alpha1.DataVolume) ([]metav1.StatusCause, error) {
+	message := ""
+	pv, err := wh.client.CoreV1().PersistentVolumes().Get(dv.Spec.PVC.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		message = fmt.Sprintf("Persistent volume %s doesn't exist", dv.Spec.PVC.VolumeName)
+	} else if err := controller.CheckPreBoundVolume(pv, dv.Namespace, dv.Name, dv.Spec.PVC); err != nil {
+		message = fmt.Sprintf("PVC can not bind to its volume: %v", err)
+	}
+	if message == "" {
+		return nil, nil
+	}
+	return []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldValueInvalid,
+		Message: message,
+		Field:   field.String(),
+	}}, nil
+}
+
+// validateFallbackStorageClasses checks the fallback storage classes in annotations are storage class names and
+// their deadline is a positive duration
+func validateFallbackStorageClasses(field *k8sfield.Path, annotations map[string]string) []metav1.StatusCause {
+	if value, ok := annotations[controller.AnnFallbackStorageClasses]; ok {
+		for _, storageClass := range strings.Split(value, ",") {
+			if errs := validation.IsDNS1123Subdomain(strings.TrimSpace(storageClass)); len(errs) > 0 {
+				return []metav1.StatusCause{{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Message: fmt.Sprintf("Fallback storage class %q is not a valid storage class name: %s", storageClass, strings.Join(errs, ", ")),
+					Field:   field.Key(controller.AnnFallbackStorageClasses).String(),
+				}}
+			}
+		}
+	}
+	if value, ok := annotations[controller.AnnFallbackStorageClassDeadline]; ok {
+		if deadline, err := time.ParseDuration(value); err != nil || deadline <= 0 {
+			return []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldValueInvalid,
+				Message: fmt.Sprintf("Fallback storage class deadline %q is not a positive duration", value),
+				Field:   field.Key(controller.AnnFallbackStorageClassDeadline).String(),
+			}}
+		}
+	}
+	return nil
+}
+
+// validateFanOut checks the fan-out targets of the DataVolume name are distinct DataVolume names other than name, and
+// the DataVolume imports a single disk image, which is what the upload servers of the targets take
+func validateFanOut(field *k8sfield.Path, name string, spec *cdicorev1alpha1.DataVolumeSpec) []metav1.StatusCause {
+	if len(spec.FanOut) == 0 {
+		return nil
+	}
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+	source := spec.Source
+	if source.HTTP == nil && source.S3 == nil && source.Registry == nil && source.Imageio == nil && source.SSH == nil && source.Nutanix == nil {
+		return invalid("FanOut is only supported for imports", field)
+	}
+	if spec.ContentType != "" && spec.ContentType != cdicorev1alpha1.DataVolumeKubeVirt {
+		return invalid("FanOut is only supported for content type kubevirt", field)
+	}
+	if spec.BlockTarget != nil {
+		return invalid("FanOut is not supported with a block target", field)
+	}
+	names := map[string]bool{name: true}
+	for i, target := range spec.FanOut {
+		if errs := validation.IsDNS1123Subdomain(target); len(errs) > 0 {
+			return invalid(fmt.Sprintf("Fan-out target %q is not a valid DataVolume name: %s", target, strings.Join(errs, ", ")), field.Index(i))
+		}
+		if names[target] {
+			return invalid(fmt.Sprintf("Fan-out target %s is the DataVolume itself or listed twice", target), field.Index(i))
+		}
+		names[target] = true
+	}
+	return nil
+}
+
+// validateDataVolumeImages checks the image name and the additional images of spec name files on a file system volume,
+// additional images are only imported with content type images from an http source
+func validateDataVolumeImages(field *k8sfield.Path, spec *cdicorev1alpha1.DataVolumeSpec) []metav1.StatusCause {
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+	blockVolume := spec.PVC != nil && spec.PVC.VolumeMode != nil && *spec.PVC.VolumeMode == v1.PersistentVolumeBlock
+	if spec.ImageName != "" {
+		if errs := validation.IsConfigMapKey(spec.ImageName); len(errs) > 0 {
+			return invalid(fmt.Sprintf("ImageName is not a valid file name: %s", strings.Join(errs, ", ")), field.Child("imageName"))
+		}
+		if spec.Source.PVC != nil || spec.ContentType == cdicorev1alpha1.DataVolumeArchive || blockVolume {
+			return invalid("ImageName is only supported for images imported or uploaded to a file system volume", field.Child("imageName"))
+		}
+	}
+	if spec.ContentType == cdicorev1alpha1.DataVolumeImages && (spec.Source.HTTP == nil || blockVolume) {
+		return invalid("ContentType images is only supported for http sources to a file system volume", field.Child("contentType"))
+	}
+	if spec.Source.HTTP == nil || len(spec.Source.HTTP.AdditionalImages) == 0 {
+		return nil
+	}
+	imagesField := field.Child("source", "HTTP", "additionalImages")
+	if spec.ContentType != cdicorev1alpha1.DataVolumeImages {
+		return invalid("AdditionalImages are only supported with content type images", imagesField)
+	}
+	names := map[string]bool{common.DiskImageName: true}
+	if spec.ImageName != "" {
+		names = map[string]bool{spec.ImageName: true}
+	}
+	for i, namedImage := range spec.Source.HTTP.AdditionalImages {
+		if errs := validation.IsConfigMapKey(namedImage.Name); len(errs) > 0 {
+			return invalid(fmt.Sprintf("Name is not a valid file name: %s", strings.Join(errs, ", ")), imagesField.Index(i).Child("name"))
+		}
+		if names[namedImage.Name] {
+			return invalid(fmt.Sprintf("Name %s is used by another image", namedImage.Name), imagesField.Index(i).Child("name"))
+		}
+		names[namedImage.Name] = true
+		if u, err := url.ParseRequestURI(namedImage.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return invalid(fmt.Sprintf("URL %s is not an http or https URL", namedImage.URL), imagesField.Index(i).Child("url"))
+		}
+	}
+	return nil
+}
+
+// validateDataVolumeState checks a kubevirt-state DataVolume uploads its blob to a file system volume, and that only
+// such a DataVolume has the checksum of an upload, since an uploaded disk image is converted rather than written as
+// it is
+func validateDataVolumeState(field *k8sfield.Path, spec *cdicorev1alpha1.DataVolumeSpec) []metav1.StatusCause {
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+	blockVolume := spec.PVC != nil && spec.PVC.VolumeMode != nil && *spec.PVC.VolumeMode == v1.PersistentVolumeBlock
+	if spec.ContentType == cdicorev1alpha1.DataVolumeKubeVirtState && (spec.Source.Upload == nil || blockVolume) {
+		return invalid("ContentType kubevirt-state is only supported for uploads to a file system volume", field.Child("contentType"))
+	}
+	if spec.Source.Upload == nil || spec.Source.Upload.Checksum == "" {
+		return nil
+	}
+	checksumField := field.Child("source", "Upload", "checksum")
+	if spec.ContentType != cdicorev1alpha1.DataVolumeKubeVirtState {
+		return invalid("Checksum of an upload is only supported with content type kubevirt-state", checksumField)
+	}
+	if _, _, err := checksum.ParseDigest(spec.Source.Upload.Checksum); err != nil {
+		return invalid(fmt.Sprintf("%s %v", field.Child("source").String(), err), checksumField)
+	}
+	return nil
+}
+
+// validateDataVolumeBlockTarget checks the block target of spec is an offset or a partition of the block volume of an
+// import, the importer resolves a partition only once it sees the partition table of the volume
+func validateDataVolumeBlockTarget(field *k8sfield.Path, spec *cdicorev1alpha1.DataVolumeSpec) []metav1.StatusCause {
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+	target := spec.BlockTarget
+	if spec.Source.Upload != nil || spec.Source.PVC != nil || spec.Source.Blank != nil {
+		return invalid("BlockTarget is only supported for imports", field)
+	}
+	if spec.PVC == nil || spec.PVC.VolumeMode == nil || *spec.PVC.VolumeMode != v1.PersistentVolumeBlock {
+		return invalid("BlockTarget is only supported for block volumes", field)
+	}
+	if spec.ContentType == cdicorev1alpha1.DataVolumeArchive {
+		return invalid("BlockTarget is not supported for archives", field)
+	}
+	if spec.BlockWipe != "" {
+		return invalid("BlockTarget cannot be combined with BlockWipe, wiping the volume would remove its partitioning", field)
+	}
+	if target.Offset != 0 && target.Partition != 0 {
+		return invalid("BlockTarget cannot have both an offset and a partition", field)
+	}
+	if target.Offset < 0 || target.Offset%512 != 0 {
+		return invalid("BlockTarget offset must be a non-negative multiple of 512", field.Child("offset"))
+	}
+	if target.Partition < 0 || target.Partition > 128 {
+		return invalid("BlockTarget partition must be between 1 and 128", field.Child("partition"))
+	}
+	if target.Offset == 0 && target.Partition == 0 {
+		return invalid("BlockTarget needs an offset or a partition", field)
+	}
+	return nil
+}
+
+// validateDataVolumeEncryption checks the encryption of spec names Secrets for the images of an import, the importer
+// pod does not start until the Secrets have the passphrase
+func validateDataVolumeEncryption(field *k8sfield.Path, spec *cdicorev1alpha1.DataVolumeSpec) []metav1.StatusCause {
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+	encryption := spec.Encryption
+	if spec.Source.Upload != nil || spec.Source.PVC != nil || spec.Source.Blank != nil {
+		return invalid("Encryption is only supported for imports", field)
+	}
+	if spec.ContentType == cdicorev1alpha1.DataVolumeArchive {
+		return invalid("Encryption is not supported for archives", field)
+	}
+	if encryption.SourceSecretRef == "" && encryption.TargetSecretRef == "" {
+		return invalid("Encryption needs a source or a target secret", field)
+	}
+	if encryption.SourceSecretRef != "" {
+		if errs := validation.IsDNS1123Subdomain(encryption.SourceSecretRef); len(errs) > 0 {
+			return invalid(fmt.Sprintf("SourceSecretRef is not a valid secret name: %s", strings.Join(errs, ", ")), field.Child("sourceSecretRef"))
+		}
+	}
+	if encryption.TargetSecretRef != "" {
+		if errs := validation.IsDNS1123Subdomain(encryption.TargetSecretRef); len(errs) > 0 {
+			return invalid(fmt.Sprintf("TargetSecretRef is not a valid secret name: %s", strings.Join(errs, ", ")), field.Child("targetSecretRef"))
+		}
+	}
+	return nil
+}
+
+// validateDataVolumeDNS checks what the API server would reject in the spec of the importer pod, before the import
+// fails on it
+func validateDataVolumeDNS(field *k8sfield.Path, dns *cdicorev1alpha1.DataVolumeDNS) []metav1.StatusCause {
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+
+	for i, alias := range dns.HostAliases {
+		if net.ParseIP(alias.IP) == nil {
+			return invalid(fmt.Sprintf("Host alias IP %q is not an IP address", alias.IP), field.Child("hostAliases").Index(i).Child("ip"))
+		}
+		if len(alias.Hostnames) == 0 {
+			return invalid("Host alias has no hostnames", field.Child("hostAliases").Index(i).Child("hostnames"))
+		}
+	}
+
+	switch dns.Policy {
+	case "", v1.DNSClusterFirst, v1.DNSDefault:
+	case v1.DNSNone:
+		if dns.Config == nil || len(dns.Config.Nameservers) == 0 {
+			return invalid("DNS policy None needs nameservers in the config", field.Child("config", "nameservers"))
+		}
+	default:
+		return invalid(fmt.Sprintf("DNS policy not one of: %s, %s, %s", v1.DNSClusterFirst, v1.DNSDefault, v1.DNSNone), field.Child("policy"))
+	}
+
+	if dns.Config != nil {
+		if len(dns.Config.Nameservers) > maxDNSNameservers {
+			return invalid(fmt.Sprintf("At most %d nameservers can be configured", maxDNSNameservers), field.Child("config", "nameservers"))
+		}
+		for i, nameserver := range dns.Config.Nameservers {
+			if net.ParseIP(nameserver) == nil {
+				return invalid(fmt.Sprintf("Nameserver %q is not an IP address", nameserver), field.Child("config", "nameservers").Index(i))
+			}
+		}
+		if len(dns.Config.Searches) > maxDNSSearches {
+			return invalid(fmt.Sprintf("At most %d search domains can be configured", maxDNSSearches), field.Child("config", "searches"))
+		}
+	}
+	return nil
+}
+
+// validateDataVolumeBandwidthLimit checks the rates and the windows of the schedule of a bandwidth limit, as the
+// importer ignores windows it can not parse
+func validateDataVolumeBandwidthLimit(field *k8sfield.Path, limit *cdicorev1alpha1.DataVolumeBandwidthLimit) []metav1.StatusCause {
+	invalid := func(message string, field *k8sfield.Path) []metav1.StatusCause {
+		return []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: message,
+			Field:   field.String(),
+		}}
+	}
+
+	if limit.Rate != nil && limit.Rate.Sign() < 0 {
+		return invalid("Rate must not be negative", field.Child("rate"))
+	}
+	for i, window := range limit.Schedule {
+		windowField := field.Child("schedule").Index(i)
+		for j, day := range window.Days {
+			if _, err := util.ParseWeekday(day); err != nil {
+				return invalid(err.Error(), windowField.Child("days").Index(j))
+			}
+		}
+		if _, err := util.ParseTimeOfDay(window.Start); err != nil {
+			return invalid(err.Error(), windowField.Child("start"))
+		}
+		if _, err := util.ParseTimeOfDay(window.End); err != nil {
+			return invalid(err.Error(), windowField.Child("end"))
+		}
+		if window.Rate != nil && window.Rate.Sign() < 0 {
+			return invalid("Rate must not be negative", windowField.Child("rate"))
+		}
+	}
+	return nil
+}
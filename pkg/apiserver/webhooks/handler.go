@@ -20,7 +20,6 @@
 package webhooks
 
 import (
-	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -38,6 +37,7 @@ import (
 	cdicorev1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	cdiclient "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/keys"
 	"kubevirt.io/containerized-data-importer/pkg/token"
 )
 
@@ -56,9 +56,9 @@ func NewDataVolumeValidatingWebhook(client kubernetes.Interface) http.Handler {
 }
 
 // NewDataVolumeMutatingWebhook creates a new DataVolumeMutation webhook
-func NewDataVolumeMutatingWebhook(client kubernetes.Interface, key *rsa.PrivateKey) http.Handler {
+func NewDataVolumeMutatingWebhook(client kubernetes.Interface, cdiClient cdiclient.Interface, key *keys.SigningKey) http.Handler {
 	generator := newCloneTokenGenerator(key)
-	return newAdmissionHandler(&dataVolumeMutatingWebhook{client: client, tokenGenerator: generator})
+	return newAdmissionHandler(&dataVolumeMutatingWebhook{client: client, cdiClient: cdiClient, tokenGenerator: generator})
 }
 
 // NewCDIValidatingWebhook creates a new CDI validating webhook
@@ -66,8 +66,8 @@ func NewCDIValidatingWebhook(client cdiclient.Interface) http.Handler {
 	return newAdmissionHandler(&cdiValidatingWebhook{client: client})
 }
 
-func newCloneTokenGenerator(key *rsa.PrivateKey) token.Generator {
-	return token.NewGenerator(common.CloneTokenIssuer, key, 5*time.Minute)
+func newCloneTokenGenerator(key *keys.SigningKey) token.Generator {
+	return key.Generator(common.CloneTokenIssuer, 5*time.Minute)
 }
 
 func newAdmissionHandler(a Admitter) http.Handler {
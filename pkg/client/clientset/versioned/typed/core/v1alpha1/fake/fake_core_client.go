@@ -40,6 +40,10 @@ func (c *FakeCdiV1alpha1) DataVolumes(namespace string) v1alpha1.DataVolumeInter
 	return &FakeDataVolumes{c, namespace}
 }
 
+func (c *FakeCdiV1alpha1) DataVolumeTemplates(namespace string) v1alpha1.DataVolumeTemplateInterface {
+	return &FakeDataVolumeTemplates{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeCdiV1alpha1) RESTClient() rest.Interface {
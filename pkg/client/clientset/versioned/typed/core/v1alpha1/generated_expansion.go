@@ -23,3 +23,5 @@ type CDIExpansion interface{}
 type CDIConfigExpansion interface{}
 
 type DataVolumeExpansion interface{}
+
+type DataVolumeTemplateExpansion interface{}
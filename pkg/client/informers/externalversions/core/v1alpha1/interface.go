@@ -30,6 +30,8 @@ type Interface interface {
 	CDIConfigs() CDIConfigInformer
 	// DataVolumes returns a DataVolumeInformer.
 	DataVolumes() DataVolumeInformer
+	// DataVolumeTemplates returns a DataVolumeTemplateInformer.
+	DataVolumeTemplates() DataVolumeTemplateInformer
 }
 
 type version struct {
@@ -57,3 +59,8 @@ func (v *version) CDIConfigs() CDIConfigInformer {
 func (v *version) DataVolumes() DataVolumeInformer {
 	return &dataVolumeInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// DataVolumeTemplates returns a DataVolumeTemplateInformer.
+func (v *version) DataVolumeTemplates() DataVolumeTemplateInformer {
+	return &dataVolumeTemplateInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -33,3 +33,11 @@ type DataVolumeListerExpansion interface{}
 // DataVolumeNamespaceListerExpansion allows custom methods to be added to
 // DataVolumeNamespaceLister.
 type DataVolumeNamespaceListerExpansion interface{}
+
+// DataVolumeTemplateListerExpansion allows custom methods to be added to
+// DataVolumeTemplateLister.
+type DataVolumeTemplateListerExpansion interface{}
+
+// DataVolumeTemplateNamespaceListerExpansion allows custom methods to be added to
+// DataVolumeTemplateNamespaceLister.
+type DataVolumeTemplateNamespaceListerExpansion interface{}
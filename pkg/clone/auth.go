@@ -62,8 +62,14 @@ func CanServiceAccountClonePVC(client kubernetes.Interface, pvcNamespace, pvcNam
 
 	user := fmt.Sprintf("system:serviceaccount:%s:%s", saNamespace, saName)
 
+	// the groups the API server puts service accounts in, so that permissions granted to them apply
 	sarSpec := authorization.SubjectAccessReviewSpec{
 		User: user,
+		Groups: []string{
+			"system:serviceaccounts",
+			"system:serviceaccounts:" + saNamespace,
+			"system:authenticated",
+		},
 	}
 
 	return sendSubjectAccessReviews(client, pvcNamespace, pvcName, sarSpec)
@@ -102,6 +108,15 @@ func sendSubjectAccessReviews(client kubernetes.Interface, namespace, name strin
 
 func getResourceAttributes(namespace, name string) []authorization.ResourceAttributes {
 	return []authorization.ResourceAttributes{
+		{
+			Namespace:   namespace,
+			Verb:        cdiv1alpha1.DataVolumeCloneSourceVerb,
+			Group:       cdiv1alpha1.SchemeGroupVersion.Group,
+			Resource:    "datavolumes",
+			Subresource: cdiv1alpha1.DataVolumeCloneSourceSubresource,
+			Name:        name,
+		},
+		// the verb before there was a dedicated one
 		{
 			Namespace:   namespace,
 			Verb:        "create",
@@ -0,0 +1,106 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 Red Hat, Inc.
+ *
+ */
+
+package clone
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	authentication "k8s.io/api/authentication/v1"
+	authorization "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newSARClient returns a client allowing the SubjectAccessReviews allow accepts, and the reviews it received
+func newSARClient(allow func(*authorization.SubjectAccessReviewSpec) bool) (*fakeclient.Clientset, *[]authorization.SubjectAccessReviewSpec) {
+	var reviews []authorization.SubjectAccessReviewSpec
+	client := fakeclient.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorization.SubjectAccessReview)
+		reviews = append(reviews, sar.Spec)
+		sar.Status.Allowed = allow(&sar.Spec)
+		return true, sar, nil
+	})
+	return client, &reviews
+}
+
+var _ = Describe("Clone authorization", func() {
+	It("Should allow cloning within a namespace without asking", func() {
+		client, reviews := newSARClient(func(*authorization.SubjectAccessReviewSpec) bool { return false })
+		allowed, reason, err := CanUserClonePVC(client, "ns", "pvc", "ns", authentication.UserInfo{Username: "joe"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+		Expect(reason).To(BeEmpty())
+		Expect(*reviews).To(BeEmpty())
+	})
+
+	It("Should check the clone verb on the source subresource first", func() {
+		client, reviews := newSARClient(func(spec *authorization.SubjectAccessReviewSpec) bool {
+			return spec.ResourceAttributes.Verb == "clone"
+		})
+		allowed, _, err := CanUserClonePVC(client, "golden", "pvc", "ns", authentication.UserInfo{Username: "joe", Groups: []string{"cloners"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+		Expect(*reviews).To(HaveLen(1))
+		attributes := (*reviews)[0].ResourceAttributes
+		Expect(attributes.Namespace).To(Equal("golden"))
+		Expect(attributes.Resource).To(Equal("datavolumes"))
+		Expect(attributes.Subresource).To(Equal("source"))
+		Expect((*reviews)[0].Groups).To(ConsistOf("cloners"))
+	})
+
+	It("Should still accept the create verb and pod creation", func() {
+		client, reviews := newSARClient(func(spec *authorization.SubjectAccessReviewSpec) bool {
+			return spec.ResourceAttributes.Resource == "pods"
+		})
+		allowed, _, err := CanUserClonePVC(client, "golden", "pvc", "ns", authentication.UserInfo{Username: "joe"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+		Expect(*reviews).To(HaveLen(3))
+		Expect((*reviews)[1].ResourceAttributes.Verb).To(Equal("create"))
+		Expect((*reviews)[1].ResourceAttributes.Subresource).To(Equal("source"))
+	})
+
+	It("Should explain why cloning is not allowed", func() {
+		client, _ := newSARClient(func(*authorization.SubjectAccessReviewSpec) bool { return false })
+		allowed, reason, err := CanUserClonePVC(client, "golden", "pvc", "ns", authentication.UserInfo{Username: "joe"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allowed).To(BeFalse())
+		Expect(reason).To(Equal("User joe has insufficient permissions in clone source namespace golden"))
+	})
+
+	It("Should check the groups of a service account", func() {
+		client, reviews := newSARClient(func(spec *authorization.SubjectAccessReviewSpec) bool {
+			for _, group := range spec.Groups {
+				if group == "system:serviceaccounts:ns" {
+					return true
+				}
+			}
+			return false
+		})
+		allowed, _, err := CanServiceAccountClonePVC(client, "golden", "pvc", "ns", "cloner")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+		Expect((*reviews)[0].User).To(Equal("system:serviceaccount:ns:cloner"))
+		Expect((*reviews)[0].Groups).To(ConsistOf("system:serviceaccounts", "system:serviceaccounts:ns", "system:authenticated"))
+	})
+})
@@ -35,6 +35,10 @@ const (
 	PodTerminationMessageFile = "/dev/termination-log"
 	// ImporterPodName provides a constant to use as a prefix for Pods created by CDI (controller only)
 	ImporterPodName = "importer"
+	// ExporterPodName provides a constant to use as a prefix for export Pods created by CDI (controller only)
+	ExporterPodName = "exporter"
+	// ScratchScrubPodName provides a constant to use as a prefix for the pods scrubbing the volumes of the scratch space pool (controller only)
+	ScratchScrubPodName = "cdi-scratch-scrub"
 	// ImporterDataDir provides a constant for the controller pkg to use as a hardcoded path to where content is transferred to/from (controller only)
 	ImporterDataDir = "/data"
 	// ScratchDataDir provides a constant for the controller pkg to use as a hardcoded path to where scratch space is located.
@@ -43,6 +47,18 @@ const (
 	ImporterS3Host = "s3.amazonaws.com"
 	// ImporterCertDir is where the configmap containing certs will be mounted
 	ImporterCertDir = "/certs"
+	// ImporterPullSecretDir is where the registry pull secrets will be mounted
+	ImporterPullSecretDir = "/pull-secrets"
+	// ImporterSSHKeyDir is where the secret containing the ssh key will be mounted
+	ImporterSSHKeyDir = "/ssh-key"
+	// ImporterSourceKeyDir is where the secret with the passphrase of an encrypted source image will be mounted
+	ImporterSourceKeyDir = "/source-key"
+	// ImporterTargetKeyDir is where the secret with the passphrase the imported image is encrypted with will be mounted
+	ImporterTargetKeyDir = "/target-key"
+	// EncryptionPassphraseKey is the key of the passphrase in the secrets of encrypted images
+	EncryptionPassphraseKey = "passphrase"
+	// TransferTmpDir is where transfer pods, whose root filesystem is read-only, write their temporary files
+	TransferTmpDir = "/tmp"
 	// DefaultPullPolicy imports k8s "IfNotPresent" string for the import_controller_gingko_test and the cdi-controller executable
 	DefaultPullPolicy = string(v1.PullIfNotPresent)
 
@@ -66,6 +82,73 @@ const (
 	InsecureTLSVar = "INSECURE_TLS"
 	// ImporterDiskID provides a constant to capture our env variable "IMPORTER_DISK_ID"
 	ImporterDiskID = "IMPORTER_DISK_ID"
+	// ImporterArchitecture provides a constant to capture our env variable "IMPORTER_ARCHITECTURE"
+	ImporterArchitecture = "IMPORTER_ARCHITECTURE"
+	// ImporterArtifactMediaType provides a constant to capture our env variable "IMPORTER_ARTIFACT_MEDIA_TYPE"
+	ImporterArtifactMediaType = "IMPORTER_ARTIFACT_MEDIA_TYPE"
+	// ImporterArtifactAnnotation provides a constant to capture our env variable "IMPORTER_ARTIFACT_ANNOTATION"
+	ImporterArtifactAnnotation = "IMPORTER_ARTIFACT_ANNOTATION"
+	// ImporterDiskPath provides a constant to capture our env variable "IMPORTER_DISK_PATH"
+	ImporterDiskPath = "IMPORTER_DISK_PATH"
+	// ImporterRegistryMirrors provides a constant to capture our env variable "IMPORTER_REGISTRY_MIRRORS"
+	ImporterRegistryMirrors = "IMPORTER_REGISTRY_MIRRORS"
+	// ImporterInsecureRegistryMirrors provides a constant to capture our env variable "IMPORTER_INSECURE_REGISTRY_MIRRORS"
+	ImporterInsecureRegistryMirrors = "IMPORTER_INSECURE_REGISTRY_MIRRORS"
+	// ImporterBackingFileURLs provides a constant to capture our env variable "IMPORTER_BACKING_FILE_URLS"
+	ImporterBackingFileURLs = "IMPORTER_BACKING_FILE_URLS"
+	// ImporterChecksum provides a constant to capture our env variable "IMPORTER_CHECKSUM"
+	ImporterChecksum = "IMPORTER_CHECKSUM"
+	// ImporterImageCheck provides a constant to capture our env variable "IMPORTER_IMAGE_CHECK"
+	ImporterImageCheck = "IMPORTER_IMAGE_CHECK"
+	// ImporterVerification provides a constant to capture our env variable "IMPORTER_VERIFICATION"
+	ImporterVerification = "IMPORTER_VERIFICATION"
+	// ImporterBlockWipe provides a constant to capture our env variable "IMPORTER_BLOCK_WIPE"
+	ImporterBlockWipe = "IMPORTER_BLOCK_WIPE"
+	// ImporterBlockTargetOffset provides a constant to capture our env variable "IMPORTER_BLOCK_TARGET_OFFSET"
+	ImporterBlockTargetOffset = "IMPORTER_BLOCK_TARGET_OFFSET"
+	// ImporterBlockTargetPartition provides a constant to capture our env variable "IMPORTER_BLOCK_TARGET_PARTITION"
+	ImporterBlockTargetPartition = "IMPORTER_BLOCK_TARGET_PARTITION"
+	// ImporterImageName provides a constant to capture our env variable "IMPORTER_IMAGE_NAME"
+	ImporterImageName = "IMPORTER_IMAGE_NAME"
+	// ImporterAdditionalImages provides a constant to capture our env variable "IMPORTER_ADDITIONAL_IMAGES"
+	ImporterAdditionalImages = "IMPORTER_ADDITIONAL_IMAGES"
+	// ImporterNutanixImageUUID provides a constant to capture our env variable "IMPORTER_NUTANIX_IMAGE_UUID"
+	ImporterNutanixImageUUID = "IMPORTER_NUTANIX_IMAGE_UUID"
+	// ImporterBandwidthLimit provides a constant to capture our env variable "IMPORTER_BANDWIDTH_LIMIT", the JSON of
+	// the DataVolumeBandwidthLimit the importer reads its source with
+	ImporterBandwidthLimit = "IMPORTER_BANDWIDTH_LIMIT"
+	// UploadProxyVirtualHostSecrets provides a constant to capture our env variable "VIRTUAL_HOST_SECRETS", the
+	// comma separated names of the TLS Secrets with the serving certificates of additional upload proxy hostnames
+	UploadProxyVirtualHostSecrets = "VIRTUAL_HOST_SECRETS"
+	// UploadProxyTrustedProxies provides a constant to capture our env variable "TRUSTED_PROXIES", the comma separated
+	// CIDRs of the load balancers whose PROXY protocol and X-Forwarded-* headers the upload proxy trusts
+	UploadProxyTrustedProxies = "TRUSTED_PROXIES"
+	// TokenSigningAlgorithm provides a constant to capture our env variable "TOKEN_SIGNING_ALGORITHM", the algorithm
+	// the apiserver signs the upload and clone tokens with
+	TokenSigningAlgorithm = "TOKEN_SIGNING_ALGORITHM"
+	// ImporterSSHInsecureSkipHostKeyCheck provides a constant to capture our env variable "IMPORTER_SSH_INSECURE_SKIP_HOST_KEY_CHECK"
+	ImporterSSHInsecureSkipHostKeyCheck = "IMPORTER_SSH_INSECURE_SKIP_HOST_KEY_CHECK"
+	// ImporterFanOutURLs provides a constant to capture our env variable "IMPORTER_FANOUT_URLS", the comma separated
+	// upload server URLs the imported image is streamed to as well
+	ImporterFanOutURLs = "IMPORTER_FANOUT_URLS"
+	// ImporterFanOutClientCert provides a constant to capture our env variable "IMPORTER_FANOUT_CLIENT_CERT", the PEM
+	// encoded client certificates for the upload servers, in the order of their URLs
+	ImporterFanOutClientCert = "IMPORTER_FANOUT_CLIENT_CERT"
+	// ImporterFanOutClientKey provides a constant to capture our env variable "IMPORTER_FANOUT_CLIENT_KEY", the PEM
+	// encoded keys of the client certificates
+	ImporterFanOutClientKey = "IMPORTER_FANOUT_CLIENT_KEY"
+	// ImporterFanOutServerCACert provides a constant to capture our env variable "IMPORTER_FANOUT_SERVER_CA_CERT"
+	ImporterFanOutServerCACert = "IMPORTER_FANOUT_SERVER_CA_CERT"
+	// ImporterUserAgent provides a constant to capture our env variable "IMPORTER_USER_AGENT", the product the importer
+	// names in the User-Agent of its requests
+	ImporterUserAgent = "IMPORTER_USER_AGENT"
+	// ImporterClusterID provides a constant to capture our env variable "IMPORTER_CLUSTER_ID"
+	ImporterClusterID = "IMPORTER_CLUSTER_ID"
+	// ExporterDestination provides a constant to capture our env variable "EXPORTER_DESTINATION"
+	ExporterDestination = "EXPORTER_DESTINATION"
+	// ImporterScrubScratch provides a constant to capture our env variable "IMPORTER_SCRUB_SCRATCH", the importer only
+	// scrubs the scratch space when it is set
+	ImporterScrubScratch = "IMPORTER_SCRUB_SCRATCH"
 
 	// CloningLabelValue provides a constant to use as a label value for pod affinity (controller pkg only)
 	CloningLabelValue = "host-assisted-cloning"
@@ -77,6 +160,8 @@ const (
 	ClonerMountPath = "/var/run/cdi/clone/source"
 	// ClonerSourcePodNameSuffix (controller pkg only)
 	ClonerSourcePodNameSuffix = "-source-pod"
+	// CloneEstimatePodName is the component label and container name of the pods that estimate clones
+	CloneEstimatePodName = "cdi-clone-estimate"
 
 	// KubeVirtAnnKey is part of a kubevirt.io key.
 	KubeVirtAnnKey = "kubevirt.io/"
@@ -86,6 +171,11 @@ const (
 	// SmartClonerCDILabel is the label applied to resources created by the smart-clone controller
 	SmartClonerCDILabel = "cdi-smart-clone"
 
+	// TransferLowPriorityClass is the PriorityClass of the transfer pods of low priority DataVolumes
+	TransferLowPriorityClass = "cdi-transfer-low"
+	// TransferHighPriorityClass is the PriorityClass of the transfer pods of high priority DataVolumes
+	TransferHighPriorityClass = "cdi-transfer-high"
+
 	// UploadServerCDILabel is the label applied to upload server resources
 	UploadServerCDILabel = "cdi-upload-server"
 	// UploadServerPodname is name of the upload server pod container
@@ -96,12 +186,46 @@ const (
 	UploadServerServiceLabel = "service"
 	// UploadImageSize provides a constant to capture our env variable "UPLOAD_IMAGE_SIZE"
 	UploadImageSize = "UPLOAD_IMAGE_SIZE"
+	// UploadVerification provides a constant to capture our env variable "UPLOAD_VERIFICATION"
+	UploadVerification = "UPLOAD_VERIFICATION"
+	// UploadBlockWipe provides a constant to capture our env variable "UPLOAD_BLOCK_WIPE"
+	UploadBlockWipe = "UPLOAD_BLOCK_WIPE"
+	// UploadContentType provides a constant to capture our env variable "UPLOAD_CONTENT_TYPE"
+	UploadContentType = "UPLOAD_CONTENT_TYPE"
+	// UploadChecksum provides a constant to capture our env variable "UPLOAD_CHECKSUM"
+	UploadChecksum = "UPLOAD_CHECKSUM"
+	// UploadReadTimeout provides a constant to capture our env variable "UPLOAD_READ_TIMEOUT"
+	UploadReadTimeout = "UPLOAD_READ_TIMEOUT"
+	// UploadWriteTimeout provides a constant to capture our env variable "UPLOAD_WRITE_TIMEOUT"
+	UploadWriteTimeout = "UPLOAD_WRITE_TIMEOUT"
+	// UploadIdleTimeout provides a constant to capture our env variable "UPLOAD_IDLE_TIMEOUT"
+	UploadIdleTimeout = "UPLOAD_IDLE_TIMEOUT"
+	// UploadKeepAlivePeriod provides a constant to capture our env variable "UPLOAD_KEEP_ALIVE_PERIOD"
+	UploadKeepAlivePeriod = "UPLOAD_KEEP_ALIVE_PERIOD"
+	// UploadContentEncodings provides a constant to capture our env variable "UPLOAD_CONTENT_ENCODINGS"
+	UploadContentEncodings = "UPLOAD_CONTENT_ENCODINGS"
+	// UploadMaxConcurrentRequests provides a const for the env variable with the number of requests with upload data an upload server takes at once
+	UploadMaxConcurrentRequests = "UPLOAD_MAX_CONCURRENT_REQUESTS"
 
 	// ConfigName is the name of default CDI Config
 	ConfigName = "config"
+	// OperationHistoryName is the name of the OperationHistory of a namespace
+	OperationHistoryName = "operations"
+	// OperationHistoryLength is the number of operations an OperationHistory keeps
+	OperationHistoryLength = 50
 
 	// OwnerUID provides the UID of the owner entity (either PVC or DV)
 	OwnerUID = "OWNER_UID"
+	// CloneVerifyContent provides a constant to capture our env variable "VERIFY_CONTENT", the clone source sends the checksums of the cloned data if it is "true"
+	CloneVerifyContent = "VERIFY_CONTENT"
+	// CloneCompression provides a constant to capture our env variable "CLONE_COMPRESSION", the Content-Encoding the clone source prefers for its stream
+	CloneCompression = "CLONE_COMPRESSION"
+	// CloneCompressionLevel provides a constant to capture our env variable "CLONE_COMPRESSION_LEVEL"
+	CloneCompressionLevel = "CLONE_COMPRESSION_LEVEL"
+	// CloneDelta provides a constant to capture our env variable "CLONE_DELTA", the clone source sends only the blocks that differ from the destination if it is "true"
+	CloneDelta = "CLONE_DELTA"
+	// CloneTargetVolumeMode provides a constant to capture our env variable "TARGET_VOLUME_MODE", the volume mode of the clone target when it differs from the one of the source
+	CloneTargetVolumeMode = "TARGET_VOLUME_MODE"
 
 	// KeyAccess provides a constant to the accessKeyId label using in controller pkg and transport_test.go
 	KeyAccess = "accessKeyId"
@@ -112,9 +236,23 @@ const (
 	DefaultResyncPeriod = 10 * time.Minute
 	// InsecureRegistryConfigMap is the name of the ConfigMap for insecure registries
 	InsecureRegistryConfigMap = "cdi-insecure-registries"
+	// RegistryMirrorConfigMap is the name of the ConfigMap for registry mirrors
+	RegistryMirrorConfigMap = "cdi-registry-mirrors"
 
 	// ScratchSpaceNeededExitCode is the exit code that indicates the importer pod requires scratch space to function properly.
 	ScratchSpaceNeededExitCode = 42
+	// ScratchSpaceExhaustedExitCode is the exit code that indicates the importer pod ran out of scratch space.
+	ScratchSpaceExhaustedExitCode = 43
+	// ScratchSpaceExhaustedMessage is the termination message of an importer pod that ran out of scratch space, with the bytes required and available.
+	ScratchSpaceExhaustedMessage = "Insufficient scratch space: %d bytes required, %d bytes available"
+	// DestinationNotWritableExitCode is the exit code that indicates the upload server pod cannot write to its destination.
+	DestinationNotWritableExitCode = 44
+	// PermanentFailureExitCode is the exit code that indicates the importer pod failed for a reason retrying does not resolve.
+	PermanentFailureExitCode = 45
+	// PermanentFailureMessage is the permanent failure annotation of a PVC whose importer pod failed permanently, with the reason and the error.
+	PermanentFailureMessage = "%s: %s"
+	// CloneVerificationFailedExitCode is the exit code that indicates the data an upload server wrote does not match the checksums of the clone source.
+	CloneVerificationFailedExitCode = 46
 
 	// UploadTokenIssuer is the JWT issuer of upload tokens
 	UploadTokenIssuer = "cdi-apiserver"
@@ -128,6 +266,54 @@ const (
 	// UploadPathAsync is the path to POST CDI uploads in async mode
 	UploadPathAsync = "/v1alpha1/upload-async"
 
+	// UploadFormPathSync is the path to POST CDI uploads as multipart/form-data, as browsers send forms
+	UploadFormPathSync = "/v1alpha1/upload-form"
+
+	// UploadFormPathAsync is the path to POST CDI uploads as multipart/form-data in async mode
+	UploadFormPathAsync = "/v1alpha1/upload-form-async"
+
+	// UploadPathChunked is the path clone sources POST the chunks of a clone to, each with its own checksum
+	UploadPathChunked = "/v1alpha1/upload-chunked"
+
+	// UploadPathBlockHashes is the path clone sources GET the checksums of the blocks of the destination of a delta clone from
+	UploadPathBlockHashes = "/v1alpha1/upload-block-hashes"
+
+	// UploadPathDelta is the path clone sources POST the blocks of a delta clone that differ from the destination to
+	UploadPathDelta = "/v1alpha1/upload-delta"
+
+	// UploadPathReady is the path the upload server answers with 200 once it can write to its destination and accept an upload
+	UploadPathReady = "/v1alpha1/ready"
+
+	// UploadPathSession is the path to POST the chunks of an upload session to, and to HEAD for its offset
+	UploadPathSession = "/v1alpha1/upload-session"
+
+	// UploadPathStatus is the path to GET the status of an async upload from
+	UploadPathStatus = "/v1alpha1/upload-status"
+
+	// UploadOperationHeader is the header of the response to an async upload with the ID of the upload
+	UploadOperationHeader = "Upload-Operation"
+
+	// UploadOperationParam is the query parameter of the upload status path with the ID of the upload
+	UploadOperationParam = "operation"
+
+	// UploadCallbackHeader is the header of an async upload with the URL the status of the upload is POSTed to once
+	// it is done or failed
+	UploadCallbackHeader = "Upload-Callback"
+
+	// UploadSessionHeader is the header the upload proxy tells the upload server the upload session of a chunk with
+	UploadSessionHeader = "x-cdi-upload-session"
+
+	// UploadOffsetHeader is the header with the offset of a chunk of an upload session, and with the offset up to which
+	// the session was written in a response
+	UploadOffsetHeader = "Upload-Offset"
+
+	// UploadCompleteHeader is the header that marks the last chunk of an upload session, and tells whether the session
+	// is complete in a response
+	UploadCompleteHeader = "Upload-Complete"
+
+	// UploadDigestHeader is the header with the sha256 digest of the data of an upload session written so far
+	UploadDigestHeader = "Upload-Digest"
+
 	// QemuSubGid is the gid used as the qemu group in fsGroup
 	QemuSubGid = int64(107)
 )
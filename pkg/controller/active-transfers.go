@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// AnnActiveTransfers is a node annotation with the number of transfer pods running on the node
+	AnnActiveTransfers = AnnAPIGroup + "/activeTransfers"
+	// AnnScaleDownDisabled keeps the cluster autoscaler from scaling down a node when set to "true"
+	AnnScaleDownDisabled = "cluster-autoscaler.kubernetes.io/scale-down-disabled"
+	// AnnScaleDownDisabledByCDI is a node annotation that tells AnnScaleDownDisabled was set by CDI, and is removed
+	// once the transfers on the node are done
+	AnnScaleDownDisabledByCDI = AnnAPIGroup + "/scaleDownDisabled"
+
+	// activeTransferInterval is how often the active transfers are counted
+	activeTransferInterval = 30 * time.Second
+)
+
+var (
+	activeTransfers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cdi_transfers_active",
+			Help: "The number of importer, upload server and clone source pods scheduled to a node",
+		},
+		[]string{"node", "component"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(activeTransfers)
+}
+
+// getAnnotateTransferNodes returns whether the CDIConfig asks for the nodes running transfer pods to be annotated
+func getAnnotateTransferNodes(c client.Client) (bool, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return cdiconfig.Spec.AnnotateTransferNodes, nil
+}
+
+// ActiveTransferTracker periodically counts the transfer pods scheduled to each node, for the cdi_transfers_active
+// metric. If the CDIConfig asks for it, it also annotates the nodes with their count, and keeps the cluster
+// autoscaler from scaling down a node while transfers run on it, so multi-hour transfers are not restarted from
+// scratch on another node.
+type ActiveTransferTracker struct {
+	Client client.Client
+	Log    logr.Logger
+}
+
+// NewActiveTransferTracker creates a new active transfer tracker and adds it to the manager.
+func NewActiveTransferTracker(mgr manager.Manager, log logr.Logger) (*ActiveTransferTracker, error) {
+	tracker := &ActiveTransferTracker{
+		Client: mgr.GetClient(),
+		Log:    log.WithName("active-transfer-tracker"),
+	}
+	if err := mgr.Add(tracker); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
+
+// Start runs the tracker, right away and then every activeTransferInterval, until stop is closed.
+func (t *ActiveTransferTracker) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := t.track(); err != nil {
+			t.Log.Error(err, "Unable to track the active transfers")
+		}
+	}, activeTransferInterval, stop)
+	return nil
+}
+
+// track counts the transfer pods of each node, and annotates the nodes
+func (t *ActiveTransferTracker) track() error {
+	pods := &corev1.PodList{}
+	selector := labels.SelectorFromSet(map[string]string{common.CDILabelKey: common.CDILabelValue})
+	if err := t.Client.List(context.TODO(), pods, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return err
+	}
+	counts := make(map[string]map[string]int)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isTransferPod(pod) || pod.Spec.NodeName == "" {
+			continue
+		}
+		if counts[pod.Spec.NodeName] == nil {
+			counts[pod.Spec.NodeName] = make(map[string]int)
+		}
+		counts[pod.Spec.NodeName][pod.Labels[common.CDIComponentLabel]]++
+	}
+	activeTransfers.Reset()
+	for node, components := range counts {
+		for component, count := range components {
+			activeTransfers.WithLabelValues(node, component).Set(float64(count))
+		}
+	}
+
+	annotate, err := getAnnotateTransferNodes(t.Client)
+	if err != nil {
+		return err
+	}
+	nodes := &corev1.NodeList{}
+	if err := t.Client.List(context.TODO(), nodes); err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		count := 0
+		if annotate {
+			for _, n := range counts[nodes.Items[i].Name] {
+				count += n
+			}
+		}
+		if err := t.annotateNode(&nodes.Items[i], count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotateNode records count transfer pods in the annotations of node. Without transfers it removes the annotations
+// it set before, AnnScaleDownDisabled only if it was set by CDI.
+func (t *ActiveTransferTracker) annotateNode(node *corev1.Node, count int) error {
+	anno := node.GetAnnotations()
+	if count == 0 {
+		_, annotated := anno[AnnActiveTransfers]
+		_, disabled := anno[AnnScaleDownDisabledByCDI]
+		if !annotated && !disabled {
+			return nil
+		}
+		delete(anno, AnnActiveTransfers)
+		if disabled {
+			delete(anno, AnnScaleDownDisabled)
+			delete(anno, AnnScaleDownDisabledByCDI)
+		}
+	} else {
+		value := strconv.Itoa(count)
+		if anno[AnnActiveTransfers] == value && anno[AnnScaleDownDisabled] == "true" {
+			return nil
+		}
+		if anno == nil {
+			anno = make(map[string]string)
+			node.SetAnnotations(anno)
+		}
+		anno[AnnActiveTransfers] = value
+		if anno[AnnScaleDownDisabled] != "true" {
+			anno[AnnScaleDownDisabled] = "true"
+			anno[AnnScaleDownDisabledByCDI] = "true"
+		}
+	}
+	t.Log.V(1).Info("Annotating node with its active transfers", "node", node.Name, "transfers", count)
+	return t.Client.Update(context.TODO(), node)
+}
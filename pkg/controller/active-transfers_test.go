@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var activeTransferLog = logf.Log.WithName("active-transfer-tracker-test")
+
+var _ = Describe("Active transfer tracker", func() {
+	createTransferPod := func(name, component, nodeName string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					common.CDILabelKey:       common.CDILabelValue,
+					common.CDIComponentLabel: component,
+				},
+			},
+			Spec:   corev1.PodSpec{NodeName: nodeName},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	getNode := func(t *ActiveTransferTracker, name string) *corev1.Node {
+		node := &corev1.Node{}
+		Expect(t.Client.Get(context.TODO(), types.NamespacedName{Name: name}, node)).To(Succeed())
+		return node
+	}
+
+	It("Should count the transfer pods of each node", func() {
+		t := createActiveTransferTracker(false,
+			createNode("node01", true),
+			createTransferPod("importer-a", common.ImporterPodName, "node01", corev1.PodRunning),
+			createTransferPod("importer-b", common.ImporterPodName, "node01", corev1.PodPending),
+			createTransferPod("cdi-upload-c", common.UploadServerCDILabel, "node01", corev1.PodRunning),
+			createTransferPod("importer-d", common.ImporterPodName, "node01", corev1.PodSucceeded),
+			createTransferPod("importer-e", common.ImporterPodName, "", corev1.PodPending),
+		)
+		Expect(t.track()).To(Succeed())
+		Expect(readGauge(activeTransfers.WithLabelValues("node01", common.ImporterPodName))).To(Equal(float64(2)))
+		Expect(readGauge(activeTransfers.WithLabelValues("node01", common.UploadServerCDILabel))).To(Equal(float64(1)))
+		Expect(getNode(t, "node01").GetAnnotations()).ToNot(HaveKey(AnnActiveTransfers))
+	})
+
+	It("Should keep the autoscaler from scaling down a node while transfers run on it", func() {
+		pod := createTransferPod("importer-a", common.ImporterPodName, "node01", corev1.PodRunning)
+		t := createActiveTransferTracker(true, createNode("node01", true), createNode("node02", true), pod)
+		Expect(t.track()).To(Succeed())
+		node := getNode(t, "node01")
+		Expect(node.GetAnnotations()[AnnActiveTransfers]).To(Equal("1"))
+		Expect(node.GetAnnotations()[AnnScaleDownDisabled]).To(Equal("true"))
+		Expect(node.GetAnnotations()).To(HaveKey(AnnScaleDownDisabledByCDI))
+		Expect(getNode(t, "node02").GetAnnotations()).ToNot(HaveKey(AnnScaleDownDisabled))
+
+		By("Removing the annotations once the transfer is done")
+		pod.Status.Phase = corev1.PodSucceeded
+		Expect(t.Client.Update(context.TODO(), pod)).To(Succeed())
+		Expect(t.track()).To(Succeed())
+		node = getNode(t, "node01")
+		Expect(node.GetAnnotations()).ToNot(HaveKey(AnnActiveTransfers))
+		Expect(node.GetAnnotations()).ToNot(HaveKey(AnnScaleDownDisabled))
+		Expect(node.GetAnnotations()).ToNot(HaveKey(AnnScaleDownDisabledByCDI))
+	})
+
+	It("Should keep the scale down setting of the admin", func() {
+		node := createNode("node01", true)
+		node.Annotations = map[string]string{AnnScaleDownDisabled: "true"}
+		pod := createTransferPod("importer-a", common.ImporterPodName, "node01", corev1.PodRunning)
+		t := createActiveTransferTracker(true, node, pod)
+		Expect(t.track()).To(Succeed())
+		Expect(getNode(t, "node01").GetAnnotations()).ToNot(HaveKey(AnnScaleDownDisabledByCDI))
+
+		Expect(t.Client.Delete(context.TODO(), pod)).To(Succeed())
+		Expect(t.track()).To(Succeed())
+		node = getNode(t, "node01")
+		Expect(node.GetAnnotations()).ToNot(HaveKey(AnnActiveTransfers))
+		Expect(node.GetAnnotations()[AnnScaleDownDisabled]).To(Equal("true"))
+	})
+})
+
+func createActiveTransferTracker(annotate bool, objects ...runtime.Object) *ActiveTransferTracker {
+	cdiv1.AddToScheme(scheme.Scheme)
+	config := createCDIConfig(common.ConfigName)
+	config.Spec.AnnotateTransferNodes = annotate
+	objs := append([]runtime.Object{config}, objects...)
+	return &ActiveTransferTracker{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+		Log:    activeTransferLog,
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// AdoptedPVC provides a const to indicate a DataVolume adopted an existing PVC
+	AdoptedPVC = "AdoptedPVC"
+	// MessageAdoptedPVC provides a const to form the message of an adopted PVC
+	MessageAdoptedPVC = "Adopted existing PVC %s, populating it in place"
+)
+
+// populationAnnotations are the PVC annotations of the transfers of CDI, a PVC with one of them was already
+// populated or is being populated
+var populationAnnotations = []string{
+	AnnPodPhase,
+	AnnEndpoint,
+	AnnSource,
+	AnnUploadRequest,
+	AnnCloneRequest,
+}
+
+// CheckPVCAdoptable returns why pvc can not be adopted by a DataVolume, nil if it can: it is not controlled by
+// another object, not being deleted and was never populated by CDI
+func CheckPVCAdoptable(pvc *corev1.PersistentVolumeClaim) error {
+	if owner := metav1.GetControllerOf(pvc); owner != nil {
+		return errors.Errorf("PVC %s is controlled by %s %s", pvc.Name, owner.Kind, owner.Name)
+	}
+	if pvc.DeletionTimestamp != nil {
+		return errors.Errorf("PVC %s is being deleted", pvc.Name)
+	}
+	for _, annotation := range populationAnnotations {
+		if _, ok := pvc.Annotations[annotation]; ok {
+			return errors.Errorf("PVC %s was already populated by CDI", pvc.Name)
+		}
+	}
+	return nil
+}
+
+// adoptsPVC returns true if dv asks to adopt an existing PVC of its name
+func adoptsPVC(dv *cdiv1.DataVolume) bool {
+	return dv.Annotations[AnnAdoptPVC] == "true"
+}
+
+// adoptPVC makes dv the controller of the existing pvc and adds the annotations and labels of the transfer of dv,
+// the PVC keeps the spec it was created with
+func (r *DatavolumeReconciler) adoptPVC(dv *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) error {
+	desired, err := newPersistentVolumeClaim(dv)
+	if err != nil {
+		return err
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string)
+	}
+	for k, v := range desired.Annotations {
+		pvc.Annotations[k] = v
+	}
+	if pvc.Labels == nil {
+		pvc.Labels = make(map[string]string)
+	}
+	for k, v := range desired.Labels {
+		pvc.Labels[k] = v
+	}
+	pvc.OwnerReferences = append(pvc.OwnerReferences, desired.OwnerReferences...)
+	if err := r.Client.Update(context.TODO(), pvc); err != nil {
+		return err
+	}
+	r.Log.V(1).Info("Adopted existing PVC", "namespace", pvc.Namespace, "name", pvc.Name)
+	r.recorder.Event(dv, corev1.EventTypeNormal, AdoptedPVC, fmt.Sprintf(MessageAdoptedPVC, pvc.Name))
+	return nil
+}
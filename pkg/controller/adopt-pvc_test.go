@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Adopt PVC", func() {
+	dvName := types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}
+
+	It("Should populate an existing PVC in place", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnAdoptPVC: "true"}
+		storageClass := "team-storage"
+		existing := createPvcInStorageClass("test-dv", metav1.NamespaceDefault, &storageClass, map[string]string{"team.example.com/owner": "storage"}, nil)
+		existing.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")}
+		r := createDatavolumeReconciler(existing, dv)
+
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: dvName})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		Expect(metav1.IsControlledBy(pvc, dv)).To(BeTrue())
+		Expect(pvc.Annotations).To(HaveKeyWithValue(AnnEndpoint, "http://example.com/data"))
+		Expect(pvc.Annotations).To(HaveKeyWithValue("team.example.com/owner", "storage"))
+		Expect(pvc.Labels).To(HaveKeyWithValue("app", "containerized-data-importer"))
+		Expect(*pvc.Spec.StorageClassName).To(Equal("team-storage"))
+		Expect(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).To(Equal(resource.MustParse("20Gi")))
+		event := <-r.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(AdoptedPVC))
+	})
+
+	It("Should not adopt a PVC without the annotation", func() {
+		r := createDatavolumeReconciler(createPvc("test-dv", metav1.NamespaceDefault, nil, nil), newImportDataVolume("test-dv"))
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: dvName})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should not adopt a PVC CDI already populated", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnAdoptPVC: "true"}
+		existing := createPvc("test-dv", metav1.NamespaceDefault, map[string]string{AnnPodPhase: string(corev1.PodSucceeded)}, nil)
+		r := createDatavolumeReconciler(existing, dv)
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: dvName})
+		Expect(err).To(HaveOccurred())
+		Expect(CheckPVCAdoptable(existing)).To(MatchError(ContainSubstring("already populated")))
+	})
+
+	It("Should not adopt a PVC controlled by another object", func() {
+		existing := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		isController := true
+		existing.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: &isController}}
+		Expect(CheckPVCAdoptable(existing)).To(MatchError(ContainSubstring("controlled by StatefulSet db")))
+	})
+
+	It("Should not fall back from an adopted PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{
+			AnnAdoptPVC:               "true",
+			AnnVolumeModeFallback:     "true",
+			AnnFallbackStorageClasses: "standard",
+		}
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Status.Phase = corev1.ClaimPending
+		Expect(canFallBackVolumeMode(dv, pvc)).To(BeFalse())
+		Expect(canFallBackStorageClass(dv)).To(BeFalse())
+	})
+})
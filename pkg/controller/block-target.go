@@ -0,0 +1,14 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// blockTargetLayout returns the region of the block device the succeeded importer in pod wrote the image to, from its
+// termination message, if the image was written to a block target
+func blockTargetLayout(pod *v1.Pod) (string, bool) {
+	if message, ok := succeededTerminationMessage(pod); ok && message.BlockTargetLayout != "" {
+		return message.BlockTargetLayout, true
+	}
+	return "", false
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Block target", func() {
+	createCompletedPod := func(exitCode int32, message string) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase: corev1.PodSucceeded,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: exitCode,
+								Message:  message,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	table.DescribeTable("Should pass the block target to the created PVC and the importer", func(target *cdiv1.DataVolumeBlockTarget, annotation, env, value string) {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.BlockTarget = target
+		reconciler := createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[annotation]).To(Equal(value))
+		podEnvVar := &importPodEnvVar{
+			blockTargetOffset:    pvc.GetAnnotations()[AnnBlockTargetOffset],
+			blockTargetPartition: pvc.GetAnnotations()[AnnBlockTargetPartition],
+		}
+		Expect(makeImportEnv(podEnvVar, "1111-1111-1111-1111")).To(ContainElement(corev1.EnvVar{Name: env, Value: value}))
+	},
+		table.Entry("with an offset", &cdiv1.DataVolumeBlockTarget{Offset: 1048576}, AnnBlockTargetOffset, common.ImporterBlockTargetOffset, "1048576"),
+		table.Entry("with a partition", &cdiv1.DataVolumeBlockTarget{Partition: 2}, AnnBlockTargetPartition, common.ImporterBlockTargetPartition, "2"),
+	)
+
+	It("Should not annotate the PVC without a block target", func() {
+		dv := newImportDataVolume("test-dv")
+		reconciler := createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnBlockTargetOffset))
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnBlockTargetPartition))
+	})
+
+	table.DescribeTable("Should read the layout of the block target", func(pod *corev1.Pod, expectedLayout string, expectedOk bool) {
+		layout, ok := blockTargetLayout(pod)
+		Expect(ok).To(Equal(expectedOk))
+		Expect(layout).To(Equal(expectedLayout))
+	},
+		table.Entry("of an importer", createCompletedPod(0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, BlockTargetLayout: "partition=1,table=gpt,offset=1048576,size=1048576"})), "partition=1,table=gpt,offset=1048576,size=1048576", true),
+		table.Entry("of an importer that computed the content digest", createCompletedPod(0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, BlockTargetLayout: "offset=512,size=1024", Digest: "sha256:00"})), "offset=512,size=1024", true),
+		table.Entry("not of an importer that wrote the whole device", createCompletedPod(0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Digest: "sha256:00"})), "", false),
+		table.Entry("not of a failed importer", createCompletedPod(1, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationFailed, BlockTargetLayout: "offset=512,size=1024"})), "", false),
+	)
+})
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"fmt"
+
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	v1 "k8s.io/api/core/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// claimConditionRule maps an annotation the controllers set on a PVC to a condition of the DataVolume of the PVC
+type claimConditionRule struct {
+	// annotation is the PVC annotation, the condition is True while it is set
+	annotation string
+	// conditionType is the type of the DataVolume condition
+	conditionType conditions.ConditionType
+	// reason is the reason of the condition while it is True
+	reason string
+	// clearedReason is the reason of the condition once the annotation is removed or the transfer succeeded
+	clearedReason string
+	// message returns the message of the condition from the value of the annotation, the value itself if nil
+	message func(value string) string
+}
+
+// claimConditionRules are the conditions of PVCs that propagate to their DataVolumes
+var claimConditionRules = []claimConditionRule{
+	{
+		annotation:    AnnPodQueued,
+		conditionType: cdiv1.DataVolumeQueued,
+		reason:        TransferQueued,
+		clearedReason: TransferStarted,
+	},
+	{
+		annotation:    AnnScratchExhausted,
+		conditionType: cdiv1.DataVolumeInsufficientScratchSpace,
+		reason:        InsufficientScratchSpace,
+		clearedReason: ImportSucceeded,
+		message:       scratchSpaceExhaustedMessage,
+	},
+	{
+		annotation:    AnnTransferStalled,
+		conditionType: cdiv1.DataVolumeStalled,
+		reason:        TransferStalled,
+		clearedReason: TransferResumed,
+	},
+	{
+		annotation:    AnnCloneTokenExpired,
+		conditionType: cdiv1.DataVolumeTokenExpired,
+		reason:        CloneTokenExpired,
+		clearedReason: CloneTokenValid,
+	},
+	{
+		annotation:    AnnNodeUnschedulable,
+		conditionType: cdiv1.DataVolumeNodeUnschedulable,
+		reason:        NodeUnschedulable,
+		clearedReason: NodeSchedulable,
+	},
+	{
+		annotation:    AnnTransferWindowClosed,
+		conditionType: cdiv1.DataVolumeWaitingForTransferWindow,
+		reason:        TransferWindowClosed,
+		clearedReason: TransferWindowOpen,
+	},
+}
+
+// propagateClaimConditions reflects the annotations of pvc in the conditions of dataVolume, by the claimConditionRules.
+// The message of a True condition starts with the PVC it comes from. Once the transfer to pvc succeeded, none of the
+// conditions is True anymore.
+func propagateClaimConditions(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	for _, rule := range claimConditionRules {
+		rule.propagate(dataVolume, pvc)
+	}
+}
+
+// propagate reflects the annotation of rule on pvc in the condition of rule on dataVolume. A condition is only added
+// once its annotation was set.
+func (rule claimConditionRule) propagate(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	current := conditions.FindStatusCondition(dataVolume.Status.Conditions, rule.conditionType)
+	value, ok := pvc.GetAnnotations()[rule.annotation]
+	if !ok || podSucceededFromPVC(pvc) {
+		if current != nil && current.Status != v1.ConditionFalse {
+			conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+				Type:   rule.conditionType,
+				Status: v1.ConditionFalse,
+				Reason: rule.clearedReason,
+			})
+		}
+		return
+	}
+	message := value
+	if rule.message != nil {
+		message = rule.message(value)
+	}
+	message = fmt.Sprintf("PersistentVolumeClaim %s/%s: %s", pvc.Namespace, pvc.Name, message)
+	if current == nil || current.Status != v1.ConditionTrue || current.Reason != rule.reason || current.Message != message {
+		conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+			Type:    rule.conditionType,
+			Status:  v1.ConditionTrue,
+			Reason:  rule.reason,
+			Message: message,
+		})
+	}
+}
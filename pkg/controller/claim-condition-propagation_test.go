@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Claim condition propagation", func() {
+	table.DescribeTable("Should propagate the condition of the PVC to the data volume", func(annotation, value string, conditionType conditions.ConditionType, reason, clearedReason, message string) {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, map[string]string{annotation: value}, nil)
+		propagateClaimConditions(dv, pvc)
+		Expect(dv.Status.Conditions).To(HaveLen(1))
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, conditionType)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(reason))
+		Expect(condition.Message).To(Equal("PersistentVolumeClaim default/test-dv: " + message))
+
+		delete(pvc.Annotations, annotation)
+		propagateClaimConditions(dv, pvc)
+		condition = conditions.FindStatusCondition(dv.Status.Conditions, conditionType)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(clearedReason))
+		Expect(condition.Message).To(BeEmpty())
+	},
+		table.Entry("Queued", AnnPodQueued, "CDIQuota quota allows 1 transfer pods, 1 exist",
+			cdiv1.DataVolumeQueued, TransferQueued, TransferStarted, "CDIQuota quota allows 1 transfer pods, 1 exist"),
+		table.Entry("InsufficientScratchSpace", AnnScratchExhausted, fmt.Sprintf(common.ScratchSpaceExhaustedMessage, 2147483648, 1073741824),
+			cdiv1.DataVolumeInsufficientScratchSpace, InsufficientScratchSpace, ImportSucceeded, "Scratch space ran out, 2Gi required and 1Gi available"),
+		table.Entry("Stalled", AnnTransferStalled, "Transferred no data and made no progress since 2020-10-16T10:00:00Z, 1048576 bytes transferred",
+			cdiv1.DataVolumeStalled, TransferStalled, TransferResumed, "Transferred no data and made no progress since 2020-10-16T10:00:00Z, 1048576 bytes transferred"),
+		table.Entry("TokenExpired", AnnCloneTokenExpired, "The clone token expired before the clone started",
+			cdiv1.DataVolumeTokenExpired, CloneTokenExpired, CloneTokenValid, "The clone token expired before the clone started"),
+	)
+
+	It("Should not add the conditions of a PVC without their annotations", func() {
+		dv := newImportDataVolume("test-dv")
+		propagateClaimConditions(dv, createPvc("test-dv", metav1.NamespaceDefault, nil, nil))
+		Expect(dv.Status.Conditions).To(BeEmpty())
+	})
+
+	It("Should clear the conditions once the transfer succeeded", func() {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, map[string]string{AnnTransferStalled: "Transferred no data"}, nil)
+		propagateClaimConditions(dv, pvc)
+		Expect(conditions.IsStatusConditionTrue(dv.Status.Conditions, cdiv1.DataVolumeStalled)).To(BeTrue())
+		pvc.Annotations[AnnPodPhase] = string(corev1.PodSucceeded)
+		propagateClaimConditions(dv, pvc)
+		Expect(conditions.IsStatusConditionFalse(dv.Status.Conditions, cdiv1.DataVolumeStalled)).To(BeTrue())
+	})
+})
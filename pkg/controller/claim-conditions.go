@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ClaimConditionsVersion is the version of the condition annotations CDI publishes on the PVCs it populates. The
+// names and meaning of the annotations of a version do not change, new ones are only added with a new version.
+const ClaimConditionsVersion = "v1"
+
+const (
+	// AnnConditionsVersion is a PVC annotation with the version of the condition annotations published on the PVC
+	AnnConditionsVersion = AnnAPIGroup + "/storage.condition.version"
+	// AnnBoundCondition is a PVC annotation that tells whether the PVC is bound to a volume, "True" or "False"
+	AnnBoundCondition = AnnAPIGroup + "/storage.condition.bound"
+	// AnnBoundConditionReason is a PVC annotation with the reason of AnnBoundCondition
+	AnnBoundConditionReason = AnnAPIGroup + "/storage.condition.bound.reason"
+	// AnnBoundConditionMessage is a PVC annotation with the message of AnnBoundCondition
+	AnnBoundConditionMessage = AnnAPIGroup + "/storage.condition.bound.message"
+	// AnnRunningCondition is a PVC annotation that tells whether a transfer pod populates the PVC, "True" or "False"
+	AnnRunningCondition = AnnAPIGroup + "/storage.condition.running"
+	// AnnRunningConditionReason is a PVC annotation with the reason of AnnRunningCondition
+	AnnRunningConditionReason = AnnAPIGroup + "/storage.condition.running.reason"
+	// AnnRunningConditionMessage is a PVC annotation with the message of AnnRunningCondition
+	AnnRunningConditionMessage = AnnAPIGroup + "/storage.condition.running.message"
+	// AnnReadyCondition is a PVC annotation that tells whether the PVC is populated and ready to be used, "True" or
+	// "False"
+	AnnReadyCondition = AnnAPIGroup + "/storage.condition.ready"
+	// AnnReadyConditionReason is a PVC annotation with the reason of AnnReadyCondition
+	AnnReadyConditionReason = AnnAPIGroup + "/storage.condition.ready.reason"
+	// AnnReadyConditionMessage is a PVC annotation with the message of AnnReadyCondition
+	AnnReadyConditionMessage = AnnAPIGroup + "/storage.condition.ready.message"
+	// AnnConditionProgress is a PVC annotation with the progress of populating the PVC, such as 45.20%
+	AnnConditionProgress = AnnAPIGroup + "/storage.condition.progress"
+	// AnnConditionResult is a PVC annotation with the terminal result of populating the PVC, Succeeded or Failed, it
+	// is only set once the population is over
+	AnnConditionResult = AnnAPIGroup + "/storage.condition.result"
+)
+
+// ClaimConditionType is the type of a condition CDI publishes on a PVC
+type ClaimConditionType string
+
+const (
+	// ClaimBound tells whether the PVC is bound to a volume
+	ClaimBound ClaimConditionType = "Bound"
+	// ClaimRunning tells whether a transfer pod populates the PVC
+	ClaimRunning ClaimConditionType = "Running"
+	// ClaimReady tells whether the PVC is populated and ready to be used
+	ClaimReady ClaimConditionType = "Ready"
+)
+
+const (
+	// ClaimResultSucceeded is the result of a PVC that was populated
+	ClaimResultSucceeded = "Succeeded"
+	// ClaimResultFailed is the result of a PVC whose population failed and is not retried
+	ClaimResultFailed = "Failed"
+)
+
+const (
+	// claimReasonBound is the reason of a bound PVC
+	claimReasonBound = "Bound"
+	// claimReasonPending is the reason of a PVC that is not bound yet, or whose transfer pod did not start yet
+	claimReasonPending = "Pending"
+	// claimReasonLost is the reason of a PVC that lost its volume
+	claimReasonLost = "ClaimLost"
+	// claimReasonQueued is the reason of a PVC whose transfer pods wait for a CDIQuota
+	claimReasonQueued = "Queued"
+	// claimReasonRunning is the reason of a PVC a transfer pod runs for
+	claimReasonRunning = "Running"
+	// claimReasonInProgress is the reason of a PVC that is not ready because it is being populated
+	claimReasonInProgress = "TransferInProgress"
+	// claimReasonCompleted is the reason of a PVC that was populated
+	claimReasonCompleted = "Completed"
+	// claimReasonFailed is the reason of a PVC whose transfer pod failed for no more specific reason
+	claimReasonFailed = "Failed"
+)
+
+// ClaimCondition is a condition CDI publishes on a PVC
+type ClaimCondition struct {
+	Type    ClaimConditionType
+	Status  v1.ConditionStatus
+	Reason  string
+	Message string
+}
+
+// claimConditionAnnotations are the annotations of the status, reason and message of each condition type
+var claimConditionAnnotations = map[ClaimConditionType][3]string{
+	ClaimBound:   {AnnBoundCondition, AnnBoundConditionReason, AnnBoundConditionMessage},
+	ClaimRunning: {AnnRunningCondition, AnnRunningConditionReason, AnnRunningConditionMessage},
+	ClaimReady:   {AnnReadyCondition, AnnReadyConditionReason, AnnReadyConditionMessage},
+}
+
+// ClaimConditionOf returns the condition of type conditionType of pvc. The condition annotations are read if pvc has
+// them, a PVC populated by a CDI that did not publish them yet has the condition derived from the pod phase and
+// readiness annotations that CDI recorded.
+func ClaimConditionOf(pvc *v1.PersistentVolumeClaim, conditionType ClaimConditionType) ClaimCondition {
+	anno := pvc.GetAnnotations()
+	if _, ok := anno[AnnConditionsVersion]; ok {
+		names := claimConditionAnnotations[conditionType]
+		return ClaimCondition{
+			Type:    conditionType,
+			Status:  v1.ConditionStatus(anno[names[0]]),
+			Reason:  anno[names[1]],
+			Message: anno[names[2]],
+		}
+	}
+	for _, condition := range claimConditions(pvc) {
+		if condition.Type == conditionType {
+			return condition
+		}
+	}
+	return ClaimCondition{Type: conditionType, Status: v1.ConditionUnknown}
+}
+
+// claimResult returns the terminal result of populating pvc, "" while it is not over
+func claimResult(pvc *v1.PersistentVolumeClaim) string {
+	anno := pvc.GetAnnotations()
+	if _, ok := anno[AnnPermanentFailure]; ok || populationPhaseOf(pvc) == populationFailed {
+		return ClaimResultFailed
+	}
+	if populationPhaseOf(pvc) == populationSucceeded || anno[AnnPodPhase] == string(v1.PodSucceeded) {
+		return ClaimResultSucceeded
+	}
+	return ""
+}
+
+// claimFailure returns the reason and message of the failure of the transfer pod of pvc
+func claimFailure(pvc *v1.PersistentVolumeClaim) (string, string) {
+	if permanentFailure, ok := pvc.GetAnnotations()[AnnPermanentFailure]; ok {
+		return parsePermanentFailure(permanentFailure)
+	}
+	return claimReasonFailed, "Populating the PVC failed"
+}
+
+// claimConditions derives the Bound, Running and Ready conditions of pvc from its phase and the annotations the CDI
+// controllers record on it
+func claimConditions(pvc *v1.PersistentVolumeClaim) []ClaimCondition {
+	anno := pvc.GetAnnotations()
+	bound := ClaimCondition{Type: ClaimBound, Status: v1.ConditionFalse, Reason: claimReasonPending, Message: "PVC " + pvc.Name + " is pending"}
+	switch pvc.Status.Phase {
+	case v1.ClaimBound:
+		bound.Status, bound.Reason, bound.Message = v1.ConditionTrue, claimReasonBound, "PVC "+pvc.Name+" is bound"
+	case v1.ClaimLost:
+		bound.Reason, bound.Message = claimReasonLost, "PVC "+pvc.Name+" lost its volume"
+	}
+
+	running := ClaimCondition{Type: ClaimRunning, Status: v1.ConditionFalse, Reason: claimReasonPending, Message: "No transfer pod is running"}
+	ready := ClaimCondition{Type: ClaimReady, Status: v1.ConditionFalse, Reason: claimReasonPending, Message: "The PVC is not populated yet"}
+	switch result := claimResult(pvc); {
+	case result == ClaimResultSucceeded:
+		running.Reason, running.Message = claimReasonCompleted, "The transfer pod completed"
+		ready.Status, ready.Reason, ready.Message = v1.ConditionTrue, claimReasonCompleted, "The PVC is populated"
+	case result == ClaimResultFailed:
+		reason, message := claimFailure(pvc)
+		running.Reason, running.Message = reason, message
+		ready.Reason, ready.Message = reason, message
+	case anno[AnnPodQueued] != "":
+		running.Reason, running.Message = claimReasonQueued, anno[AnnPodQueued]
+	case anno[AnnPodPhase] == string(v1.PodRunning):
+		running.Status, running.Reason, running.Message = v1.ConditionTrue, claimReasonRunning, "A transfer pod populates the PVC"
+		ready.Reason, ready.Message = claimReasonInProgress, "The PVC is being populated"
+	case anno[AnnPodPhase] == string(v1.PodFailed):
+		running.Reason, running.Message = claimFailure(pvc)
+		ready.Reason, ready.Message = claimReasonInProgress, "The population is retried"
+	}
+	// A populated PVC that waits for its first consumer to be bound is ready to be used by it
+	if ready.Status == v1.ConditionTrue && pvc.Status.Phase == v1.ClaimLost {
+		ready.Status, ready.Reason, ready.Message = v1.ConditionFalse, bound.Reason, bound.Message
+	}
+	return []ClaimCondition{bound, running, ready}
+}
+
+// publishClaimConditions records the conditions of pvc in its condition annotations, with progress unless it is ""
+// or N/A. The caller updates pvc.
+func publishClaimConditions(pvc *v1.PersistentVolumeClaim, progress string) {
+	if pvc.GetAnnotations() == nil {
+		pvc.SetAnnotations(make(map[string]string))
+	}
+	anno := pvc.GetAnnotations()
+	anno[AnnConditionsVersion] = ClaimConditionsVersion
+	for _, condition := range claimConditions(pvc) {
+		names := claimConditionAnnotations[condition.Type]
+		anno[names[0]] = string(condition.Status)
+		anno[names[1]] = condition.Reason
+		anno[names[2]] = condition.Message
+	}
+	result := claimResult(pvc)
+	if result == ClaimResultSucceeded {
+		progress = "100.0%"
+	}
+	if progress != "" && progress != "N/A" {
+		anno[AnnConditionProgress] = progress
+	}
+	if result != "" {
+		anno[AnnConditionResult] = result
+	} else {
+		delete(anno, AnnConditionResult)
+	}
+}
+
+// publishClaimConditions publishes the conditions of pvcCopy, the copy of the PVC of a DataVolume, with the progress of
+// the DataVolume, and updates the PVC if its annotations changed
+func (r *DatavolumeReconciler) publishClaimConditions(pvc, pvcCopy *v1.PersistentVolumeClaim, progress string) error {
+	publishClaimConditions(pvcCopy, progress)
+	if reflect.DeepEqual(pvc.GetAnnotations(), pvcCopy.GetAnnotations()) {
+		return nil
+	}
+	return r.Client.Update(context.TODO(), pvcCopy)
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Claim conditions", func() {
+	table.DescribeTable("Should derive the conditions of a PVC", func(phase corev1.PersistentVolumeClaimPhase, annotations map[string]string, bound, running, ready corev1.ConditionStatus, readyReason, result string) {
+		pvc := createPvc("test-pvc", metav1.NamespaceDefault, annotations, nil)
+		pvc.Status.Phase = phase
+		publishClaimConditions(pvc, "")
+		anno := pvc.GetAnnotations()
+		Expect(anno[AnnConditionsVersion]).To(Equal(ClaimConditionsVersion))
+		Expect(anno[AnnBoundCondition]).To(Equal(string(bound)))
+		Expect(anno[AnnRunningCondition]).To(Equal(string(running)))
+		Expect(anno[AnnReadyCondition]).To(Equal(string(ready)))
+		Expect(anno[AnnReadyConditionReason]).To(Equal(readyReason))
+		Expect(anno[AnnConditionResult]).To(Equal(result))
+	},
+		table.Entry("pending", corev1.ClaimPending, nil, corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionFalse, claimReasonPending, ""),
+		table.Entry("queued", corev1.ClaimBound, map[string]string{AnnPodQueued: "quota exceeded"}, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse, claimReasonPending, ""),
+		table.Entry("running", corev1.ClaimBound, map[string]string{AnnPodPhase: string(corev1.PodRunning)}, corev1.ConditionTrue, corev1.ConditionTrue, corev1.ConditionFalse, claimReasonInProgress, ""),
+		table.Entry("succeeded", corev1.ClaimBound, map[string]string{AnnPodPhase: string(corev1.PodSucceeded)}, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionTrue, claimReasonCompleted, ClaimResultSucceeded),
+		table.Entry("succeeded waiting for its first consumer", corev1.ClaimPending, map[string]string{AnnPodPhase: string(corev1.PodSucceeded)}, corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionTrue, claimReasonCompleted, ClaimResultSucceeded),
+		table.Entry("failed permanently", corev1.ClaimBound, map[string]string{AnnPodPhase: string(corev1.PodFailed), AnnPermanentFailure: "ChecksumMismatch: digest differs"}, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse, "ChecksumMismatch", ClaimResultFailed),
+		table.Entry("failed population", corev1.ClaimBound, map[string]string{AnnPopulationPhase: string(populationFailed)}, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse, claimReasonFailed, ClaimResultFailed),
+		table.Entry("lost", corev1.ClaimLost, map[string]string{AnnPodPhase: string(corev1.PodSucceeded)}, corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionFalse, claimReasonLost, ClaimResultSucceeded),
+	)
+
+	It("Should publish the progress and complete it on success", func() {
+		pvc := createPvc("test-pvc", metav1.NamespaceDefault, map[string]string{AnnPodPhase: string(corev1.PodRunning)}, nil)
+		publishClaimConditions(pvc, "N/A")
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnConditionProgress))
+		publishClaimConditions(pvc, "45.20%")
+		Expect(pvc.GetAnnotations()[AnnConditionProgress]).To(Equal("45.20%"))
+		publishClaimConditions(pvc, "")
+		Expect(pvc.GetAnnotations()[AnnConditionProgress]).To(Equal("45.20%"))
+		pvc.GetAnnotations()[AnnPodPhase] = string(corev1.PodSucceeded)
+		publishClaimConditions(pvc, "")
+		Expect(pvc.GetAnnotations()[AnnConditionProgress]).To(Equal("100.0%"))
+	})
+
+	It("Should derive the condition of a PVC without condition annotations from its pod phase", func() {
+		pvc := createPvc("test-pvc", metav1.NamespaceDefault, map[string]string{AnnPodPhase: string(corev1.PodRunning), AnnPodReady: "true"}, nil)
+		pvc.Status.Phase = corev1.ClaimBound
+		condition := ClaimConditionOf(pvc, ClaimRunning)
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(claimReasonRunning))
+	})
+
+	It("Should read the published condition of a PVC", func() {
+		pvc := createPvc("test-pvc", metav1.NamespaceDefault, map[string]string{
+			AnnConditionsVersion:     ClaimConditionsVersion,
+			AnnReadyCondition:        string(corev1.ConditionFalse),
+			AnnReadyConditionReason:  "Custom",
+			AnnReadyConditionMessage: "published",
+			AnnPodPhase:              string(corev1.PodSucceeded),
+		}, nil)
+		Expect(ClaimConditionOf(pvc, ClaimReady)).To(Equal(ClaimCondition{Type: ClaimReady, Status: corev1.ConditionFalse, Reason: "Custom", Message: "published"}))
+	})
+
+	It("Should publish the conditions on the PVC of a DataVolume", func() {
+		dv := newImportDataVolume("test-dv")
+		reconciler := createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		pvc.Status.Phase = corev1.ClaimBound
+		pvc.GetAnnotations()[AnnPodPhase] = string(corev1.PodSucceeded)
+		Expect(reconciler.Client.Update(context.TODO(), pvc)).To(Succeed())
+
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnBoundCondition]).To(Equal(string(corev1.ConditionTrue)))
+		Expect(pvc.GetAnnotations()[AnnReadyCondition]).To(Equal(string(corev1.ConditionTrue)))
+		Expect(pvc.GetAnnotations()[AnnConditionResult]).To(Equal(ClaimResultSucceeded))
+		Expect(pvc.GetAnnotations()[AnnConditionProgress]).To(Equal("100.0%"))
+	})
+})
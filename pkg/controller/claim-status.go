@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// AnnSelectedNode is the annotation the scheduler sets on a PVC whose storage class waits for the first consumer, with
+// the node the PVC is to be provisioned for
+const AnnSelectedNode = "volume.kubernetes.io/selected-node"
+
+// getBoundVolume returns the persistent volume pvc is bound to, or nil if it is not bound yet
+func (r *DatavolumeReconciler) getBoundVolume(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	if pvc.Spec.VolumeName == "" {
+		return nil, nil
+	}
+	pv := &v1.PersistentVolume{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pv, nil
+}
+
+// updateClaimStatus records the volume mode, access modes, storage class and node topology pvc ended up with in the
+// status of dataVolume, those of the volume pv it is bound to take precedence once there is one
+func updateClaimStatus(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) {
+	claim := &cdiv1.DataVolumeClaimStatus{
+		VolumeMode:       pvc.Spec.VolumeMode,
+		AccessModes:      pvc.Spec.AccessModes,
+		StorageClassName: pvc.Spec.StorageClassName,
+		VolumeName:       pvc.Spec.VolumeName,
+		SelectedNode:     pvc.GetAnnotations()[AnnSelectedNode],
+		CloneSizing:      pvc.GetAnnotations()[AnnCloneSizing],
+	}
+	if len(pvc.Status.AccessModes) > 0 {
+		claim.AccessModes = pvc.Status.AccessModes
+	}
+	if pv != nil {
+		if pv.Spec.VolumeMode != nil {
+			claim.VolumeMode = pv.Spec.VolumeMode
+		}
+		if claim.StorageClassName == nil && pv.Spec.StorageClassName != "" {
+			claim.StorageClassName = &pv.Spec.StorageClassName
+		}
+		claim.NodeAffinity = pv.Spec.NodeAffinity
+	}
+	dataVolume.Status.Claim = claim.DeepCopy()
+}
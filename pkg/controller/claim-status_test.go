@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Claim status", func() {
+	blockMode := corev1.PersistentVolumeBlock
+	filesystemMode := corev1.PersistentVolumeFilesystem
+	nodeAffinity := &corev1.VolumeNodeAffinity{
+		Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: "kubernetes.io/hostname", Operator: corev1.NodeSelectorOpIn, Values: []string{"node01"}},
+					},
+				},
+			},
+		},
+	}
+
+	It("Should record the PVC before it is bound", func() {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvcInStorageClass("test-dv", metav1.NamespaceDefault, &testStorageClass, map[string]string{AnnSelectedNode: "node01"}, nil)
+		pvc.Spec.VolumeMode = &filesystemMode
+		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		updateClaimStatus(dv, pvc, nil)
+		Expect(dv.Status.Claim).To(Equal(&cdiv1.DataVolumeClaimStatus{
+			VolumeMode:       &filesystemMode,
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &testStorageClass,
+			SelectedNode:     "node01",
+		}))
+	})
+
+	It("Should record the volume the PVC is bound to", func() {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Spec.VolumeName = "pv01"
+		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		pvc.Status.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadOnlyMany}
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv01"},
+			Spec: corev1.PersistentVolumeSpec{
+				VolumeMode:       &blockMode,
+				StorageClassName: "local",
+				NodeAffinity:     nodeAffinity,
+			},
+		}
+		updateClaimStatus(dv, pvc, pv)
+		storageClassName := "local"
+		Expect(dv.Status.Claim).To(Equal(&cdiv1.DataVolumeClaimStatus{
+			VolumeMode:       &blockMode,
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadOnlyMany},
+			StorageClassName: &storageClassName,
+			VolumeName:       "pv01",
+			NodeAffinity:     nodeAffinity,
+		}))
+	})
+
+	It("Should update the status of the DataVolume once the PVC is bound", func() {
+		dv := newImportDataVolume("test-dv")
+		reconciler := createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		pvc.Spec.VolumeName = "pv01"
+		pvc.Status.Phase = corev1.ClaimBound
+		Expect(reconciler.Client.Update(context.TODO(), pvc)).To(Succeed())
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv01"},
+			Spec:       corev1.PersistentVolumeSpec{VolumeMode: &blockMode, NodeAffinity: nodeAffinity},
+		}
+		Expect(reconciler.Client.Create(context.TODO(), pv)).To(Succeed())
+
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dv.Status.Claim).ToNot(BeNil())
+		Expect(dv.Status.Claim.VolumeName).To(Equal("pv01"))
+		Expect(dv.Status.Claim.VolumeMode).To(Equal(&blockMode))
+		Expect(dv.Status.Claim.NodeAffinity).To(Equal(nodeAffinity))
+	})
+})
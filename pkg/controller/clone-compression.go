@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// getCloneCompression returns the CloneCompression of the CDIConfig, nil if it sets none
+func getCloneCompression(c client.Client) (*cdiv1.CloneCompression, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cdiconfig.Spec.CloneCompression, nil
+}
+
+// setCloneCompression tells a clone source pod how the CDIConfig asks it to compress its stream. The pod negotiates
+// the encoding with the upload server, so the configured encoding is only a preference.
+func setCloneCompression(c client.Client, pod *corev1.Pod) error {
+	compression, err := getCloneCompression(c)
+	if err != nil || compression == nil {
+		return err
+	}
+	if compression.Encoding != "" {
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  common.CloneCompression,
+			Value: compression.Encoding,
+		})
+	}
+	if compression.Level != 0 {
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  common.CloneCompressionLevel,
+			Value: strconv.Itoa(int(compression.Level)),
+		})
+	}
+	return nil
+}
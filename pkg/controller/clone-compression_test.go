@@ -0,0 +1,49 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Clone compression", func() {
+	clonePod := func() *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: common.ClonerSourcePodName}}}}
+	}
+
+	It("Should pass the encoding and level of the CDIConfig to the clone source pod", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.CloneCompression = &cdiv1.CloneCompression{Encoding: "gzip", Level: 1}
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pod := clonePod()
+		Expect(setCloneCompression(c, pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Env).To(ConsistOf(
+			corev1.EnvVar{Name: common.CloneCompression, Value: "gzip"},
+			corev1.EnvVar{Name: common.CloneCompressionLevel, Value: "1"},
+		))
+	})
+
+	It("Should leave the defaults of the clone source pod if the CDIConfig sets no compression", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pod := clonePod()
+		Expect(setCloneCompression(c, pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Env).To(BeEmpty())
+	})
+
+	It("Should leave the defaults of the clone source pod without a CDIConfig", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		pod := clonePod()
+		Expect(setCloneCompression(c, pod)).To(Succeed())
+		Expect(pod.Spec.Containers[0].Env).To(BeEmpty())
+	})
+})
@@ -2,8 +2,6 @@ package controller
 
 import (
 	"context"
-	"crypto/rsa"
-	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -41,6 +39,9 @@ const (
 	AnnCloneOf = "k8s.io/CloneOf"
 	// AnnCloneToken is the annotation containing the clone token
 	AnnCloneToken = "cdi.kubevirt.io/storage.clone.token"
+	// AnnCloneTokenExpired is the annotation of a clone target PVC whose clone token expired before the source pod
+	// was created
+	AnnCloneTokenExpired = "cdi.kubevirt.io/storage.clone.tokenExpired"
 
 	//CloneUniqueID is used as a special label to be used when we search for the pod
 	CloneUniqueID = "cdi.kubevirt.io/storage.clone.cloneUniqeId"
@@ -56,12 +57,21 @@ const (
 
 	// CloneSucceededPVC provides a const to indicate a clone to the PVC succeeded
 	CloneSucceededPVC = "CloneSucceeded"
+	// CloneTokenExpired provides a const to indicate the clone token of the PVC expired before the clone started
+	CloneTokenExpired = "CloneTokenExpired"
+	// CloneTokenValid provides a const to indicate the clone token of the PVC was valid when the clone started
+	CloneTokenValid = "CloneTokenValid"
 
 	cloneSourcePodFinalizer = "cdi.kubevirt.io/cloneSource"
 
 	cloneTokenLeeway = 10 * time.Second
 
+	// cloneCleanupRetryInterval is how often the cleanup of a clone checks if its source pod is gone
+	cloneCleanupRetryInterval = 2 * time.Second
+
 	uploadClientCertDuration = 365 * 24 * time.Hour
+
+	metricsServerCertDuration = 365 * 24 * time.Hour
 )
 
 // CloneReconciler members
@@ -72,11 +82,15 @@ type CloneReconciler struct {
 	recorder            record.EventRecorder
 	clientCertGenerator generator.CertGenerator
 	serverCAFetcher     fetcher.CertBundleFetcher
-	Log                 logr.Logger
-	tokenValidator      token.Validator
-	Image               string
-	Verbose             string
-	PullPolicy          string
+	// metricsCertGenerator signs the certificate of the metrics endpoint of the source pods
+	metricsCertGenerator generator.CertGenerator
+	// metricsClientCAFetcher fetches the CAs of the clients the metrics endpoint of the source pods serves
+	metricsClientCAFetcher fetcher.CertBundleFetcher
+	Log                    logr.Logger
+	tokenValidator         token.Validator
+	Image                  string
+	Verbose                string
+	PullPolicy             string
 }
 
 // NewCloneController creates a new instance of the config controller.
@@ -87,12 +101,14 @@ func NewCloneController(mgr manager.Manager,
 	verbose string,
 	clientCertGenerator generator.CertGenerator,
 	serverCAFetcher fetcher.CertBundleFetcher,
-	apiServerKey *rsa.PublicKey) (controller.Controller, error) {
+	metricsCertGenerator generator.CertGenerator,
+	metricsClientCAFetcher fetcher.CertBundleFetcher,
+	apiServerKeys token.PublicKeys) (controller.Controller, error) {
 	reconciler := &CloneReconciler{
 		Client:              mgr.GetClient(),
 		Scheme:              mgr.GetScheme(),
-		Log:                 log.WithName("clone-controller"),
-		tokenValidator:      newCloneTokenValidator(apiServerKey),
+		Log:                 newSampledLogger(log.WithName("clone-controller")),
+		tokenValidator:      newCloneTokenValidator(apiServerKeys),
 		Image:               image,
 		Verbose:             verbose,
 		PullPolicy:          pullPolicy,
@@ -100,6 +116,9 @@ func NewCloneController(mgr manager.Manager,
 		K8sClient:           k8sClient,
 		clientCertGenerator: clientCertGenerator,
 		serverCAFetcher:     serverCAFetcher,
+
+		metricsCertGenerator:   metricsCertGenerator,
+		metricsClientCAFetcher: metricsClientCAFetcher,
 	}
 	cloneController, err := controller.New("clone-controller", mgr, controller.Options{
 		Reconciler: reconciler,
@@ -128,12 +147,13 @@ func addCloneControllerWatches(mgr manager.Manager, cloneController controller.C
 	return nil
 }
 
-func newCloneTokenValidator(key *rsa.PublicKey) token.Validator {
-	return token.NewValidator(common.CloneTokenIssuer, key, cloneTokenLeeway)
+func newCloneTokenValidator(keys token.PublicKeys) token.Validator {
+	return token.NewKeysValidator(common.CloneTokenIssuer, keys, cloneTokenLeeway)
 }
 
 func (r *CloneReconciler) shouldReconcile(pvc *corev1.PersistentVolumeClaim) bool {
-	return checkPVC(pvc, AnnCloneRequest) && !metav1.HasAnnotation(pvc.ObjectMeta, AnnCloneOf)
+	return checkPVC(pvc, AnnCloneRequest) && !metav1.HasAnnotation(pvc.ObjectMeta, AnnCloneOf) && pvc.DeletionTimestamp == nil &&
+		populationPhaseOf(pvc) != populationSucceeded && populationPhaseOf(pvc) != populationFailed
 }
 
 // Reconcile the reconcile loop for host assisted clone pvc.
@@ -146,15 +166,24 @@ func (r *CloneReconciler) Reconcile(req reconcile.Request) (reconcile.Result, er
 		}
 		return reconcile.Result{}, err
 	}
-	log := r.Log.WithValues("PVC", req.NamespacedName)
+	log := r.Log.WithValues(logKeyPVC, req.NamespacedName)
 	log.V(1).Info("reconciling Clone PVCs")
+	if isForceCleanup(pvc) {
+		log.V(1).Info("Forcing the cleanup of the PVC")
+		return reconcile.Result{}, r.forceCleanup(pvc)
+	}
 	if !r.shouldReconcile(pvc) {
 		log.V(1).Info("Should not reconcile this PVC", "checkPVC(AnnCloneRequest)", checkPVC(pvc, AnnCloneRequest), "NOT has annotation(AnnCloneOf)", !metav1.HasAnnotation(pvc.ObjectMeta, AnnCloneOf), "has finalizer?", r.hasFinalizer(pvc, cloneSourcePodFinalizer))
 		if r.hasFinalizer(pvc, cloneSourcePodFinalizer) {
-			// Clone completed, remove source pod and finalizer.
-			if err := r.cleanup(pvc, log); err != nil {
+			// Clone completed or the target is being deleted, remove source pod and finalizer.
+			done, err := r.cleanup(pvc, log)
+			if err != nil {
 				return reconcile.Result{}, err
 			}
+			if !done {
+				// The source pod is in another namespace, its deletion does not trigger a reconcile
+				return reconcile.Result{RequeueAfter: cloneCleanupRetryInterval}, nil
+			}
 		}
 		return reconcile.Result{}, nil
 	}
@@ -174,6 +203,44 @@ func (r *CloneReconciler) Reconcile(req reconcile.Request) (reconcile.Result, er
 		return reconcile.Result{}, err
 	}
 
+	var untilDeadline time.Duration
+	if sourcePod != nil {
+		// The upload server of the target is terminated with the source pod, both are created again for a retry
+		uploadPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: pvc.Namespace, Name: getUploadResourceName(pvc.Name)}}
+		var exceeded bool
+		exceeded, untilDeadline, err = checkTransferDeadline(r.Client, r.recorder, pvc, sourcePod, uploadPod)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if exceeded {
+			phase := populationPending
+			if isTransferTimedOut(pvc) {
+				phase = populationFailed
+			}
+			return reconcile.Result{}, r.setPopulationPhase(pvc, phase, log)
+		}
+		if err := reconcileDisruptionBudget(r.Client, pvc, sourcePod); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if sourcePod == nil {
+		if populationPhaseOf(pvc) == populationRunning {
+			// The source pod is gone, the one created next is a new attempt
+			log.V(1).Info("Clone source pod is gone, retrying", logKeyAttempt, populationAttempts(pvc))
+			r.transitionPopulation(pvc, populationPending, log)
+		}
+		reason, err := checkCloneSourceQuota(r.Client, pvc)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if reason != "" {
+			log.V(1).Info("Clone source pod is queued", "reason", reason)
+			r.transitionPopulation(pvc, populationQueued, log)
+			return queueTransfer(r.Client, r.recorder, pvc, reason)
+		}
+	}
+
 	if err := r.reconcileSourcePod(sourcePod, pvc, log); err != nil {
 		return reconcile.Result{}, err
 	}
@@ -181,12 +248,17 @@ func (r *CloneReconciler) Reconcile(req reconcile.Request) (reconcile.Result, er
 	if err := r.updatePvcFromPod(sourcePod, pvc, log); err != nil {
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: untilDeadline}, nil
 }
 
 func (r *CloneReconciler) reconcileSourcePod(sourcePod *corev1.Pod, pvc *corev1.PersistentVolumeClaim, log logr.Logger) error {
 	if sourcePod == nil {
 		if err := r.validateSourceAndTarget(pvc); err != nil {
+			if token.IsExpired(err) {
+				if err := r.setCloneTokenExpired(pvc); err != nil {
+					return err
+				}
+			}
 			return err
 		}
 
@@ -199,7 +271,7 @@ func (r *CloneReconciler) reconcileSourcePod(sourcePod *corev1.Pod, pvc *corev1.
 		if err != nil {
 			return err
 		}
-		log.V(3).Info("Created source pod ", "sourcePod.Namespace", sourcePod.Namespace, "sourcePod.Name", sourcePod.Name)
+		log.V(3).Info("Created source pod", logKeyPod, logObjectKey(sourcePod))
 	}
 	return nil
 }
@@ -209,14 +281,22 @@ func (r *CloneReconciler) updatePvcFromPod(sourcePod *corev1.Pod, pvc *corev1.Pe
 	log.V(1).Info("Updating PVC from pod")
 
 	pvc = r.addFinalizer(pvc, cloneSourcePodFinalizer)
+	// The source pod exists, it no longer waits for a CDIQuota, and its token was valid
+	delete(pvc.Annotations, AnnPodQueued)
+	delete(pvc.Annotations, AnnCloneTokenExpired)
 
-	log.V(3).Info("Pod phase for PVC", "PVC phase", pvc.Annotations[AnnPodPhase])
+	log.V(3).Info("Pod phase for PVC", logKeyPhase, pvc.Annotations[AnnPodPhase])
 
 	if podSucceededFromPVC(pvc) && pvc.Annotations[AnnCloneOf] != "true" {
 		log.V(1).Info("Adding CloneOf annotation to PVC")
 		pvc.Annotations[AnnCloneOf] = "true"
 		r.recorder.Event(pvc, corev1.EventTypeNormal, CloneSucceededPVC, "Clone Successful")
 	}
+	if podSucceededFromPVC(pvc) {
+		r.transitionPopulation(pvc, populationSucceeded, log)
+	} else {
+		r.transitionPopulation(pvc, populationRunning, log)
+	}
 	if sourcePod != nil && sourcePod.Status.ContainerStatuses != nil {
 		// update pvc annotation tracking pod restarts only if the source pod restart count is greater
 		// see the same in upload-controller
@@ -226,6 +306,9 @@ func (r *CloneReconciler) updatePvcFromPod(sourcePod *corev1.Pod, pvc *corev1.Pe
 			pvc.Annotations[AnnPodRestarts] = strconv.Itoa(podRestarts)
 		}
 	}
+	if sourcePod != nil {
+		updateTransferFailure(sourcePod, pvc.Annotations)
+	}
 
 	if !reflect.DeepEqual(currentPvcCopy, pvc) {
 		return r.updatePVC(pvc)
@@ -233,7 +316,38 @@ func (r *CloneReconciler) updatePvcFromPod(sourcePod *corev1.Pod, pvc *corev1.Pe
 	return nil
 }
 
+// setCloneTokenExpired records on the clone target pvc that its clone token expired before the source pod was created
+func (r *CloneReconciler) setCloneTokenExpired(pvc *corev1.PersistentVolumeClaim) error {
+	if _, ok := pvc.Annotations[AnnCloneTokenExpired]; ok {
+		return nil
+	}
+	message := "The clone token expired before the clone started, recreate the DataVolume for a new one"
+	pvc.Annotations[AnnCloneTokenExpired] = message
+	if err := r.updatePVC(pvc); err != nil {
+		return err
+	}
+	r.recorder.Event(pvc, corev1.EventTypeWarning, CloneTokenExpired, message)
+	return nil
+}
+
+// transitionPopulation records the population phase of the clone target pvc, the caller updates pvc
+func (r *CloneReconciler) transitionPopulation(pvc *corev1.PersistentVolumeClaim, phase populationPhase, log logr.Logger) {
+	if err := transitionPopulation(pvc, phase, populationStrategyHostAssistedClone); err != nil {
+		log.Error(err, "Not recording the population phase")
+	}
+}
+
+// setPopulationPhase records the population phase of the clone target pvc and updates pvc
+func (r *CloneReconciler) setPopulationPhase(pvc *corev1.PersistentVolumeClaim, phase populationPhase, log logr.Logger) error {
+	if populationPhaseOf(pvc) == phase {
+		return nil
+	}
+	r.transitionPopulation(pvc, phase, log)
+	return r.updatePVC(pvc)
+}
+
 func (r *CloneReconciler) updatePVC(pvc *corev1.PersistentVolumeClaim) error {
+	publishClaimConditions(pvc, "")
 	if err := r.Client.Update(context.TODO(), pvc); err != nil {
 		return err
 	}
@@ -266,29 +380,15 @@ func (r *CloneReconciler) findCloneSourcePod(pvc *corev1.PersistentVolumeClaim)
 		return nil, nil
 	}
 
-	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			CloneUniqueID: string(pvc.GetUID()) + "-source-pod",
-		},
-	})
-	if err != nil {
-		return nil, errors.Wrap(err, "error creating label selector")
-	}
-
-	podList := &corev1.PodList{}
-	if err := r.Client.List(context.TODO(), podList, &client.ListOptions{Namespace: sourceNamespace, LabelSelector: selector}); err != nil {
-		return nil, errors.Wrap(err, "error listing pods")
-	}
-
-	if len(podList.Items) > 1 {
-		return nil, errors.Errorf("multiple source pods found for clone PVC %s/%s", pvc.Namespace, pvc.Name)
-	}
-
-	if len(podList.Items) == 0 {
-		return nil, nil
+	pod := &corev1.Pod{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: sourceNamespace, Name: getCloneSourcePodName(pvc)}, pod); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "error getting source pod")
 	}
 
-	return &podList.Items[0], nil
+	return pod, nil
 }
 
 func (r *CloneReconciler) validateSourceAndTarget(targetPvc *corev1.PersistentVolumeClaim) error {
@@ -297,7 +397,11 @@ func (r *CloneReconciler) validateSourceAndTarget(targetPvc *corev1.PersistentVo
 		return err
 	}
 
-	if err = validateCloneToken(r.tokenValidator, sourcePvc, targetPvc); err != nil {
+	if _, ok := targetPvc.Annotations[AnnRegistryCacheSource]; ok {
+		if err = validateRegistryCacheSource(r.Client, sourcePvc, targetPvc); err != nil {
+			return err
+		}
+	} else if err = validateCloneToken(r.tokenValidator, sourcePvc, targetPvc); err != nil {
 		return err
 	}
 
@@ -351,28 +455,70 @@ func (r *CloneReconciler) getCloneRequestSourcePVC(pvc *corev1.PersistentVolumeC
 	return pvc, nil
 }
 
-func (r *CloneReconciler) cleanup(pvc *corev1.PersistentVolumeClaim, log logr.Logger) error {
-	log.V(3).Info("Cleaning up for PVC", "pvc.Namespace", pvc.Namespace, "pvc.Name", pvc.Name)
+// cleanup deletes the source pod of the clone to pvc, and removes the finalizer of pvc once the pod is gone, so
+// that the source pod is not left behind when the target is deleted mid-transfer. It returns false while it waits
+// for the source pod.
+func (r *CloneReconciler) cleanup(pvc *corev1.PersistentVolumeClaim, log logr.Logger) (bool, error) {
+	log.V(3).Info("Cleaning up for PVC")
 
 	pod, err := r.findCloneSourcePod(pvc)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if pod != nil && pod.DeletionTimestamp == nil {
-		if podSucceededFromPVC(pvc) && pod.Status.Phase == corev1.PodRunning {
-			log.V(3).Info("Clone succeeded, waiting for source pod to stop running", "pod.Namespace", pod.Namespace, "pod.Name", pod.Name)
-			return nil
-		}
+	if pod != nil {
+		if pod.DeletionTimestamp == nil {
+			if podSucceededFromPVC(pvc) && pod.Status.Phase == corev1.PodRunning && pvc.DeletionTimestamp == nil {
+				log.V(3).Info("Clone succeeded, waiting for source pod to stop running", logKeyPod, logObjectKey(pod))
+				return false, nil
+			}
+			keep, err := r.keepCompletedSourcePod(pvc, pod)
+			if err != nil {
+				return false, err
+			}
+			if keep {
+				// The transfer pod janitor deletes the source pod once the retention is over
+				log.V(3).Info("Clone succeeded, keeping source pod", logKeyPod, logObjectKey(pod))
+				return true, r.updatePVC(r.removeFinalizer(pvc, cloneSourcePodFinalizer))
+			}
 
-		if err = r.Client.Delete(context.TODO(), pod); err != nil {
-			if !k8serrors.IsNotFound(err) {
-				return errors.Wrap(err, "error deleting clone source pod")
+			if err = r.Client.Delete(context.TODO(), pod); err != nil {
+				if !k8serrors.IsNotFound(err) {
+					return false, errors.Wrap(err, "error deleting clone source pod")
+				}
 			}
 		}
+		if pvc.DeletionTimestamp != nil {
+			log.V(3).Info("Target is being deleted, waiting for source pod to terminate", logKeyPod, logObjectKey(pod))
+			return false, nil
+		}
 	}
 
-	return r.updatePVC(r.removeFinalizer(pvc, cloneSourcePodFinalizer))
+	return true, r.updatePVC(r.removeFinalizer(pvc, cloneSourcePodFinalizer))
+}
+
+// forceCleanup removes the clone source pod and the CDI finalizers of pvc, without waiting for the source pod to
+// terminate
+func (r *CloneReconciler) forceCleanup(pvc *corev1.PersistentVolumeClaim) error {
+	cleanup := newForceCleanup(r.Client, pvc)
+	pod, err := r.findCloneSourcePod(pvc)
+	if err != nil {
+		cleanup.errs = append(cleanup.errs, err)
+	} else if pod != nil {
+		cleanup.delete("pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{}, nil)
+	}
+	cleanup.removeFinalizers()
+	return cleanup.done(r.recorder)
+}
+
+// keepCompletedSourcePod returns true if the source pod of the clone to pvc succeeded and is kept for the completed
+// pod retention
+func (r *CloneReconciler) keepCompletedSourcePod(pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod) (bool, error) {
+	if !podSucceededFromPVC(pvc) || pod.Status.Phase != corev1.PodSucceeded || pvc.DeletionTimestamp != nil {
+		return false, nil
+	}
+	retention, _, err := getCompletedPodRetention(r.Client)
+	return retention > 0, err
 }
 
 // CreateCloneSourcePod creates our cloning src pod which will be used for out of band cloning to read the contents of the src PVC
@@ -397,18 +543,51 @@ func (r *CloneReconciler) CreateCloneSourcePod(image, pullPolicy, clientName str
 		return nil, err
 	}
 
+	// Prometheus checks the name of the certificate against the name in the PodMonitor of the source pods
+	metricsCert, metricsKey, err := r.metricsCertGenerator.MakeServerCert(sourcePvcNamespace, common.ClonerSourcePodName, metricsServerCertDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	metricsClientCABundle, err := r.metricsClientCAFetcher.BundleBytes()
+	if err != nil {
+		return nil, err
+	}
+
 	podResourceRequirements, err := GetDefaultPodResourceRequirements(r.Client)
 	if err != nil {
 		return nil, err
 	}
 
-	pod := MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace, ownerKey, clientKey, clientCert, serverCABundle, pvc, podResourceRequirements)
+	priorityClassName, err := getPriorityClassName(r.K8sClient, pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	sourcePvc, err := r.getCloneRequestSourcePVC(pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	pod := MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace, ownerKey, clientKey, clientCert, serverCABundle,
+		metricsKey, metricsCert, metricsClientCABundle, pvc, getVolumeMode(sourcePvc), podResourceRequirements, priorityClassName)
+	if err := setServiceMeshAnnotations(r.Client, pod); err != nil {
+		return nil, err
+	}
+	if err := setSecurityProfiles(r.Client, pod, clonerSeccompProfile); err != nil {
+		return nil, err
+	}
+	if err := setCloneCompression(r.Client, pod); err != nil {
+		return nil, err
+	}
+	setCloneDelta(pod, sourcePvc, pvc)
 
-	if err := r.Client.Create(context.TODO(), pod); err != nil {
+	pod, err = createPodIfNotExists(r.Client, pod)
+	if err != nil {
 		return nil, errors.Wrap(err, "source pod API create errored")
 	}
 
-	log.V(1).Info("cloning source pod (image) created\n", "pod.Namespace", pod.Namespace, "pod.Name", pod.Name, "image", image)
+	log.V(1).Info("cloning source pod (image) created", logKeyPod, logObjectKey(pod), "image", image)
 
 	return pod, nil
 }
@@ -417,13 +596,15 @@ func getCloneSourcePodName(targetPvc *corev1.PersistentVolumeClaim) string {
 	return string(targetPvc.GetUID()) + "-source-pod"
 }
 
-// MakeCloneSourcePodSpec creates and returns the clone source pod spec based on the target pvc.
+// MakeCloneSourcePodSpec creates and returns the clone source pod spec based on the target pvc, mounting the source pvc
+// with sourceVolumeMode.
 func MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace, ownerRefAnno string,
-	clientKey, clientCert, serverCACert []byte, targetPvc *corev1.PersistentVolumeClaim, resourceRequirements *corev1.ResourceRequirements) *corev1.Pod {
+	clientKey, clientCert, serverCACert, metricsKey, metricsCert, metricsClientCACert []byte, targetPvc *corev1.PersistentVolumeClaim, sourceVolumeMode corev1.PersistentVolumeMode,
+	resourceRequirements *corev1.ResourceRequirements, priorityClassName string) *corev1.Pod {
 
 	var ownerID string
 	podName := getCloneSourcePodName(targetPvc)
-	url := GetUploadServerURL(targetPvc.Namespace, targetPvc.Name, common.UploadPathSync)
+	url := GetUploadServerURL(targetPvc.Namespace, targetPvc.Name, common.UploadPathChunked)
 	pvcOwner := metav1.GetControllerOf(targetPvc)
 	if pvcOwner != nil && pvcOwner.Kind == "DataVolume" {
 		ownerID = string(pvcOwner.UID)
@@ -451,9 +632,10 @@ func MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace
 			},
 			Containers: []corev1.Container{
 				{
-					Name:            common.ClonerSourcePodName,
-					Image:           image,
-					ImagePullPolicy: corev1.PullPolicy(pullPolicy),
+					Name:                     common.ClonerSourcePodName,
+					Image:                    image,
+					ImagePullPolicy:          corev1.PullPolicy(pullPolicy),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 					Env: []corev1.EnvVar{
 						/*
 						 Easier to just stick key/certs in env vars directly no.
@@ -471,6 +653,18 @@ func MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace
 							Name:  "SERVER_CA_CERT",
 							Value: string(serverCACert),
 						},
+						{
+							Name:  "METRICS_KEY",
+							Value: string(metricsKey),
+						},
+						{
+							Name:  "METRICS_CERT",
+							Value: string(metricsCert),
+						},
+						{
+							Name:  "METRICS_CLIENT_CA_CERT",
+							Value: string(metricsClientCACert),
+						},
 						{
 							Name:  "UPLOAD_URL",
 							Value: url,
@@ -489,7 +683,8 @@ func MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace
 					},
 				},
 			},
-			RestartPolicy: corev1.RestartPolicyOnFailure,
+			RestartPolicy:     corev1.RestartPolicyOnFailure,
+			PriorityClassName: priorityClassName,
 			Volumes: []corev1.Volume{
 				{
 					Name: DataVolName,
@@ -508,16 +703,9 @@ func MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace
 		pod.Spec.Containers[0].Resources = *resourceRequirements
 	}
 
-	var volumeMode corev1.PersistentVolumeMode
 	var addVars []corev1.EnvVar
 
-	if targetPvc.Spec.VolumeMode != nil {
-		volumeMode = *targetPvc.Spec.VolumeMode
-	} else {
-		volumeMode = corev1.PersistentVolumeFilesystem
-	}
-
-	if volumeMode == corev1.PersistentVolumeBlock {
+	if sourceVolumeMode == corev1.PersistentVolumeBlock {
 		pod.Spec.Containers[0].VolumeDevices = addVolumeDevices()
 		addVars = []corev1.EnvVar{
 			{
@@ -548,7 +736,23 @@ func MakeCloneSourcePodSpec(image, pullPolicy, sourcePvcName, sourcePvcNamespace
 		}
 	}
 
+	if targetVolumeMode := getVolumeMode(targetPvc); targetVolumeMode != sourceVolumeMode {
+		// The source of a clone between volume modes streams the disk image of a file system volume instead of its files
+		addVars = append(addVars, corev1.EnvVar{
+			Name:  common.CloneTargetVolumeMode,
+			Value: strings.ToLower(string(targetVolumeMode)),
+		})
+	}
+
+	if shouldVerifyClone(targetPvc) {
+		addVars = append(addVars, corev1.EnvVar{
+			Name:  common.CloneVerifyContent,
+			Value: "true",
+		})
+	}
+
 	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, addVars...)
+	setReadOnlyRootFilesystem(pod)
 
 	return pod
 }
@@ -594,27 +798,16 @@ func ParseCloneRequestAnnotation(pvc *corev1.PersistentVolumeClaim) (exists bool
 	return
 }
 
-// ValidateCanCloneSourceAndTargetSpec validates the specs passed in are compatible for cloning.
+// ValidateCanCloneSourceAndTargetSpec validates the specs passed in are compatible for cloning. The volume modes may
+// differ, the DataVolume controller sizes the target of such a clone with cloneTargetSize, and the upload server
+// refuses a target too small for the source before the data is transferred.
 func ValidateCanCloneSourceAndTargetSpec(sourceSpec, targetSpec *corev1.PersistentVolumeClaimSpec) error {
 	sourceRequest := sourceSpec.Resources.Requests[corev1.ResourceStorage]
 	targetRequest := targetSpec.Resources.Requests[corev1.ResourceStorage]
-	// Verify that the target PVC size is equal or larger than the source.
-	if sourceRequest.Value() > targetRequest.Value() {
+	// Verify that the target PVC size is equal or larger than the source, in the same volume mode.
+	if volumeModeOfSpec(sourceSpec) == volumeModeOfSpec(targetSpec) && sourceRequest.Value() > targetRequest.Value() {
 		return errors.New("target resources requests storage size is smaller than the source")
 	}
-	// Verify that the source and target volume modes are the same.
-	sourceVolumeMode := corev1.PersistentVolumeFilesystem
-	if sourceSpec.VolumeMode != nil && *sourceSpec.VolumeMode == corev1.PersistentVolumeBlock {
-		sourceVolumeMode = corev1.PersistentVolumeBlock
-	}
-	targetVolumeMode := corev1.PersistentVolumeFilesystem
-	if targetSpec.VolumeMode != nil && *targetSpec.VolumeMode == corev1.PersistentVolumeBlock {
-		targetVolumeMode = corev1.PersistentVolumeBlock
-	}
-	if sourceVolumeMode != targetVolumeMode {
-		return fmt.Errorf("source volumeMode (%s) and target volumeMode (%s) do not match",
-			sourceVolumeMode, targetVolumeMode)
-	}
 	// Can clone.
 	return nil
 }
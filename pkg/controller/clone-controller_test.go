@@ -20,6 +20,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"net/url"
 	"sync"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -57,7 +59,7 @@ func (cg *fakeCertGenerator) MakeClientCert(name string, groups []string, durati
 	return []byte("foo"), []byte("bar"), nil
 }
 
-func (cg *fakeCertGenerator) MakeServerCert(namespace, service string, duration time.Duration) ([]byte, []byte, error) {
+func (cg *fakeCertGenerator) MakeServerCert(namespace, service string, duration time.Duration, uris ...*url.URL) ([]byte, []byte, error) {
 	return []byte("foo"), []byte("bar"), nil
 }
 
@@ -143,6 +145,87 @@ var _ = Describe("Clone controller reconcile loop", func() {
 		Expect(reconciler.hasFinalizer(testPvc, cloneSourcePodFinalizer)).To(BeTrue())
 	})
 
+	It("Should record a clone token that expired before the source pod was created", func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		expired, err := token.NewGenerator(common.CloneTokenIssuer, key, -time.Minute).Generate(&token.Payload{})
+		Expect(err).ToNot(HaveOccurred())
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: expired, AnnUploadClientName: "uploadclient"}, nil)
+		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
+		reconciler.tokenValidator = newCloneTokenValidator(token.PublicKeyList{&key.PublicKey})
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).To(HaveOccurred())
+		resultPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resultPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resultPvc.GetAnnotations()).To(HaveKey(AnnCloneTokenExpired))
+		Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(CloneTokenExpired))
+		sourcePod, err := reconciler.findCloneSourcePod(testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).To(BeNil())
+	})
+
+	It("Should count a new attempt if the source pod of a running clone is gone", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient",
+			AnnPopulationPhase: string(populationRunning), AnnPopulationAttempts: "1", AnnPopulationStrategy: populationStrategyHostAssistedClone}, nil)
+		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
+		reconciler.tokenValidator.(*FakeValidator).match = "foobaz"
+		reconciler.tokenValidator.(*FakeValidator).Name = "source"
+		reconciler.tokenValidator.(*FakeValidator).Namespace = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetNamespace"] = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetName"] = "testPvc1"
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		sourcePod, err := reconciler.findCloneSourcePod(testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).ToNot(BeNil())
+		resultPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resultPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resultPvc.GetAnnotations()[AnnPopulationPhase]).To(Equal(string(populationRunning)))
+		Expect(resultPvc.GetAnnotations()[AnnPopulationAttempts]).To(Equal("2"))
+	})
+
+	It("Should not create a source pod for a clone that succeeded", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient",
+			AnnPopulationPhase: string(populationSucceeded)}, nil)
+		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		sourcePod, err := reconciler.findCloneSourcePod(testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).To(BeNil())
+	})
+
+	It("Should return the existing source pod if an earlier reconcile created it", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
+		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
+		firstPod, err := reconciler.CreateCloneSourcePod(testImage, testPullPolicy, "uploadclient", testPvc, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		secondPod, err := reconciler.CreateCloneSourcePod(testImage, testPullPolicy, "uploadclient", testPvc, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secondPod.Name).To(Equal(firstPod.Name))
+		By("Verifying only one source pod exists")
+		podList := &corev1.PodList{}
+		Expect(reconciler.Client.List(context.TODO(), podList, &client.ListOptions{Namespace: "default"})).To(Succeed())
+		Expect(podList.Items).To(HaveLen(1))
+	})
+
+	It("Should pass the signed metrics certificate and the metrics client CA to the source pod", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
+		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
+		pod, err := reconciler.CreateCloneSourcePod(testImage, testPullPolicy, "uploadclient", testPvc, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "METRICS_KEY", Value: "bar"}))
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "METRICS_CERT", Value: "foo"}))
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "METRICS_CLIENT_CA_CERT", Value: "qux"}))
+	})
+
 	It("Should error with missing upload client name annotation if none provided", func() {
 		testPvc := createPvc("testPvc1", "default", map[string]string{
 			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz"}, nil)
@@ -231,6 +314,45 @@ var _ = Describe("Clone controller reconcile loop", func() {
 		Expect(sourcePod).To(BeNil())
 	})
 
+	It("Should delete the source pod before the finalizer if the target is deleted mid-clone", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
+		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
+		reconciler.tokenValidator.(*FakeValidator).match = "foobaz"
+		reconciler.tokenValidator.(*FakeValidator).Name = "source"
+		reconciler.tokenValidator.(*FakeValidator).Namespace = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetNamespace"] = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetName"] = "testPvc1"
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		sourcePod, err := reconciler.findCloneSourcePod(testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).ToNot(BeNil())
+		By("Deleting the target PVC")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		now := metav1.Now()
+		testPvc.DeletionTimestamp = &now
+		Expect(reconciler.Client.Update(context.TODO(), testPvc)).To(Succeed())
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(cloneCleanupRetryInterval))
+		sourcePod, err = reconciler.findCloneSourcePod(testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).To(BeNil())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.hasFinalizer(testPvc, cloneSourcePodFinalizer)).To(BeTrue())
+		By("Removing the finalizer once the source pod is gone")
+		result, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		testPvc = &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, testPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.hasFinalizer(testPvc, cloneSourcePodFinalizer)).To(BeFalse())
+	})
+
 	It("Should update the cloneof when complete, block mode", func() {
 		testPvc := createBlockPvc("testPvc1", "default", map[string]string{
 			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
@@ -276,7 +398,7 @@ var _ = Describe("Clone controller reconcile loop", func() {
 		Expect(sourcePod).To(BeNil())
 	})
 
-	It("Should error when source and target volume modes do not match (fs->block)", func() {
+	It("Should clone between volume modes (fs->block)", func() {
 		testPvc := createBlockPvc("testPvc1", "default", map[string]string{
 			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
 		reconciler = createCloneReconciler(testPvc, createPvc("source", "default", map[string]string{}, nil))
@@ -286,15 +408,18 @@ var _ = Describe("Clone controller reconcile loop", func() {
 		reconciler.tokenValidator.(*FakeValidator).Namespace = "default"
 		reconciler.tokenValidator.(*FakeValidator).Params["targetNamespace"] = "default"
 		reconciler.tokenValidator.(*FakeValidator).Params["targetName"] = "testPvc1"
-		By("Verifying no source pod exists")
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		By("Verifying the source pod mounts the file system of the source")
 		sourcePod, err := reconciler.findCloneSourcePod(testPvc)
-		Expect(sourcePod).To(BeNil())
-		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
-		Expect(err).To(HaveOccurred())
-		Expect(err.Error()).To(ContainSubstring("source volumeMode (Filesystem) and target volumeMode (Block) do not match"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).ToNot(BeNil())
+		Expect(sourcePod.Spec.Containers[0].VolumeMounts).ToNot(BeEmpty())
+		Expect(sourcePod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "VOLUME_MODE", Value: "filesystem"}))
+		Expect(sourcePod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.CloneTargetVolumeMode, Value: "block"}))
 	})
 
-	It("Should error when source and target volume modes do not match (fs->block)", func() {
+	It("Should clone between volume modes (block->fs)", func() {
 		testPvc := createPvc("testPvc1", "default", map[string]string{
 			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
 		reconciler = createCloneReconciler(testPvc, createBlockPvc("source", "default", map[string]string{}, nil))
@@ -304,12 +429,32 @@ var _ = Describe("Clone controller reconcile loop", func() {
 		reconciler.tokenValidator.(*FakeValidator).Namespace = "default"
 		reconciler.tokenValidator.(*FakeValidator).Params["targetNamespace"] = "default"
 		reconciler.tokenValidator.(*FakeValidator).Params["targetName"] = "testPvc1"
-		By("Verifying no source pod exists")
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		By("Verifying the source pod reads the block device of the source")
 		sourcePod, err := reconciler.findCloneSourcePod(testPvc)
-		Expect(sourcePod).To(BeNil())
-		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).ToNot(BeNil())
+		Expect(sourcePod.Spec.Containers[0].VolumeDevices).ToNot(BeEmpty())
+		Expect(sourcePod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "VOLUME_MODE", Value: "block"}))
+		Expect(sourcePod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.CloneTargetVolumeMode, Value: "filesystem"}))
+	})
+
+	It("Should error when the target is smaller than the source", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneRequest: "default/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil)
+		sourcePvc := createPvc("source", "default", map[string]string{}, nil)
+		sourcePvc.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("2G")
+		reconciler = createCloneReconciler(testPvc, sourcePvc)
+		By("Setting up the match token")
+		reconciler.tokenValidator.(*FakeValidator).match = "foobaz"
+		reconciler.tokenValidator.(*FakeValidator).Name = "source"
+		reconciler.tokenValidator.(*FakeValidator).Namespace = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetNamespace"] = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetName"] = "testPvc1"
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
 		Expect(err).To(HaveOccurred())
-		Expect(err.Error()).To(ContainSubstring("source volumeMode (Block) and target volumeMode (Filesystem) do not match"))
+		Expect(err.Error()).To(ContainSubstring("target resources requests storage size is smaller than the source"))
 	})
 })
 
@@ -468,6 +613,9 @@ func createCloneReconciler(objects ...runtime.Object) *CloneReconciler {
 		Image:               testImage,
 		clientCertGenerator: &fakeCertGenerator{},
 		serverCAFetcher:     &fetcher.MemCertBundleFetcher{Bundle: []byte("baz")},
+
+		metricsCertGenerator:   &fakeCertGenerator{},
+		metricsClientCAFetcher: &fetcher.MemCertBundleFetcher{Bundle: []byte("qux")},
 	}
 }
 
@@ -601,7 +749,7 @@ func createSourcePod(pvc *corev1.PersistentVolumeClaim, pvcUID string) *corev1.P
 						},
 						{
 							Name:  "UPLOAD_URL",
-							Value: GetUploadServerURL(pvc.Namespace, pvc.Name, common.UploadPathSync),
+							Value: GetUploadServerURL(pvc.Namespace, pvc.Name, common.UploadPathChunked),
 						},
 						{
 							Name:  common.OwnerUID,
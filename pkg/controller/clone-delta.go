@@ -0,0 +1,24 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// setCloneDelta tells a clone source pod to send only the blocks that differ from the target, if both PVCs are block
+// devices the same content was written to, as their content digests tell. The target then holds an earlier copy of
+// the source, as the digest is of what was written, not of what the PVC holds now.
+func setCloneDelta(pod *corev1.Pod, source, target *corev1.PersistentVolumeClaim) {
+	if getVolumeMode(source) != corev1.PersistentVolumeBlock || getVolumeMode(target) != corev1.PersistentVolumeBlock {
+		return
+	}
+	digest := source.Annotations[AnnContentDigest]
+	if digest == "" || digest != target.Annotations[AnnContentDigest] {
+		return
+	}
+	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  common.CloneDelta,
+		Value: "true",
+	})
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Clone delta", func() {
+	digest := map[string]string{AnnContentDigest: "sha256:0123"}
+
+	table.DescribeTable("Should send only the blocks that differ", func(source, target *corev1.PersistentVolumeClaim, expected bool) {
+		pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: common.ClonerSourcePodName}}}}
+		setCloneDelta(pod, source, target)
+		if expected {
+			Expect(pod.Spec.Containers[0].Env).To(ConsistOf(corev1.EnvVar{Name: common.CloneDelta, Value: "true"}))
+		} else {
+			Expect(pod.Spec.Containers[0].Env).To(BeEmpty())
+		}
+	},
+		table.Entry("if block devices have the same content digest", createBlockPvc("source", "default", digest, nil), createBlockPvc("target", "default", digest, nil), true),
+		table.Entry("but not if the target has another content digest", createBlockPvc("source", "default", digest, nil),
+			createBlockPvc("target", "default", map[string]string{AnnContentDigest: "sha256:4567"}, nil), false),
+		table.Entry("but not if the target was not written", createBlockPvc("source", "default", digest, nil), createBlockPvc("target", "default", nil, nil), false),
+		table.Entry("but not without content digests", createBlockPvc("source", "default", nil, nil), createBlockPvc("target", "default", nil, nil), false),
+		table.Entry("but not for filesystem volumes", createPvc("source", "default", digest, nil), createPvc("target", "default", digest, nil), false),
+	)
+})
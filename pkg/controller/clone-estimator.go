@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// AnnCloneEstimate requests an estimate of cloning the PVC, its value is the network bandwidth per second the
+	// duration is estimated for, like 100Mi, or true for the default
+	AnnCloneEstimate = AnnAPIGroup + "/storage.clone.estimate"
+	// AnnCloneEstimatePhase is the phase of the estimate, Running, Succeeded or Failed, remove it to estimate again
+	AnnCloneEstimatePhase = AnnAPIGroup + "/storage.clone.estimate.phase"
+	// AnnCloneEstimateAllocatedBytes is how much of the PVC is allocated, the data a clone reads
+	AnnCloneEstimateAllocatedBytes = AnnAPIGroup + "/storage.clone.estimate.allocatedBytes"
+	// AnnCloneEstimateTransferBytes is how much data a host-assisted clone of the PVC sends over the network
+	AnnCloneEstimateTransferBytes = AnnAPIGroup + "/storage.clone.estimate.transferBytes"
+	// AnnCloneEstimateDuration is how long a host-assisted clone of the PVC takes at the bandwidth of the request
+	AnnCloneEstimateDuration = AnnAPIGroup + "/storage.clone.estimate.duration"
+
+	// CloneEstimated is the event of a PVC whose clone was estimated
+	CloneEstimated = "CloneEstimated"
+	// CloneEstimateFailed is the event of a PVC whose clone could not be estimated
+	CloneEstimateFailed = "CloneEstimateFailed"
+
+	cloneEstimatePodSuffix = "-clone-estimate"
+)
+
+// defaultCloneEstimateBandwidth is the network bandwidth per second clones are estimated for if the request does not
+// tell one
+var defaultCloneEstimateBandwidth = resource.MustParse("100Mi")
+
+// CloneEstimator estimates clones of the PVCs annotated with AnnCloneEstimate, with a pod that samples the PVC
+// instead of cloning it
+type CloneEstimator struct {
+	Client     client.Client
+	Log        logr.Logger
+	Image      string
+	PullPolicy string
+	recorder   record.EventRecorder
+}
+
+// NewCloneEstimator creates a new instance of the clone estimator.
+func NewCloneEstimator(mgr manager.Manager, log logr.Logger, image, pullPolicy string) (controller.Controller, error) {
+	reconciler := &CloneEstimator{
+		Client:     mgr.GetClient(),
+		Log:        newSampledLogger(log.WithName("clone-estimator")),
+		Image:      image,
+		PullPolicy: pullPolicy,
+		recorder:   mgr.GetEventRecorderFor("clone-estimator"),
+	}
+	cloneEstimator, err := controller.New("clone-estimator", mgr, controller.Options{
+		Reconciler: reconciler,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cloneEstimator.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return nil, err
+	}
+	if err := cloneEstimator.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &corev1.PersistentVolumeClaim{},
+		IsController: true,
+	}); err != nil {
+		return nil, err
+	}
+	return cloneEstimator, nil
+}
+
+// getCloneEstimateBandwidth returns the network bandwidth per second the estimate of the PVC is for
+func getCloneEstimateBandwidth(pvc *corev1.PersistentVolumeClaim) (int64, error) {
+	value := pvc.Annotations[AnnCloneEstimate]
+	if value == "" || value == "true" {
+		return defaultCloneEstimateBandwidth.Value(), nil
+	}
+	bandwidth, err := resource.ParseQuantity(value)
+	if err != nil || bandwidth.Value() <= 0 {
+		return 0, errors.Errorf("invalid bandwidth %q", value)
+	}
+	return bandwidth.Value(), nil
+}
+
+// estimateCloneDuration returns how long a clone takes to send transferBytes at bandwidth, or to compress readBytes
+// at compressRate if that takes longer
+func estimateCloneDuration(readBytes, transferBytes, compressRate, bandwidth int64) time.Duration {
+	duration := time.Duration(float64(transferBytes) / float64(bandwidth) * float64(time.Second))
+	if compressRate > 0 {
+		if compressDuration := time.Duration(float64(readBytes) / float64(compressRate) * float64(time.Second)); compressDuration > duration {
+			duration = compressDuration
+		}
+	}
+	return duration.Round(time.Second)
+}
+
+// Reconcile estimates the clone of a PVC
+func (r *CloneEstimator) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log := r.Log.WithValues(logKeyPVC, req.NamespacedName)
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), req.NamespacedName, pvc); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if _, ok := pvc.Annotations[AnnCloneEstimate]; !ok || pvc.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+	phase := pvc.Annotations[AnnCloneEstimatePhase]
+	if phase == string(corev1.PodSucceeded) || phase == string(corev1.PodFailed) {
+		return reconcile.Result{}, nil
+	}
+	log.V(1).Info("reconciling clone estimate", logKeyPhase, phase)
+
+	bandwidth, err := getCloneEstimateBandwidth(pvc)
+	if err != nil {
+		return reconcile.Result{}, r.fail(pvc, err.Error())
+	}
+
+	pod := &corev1.Pod{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name + cloneEstimatePodSuffix}, pod)
+	if k8serrors.IsNotFound(err) {
+		return reconcile.Result{}, r.createEstimatePod(pvc, log)
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if owner := metav1.GetControllerOf(pod); owner == nil || owner.UID != pvc.UID {
+		return reconcile.Result{}, errors.Errorf("pod %s/%s already exists and is not owned by the PVC", pod.Namespace, pod.Name)
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		message, ok := succeededTerminationMessage(pod)
+		if !ok {
+			return reconcile.Result{}, r.fail(pvc, "The clone estimate pod wrote no estimate")
+		}
+		if err := r.succeed(pvc, message, bandwidth); err != nil {
+			return reconcile.Result{}, err
+		}
+	case corev1.PodFailed:
+		reason := "The clone estimate pod failed"
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil {
+				reason = terminationMessage(terminated).Message
+			}
+		}
+		if err := r.fail(pvc, reason); err != nil {
+			return reconcile.Result{}, err
+		}
+	default:
+		return reconcile.Result{}, nil
+	}
+	log.V(1).Info("Clone estimate done, deleting pod", logKeyPod, logObjectKey(pod))
+	if err := r.Client.Delete(context.TODO(), pod); err != nil && !k8serrors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+func (r *CloneEstimator) createEstimatePod(pvc *corev1.PersistentVolumeClaim, log logr.Logger) error {
+	podResourceRequirements, err := GetDefaultPodResourceRequirements(r.Client)
+	if err != nil {
+		return err
+	}
+	pod := makeCloneEstimatePodSpec(r.Image, r.PullPolicy, pvc, podResourceRequirements)
+	if err := setServiceMeshAnnotations(r.Client, pod); err != nil {
+		return err
+	}
+	if err := setSecurityProfiles(r.Client, pod, clonerSeccompProfile); err != nil {
+		return err
+	}
+	if _, err := createPodIfNotExists(r.Client, pod); err != nil {
+		return errors.Wrap(err, "clone estimate pod API create errored")
+	}
+	log.V(1).Info("clone estimate pod created", logKeyPod, logObjectKey(pod), "image", r.Image)
+	return r.setPhase(pvc, string(corev1.PodRunning), nil)
+}
+
+func (r *CloneEstimator) succeed(pvc *corev1.PersistentVolumeClaim, message *util.TerminationMessage, bandwidth int64) error {
+	readBytes := message.AllocatedBytes
+	if getVolumeMode(pvc) == corev1.PersistentVolumeBlock {
+		// The whole block device is read and compressed
+		readBytes = message.Bytes
+	}
+	duration := estimateCloneDuration(readBytes, message.TransferBytes, message.CompressRate, bandwidth)
+	if err := r.setPhase(pvc, string(corev1.PodSucceeded), map[string]string{
+		AnnCloneEstimateAllocatedBytes: fmt.Sprintf("%d", message.AllocatedBytes),
+		AnnCloneEstimateTransferBytes:  fmt.Sprintf("%d", message.TransferBytes),
+		AnnCloneEstimateDuration:       duration.String(),
+	}); err != nil {
+		return err
+	}
+	r.recorder.Event(pvc, corev1.EventTypeNormal, CloneEstimated, fmt.Sprintf("A clone reads %s, sends %s and takes %s at %s/s",
+		resource.NewQuantity(readBytes, resource.BinarySI), resource.NewQuantity(message.TransferBytes, resource.BinarySI), duration,
+		resource.NewQuantity(bandwidth, resource.BinarySI)))
+	return nil
+}
+
+func (r *CloneEstimator) fail(pvc *corev1.PersistentVolumeClaim, reason string) error {
+	if err := r.setPhase(pvc, string(corev1.PodFailed), nil); err != nil {
+		return err
+	}
+	r.recorder.Event(pvc, corev1.EventTypeWarning, CloneEstimateFailed, reason)
+	return nil
+}
+
+// setPhase sets the phase of the estimate of pvc with results, the results of an earlier estimate are removed
+func (r *CloneEstimator) setPhase(pvc *corev1.PersistentVolumeClaim, phase string, results map[string]string) error {
+	pvcCopy := pvc.DeepCopy()
+	for _, key := range []string{AnnCloneEstimateAllocatedBytes, AnnCloneEstimateTransferBytes, AnnCloneEstimateDuration} {
+		delete(pvcCopy.Annotations, key)
+	}
+	pvcCopy.Annotations[AnnCloneEstimatePhase] = phase
+	for key, value := range results {
+		pvcCopy.Annotations[key] = value
+	}
+	return r.Client.Update(context.TODO(), pvcCopy)
+}
+
+// makeCloneEstimatePodSpec returns the spec of the pod that samples pvc, the clone source image in estimate mode
+func makeCloneEstimatePodSpec(image, pullPolicy string, pvc *corev1.PersistentVolumeClaim, resourceRequirements *corev1.ResourceRequirements) *corev1.Pod {
+	volumeMode := "filesystem"
+	mountPoint := common.ClonerMountPath
+	if getVolumeMode(pvc) == corev1.PersistentVolumeBlock {
+		volumeMode, mountPoint = "block", common.WriteBlockPath
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvc.Name + cloneEstimatePodSuffix,
+			Namespace: pvc.Namespace,
+			Annotations: map[string]string{
+				AnnCreatedBy: "yes",
+			},
+			Labels: map[string]string{
+				common.CDILabelKey:       common.CDILabelValue,
+				common.CDIComponentLabel: common.CloneEstimatePodName,
+			},
+			OwnerReferences: []metav1.OwnerReference{MakePVCOwnerReference(pvc)},
+		},
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser: &[]int64{0}[0],
+			},
+			Containers: []corev1.Container{
+				{
+					Name:                     common.CloneEstimatePodName,
+					Image:                    image,
+					ImagePullPolicy:          corev1.PullPolicy(pullPolicy),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					Env: []corev1.EnvVar{
+						{
+							Name:  "VOLUME_MODE",
+							Value: volumeMode,
+						},
+						{
+							Name:  "MOUNT_POINT",
+							Value: mountPoint,
+						},
+						{
+							Name:  "ESTIMATE",
+							Value: "true",
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+			Volumes: []corev1.Volume{
+				{
+					Name: DataVolName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: pvc.Name,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+		},
+	}
+	if resourceRequirements != nil {
+		pod.Spec.Containers[0].Resources = *resourceRequirements
+	}
+	if volumeMode == "block" {
+		pod.Spec.Containers[0].VolumeDevices = addVolumeDevices()
+	} else {
+		pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      DataVolName,
+				MountPath: common.ClonerMountPath,
+				ReadOnly:  true,
+			},
+		}
+	}
+	setReadOnlyRootFilesystem(pod)
+	return pod
+}
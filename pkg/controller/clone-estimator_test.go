@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var cloneEstimatorLog = logf.Log.WithName("clone-estimator-test")
+
+var _ = Describe("Clone estimate duration", func() {
+	table.DescribeTable("should be", func(readBytes, transferBytes, compressRate, bandwidth int64, expected time.Duration) {
+		Expect(estimateCloneDuration(readBytes, transferBytes, compressRate, bandwidth)).To(Equal(expected))
+	},
+		table.Entry("the network time", int64(1<<30), int64(100<<20), int64(1<<30), int64(10<<20), 10*time.Second),
+		table.Entry("the compress time if it is longer", int64(1<<30), int64(100<<20), int64(10<<20), int64(100<<20), 102*time.Second),
+		table.Entry("the network time without a compress rate", int64(1<<30), int64(100<<20), int64(0), int64(100<<20), time.Second),
+		table.Entry("zero for an empty source", int64(0), int64(0), int64(1<<20), int64(1<<20), time.Duration(0)),
+	)
+})
+
+var _ = Describe("Clone estimate bandwidth", func() {
+	table.DescribeTable("should parse", func(value string, expected int64, expectErr bool) {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnCloneEstimate: value}, nil)
+		bandwidth, err := getCloneEstimateBandwidth(pvc)
+		Expect(err != nil).To(Equal(expectErr))
+		Expect(bandwidth).To(Equal(expected))
+	},
+		table.Entry("the default", "true", defaultCloneEstimateBandwidth.Value(), false),
+		table.Entry("a quantity", "1Gi", int64(1<<30), false),
+		table.Entry("not a quantity", "fast", int64(0), true),
+		table.Entry("not a positive quantity", "0", int64(0), true),
+	)
+})
+
+var _ = Describe("Clone estimator reconcile", func() {
+	var reconciler *CloneEstimator
+
+	AfterEach(func() {
+		if reconciler != nil {
+			close(reconciler.recorder.(*record.FakeRecorder).Events)
+			reconciler = nil
+		}
+	})
+
+	reconcilePvc := func(pvc *corev1.PersistentVolumeClaim) {
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	getPvc := func(pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+		result := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, result)).To(Succeed())
+		return result
+	}
+
+	getPod := func(pvc *corev1.PersistentVolumeClaim) (*corev1.Pod, error) {
+		pod := &corev1.Pod{}
+		err := reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name + cloneEstimatePodSuffix, Namespace: pvc.Namespace}, pod)
+		return pod, err
+	}
+
+	terminatedPod := func(pvc *corev1.PersistentVolumeClaim, phase corev1.PodPhase, exitCode int32, message string) *corev1.Pod {
+		pod := makeCloneEstimatePodSpec("cloner", "Always", pvc, nil)
+		pod.Status.Phase = phase
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: message},
+				},
+			},
+		}
+		return pod
+	}
+
+	It("should ignore a PVC without the annotation", func() {
+		pvc := createPvc("testPvc1", "default", nil, nil)
+		reconciler = createCloneEstimator(pvc)
+		reconcilePvc(pvc)
+		_, err := getPod(pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should create a pod that mounts the PVC read only", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnCloneEstimate: "true"}, nil)
+		reconciler = createCloneEstimator(pvc)
+		reconcilePvc(pvc)
+
+		pod, err := getPod(pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metav1.GetControllerOf(pod).UID).To(Equal(pvc.UID))
+		Expect(pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(pvc.Name))
+		Expect(pod.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+		Expect(pod.Spec.Containers[0].VolumeMounts[0].ReadOnly).To(BeTrue())
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "ESTIMATE", Value: "true"}))
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "MOUNT_POINT", Value: common.ClonerMountPath}))
+		Expect(getPvc(pvc).Annotations[AnnCloneEstimatePhase]).To(Equal(string(corev1.PodRunning)))
+	})
+
+	It("should map a block PVC to a device", func() {
+		pvc := createBlockPvc("testPvc1", "default", map[string]string{AnnCloneEstimate: "true"}, nil)
+		pod := makeCloneEstimatePodSpec("cloner", "Always", pvc, nil)
+		Expect(pod.Spec.Containers[0].VolumeDevices).To(HaveLen(1))
+		for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+			Expect(mount.Name).ToNot(Equal(DataVolName))
+		}
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "VOLUME_MODE", Value: "block"}))
+	})
+
+	It("should annotate the PVC with the estimate and delete the pod", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneEstimate:      "10Mi",
+			AnnCloneEstimatePhase: string(corev1.PodRunning),
+		}, nil)
+		message, err := json.Marshal(&util.TerminationMessage{
+			Result:         util.TerminationSucceeded,
+			Bytes:          10 << 30,
+			AllocatedBytes: 1 << 30,
+			TransferBytes:  100 << 20,
+			CompressRate:   1 << 30,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		reconciler = createCloneEstimator(pvc, terminatedPod(pvc, corev1.PodSucceeded, 0, string(message)))
+		reconcilePvc(pvc)
+
+		annotations := getPvc(pvc).Annotations
+		Expect(annotations[AnnCloneEstimatePhase]).To(Equal(string(corev1.PodSucceeded)))
+		Expect(annotations[AnnCloneEstimateAllocatedBytes]).To(Equal("1073741824"))
+		Expect(annotations[AnnCloneEstimateTransferBytes]).To(Equal("104857600"))
+		Expect(annotations[AnnCloneEstimateDuration]).To(Equal("10s"))
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(CloneEstimated))
+		_, err = getPod(pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should fail the estimate if the pod failed", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneEstimate:      "true",
+			AnnCloneEstimatePhase: string(corev1.PodRunning),
+		}, nil)
+		message, err := json.Marshal(util.NewTerminationFailure("Unable to estimate the clone", k8serrors.NewBadRequest("no source")))
+		Expect(err).ToNot(HaveOccurred())
+		reconciler = createCloneEstimator(pvc, terminatedPod(pvc, corev1.PodFailed, 1, string(message)))
+		reconcilePvc(pvc)
+
+		Expect(getPvc(pvc).Annotations[AnnCloneEstimatePhase]).To(Equal(string(corev1.PodFailed)))
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(CloneEstimateFailed))
+		Expect(event).To(ContainSubstring("Unable to estimate the clone"))
+		_, err = getPod(pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should fail the estimate of an invalid bandwidth without a pod", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnCloneEstimate: "fast"}, nil)
+		reconciler = createCloneEstimator(pvc)
+		reconcilePvc(pvc)
+
+		Expect(getPvc(pvc).Annotations[AnnCloneEstimatePhase]).To(Equal(string(corev1.PodFailed)))
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("invalid bandwidth"))
+		_, err := getPod(pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should not estimate again until the phase is removed", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{
+			AnnCloneEstimate:              "true",
+			AnnCloneEstimatePhase:         string(corev1.PodSucceeded),
+			AnnCloneEstimateDuration:      "10s",
+			AnnCloneEstimateTransferBytes: "1024",
+		}, nil)
+		reconciler = createCloneEstimator(pvc)
+		reconcilePvc(pvc)
+		_, err := getPod(pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+		pvc = getPvc(pvc)
+		delete(pvc.Annotations, AnnCloneEstimatePhase)
+		Expect(reconciler.Client.Update(context.TODO(), pvc)).To(Succeed())
+		reconcilePvc(pvc)
+		_, err = getPod(pvc)
+		Expect(err).ToNot(HaveOccurred())
+		annotations := getPvc(pvc).Annotations
+		Expect(annotations[AnnCloneEstimatePhase]).To(Equal(string(corev1.PodRunning)))
+		Expect(annotations).ToNot(HaveKey(AnnCloneEstimateDuration))
+		Expect(annotations).ToNot(HaveKey(AnnCloneEstimateTransferBytes))
+	})
+})
+
+func createCloneEstimator(objects ...runtime.Object) *CloneEstimator {
+	cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+	cdiConfig.Status = cdiv1.CDIConfigStatus{
+		DefaultPodResourceRequirements: createDefaultPodResourceRequirements(int64(0), int64(0), int64(0), int64(0)),
+	}
+	objects = append(objects, cdiConfig)
+
+	s := scheme.Scheme
+	cdiv1.AddToScheme(s)
+	return &CloneEstimator{
+		Client:     fake.NewFakeClientWithScheme(s, objects...),
+		Log:        cloneEstimatorLog,
+		Image:      "cloner",
+		PullPolicy: "Always",
+		recorder:   record.NewFakeRecorder(10),
+	}
+}
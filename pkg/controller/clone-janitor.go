@@ -0,0 +1,156 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// cloneJanitorInterval is how often the janitor looks for orphaned clone source pods
+	cloneJanitorInterval = 5 * time.Minute
+	// cloneJanitorGracePeriod is the age a clone source pod needs before the janitor considers it, so it does
+	// not race the clone controller on a target PVC that is not in the cache yet
+	cloneJanitorGracePeriod = time.Minute
+)
+
+var (
+	orphanedCloneSourcePods = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_clone_source_pods_orphaned_total",
+			Help: "The number of clone source pods deleted because their target PVC no longer needs them",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedCloneSourcePods)
+}
+
+// CloneJanitor periodically deletes clone source pods that were left behind, for instance because the
+// controller restarted between creating a source pod and adding the finalizer to its target PVC. Clone
+// source pods live in the namespace of the source PVC, so they are not garbage collected with their target.
+type CloneJanitor struct {
+	Client client.Client
+	Log    logr.Logger
+}
+
+// NewCloneJanitor creates a new clone janitor and adds it to the manager.
+func NewCloneJanitor(mgr manager.Manager, log logr.Logger) (*CloneJanitor, error) {
+	janitor := &CloneJanitor{
+		Client: mgr.GetClient(),
+		Log:    log.WithName("clone-janitor"),
+	}
+	if err := mgr.Add(janitor); err != nil {
+		return nil, err
+	}
+	return janitor, nil
+}
+
+// Start runs the janitor, right away and then every cloneJanitorInterval, until stop is closed.
+func (j *CloneJanitor) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := j.cleanupOrphans(time.Now()); err != nil {
+			j.Log.Error(err, "Unable to clean up orphaned clone source pods")
+		}
+	}, cloneJanitorInterval, stop)
+	return nil
+}
+
+// cleanupOrphans deletes the clone source pods older than the grace period at now that are orphaned
+func (j *CloneJanitor) cleanupOrphans(now time.Time) error {
+	retention, _, err := getCompletedPodRetention(j.Client)
+	if err != nil {
+		return err
+	}
+	pods := &corev1.PodList{}
+	selector := labels.SelectorFromSet(map[string]string{common.CDIComponentLabel: common.ClonerSourcePodName})
+	if err := j.Client.List(context.TODO(), pods, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || now.Sub(pod.CreationTimestamp.Time) < cloneJanitorGracePeriod {
+			continue
+		}
+		if retention > 0 && pod.Status.Phase == corev1.PodSucceeded {
+			// Kept for the completed pod retention, the transfer pod janitor deletes it
+			continue
+		}
+		orphaned, err := j.isOrphaned(pod)
+		if err != nil {
+			return err
+		}
+		if !orphaned {
+			continue
+		}
+		j.Log.Info("Deleting orphaned clone source pod", "namespace", pod.Namespace, "name", pod.Name, "target", pod.Annotations[AnnOwnerRef])
+		if err := j.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return err
+		}
+		orphanedCloneSourcePods.Inc()
+	}
+	return nil
+}
+
+// isOrphaned returns true if the target PVC of a clone source pod no longer exists, was replaced by one with
+// the same name, or no longer needs the pod.
+func (j *CloneJanitor) isOrphaned(pod *corev1.Pod) (bool, error) {
+	ownerRef, ok := pod.Annotations[AnnOwnerRef]
+	if !ok {
+		// Not created by the clone controller
+		return false, nil
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(ownerRef)
+	if err != nil {
+		return false, nil
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := j.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, pvc); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if pod.Labels[CloneUniqueID] != getCloneSourcePodName(pvc) {
+		return true, nil
+	}
+	for _, f := range pvc.Finalizers {
+		if f == cloneSourcePodFinalizer {
+			// The clone controller deletes the pod when it removes the finalizer
+			return false, nil
+		}
+	}
+	cloning := metav1.HasAnnotation(pvc.ObjectMeta, AnnCloneRequest) && !metav1.HasAnnotation(pvc.ObjectMeta, AnnCloneOf)
+	return !cloning, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var cloneJanitorLog = logf.Log.WithName("clone-janitor-test")
+
+var _ = Describe("Clone janitor", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	podExists := func(janitor *CloneJanitor, pod *corev1.Pod) bool {
+		err := janitor.Client.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})
+		if err != nil {
+			Expect(IgnoreNotFound(err)).ToNot(HaveOccurred())
+			return false
+		}
+		return true
+	}
+
+	It("Should delete a source pod whose target PVC does not exist", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", nil, nil)
+		pod := createJanitorSourcePod(pvc, now.Add(-2*cloneJanitorGracePeriod))
+		janitor := createCloneJanitor(pod)
+		orphans := readCounter(orphanedCloneSourcePods)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(podExists(janitor, pod)).To(BeFalse())
+		Expect(readCounter(orphanedCloneSourcePods)).To(Equal(orphans + 1))
+	})
+
+	It("Should delete a source pod of a PVC that was replaced by one with the same name", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", nil, nil)
+		pvc.Finalizers = []string{cloneSourcePodFinalizer}
+		pod := createJanitorSourcePod(pvc, now.Add(-2*cloneJanitorGracePeriod))
+		pvc.UID = "new-pvc-uid"
+		janitor := createCloneJanitor(pvc, pod)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(podExists(janitor, pod)).To(BeFalse())
+	})
+
+	It("Should delete a source pod of a PVC that is done cloning", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", map[string]string{AnnCloneOf: "true"}, nil)
+		pod := createJanitorSourcePod(pvc, now.Add(-2*cloneJanitorGracePeriod))
+		janitor := createCloneJanitor(pvc, pod)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(podExists(janitor, pod)).To(BeFalse())
+	})
+
+	It("Should keep the source pod of a PVC that is cloning", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", nil, nil)
+		pod := createJanitorSourcePod(pvc, now.Add(-2*cloneJanitorGracePeriod))
+		janitor := createCloneJanitor(pvc, pod)
+		orphans := readCounter(orphanedCloneSourcePods)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(podExists(janitor, pod)).To(BeTrue())
+		Expect(readCounter(orphanedCloneSourcePods)).To(Equal(orphans))
+	})
+
+	It("Should keep the source pod of a PVC with the clone source pod finalizer", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", map[string]string{AnnCloneOf: "true"}, nil)
+		pvc.Finalizers = []string{cloneSourcePodFinalizer}
+		pod := createJanitorSourcePod(pvc, now.Add(-2*cloneJanitorGracePeriod))
+		janitor := createCloneJanitor(pvc, pod)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(podExists(janitor, pod)).To(BeTrue())
+	})
+
+	It("Should keep a source pod younger than the grace period", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", nil, nil)
+		pod := createJanitorSourcePod(pvc, now.Add(-cloneJanitorGracePeriod/2))
+		janitor := createCloneJanitor(pod)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(podExists(janitor, pod)).To(BeTrue())
+	})
+})
+
+func createJanitorSourcePod(pvc *corev1.PersistentVolumeClaim, created time.Time) *corev1.Pod {
+	pod := createSourcePod(pvc, string(pvc.GetUID()))
+	pod.Name = getCloneSourcePodName(pvc)
+	pod.Namespace = "source-ns"
+	pod.CreationTimestamp = metav1.NewTime(created)
+	return pod
+}
+
+func createCloneJanitor(objects ...runtime.Object) *CloneJanitor {
+	cdiv1.AddToScheme(scheme.Scheme)
+	return &CloneJanitor{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		Log:    cloneJanitorLog,
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// AnnCloneSizing is a PVC annotation that tells how the storage request of the target of a clone between volume
+	// modes was derived from the size of its source
+	AnnCloneSizing = AnnAPIGroup + "/storage.clone.sizing"
+)
+
+// volumeModeOfSpec returns the volume mode of a PVC spec, file system if it names none
+func volumeModeOfSpec(spec *corev1.PersistentVolumeClaimSpec) corev1.PersistentVolumeMode {
+	if spec.VolumeMode != nil {
+		return *spec.VolumeMode
+	}
+	return corev1.PersistentVolumeFilesystem
+}
+
+// cloneTargetSize returns the storage the target of a clone needs to hold the disk image of its source when their
+// volume modes differ, 0 when they are the same. A file system takes util.FilesystemOverhead of its volume for
+// itself, so the image of a block volume needs a larger file system volume, and the image of a file system volume
+// written by CDI fits a smaller block volume.
+func cloneTargetSize(sourceSpec, targetSpec *corev1.PersistentVolumeClaimSpec) int64 {
+	sourceMode := volumeModeOfSpec(sourceSpec)
+	if sourceMode == volumeModeOfSpec(targetSpec) {
+		return 0
+	}
+	sourceRequest := sourceSpec.Resources.Requests[corev1.ResourceStorage]
+	if sourceMode == corev1.PersistentVolumeBlock {
+		return util.MinimumTargetSize(sourceRequest.Value(), true)
+	}
+	imageSize := int64(float64(sourceRequest.Value()) * (1 - util.FilesystemOverhead))
+	return util.MinimumTargetSize(imageSize/util.DiskImageAlignment*util.DiskImageAlignment, false)
+}
+
+// sizeCloneTarget sizes the target PVC of a clone between volume modes for the disk image of its source, and records
+// the decision in the AnnCloneSizing annotation, for the claim status of the DataVolume. The request of the
+// DataVolume is kept when it is large enough.
+func (r *DatavolumeReconciler) sizeCloneTarget(dataVolume *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) error {
+	if dataVolume.Spec.Source.PVC == nil {
+		return nil
+	}
+	namespace := dataVolume.Spec.Source.PVC.Namespace
+	if namespace == "" {
+		namespace = dataVolume.Namespace
+	}
+	source := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: dataVolume.Spec.Source.PVC.Name}, source); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The clone waits for its source, sized as requested
+			return nil
+		}
+		return err
+	}
+	required := cloneTargetSize(&source.Spec, &pvc.Spec)
+	if required == 0 {
+		return nil
+	}
+	sourceRequest := source.Spec.Resources.Requests[corev1.ResourceStorage]
+	request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	requiredQuantity := resource.NewQuantity(required, resource.BinarySI)
+	sizing := fmt.Sprintf("The disk image of the %s %s source needs %s as a %s volume", sourceRequest.String(),
+		volumeModeOfSpec(&source.Spec), requiredQuantity.String(), volumeModeOfSpec(&pvc.Spec))
+	if request.Value() < required {
+		sizing += fmt.Sprintf(", requested instead of %s", request.String())
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = *requiredQuantity
+	} else {
+		sizing += fmt.Sprintf(", %s requested", request.String())
+	}
+	r.Log.V(1).Info("Sizing clone target", "sizing", sizing)
+	if pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string)
+	}
+	pvc.Annotations[AnnCloneSizing] = sizing
+	return nil
+}
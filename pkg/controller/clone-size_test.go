@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Clone sizing", func() {
+	blockMode := corev1.PersistentVolumeBlock
+	filesystemMode := corev1.PersistentVolumeFilesystem
+
+	claimSpec := func(size string, volumeMode corev1.PersistentVolumeMode) *corev1.PersistentVolumeClaimSpec {
+		return &corev1.PersistentVolumeClaimSpec{
+			VolumeMode: &volumeMode,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		}
+	}
+
+	table.DescribeTable("Should size the target for the source", func(sourceMode, targetMode corev1.PersistentVolumeMode, expected int64) {
+		Expect(cloneTargetSize(claimSpec("1Gi", sourceMode), claimSpec("1Gi", targetMode))).To(Equal(expected))
+	},
+		table.Entry("with the file system overhead from block to file system", blockMode, filesystemMode, int64(1084*1024*1024)),
+		table.Entry("without the file system overhead from file system to block", filesystemMode, blockMode, int64(967*1024*1024)),
+		table.Entry("as the source in the same volume mode", blockMode, blockMode, int64(0)),
+	)
+
+	It("Should allow a clone between volume modes", func() {
+		Expect(ValidateCanCloneSourceAndTargetSpec(claimSpec("1Gi", blockMode), claimSpec("1Gi", filesystemMode))).To(Succeed())
+		Expect(ValidateCanCloneSourceAndTargetSpec(claimSpec("1Gi", filesystemMode), claimSpec("1Gi", blockMode))).To(Succeed())
+		Expect(ValidateCanCloneSourceAndTargetSpec(claimSpec("2Gi", blockMode), claimSpec("1Gi", blockMode))).ToNot(Succeed())
+	})
+
+	table.DescribeTable("Should record the sizing of a clone between volume modes", func(request, expectedRequest, expectedSizing string) {
+		dv := newCloneDataVolume("test-dv")
+		dv.Spec.PVC = claimSpec(request, filesystemMode)
+		source := createBlockPvc("test", metav1.NamespaceDefault, nil, nil)
+		source.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("1Gi")
+		reconciler := createDatavolumeReconciler(dv, source)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)).To(Succeed())
+		actual := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(actual.Cmp(resource.MustParse(expectedRequest))).To(BeZero())
+		Expect(pvc.GetAnnotations()[AnnCloneSizing]).To(Equal(expectedSizing))
+
+		updateClaimStatus(dv, pvc, nil)
+		Expect(dv.Status.Claim.CloneSizing).To(Equal(expectedSizing))
+	},
+		table.Entry("growing a request that is too small", "1Gi", "1084Mi",
+			"The disk image of the 1Gi Block source needs 1084Mi as a Filesystem volume, requested instead of 1Gi"),
+		table.Entry("keeping a request that is large enough", "2Gi", "2Gi",
+			"The disk image of the 1Gi Block source needs 1084Mi as a Filesystem volume, 2Gi requested"),
+	)
+
+	It("Should not size a clone in the same volume mode", func() {
+		dv := newCloneDataVolume("test-dv")
+		dv.Spec.PVC = claimSpec("1Gi", filesystemMode)
+		reconciler := createDatavolumeReconciler(dv, createPvc("test", metav1.NamespaceDefault, nil, nil))
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnCloneSizing))
+	})
+})
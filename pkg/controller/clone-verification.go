@@ -0,0 +1,84 @@
+package controller
+
+import (
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// CloneVerified provides a const to indicate the data of a clone matches the checksums of the source
+	CloneVerified = "CloneVerified"
+	// CloneVerificationFailed provides a const to indicate the data of a clone does not match the checksums of the source
+	CloneVerificationFailed = "CloneVerificationFailed"
+	// CloneNotVerified provides a const to indicate a clone that was to be verified succeeded without checksums
+	CloneNotVerified = "CloneNotVerified"
+)
+
+// shouldVerifyClone returns true if the clone to pvc is to be verified against the checksums of the source
+func shouldVerifyClone(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.GetAnnotations()[AnnCloneVerify] == "true"
+}
+
+// cloneVerificationFailedMessage returns the termination message of the upload server in pod if it exited because
+// the data of the clone did not match the checksums of the source
+func cloneVerificationFailedMessage(pod *v1.Pod) (string, bool) {
+	if message, ok := failedTerminationMessage(pod, util.TerminationErrorCloneVerificationFailed); ok {
+		return message.Message, true
+	}
+	return "", false
+}
+
+// cloneVerifiedMessage returns the result of verifying the clone from the termination message of the upload server in
+// pod if it succeeded
+func cloneVerifiedMessage(pod *v1.Pod) string {
+	if message, ok := succeededTerminationMessage(pod); ok {
+		return message.Message
+	}
+	return ""
+}
+
+// updateCloneVerification records the result of verifying the clone to pvc by the upload server in pod in
+// annotations, the annotations of the copy of pvc the caller updates
+func updateCloneVerification(recorder record.EventRecorder, pvc *v1.PersistentVolumeClaim, pod *v1.Pod, annotations map[string]string) {
+	if !shouldVerifyClone(pvc) {
+		return
+	}
+	if message, ok := cloneVerificationFailedMessage(pod); ok && annotations[AnnCloneVerificationFailed] != message {
+		annotations[AnnCloneVerificationFailed] = message
+		recorder.Event(pvc, v1.EventTypeWarning, CloneVerificationFailed, message)
+	}
+	if pod.Status.Phase != v1.PodSucceeded {
+		return
+	}
+	if message := cloneVerifiedMessage(pod); message != "" && annotations[AnnCloneVerified] != message {
+		annotations[AnnCloneVerified] = message
+		delete(annotations, AnnCloneVerificationFailed)
+		recorder.Event(pvc, v1.EventTypeNormal, CloneVerified, message)
+	}
+}
+
+// updateVerifiedCondition reflects the AnnCloneVerified and AnnCloneVerificationFailed annotations of pvc in the
+// Verified condition of dataVolume
+func updateVerifiedCondition(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	if !shouldVerifyClone(pvc) {
+		return
+	}
+	condition := conditions.Condition{Type: cdiv1.DataVolumeVerified, Status: v1.ConditionFalse}
+	if message, ok := pvc.Annotations[AnnCloneVerified]; ok {
+		condition.Status, condition.Reason, condition.Message = v1.ConditionTrue, CloneVerified, message
+	} else if message, ok := pvc.Annotations[AnnCloneVerificationFailed]; ok {
+		condition.Reason, condition.Message = CloneVerificationFailed, message
+	} else if podSucceededFromPVC(pvc) {
+		condition.Reason, condition.Message = CloneNotVerified, "The clone source sent no checksums to verify against"
+	} else {
+		return
+	}
+	current := conditions.FindStatusCondition(dataVolume.Status.Conditions, cdiv1.DataVolumeVerified)
+	if current == nil || current.Status != condition.Status || current.Reason != condition.Reason || current.Message != condition.Message {
+		conditions.SetStatusCondition(&dataVolume.Status.Conditions, condition)
+	}
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Clone verification", func() {
+	createVerifiedPod := func(pvc *corev1.PersistentVolumeClaim, phase corev1.PodPhase, exitCode int32, message string) *corev1.Pod {
+		pod := createUploadPod(pvc)
+		pod.Status.Phase = phase
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: exitCode,
+						Message:  message,
+					},
+				},
+			},
+		}
+		return pod
+	}
+
+	It("Should ask the clone source to send checksums", func() {
+		pvc := createPvc("target", "default", map[string]string{AnnCloneRequest: "default/source", AnnCloneVerify: "true"}, nil)
+		pod := MakeCloneSourcePodSpec("cloner", "Always", "source", "default", "", nil, nil, nil, nil, nil, nil, pvc, corev1.PersistentVolumeFilesystem, nil, "")
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.CloneVerifyContent, Value: "true"}))
+		pvc = createPvc("target", "default", map[string]string{AnnCloneRequest: "default/source"}, nil)
+		pod = MakeCloneSourcePodSpec("cloner", "Always", "source", "default", "", nil, nil, nil, nil, nil, nil, pvc, corev1.PersistentVolumeFilesystem, nil, "")
+		for _, env := range pod.Spec.Containers[0].Env {
+			Expect(env.Name).ToNot(Equal(common.CloneVerifyContent))
+		}
+	})
+
+	It("Should record a failed verification", func() {
+		pvc := createPvc("target", "default", map[string]string{AnnCloneRequest: "default/source", AnnCloneVerify: "true"}, nil)
+		pod := createVerifiedPod(pvc, corev1.PodRunning, common.CloneVerificationFailedExitCode, "disk.img: checksum mismatch")
+		recorder := record.NewFakeRecorder(1)
+		annotations := map[string]string{}
+		updateCloneVerification(recorder, pvc, pod, annotations)
+		Expect(annotations[AnnCloneVerificationFailed]).To(Equal("disk.img: checksum mismatch"))
+		Expect(<-recorder.Events).To(ContainSubstring(CloneVerificationFailed))
+	})
+
+	It("Should record a successful verification", func() {
+		pvc := createPvc("target", "default", map[string]string{AnnCloneRequest: "default/source", AnnCloneVerify: "true"}, nil)
+		pod := createVerifiedPod(pvc, corev1.PodSucceeded, 0, "Verified 1024 bytes in 1 files")
+		recorder := record.NewFakeRecorder(1)
+		annotations := map[string]string{AnnCloneVerificationFailed: "disk.img: checksum mismatch"}
+		updateCloneVerification(recorder, pvc, pod, annotations)
+		Expect(annotations[AnnCloneVerified]).To(Equal("Verified 1024 bytes in 1 files"))
+		Expect(annotations).ToNot(HaveKey(AnnCloneVerificationFailed))
+		Expect(<-recorder.Events).To(ContainSubstring(CloneVerified))
+	})
+
+	It("Should not record anything for a clone that is not verified", func() {
+		pvc := createPvc("target", "default", map[string]string{AnnCloneRequest: "default/source"}, nil)
+		pod := createVerifiedPod(pvc, corev1.PodSucceeded, 0, "Verified 1024 bytes in 1 files")
+		annotations := map[string]string{}
+		updateCloneVerification(record.NewFakeRecorder(1), pvc, pod, annotations)
+		Expect(annotations).To(BeEmpty())
+	})
+
+	table.DescribeTable("Should reflect the verification in the Verified condition", func(annotations map[string]string, expectedStatus corev1.ConditionStatus, expectedReason string) {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", "default", annotations, nil)
+		updateVerifiedCondition(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeVerified)
+		if expectedStatus == "" {
+			Expect(condition).To(BeNil())
+			return
+		}
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(expectedStatus))
+		Expect(condition.Reason).To(Equal(expectedReason))
+	},
+		table.Entry("not set without verification", map[string]string{AnnPodPhase: string(corev1.PodSucceeded)}, corev1.ConditionStatus(""), ""),
+		table.Entry("not set while cloning", map[string]string{AnnCloneVerify: "true", AnnPodPhase: string(corev1.PodRunning)}, corev1.ConditionStatus(""), ""),
+		table.Entry("true when verified", map[string]string{AnnCloneVerify: "true", AnnCloneVerified: "ok", AnnPodPhase: string(corev1.PodSucceeded)}, corev1.ConditionTrue, CloneVerified),
+		table.Entry("false when failed", map[string]string{AnnCloneVerify: "true", AnnCloneVerificationFailed: "mismatch", AnnPodPhase: string(corev1.PodRunning)}, corev1.ConditionFalse, CloneVerificationFailed),
+		table.Entry("false without checksums", map[string]string{AnnCloneVerify: "true", AnnPodPhase: string(corev1.PodSucceeded)}, corev1.ConditionFalse, CloneNotVerified),
+	)
+})
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// contentDigest returns the digest of the raw image the succeeded importer or upload server in pod wrote, from its
+// termination message
+func contentDigest(pod *v1.Pod) (string, bool) {
+	if message, ok := succeededTerminationMessage(pod); ok && message.Digest != "" {
+		return message.Digest, true
+	}
+	return "", false
+}
+
+// updateContentDigest records the digest of the content the importer or upload server in pod wrote to the PVC in
+// annotations, once the pod succeeded
+func updateContentDigest(pod *v1.Pod, annotations map[string]string) {
+	if pod.Status.Phase != v1.PodSucceeded {
+		return
+	}
+	if digest, ok := contentDigest(pod); ok {
+		annotations[AnnContentDigest] = digest
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Content digest", func() {
+	createTerminatedPod := func(phase corev1.PodPhase, exitCode int32, message string) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: exitCode,
+								Message:  message,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	table.DescribeTable("Should record the digest of the content written to the PVC", func(pod *corev1.Pod, expectedDigest string) {
+		annotations := map[string]string{}
+		updateContentDigest(pod, annotations)
+		if expectedDigest == "" {
+			Expect(annotations).ToNot(HaveKey(AnnContentDigest))
+			return
+		}
+		Expect(annotations[AnnContentDigest]).To(Equal(expectedDigest))
+	},
+		table.Entry("by an importer", createTerminatedPod(corev1.PodSucceeded, 0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Import Complete", Digest: "sha256:00"})), "sha256:00"),
+		table.Entry("by an upload server", createTerminatedPod(corev1.PodSucceeded, 0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Digest: "sha256:00"})), "sha256:00"),
+		table.Entry("not by a pod without a digest", createTerminatedPod(corev1.PodSucceeded, 0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Import Complete"})), ""),
+		table.Entry("not by a pod that wrote no json", createTerminatedPod(corev1.PodSucceeded, 0, "Import Complete"), ""),
+		table.Entry("not by a failed pod", createTerminatedPod(corev1.PodFailed, 1, "Unable to process data"), ""),
+	)
+
+	It("Should not take the digest for the result of verifying a clone", func() {
+		Expect(cloneVerifiedMessage(createTerminatedPod(corev1.PodSucceeded, 0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Digest: "sha256:00"})))).To(BeEmpty())
+		message := cloneVerifiedMessage(createTerminatedPod(corev1.PodSucceeded, 0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Verified 4 bytes in 1 files", Digest: "sha256:00"})))
+		Expect(message).To(Equal("Verified 4 bytes in 1 files"))
+	})
+})
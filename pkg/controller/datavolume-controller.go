@@ -19,8 +19,10 @@ package controller
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"reflect"
 	"regexp"
@@ -43,9 +45,11 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
 	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	cdiclientset "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -137,6 +141,9 @@ const (
 
 var httpClient *http.Client
 
+// metricsClientCertFetcher fetches the certificate the controller presents to the metrics endpoints of the pods
+var metricsClientCertFetcher fetcher.CertFetcher = &fetcher.FileCertFetcher{Name: "cdi-metrics-client-cert"}
+
 // DataVolumeEvent reoresents event
 type DataVolumeEvent struct {
 	eventType string
@@ -163,7 +170,7 @@ func NewDatavolumeController(mgr manager.Manager, cdiClient *cdiclientset.Client
 		CdiClient:    cdiClient,
 		K8sClient:    k8sClient,
 		ExtClientSet: extClientSet,
-		Log:          log.WithName("datavolume-controller"),
+		Log:          newSampledLogger(log.WithName("datavolume-controller")),
 		recorder:     mgr.GetEventRecorderFor("datavolume-controller"),
 	}
 	datavolumeController, err := controller.New("datavolume-controller", mgr, controller.Options{
@@ -206,7 +213,7 @@ func addDatavolumeControllerWatches(mgr manager.Manager, datavolumeController co
 
 // Reconcile the reconcile loop for the data volumes.
 func (r *DatavolumeReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
-	log := r.Log.WithValues("Datavolume", req.NamespacedName)
+	log := r.Log.WithValues(logKeyDataVolume, req.NamespacedName)
 
 	// Get the Datavolume.
 	datavolume := &cdiv1.DataVolume{}
@@ -219,10 +226,21 @@ func (r *DatavolumeReconciler) Reconcile(req reconcile.Request) (reconcile.Resul
 
 	if datavolume.DeletionTimestamp != nil {
 		log.Info("Datavolume marked for deletion, skipping")
-		return reconcile.Result{}, nil
+		if err := r.reconcileSmartCloneSnapshot(datavolume); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, r.retainPVC(datavolume)
+	}
+
+	if err := r.reconcileRetainPVCFinalizer(datavolume); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.reconcileSmartCloneSnapshot(datavolume); err != nil {
+		return reconcile.Result{}, err
 	}
 
 	pvcExists := true
+	var fallbackRequeueAfter time.Duration
 	// Get the pvc with the name specified in DataVolume.spec
 	pvc := &corev1.PersistentVolumeClaim{}
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: datavolume.Namespace, Name: datavolume.Name}, pvc); err != nil {
@@ -237,16 +255,32 @@ func (r *DatavolumeReconciler) Reconcile(req reconcile.Request) (reconcile.Resul
 		// If the PVC is not controlled by this DataVolume resource, we should log
 		// a warning to the event recorder and return
 		if !metav1.IsControlledBy(pvc, datavolume) {
-			msg := fmt.Sprintf(MessageResourceExists, pvc.Name)
-			r.recorder.Event(datavolume, corev1.EventTypeWarning, ErrResourceExists, msg)
-			return reconcile.Result{}, errors.Errorf(msg)
+			if !adoptsPVC(datavolume) || CheckPVCAdoptable(pvc) != nil {
+				msg := fmt.Sprintf(MessageResourceExists, pvc.Name)
+				r.recorder.Event(datavolume, corev1.EventTypeWarning, ErrResourceExists, msg)
+				return reconcile.Result{}, errors.Errorf(msg)
+			}
+			if err := r.adoptPVC(datavolume, pvc); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		if deleted, err := r.reconcileVolumeModeFallback(datavolume, pvc); err != nil || deleted {
+			return reconcile.Result{}, err
 		}
+		deleted, requeueAfter, err := r.reconcileStorageClassFallback(datavolume, pvc)
+		if err != nil || deleted {
+			return reconcile.Result{}, err
+		}
+		fallbackRequeueAfter = requeueAfter
 	}
 
 	if !pvcExists {
 		snapshotClassName, err := r.getSnapshotClassForSmartClone(datavolume)
 		if err == nil {
 			r.Log.V(3).Info("Smart-Clone via Snapshot is available with Volume Snapshot Class", "snapshotClassName", snapshotClassName)
+			if err := r.addSmartCloneSnapshotFinalizer(datavolume); err != nil {
+				return reconcile.Result{}, err
+			}
 			newSnapshot := newSnapshot(datavolume, snapshotClassName)
 			if err := r.Client.Create(context.TODO(), newSnapshot); err != nil {
 				if k8serrors.IsAlreadyExists(err) {
@@ -261,7 +295,16 @@ func (r *DatavolumeReconciler) Reconcile(req reconcile.Request) (reconcile.Resul
 		if err != nil {
 			return reconcile.Result{}, err
 		}
+		if err := r.useRegistryCache(datavolume, newPvc); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.sizeCloneTarget(datavolume, newPvc); err != nil {
+			return reconcile.Result{}, err
+		}
 		if err := r.Client.Create(context.TODO(), newPvc); err != nil {
+			if fellBack, fallbackErr := r.reconcileStorageClassFallbackOnCreate(datavolume, newPvc, err); fallbackErr != nil || fellBack {
+				return reconcile.Result{}, fallbackErr
+			}
 			return reconcile.Result{}, err
 		}
 		pvc = newPvc
@@ -269,16 +312,23 @@ func (r *DatavolumeReconciler) Reconcile(req reconcile.Request) (reconcile.Resul
 
 	// Finally, we update the status block of the DataVolume resource to reflect the
 	// current state of the world
-	return r.reconcileDataVolumeStatus(datavolume, pvc)
+	result, err := r.reconcileDataVolumeStatus(datavolume, pvc)
+	if err == nil && fallbackRequeueAfter > 0 && (result.RequeueAfter == 0 || fallbackRequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = fallbackRequeueAfter
+	}
+	return result, err
 }
 
-func (r *DatavolumeReconciler) reconcileProgressUpdate(datavolume *cdiv1.DataVolume, pvcUID types.UID) (reconcile.Result, error) {
+// reconcileProgressUpdate updates the progress of datavolume, and relays the heartbeat of its transfer pod to the
+// annotations of pvc
+func (r *DatavolumeReconciler) reconcileProgressUpdate(datavolume *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) (reconcile.Result, error) {
 	var podNamespace string
 	if datavolume.Status.Progress == "" {
 		datavolume.Status.Progress = "N/A"
 	}
 
-	if datavolume.Spec.Source.HTTP != nil {
+	if datavolume.Spec.Source.HTTP != nil || datavolume.Spec.Source.SSH != nil || datavolume.Spec.Source.Nutanix != nil ||
+		datavolume.Spec.Source.S3 != nil || datavolume.Spec.Source.Imageio != nil || datavolume.Spec.Source.Upload != nil {
 		podNamespace = datavolume.Namespace
 	} else if datavolume.Spec.Source.PVC != nil {
 		podNamespace = datavolume.Spec.Source.PVC.Namespace
@@ -291,9 +341,9 @@ func (r *DatavolumeReconciler) reconcileProgressUpdate(datavolume *cdiv1.DataVol
 		r.Log.Info("Datavolume finished, no longer updating progress", "Namespace", datavolume.Namespace, "Name", datavolume.Name, "Phase", datavolume.Status.Phase)
 		return reconcile.Result{}, nil
 	}
-	pod, err := r.getPodFromPvc(podNamespace, pvcUID)
+	pod, err := r.getPodFromPvc(podNamespace, pvc.GetUID())
 	if err == nil {
-		if err := updateProgressUsingPod(datavolume, pod); err != nil {
+		if err := updateProgressUsingPod(datavolume, pvc, pod); err != nil {
 			return reconcile.Result{}, err
 		}
 	}
@@ -308,6 +358,16 @@ func (r *DatavolumeReconciler) getSnapshotClassForSmartClone(dataVolume *cdiv1.D
 		return "", errors.New("no source PVC provided")
 	}
 
+	// A PVC restored from a snapshot is provisioned, it can not bind to the volume the target is pre-bound to
+	if isPreBound(dataVolume) {
+		return "", errors.New("target PVC is pre-bound to a persistent volume")
+	}
+
+	// A PVC restored from a snapshot is provisioned wherever the snapshot is, not on the node the target is pinned to
+	if dataVolume.Spec.NodeName != "" {
+		return "", errors.New("target PVC is pinned to a node")
+	}
+
 	// Check if relevant CRDs are available
 	if !IsCsiCrdsDeployed(r.ExtClientSet) {
 		r.Log.V(3).Info("Missing CSI snapshotter CRDs, falling back to host assisted clone")
@@ -359,6 +419,12 @@ func (r *DatavolumeReconciler) getSnapshotClassForSmartClone(dataVolume *cdiv1.D
 		return "", errors.New("source PVC and target PVC belong to different storage classes")
 	}
 
+	// A snapshot is restored in the volume mode of its source
+	if getVolumeMode(pvc) != volumeModeOfSpec(dataVolume.Spec.PVC) {
+		r.Log.V(3).Info("Source PVC and target PVC have different volume modes")
+		return "", errors.New("source PVC and target PVC have different volume modes")
+	}
+
 	// Compare source and target namespaces
 	if pvc.Namespace != dataVolume.Namespace {
 		r.Log.V(3).Info("Source PVC and target PVC belong to different namespaces", "source namespace",
@@ -451,6 +517,11 @@ func (r *DatavolumeReconciler) updateImportStatusPhase(pvc *corev1.PersistentVol
 			event.eventType = corev1.EventTypeWarning
 			event.reason = ImportFailed
 			event.message = fmt.Sprintf(MessageImportFailed, pvc.Name)
+			if permanentFailure, ok := pvc.Annotations[AnnPermanentFailure]; ok {
+				reason, message := parsePermanentFailure(permanentFailure)
+				event.reason = reason
+				event.message = fmt.Sprintf("%s: %s", event.message, message)
+			}
 		case string(corev1.PodSucceeded):
 			dataVolumeCopy.Status.Phase = cdiv1.Succeeded
 			dataVolumeCopy.Status.Progress = cdiv1.DataVolumeProgress("100.0%")
@@ -479,6 +550,8 @@ func (r *DatavolumeReconciler) updateSmartCloneStatusPhase(phase cdiv1.DataVolum
 }
 
 func (r *DatavolumeReconciler) updateCloneStatusPhase(pvc *corev1.PersistentVolumeClaim, dataVolumeCopy *cdiv1.DataVolume, event *DataVolumeEvent) {
+	// The source comes from the PVC, registry imports may be cloned from a cache entry.
+	_, sourceNamespace, sourceName := ParseCloneRequestAnnotation(pvc)
 	phase, ok := pvc.Annotations[AnnPodPhase]
 	if ok {
 		switch phase {
@@ -487,29 +560,60 @@ func (r *DatavolumeReconciler) updateCloneStatusPhase(pvc *corev1.PersistentVolu
 			dataVolumeCopy.Status.Phase = cdiv1.CloneScheduled
 			event.eventType = corev1.EventTypeNormal
 			event.reason = CloneScheduled
-			event.message = fmt.Sprintf(MessageCloneScheduled, dataVolumeCopy.Spec.Source.PVC.Namespace, dataVolumeCopy.Spec.Source.PVC.Name, pvc.Namespace, pvc.Name)
+			event.message = fmt.Sprintf(MessageCloneScheduled, sourceNamespace, sourceName, pvc.Namespace, pvc.Name)
 		case string(corev1.PodRunning):
 			// TODO: Use a more generic In Progess, like maybe TransferInProgress.
 			dataVolumeCopy.Status.Phase = cdiv1.CloneInProgress
 			event.eventType = corev1.EventTypeNormal
 			event.reason = CloneInProgress
-			event.message = fmt.Sprintf(MessageCloneInProgress, dataVolumeCopy.Spec.Source.PVC.Namespace, dataVolumeCopy.Spec.Source.PVC.Name, pvc.Namespace, pvc.Name)
+			event.message = fmt.Sprintf(MessageCloneInProgress, sourceNamespace, sourceName, pvc.Namespace, pvc.Name)
 		case string(corev1.PodFailed):
 			dataVolumeCopy.Status.Phase = cdiv1.Failed
 			event.eventType = corev1.EventTypeWarning
 			event.reason = CloneFailed
-			event.message = fmt.Sprintf(MessageCloneFailed, dataVolumeCopy.Spec.Source.PVC.Namespace, dataVolumeCopy.Spec.Source.PVC.Name, pvc.Namespace, pvc.Name)
+			event.message = fmt.Sprintf(MessageCloneFailed, sourceNamespace, sourceName, pvc.Namespace, pvc.Name)
 		case string(corev1.PodSucceeded):
 			dataVolumeCopy.Status.Phase = cdiv1.Succeeded
 			dataVolumeCopy.Status.Progress = cdiv1.DataVolumeProgress("100.0%")
 			event.eventType = corev1.EventTypeNormal
 			event.reason = CloneSucceeded
-			event.message = fmt.Sprintf(MessageCloneSucceeded, dataVolumeCopy.Spec.Source.PVC.Namespace, dataVolumeCopy.Spec.Source.PVC.Name, pvc.Namespace, pvc.Name)
+			event.message = fmt.Sprintf(MessageCloneSucceeded, sourceNamespace, sourceName, pvc.Namespace, pvc.Name)
 		}
 
 	}
 }
 
+// useRegistryCache turns the import of a registry image pinned by digest into a clone of a cache entry
+// for the same digest in the namespace, if there is one.
+func (r *DatavolumeReconciler) useRegistryCache(dataVolume *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) error {
+	if dataVolume.Spec.Source.Registry == nil || pvc.Annotations[AnnRegistryCache] == "true" || dataVolume.Spec.Encryption != nil {
+		// The cache holds the image decrypted and as it was imported
+		return nil
+	}
+	digest, ok := registryDigest(dataVolume.Spec.Source.Registry.URL)
+	if !ok {
+		return nil
+	}
+	cachePvc, err := findRegistryCachePVC(r.Client, dataVolume.Namespace, digest)
+	if err != nil {
+		return err
+	}
+	if cachePvc == nil || getVolumeMode(cachePvc) != getVolumeMode(pvc) || ValidateCanCloneSourceAndTargetSpec(&cachePvc.Spec, &pvc.Spec) != nil {
+		registryCacheMisses.Inc()
+		return nil
+	}
+
+	r.Log.V(1).Info("Cloning registry image from cache", "digest", digest, "cache PVC", cachePvc.Name)
+	for _, ann := range []string{AnnSource, AnnEndpoint, AnnContentType, AnnSecret, AnnCertConfigMap, AnnArchitecture, AnnDiskPath, AnnArtifactMediaType, AnnArtifactAnnotation} {
+		delete(pvc.Annotations, ann)
+	}
+	pvc.Annotations[AnnCloneRequest] = cachePvc.Namespace + "/" + cachePvc.Name
+	pvc.Annotations[AnnRegistryCacheSource] = cachePvc.Name
+	pvc.Annotations[AnnRegistryDigest] = digest
+	registryCacheHits.Inc()
+	return nil
+}
+
 func (r *DatavolumeReconciler) updateUploadStatusPhase(pvc *corev1.PersistentVolumeClaim, dataVolumeCopy *cdiv1.DataVolume, event *DataVolumeEvent) {
 	phase, ok := pvc.Annotations[AnnPodPhase]
 	if ok {
@@ -580,6 +684,12 @@ func (r *DatavolumeReconciler) reconcileDataVolumeStatus(dataVolume *cdiv1.DataV
 			if ok {
 				dataVolumeCopy.Status.Phase = cdiv1.ImportScheduled
 				r.updateImportStatusPhase(pvc, dataVolumeCopy, &event)
+			} else if _, ok = pvc.Annotations[AnnTransferWindowClosed]; ok && pvc.Annotations[AnnCloneRequest] == "" {
+				// The importer pod of a low priority import is created once a transfer window opens
+				dataVolumeCopy.Status.Phase = cdiv1.ImportScheduled
+				event.eventType = corev1.EventTypeNormal
+				event.reason = ImportScheduled
+				event.message = fmt.Sprintf(MessageImportScheduled, pvc.Name)
 			}
 			_, ok = pvc.Annotations[AnnCloneRequest]
 			if ok {
@@ -604,23 +714,48 @@ func (r *DatavolumeReconciler) reconcileDataVolumeStatus(dataVolume *cdiv1.DataV
 		}
 	}
 
+	var transferFailure string
+	var transferFailed bool
 	if pvc != nil {
 		if i, err := strconv.Atoi(pvc.Annotations[AnnPodRestarts]); err == nil && i >= 0 {
 			dataVolumeCopy.Status.RestartCount = int32(i)
 		}
+		propagateClaimConditions(dataVolumeCopy, pvc)
+		updateTimeoutCondition(dataVolumeCopy, pvc)
+		updateVerifiedCondition(dataVolumeCopy, pvc)
+		updateChecksumMismatchCondition(dataVolumeCopy, pvc)
+		updateImageCheckedCondition(dataVolumeCopy, pvc)
+		transferFailure, transferFailed = updateTransferFailedCondition(dataVolumeCopy, pvc)
+		updateStageDurations(dataVolumeCopy, pvc)
+		pv, err := r.getBoundVolume(pvc)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		updateClaimStatus(dataVolumeCopy, pvc, pv)
 	}
 	result := reconcile.Result{}
 	var err error
 	if pvc != nil {
-		result, err = r.reconcileProgressUpdate(dataVolumeCopy, pvc.GetUID())
+		pvcCopy := pvc.DeepCopy()
+		result, err = r.reconcileProgressUpdate(dataVolumeCopy, pvcCopy)
 		if err != nil {
 			return result, err
 		}
+		if err := r.publishClaimConditions(pvc, pvcCopy, string(dataVolumeCopy.Status.Progress)); err != nil {
+			return result, err
+		}
+	}
+	if err := r.emitEvent(dataVolume, dataVolumeCopy, curPhase, &event); err != nil {
+		return result, err
+	}
+	if transferFailed {
+		r.recorder.Event(dataVolume, corev1.EventTypeWarning, TransferFailed, transferFailure)
 	}
-	return result, r.emitEvent(dataVolume, dataVolumeCopy, curPhase, &event)
+	return result, nil
 }
 
 func (r *DatavolumeReconciler) emitEvent(dataVolume *cdiv1.DataVolume, dataVolumeCopy *cdiv1.DataVolume, curPhase cdiv1.DataVolumePhase, event *DataVolumeEvent) error {
+	recordPhaseTransition(dataVolume, dataVolumeCopy)
 	// Only update the object if something actually changed in the status.
 	if !reflect.DeepEqual(dataVolume.Status, dataVolumeCopy.Status) {
 		if err := r.Client.Update(context.TODO(), dataVolumeCopy); err != nil {
@@ -631,6 +766,12 @@ func (r *DatavolumeReconciler) emitEvent(dataVolume *cdiv1.DataVolume, dataVolum
 		if event.eventType != "" && curPhase != dataVolumeCopy.Status.Phase {
 			r.recorder.Event(dataVolume, event.eventType, event.reason, event.message)
 		}
+		if isOperationCompleted(curPhase, dataVolumeCopy) {
+			// The status is updated already, a failure to record the operation does not fail the reconcile
+			if err := recordOperation(r.Client, dataVolumeCopy, event.message); err != nil {
+				r.Log.Error(err, "Unable to record operation", "namespace", dataVolumeCopy.Namespace, "name", dataVolumeCopy.Name)
+			}
+		}
 	}
 	return nil
 }
@@ -661,14 +802,14 @@ func (r *DatavolumeReconciler) getPodFromPvc(namespace string, pvcUID types.UID)
 	return nil, errors.Errorf("Unable to find pod owned by UID: %s, in namespace: %s", string(pvcUID), namespace)
 }
 
-func updateProgressUsingPod(dataVolumeCopy *cdiv1.DataVolume, pod *corev1.Pod) error {
+func updateProgressUsingPod(dataVolumeCopy *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod) error {
 	httpClient := buildHTTPClient()
 	// Example value: import_progress{ownerUID="b856691e-1038-11e9-a5ab-525500d15501"} 13.45
 	var importRegExp = regexp.MustCompile("progress\\{ownerUID\\=\"" + string(dataVolumeCopy.UID) + "\"\\} (\\d{1,3}\\.?\\d*)")
 
 	port, err := getPodMetricsPort(pod)
 	if err == nil && pod.Status.PodIP != "" {
-		url := fmt.Sprintf("https://%s:%d/metrics", pod.Status.PodIP, port)
+		url := fmt.Sprintf("https://%s/metrics", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)))
 		resp, err := httpClient.Get(url)
 		if err != nil {
 			if errConnectionRefused(err) {
@@ -682,14 +823,12 @@ func updateProgressUsingPod(dataVolumeCopy *cdiv1.DataVolume, pod *corev1.Pod) e
 			return err
 		}
 
-		match := importRegExp.FindStringSubmatch(string(body))
-		if match == nil {
-			// No match
-			return nil
-		}
-		if f, err := strconv.ParseFloat(match[1], 64); err == nil {
-			dataVolumeCopy.Status.Progress = cdiv1.DataVolumeProgress(fmt.Sprintf("%.2f%%", f))
+		if match := importRegExp.FindStringSubmatch(string(body)); match != nil {
+			if f, err := strconv.ParseFloat(match[1], 64); err == nil {
+				dataVolumeCopy.Status.Progress = cdiv1.DataVolumeProgress(fmt.Sprintf("%.2f%%", f))
+			}
 		}
+		updateHeartbeat(pvc, string(body), dataVolumeCopy.UID, string(dataVolumeCopy.Status.Progress))
 		return nil
 	}
 	return err
@@ -711,8 +850,8 @@ func getPodMetricsPort(pod *corev1.Pod) (int, error) {
 }
 
 // buildHTTPClient generates an http client that accepts any certificate, since we are using
-// it to get prometheus data it doesn't matter if someone can intercept the data. Once we have
-// a mechanism to properly sign the server, we can update this method to get a proper client.
+// it to get prometheus data it doesn't matter if someone can intercept the data. Only the clone
+// source pods have a signed certificate yet, they ask for the metrics client certificate though.
 func buildHTTPClient() *http.Client {
 	if httpClient == nil {
 		defaultTransport := http.DefaultTransport.(*http.Transport)
@@ -724,7 +863,10 @@ func buildHTTPClient() *http.Client {
 			IdleConnTimeout:       defaultTransport.IdleConnTimeout,
 			ExpectContinueTimeout: defaultTransport.ExpectContinueTimeout,
 			TLSHandshakeTimeout:   defaultTransport.TLSHandshakeTimeout,
-			TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify:   true,
+				GetClientCertificate: getMetricsClientCert,
+			},
 		}
 		httpClient = &http.Client{
 			Transport: tr,
@@ -733,6 +875,26 @@ func buildHTTPClient() *http.Client {
 	return httpClient
 }
 
+// getMetricsClientCert returns the metrics client certificate, read on every handshake as the operator rotates it. No
+// certificate is sent if there is none.
+func getMetricsClientCert(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	certBytes, err := metricsClientCertFetcher.CertBytes()
+	if err != nil {
+		klog.V(3).Infof("No metrics client certificate: %v", err)
+		return &tls.Certificate{}, nil
+	}
+	keyBytes, err := metricsClientCertFetcher.KeyBytes()
+	if err != nil {
+		klog.V(3).Infof("No metrics client key: %v", err)
+		return &tls.Certificate{}, nil
+	}
+	cert, err := tls.X509KeyPair(certBytes, keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
 // newPersistentVolumeClaim creates a new PVC the DataVolume resource.
 // It also sets the appropriate OwnerReferences on the resource
 // which allows handleObject to discover the DataVolume resource
@@ -759,8 +921,8 @@ func newPersistentVolumeClaim(dataVolume *cdiv1.DataVolume) (*corev1.PersistentV
 	if dataVolume.Spec.Source.HTTP != nil {
 		annotations[AnnEndpoint] = dataVolume.Spec.Source.HTTP.URL
 		annotations[AnnSource] = SourceHTTP
-		if dataVolume.Spec.ContentType == cdiv1.DataVolumeArchive {
-			annotations[AnnContentType] = string(cdiv1.DataVolumeArchive)
+		if dataVolume.Spec.ContentType == cdiv1.DataVolumeArchive || dataVolume.Spec.ContentType == cdiv1.DataVolumeImages {
+			annotations[AnnContentType] = string(dataVolume.Spec.ContentType)
 		} else {
 			annotations[AnnContentType] = string(cdiv1.DataVolumeKubeVirt)
 		}
@@ -770,11 +932,20 @@ func newPersistentVolumeClaim(dataVolume *cdiv1.DataVolume) (*corev1.PersistentV
 		if dataVolume.Spec.Source.HTTP.CertConfigMap != "" {
 			annotations[AnnCertConfigMap] = dataVolume.Spec.Source.HTTP.CertConfigMap
 		}
+		if len(dataVolume.Spec.Source.HTTP.BackingFileURLs) > 0 {
+			annotations[AnnBackingFileURLs] = strings.Join(dataVolume.Spec.Source.HTTP.BackingFileURLs, ",")
+		}
+		if dataVolume.Spec.Source.HTTP.Checksum != "" {
+			annotations[AnnChecksum] = dataVolume.Spec.Source.HTTP.Checksum
+		}
 	} else if dataVolume.Spec.Source.S3 != nil {
 		annotations[AnnEndpoint] = dataVolume.Spec.Source.S3.URL
 		if dataVolume.Spec.Source.S3.SecretRef != "" {
 			annotations[AnnSecret] = dataVolume.Spec.Source.S3.SecretRef
 		}
+		if dataVolume.Spec.Source.S3.Checksum != "" {
+			annotations[AnnChecksum] = dataVolume.Spec.Source.S3.Checksum
+		}
 	} else if dataVolume.Spec.Source.Registry != nil {
 		annotations[AnnSource] = SourceRegistry
 		annotations[AnnEndpoint] = dataVolume.Spec.Source.Registry.URL
@@ -785,6 +956,18 @@ func newPersistentVolumeClaim(dataVolume *cdiv1.DataVolume) (*corev1.PersistentV
 		if dataVolume.Spec.Source.Registry.CertConfigMap != "" {
 			annotations[AnnCertConfigMap] = dataVolume.Spec.Source.Registry.CertConfigMap
 		}
+		if dataVolume.Spec.Source.Registry.Architecture != "" {
+			annotations[AnnArchitecture] = dataVolume.Spec.Source.Registry.Architecture
+		}
+		if dataVolume.Spec.Source.Registry.DiskPath != "" {
+			annotations[AnnDiskPath] = dataVolume.Spec.Source.Registry.DiskPath
+		}
+		if dataVolume.Spec.Source.Registry.ArtifactMediaType != "" {
+			annotations[AnnArtifactMediaType] = dataVolume.Spec.Source.Registry.ArtifactMediaType
+		}
+		if dataVolume.Spec.Source.Registry.ArtifactAnnotation != "" {
+			annotations[AnnArtifactAnnotation] = dataVolume.Spec.Source.Registry.ArtifactAnnotation
+		}
 	} else if dataVolume.Spec.Source.PVC != nil {
 		sourceNamespace := dataVolume.Spec.Source.PVC.Namespace
 		if sourceNamespace == "" {
@@ -798,6 +981,12 @@ func newPersistentVolumeClaim(dataVolume *cdiv1.DataVolume) (*corev1.PersistentV
 		annotations[AnnCloneRequest] = sourceNamespace + "/" + dataVolume.Spec.Source.PVC.Name
 	} else if dataVolume.Spec.Source.Upload != nil {
 		annotations[AnnUploadRequest] = ""
+		if dataVolume.Spec.ContentType == cdiv1.DataVolumeKubeVirtState {
+			annotations[AnnContentType] = string(dataVolume.Spec.ContentType)
+			if dataVolume.Spec.Source.Upload.Checksum != "" {
+				annotations[AnnChecksum] = dataVolume.Spec.Source.Upload.Checksum
+			}
+		}
 	} else if dataVolume.Spec.Source.Blank != nil {
 		annotations[AnnSource] = SourceNone
 		annotations[AnnContentType] = string(cdiv1.DataVolumeKubeVirt)
@@ -807,10 +996,95 @@ func newPersistentVolumeClaim(dataVolume *cdiv1.DataVolume) (*corev1.PersistentV
 		annotations[AnnSecret] = dataVolume.Spec.Source.Imageio.SecretRef
 		annotations[AnnCertConfigMap] = dataVolume.Spec.Source.Imageio.CertConfigMap
 		annotations[AnnDiskID] = dataVolume.Spec.Source.Imageio.DiskID
+	} else if dataVolume.Spec.Source.SSH != nil {
+		annotations[AnnEndpoint] = dataVolume.Spec.Source.SSH.URL
+		annotations[AnnSource] = SourceSSH
+		annotations[AnnSecret] = dataVolume.Spec.Source.SSH.SecretRef
+		annotations[AnnContentType] = string(cdiv1.DataVolumeKubeVirt)
+		if len(dataVolume.Spec.Source.SSH.BackingFileURLs) > 0 {
+			annotations[AnnBackingFileURLs] = strings.Join(dataVolume.Spec.Source.SSH.BackingFileURLs, ",")
+		}
+		if dataVolume.Spec.Source.SSH.InsecureSkipHostKeyCheck {
+			annotations[AnnSSHInsecureSkipHostKeyCheck] = "true"
+		}
+	} else if dataVolume.Spec.Source.Nutanix != nil {
+		annotations[AnnEndpoint] = dataVolume.Spec.Source.Nutanix.URL
+		annotations[AnnSource] = SourceNutanix
+		annotations[AnnSecret] = dataVolume.Spec.Source.Nutanix.SecretRef
+		annotations[AnnCertConfigMap] = dataVolume.Spec.Source.Nutanix.CertConfigMap
+		annotations[AnnNutanixImageUUID] = dataVolume.Spec.Source.Nutanix.ImageUUID
+		annotations[AnnContentType] = string(cdiv1.DataVolumeKubeVirt)
 	} else {
 		return nil, errors.Errorf("no source set for datavolume")
 	}
 
+	if dataVolume.Spec.Priority != "" {
+		annotations[AnnPriority] = string(dataVolume.Spec.Priority)
+	}
+	if dataVolume.Spec.Deadline != nil {
+		annotations[AnnTransferDeadline] = dataVolume.Spec.Deadline.Duration.String()
+	}
+	if dataVolume.Spec.ImageCheck != "" {
+		annotations[AnnImageCheck] = string(dataVolume.Spec.ImageCheck)
+	}
+	if dataVolume.Spec.Verification != "" {
+		annotations[AnnVerification] = string(dataVolume.Spec.Verification)
+	}
+	if dataVolume.Spec.BlockWipe != "" {
+		annotations[AnnBlockWipe] = string(dataVolume.Spec.BlockWipe)
+	}
+	if target := dataVolume.Spec.BlockTarget; target != nil {
+		if target.Partition > 0 {
+			annotations[AnnBlockTargetPartition] = strconv.Itoa(int(target.Partition))
+		} else {
+			annotations[AnnBlockTargetOffset] = strconv.FormatInt(target.Offset, 10)
+		}
+	}
+	if dataVolume.Spec.ImageName != "" {
+		annotations[AnnImageName] = dataVolume.Spec.ImageName
+	}
+	if dataVolume.Spec.NodeName != "" {
+		annotations[AnnNodeName] = dataVolume.Spec.NodeName
+		// Volumes that wait for their first consumer are provisioned on the node right away
+		annotations[AnnSelectedNode] = dataVolume.Spec.NodeName
+	}
+	if len(dataVolume.Spec.FanOut) > 0 {
+		annotations[AnnFanOutTargets] = strings.Join(dataVolume.Spec.FanOut, ",")
+	}
+	if dataVolume.Spec.Source.HTTP != nil && len(dataVolume.Spec.Source.HTTP.AdditionalImages) > 0 {
+		images, err := json.Marshal(dataVolume.Spec.Source.HTTP.AdditionalImages)
+		if err != nil {
+			return nil, err
+		}
+		annotations[AnnAdditionalImages] = string(images)
+	}
+	if dataVolume.Spec.DNS != nil {
+		dns, err := json.Marshal(dataVolume.Spec.DNS)
+		if err != nil {
+			return nil, err
+		}
+		annotations[AnnPodDNS] = string(dns)
+	}
+	if dataVolume.Spec.BandwidthLimit != nil {
+		limit, err := json.Marshal(dataVolume.Spec.BandwidthLimit)
+		if err != nil {
+			return nil, err
+		}
+		annotations[AnnBandwidthLimit] = string(limit)
+	}
+	if encryption := dataVolume.Spec.Encryption; encryption != nil {
+		if encryption.SourceSecretRef != "" {
+			annotations[AnnSourceKeySecret] = encryption.SourceSecretRef
+		}
+		if encryption.TargetSecretRef != "" {
+			annotations[AnnTargetKeySecret] = encryption.TargetSecretRef
+		}
+	}
+
+	spec := *dataVolume.Spec.PVC
+	spec.VolumeMode = dataVolumeVolumeMode(dataVolume)
+	spec.StorageClassName = dataVolumeStorageClass(dataVolume)
+
 	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        dataVolume.Name,
@@ -825,6 +1099,6 @@ func newPersistentVolumeClaim(dataVolume *cdiv1.DataVolume) (*corev1.PersistentV
 				}),
 			},
 		},
-		Spec: *dataVolume.Spec.PVC,
+		Spec: spec,
 	}, nil
 }
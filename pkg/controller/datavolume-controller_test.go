@@ -18,6 +18,8 @@ package controller
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -46,6 +48,9 @@ import (
 	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	cdifake "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/triple"
 )
 
 var (
@@ -106,6 +111,121 @@ var _ = Describe("Datavolume controller reconcile loop", func() {
 		Expect(pvc.GetAnnotations()[AnnSource]).To(Equal(SourceHTTP))
 	})
 
+	It("Should pass the registry architecture, disk path and artifact selector to the created PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Source = cdiv1.DataVolumeSource{
+			Registry: &cdiv1.DataVolumeSourceRegistry{
+				URL:                "docker://example.com/data",
+				Architecture:       "arm64",
+				DiskPath:           "/images/*.qcow2",
+				ArtifactMediaType:  "application/x-qemu-disk",
+				ArtifactAnnotation: "kind=root",
+			},
+		}
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnSource]).To(Equal(SourceRegistry))
+		Expect(pvc.GetAnnotations()[AnnArchitecture]).To(Equal("arm64"))
+		Expect(pvc.GetAnnotations()[AnnDiskPath]).To(Equal("/images/*.qcow2"))
+		Expect(pvc.GetAnnotations()[AnnArtifactMediaType]).To(Equal("application/x-qemu-disk"))
+		Expect(pvc.GetAnnotations()[AnnArtifactAnnotation]).To(Equal("kind=root"))
+	})
+
+	It("Should pass the ssh url, secret and backing file urls to the created PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Source = cdiv1.DataVolumeSource{
+			SSH: &cdiv1.DataVolumeSourceSSH{
+				URL:                      "ssh://root@libvirt.example.com/var/lib/libvirt/images/vm.qcow2",
+				SecretRef:                "ssh-key",
+				BackingFileURLs:          []string{"ssh://libvirt.example.com/var/lib/libvirt/images/", "ssh://libvirt.example.com/srv/base/"},
+				InsecureSkipHostKeyCheck: true,
+			},
+		}
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnSource]).To(Equal(SourceSSH))
+		Expect(pvc.GetAnnotations()[AnnEndpoint]).To(Equal("ssh://root@libvirt.example.com/var/lib/libvirt/images/vm.qcow2"))
+		Expect(pvc.GetAnnotations()[AnnSecret]).To(Equal("ssh-key"))
+		Expect(pvc.GetAnnotations()[AnnBackingFileURLs]).To(Equal("ssh://libvirt.example.com/var/lib/libvirt/images/,ssh://libvirt.example.com/srv/base/"))
+		Expect(pvc.GetAnnotations()[AnnSSHInsecureSkipHostKeyCheck]).To(Equal("true"))
+	})
+
+	It("Should pass the checksum of an http source to the created PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Source.HTTP.Checksum = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnChecksum]).To(Equal("sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"))
+	})
+
+	It("Should pass the block wipe to the created PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.BlockWipe = cdiv1.DataVolumeBlockWipeDiscard
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnBlockWipe]).To(Equal("discard"))
+		env := makeImportEnv(&importPodEnvVar{blockWipe: pvc.GetAnnotations()[AnnBlockWipe]}, "1111-1111-1111-1111")
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: common.ImporterBlockWipe, Value: "discard"}))
+	})
+
+	It("Should pass the DNS settings to the created PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.DNS = &cdiv1.DataVolumeDNS{
+			HostAliases: []corev1.HostAlias{{IP: "10.0.0.5", Hostnames: []string{"vcenter.lab.local"}}},
+			Policy:      corev1.DNSNone,
+			Config:      &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.53"}},
+		}
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		dns, err := getImporterDNS(pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dns).To(Equal(dv.Spec.DNS))
+	})
+
+	It("Should pass the nutanix url, image uuid, secret and certs to the created PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Source = cdiv1.DataVolumeSource{
+			Nutanix: &cdiv1.DataVolumeSourceNutanix{
+				URL:           "https://prism.example.com:9440",
+				ImageUUID:     "c3e5b4a2-7d8e-4f10-9a6b-0123456789ab",
+				SecretRef:     "prism-credentials",
+				CertConfigMap: "prism-certs",
+			},
+		}
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnSource]).To(Equal(SourceNutanix))
+		Expect(pvc.GetAnnotations()[AnnEndpoint]).To(Equal("https://prism.example.com:9440"))
+		Expect(pvc.GetAnnotations()[AnnNutanixImageUUID]).To(Equal("c3e5b4a2-7d8e-4f10-9a6b-0123456789ab"))
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnDiskID))
+		Expect(pvc.GetAnnotations()[AnnSecret]).To(Equal("prism-credentials"))
+		Expect(pvc.GetAnnotations()[AnnCertConfigMap]).To(Equal("prism-certs"))
+	})
+
 	It("Should follow the phase of the created PVC", func() {
 		reconciler = createDatavolumeReconciler(newImportDataVolume("test-dv"))
 		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
@@ -188,6 +308,7 @@ var _ = Describe("Datavolume controller reconcile loop", func() {
 		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, dv)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(dv.Status.Phase).To(Equal(cdiv1.SnapshotForSmartCloneInProgress))
+		Expect(dv.Finalizers).To(ContainElement(smartCloneSnapshotFinalizer))
 	})
 })
 
@@ -309,6 +430,7 @@ var _ = Describe("Reconcile Datavolume status", func() {
 		table.Entry("should switch to bound for import", newImportDataVolume("test-dv"), cdiv1.Pending, cdiv1.PVCBound, corev1.ClaimBound, corev1.PodPending, "invalid"),
 		table.Entry("should switch to bound for import", newImportDataVolume("test-dv"), cdiv1.Unknown, cdiv1.PVCBound, corev1.ClaimBound, corev1.PodPending, "invalid"),
 		table.Entry("should switch to scheduled for import", newImportDataVolume("test-dv"), cdiv1.Pending, cdiv1.ImportScheduled, corev1.ClaimBound, corev1.PodPending, AnnImportPod),
+		table.Entry("should switch to scheduled for import waiting for a transfer window", newImportDataVolume("test-dv"), cdiv1.Pending, cdiv1.ImportScheduled, corev1.ClaimBound, corev1.PodPending, AnnTransferWindowClosed),
 		table.Entry("should switch to inprogress for import", newImportDataVolume("test-dv"), cdiv1.Pending, cdiv1.ImportInProgress, corev1.ClaimBound, corev1.PodRunning, AnnImportPod),
 		table.Entry("should switch to failed for import", newImportDataVolume("test-dv"), cdiv1.Pending, cdiv1.Failed, corev1.ClaimBound, corev1.PodFailed, AnnImportPod),
 		table.Entry("should switch to failed on claim lost for impot", newImportDataVolume("test-dv"), cdiv1.Pending, cdiv1.Failed, corev1.ClaimLost, corev1.PodFailed, AnnImportPod),
@@ -394,6 +516,21 @@ var _ = Describe("Smart clone", func() {
 		Expect(snapclass).To(BeEmpty())
 	})
 
+	It("Should not return storage class, if source and target volume modes do not match", func() {
+		dv := newCloneDataVolume("test-dv")
+		scName := "testsc"
+		dv.Spec.PVC.StorageClassName = &scName
+		pvc := createPvcInStorageClass("test", metav1.NamespaceDefault, &scName, nil, nil)
+		blockMode := corev1.PersistentVolumeBlock
+		pvc.Spec.VolumeMode = &blockMode
+		reconciler := createDatavolumeReconciler(dv, pvc)
+		reconciler.ExtClientSet = extfake.NewSimpleClientset(createVolumeSnapshotContentCrd(), createVolumeSnapshotClassCrd(), createVolumeSnapshotCrd())
+		snapclass, err := reconciler.getSnapshotClassForSmartClone(dv)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("source PVC and target PVC have different volume modes"))
+		Expect(snapclass).To(BeEmpty())
+	})
+
 	It("Should not return storage class, if source NS and target NS do not match", func() {
 		dv := newCloneDataVolume("test-dv")
 		scName := "testsc"
@@ -528,7 +665,7 @@ var _ = Describe("Update Progress from pod", func() {
 
 	It("Should return error, if no metrics port in pod", func() {
 		pod.Spec.Containers[0].Ports = nil
-		err := updateProgressUsingPod(dv, pod)
+		err := updateProgressUsingPod(dv, pvc, pod)
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(ContainSubstring("Metrics port not found in pod"))
 	})
@@ -536,7 +673,7 @@ var _ = Describe("Update Progress from pod", func() {
 	It("Should not error, if no endpoint exists", func() {
 		pod.Spec.Containers[0].Ports[0].ContainerPort = 12345
 		pod.Status.PodIP = "127.0.0.1"
-		err := updateProgressUsingPod(dv, pod)
+		err := updateProgressUsingPod(dv, pvc, pod)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
@@ -553,7 +690,7 @@ var _ = Describe("Update Progress from pod", func() {
 		Expect(err).ToNot(HaveOccurred())
 		pod.Spec.Containers[0].Ports[0].ContainerPort = int32(port)
 		pod.Status.PodIP = ep.Hostname()
-		err = updateProgressUsingPod(dv, pod)
+		err = updateProgressUsingPod(dv, pvc, pod)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(dv.Status.Progress).To(BeEquivalentTo("13.45%"))
 	})
@@ -572,10 +709,41 @@ var _ = Describe("Update Progress from pod", func() {
 		Expect(err).ToNot(HaveOccurred())
 		pod.Spec.Containers[0].Ports[0].ContainerPort = int32(port)
 		pod.Status.PodIP = ep.Hostname()
-		err = updateProgressUsingPod(dv, pod)
+		err = updateProgressUsingPod(dv, pvc, pod)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(dv.Status.Progress).To(BeEquivalentTo("2.3%"))
 	})
+
+	It("Should present the metrics client certificate to an endpoint asking for one", func() {
+		clientCA, err := triple.NewCA("metrics-client")
+		Expect(err).ToNot(HaveOccurred())
+		clientKeyPair, err := triple.NewClientKeyPair(clientCA, "client.metrics.cdi.kubevirt.io", nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer func(f fetcher.CertFetcher) { metricsClientCertFetcher = f }(metricsClientCertFetcher)
+		metricsClientCertFetcher = &fetcher.MemCertFetcher{
+			Cert: cert.EncodeCertPEM(clientKeyPair.Cert),
+			Key:  cert.EncodePrivateKeyPEM(clientKeyPair.Key),
+		}
+
+		dv.SetUID("b856691e-1038-11e9-a5ab-525500d15501")
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(fmt.Sprintf("clone_progress{ownerUID=\"%v\"} 42", dv.GetUID())))
+		}))
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(clientCA.Cert)
+		ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		ts.StartTLS()
+		defer ts.Close()
+		ep, err := url.Parse(ts.URL)
+		Expect(err).ToNot(HaveOccurred())
+		port, err := strconv.Atoi(ep.Port())
+		Expect(err).ToNot(HaveOccurred())
+		pod.Spec.Containers[0].Ports[0].ContainerPort = int32(port)
+		pod.Status.PodIP = ep.Hostname()
+		err = updateProgressUsingPod(dv, pvc, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dv.Status.Progress).To(BeEquivalentTo("42.00%"))
+	})
 })
 
 func createDatavolumeReconciler(objects ...runtime.Object) *DatavolumeReconciler {
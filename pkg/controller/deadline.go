@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// TransferTimeout provides a const to indicate a transfer pod ran longer than its deadline
+	TransferTimeout = "TransferTimeout"
+
+	// deadlineRetries is how often a transfer whose pod ran longer than its deadline is retried before it fails
+	deadlineRetries = 1
+)
+
+// getTransferDeadline returns how long the transfer pods of pvc may run, the AnnTransferDeadline annotation of
+// pvc or else the TransferDeadline of the CDIConfig, 0 if they may run until they are done
+func getTransferDeadline(c client.Client, pvc *v1.PersistentVolumeClaim) (time.Duration, error) {
+	if value, ok := pvc.GetAnnotations()[AnnTransferDeadline]; ok {
+		if deadline, err := time.ParseDuration(value); err == nil && deadline > 0 {
+			return deadline, nil
+		}
+	}
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if cdiconfig.Spec.TransferDeadline != nil && cdiconfig.Spec.TransferDeadline.Duration > 0 {
+		return cdiconfig.Spec.TransferDeadline.Duration, nil
+	}
+	return 0, nil
+}
+
+// deadlineExceededCount returns how often the transfer pods of pvc ran longer than their deadline
+func deadlineExceededCount(pvc *v1.PersistentVolumeClaim) int {
+	count, _ := strconv.Atoi(pvc.GetAnnotations()[AnnDeadlineExceeded])
+	return count
+}
+
+// isTransferTimedOut returns true if the transfer to pvc failed because its pods ran longer than their deadline
+// more often than the transfer is retried
+func isTransferTimedOut(pvc *v1.PersistentVolumeClaim) bool {
+	return deadlineExceededCount(pvc) > deadlineRetries
+}
+
+// checkTransferDeadline deletes pod, and the other pods of the same transfer, once pod has run longer than the
+// deadline of pvc. The pod counts from its creation, so that a pod that never gets scheduled is terminated too.
+// The transfer is retried deadlineRetries times, then the PVC is marked failed. It returns true if the pods were
+// deleted, or else how long until the deadline is reached, 0 if the transfer has no deadline.
+func checkTransferDeadline(c client.Client, recorder record.EventRecorder, pvc *v1.PersistentVolumeClaim, pod *v1.Pod, otherPods ...*v1.Pod) (bool, time.Duration, error) {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false, 0, nil
+	}
+	deadline, err := getTransferDeadline(c, pvc)
+	if err != nil || deadline == 0 {
+		return false, 0, err
+	}
+	if remaining := pod.CreationTimestamp.Add(deadline).Sub(time.Now()); remaining > 0 {
+		return false, remaining, nil
+	}
+
+	for _, p := range append([]*v1.Pod{pod}, otherPods...) {
+		if err := c.Delete(context.TODO(), p); IgnoreNotFound(err) != nil {
+			return false, 0, err
+		}
+	}
+	count := deadlineExceededCount(pvc) + 1
+	pvc.GetAnnotations()[AnnDeadlineExceeded] = strconv.Itoa(count)
+	// A new clone source pod has to wait for the new upload server
+	delete(pvc.GetAnnotations(), AnnPodReady)
+	message := fmt.Sprintf("Transfer pod %s ran longer than its deadline of %s", pod.Name, deadline)
+	if count > deadlineRetries {
+		pvc.GetAnnotations()[AnnPodPhase] = string(v1.PodFailed)
+		message = fmt.Sprintf("%s, giving up after %d attempts", message, count)
+	} else {
+		message = fmt.Sprintf("%s, retrying", message)
+	}
+	if err := c.Update(context.TODO(), pvc); err != nil {
+		return false, 0, err
+	}
+	recorder.Event(pvc, v1.EventTypeWarning, TransferTimeout, message)
+	return true, 0, nil
+}
+
+// updateTimeoutCondition reflects the AnnDeadlineExceeded annotation of pvc in the Timeout condition of dataVolume
+func updateTimeoutCondition(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	current := conditions.FindStatusCondition(dataVolume.Status.Conditions, cdiv1.DataVolumeTimeout)
+	count := deadlineExceededCount(pvc)
+	if count == 0 {
+		return
+	}
+	if podSucceededFromPVC(pvc) {
+		if current != nil && current.Status != v1.ConditionFalse {
+			conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+				Type:   cdiv1.DataVolumeTimeout,
+				Status: v1.ConditionFalse,
+				Reason: string(cdiv1.Succeeded),
+			})
+		}
+		return
+	}
+	message := fmt.Sprintf("Transfer pod ran longer than its deadline, retried %d of %d times", count, deadlineRetries)
+	if isTransferTimedOut(pvc) {
+		message = fmt.Sprintf("Transfer pod ran longer than its deadline, failed after %d attempts", count)
+	}
+	if current == nil || current.Status != v1.ConditionTrue || current.Message != message {
+		conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+			Type:    cdiv1.DataVolumeTimeout,
+			Status:  v1.ConditionTrue,
+			Reason:  TransferTimeout,
+			Message: message,
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Transfer deadline", func() {
+	createConfig := func(deadline time.Duration) *cdiv1.CDIConfig {
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		if deadline > 0 {
+			cdiConfig.Spec.TransferDeadline = &metav1.Duration{Duration: deadline}
+		}
+		return cdiConfig
+	}
+
+	table.DescribeTable("Should get the deadline", func(annotation string, configured, expected time.Duration) {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, createConfig(configured))
+		pvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		if annotation != "" {
+			pvc.Annotations[AnnTransferDeadline] = annotation
+		}
+		deadline, err := getTransferDeadline(c, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deadline).To(Equal(expected))
+	},
+		table.Entry("of the data volume", "30m0s", time.Hour, 30*time.Minute),
+		table.Entry("of the CDIConfig", "", time.Hour, time.Hour),
+		table.Entry("of the CDIConfig if the annotation is invalid", "soon", time.Hour, time.Hour),
+		table.Entry("none if neither is set", "", time.Duration(0), time.Duration(0)),
+	)
+
+	It("Should not terminate a pod before its deadline", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnTransferDeadline: "1h"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, createConfig(0), pvc, pod)
+		exceeded, untilDeadline, err := checkTransferDeadline(c, record.NewFakeRecorder(10), pvc, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exceeded).To(BeFalse())
+		Expect(untilDeadline).To(BeNumerically(">", 45*time.Minute))
+		Expect(untilDeadline).To(BeNumerically("<=", 50*time.Minute))
+	})
+
+	It("Should terminate a pod after its deadline and retry once", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnTransferDeadline: "1h"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, createConfig(0), pvc, pod)
+		recorder := record.NewFakeRecorder(10)
+
+		exceeded, _, err := checkTransferDeadline(c, recorder, pvc, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exceeded).To(BeTrue())
+		err = c.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(pvc.Annotations[AnnDeadlineExceeded]).To(Equal("1"))
+		Expect(isTransferTimedOut(pvc)).To(BeFalse())
+		Expect(<-recorder.Events).To(ContainSubstring("retrying"))
+
+		By("Giving up when the retry runs longer than the deadline too")
+		pod = createImporterTestPod(pvc, "testPvc1", nil)
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		Expect(c.Create(context.TODO(), pod)).To(Succeed())
+		exceeded, _, err = checkTransferDeadline(c, recorder, pvc, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exceeded).To(BeTrue())
+		Expect(isTransferTimedOut(pvc)).To(BeTrue())
+		Expect(pvc.Annotations[AnnPodPhase]).To(Equal(string(corev1.PodFailed)))
+		Expect(<-recorder.Events).To(ContainSubstring("giving up after 2 attempts"))
+	})
+
+	It("Should not terminate a pod that is being deleted", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnTransferDeadline: "1h"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		now := metav1.Now()
+		pod.DeletionTimestamp = &now
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, createConfig(0), pvc, pod)
+		exceeded, _, err := checkTransferDeadline(c, record.NewFakeRecorder(10), pvc, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exceeded).To(BeFalse())
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnDeadlineExceeded))
+	})
+
+	table.DescribeTable("Should reflect the exceeded deadline in the Timeout condition", func(count, phase string, expectedStatus corev1.ConditionStatus) {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", "default", map[string]string{AnnDeadlineExceeded: count, AnnPodPhase: phase}, nil)
+		updateTimeoutCondition(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeTimeout)
+		if expectedStatus == "" {
+			Expect(condition).To(BeNil())
+			return
+		}
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(expectedStatus))
+	},
+		table.Entry("not set without an exceeded deadline", "", string(corev1.PodRunning), corev1.ConditionStatus("")),
+		table.Entry("true while retrying", "1", string(corev1.PodRunning), corev1.ConditionTrue),
+		table.Entry("true after giving up", "2", string(corev1.PodFailed), corev1.ConditionTrue),
+	)
+})
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// isEvictionAllowed returns true if an admin allowed the transfer pods of pvc to be evicted while they move data
+func isEvictionAllowed(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.GetAnnotations()[AnnAllowEviction] == "true"
+}
+
+// reconcileDisruptionBudget keeps a PodDisruptionBudget that prevents the eviction of pod, such as by a node drain,
+// while pod is running, so a long transfer is not restarted from the beginning. The budget is owned by pod and
+// named after it. It is deleted once pod is done, or if the AnnAllowEviction annotation of pvc allows evicting pod.
+func reconcileDisruptionBudget(c client.Client, pvc *v1.PersistentVolumeClaim, pod *v1.Pod) error {
+	budget := &policyv1beta1.PodDisruptionBudget{}
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, budget)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	protect := pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil && !isEvictionAllowed(pvc)
+	if !protect {
+		if exists {
+			return IgnoreNotFound(c.Delete(context.TODO(), budget))
+		}
+		return nil
+	}
+	if exists {
+		return nil
+	}
+
+	maxUnavailable := intstr.FromInt(0)
+	budget = &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Labels: map[string]string{
+				common.CDILabelKey: common.CDILabelValue,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "Pod",
+					Name:       pod.Name,
+					UID:        pod.UID,
+				},
+			},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: pod.GetLabels(),
+			},
+		},
+	}
+	if err := c.Create(context.TODO(), budget); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Transfer pod disruption budget", func() {
+	getBudget := func(c client.Client, pod *corev1.Pod) (*policyv1beta1.PodDisruptionBudget, error) {
+		budget := &policyv1beta1.PodDisruptionBudget{}
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, budget)
+		return budget, err
+	}
+
+	It("Should prevent evicting a running transfer pod", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.Status.Phase = corev1.PodRunning
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, pvc, pod)
+		Expect(reconcileDisruptionBudget(c, pvc, pod)).To(Succeed())
+		budget, err := getBudget(c, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(budget.Spec.MaxUnavailable.IntValue()).To(Equal(0))
+		Expect(budget.Spec.Selector.MatchLabels).To(Equal(pod.GetLabels()))
+		Expect(budget.OwnerReferences[0].Name).To(Equal(pod.Name))
+
+		By("Allowing the eviction once the pod is done")
+		pod.Status.Phase = corev1.PodSucceeded
+		Expect(reconcileDisruptionBudget(c, pvc, pod)).To(Succeed())
+		_, err = getBudget(c, pod)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should allow evicting a running transfer pod if an admin allows it", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnAllowEviction: "true"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.Status.Phase = corev1.PodRunning
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, pvc, pod)
+		Expect(reconcileDisruptionBudget(c, pvc, pod)).To(Succeed())
+		_, err := getBudget(c, pod)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should not protect a pod that is not running yet", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.Status.Phase = corev1.PodPending
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, pvc, pod)
+		Expect(reconcileDisruptionBudget(c, pvc, pod)).To(Succeed())
+		_, err := getBudget(c, pod)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,506 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	cdiclientset "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// AnnExportDestination provides a const for the registry url a PVC is exported to as a containerDisk
+	AnnExportDestination = AnnAPIGroup + "/storage.export.destination"
+	// AnnExportSecret provides a const for the secret containing the destination registry credentials
+	AnnExportSecret = AnnAPIGroup + "/storage.export.secretName"
+	// AnnExportCertConfigMap is the name of a configmap containing the destination registry tls certs
+	AnnExportCertConfigMap = AnnAPIGroup + "/storage.export.certConfigMap"
+	// AnnExportPod provides a const for our PVC exportPodName annotation
+	AnnExportPod = AnnAPIGroup + "/storage.export.exportPodName"
+	// AnnExportPodPhase is a PVC annotation indicating the related export pod progress (phase)
+	AnnExportPodPhase = AnnAPIGroup + "/storage.export.pod.phase"
+	// AnnExportedDestination is a PVC annotation holding the destination AnnExportPodPhase refers to
+	AnnExportedDestination = AnnAPIGroup + "/storage.export.exportedDestination"
+
+	// LabelExportPvc is a pod label used to find the export pod that was created by the relevant PVC
+	LabelExportPvc = AnnAPIGroup + "/storage.export.exportPvcName"
+
+	// ErrExportFailedPVC provides a const to indicate an export of the PVC failed
+	ErrExportFailedPVC = "ErrExportFailed"
+	// ExportSucceededPVC provides a const to indicate an export of the PVC succeeded
+	ExportSucceededPVC = "ExportSucceeded"
+
+	// exportMaxRestarts is how often a failing exporter container is restarted before the export is failed
+	exportMaxRestarts = 5
+)
+
+// ExportReconciler members
+type ExportReconciler struct {
+	Client     client.Client
+	CdiClient  cdiclientset.Interface
+	K8sClient  kubernetes.Interface
+	recorder   record.EventRecorder
+	Scheme     *runtime.Scheme
+	Log        logr.Logger
+	Image      string
+	Verbose    string
+	PullPolicy string
+}
+
+type exportPodEnvVar struct {
+	destination, secretName, certConfigMap, imageName string
+	insecureTLS                                       bool
+}
+
+// NewExportController creates a new instance of the export controller.
+func NewExportController(mgr manager.Manager, cdiClient *cdiclientset.Clientset, k8sClient kubernetes.Interface, log logr.Logger, exporterImage, pullPolicy, verbose string) (controller.Controller, error) {
+	reconciler := &ExportReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		CdiClient:  cdiClient,
+		K8sClient:  k8sClient,
+		Log:        newSampledLogger(log.WithName("export-controller")),
+		Image:      exporterImage,
+		Verbose:    verbose,
+		PullPolicy: pullPolicy,
+		recorder:   mgr.GetEventRecorderFor("export-controller"),
+	}
+	exportController, err := controller.New("export-controller", mgr, controller.Options{
+		Reconciler: reconciler,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := addExportControllerWatches(mgr, exportController); err != nil {
+		return nil, err
+	}
+	return exportController, nil
+}
+
+func addExportControllerWatches(mgr manager.Manager, exportController controller.Controller) error {
+	// Setup watches
+	if err := exportController.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	if err := exportController.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &corev1.PersistentVolumeClaim{},
+		IsController: true,
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func shouldExportPVC(pvc *corev1.PersistentVolumeClaim) bool {
+	return checkPVC(pvc, AnnExportDestination) && !isExportComplete(pvc) && !isExportFailed(pvc)
+}
+
+func isExportComplete(pvc *corev1.PersistentVolumeClaim) bool {
+	phase, exists := pvc.ObjectMeta.Annotations[AnnExportPodPhase]
+	return exists && (phase == string(corev1.PodSucceeded)) && isExportedToDestination(pvc)
+}
+
+// isExportFailed returns true if the export to the current destination failed and is not retried.
+func isExportFailed(pvc *corev1.PersistentVolumeClaim) bool {
+	phase, exists := pvc.ObjectMeta.Annotations[AnnExportPodPhase]
+	return exists && (phase == string(corev1.PodFailed)) && isExportedToDestination(pvc)
+}
+
+// isExportedToDestination returns true if the export phase of the PVC refers to its current destination, a
+// changed destination is exported again.
+func isExportedToDestination(pvc *corev1.PersistentVolumeClaim) bool {
+	exported, ok := pvc.Annotations[AnnExportedDestination]
+	return !ok || exported == pvc.Annotations[AnnExportDestination]
+}
+
+// isExportPodForDestination returns true if the pod exports to the current destination of the PVC.
+func isExportPodForDestination(pod *corev1.Pod, pvc *corev1.PersistentVolumeClaim) bool {
+	destination, ok := pod.Annotations[AnnExportDestination]
+	return !ok || destination == pvc.Annotations[AnnExportDestination]
+}
+
+// isPVCPopulated returns true if the PVC is bound, and any import, upload or clone into it has completed.
+func isPVCPopulated(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return false
+	}
+	for _, ann := range []string{AnnEndpoint, AnnSource, AnnUploadRequest, AnnCloneRequest} {
+		if _, ok := pvc.Annotations[ann]; ok {
+			return podSucceededFromPVC(pvc)
+		}
+	}
+	return true
+}
+
+// Reconcile the reconcile loop for PVCs that are exported to a registry.
+func (r *ExportReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log := r.Log.WithValues(logKeyPVC, req.NamespacedName)
+	log.V(1).Info("reconciling Export PVCs")
+
+	// Get the PVC.
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), req.NamespacedName, pvc); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if !shouldExportPVC(pvc) {
+		log.V(1).Info("Should not export this PVC", "pvc.annotation.export.complete", isExportComplete(pvc),
+			"pvc.annotation.export.failed", isExportFailed(pvc), "pvc.annotations.export.destination", checkPVC(pvc, AnnExportDestination))
+		return reconcile.Result{}, nil
+	}
+
+	pod, err := r.findExporterPod(pvc, log)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if pod == nil {
+		if pvc.DeletionTimestamp != nil {
+			return reconcile.Result{}, nil
+		}
+		if !isPVCPopulated(pvc) {
+			log.V(1).Info("PVC is not populated yet, waiting before export")
+			return reconcile.Result{}, nil
+		}
+		// Create exporter pod, make sure the PVC owns it.
+		return reconcile.Result{}, r.createExporterPod(pvc)
+	}
+
+	if pvc.DeletionTimestamp != nil {
+		log.V(1).Info("PVC being terminated, delete pods", logKeyPod, logObjectKey(pod))
+		if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if !isExportPodForDestination(pod, pvc) {
+		// The destination changed during the export, start over once the pod is gone.
+		log.V(1).Info("Export destination changed, delete pod", logKeyPod, logObjectKey(pod))
+		if pod.DeletionTimestamp == nil {
+			if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		return reconcile.Result{Requeue: true}, nil
+	}
+
+	// Pod exists, we need to update the PVC status.
+	return reconcile.Result{}, r.updatePvcFromPod(pvc, pod, log)
+}
+
+func (r *ExportReconciler) findExporterPod(pvc *corev1.PersistentVolumeClaim, log logr.Logger) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: exportPodNameFromPvc(pvc), Namespace: pvc.GetNamespace()}, pod)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !metav1.IsControlledBy(pod, pvc) {
+		return nil, errors.Errorf("Pod %s is not owned by PVC %s", pod.Name, pvc.Name)
+	}
+	log.V(1).Info("Pod is owned by PVC", pod.Name, pvc.Name)
+	return pod, nil
+}
+
+func (r *ExportReconciler) updatePvcFromPod(pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod, log logr.Logger) error {
+	// Keep a copy of the original for comparison later.
+	currentPvcCopy := pvc.DeepCopyObject()
+
+	log.V(1).Info("Updating PVC from export pod")
+	anno := pvc.GetAnnotations()
+	phase := pod.Status.Phase
+	if pod.Status.ContainerStatuses != nil &&
+		pod.Status.ContainerStatuses[0].LastTerminationState.Terminated != nil &&
+		pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode > 0 {
+		log.Info("Pod termination code", logKeyPod, logObjectKey(pod), "ExitCode", pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode)
+		r.recorder.Event(pvc, corev1.EventTypeWarning, ErrExportFailedPVC, terminationMessage(pod.Status.ContainerStatuses[0].LastTerminationState.Terminated).Message)
+		if pod.Status.ContainerStatuses[0].RestartCount >= exportMaxRestarts {
+			// The kubelet backs off between restarts, give up after a few of them
+			phase = corev1.PodFailed
+		}
+	}
+	anno[AnnExportPod] = pod.Name
+	anno[AnnExportPodPhase] = string(phase)
+	anno[AnnExportedDestination] = anno[AnnExportDestination]
+	if destination, ok := pod.Annotations[AnnExportDestination]; ok {
+		anno[AnnExportedDestination] = destination
+	}
+
+	if !reflect.DeepEqual(currentPvcCopy, pvc) {
+		if err := r.Client.Update(context.TODO(), pvc); err != nil {
+			return err
+		}
+		log.V(1).Info("Updated PVC", logKeyPhase, anno[AnnExportPodPhase])
+	}
+
+	if isExportComplete(pvc) {
+		r.recorder.Event(pvc, corev1.EventTypeNormal, ExportSucceededPVC, fmt.Sprintf("Export to %s Successful", anno[AnnExportDestination]))
+		log.V(1).Info("Completed successfully, deleting POD", logKeyPod, logObjectKey(pod))
+		if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return err
+		}
+	} else if isExportFailed(pvc) {
+		r.recorder.Event(pvc, corev1.EventTypeWarning, ErrExportFailedPVC, fmt.Sprintf("Export to %s failed", anno[AnnExportDestination]))
+		log.V(1).Info("Failed, deleting POD", logKeyPod, logObjectKey(pod))
+		if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ExportReconciler) createExporterPod(pvc *corev1.PersistentVolumeClaim) error {
+	r.Log.V(1).Info("Creating exporter POD for PVC", "pvc.Name", pvc.Name)
+	podEnvVar, err := r.createExportEnvVar(pvc)
+	if err != nil {
+		return err
+	}
+	podResourceRequirements, err := GetDefaultPodResourceRequirements(r.Client)
+	if err != nil {
+		return err
+	}
+
+	scratchPvcName := scratchNameFromPvc(pvc)
+	pod := makeExporterPodSpec(r.Image, r.Verbose, r.PullPolicy, podEnvVar, pvc, scratchPvcName, podResourceRequirements)
+	// The exporter runs the importer binary
+	if err := setSecurityProfiles(r.Client, pod, importerSeccompProfile); err != nil {
+		return err
+	}
+	pod, err = createPodIfNotExists(r.Client, pod)
+	if err != nil {
+		return err
+	}
+	r.Log.V(1).Info("Created POD", logKeyPod, logObjectKey(pod))
+
+	// The container image is assembled in scratch space before it is pushed.
+	storageClassName := GetScratchPvcStorageClass(r.K8sClient, r.CdiClient, pvc)
+	maxSize, err := getScratchSpaceMaxSize(r.Client)
+	if err != nil {
+		return err
+	}
+	pool, err := getScratchSpacePool(r.Client)
+	if err != nil {
+		return err
+	}
+	if _, err = CreateScratchPersistentVolumeClaim(r.K8sClient, pvc, pod, scratchPvcName, storageClassName, maxSize, pool); err != nil {
+		if !k8serrors.IsAlreadyExists(errors.Cause(err)) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ExportReconciler) createExportEnvVar(pvc *corev1.PersistentVolumeClaim) (*exportPodEnvVar, error) {
+	var err error
+	podEnvVar := &exportPodEnvVar{
+		destination:   pvc.Annotations[AnnExportDestination],
+		secretName:    pvc.Annotations[AnnExportSecret],
+		certConfigMap: pvc.Annotations[AnnExportCertConfigMap],
+		imageName:     pvc.Annotations[AnnImageName],
+	}
+	podEnvVar.insecureTLS, err = isInsecureTLSEndpoint(r.K8sClient, podEnvVar.destination)
+	if err != nil {
+		return nil, err
+	}
+	return podEnvVar, nil
+}
+
+func exportPodNameFromPvc(pvc *corev1.PersistentVolumeClaim) string {
+	return fmt.Sprintf("%s-%s", common.ExporterPodName, pvc.Name)
+}
+
+// makeExporterPodSpec creates and returns the exporter pod spec based on the passed-in destination, secret and pvc.
+func makeExporterPodSpec(image, verbose, pullPolicy string, podEnvVar *exportPodEnvVar, pvc *corev1.PersistentVolumeClaim, scratchPvcName string, podResourceRequirements *corev1.ResourceRequirements) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      exportPodNameFromPvc(pvc),
+			Namespace: pvc.Namespace,
+			Annotations: map[string]string{
+				AnnCreatedBy:         "yes",
+				AnnExportDestination: podEnvVar.destination,
+			},
+			Labels: map[string]string{
+				common.CDILabelKey:       common.CDILabelValue,
+				common.CDIComponentLabel: common.ExporterPodName,
+				// this label is used when searching for a pvc's export pod.
+				LabelExportPvc: pvc.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				MakePVCOwnerReference(pvc),
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:            common.ExporterPodName,
+					Image:           image,
+					ImagePullPolicy: corev1.PullPolicy(pullPolicy),
+					Args:            []string{"-v=" + verbose},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      ScratchVolName,
+							MountPath: common.ScratchDataDir,
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyOnFailure,
+			Volumes: []corev1.Volume{
+				{
+					Name: DataVolName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: pvc.Name,
+							ReadOnly:  true,
+						},
+					},
+				},
+				{
+					Name: ScratchVolName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: scratchPvcName,
+							ReadOnly:  false,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if podResourceRequirements != nil {
+		pod.Spec.Containers[0].Resources = *podResourceRequirements
+	}
+
+	if getVolumeMode(pvc) == corev1.PersistentVolumeBlock {
+		pod.Spec.Containers[0].VolumeDevices = addVolumeDevices()
+		pod.Spec.SecurityContext = &corev1.PodSecurityContext{
+			RunAsUser: &[]int64{0}[0],
+		}
+	} else {
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      DataVolName,
+			MountPath: common.ImporterDataDir,
+			ReadOnly:  true,
+		})
+	}
+
+	pod.Spec.Containers[0].Env = makeExportEnv(podEnvVar, pvc.UID)
+
+	if podEnvVar.certConfigMap != "" {
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      CertVolName,
+			MountPath: common.ImporterCertDir,
+		})
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: CertVolName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: podEnvVar.certConfigMap,
+					},
+				},
+			},
+		})
+	}
+	setReadOnlyRootFilesystem(pod)
+	return pod
+}
+
+// return the Env portion for the exporter container.
+func makeExportEnv(podEnvVar *exportPodEnvVar, uid types.UID) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{
+			Name:  common.ExporterDestination,
+			Value: podEnvVar.destination,
+		},
+		{
+			Name:  common.OwnerUID,
+			Value: string(uid),
+		},
+		{
+			Name:  common.InsecureTLSVar,
+			Value: strconv.FormatBool(podEnvVar.insecureTLS),
+		},
+	}
+	if podEnvVar.secretName != "" {
+		env = append(env, corev1.EnvVar{
+			Name: common.ImporterAccessKeyID,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: podEnvVar.secretName,
+					},
+					Key: common.KeyAccess,
+				},
+			},
+		}, corev1.EnvVar{
+			Name: common.ImporterSecretKey,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: podEnvVar.secretName,
+					},
+					Key: common.KeySecret,
+				},
+			},
+		})
+	}
+	if podEnvVar.certConfigMap != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterCertDirVar,
+			Value: common.ImporterCertDir,
+		})
+	}
+	if podEnvVar.imageName != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterImageName,
+			Value: podEnvVar.imageName,
+		})
+	}
+	return env
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	cdifake "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const testExportDestination = "docker://registry.example.com/vmdisk:latest"
+
+var exportLog = logf.Log.WithName("export-controller-test")
+
+var _ = Describe("Export PVC annotations status", func() {
+	It("Should be interesting if destination is set and export is not complete", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination}, nil)
+		Expect(shouldExportPVC(testPvc)).To(BeTrue())
+	})
+
+	It("Should NOT be interesting if export is complete", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination, AnnExportPodPhase: string(corev1.PodSucceeded)}, nil)
+		Expect(shouldExportPVC(testPvc)).To(BeFalse())
+	})
+
+	It("Should be interesting if it was exported to a different destination", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination, AnnExportPodPhase: string(corev1.PodSucceeded), AnnExportedDestination: "docker://registry.example.com/vmdisk:old"}, nil)
+		Expect(shouldExportPVC(testPvc)).To(BeTrue())
+	})
+
+	It("Should NOT be interesting if export failed", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination, AnnExportPodPhase: string(corev1.PodFailed), AnnExportedDestination: testExportDestination}, nil)
+		Expect(shouldExportPVC(testPvc)).To(BeFalse())
+	})
+
+	It("Should NOT be interesting if destination is missing", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		Expect(shouldExportPVC(testPvc)).To(BeFalse())
+	})
+
+	It("Should not be populated if the import is still running", func() {
+		testPvc := createBoundPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodRunning)})
+		Expect(isPVCPopulated(testPvc)).To(BeFalse())
+	})
+
+	It("Should be populated if the import succeeded", func() {
+		testPvc := createBoundPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodSucceeded)})
+		Expect(isPVCPopulated(testPvc)).To(BeTrue())
+	})
+
+	It("Should be populated if the PVC was not created by CDI", func() {
+		testPvc := createBoundPvc("testPvc1", "default", map[string]string{})
+		Expect(isPVCPopulated(testPvc)).To(BeTrue())
+	})
+
+	It("Should not be populated if the PVC is not bound", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		Expect(isPVCPopulated(testPvc)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Export controller reconcile loop", func() {
+	var (
+		reconciler *ExportReconciler
+	)
+	AfterEach(func() {
+		if reconciler != nil {
+			close(reconciler.recorder.(*record.FakeRecorder).Events)
+			reconciler = nil
+		}
+	})
+
+	It("Should not create a POD if the PVC is not populated", func() {
+		reconciler = createExportReconciler(createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination, AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodRunning)}))
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, pod)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should create a POD and scratch PVC if the PVC is populated", func() {
+		reconciler = createExportReconciler(createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination, AnnExportSecret: "secret", AnnExportCertConfigMap: "certs"}))
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, pod)
+		Expect(err).ToNot(HaveOccurred())
+		env := map[string]corev1.EnvVar{}
+		for _, envVar := range pod.Spec.Containers[0].Env {
+			env[envVar.Name] = envVar
+		}
+		Expect(env[common.ExporterDestination].Value).To(Equal(testExportDestination))
+		Expect(env[common.ImporterAccessKeyID].ValueFrom.SecretKeyRef.Name).To(Equal("secret"))
+		Expect(env[common.ImporterCertDirVar].Value).To(Equal(common.ImporterCertDir))
+		By("Verifying the PVC is mounted read only")
+		Expect(pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("testPvc1"))
+		Expect(pod.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+		By("Verifying the scratch PVC is created")
+		_, err = reconciler.K8sClient.CoreV1().PersistentVolumeClaims("default").Get("testPvc1-scratch", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("Should confine the POD to the security profiles of the CDIConfig", func() {
+		reconciler = createExportReconciler(createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination}))
+		cdiConfig := &cdiv1.CDIConfig{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiConfig)).To(Succeed())
+		cdiConfig.Spec.SecurityProfiles = &cdiv1.SecurityProfiles{Seccomp: true, AppArmor: true}
+		Expect(reconciler.Client.Update(context.TODO(), cdiConfig)).To(Succeed())
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, pod)).To(Succeed())
+		Expect(pod.Annotations).To(HaveKeyWithValue(corev1.SeccompPodAnnotationKey, localhostProfilePrefix+importerSeccompProfile))
+		Expect(pod.Annotations).To(HaveKeyWithValue(AnnAppArmorProfilePrefix+common.ExporterPodName, localhostProfilePrefix+transferAppArmorProfile))
+	})
+
+	It("Should update the PVC and delete the POD when the export succeeded", func() {
+		pvc := createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination})
+		pod := makeExporterPodSpec("test/image", "5", "Always", &exportPodEnvVar{destination: testExportDestination}, pvc, "testPvc1-scratch", nil)
+		pod.Status.Phase = corev1.PodSucceeded
+		reconciler = createExportReconciler(pvc, pod)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		By("Checking export successful event recorded")
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(ExportSucceededPVC))
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.GetAnnotations()[AnnExportPodPhase]).To(BeEquivalentTo(corev1.PodSucceeded))
+		Expect(resPvc.GetAnnotations()[AnnExportPod]).To(Equal("exporter-testPvc1"))
+		By("Checking pod has been deleted")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should record an event if the export POD failed", func() {
+		pvc := createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination})
+		pod := makeExporterPodSpec("test/image", "5", "Always", &exportPodEnvVar{destination: testExportDestination}, pvc, "testPvc1-scratch", nil)
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "unauthorized",
+					},
+				},
+			},
+		}
+		reconciler = createExportReconciler(pvc, pod)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(ErrExportFailedPVC))
+		Expect(event).To(ContainSubstring("unauthorized"))
+	})
+
+	It("Should fail the export and delete the POD after too many restarts", func() {
+		pvc := createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination})
+		pod := makeExporterPodSpec("test/image", "5", "Always", &exportPodEnvVar{destination: testExportDestination}, pvc, "testPvc1-scratch", nil)
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				RestartCount: exportMaxRestarts,
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "unauthorized",
+					},
+				},
+			},
+		}
+		reconciler = createExportReconciler(pvc, pod)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.GetAnnotations()[AnnExportPodPhase]).To(BeEquivalentTo(corev1.PodFailed))
+		Expect(resPvc.GetAnnotations()[AnnExportedDestination]).To(Equal(testExportDestination))
+		Expect(shouldExportPVC(resPvc)).To(BeFalse())
+		<-reconciler.recorder.(*record.FakeRecorder).Events
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("Export to " + testExportDestination + " failed"))
+		By("Checking pod has been deleted")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should delete the POD if the destination changed during the export", func() {
+		pvc := createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination})
+		pod := makeExporterPodSpec("test/image", "5", "Always", &exportPodEnvVar{destination: "docker://registry.example.com/vmdisk:old"}, pvc, "testPvc1-scratch", nil)
+		pod.Status.Phase = corev1.PodRunning
+		reconciler = createExportReconciler(pvc, pod)
+		res, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Requeue).To(BeTrue())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should export again if the destination changed after the export succeeded", func() {
+		reconciler = createExportReconciler(createBoundPvc("testPvc1", "default", map[string]string{
+			AnnExportDestination:   testExportDestination,
+			AnnExportPodPhase:      string(corev1.PodSucceeded),
+			AnnExportedDestination: "docker://registry.example.com/vmdisk:old",
+		}))
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "exporter-testPvc1", Namespace: "default"}, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pod.GetAnnotations()[AnnExportDestination]).To(Equal(testExportDestination))
+	})
+
+	It("Should mount a block PVC as a device", func() {
+		pvc := createBlockPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination}, nil)
+		pod := makeExporterPodSpec("test/image", "5", "Always", &exportPodEnvVar{destination: testExportDestination}, pvc, "testPvc1-scratch", nil)
+		Expect(pod.Spec.Containers[0].VolumeDevices).To(Equal(addVolumeDevices()))
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(ConsistOf(
+			corev1.VolumeMount{Name: ScratchVolName, MountPath: common.ScratchDataDir},
+			corev1.VolumeMount{Name: TmpVolName, MountPath: common.TransferTmpDir},
+		))
+	})
+
+	It("Should run the POD with a read-only root filesystem", func() {
+		pvc := createBoundPvc("testPvc1", "default", map[string]string{AnnExportDestination: testExportDestination})
+		pod := makeExporterPodSpec("test/image", "5", "Always", &exportPodEnvVar{destination: testExportDestination}, pvc, "testPvc1-scratch", nil)
+		Expect(*pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: TmpVolName, MountPath: common.TransferTmpDir}))
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "TMPDIR", Value: common.TransferTmpDir}))
+	})
+})
+
+func createBoundPvc(name, ns string, annotations map[string]string) *corev1.PersistentVolumeClaim {
+	pvc := createPvc(name, ns, annotations, nil)
+	pvc.Status.Phase = corev1.ClaimBound
+	return pvc
+}
+
+func createExportReconciler(objects ...runtime.Object) *ExportReconciler {
+	objs := []runtime.Object{}
+	objs = append(objs, objects...)
+
+	// Register operator types with the runtime scheme.
+	s := scheme.Scheme
+	cdiv1.AddToScheme(s)
+
+	cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+	cdiConfig.Status = cdiv1.CDIConfigStatus{
+		ScratchSpaceStorageClass: testStorageClass,
+	}
+	objs = append(objs, cdiConfig)
+
+	r := &ExportReconciler{
+		Client:    fake.NewFakeClientWithScheme(s, objs...),
+		Scheme:    s,
+		Log:       exportLog,
+		recorder:  record.NewFakeRecorder(10),
+		CdiClient: cdifake.NewSimpleClientset(cdiConfig),
+		K8sClient: k8sfake.NewSimpleClientset(createStorageClass(testStorageClass, nil)),
+		Image:     "test/image",
+	}
+	return r
+}
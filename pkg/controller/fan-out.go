@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// FanOutPending provides a const to indicate an import waits for the upload servers of its fan-out targets
+	FanOutPending = "FanOutPending"
+
+	// fanOutRetryInterval is how often an import checks its fan-out targets again
+	fanOutRetryInterval = 10 * time.Second
+
+	// fanOutClientCertDuration is how long the importer pod of an import without a transfer deadline may stream to
+	// its fan-out targets
+	fanOutClientCertDuration = 24 * time.Hour
+)
+
+// fanOutTargetNames returns the names of the PVCs the import to pvc writes its image to as well
+func fanOutTargetNames(pvc *corev1.PersistentVolumeClaim) []string {
+	var names []string
+	for _, name := range strings.Split(pvc.GetAnnotations()[AnnFanOutTargets], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkFanOutTargets returns why the importer pod of pvc can not stream to its fan-out targets yet, empty if it can.
+// The targets have to be upload targets of the storage class and size of pvc, whose upload servers are ready and only
+// accept the importer pod of pvc. A target that accepts another client is annotated to accept the importer pod, which
+// has the upload controller restart its upload server. Targets that were already populated are skipped.
+func checkFanOutTargets(c client.Client, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	for _, name := range fanOutTargetNames(pvc) {
+		target := &corev1.PersistentVolumeClaim{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: pvc.Namespace, Name: name}, target); err != nil {
+			if k8serrors.IsNotFound(err) {
+				return fmt.Sprintf("fan-out target %s does not exist", name), nil
+			}
+			return "", err
+		}
+		if podSucceededFromPVC(target) {
+			continue
+		}
+		if _, ok := target.Annotations[AnnUploadRequest]; !ok {
+			return fmt.Sprintf("fan-out target %s is not an upload target", name), nil
+		}
+		if storageClassName(target.Spec.StorageClassName) != storageClassName(pvc.Spec.StorageClassName) {
+			return fmt.Sprintf("fan-out target %s has storage class %s, not %s", name, storageClassName(target.Spec.StorageClassName), storageClassName(pvc.Spec.StorageClassName)), nil
+		}
+		if size := target.Spec.Resources.Requests[corev1.ResourceStorage]; size.Cmp(requested) != 0 {
+			return fmt.Sprintf("fan-out target %s requests %s, not %s", name, size.String(), requested.String()), nil
+		}
+		clientName := fanOutClientName(pvc, target)
+		if current, ok := target.Annotations[AnnUploadClientName]; !ok {
+			target.Annotations[AnnUploadClientName] = clientName
+			if err := c.Update(context.TODO(), target); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("upload server of fan-out target %s is restarted for the import", name), nil
+		} else if current != clientName {
+			return fmt.Sprintf("fan-out target %s accepts another client", name), nil
+		}
+		if ready, _ := strconv.ParseBool(target.Annotations[AnnPodReady]); !ready {
+			return fmt.Sprintf("upload server of fan-out target %s is not ready", name), nil
+		}
+		pod := &corev1.Pod{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: target.Namespace, Name: getUploadResourceName(target.Name)}, pod); IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		if !isPodReady(pod) || uploadPodClientName(pod) != clientName {
+			return fmt.Sprintf("upload server of fan-out target %s is not ready", name), nil
+		}
+	}
+	return "", nil
+}
+
+// fanOutClientName returns the name of the client certificate the upload server of target accepts from the importer
+// pod of pvc
+func fanOutClientName(pvc, target *corev1.PersistentVolumeClaim) string {
+	return fmt.Sprintf("fan-out/%s/%s-%s/%s", pvc.Namespace, pvc.Name, target.Namespace, target.Name)
+}
+
+// waitForFanOutTargets records why the importer pod of pvc is not created yet, and returns when to check the
+// fan-out targets again.
+func waitForFanOutTargets(recorder record.EventRecorder, pvc *corev1.PersistentVolumeClaim, reason string) (reconcile.Result, error) {
+	recorder.Event(pvc, corev1.EventTypeNormal, FanOutPending, fmt.Sprintf("Not starting the import: %s", reason))
+	return reconcile.Result{RequeueAfter: fanOutRetryInterval}, nil
+}
+
+// setFanOutEnvVar has the importer pod of pvc stream the imported image to the upload servers of the fan-out targets
+// that were not populated yet, with a client certificate for each of them. The certificates expire with the transfer
+// deadline of pvc, after fanOutClientCertDuration without one.
+func (r *ImportReconciler) setFanOutEnvVar(pvc *corev1.PersistentVolumeClaim, podEnvVar *importPodEnvVar) error {
+	podEnvVar.fanOutURLs = nil
+	var targets []*corev1.PersistentVolumeClaim
+	for _, name := range fanOutTargetNames(pvc) {
+		target := &corev1.PersistentVolumeClaim{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: pvc.Namespace, Name: name}, target); err != nil {
+			return err
+		}
+		if !podSucceededFromPVC(target) {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	duration, err := getTransferDeadline(r.Client, pvc)
+	if err != nil {
+		return err
+	}
+	if duration == 0 {
+		duration = fanOutClientCertDuration
+	}
+	var clientCerts, clientKeys []string
+	for _, target := range targets {
+		clientCert, clientKey, err := r.clientCertGenerator.MakeClientCert(fanOutClientName(pvc, target), nil, duration)
+		if err != nil {
+			return err
+		}
+		podEnvVar.fanOutURLs = append(podEnvVar.fanOutURLs, GetUploadServerURL(target.Namespace, target.Name, common.UploadPathSync))
+		clientCerts = append(clientCerts, string(clientCert))
+		clientKeys = append(clientKeys, string(clientKey))
+	}
+	serverCABundle, err := r.serverCAFetcher.BundleBytes()
+	if err != nil {
+		return err
+	}
+	podEnvVar.fanOutClientCert = strings.Join(clientCerts, "")
+	podEnvVar.fanOutClientKey = strings.Join(clientKeys, "")
+	podEnvVar.fanOutServerCA = string(serverCABundle)
+	return nil
+}
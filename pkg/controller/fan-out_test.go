@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
+)
+
+// recordingCertGenerator makes client certificates of their name, recording their duration
+type recordingCertGenerator struct {
+	fakeCertGenerator
+	durations map[string]time.Duration
+}
+
+func (cg *recordingCertGenerator) MakeClientCert(name string, groups []string, duration time.Duration) ([]byte, []byte, error) {
+	cg.durations[name] = duration
+	return []byte("cert " + name + "\n"), []byte("key " + name + "\n"), nil
+}
+
+var _ = Describe("Fan-out", func() {
+	pvcName := types.NamespacedName{Name: "testPvc1", Namespace: "default"}
+
+	var certGenerator *recordingCertGenerator
+
+	BeforeEach(func() {
+		certGenerator = &recordingCertGenerator{durations: map[string]time.Duration{}}
+	})
+
+	createFanOutPvc := func() *corev1.PersistentVolumeClaim {
+		return createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnFanOutTargets: "target1,target2"}, nil)
+	}
+
+	targetClientName := func(name string) string {
+		return "fan-out/default/testPvc1-default/" + name
+	}
+
+	createTarget := func(name string, annotations map[string]string) *corev1.PersistentVolumeClaim {
+		pvcAnnotations := map[string]string{AnnUploadRequest: "", AnnPodReady: "true", AnnUploadClientName: targetClientName(name)}
+		for k, v := range annotations {
+			pvcAnnotations[k] = v
+		}
+		return createPvc(name, "default", pvcAnnotations, nil)
+	}
+
+	createUploadPod := func(target, clientName string, ready bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: getUploadResourceName(target), Namespace: "default"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Env: []corev1.EnvVar{{Name: "CLIENT_NAME", Value: clientName}}}},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+			},
+		}
+	}
+
+	createFanOutReconciler := func(objects ...runtime.Object) *ImportReconciler {
+		reconciler := createImportReconciler(objects...)
+		reconciler.clientCertGenerator = certGenerator
+		reconciler.serverCAFetcher = &fetcher.MemCertBundleFetcher{Bundle: []byte("baz")}
+		return reconciler
+	}
+
+	table.DescribeTable("Should check the fan-out targets", func(modify func(*corev1.PersistentVolumeClaim, *corev1.Pod), expected string) {
+		target := createTarget("target2", nil)
+		pod := createUploadPod("target2", targetClientName("target2"), true)
+		modify(target, pod)
+		reconciler := createFanOutReconciler(createTarget("target1", nil), createUploadPod("target1", targetClientName("target1"), true), target, pod)
+		reason, err := checkFanOutTargets(reconciler.Client, createFanOutPvc())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reason).To(Equal(expected))
+	},
+		table.Entry("accept ready upload targets", func(*corev1.PersistentVolumeClaim, *corev1.Pod) {}, ""),
+		table.Entry("wait for a missing target", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			target.Name = "other"
+		}, "fan-out target target2 does not exist"),
+		table.Entry("wait for an upload server that is not ready", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			target.Annotations[AnnPodReady] = "false"
+		}, "upload server of fan-out target target2 is not ready"),
+		table.Entry("wait for an upload pod that is not ready", func(_ *corev1.PersistentVolumeClaim, pod *corev1.Pod) {
+			pod.Status.ContainerStatuses[0].Ready = false
+		}, "upload server of fan-out target target2 is not ready"),
+		table.Entry("wait for a missing upload pod", func(_ *corev1.PersistentVolumeClaim, pod *corev1.Pod) {
+			pod.Name = "other"
+		}, "upload server of fan-out target target2 is not ready"),
+		table.Entry("wait for an upload server that accepts another client", func(_ *corev1.PersistentVolumeClaim, pod *corev1.Pod) {
+			pod.Spec.Containers[0].Env[0].Value = uploadServerClientName
+		}, "upload server of fan-out target target2 is not ready"),
+		table.Entry("restart the upload server of a target for the import", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			delete(target.Annotations, AnnUploadClientName)
+		}, "upload server of fan-out target target2 is restarted for the import"),
+		table.Entry("reject a target of another import", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			target.Annotations[AnnUploadClientName] = "fan-out/default/other-default/target2"
+		}, "fan-out target target2 accepts another client"),
+		table.Entry("reject a target that is not an upload target", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			delete(target.Annotations, AnnUploadRequest)
+		}, "fan-out target target2 is not an upload target"),
+		table.Entry("reject a target of another storage class", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			storageClass := "local"
+			target.Spec.StorageClassName = &storageClass
+		}, "fan-out target target2 has storage class local, not default"),
+		table.Entry("reject a target of another size", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			target.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("2G")
+		}, "fan-out target target2 requests 2G, not 1G"),
+		table.Entry("skip a target that was already populated", func(target *corev1.PersistentVolumeClaim, _ *corev1.Pod) {
+			delete(target.Annotations, AnnUploadRequest)
+			target.Annotations[AnnPodPhase] = string(corev1.PodSucceeded)
+		}, ""),
+	)
+
+	It("Should have the upload server of a target accept the importer pod", func() {
+		target := createTarget("target2", nil)
+		delete(target.Annotations, AnnUploadClientName)
+		reconciler := createFanOutReconciler(createTarget("target1", nil), createUploadPod("target1", targetClientName("target1"), true), target)
+
+		_, err := checkFanOutTargets(reconciler.Client, createFanOutPvc())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "target2", Namespace: "default"}, target)).To(Succeed())
+		Expect(target.Annotations[AnnUploadClientName]).To(Equal(targetClientName("target2")))
+	})
+
+	It("Should hold the importer pod until the upload servers of the targets are ready", func() {
+		target := createTarget("target2", map[string]string{AnnPodReady: "false"})
+		reconciler := createFanOutReconciler(createFanOutPvc(), createTarget("target1", nil), target,
+			createUploadPod("target1", targetClientName("target1"), true), createUploadPod("target2", targetClientName("target2"), true))
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(fanOutRetryInterval))
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+		Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(FanOutPending))
+
+		By("Creating the pod once they are ready")
+		target.Annotations[AnnPodReady] = "true"
+		Expect(reconciler.Client.Update(context.TODO(), target)).To(Succeed())
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, pod)).To(Succeed())
+		env := map[string]string{}
+		for _, e := range pod.Spec.Containers[0].Env {
+			env[e.Name] = e.Value
+		}
+		Expect(env[common.ImporterFanOutURLs]).To(Equal("https://cdi-upload-target1.default.svc/v1alpha1/upload,https://cdi-upload-target2.default.svc/v1alpha1/upload"))
+		Expect(env[common.ImporterFanOutClientCert]).To(Equal("cert " + targetClientName("target1") + "\ncert " + targetClientName("target2") + "\n"))
+		Expect(env[common.ImporterFanOutClientKey]).To(Equal("key " + targetClientName("target1") + "\nkey " + targetClientName("target2") + "\n"))
+		Expect(env[common.ImporterFanOutServerCACert]).To(Equal("baz"))
+	})
+
+	It("Should limit the client certificates to the transfer deadline", func() {
+		pvc := createFanOutPvc()
+		reconciler := createFanOutReconciler(pvc, createTarget("target1", nil), createTarget("target2", nil))
+		Expect(reconciler.setFanOutEnvVar(pvc, &importPodEnvVar{})).To(Succeed())
+		Expect(certGenerator.durations).To(Equal(map[string]time.Duration{
+			targetClientName("target1"): fanOutClientCertDuration,
+			targetClientName("target2"): fanOutClientCertDuration,
+		}))
+
+		pvc.Annotations[AnnTransferDeadline] = "2h"
+		Expect(reconciler.setFanOutEnvVar(pvc, &importPodEnvVar{})).To(Succeed())
+		Expect(certGenerator.durations[targetClientName("target1")]).To(Equal(2 * time.Hour))
+	})
+
+	It("Should only stream to the targets that were not populated yet", func() {
+		reconciler := createFanOutReconciler(
+			createFanOutPvc(),
+			createTarget("target1", map[string]string{AnnPodPhase: string(corev1.PodSucceeded)}),
+			createTarget("target2", nil),
+		)
+		podEnvVar := &importPodEnvVar{}
+		Expect(reconciler.setFanOutEnvVar(createFanOutPvc(), podEnvVar)).To(Succeed())
+		Expect(podEnvVar.fanOutURLs).To(Equal([]string{"https://cdi-upload-target2.default.svc/v1alpha1/upload"}))
+	})
+
+	It("Should not stream without fan-out targets", func() {
+		reconciler := createImportReconciler()
+		podEnvVar := &importPodEnvVar{}
+		Expect(reconciler.setFanOutEnvVar(createPvc("testPvc1", "default", nil, nil), podEnvVar)).To(Succeed())
+		Expect(podEnvVar.fanOutURLs).To(BeEmpty())
+		for _, e := range makeImportEnv(podEnvVar, "uid") {
+			Expect(e.Name).ToNot(Equal(common.ImporterFanOutURLs))
+		}
+	})
+
+	It("Should annotate the PVC of a data volume with its fan-out targets", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.FanOut = []string{"vm-2", "vm-3"}
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnFanOutTargets]).To(Equal("vm-2,vm-3"))
+	})
+})
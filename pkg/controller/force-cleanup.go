@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ForceCleanupPVC provides a const to indicate the CDI artifacts of a PVC were removed by a forced cleanup
+	ForceCleanupPVC = "ForceCleanup"
+
+	// cdiFinalizerPrefix is the prefix of the finalizers CDI adds to PVCs
+	cdiFinalizerPrefix = "cdi.kubevirt.io/"
+)
+
+// isForceCleanup returns true if an admin asked to remove the CDI artifacts of pvc with the AnnForceCleanup annotation
+func isForceCleanup(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.GetAnnotations()[AnnForceCleanup] == "true"
+}
+
+// forceCleanup removes the CDI artifacts of a PVC on a best-effort basis: a failure to remove one artifact does not
+// keep the others, and the failures are returned together by done.
+type forceCleanup struct {
+	client  client.Client
+	pvc     *v1.PersistentVolumeClaim
+	removed []string
+	errs    []error
+}
+
+func newForceCleanup(c client.Client, pvc *v1.PersistentVolumeClaim) *forceCleanup {
+	return &forceCleanup{client: c, pvc: pvc}
+}
+
+// delete deletes the object of kind named name into obj, unless it is gone or being deleted already. If owned is not
+// nil, the object is only deleted if owned returns true for it.
+func (f *forceCleanup) delete(kind string, name types.NamespacedName, obj runtime.Object, owned func() bool) {
+	if err := f.client.Get(context.TODO(), name, obj); err != nil {
+		if IgnoreNotFound(err) != nil {
+			f.errs = append(f.errs, err)
+		}
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		f.errs = append(f.errs, err)
+		return
+	}
+	if accessor.GetDeletionTimestamp() != nil || (owned != nil && !owned()) {
+		return
+	}
+	if err := f.client.Delete(context.TODO(), obj); err != nil {
+		if IgnoreNotFound(err) != nil {
+			f.errs = append(f.errs, err)
+		}
+		return
+	}
+	f.removed = append(f.removed, fmt.Sprintf("%s %s", kind, name))
+}
+
+// deleteScratchPvc deletes the scratch space PVC of the PVC
+func (f *forceCleanup) deleteScratchPvc() {
+	scratchPvc := &v1.PersistentVolumeClaim{}
+	f.delete("scratch PVC", types.NamespacedName{Namespace: f.pvc.Namespace, Name: scratchNameFromPvc(f.pvc)}, scratchPvc, func() bool {
+		_, ok := scratchPvc.Labels[scratchPvcLabel]
+		return ok && scratchPvc.Labels[LabelImportPvc] == f.pvc.Name
+	})
+}
+
+// removeFinalizers removes the CDI finalizers of the PVC
+func (f *forceCleanup) removeFinalizers() {
+	var kept, removed []string
+	for _, finalizer := range f.pvc.Finalizers {
+		if strings.HasPrefix(finalizer, cdiFinalizerPrefix) {
+			removed = append(removed, finalizer)
+		} else {
+			kept = append(kept, finalizer)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	pvc := f.pvc.DeepCopy()
+	pvc.Finalizers = kept
+	if err := f.client.Update(context.TODO(), pvc); err != nil {
+		f.errs = append(f.errs, err)
+		return
+	}
+	for _, finalizer := range removed {
+		f.removed = append(f.removed, "finalizer "+finalizer)
+	}
+}
+
+// done records an event on the PVC with what was removed, and returns the failures
+func (f *forceCleanup) done(recorder record.EventRecorder) error {
+	if len(f.removed) > 0 {
+		recorder.Event(f.pvc, v1.EventTypeNormal, ForceCleanupPVC, "Force cleanup removed "+strings.Join(f.removed, ", "))
+	}
+	return utilerrors.NewAggregate(f.errs)
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Force cleanup", func() {
+	exists := func(c client.Client, namespace, name string, obj runtime.Object) bool {
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj)
+		if err != nil {
+			Expect(IgnoreNotFound(err)).ToNot(HaveOccurred())
+			return false
+		}
+		return true
+	}
+
+	It("Should delete the importer pod and the scratch space and not create a new pod", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnForceCleanup: "true"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		scratchPvc := createScratchPvc(pvc, pod, testStorageClass)
+		reconciler := createImportReconciler(pvc, pod, scratchPvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists(reconciler.Client, "default", pod.Name, &corev1.Pod{})).To(BeFalse())
+		Expect(exists(reconciler.Client, "default", scratchPvc.Name, &corev1.PersistentVolumeClaim{})).To(BeFalse())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(ForceCleanupPVC))
+		Expect(event).To(ContainSubstring("pod default/importer-testPvc1"))
+		Expect(event).To(ContainSubstring("scratch PVC default/testPvc1-scratch"))
+
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists(reconciler.Client, "default", pod.Name, &corev1.Pod{})).To(BeFalse())
+	})
+
+	It("Should keep a pod the PVC does not own", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnForceCleanup: "true"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.OwnerReferences = nil
+		reconciler := createImportReconciler(pvc, pod)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists(reconciler.Client, "default", pod.Name, &corev1.Pod{})).To(BeTrue())
+		Expect(reconciler.recorder.(*record.FakeRecorder).Events).To(BeEmpty())
+	})
+
+	It("Should delete the upload server and its service", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: "", AnnForceCleanup: "true"}, nil)
+		pod := createUploadPod(pvc)
+		service := createUploadService(pvc)
+		reconciler := createUploadReconciler(pvc, pod, service)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists(reconciler.Client, "default", pod.Name, &corev1.Pod{})).To(BeFalse())
+		Expect(exists(reconciler.Client, "default", service.Name, &corev1.Service{})).To(BeFalse())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("pod default/cdi-upload-testPvc1, service default/cdi-upload-testPvc1"))
+	})
+
+	It("Should delete the clone source pod and remove the finalizer of a deleted PVC", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", map[string]string{AnnForceCleanup: "true"}, nil)
+		pvc.Finalizers = []string{cloneSourcePodFinalizer, "example.com/other"}
+		now := metav1.Now()
+		pvc.DeletionTimestamp = &now
+		pod := createJanitorSourcePod(pvc, now.Time)
+		reconciler := createCloneReconciler(pvc, pod)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "target", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists(reconciler.Client, pod.Namespace, pod.Name, &corev1.Pod{})).To(BeFalse())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		Expect(exists(reconciler.Client, "default", "target", resPvc)).To(BeTrue())
+		Expect(resPvc.Finalizers).To(Equal([]string{"example.com/other"}))
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("finalizer " + cloneSourcePodFinalizer))
+	})
+})
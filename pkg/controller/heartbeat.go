@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// TransferStalled provides a const to indicate the transfer pod of a PVC transferred no data for a while
+	TransferStalled = "TransferStalled"
+	// TransferResumed provides a const to indicate the transfer pod of a PVC transfers data again after it stalled
+	TransferResumed = "TransferResumed"
+
+	// transferStallTimeout is how long a transfer pod may transfer no data and make no progress before it is stalled
+	transferStallTimeout = 5 * time.Minute
+)
+
+// metricValue returns the value of the metric name of ownerUID in the prometheus metrics of a transfer pod, like
+// transfer_heartbeat_bytes{ownerUID="b856691e-1038-11e9-a5ab-525500d15501"} 1.048576e+06
+func metricValue(metrics, name string, ownerUID types.UID) (float64, bool) {
+	metricRegExp := regexp.MustCompile(regexp.QuoteMeta(fmt.Sprintf("%s{ownerUID=%q} ", name, ownerUID)) + `(\S+)`)
+	match := metricRegExp.FindStringSubmatch(metrics)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// heartbeatFromMetrics returns the heartbeat of the transfer of ownerUID in the prometheus metrics of a transfer pod,
+// as the value of the AnnHeartbeat annotation
+func heartbeatFromMetrics(metrics string, ownerUID types.UID) (string, bool) {
+	timestamp, ok := metricValue(metrics, "transfer_heartbeat_timestamp_seconds", ownerUID)
+	if !ok {
+		return "", false
+	}
+	bytes, ok := metricValue(metrics, "transfer_heartbeat_bytes", ownerUID)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s,%d", time.Unix(int64(timestamp), 0).UTC().Format(time.RFC3339), uint64(bytes)), true
+}
+
+// parseHeartbeat returns the time and the bytes of the value of an AnnHeartbeat annotation
+func parseHeartbeat(heartbeat string) (time.Time, uint64, bool) {
+	parts := strings.SplitN(heartbeat, ",", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, false
+	}
+	beat, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	bytes, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return beat, bytes, true
+}
+
+// parseTransferIdle returns the time and the progress of the value of an AnnTransferIdle annotation
+func parseTransferIdle(idle string) (time.Time, string, bool) {
+	parts := strings.SplitN(idle, ",", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", false
+	}
+	since, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return since, parts[1], true
+}
+
+// updateHeartbeat relays the heartbeat in the prometheus metrics of the transfer pod of pvc to its AnnHeartbeat
+// annotation. The pod updates its heartbeat every HeartbeatInterval, which throttles the updates of the PVC.
+// A heartbeat that repeats the bytes of the last one, while the progress stays the same too, makes the transfer idle
+// since the last one, the transfer is stalled once it is idle for transferStallTimeout. A qemu-img conversion reads
+// no data but makes progress.
+func updateHeartbeat(pvc *v1.PersistentVolumeClaim, metrics string, ownerUID types.UID, progress string) {
+	heartbeat, ok := heartbeatFromMetrics(metrics, ownerUID)
+	if !ok || pvc.GetAnnotations()[AnnHeartbeat] == heartbeat {
+		return
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string)
+	}
+	lastBeat, lastBytes, hadHeartbeat := parseHeartbeat(pvc.Annotations[AnnHeartbeat])
+	pvc.Annotations[AnnHeartbeat] = heartbeat
+	beat, bytes, _ := parseHeartbeat(heartbeat)
+	if !hadHeartbeat || bytes != lastBytes {
+		delete(pvc.Annotations, AnnTransferIdle)
+		delete(pvc.Annotations, AnnTransferStalled)
+		return
+	}
+	since, idleProgress, idle := parseTransferIdle(pvc.Annotations[AnnTransferIdle])
+	if !idle || idleProgress != progress {
+		// The transfer is idle since the last heartbeat, or it made progress while it was idle
+		since = lastBeat
+		if idle {
+			since = beat
+		}
+		pvc.Annotations[AnnTransferIdle] = fmt.Sprintf("%s,%s", since.UTC().Format(time.RFC3339), progress)
+		delete(pvc.Annotations, AnnTransferStalled)
+		return
+	}
+	if beat.Sub(since) >= transferStallTimeout {
+		pvc.Annotations[AnnTransferStalled] = fmt.Sprintf("Transferred no data and made no progress since %s, %d bytes transferred",
+			since.UTC().Format(time.RFC3339), bytes)
+	}
+}
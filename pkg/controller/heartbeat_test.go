@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testHeartbeatMetrics = `import_progress{ownerUID="b856691e-1038-11e9-a5ab-525500d15501"} 13.45
+transfer_heartbeat_bytes{ownerUID="b856691e-1038-11e9-a5ab-525500d15501"} 1.048576e+06
+transfer_heartbeat_timestamp_seconds{ownerUID="b856691e-1038-11e9-a5ab-525500d15501"} 1.6028424e+09
+`
+
+var _ = Describe("Heartbeat", func() {
+	It("Should read the heartbeat of the owner from the metrics", func() {
+		heartbeat, ok := heartbeatFromMetrics(testHeartbeatMetrics, "b856691e-1038-11e9-a5ab-525500d15501")
+		Expect(ok).To(BeTrue())
+		Expect(heartbeat).To(Equal("2020-10-16T10:00:00Z,1048576"))
+	})
+
+	It("Should ignore the heartbeat of another owner", func() {
+		_, ok := heartbeatFromMetrics(testHeartbeatMetrics, "b856691e-1038-11e9-a5ab-55500d15501")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Should relay the heartbeat to the PVC", func() {
+		pvc := createPvc("test", metav1.NamespaceDefault, nil, nil)
+		updateHeartbeat(pvc, "", "b856691e-1038-11e9-a5ab-525500d15501", "N/A")
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnHeartbeat))
+		updateHeartbeat(pvc, testHeartbeatMetrics, "b856691e-1038-11e9-a5ab-525500d15501", "13.45%")
+		Expect(pvc.Annotations[AnnHeartbeat]).To(Equal("2020-10-16T10:00:00Z,1048576"))
+	})
+
+	It("Should mark the PVC stalled once its transfer pod transferred no data and made no progress for a while", func() {
+		start := time.Date(2020, 10, 16, 10, 0, 0, 0, time.UTC)
+		metrics := func(after time.Duration, bytes int) string {
+			return fmt.Sprintf(`transfer_heartbeat_bytes{ownerUID="owner"} %d
+transfer_heartbeat_timestamp_seconds{ownerUID="owner"} %d
+`, bytes, start.Add(after).Unix())
+		}
+		pvc := createPvc("test", metav1.NamespaceDefault, nil, nil)
+		updateHeartbeat(pvc, metrics(0, 1024), "owner", "10.00%")
+		updateHeartbeat(pvc, metrics(10*time.Second, 1024), "owner", "10.00%")
+		Expect(pvc.Annotations[AnnTransferIdle]).To(Equal("2020-10-16T10:00:00Z,10.00%"))
+		updateHeartbeat(pvc, metrics(transferStallTimeout-time.Second, 1024), "owner", "10.00%")
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnTransferStalled))
+		updateHeartbeat(pvc, metrics(transferStallTimeout, 1024), "owner", "10.00%")
+		Expect(pvc.Annotations[AnnTransferStalled]).To(Equal("Transferred no data and made no progress since 2020-10-16T10:00:00Z, 1024 bytes transferred"))
+
+		By("Resuming the stall once the conversion makes progress")
+		updateHeartbeat(pvc, metrics(transferStallTimeout+10*time.Second, 1024), "owner", "12.00%")
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnTransferStalled))
+		Expect(pvc.Annotations[AnnTransferIdle]).To(Equal("2020-10-16T10:05:10Z,12.00%"))
+
+		By("Forgetting the idle time once the transfer pod transfers data")
+		updateHeartbeat(pvc, metrics(transferStallTimeout+20*time.Second, 2048), "owner", "12.00%")
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnTransferIdle))
+	})
+})
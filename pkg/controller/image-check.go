@@ -0,0 +1,44 @@
+package controller
+
+import (
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	v1 "k8s.io/api/core/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// ImageCheckPassed provides a const to indicate the imported image was checked and has no corruptions
+const ImageCheckPassed = "ImageCheckPassed"
+
+// imageCheckResult returns the result of checking the image from the termination message of the succeeded importer in pod
+func imageCheckResult(pod *v1.Pod) (string, bool) {
+	if message, ok := succeededTerminationMessage(pod); ok && message.ImageCheck != "" {
+		return message.ImageCheck, true
+	}
+	return "", false
+}
+
+// updateImageCheckedCondition reflects the result of checking the image imported to pvc in the ImageChecked condition
+// of dataVolume, True if the importer found no corruptions, False if it failed because of them
+func updateImageCheckedCondition(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	if pvc.GetAnnotations()[AnnImageCheck] == "" {
+		return
+	}
+	condition := conditions.Condition{Type: cdiv1.DataVolumeImageChecked}
+	if result, ok := pvc.GetAnnotations()[AnnImageCheckResult]; ok {
+		condition.Status, condition.Reason, condition.Message = v1.ConditionTrue, ImageCheckPassed, result
+	} else if permanentFailure, ok := pvc.GetAnnotations()[AnnPermanentFailure]; ok {
+		reason, message := parsePermanentFailure(permanentFailure)
+		if reason != util.PermanentErrorImageCorrupt {
+			return
+		}
+		condition.Status, condition.Reason, condition.Message = v1.ConditionFalse, reason, message
+	} else {
+		return
+	}
+	current := conditions.FindStatusCondition(dataVolume.Status.Conditions, cdiv1.DataVolumeImageChecked)
+	if current == nil || current.Status != condition.Status || current.Reason != condition.Reason || current.Message != condition.Message {
+		conditions.SetStatusCondition(&dataVolume.Status.Conditions, condition)
+	}
+}
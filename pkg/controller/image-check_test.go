@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Image check", func() {
+	createCompletedPod := func(exitCode int32, message string) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase: corev1.PodSucceeded,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: exitCode,
+								Message:  message,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	It("Should pass the image check to the importer", func() {
+		env := makeImportEnv(&importPodEnvVar{imageCheck: string(cdiv1.DataVolumeImageCheckRepairLeaks)}, "1111-1111-1111-1111")
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: common.ImporterImageCheck, Value: "repairLeaks"}))
+	})
+
+	table.DescribeTable("Should read the result of the image check", func(pod *corev1.Pod, expectedResult string, expectedOk bool) {
+		result, ok := imageCheckResult(pod)
+		Expect(ok).To(Equal(expectedOk))
+		Expect(result).To(Equal(expectedResult))
+	},
+		table.Entry("of a checked image", createCompletedPod(0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, ImageCheck: "2 leaked clusters found"})), "2 leaked clusters found", true),
+		table.Entry("of a checked image written to a block target", createCompletedPod(0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, ImageCheck: "no errors found", BlockTargetLayout: "offset=1048576,size=1048576", Digest: "sha256:00"})), "no errors found", true),
+		table.Entry("not of an image that was not checked", createCompletedPod(0, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Message: "Import Complete"})), "", false),
+		table.Entry("not of a failed importer", createCompletedPod(1, encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationFailed, ImageCheck: "no errors found"})), "", false),
+	)
+
+	table.DescribeTable("Should reflect the image check in the ImageChecked condition", func(annotations map[string]string, expectedStatus corev1.ConditionStatus, expectedReason, expectedMessage string) {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", "default", annotations, nil)
+		updateImageCheckedCondition(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeImageChecked)
+		if expectedStatus == "" {
+			Expect(condition).To(BeNil())
+			return
+		}
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(expectedStatus))
+		Expect(condition.Reason).To(Equal(expectedReason))
+		Expect(condition.Message).To(Equal(expectedMessage))
+	},
+		table.Entry("true when the check passed", map[string]string{AnnImageCheck: "check", AnnImageCheckResult: "no errors found"}, corev1.ConditionTrue, ImageCheckPassed, "no errors found"),
+		table.Entry("false when the image is corrupt", map[string]string{
+			AnnImageCheck:       "check",
+			AnnPermanentFailure: fmt.Sprintf(common.PermanentFailureMessage, util.PermanentErrorImageCorrupt, "qemu-img check found 2 corruptions on the image"),
+		}, corev1.ConditionFalse, util.PermanentErrorImageCorrupt, "qemu-img check found 2 corruptions on the image"),
+		table.Entry("not set on another permanent failure", map[string]string{
+			AnnImageCheck:       "check",
+			AnnPermanentFailure: fmt.Sprintf(common.PermanentFailureMessage, util.PermanentErrorNotFound, "not found"),
+		}, corev1.ConditionStatus(""), "", ""),
+		table.Entry("not set while the import runs", map[string]string{AnnImageCheck: "check"}, corev1.ConditionStatus(""), "", ""),
+		table.Entry("not set if the image is not checked", map[string]string{AnnImageCheckResult: "no errors found"}, corev1.ConditionStatus(""), "", ""),
+	)
+})
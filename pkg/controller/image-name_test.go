@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Image names", func() {
+	reconcileDataVolume := func(dv *cdiv1.DataVolume) *corev1.PersistentVolumeClaim {
+		reconciler := createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: dv.Name, Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: dv.Name, Namespace: metav1.NamespaceDefault}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		return pvc
+	}
+
+	It("Should pass the image name and the additional images to the importer", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.ImageName = "root.img"
+		dv.Spec.ContentType = cdiv1.DataVolumeImages
+		dv.Spec.Source.HTTP.AdditionalImages = []cdiv1.DataVolumeNamedImage{
+			{Name: "vmlinuz", URL: "http://example.com/vmlinuz"},
+		}
+		pvc := reconcileDataVolume(dv)
+		pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1G")}
+		Expect(pvc.GetAnnotations()[AnnImageName]).To(Equal("root.img"))
+		Expect(pvc.GetAnnotations()[AnnContentType]).To(Equal(string(cdiv1.DataVolumeImages)))
+		Expect(pvc.GetAnnotations()[AnnAdditionalImages]).To(MatchJSON(`[{"name":"vmlinuz","url":"http://example.com/vmlinuz"}]`))
+
+		podEnvVar, err := createImportEnvVar(k8sfake.NewSimpleClientset(), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		env := makeImportEnv(podEnvVar, "1111-1111-1111-1111")
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: common.ImporterImageName, Value: "root.img"}))
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: common.ImporterAdditionalImages, Value: pvc.GetAnnotations()[AnnAdditionalImages]}))
+	})
+
+	It("Should not annotate the PVC without an image name", func() {
+		pvc := reconcileDataVolume(newImportDataVolume("test-dv"))
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnImageName))
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnAdditionalImages))
+		Expect(pvc.GetAnnotations()[AnnContentType]).To(Equal(string(cdiv1.DataVolumeKubeVirt)))
+	})
+
+	It("Should upload to the image name", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: "", AnnImageName: "root.img"}, nil)
+		r := createUploadReconciler(pvc)
+		pod := r.makeUploadPodSpec(UploadPodArgs{
+			Name: "cdi-upload-testPvc1",
+			PVC:  pvc,
+		}, nil)
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "DESTINATION", Value: path.Join(common.UploadServerDataDir, "root.img")}))
+	})
+
+	It("Should export the image name", func() {
+		env := makeExportEnv(&exportPodEnvVar{destination: "http://example.com/export", imageName: "root.img"}, "1111-1111-1111-1111")
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: common.ImporterImageName, Value: "root.img"}))
+	})
+})
@@ -3,8 +3,10 @@ package controller
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -19,6 +21,8 @@ import (
 	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	cdiclientset "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/generator"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -46,6 +50,38 @@ const (
 	AnnRequiresScratch = AnnAPIGroup + "/storage.import.requiresScratch"
 	// AnnDiskID provides a const for our PVC diskId annotation
 	AnnDiskID = AnnAPIGroup + "/storage.import.diskId"
+	// AnnNutanixImageUUID provides a const for our PVC annotation with the uuid of the Nutanix image to import
+	AnnNutanixImageUUID = AnnAPIGroup + "/storage.import.nutanixImageUUID"
+	// AnnArchitecture provides a const for our PVC architecture annotation, used to select an image from a registry manifest list
+	AnnArchitecture = AnnAPIGroup + "/storage.import.architecture"
+	// AnnArtifactMediaType provides a const for our PVC annotation selecting the disk image blob of an OCI artifact by media type
+	AnnArtifactMediaType = AnnAPIGroup + "/storage.import.artifactMediaType"
+	// AnnArtifactAnnotation provides a const for our PVC annotation selecting the disk image blob of an OCI artifact by annotation
+	AnnArtifactAnnotation = AnnAPIGroup + "/storage.import.artifactAnnotation"
+	// AnnDiskPath provides a const for our PVC annotation with a glob matching the disk image in the layers of a registry image
+	AnnDiskPath = AnnAPIGroup + "/storage.import.diskPath"
+	// AnnBackingFileURLs provides a const for our PVC annotation with the comma separated url prefixes qcow2 backing files may be fetched from
+	AnnBackingFileURLs = AnnAPIGroup + "/storage.import.backingFileURLs"
+	// AnnAdditionalImages provides a const for our PVC annotation with the named images imported next to the image of an http source with content type images, a list of DataVolumeNamedImage in JSON
+	AnnAdditionalImages = AnnAPIGroup + "/storage.import.additionalImages"
+	// AnnChecksum provides a const for our PVC annotation with the digest an http or s3 source, or a kubevirt-state upload, is verified against
+	AnnChecksum = AnnAPIGroup + "/storage.import.checksum"
+	// AnnImageCheck provides a const for our PVC annotation with how the consistency of the imported image is checked
+	AnnImageCheck = AnnAPIGroup + "/storage.import.imageCheck"
+	// AnnImageCheckResult provides a const for our PVC annotation with the result of checking the imported image
+	AnnImageCheckResult = AnnAPIGroup + "/storage.import.imageCheckResult"
+	// AnnBlockTargetLayout provides a const for our PVC annotation with the region of the block device the image was written to
+	AnnBlockTargetLayout = AnnAPIGroup + "/storage.import.blockTargetLayout"
+	// AnnSSHInsecureSkipHostKeyCheck provides a const for our PVC annotation allowing an ssh import without verifying the key of the host
+	AnnSSHInsecureSkipHostKeyCheck = AnnAPIGroup + "/storage.import.sshInsecureSkipHostKeyCheck"
+	// AnnFanOutTargets provides a const for our PVC annotation with the comma separated upload target PVCs the imported image is streamed to as well
+	AnnFanOutTargets = AnnAPIGroup + "/storage.import.fanOutTargets"
+	// AnnBandwidthLimit provides a const for our PVC annotation with the JSON of the DataVolumeBandwidthLimit the importer reads its source with
+	AnnBandwidthLimit = AnnAPIGroup + "/storage.import.bandwidthLimit"
+	// AnnSourceKeySecret provides a const for our PVC annotation with the name of the Secret with the passphrase of an encrypted source image
+	AnnSourceKeySecret = AnnAPIGroup + "/storage.import.sourceKeySecret"
+	// AnnTargetKeySecret provides a const for our PVC annotation with the name of the Secret with the passphrase the imported image is encrypted with
+	AnnTargetKeySecret = AnnAPIGroup + "/storage.import.targetKeySecret"
 
 	//LabelImportPvc is a pod label used to find the import pod that was created by the relevant PVC
 	LabelImportPvc = AnnAPIGroup + "/storage.import.importPvcName"
@@ -60,34 +96,45 @@ const (
 
 // ImportReconciler members
 type ImportReconciler struct {
-	Client     client.Client
-	CdiClient  cdiclientset.Interface
-	K8sClient  kubernetes.Interface
-	recorder   record.EventRecorder
-	Scheme     *runtime.Scheme
-	Log        logr.Logger
-	Image      string
-	Verbose    string
-	PullPolicy string
+	Client              client.Client
+	CdiClient           cdiclientset.Interface
+	K8sClient           kubernetes.Interface
+	recorder            record.EventRecorder
+	Scheme              *runtime.Scheme
+	Log                 logr.Logger
+	Image               string
+	Verbose             string
+	PullPolicy          string
+	clientCertGenerator generator.CertGenerator
+	serverCAFetcher     fetcher.CertBundleFetcher
 }
 
 type importPodEnvVar struct {
-	ep, secretName, source, contentType, imageSize, certConfigMap, diskID string
-	insecureTLS                                                           bool
+	ep, secretName, source, contentType, imageSize, certConfigMap, diskID, architecture string
+	artifactMediaType, artifactAnnotation, diskPath, serviceAccount, nutanixImageUUID   string
+	priorityClassName, checksum, imageCheck, verification, blockWipe, networks          string
+	blockTargetOffset, blockTargetPartition, imageName, additionalImages                string
+	fanOutClientCert, fanOutClientKey, fanOutServerCA, userAgent, clusterID             string
+	bandwidthLimit, sourceKeySecret, targetKeySecret                                    string
+	insecureTLS, sshInsecureSkipHostKeyCheck                                            bool
+	registryMirrors, insecureRegistryMirrors, pullSecrets, backingFileURLs, fanOutURLs  []string
+	dns                                                                                 *cdiv1.DataVolumeDNS
 }
 
 // NewImportController creates a new instance of the import controller.
-func NewImportController(mgr manager.Manager, cdiClient *cdiclientset.Clientset, k8sClient kubernetes.Interface, log logr.Logger, importerImage, pullPolicy, verbose string) (controller.Controller, error) {
+func NewImportController(mgr manager.Manager, cdiClient *cdiclientset.Clientset, k8sClient kubernetes.Interface, log logr.Logger, importerImage, pullPolicy, verbose string, clientCertGenerator generator.CertGenerator, serverCAFetcher fetcher.CertBundleFetcher) (controller.Controller, error) {
 	reconciler := &ImportReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		CdiClient:  cdiClient,
-		K8sClient:  k8sClient,
-		Log:        log.WithName("import-controller"),
-		Image:      importerImage,
-		Verbose:    verbose,
-		PullPolicy: pullPolicy,
-		recorder:   mgr.GetEventRecorderFor("import-controller"),
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		CdiClient:           cdiClient,
+		K8sClient:           k8sClient,
+		Log:                 newSampledLogger(log.WithName("import-controller")),
+		Image:               importerImage,
+		Verbose:             verbose,
+		PullPolicy:          pullPolicy,
+		recorder:            mgr.GetEventRecorderFor("import-controller"),
+		clientCertGenerator: clientCertGenerator,
+		serverCAFetcher:     serverCAFetcher,
 	}
 	importController, err := controller.New("import-controller", mgr, controller.Options{
 		Reconciler: reconciler,
@@ -127,7 +174,7 @@ func isPVCComplete(pvc *corev1.PersistentVolumeClaim) bool {
 
 // Reconcile the reconcile loop for the CDIConfig object.
 func (r *ImportReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
-	log := r.Log.WithValues("PVC", req.NamespacedName)
+	log := r.Log.WithValues(logKeyPVC, req.NamespacedName)
 	log.V(1).Info("reconciling Import PVCs")
 
 	// Get the PVC.
@@ -139,6 +186,11 @@ func (r *ImportReconciler) Reconcile(req reconcile.Request) (reconcile.Result, e
 		return reconcile.Result{}, err
 	}
 
+	if isForceCleanup(pvc) {
+		log.V(1).Info("Forcing the cleanup of the PVC")
+		return reconcile.Result{}, r.forceCleanup(pvc)
+	}
+
 	if !shouldReconcilePVC(pvc) {
 		log.V(1).Info("Should not reconcile this PVC", "pvc.annotation.phase.complete", isPVCComplete(pvc),
 			"pvc.annotations.endpoint", checkPVC(pvc, AnnEndpoint), "pvc.annotations.source", checkPVC(pvc, AnnSource))
@@ -175,7 +227,7 @@ func (r *ImportReconciler) findImporterPod(pvc *corev1.PersistentVolumeClaim, lo
 		return nil, errors.Errorf("Pod is not owned by PVC")
 	}
 
-	log.V(1).Info("Pod is owned by PVC", pod.Name, pvc.Name)
+	log.V(1).Info("Pod is owned by PVC", logKeyPod, logObjectKey(pod))
 	return pod, nil
 }
 
@@ -189,29 +241,113 @@ func (r *ImportReconciler) reconcilePvc(pvc *corev1.PersistentVolumeClaim, log l
 		if isPVCComplete(pvc) {
 			// Don't create the POD if the PVC is completed already
 			log.V(1).Info("PVC is already complete")
+		} else if isTransferTimedOut(pvc) {
+			log.V(1).Info("Import ran longer than its deadline too often, not retrying")
+		} else if isImportFailedPermanently(pvc) {
+			log.V(1).Info("Import failed permanently, not retrying")
 		} else if pvc.DeletionTimestamp == nil {
+			if terminating, err := r.isScratchPvcTerminating(pvc); err != nil || terminating {
+				// The scratch space PVC of the previous importer pod is not deleted yet
+				return reconcile.Result{RequeueAfter: scratchRetryInterval}, err
+			}
+			sourceType, urls := importSourcePolicy(pvc)
+			reason, err := checkSourcePolicy(r.K8sClient, pvc, sourceType, urls)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if reason != "" {
+				log.V(1).Info("Source is not allowed", "reason", reason)
+				return rejectSource(r.recorder, pvc, reason)
+			}
+			reason, err = checkTransferWindow(r.Client, r.K8sClient, pvc)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if reason != "" {
+				log.V(1).Info("Importer pod waits for a transfer window", "reason", reason)
+				return waitForTransferWindow(r.Client, r.recorder, pvc, reason)
+			}
+			reason, err = checkTransferQuota(r.Client, pvc, r.requiresScratchSpace(pvc))
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if reason != "" {
+				log.V(1).Info("Importer pod is queued", "reason", reason)
+				return queueTransfer(r.Client, r.recorder, pvc, reason)
+			}
+			reason, err = checkPinnedNode(r.Client, pvc)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if reason != "" {
+				log.V(1).Info("Importer pod waits for its node", "reason", reason)
+				return waitForNode(r.Client, r.recorder, pvc, reason)
+			}
+			reason, err = checkFanOutTargets(r.Client, pvc)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if reason != "" {
+				log.V(1).Info("Importer pod waits for its fan-out targets", "reason", reason)
+				return waitForFanOutTargets(r.recorder, pvc, reason)
+			}
 			// Create importer pod, make sure the PVC owns it.
 			if err := r.createImporterPod(pvc); err != nil {
 				return reconcile.Result{}, err
 			}
+		} else {
+			log.V(1).Info("PVC being terminated, release scratch space")
+			if err := releaseScratchPvc(r.Client, pvc); err != nil {
+				return reconcile.Result{}, err
+			}
 		}
 	} else {
 		if pvc.DeletionTimestamp != nil {
-			log.V(1).Info("PVC being terminated, delete pods", "pod.Name", pod.Name)
-			if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			log.V(1).Info("PVC being terminated, delete pods", logKeyPod, logObjectKey(pod))
+			if pod.DeletionTimestamp == nil {
+				if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+					return reconcile.Result{}, err
+				}
+			}
+			if err := releaseScratchPvc(r.Client, pvc); err != nil {
 				return reconcile.Result{}, err
 			}
 			return reconcile.Result{}, nil
 		}
 
+		if isTransferTimedOut(pvc) || isImportFailedPermanently(pvc) {
+			// The pod was deleted for running longer than its deadline, or for failing permanently, keep the failed phase
+			return reconcile.Result{}, nil
+		}
+		exceeded, untilDeadline, err := checkTransferDeadline(r.Client, r.recorder, pvc, pod)
+		if err != nil || exceeded {
+			return reconcile.Result{}, err
+		}
+
+		if err := reconcileDisruptionBudget(r.Client, pvc, pod); err != nil {
+			return reconcile.Result{}, err
+		}
+
 		// Pod exists, we need to update the PVC status.
 		if err := r.updatePvcFromPod(pvc, pod, log); err != nil {
 			return reconcile.Result{}, err
 		}
+		return reconcile.Result{RequeueAfter: untilDeadline}, nil
 	}
 	return reconcile.Result{}, nil
 }
 
+// forceCleanup removes the importer pod and the scratch space of pvc
+func (r *ImportReconciler) forceCleanup(pvc *corev1.PersistentVolumeClaim) error {
+	cleanup := newForceCleanup(r.Client, pvc)
+	pod := &corev1.Pod{}
+	cleanup.delete("pod", types.NamespacedName{Namespace: pvc.Namespace, Name: importPodNameFromPvc(pvc)}, pod, func() bool {
+		return metav1.IsControlledBy(pod, pvc)
+	})
+	cleanup.deleteScratchPvc()
+	return cleanup.done(r.recorder)
+}
+
 func (r *ImportReconciler) updatePvcFromPod(pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod, log logr.Logger) error {
 	// Keep a copy of the original for comparison later.
 	currentPvcCopy := pvc.DeepCopyObject()
@@ -219,16 +355,30 @@ func (r *ImportReconciler) updatePvcFromPod(pvc *corev1.PersistentVolumeClaim, p
 	log.V(1).Info("Updating PVC from pod")
 	anno := pvc.GetAnnotations()
 	scratchExitCode := false
+	scratchResized := false
+	permanentFailure, failedPermanently := permanentFailureMessage(pod)
 	if pod.Status.ContainerStatuses != nil &&
 		pod.Status.ContainerStatuses[0].LastTerminationState.Terminated != nil &&
 		pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode > 0 {
-		log.Info("Pod termination code", "pod.Name", pod.Name, "ExitCode", pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode)
+		log.Info("Pod termination code", logKeyPod, logObjectKey(pod), "ExitCode", pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode)
 		if pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode == common.ScratchSpaceNeededExitCode {
-			log.V(1).Info("Pod requires scratch space, terminating pod, and restarting with scratch space", "pod.Name", pod.Name)
+			log.V(1).Info("Pod requires scratch space, terminating pod, and restarting with scratch space", logKeyPod, logObjectKey(pod))
 			scratchExitCode = true
 			anno[AnnRequiresScratch] = "true"
-		} else {
-			r.recorder.Event(pvc, corev1.EventTypeWarning, ErrImportFailedPVC, pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.Message)
+		} else if pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.ExitCode == common.ScratchSpaceExhaustedExitCode {
+			message := terminationMessage(pod.Status.ContainerStatuses[0].LastTerminationState.Terminated).Message
+			anno[AnnScratchExhausted] = message
+			var err error
+			if scratchResized, err = enlargeScratchSpace(r.Client, pvc, message); err != nil {
+				return err
+			}
+			if scratchResized {
+				log.V(1).Info("Pod ran out of scratch space, restarting with enlarged scratch space", logKeyPod, logObjectKey(pod), "size", anno[AnnScratchSize])
+				message = fmt.Sprintf("%s, retrying with %s of scratch space", message, anno[AnnScratchSize])
+			}
+			r.recorder.Event(pvc, corev1.EventTypeWarning, InsufficientScratchSpace, message)
+		} else if !failedPermanently {
+			r.recorder.Event(pvc, corev1.EventTypeWarning, ErrImportFailedPVC, sanitizeTransferFailure(terminationMessage(pod.Status.ContainerStatuses[0].LastTerminationState.Terminated).Message))
 		}
 	}
 
@@ -236,8 +386,37 @@ func (r *ImportReconciler) updatePvcFromPod(pvc *corev1.PersistentVolumeClaim, p
 		anno[AnnPodRestarts] = strconv.Itoa(int(pod.Status.ContainerStatuses[0].RestartCount))
 	}
 	anno[AnnImportPod] = string(pod.Name)
+	delete(anno, AnnPodQueued)
+	delete(anno, AnnNodeUnschedulable)
+	delete(anno, AnnTransferWindowClosed)
+	if pod.Status.Phase == corev1.PodSucceeded {
+		delete(anno, AnnScratchExhausted)
+		if result, ok := imageCheckResult(pod); ok {
+			anno[AnnImageCheckResult] = result
+		}
+		if layout, ok := blockTargetLayout(pod); ok {
+			anno[AnnBlockTargetLayout] = layout
+		}
+		if stages, ok := stageDurations(pod); ok {
+			anno[AnnStageDurations] = stages
+		}
+	}
+	updateContentDigest(pod, anno)
+	updateSourceValidators(pod, anno)
+	updatePartialWriteAnnotation(pvc, pod, anno)
+	updateTransferFailure(pod, anno)
 	// Even if scratch space is needed, the pod state will still remain running, until the new pod is started.
 	anno[AnnPodPhase] = string(pod.Status.Phase)
+	if failedPermanently {
+		// Fail the import rather than letting the pod restart
+		log.V(1).Info("Pod failed permanently, not retrying", logKeyPod, logObjectKey(pod))
+		anno[AnnPodPhase] = string(corev1.PodFailed)
+		if anno[AnnPermanentFailure] != permanentFailure {
+			anno[AnnPermanentFailure] = permanentFailure
+			reason, message := parsePermanentFailure(permanentFailure)
+			r.recorder.Event(pvc, corev1.EventTypeWarning, reason, message)
+		}
+	}
 
 	// Check if the POD is waiting for scratch space, if so create some.
 	if pod.Status.Phase == corev1.PodPending && r.requiresScratchSpace(pvc) {
@@ -258,24 +437,41 @@ func (r *ImportReconciler) updatePvcFromPod(pvc *corev1.PersistentVolumeClaim, p
 		if err := r.updatePVC(pvc, log); err != nil {
 			return err
 		}
-		log.V(1).Info("Updated PVC", "pvc.anno.Phase", anno[AnnPodPhase], "pvc.anno.Restarts", anno[AnnPodRestarts])
+		log.V(1).Info("Updated PVC", logKeyPhase, anno[AnnPodPhase], "restarts", anno[AnnPodRestarts])
 	}
 
-	if isPVCComplete(pvc) || scratchExitCode {
-		if !scratchExitCode {
+	if isPVCComplete(pvc) || scratchExitCode || scratchResized || failedPermanently {
+		if !scratchExitCode && !scratchResized && !failedPermanently {
 			r.recorder.Event(pvc, corev1.EventTypeNormal, ImportSucceededPVC, "Import Successful")
-			log.V(1).Info("Completed successfully, deleting POD", "pod.Name", pod.Name)
+			retention, _, err := getCompletedPodRetention(r.Client)
+			if err != nil {
+				return err
+			}
+			if retention > 0 {
+				// The transfer pod janitor deletes the pod once the retention is over
+				log.V(1).Info("Completed successfully, keeping POD", logKeyPod, logObjectKey(pod), "retention", retention)
+				return nil
+			}
+			log.V(1).Info("Completed successfully, deleting POD", logKeyPod, logObjectKey(pod))
 		}
 		if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
 			return err
 		}
 	}
+	if scratchResized {
+		// The scratch space PVC is recreated with the new size for the next importer pod
+		scratchPvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: pvc.Namespace, Name: scratchNameFromPvc(pvc)}}
+		if err := r.Client.Delete(context.TODO(), scratchPvc); IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (r *ImportReconciler) updatePVC(pvc *corev1.PersistentVolumeClaim, log logr.Logger) error {
-	log.V(1).Info("Phase is now", "pvc.anno.Phase", pvc.GetAnnotations()[AnnPodPhase])
-	log.V(1).Info("Restarts is now", "pvc.anno.Restarts", pvc.GetAnnotations()[AnnPodRestarts])
+	log.V(1).Info("Phase is now", logKeyPhase, pvc.GetAnnotations()[AnnPodPhase])
+	log.V(1).Info("Restarts is now", "restarts", pvc.GetAnnotations()[AnnPodRestarts])
+	publishClaimConditions(pvc, "")
 	if err := r.Client.Update(context.TODO(), pvc); err != nil {
 		return err
 	}
@@ -297,6 +493,17 @@ func (r *ImportReconciler) createImporterPod(pvc *corev1.PersistentVolumeClaim)
 	if err != nil {
 		return err
 	}
+	verification, err := getVerification(r.Client, pvc)
+	if err != nil {
+		return err
+	}
+	podEnvVar.verification = string(verification)
+	if err := r.setFanOutEnvVar(pvc, podEnvVar); err != nil {
+		return err
+	}
+	if err := setUserAgentEnvVar(r.Client, r.K8sClient, podEnvVar); err != nil {
+		return err
+	}
 
 	// all checks passed, let's create the importer pod!
 	pod, err := createImporterPod(r.Log, r.Client, r.CdiClient, r.Image, r.Verbose, r.PullPolicy, podEnvVar, pvc, scratchPvcName)
@@ -304,7 +511,7 @@ func (r *ImportReconciler) createImporterPod(pvc *corev1.PersistentVolumeClaim)
 	if err != nil {
 		return err
 	}
-	r.Log.V(1).Info("Created POD", "pod.Name", pod.Name)
+	r.Log.V(1).Info("Created POD", logKeyPod, logObjectKey(pod))
 	if requiresScratch {
 		r.Log.V(1).Info("POD requires scratch space")
 		return r.createScratchPvcForPod(pvc, pod)
@@ -343,8 +550,16 @@ func (r *ImportReconciler) createScratchPvcForPod(pvc *corev1.PersistentVolumeCl
 	if k8serrors.IsNotFound(err) {
 		scratchPVCName := scratchNameFromPvc(pvc)
 		storageClassName := GetScratchPvcStorageClass(r.K8sClient, r.CdiClient, pvc)
+		maxSize, err := getScratchSpaceMaxSize(r.Client)
+		if err != nil {
+			return err
+		}
+		pool, err := getScratchSpacePool(r.Client)
+		if err != nil {
+			return err
+		}
 		// Scratch PVC doesn't exist yet, create it. Determine which storage class to use.
-		_, err = CreateScratchPersistentVolumeClaim(r.K8sClient, pvc, pod, scratchPVCName, storageClassName)
+		_, err = CreateScratchPersistentVolumeClaim(r.K8sClient, pvc, pod, scratchPVCName, storageClassName, maxSize, pool)
 		if err != nil {
 			return err
 		}
@@ -352,6 +567,15 @@ func (r *ImportReconciler) createScratchPvcForPod(pvc *corev1.PersistentVolumeCl
 	return nil
 }
 
+// isScratchPvcTerminating returns true if the scratch space PVC of pvc is being deleted
+func (r *ImportReconciler) isScratchPvcTerminating(pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	scratchPvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: pvc.Namespace, Name: scratchNameFromPvc(pvc)}, scratchPvc); err != nil {
+		return false, IgnoreNotFound(err)
+	}
+	return scratchPvc.DeletionTimestamp != nil, nil
+}
+
 func importPodNameFromPvc(pvc *corev1.PersistentVolumeClaim) string {
 	return fmt.Sprintf("%s-%s", common.ImporterPodName, pvc.Name)
 }
@@ -370,11 +594,22 @@ func createImporterPod(log logr.Logger, client client.Client, cdiClient cdiclien
 	}
 
 	pod := makeImporterPodSpec(pvc.Namespace, image, verbose, pullPolicy, podEnvVar, pvc, scratchPvcName, podResourceRequirements)
+	if err := setServiceMeshAnnotations(client, pod); err != nil {
+		return nil, err
+	}
+	if err := setSecurityProfiles(client, pod, importerSeccompProfile); err != nil {
+		return nil, err
+	}
+	if err := setVolumeNodeAffinity(client, pvc, pod); err != nil {
+		return nil, err
+	}
+	setPinnedNodeAffinity(pvc, pod)
 
-	if err := client.Create(context.TODO(), pod); err != nil {
+	pod, err = createPodIfNotExists(client, pod)
+	if err != nil {
 		return nil, err
 	}
-	log.V(3).Info("importer pod created\n", "pod.Name", pod.Name, "pod.Namespace", pod.Namespace, "image name", image)
+	log.V(3).Info("importer pod created", logKeyPod, logObjectKey(pod), "image name", image)
 	return pod, nil
 }
 
@@ -410,17 +645,22 @@ func makeImporterPodSpec(namespace, image, verbose, pullPolicy string, podEnvVar
 		})
 	}
 
+	annotations := map[string]string{
+		AnnCreatedBy: "yes",
+	}
+	if podEnvVar.networks != "" {
+		annotations[AnnMultusNetworks] = podEnvVar.networks
+	}
+
 	pod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Pod",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: namespace,
-			Annotations: map[string]string{
-				AnnCreatedBy: "yes",
-			},
+			Name:        podName,
+			Namespace:   namespace,
+			Annotations: annotations,
 			Labels: map[string]string{
 				common.CDILabelKey:       common.CDILabelValue,
 				common.CDIComponentLabel: common.ImporterPodName,
@@ -445,7 +685,9 @@ func makeImporterPodSpec(namespace, image, verbose, pullPolicy string, podEnvVar
 					Name:            common.ImporterPodName,
 					Image:           image,
 					ImagePullPolicy: corev1.PullPolicy(pullPolicy),
-					Args:            []string{"-v=" + verbose},
+					// The end of the log tells why the pod failed if it could not write a termination message
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					Args:                     []string{"-v=" + verbose},
 					Ports: []corev1.ContainerPort{
 						{
 							Name:          "metrics",
@@ -455,8 +697,10 @@ func makeImporterPodSpec(namespace, image, verbose, pullPolicy string, podEnvVar
 					},
 				},
 			},
-			RestartPolicy: corev1.RestartPolicyOnFailure,
-			Volumes:       volumes,
+			RestartPolicy:      corev1.RestartPolicyOnFailure,
+			Volumes:            volumes,
+			PriorityClassName:  podEnvVar.priorityClassName,
+			ServiceAccountName: podEnvVar.serviceAccount,
 		},
 	}
 
@@ -464,6 +708,12 @@ func makeImporterPodSpec(namespace, image, verbose, pullPolicy string, podEnvVar
 		pod.Spec.Containers[0].Resources = *podResourceRequirements
 	}
 
+	if podEnvVar.dns != nil {
+		pod.Spec.HostAliases = podEnvVar.dns.HostAliases
+		pod.Spec.DNSPolicy = podEnvVar.dns.Policy
+		pod.Spec.DNSConfig = podEnvVar.dns.Config
+	}
+
 	ownerUID := pvc.UID
 	if len(pvc.OwnerReferences) == 1 {
 		ownerUID = pvc.OwnerReferences[0].UID
@@ -508,6 +758,47 @@ func makeImporterPodSpec(namespace, image, verbose, pullPolicy string, podEnvVar
 		pod.Spec.Volumes = append(pod.Spec.Volumes, vol)
 	}
 
+	// The ssh source reads the key from a file, the other sources get their credentials from the environment
+	if podEnvVar.source == SourceSSH && podEnvVar.secretName != "" {
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      SSHKeyVolName,
+			MountPath: common.ImporterSSHKeyDir,
+			ReadOnly:  true,
+		})
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: SSHKeyVolName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: podEnvVar.secretName,
+				},
+			},
+		})
+	}
+
+	// qemu-img reads the passphrases of encrypted images from files, so they do not show in its command line
+	addKeySecretVolume(pod, SourceKeyVolName, common.ImporterSourceKeyDir, podEnvVar.sourceKeySecret)
+	addKeySecretVolume(pod, TargetKeyVolName, common.ImporterTargetKeyDir, podEnvVar.targetKeySecret)
+
+	// Pull secrets are mounted in numbered directories to preserve their order of preference
+	optional := true
+	for i, secret := range podEnvVar.pullSecrets {
+		volName := fmt.Sprintf("%s-%d", PullSecretVolName, i)
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      volName,
+			MountPath: filepath.Join(common.ImporterPullSecretDir, strconv.Itoa(i)),
+			ReadOnly:  true,
+		})
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: volName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: secret,
+					Optional:   &optional,
+				},
+			},
+		})
+	}
+
 	if podEnvVar.contentType == string(cdiv1.DataVolumeKubeVirt) {
 		// Set the fsGroup on the security context to the QemuSubGid
 		if pod.Spec.SecurityContext == nil {
@@ -516,9 +807,35 @@ func makeImporterPodSpec(namespace, image, verbose, pullPolicy string, podEnvVar
 		fsGroup := common.QemuSubGid
 		pod.Spec.SecurityContext.FSGroup = &fsGroup
 	}
+	setReadOnlyRootFilesystem(pod)
 	return pod
 }
 
+// addKeySecretVolume mounts the passphrase of secret in the importer container of pod at dir, nothing is mounted if
+// secret is empty. The pod does not start until the secret has the passphrase.
+func addKeySecretVolume(pod *corev1.Pod, volName, dir, secret string) {
+	if secret == "" {
+		return
+	}
+	pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      volName,
+		MountPath: dir,
+		ReadOnly:  true,
+	})
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: volName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secret,
+				Items: []corev1.KeyToPath{{
+					Key:  common.EncryptionPassphraseKey,
+					Path: common.EncryptionPassphraseKey,
+				}},
+			},
+		},
+	})
+}
+
 // this is being called for pods using PV with filesystem volume mode
 func addImportVolumeMounts() []v1.VolumeMount {
 	volumeMounts := []v1.VolumeMount{
@@ -561,8 +878,124 @@ func makeImportEnv(podEnvVar *importPodEnvVar, uid types.UID) []v1.EnvVar {
 			Name:  common.ImporterDiskID,
 			Value: podEnvVar.diskID,
 		},
+		{
+			Name:  common.ImporterArchitecture,
+			Value: podEnvVar.architecture,
+		},
+		{
+			Name:  common.ImporterArtifactMediaType,
+			Value: podEnvVar.artifactMediaType,
+		},
+		{
+			Name:  common.ImporterArtifactAnnotation,
+			Value: podEnvVar.artifactAnnotation,
+		},
+		{
+			Name:  common.ImporterDiskPath,
+			Value: podEnvVar.diskPath,
+		},
+	}
+	if len(podEnvVar.registryMirrors) > 0 {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterRegistryMirrors,
+			Value: strings.Join(podEnvVar.registryMirrors, ","),
+		})
+	}
+	if len(podEnvVar.insecureRegistryMirrors) > 0 {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterInsecureRegistryMirrors,
+			Value: strings.Join(podEnvVar.insecureRegistryMirrors, ","),
+		})
+	}
+	if podEnvVar.nutanixImageUUID != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterNutanixImageUUID,
+			Value: podEnvVar.nutanixImageUUID,
+		})
+	}
+	if podEnvVar.sshInsecureSkipHostKeyCheck {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterSSHInsecureSkipHostKeyCheck,
+			Value: strconv.FormatBool(podEnvVar.sshInsecureSkipHostKeyCheck),
+		})
+	}
+	if len(podEnvVar.backingFileURLs) > 0 {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterBackingFileURLs,
+			Value: strings.Join(podEnvVar.backingFileURLs, ","),
+		})
+	}
+	if podEnvVar.checksum != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterChecksum,
+			Value: podEnvVar.checksum,
+		})
+	}
+	if podEnvVar.imageCheck != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterImageCheck,
+			Value: podEnvVar.imageCheck,
+		})
+	}
+	if podEnvVar.verification != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterVerification,
+			Value: podEnvVar.verification,
+		})
+	}
+	if podEnvVar.blockWipe != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterBlockWipe,
+			Value: podEnvVar.blockWipe,
+		})
+	}
+	if podEnvVar.blockTargetOffset != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterBlockTargetOffset,
+			Value: podEnvVar.blockTargetOffset,
+		})
+	}
+	if podEnvVar.blockTargetPartition != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterBlockTargetPartition,
+			Value: podEnvVar.blockTargetPartition,
+		})
+	}
+	if podEnvVar.imageName != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterImageName,
+			Value: podEnvVar.imageName,
+		})
+	}
+	if podEnvVar.additionalImages != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterAdditionalImages,
+			Value: podEnvVar.additionalImages,
+		})
+	}
+	if podEnvVar.bandwidthLimit != "" {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterBandwidthLimit,
+			Value: podEnvVar.bandwidthLimit,
+		})
+	}
+	if len(podEnvVar.fanOutURLs) > 0 {
+		env = append(env, v1.EnvVar{
+			Name:  common.ImporterFanOutURLs,
+			Value: strings.Join(podEnvVar.fanOutURLs, ","),
+		}, v1.EnvVar{
+			Name:  common.ImporterFanOutClientCert,
+			Value: podEnvVar.fanOutClientCert,
+		}, v1.EnvVar{
+			Name:  common.ImporterFanOutClientKey,
+			Value: podEnvVar.fanOutClientKey,
+		}, v1.EnvVar{
+			Name:  common.ImporterFanOutServerCACert,
+			Value: podEnvVar.fanOutServerCA,
+		})
 	}
-	if podEnvVar.secretName != "" {
+	env = append(env, userAgentEnv(podEnvVar)...)
+	if podEnvVar.secretName != "" && podEnvVar.source != SourceSSH {
 		env = append(env, v1.EnvVar{
 			Name: common.ImporterAccessKeyID,
 			ValueFrom: &v1.EnvVarSource{
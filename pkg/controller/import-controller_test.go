@@ -18,8 +18,11 @@ package controller
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	cdifake "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
@@ -29,12 +32,14 @@ import (
 	. "github.com/onsi/gomega"
 	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -161,6 +166,15 @@ var _ = Describe("ImportConfig Controller reconcile loop", func() {
 		Expect(*pod.Spec.SecurityContext.FSGroup).To(Equal(int64(107)))
 	})
 
+	It("Should not create a POD if the import ran longer than its deadline too often", func() {
+		reconciler = createImportReconciler(createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnDeadlineExceeded: "2"}, nil))
+		_, err := reconciler.Reconcile(reconcile.Request{})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, pod)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
 	It("Should create a POD if a PVC with all needed annotations is passed, but not set fsgroup if not kubevirt contenttype", func() {
 		reconciler = createImportReconciler(createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnContentType: string(cdiv1.DataVolumeArchive)}, nil))
 		_, err := reconciler.Reconcile(reconcile.Request{})
@@ -180,6 +194,37 @@ var _ = Describe("ImportConfig Controller reconcile loop", func() {
 		Expect(pod.Spec.SecurityContext).To(BeNil())
 	})
 
+	It("Should pass the artifact selector to the POD of a registry import", func() {
+		reconciler = createImportReconciler(createPvc("testPvc1", "default", map[string]string{AnnEndpoint: "docker://registry.example.com/disk", AnnSource: SourceRegistry, AnnArtifactMediaType: "application/x-qemu-disk", AnnArtifactAnnotation: "kind=root"}, nil))
+		_, err := reconciler.Reconcile(reconcile.Request{})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, pod)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.ImporterArtifactMediaType, Value: "application/x-qemu-disk"}))
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.ImporterArtifactAnnotation, Value: "kind=root"}))
+	})
+
+	It("Should not create a POD if the namespace does not allow the source host", func() {
+		reconciler = createImportReconciler(createPvc("testPvc1", "default", map[string]string{AnnEndpoint: "http://images.example.com/disk.img"}, nil))
+		_, err := reconciler.K8sClient.CoreV1().Namespaces().Create(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "default",
+				Annotations: map[string]string{AnnAllowedSourceHosts: "images.internal"},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+		pod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, pod)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(SourceNotAllowed))
+		Expect(event).To(ContainSubstring("http://images.example.com/disk.img"))
+	})
+
 	It("Should error if a POD with the same name exists, but is not owned by the PVC, if a PVC with all needed annotations is passed", func() {
 		pod := &corev1.Pod{
 			TypeMeta: metav1.TypeMeta{
@@ -236,6 +281,53 @@ var _ = Describe("Update PVC from POD", func() {
 		Expect(errors.IsNotFound(err)).To(BeTrue())
 	})
 
+	It("Should keep the succeeded pod for the completed pod retention", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodPending)}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodSucceeded,
+		}
+		reconciler = createImportReconciler(pvc, pod)
+		config := &cdiv1.CDIConfig{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, config)).To(Succeed())
+		config.Spec.CompletedPodRetention = &metav1.Duration{Duration: time.Hour}
+		Expect(reconciler.Client.Update(context.TODO(), config)).To(Succeed())
+		err := reconciler.updatePvcFromPod(pvc, pod, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("Import Successful"))
+		By("Checking pod has been kept")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("Should delete the pod and release the scratch space, if the PVC is deleted mid-import", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodRunning)}, nil)
+		now := metav1.Now()
+		pvc.DeletionTimestamp = &now
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		scratchPvc := newScratchPersistentVolumeClaimSpec(pvc, pod, scratchNameFromPvc(pvc), "", nil)
+		reconciler = createImportReconciler(pvc, pod, scratchPvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: "default"}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: scratchPvc.Name, Namespace: "default"}, &corev1.PersistentVolumeClaim{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should not delete a PVC with the scratch space name that is not scratch space, if the PVC is deleted", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		now := metav1.Now()
+		pvc.DeletionTimestamp = &now
+		otherPvc := createPvc(scratchNameFromPvc(pvc), "default", map[string]string{}, nil)
+		reconciler = createImportReconciler(pvc, otherPvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: otherPvc.Name, Namespace: "default"}, &corev1.PersistentVolumeClaim{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
 	It("Should update the PVC status to running, if pod is running", func() {
 		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodPending)}, nil)
 		pod := createImporterTestPod(pvc, "testPvc1", nil)
@@ -345,6 +437,83 @@ var _ = Describe("Update PVC from POD", func() {
 		Expect(resPvc.GetAnnotations()[AnnPodRestarts]).To(Equal("0"))
 		// No scratch space because the pod is not in pending.
 	})
+
+	It("Should fail the import without retrying, if pod exited because it failed permanently", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodRunning)}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: common.PermanentFailureExitCode,
+							Message: encodeTerminationMessage(&util.TerminationMessage{
+								Result:    util.TerminationFailed,
+								Message:   "expected status code 200, got 401",
+								ErrorCode: util.PermanentErrorUnauthorized,
+							}),
+						},
+					},
+				},
+			},
+		}
+		reconciler = createImportReconciler(pvc, pod)
+		err := reconciler.updatePvcFromPod(pvc, pod, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.GetAnnotations()[AnnPodPhase]).To(BeEquivalentTo(corev1.PodFailed))
+		Expect(isImportFailedPermanently(resPvc)).To(BeTrue())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("Unauthorized expected status code 200, got 401"))
+		By("Checking the pod is deleted and not created again")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		_, err = reconciler.reconcilePvc(resPvc, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		newPod, err := reconciler.findImporterPod(resPvc, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(newPod).To(BeNil())
+	})
+
+	It("Should restart the pod with enlarged scratch space, if pod exited because it ran out of scratch space", func() {
+		pvc := createPvcInStorageClass("testPvc1", "default", &testStorageClass, map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodRunning)}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: common.ScratchSpaceExhaustedExitCode,
+							Message:  fmt.Sprintf(common.ScratchSpaceExhaustedMessage, 1500000000, 1000000000),
+						},
+					},
+				},
+			},
+		}
+		reconciler = createImportReconciler(pvc, pod)
+		cdiConfig := &cdiv1.CDIConfig{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiConfig)).To(Succeed())
+		maxSize := resource.MustParse("5G")
+		cdiConfig.Spec.ScratchSpaceMaxSize = &maxSize
+		Expect(reconciler.Client.Update(context.TODO(), cdiConfig)).To(Succeed())
+		err := reconciler.updatePvcFromPod(pvc, pod, reconciler.Log)
+		Expect(err).ToNot(HaveOccurred())
+		By("Checking the scratch space size is recorded")
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.GetAnnotations()[AnnScratchExhausted]).To(Equal(pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.Message))
+		Expect(resPvc.GetAnnotations()[AnnScratchSize]).To(Equal("2G"))
+		By("Checking the pod is deleted")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring("retrying with 2G of scratch space"))
+	})
 })
 
 var _ = Describe("Create Importer Pod", func() {
@@ -360,6 +529,7 @@ var _ = Describe("Create Importer Pod", func() {
 			imageSize:     "1G",
 			certConfigMap: "",
 			diskID:        "",
+			architecture:  "",
 			insecureTLS:   false,
 		}
 		pod, err := createImporterPod(reconciler.Log, reconciler.Client, reconciler.CdiClient, testImage, "5", testPullPolicy, podEnvVar, pvc, scratchPvcName)
@@ -374,7 +544,7 @@ var _ = Describe("Create Importer Pod", func() {
 			Expect(pod.Spec.SecurityContext.RunAsUser).To(Equal(&[]int64{0}[0]))
 			if scratchPvcName != nil {
 				By("Verifying scratch space is set if available")
-				Expect(len(pod.Spec.Containers[0].VolumeMounts)).To(Equal(1))
+				Expect(len(pod.Spec.Containers[0].VolumeMounts)).To(Equal(2))
 				Expect(pod.Spec.Containers[0].VolumeMounts[0].Name).To(Equal(ScratchVolName))
 				Expect(pod.Spec.Containers[0].VolumeMounts[0].MountPath).To(Equal(common.ScratchDataDir))
 			}
@@ -383,11 +553,17 @@ var _ = Describe("Create Importer Pod", func() {
 			Expect(pod.Spec.Containers[0].VolumeMounts[0].MountPath).To(Equal(common.ImporterDataDir))
 			if scratchPvcName != nil {
 				By("Verifying scratch space is set if available")
-				Expect(len(pod.Spec.Containers[0].VolumeMounts)).To(Equal(2))
+				Expect(len(pod.Spec.Containers[0].VolumeMounts)).To(Equal(3))
 				Expect(pod.Spec.Containers[0].VolumeMounts[1].Name).To(Equal(ScratchVolName))
 				Expect(pod.Spec.Containers[0].VolumeMounts[1].MountPath).To(Equal(common.ScratchDataDir))
 			}
 		}
+		By("Verifying the root filesystem is read-only")
+		Expect(*pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      TmpVolName,
+			MountPath: common.TransferTmpDir,
+		}))
 		By("Verifying container spec is correct")
 		Expect(pod.Spec.Containers[0].Image).To(Equal(testImage))
 		Expect(pod.Spec.Containers[0].ImagePullPolicy).To(BeEquivalentTo(testPullPolicy))
@@ -398,17 +574,212 @@ var _ = Describe("Create Importer Pod", func() {
 		table.Entry("should create pod with file system volume mode and scratchspace", createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodPending)}, nil), &scratchPvcName),
 		table.Entry("should create pod with block volume mode and scratchspace", createBlockPvc("testBlockPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPodPhase: string(corev1.PodPending)}, nil), &scratchPvcName),
 	)
+
+	It("should run as the importer service account of the namespace", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnSource: SourceS3}, nil)
+		podEnvVar := &importPodEnvVar{
+			ep:             testEndPoint,
+			source:         SourceS3,
+			imageSize:      "1G",
+			serviceAccount: "egress",
+		}
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Spec.ServiceAccountName).To(Equal("egress"))
+	})
+
+	It("should attach the importer pod to its networks", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnSource: SourceHTTP}, nil)
+		podEnvVar := &importPodEnvVar{
+			ep:        testEndPoint,
+			source:    SourceHTTP,
+			imageSize: "1G",
+			networks:  "storage/san",
+		}
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Annotations[AnnMultusNetworks]).To(Equal("storage/san"))
+
+		podEnvVar.networks = ""
+		pod = makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Annotations).ToNot(HaveKey(AnnMultusNetworks))
+	})
+
+	It("should set the host aliases and DNS settings of the importer pod", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnSource: SourceHTTP}, nil)
+		podEnvVar := &importPodEnvVar{
+			ep:        testEndPoint,
+			source:    SourceHTTP,
+			imageSize: "1G",
+			dns: &cdiv1.DataVolumeDNS{
+				HostAliases: []corev1.HostAlias{{IP: "10.0.0.5", Hostnames: []string{"vcenter.lab.local"}}},
+				Policy:      corev1.DNSNone,
+				Config:      &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.53"}},
+			},
+		}
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Spec.HostAliases).To(Equal(podEnvVar.dns.HostAliases))
+		Expect(pod.Spec.DNSPolicy).To(Equal(corev1.DNSNone))
+		Expect(pod.Spec.DNSConfig).To(Equal(podEnvVar.dns.Config))
+	})
+
+	It("should fail on an invalid DNS annotation", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnPodDNS: "{"}, nil)
+		_, err := getImporterDNS(pvc)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should mount the pull secrets in order", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnSource: SourceRegistry}, nil)
+		podEnvVar := &importPodEnvVar{
+			ep:          testEndPoint,
+			source:      SourceRegistry,
+			imageSize:   "1G",
+			pullSecrets: []string{"first", "second"},
+		}
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		for i, secret := range podEnvVar.pullSecrets {
+			name := fmt.Sprintf("%s-%d", PullSecretVolName, i)
+			Expect(pod.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      name,
+				MountPath: filepath.Join(common.ImporterPullSecretDir, strconv.Itoa(i)),
+				ReadOnly:  true,
+			}))
+			found := false
+			for _, vol := range pod.Spec.Volumes {
+				if vol.Name == name {
+					Expect(vol.Secret.SecretName).To(Equal(secret))
+					Expect(*vol.Secret.Optional).To(BeTrue())
+					found = true
+				}
+			}
+			Expect(found).To(BeTrue())
+		}
+	})
+
+	It("should mount the ssh secret instead of passing it in the environment", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: "ssh://host/disk.img", AnnSource: SourceSSH}, nil)
+		podEnvVar := &importPodEnvVar{
+			ep:         "ssh://host/disk.img",
+			source:     SourceSSH,
+			imageSize:  "1G",
+			secretName: "ssh-key",
+		}
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      SSHKeyVolName,
+			MountPath: common.ImporterSSHKeyDir,
+			ReadOnly:  true,
+		}))
+		Expect(pod.Spec.Volumes).To(ContainElement(corev1.Volume{
+			Name: SSHKeyVolName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: "ssh-key"},
+			},
+		}))
+		for _, env := range pod.Spec.Containers[0].Env {
+			Expect(env.Name).ToNot(Equal(common.ImporterAccessKeyID))
+			Expect(env.Name).ToNot(Equal(common.ImporterSSHInsecureSkipHostKeyCheck))
+		}
+	})
+
+	It("should pass the uuid of a Nutanix image to the importer", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: "https://prism.example.com:9440", AnnSource: SourceNutanix, AnnNutanixImageUUID: "c3e5b4a2-7d8e-4f10-9a6b-0123456789ab"}, nil)
+		podEnvVar, err := createImportEnvVar(k8sfake.NewSimpleClientset(), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(podEnvVar.diskID).To(BeEmpty())
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.ImporterNutanixImageUUID, Value: "c3e5b4a2-7d8e-4f10-9a6b-0123456789ab"}))
+	})
+
+	It("should only skip the ssh host key check when the PVC asks for it", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: "ssh://host/disk.img", AnnSource: SourceSSH, AnnSSHInsecureSkipHostKeyCheck: "true"}, nil)
+		podEnvVar, err := createImportEnvVar(k8sfake.NewSimpleClientset(), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(podEnvVar.sshInsecureSkipHostKeyCheck).To(BeTrue())
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.ImporterSSHInsecureSkipHostKeyCheck, Value: "true"}))
+	})
 })
 
 var _ = Describe("Import test env", func() {
 	const mockUID = "1111-1111-1111-1111"
 
 	It("Should create import env", func() {
-		testEnvVar := &importPodEnvVar{"myendpoint", "mysecret", SourceHTTP, string(cdiv1.DataVolumeKubeVirt), "1G", "", "", false}
+		testEnvVar := &importPodEnvVar{"myendpoint", "mysecret", SourceHTTP, string(cdiv1.DataVolumeKubeVirt), "1G", "", "", "arm64", "application/x-qemu-disk", "kind=root", "/images/*.qcow2", "", "", "", "sha256:00", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", false, false, []string{"docker://mirror.example.com/image"}, []string{"docker://mirror.example.com/image"}, nil, []string{"https://images.example.com/base"}, nil, nil}
 		Expect(reflect.DeepEqual(makeImportEnv(testEnvVar, mockUID), createImportTestEnv(testEnvVar, mockUID))).To(BeTrue())
 	})
 })
 
+var _ = Describe("Import bandwidth limit", func() {
+	It("Should annotate the PVC of a data volume with its bandwidth limit", func() {
+		dv := newImportDataVolume("test-dv")
+		rate := resource.MustParse("10Mi")
+		dv.Spec.BandwidthLimit = &cdiv1.DataVolumeBandwidthLimit{
+			Schedule: []cdiv1.DataVolumeBandwidthWindow{{Start: "08:00", End: "18:00", Rate: &rate}},
+		}
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnBandwidthLimit]).To(Equal(`{"schedule":[{"start":"08:00","end":"18:00","rate":"10Mi"}]}`))
+	})
+
+	It("Should pass the bandwidth limit of the namespace to the importer", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{AnnImportBandwidthLimit: `{"rate":"50Mi"}`},
+		}}
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		podEnvVar, err := createImportEnvVar(k8sfake.NewSimpleClientset(ns), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(makeImportEnv(podEnvVar, "uid")).To(ContainElement(corev1.EnvVar{Name: common.ImporterBandwidthLimit, Value: `{"rate":"50Mi"}`}))
+	})
+})
+
+var _ = Describe("Import encryption", func() {
+	It("Should annotate the PVC of a data volume with its key secrets", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Encryption = &cdiv1.DataVolumeEncryption{SourceSecretRef: "source-key", TargetSecretRef: "target-key"}
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnSourceKeySecret]).To(Equal("source-key"))
+		Expect(pvc.Annotations[AnnTargetKeySecret]).To(Equal("target-key"))
+	})
+
+	It("Should mount only the passphrase of the source key secret in the importer pod", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnSource: SourceHTTP, AnnSourceKeySecret: "source-key"}, nil)
+		podEnvVar, err := createImportEnvVar(k8sfake.NewSimpleClientset(), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		pod := makeImporterPodSpec(pvc.Namespace, testImage, "5", testPullPolicy, podEnvVar, pvc, nil, nil)
+		Expect(pod.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      SourceKeyVolName,
+			MountPath: common.ImporterSourceKeyDir,
+			ReadOnly:  true,
+		}))
+		Expect(pod.Spec.Volumes).To(ContainElement(corev1.Volume{
+			Name: SourceKeyVolName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: "source-key",
+					Items:      []corev1.KeyToPath{{Key: common.EncryptionPassphraseKey, Path: common.EncryptionPassphraseKey}},
+				},
+			},
+		}))
+		for _, vol := range pod.Spec.Volumes {
+			Expect(vol.Name).ToNot(Equal(TargetKeyVolName))
+		}
+	})
+
+	It("Should not use the registry cache for an encrypted import", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{
+			AnnRegistryCache:   "true",
+			AnnSource:          SourceRegistry,
+			AnnEndpoint:        "docker://registry.example.com/image@sha256:" + strings.Repeat("a", 64),
+			AnnTargetKeySecret: "target-key",
+		}, nil)
+		Expect(isRegistryCacheCandidate(pvc)).To(BeFalse())
+		delete(pvc.Annotations, AnnTargetKeySecret)
+		Expect(isRegistryCacheCandidate(pvc)).To(BeTrue())
+	})
+})
+
 func createImportReconciler(objects ...runtime.Object) *ImportReconciler {
 	objs := []runtime.Object{}
 	objs = append(objs, objects...)
@@ -471,6 +842,104 @@ func createImportTestEnv(podEnvVar *importPodEnvVar, uid string) []corev1.EnvVar
 			Name:  common.ImporterDiskID,
 			Value: podEnvVar.diskID,
 		},
+		{
+			Name:  common.ImporterArchitecture,
+			Value: podEnvVar.architecture,
+		},
+		{
+			Name:  common.ImporterArtifactMediaType,
+			Value: podEnvVar.artifactMediaType,
+		},
+		{
+			Name:  common.ImporterArtifactAnnotation,
+			Value: podEnvVar.artifactAnnotation,
+		},
+		{
+			Name:  common.ImporterDiskPath,
+			Value: podEnvVar.diskPath,
+		},
+	}
+	if len(podEnvVar.registryMirrors) > 0 {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterRegistryMirrors,
+			Value: strings.Join(podEnvVar.registryMirrors, ","),
+		})
+	}
+	if len(podEnvVar.insecureRegistryMirrors) > 0 {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterInsecureRegistryMirrors,
+			Value: strings.Join(podEnvVar.insecureRegistryMirrors, ","),
+		})
+	}
+	if podEnvVar.nutanixImageUUID != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterNutanixImageUUID,
+			Value: podEnvVar.nutanixImageUUID,
+		})
+	}
+	if podEnvVar.sshInsecureSkipHostKeyCheck {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterSSHInsecureSkipHostKeyCheck,
+			Value: strconv.FormatBool(podEnvVar.sshInsecureSkipHostKeyCheck),
+		})
+	}
+	if len(podEnvVar.backingFileURLs) > 0 {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterBackingFileURLs,
+			Value: strings.Join(podEnvVar.backingFileURLs, ","),
+		})
+	}
+	if podEnvVar.checksum != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterChecksum,
+			Value: podEnvVar.checksum,
+		})
+	}
+	if podEnvVar.imageCheck != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterImageCheck,
+			Value: podEnvVar.imageCheck,
+		})
+	}
+	if podEnvVar.verification != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterVerification,
+			Value: podEnvVar.verification,
+		})
+	}
+	if podEnvVar.blockWipe != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterBlockWipe,
+			Value: podEnvVar.blockWipe,
+		})
+	}
+
+	if podEnvVar.blockTargetOffset != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterBlockTargetOffset,
+			Value: podEnvVar.blockTargetOffset,
+		})
+	}
+
+	if podEnvVar.blockTargetPartition != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterBlockTargetPartition,
+			Value: podEnvVar.blockTargetPartition,
+		})
+	}
+
+	if podEnvVar.imageName != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterImageName,
+			Value: podEnvVar.imageName,
+		})
+	}
+
+	if podEnvVar.additionalImages != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  common.ImporterAdditionalImages,
+			Value: podEnvVar.additionalImages,
+		})
 	}
 
 	if podEnvVar.secretName != "" {
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The keys of the values the controllers log, the same in the logs of all controllers so the logs can be queried alike
+const (
+	// logKeyDataVolume is the namespace/name of a data volume
+	logKeyDataVolume = "dv"
+	// logKeyPVC is the namespace/name of a PVC
+	logKeyPVC = "pvc"
+	// logKeyPod is the namespace/name of a transfer pod
+	logKeyPod = "pod"
+	// logKeyPhase is the phase of a transfer pod or of a population
+	logKeyPhase = "phase"
+	// logKeyAttempt is the number of the attempt to populate a PVC
+	logKeyAttempt = "attempt"
+)
+
+const (
+	// logSampleTick is the period the verbose messages are sampled in
+	logSampleTick = time.Second
+	// logSampleFirst is how many times a verbose message is logged in a period before it is sampled
+	logSampleFirst = 10
+	// logSampleThereafter is the rate a verbose message is sampled at in a period, once it was logged logSampleFirst
+	// times
+	logSampleThereafter = 100
+)
+
+// logObjectKey returns the key obj is logged with
+func logObjectKey(obj metav1.Object) types.NamespacedName {
+	return types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
+
+// logSampler counts how often the messages were logged in the current period
+type logSampler struct {
+	tick              time.Duration
+	first, thereafter uint64
+	now               func() time.Time
+
+	mu     sync.Mutex
+	counts map[string]*logCount
+}
+
+type logCount struct {
+	reset time.Time
+	n     uint64
+}
+
+// sample counts message and tells if it is logged
+func (s *logSampler) sample(message string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	count, ok := s.counts[message]
+	if !ok || !now.Before(count.reset) {
+		count = &logCount{reset: now.Add(s.tick)}
+		s.counts[message] = count
+	}
+	count.n++
+	return count.n <= s.first || (count.n-s.first)%s.thereafter == 0
+}
+
+// sampledLogger is a logger that samples its verbose messages, so the messages logged for every reconcile do not
+// drown the others on clusters with many PVCs. Errors and messages at level 0 are always logged.
+type sampledLogger struct {
+	logr.Logger
+	name    string
+	sampler *logSampler
+}
+
+// newSampledLogger returns log sampling its verbose messages
+func newSampledLogger(log logr.Logger) logr.Logger {
+	return &sampledLogger{
+		Logger: log,
+		sampler: &logSampler{
+			tick:       logSampleTick,
+			first:      logSampleFirst,
+			thereafter: logSampleThereafter,
+			now:        time.Now,
+			counts:     make(map[string]*logCount),
+		},
+	}
+}
+
+func (l *sampledLogger) V(level int) logr.InfoLogger {
+	if level <= 0 {
+		return l.Logger.V(level)
+	}
+	return &sampledInfoLogger{
+		InfoLogger: l.Logger.V(level),
+		prefix:     l.name + "/" + strconv.Itoa(level) + "/",
+		sampler:    l.sampler,
+	}
+}
+
+func (l *sampledLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &sampledLogger{Logger: l.Logger.WithValues(keysAndValues...), name: l.name, sampler: l.sampler}
+}
+
+func (l *sampledLogger) WithName(name string) logr.Logger {
+	return &sampledLogger{Logger: l.Logger.WithName(name), name: l.name + "." + name, sampler: l.sampler}
+}
+
+// sampledInfoLogger samples the messages of a verbosity level, counted by the name of the logger and the level
+type sampledInfoLogger struct {
+	logr.InfoLogger
+	prefix  string
+	sampler *logSampler
+}
+
+func (l *sampledInfoLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !l.InfoLogger.Enabled() || !l.sampler.sample(l.prefix+msg) {
+		return
+	}
+	l.InfoLogger.Info(msg, keysAndValues...)
+}
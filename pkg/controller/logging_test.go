@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var _ = Describe("Sampled logger", func() {
+	var (
+		output *bytes.Buffer
+		now    time.Time
+		log    *sampledLogger
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		now = time.Now()
+		log = newSampledLogger(logf.ZapLoggerTo(output, true)).(*sampledLogger)
+		log.sampler.now = func() time.Time {
+			return now
+		}
+	})
+
+	countLines := func(message string) int {
+		return strings.Count(output.String(), message)
+	}
+
+	It("Should sample verbose messages", func() {
+		for i := 0; i < logSampleFirst+2*logSampleThereafter; i++ {
+			log.V(1).Info("reconciling")
+		}
+		Expect(countLines("reconciling")).To(Equal(logSampleFirst + 2))
+	})
+
+	It("Should log the first messages again in the next period", func() {
+		for i := 0; i < logSampleFirst+1; i++ {
+			log.V(1).Info("reconciling")
+		}
+		now = now.Add(logSampleTick)
+		log.V(1).Info("reconciling")
+		Expect(countLines("reconciling")).To(Equal(logSampleFirst + 1))
+	})
+
+	It("Should sample messages apart", func() {
+		for i := 0; i < logSampleFirst; i++ {
+			log.V(1).Info("reconciling")
+			log.WithValues(logKeyPVC, "default/testPvc1").V(1).Info("updating")
+		}
+		log.WithName("other").V(1).Info("reconciling")
+		Expect(countLines("reconciling")).To(Equal(logSampleFirst + 1))
+		Expect(countLines("updating")).To(Equal(logSampleFirst))
+	})
+
+	It("Should not sample errors and messages at level 0", func() {
+		for i := 0; i < 2*logSampleFirst; i++ {
+			log.Info("created")
+			log.Error(errors.New("failed"), "not created")
+		}
+		Expect(countLines("\tcreated")).To(Equal(2 * logSampleFirst))
+		Expect(countLines("not created")).To(Equal(2 * logSampleFirst))
+	})
+
+	It("Should log objects by namespace and name", func() {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "importer-testPvc1", Namespace: "default"}}
+		Expect(logObjectKey(pod).String()).To(Equal("default/importer-testPvc1"))
+	})
+})
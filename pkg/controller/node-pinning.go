@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// NodeUnschedulable provides a const to indicate the node a transfer pod is pinned to can not run it
+	NodeUnschedulable = "NodeUnschedulable"
+	// NodeSchedulable provides a const to indicate the node a transfer pod is pinned to runs it
+	NodeSchedulable = "NodeSchedulable"
+
+	// nodeRetryInterval is how often a transfer pinned to an unschedulable node checks the node again
+	nodeRetryInterval = 30 * time.Second
+	// hostnameLabel is the node label local persistent volumes select their node with
+	hostnameLabel = "kubernetes.io/hostname"
+)
+
+// pinnedNode returns the node the transfer pod of pvc is pinned to, by the node name of its DataVolume or by the
+// node affinity of the local persistent volume it is pre-bound to. It returns the name of the node, empty if the pod
+// is not pinned, and the node, nil if it does not exist.
+func pinnedNode(c client.Client, pvc *corev1.PersistentVolumeClaim) (string, *corev1.Node, error) {
+	if nodeName := pvc.GetAnnotations()[AnnNodeName]; nodeName != "" {
+		node := &corev1.Node{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+			return nodeName, nil, IgnoreNotFound(err)
+		}
+		return nodeName, node, nil
+	}
+	if pvc.Spec.VolumeName == "" {
+		return "", nil, nil
+	}
+	pv := &corev1.PersistentVolume{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+		return "", nil, IgnoreNotFound(err)
+	}
+	hostname := volumeHostname(pv)
+	if hostname == "" {
+		return "", nil, nil
+	}
+	nodes := &corev1.NodeList{}
+	if err := c.List(context.TODO(), nodes, client.MatchingLabels{hostnameLabel: hostname}); err != nil {
+		return "", nil, err
+	}
+	if len(nodes.Items) == 0 {
+		return hostname, nil, nil
+	}
+	return nodes.Items[0].Name, &nodes.Items[0], nil
+}
+
+// volumeHostname returns the hostname of the single node pv can be used from, empty if it can be used from others
+func volumeHostname(pv *corev1.PersistentVolume) string {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil || len(pv.Spec.NodeAffinity.Required.NodeSelectorTerms) != 1 {
+		return ""
+	}
+	term := pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0]
+	for _, expression := range term.MatchExpressions {
+		if expression.Key == hostnameLabel && expression.Operator == corev1.NodeSelectorOpIn && len(expression.Values) == 1 {
+			return expression.Values[0]
+		}
+	}
+	return ""
+}
+
+// checkPinnedNode returns why the node the transfer pod of pvc is pinned to can not run the pod, empty if it can or
+// the pod is not pinned
+func checkPinnedNode(c client.Client, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	nodeName, node, err := pinnedNode(c, pvc)
+	if err != nil || nodeName == "" {
+		return "", err
+	}
+	if node == nil {
+		return fmt.Sprintf("node %s does not exist", nodeName), nil
+	}
+	if node.Spec.Unschedulable {
+		return fmt.Sprintf("node %s is cordoned", nodeName), nil
+	}
+	ready := false
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	if !ready {
+		return fmt.Sprintf("node %s is not ready", nodeName), nil
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return fmt.Sprintf("node %s has the taint %s", nodeName, taint.ToString()), nil
+		}
+	}
+	return "", nil
+}
+
+// waitForNode records in the AnnNodeUnschedulable annotation of pvc why the node its transfer pod is pinned to can not
+// run the pod, and returns when to check the node again.
+func waitForNode(c client.Client, recorder record.EventRecorder, pvc *corev1.PersistentVolumeClaim, reason string) (reconcile.Result, error) {
+	if pvc.GetAnnotations()[AnnNodeUnschedulable] != reason {
+		if pvc.GetAnnotations() == nil {
+			pvc.SetAnnotations(make(map[string]string))
+		}
+		pvc.GetAnnotations()[AnnNodeUnschedulable] = reason
+		if err := c.Update(context.TODO(), pvc); err != nil {
+			return reconcile.Result{}, err
+		}
+		recorder.Event(pvc, corev1.EventTypeWarning, NodeUnschedulable, reason)
+	}
+	return reconcile.Result{RequeueAfter: nodeRetryInterval}, nil
+}
+
+// setPinnedNodeAffinity schedules a transfer pod to pvc on the node its DataVolume names, in addition to the nodes
+// the volume of pvc can be used from
+func setPinnedNodeAffinity(pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod) {
+	nodeName := pvc.GetAnnotations()[AnnNodeName]
+	if nodeName == "" {
+		return
+	}
+	requirement := corev1.NodeSelectorRequirement{
+		Key:      "metadata.name",
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   []string{nodeName},
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	selector := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if selector == nil || len(selector.NodeSelectorTerms) == 0 {
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchFields: []corev1.NodeSelectorRequirement{requirement}}},
+		}
+		return
+	}
+	// The terms are ORed, the requirements of a term ANDed
+	for i := range selector.NodeSelectorTerms {
+		selector.NodeSelectorTerms[i].MatchFields = append(selector.NodeSelectorTerms[i].MatchFields, requirement)
+	}
+}
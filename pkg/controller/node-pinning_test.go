@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func createNode(name string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{hostnameLabel: name},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
+
+var _ = Describe("Node pinning", func() {
+	pvcName := types.NamespacedName{Name: "testPvc1", Namespace: "default"}
+
+	table.DescribeTable("Should check the pinned node can run the transfer pod", func(modify func(*corev1.Node), expected string) {
+		node := createNode("node01", true)
+		modify(node)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+		reason, err := checkPinnedNode(c, createPvc("testPvc1", "default", map[string]string{AnnNodeName: "node01"}, nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reason).To(Equal(expected))
+	},
+		table.Entry("accept a ready node", func(*corev1.Node) {}, ""),
+		table.Entry("reject a missing node", func(node *corev1.Node) {
+			node.Name = "node02"
+		}, "node node01 does not exist"),
+		table.Entry("reject a cordoned node", func(node *corev1.Node) {
+			node.Spec.Unschedulable = true
+		}, "node node01 is cordoned"),
+		table.Entry("reject a node that is not ready", func(node *corev1.Node) {
+			node.Status.Conditions[0].Status = corev1.ConditionUnknown
+		}, "node node01 is not ready"),
+		table.Entry("reject a tainted node", func(node *corev1.Node) {
+			node.Spec.Taints = []corev1.Taint{{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}}
+		}, "node node01 has the taint maintenance:NoSchedule"),
+		table.Entry("accept a node that prefers not to schedule", func(node *corev1.Node) {
+			node.Spec.Taints = []corev1.Taint{{Key: "maintenance", Effect: corev1.TaintEffectPreferNoSchedule}}
+		}, ""),
+	)
+
+	It("Should derive the node from the local volume a PVC is pre-bound to", func() {
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "local-pv-1"},
+			Spec: corev1.PersistentVolumeSpec{
+				NodeAffinity: &corev1.VolumeNodeAffinity{
+					Required: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{
+								Key:      hostnameLabel,
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{"node01"},
+							}},
+						}},
+					},
+				},
+			},
+		}
+		node := createNode("node01", true)
+		node.Spec.Unschedulable = true
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, pv, node)
+		pvc := createPvc("testPvc1", "default", nil, nil)
+		pvc.Spec.VolumeName = pv.Name
+		reason, err := checkPinnedNode(c, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reason).To(Equal("node node01 is cordoned"))
+	})
+
+	It("Should not check the node of a PVC that is not pinned", func() {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		reason, err := checkPinnedNode(c, createPvc("testPvc1", "default", nil, nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reason).To(BeEmpty())
+	})
+
+	It("Should pin the transfer pod to the node", func() {
+		pod := &corev1.Pod{}
+		setPinnedNodeAffinity(createPvc("testPvc1", "default", map[string]string{AnnNodeName: "node01"}, nil), pod)
+		terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		Expect(terms).To(HaveLen(1))
+		Expect(terms[0].MatchFields).To(ConsistOf(corev1.NodeSelectorRequirement{
+			Key:      "metadata.name",
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{"node01"},
+		}))
+	})
+
+	It("Should pin the transfer pod to the node in addition to the nodes of its volume", func() {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{
+								{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}}}},
+								{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"b"}}}},
+							},
+						},
+					},
+				},
+			},
+		}
+		setPinnedNodeAffinity(createPvc("testPvc1", "default", map[string]string{AnnNodeName: "node01"}, nil), pod)
+		for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			Expect(term.MatchExpressions).To(HaveLen(1))
+			Expect(term.MatchFields).To(HaveLen(1))
+			Expect(term.MatchFields[0].Values).To(Equal([]string{"node01"}))
+		}
+	})
+
+	It("Should hold the importer pod while its node is cordoned", func() {
+		node := createNode("node01", true)
+		node.Spec.Unschedulable = true
+		reconciler := createImportReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnNodeName: "node01"}, nil),
+			node,
+		)
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(nodeRetryInterval))
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()[AnnNodeUnschedulable]).To(Equal("node node01 is cordoned"))
+		Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(NodeUnschedulable))
+
+		By("Creating the pod on the node once it is uncordoned")
+		node.Spec.Unschedulable = false
+		Expect(reconciler.Client.Update(context.TODO(), node)).To(Succeed())
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, pod)).To(Succeed())
+		terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		Expect(terms[0].MatchFields[0].Values).To(Equal([]string{"node01"}))
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		pvc = &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnNodeUnschedulable))
+	})
+
+	It("Should reflect the unschedulable node in the NodeUnschedulable condition of the data volume", func() {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", "default", map[string]string{AnnNodeUnschedulable: "node node01 is cordoned"}, nil)
+		propagateClaimConditions(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeNodeUnschedulable)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(NodeUnschedulable))
+		Expect(condition.Message).To(Equal("PersistentVolumeClaim default/test-dv: node node01 is cordoned"))
+
+		delete(pvc.Annotations, AnnNodeUnschedulable)
+		propagateClaimConditions(dv, pvc)
+		condition = conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeNodeUnschedulable)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(NodeSchedulable))
+	})
+
+	It("Should pin the PVC of a data volume to its node", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.NodeName = "node01"
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnNodeName]).To(Equal("node01"))
+		Expect(pvc.Annotations[AnnSelectedNode]).To(Equal("node01"))
+	})
+})
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// getOperationType returns the type of the operation populating dataVolume
+func getOperationType(dataVolume *cdiv1.DataVolume) cdiv1.OperationType {
+	if dataVolume.Spec.Source.PVC != nil {
+		return cdiv1.OperationClone
+	}
+	if dataVolume.Spec.Source.Upload != nil {
+		return cdiv1.OperationUpload
+	}
+	return cdiv1.OperationImport
+}
+
+// newOperationRecord describes the operation of dataVolume, which just succeeded or failed
+func newOperationRecord(dataVolume *cdiv1.DataVolume, message string, now time.Time) cdiv1.OperationRecord {
+	start := dataVolume.CreationTimestamp
+	return cdiv1.OperationRecord{
+		Type:           getOperationType(dataVolume),
+		Target:         dataVolume.Name,
+		Initiator:      dataVolume.Annotations[AnnInitiator],
+		StartTime:      start,
+		CompletionTime: metav1.NewTime(now),
+		Duration:       now.Sub(start.Time).Round(time.Second).String(),
+		Result:         dataVolume.Status.Phase,
+		Message:        message,
+	}
+}
+
+// addOperationRecord adds record in front of the operations of history, dropping the oldest ones
+func addOperationRecord(history *cdiv1.OperationHistory, record cdiv1.OperationRecord) {
+	operations := append([]cdiv1.OperationRecord{record}, history.Status.Operations...)
+	if len(operations) > common.OperationHistoryLength {
+		operations = operations[:common.OperationHistoryLength]
+	}
+	history.Status.Operations = operations
+}
+
+// recordOperation adds the operation of dataVolume to the OperationHistory of its namespace, and creates the
+// OperationHistory if it does not exist yet. The data volumes of a namespace share the OperationHistory, so it is
+// read again and the record added again when another data volume changed it in the meantime.
+func recordOperation(c client.Client, dataVolume *cdiv1.DataVolume, message string) error {
+	record := newOperationRecord(dataVolume, message, time.Now())
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err)
+	}, func() error {
+		history := &cdiv1.OperationHistory{}
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: dataVolume.Namespace, Name: common.OperationHistoryName}, history)
+		if k8serrors.IsNotFound(err) {
+			history = &cdiv1.OperationHistory{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.OperationHistoryName,
+					Namespace: dataVolume.Namespace,
+					Labels: map[string]string{
+						common.CDILabelKey: common.CDILabelValue,
+					},
+				},
+			}
+			addOperationRecord(history, record)
+			return c.Create(context.TODO(), history)
+		}
+		if err != nil {
+			return err
+		}
+		addOperationRecord(history, record)
+		return c.Update(context.TODO(), history)
+	})
+}
+
+// isOperationCompleted returns true if the phase of a data volume changed from curPhase to one that ends its operation
+func isOperationCompleted(curPhase cdiv1.DataVolumePhase, dataVolume *cdiv1.DataVolume) bool {
+	if curPhase == dataVolume.Status.Phase {
+		return false
+	}
+	return dataVolume.Status.Phase == cdiv1.Succeeded || dataVolume.Status.Phase == cdiv1.Failed
+}
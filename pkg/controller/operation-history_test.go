@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// conflictingClient records another operation in the OperationHistory before the first update of the
+// OperationHistory, and fails that update with a conflict like the API server would
+type conflictingClient struct {
+	client.Client
+	conflicted bool
+}
+
+func (c *conflictingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if history, ok := obj.(*cdiv1.OperationHistory); ok && !c.conflicted {
+		c.conflicted = true
+		current := &cdiv1.OperationHistory{}
+		if err := c.Client.Get(ctx, types.NamespacedName{Namespace: history.Namespace, Name: history.Name}, current); err != nil {
+			return err
+		}
+		addOperationRecord(current, cdiv1.OperationRecord{Target: "other-dv"})
+		if err := c.Client.Update(ctx, current); err != nil {
+			return err
+		}
+		return k8serrors.NewConflict(cdiv1.Resource("operationhistories"), history.Name, fmt.Errorf("the object has been modified"))
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+var _ = Describe("OperationHistory", func() {
+	historyName := types.NamespacedName{Name: common.OperationHistoryName, Namespace: metav1.NamespaceDefault}
+
+	table.DescribeTable("Should derive the operation type from the source", func(dataVolume *cdiv1.DataVolume, expected cdiv1.OperationType) {
+		Expect(getOperationType(dataVolume)).To(Equal(expected))
+	},
+		table.Entry("of an import", newImportDataVolume("test-dv"), cdiv1.OperationImport),
+		table.Entry("of a blank image", newBlankImageDataVolume("test-dv"), cdiv1.OperationImport),
+		table.Entry("of a clone", newCloneDataVolume("test-dv"), cdiv1.OperationClone),
+		table.Entry("of an upload", newUploadDataVolume("test-dv"), cdiv1.OperationUpload),
+	)
+
+	It("Should describe a completed operation", func() {
+		dataVolume := newUploadDataVolume("test-dv")
+		start := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+		dataVolume.CreationTimestamp = metav1.NewTime(start)
+		dataVolume.Annotations = map[string]string{AnnInitiator: "alice"}
+		dataVolume.Status.Phase = cdiv1.Succeeded
+
+		record := newOperationRecord(dataVolume, "Successfully uploaded into test-dv", start.Add(90*time.Minute+400*time.Millisecond))
+		Expect(record.Type).To(Equal(cdiv1.OperationUpload))
+		Expect(record.Target).To(Equal("test-dv"))
+		Expect(record.Initiator).To(Equal("alice"))
+		Expect(record.Duration).To(Equal("1h30m0s"))
+		Expect(record.Result).To(Equal(cdiv1.Succeeded))
+		Expect(record.Message).To(Equal("Successfully uploaded into test-dv"))
+	})
+
+	It("Should keep the most recent operations", func() {
+		history := &cdiv1.OperationHistory{}
+		for i := 0; i < common.OperationHistoryLength+5; i++ {
+			addOperationRecord(history, cdiv1.OperationRecord{Target: fmt.Sprintf("dv-%d", i)})
+		}
+		Expect(history.Status.Operations).To(HaveLen(common.OperationHistoryLength))
+		Expect(history.Status.Operations[0].Target).To(Equal(fmt.Sprintf("dv-%d", common.OperationHistoryLength+4)))
+		Expect(history.Status.Operations[common.OperationHistoryLength-1].Target).To(Equal("dv-5"))
+	})
+
+	It("Should record a succeeded import in the namespace", func() {
+		reconciler := createDatavolumeReconciler(newImportDataVolume("test-dv"))
+		dvName := types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: dvName})
+		Expect(err).ToNot(HaveOccurred())
+		err = reconciler.Client.Get(context.TODO(), historyName, &cdiv1.OperationHistory{})
+		Expect(err).To(HaveOccurred())
+
+		dv := &cdiv1.DataVolume{}
+		Expect(reconciler.Client.Get(context.TODO(), dvName, dv)).To(Succeed())
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		pvc.Status.Phase = corev1.ClaimPending
+		pvc.SetAnnotations(map[string]string{AnnPodPhase: string(corev1.PodSucceeded)})
+		Expect(reconciler.Client.Update(context.TODO(), pvc)).To(Succeed())
+		_, err = reconciler.reconcileDataVolumeStatus(dv, pvc)
+		Expect(err).ToNot(HaveOccurred())
+
+		history := &cdiv1.OperationHistory{}
+		Expect(reconciler.Client.Get(context.TODO(), historyName, history)).To(Succeed())
+		Expect(history.Status.Operations).To(HaveLen(1))
+		Expect(history.Status.Operations[0].Type).To(Equal(cdiv1.OperationImport))
+		Expect(history.Status.Operations[0].Target).To(Equal("test-dv"))
+		Expect(history.Status.Operations[0].Result).To(Equal(cdiv1.Succeeded))
+		Expect(history.Status.Operations[0].Message).To(Equal("Successfully imported into PVC test-dv"))
+
+		By("Not recording the operation again")
+		Expect(reconciler.Client.Get(context.TODO(), dvName, dv)).To(Succeed())
+		_, err = reconciler.reconcileDataVolumeStatus(dv, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), historyName, history)).To(Succeed())
+		Expect(history.Status.Operations).To(HaveLen(1))
+	})
+
+	It("Should not lose a record when the OperationHistory changed concurrently", func() {
+		s := scheme.Scheme
+		cdiv1.AddToScheme(s)
+		history := &cdiv1.OperationHistory{
+			ObjectMeta: metav1.ObjectMeta{Name: common.OperationHistoryName, Namespace: metav1.NamespaceDefault},
+		}
+		c := &conflictingClient{Client: fake.NewFakeClientWithScheme(s, history)}
+		dataVolume := newImportDataVolume("test-dv")
+		dataVolume.Status.Phase = cdiv1.Succeeded
+
+		Expect(recordOperation(c, dataVolume, "Successfully imported into PVC test-dv")).To(Succeed())
+		Expect(c.conflicted).To(BeTrue())
+		history = &cdiv1.OperationHistory{}
+		Expect(c.Get(context.TODO(), historyName, history)).To(Succeed())
+		Expect(history.Status.Operations).To(HaveLen(2))
+		Expect(history.Status.Operations[0].Target).To(Equal("test-dv"))
+		Expect(history.Status.Operations[1].Target).To(Equal("other-dv"))
+	})
+})
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	v1 "k8s.io/api/core/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// permanentFailureMessage returns the reason and the error of the importer in pod if it failed for a reason retrying
+// does not resolve, such as a source that rejects the credentials, see common.PermanentFailureMessage
+func permanentFailureMessage(pod *v1.Pod) (string, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			if message := terminationMessage(terminated); !message.Retryable {
+				reason := message.ErrorCode
+				if reason == "" {
+					reason = ImportFailed
+				}
+				return fmt.Sprintf(common.PermanentFailureMessage, reason, message.Message), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isImportFailedPermanently returns true if the import to pvc failed for a reason retrying does not resolve
+func isImportFailedPermanently(pvc *v1.PersistentVolumeClaim) bool {
+	_, ok := pvc.GetAnnotations()[AnnPermanentFailure]
+	return ok
+}
+
+// parsePermanentFailure returns the reason and the error of the AnnPermanentFailure annotation of a PVC, see
+// common.PermanentFailureMessage
+func parsePermanentFailure(message string) (string, string) {
+	parts := strings.SplitN(message, ": ", 2)
+	if len(parts) < 2 {
+		return ImportFailed, message
+	}
+	return parts[0], parts[1]
+}
+
+// updateChecksumMismatchCondition sets the ChecksumMismatch condition of dataVolume if the import to pvc failed
+// because its source does not match the digest it is pinned to
+func updateChecksumMismatchCondition(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	permanentFailure, ok := pvc.GetAnnotations()[AnnPermanentFailure]
+	if !ok {
+		return
+	}
+	reason, message := parsePermanentFailure(permanentFailure)
+	if reason != util.PermanentErrorChecksumMismatch {
+		return
+	}
+	current := conditions.FindStatusCondition(dataVolume.Status.Conditions, cdiv1.DataVolumeChecksumMismatch)
+	if current == nil || current.Status != v1.ConditionTrue || current.Message != message {
+		conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+			Type:    cdiv1.DataVolumeChecksumMismatch,
+			Status:  v1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+
+	corev1 "k8s.io/api/core/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Permanent failure", func() {
+	table.DescribeTable("Should parse the termination message", func(message, expectedReason, expectedMessage string) {
+		reason, parsed := parsePermanentFailure(message)
+		Expect(reason).To(Equal(expectedReason))
+		Expect(parsed).To(Equal(expectedMessage))
+	},
+		table.Entry("of an importer", fmt.Sprintf(common.PermanentFailureMessage, "NotFound", "Unable to process data: not found"), "NotFound", "Unable to process data: not found"),
+		table.Entry("without a reason", "failed", ImportFailed, "failed"),
+	)
+
+	table.DescribeTable("Should reflect a source that does not match its digest in the ChecksumMismatch condition", func(reason string, expectedStatus corev1.ConditionStatus) {
+		dv := newImportDataVolume("test-dv")
+		annotations := map[string]string{}
+		if reason != "" {
+			annotations[AnnPermanentFailure] = fmt.Sprintf(common.PermanentFailureMessage, reason, "Unable to process data: source has digest sha256:00")
+		}
+		pvc := createPvc("test-dv", "default", annotations, nil)
+		updateChecksumMismatchCondition(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeChecksumMismatch)
+		if expectedStatus == "" {
+			Expect(condition).To(BeNil())
+			return
+		}
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(expectedStatus))
+		Expect(condition.Message).To(ContainSubstring("sha256:00"))
+	},
+		table.Entry("set on a checksum mismatch", util.PermanentErrorChecksumMismatch, corev1.ConditionTrue),
+		table.Entry("not set on another permanent failure", util.PermanentErrorNotFound, corev1.ConditionStatus("")),
+		table.Entry("not set without a permanent failure", "", corev1.ConditionStatus("")),
+	)
+})
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// maxPhaseTransitions is how many phase transitions are kept in the status of a DataVolume, the oldest are dropped
+// once a retried transfer goes through more
+const maxPhaseTransitions = 32
+
+// recordPhaseTransition records in the status of dataVolumeCopy when it entered its phase, if the phase differs from
+// that of dataVolume
+func recordPhaseTransition(dataVolume, dataVolumeCopy *cdiv1.DataVolume) {
+	phase := dataVolumeCopy.Status.Phase
+	if phase == cdiv1.PhaseUnset || phase == dataVolume.Status.Phase {
+		return
+	}
+	transitions := append(dataVolumeCopy.Status.PhaseTransitions, cdiv1.DataVolumePhaseTransition{
+		Phase:          phase,
+		TransitionTime: metav1.Now(),
+	})
+	if len(transitions) > maxPhaseTransitions {
+		transitions = transitions[len(transitions)-maxPhaseTransitions:]
+	}
+	dataVolumeCopy.Status.PhaseTransitions = transitions
+}
+
+// stageDurations returns how long the stages of the import of the succeeded importer in pod took, from its
+// termination message
+func stageDurations(pod *v1.Pod) (string, bool) {
+	if message, ok := succeededTerminationMessage(pod); ok && message.StageDurations != "" {
+		return message.StageDurations, true
+	}
+	return "", false
+}
+
+// updateStageDurations records how long the stages of the import to pvc took in the status of dataVolume, from the
+// AnnStageDurations annotation of pvc, stages that can not be parsed are left out
+func updateStageDurations(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) {
+	stages, ok := pvc.GetAnnotations()[AnnStageDurations]
+	if !ok {
+		return
+	}
+	durations := &cdiv1.DataVolumeStageDurations{}
+	for _, stage := range strings.Split(stages, ",") {
+		parts := strings.SplitN(stage, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		duration, err := time.ParseDuration(parts[1])
+		if err != nil {
+			continue
+		}
+		switch parts[0] {
+		case "download":
+			durations.Download = &metav1.Duration{Duration: duration}
+		case "convert":
+			durations.Convert = &metav1.Duration{Duration: duration}
+		case "resize":
+			durations.Resize = &metav1.Duration{Duration: duration}
+		}
+	}
+	dataVolume.Status.StageDurations = durations
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Phase transitions", func() {
+	It("Should record a new phase", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Status.Phase = cdiv1.Pending
+		dvCopy := dv.DeepCopy()
+		dvCopy.Status.Phase = cdiv1.ImportScheduled
+		recordPhaseTransition(dv, dvCopy)
+		Expect(dvCopy.Status.PhaseTransitions).To(HaveLen(1))
+		Expect(dvCopy.Status.PhaseTransitions[0].Phase).To(Equal(cdiv1.ImportScheduled))
+		Expect(dvCopy.Status.PhaseTransitions[0].TransitionTime.IsZero()).To(BeFalse())
+	})
+
+	It("Should not record an unchanged phase", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Status.Phase = cdiv1.ImportInProgress
+		dvCopy := dv.DeepCopy()
+		recordPhaseTransition(dv, dvCopy)
+		Expect(dvCopy.Status.PhaseTransitions).To(BeEmpty())
+	})
+
+	It("Should drop the oldest transitions", func() {
+		dv := newImportDataVolume("test-dv")
+		for i := 0; i < maxPhaseTransitions; i++ {
+			dv.Status.PhaseTransitions = append(dv.Status.PhaseTransitions, cdiv1.DataVolumePhaseTransition{Phase: cdiv1.ImportScheduled})
+		}
+		dv.Status.PhaseTransitions[0].Phase = cdiv1.Pending
+		dv.Status.Phase = cdiv1.ImportScheduled
+		dvCopy := dv.DeepCopy()
+		dvCopy.Status.Phase = cdiv1.ImportInProgress
+		recordPhaseTransition(dv, dvCopy)
+		Expect(dvCopy.Status.PhaseTransitions).To(HaveLen(maxPhaseTransitions))
+		Expect(dvCopy.Status.PhaseTransitions[0].Phase).To(Equal(cdiv1.ImportScheduled))
+		Expect(dvCopy.Status.PhaseTransitions[maxPhaseTransitions-1].Phase).To(Equal(cdiv1.ImportInProgress))
+	})
+
+	It("Should record the phases a DataVolume goes through", func() {
+		dv := newImportDataVolume("test-dv")
+		reconciler := createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+		Expect(err).ToNot(HaveOccurred())
+		for _, phase := range []corev1.PersistentVolumeClaimPhase{corev1.ClaimPending, corev1.ClaimBound} {
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, pvc)
+			Expect(err).ToNot(HaveOccurred())
+			pvc.Status.Phase = phase
+			Expect(reconciler.Client.Update(context.TODO(), pvc)).To(Succeed())
+			_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, dv)
+		Expect(err).ToNot(HaveOccurred())
+		var phases []cdiv1.DataVolumePhase
+		for _, transition := range dv.Status.PhaseTransitions {
+			phases = append(phases, transition.Phase)
+		}
+		Expect(phases).To(Equal([]cdiv1.DataVolumePhase{cdiv1.Pending, cdiv1.PVCBound}))
+	})
+})
+
+var _ = Describe("Stage durations", func() {
+	It("Should read the stage durations of a succeeded importer", func() {
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								Message: encodeTerminationMessage(&util.TerminationMessage{
+									Result:         util.TerminationSucceeded,
+									Message:        "Import Complete",
+									Digest:         "sha256:0123",
+									StageDurations: "download=1m4s,convert=12.5s",
+								}),
+							},
+						},
+					},
+				},
+			},
+		}
+		stages, ok := stageDurations(pod)
+		Expect(ok).To(BeTrue())
+		Expect(stages).To(Equal("download=1m4s,convert=12.5s"))
+		digest, ok := contentDigest(pod)
+		Expect(ok).To(BeTrue())
+		Expect(digest).To(Equal("sha256:0123"))
+	})
+
+	It("Should record the stage durations in the status of the DataVolume", func() {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, map[string]string{AnnStageDurations: "download=1m4s,convert=12.5s,bogus=1s,resize=x"}, nil)
+		updateStageDurations(dv, pvc)
+		Expect(dv.Status.StageDurations).To(Equal(&cdiv1.DataVolumeStageDurations{
+			Download: &metav1.Duration{Duration: time.Minute + 4*time.Second},
+			Convert:  &metav1.Duration{Duration: 12500 * time.Millisecond},
+		}))
+	})
+})
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// populationPhase is the phase of populating a PVC, it is kept in the AnnPopulationPhase annotation so that a
+// controller that restarts continues where it left off, rather than inferring the phase from the pods it finds
+type populationPhase string
+
+const (
+	// populationPending means no transfer pod was created yet, or a retry waits for new pods
+	populationPending populationPhase = "Pending"
+	// populationQueued means the transfer pods wait for a CDIQuota
+	populationQueued populationPhase = "Queued"
+	// populationRunning means the transfer pods were created
+	populationRunning populationPhase = "Running"
+	// populationSucceeded means the PVC is populated, it is final
+	populationSucceeded populationPhase = "Succeeded"
+	// populationFailed means the population failed and is not retried, it is final
+	populationFailed populationPhase = "Failed"
+)
+
+const (
+	// populationStrategyUpload populates the PVC with an upload server
+	populationStrategyUpload = "upload"
+	// populationStrategyHostAssistedClone populates the PVC with a clone source pod streaming to an upload server
+	populationStrategyHostAssistedClone = "host-assisted-clone"
+)
+
+// populationTransitions are the phases each phase may move to. A PVC without a phase, one populated before the
+// phase was recorded, may move to any phase.
+var populationTransitions = map[populationPhase][]populationPhase{
+	populationPending: {populationQueued, populationRunning, populationFailed},
+	populationQueued:  {populationPending, populationRunning, populationFailed},
+	// A running population goes back to pending when its pods are gone and it is retried
+	populationRunning:   {populationPending, populationSucceeded, populationFailed},
+	populationSucceeded: {},
+	populationFailed:    {},
+}
+
+// populationPhaseOf returns the phase recorded in pvc, "" if there is none
+func populationPhaseOf(pvc *v1.PersistentVolumeClaim) populationPhase {
+	return populationPhase(pvc.GetAnnotations()[AnnPopulationPhase])
+}
+
+// populationAttempts returns how often transfer pods were created for pvc
+func populationAttempts(pvc *v1.PersistentVolumeClaim) int {
+	attempts, _ := strconv.Atoi(pvc.GetAnnotations()[AnnPopulationAttempts])
+	return attempts
+}
+
+// transitionPopulation records in the annotations of pvc that its population moved to phase with strategy, and
+// counts an attempt each time it starts running. It returns an error and leaves pvc as it is if the phase may not
+// move to phase. The caller updates pvc.
+func transitionPopulation(pvc *v1.PersistentVolumeClaim, phase populationPhase, strategy string) error {
+	current := populationPhaseOf(pvc)
+	if current == phase {
+		return nil
+	}
+	if current != "" {
+		allowed := false
+		for _, next := range populationTransitions[current] {
+			if next == phase {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.Errorf("population of PVC %s/%s may not move from %s to %s", pvc.Namespace, pvc.Name, current, phase)
+		}
+	}
+	if pvc.GetAnnotations() == nil {
+		pvc.SetAnnotations(make(map[string]string))
+	}
+	anno := pvc.GetAnnotations()
+	anno[AnnPopulationPhase] = string(phase)
+	anno[AnnPopulationStrategy] = strategy
+	if phase == populationRunning {
+		anno[AnnPopulationAttempts] = strconv.Itoa(populationAttempts(pvc) + 1)
+	}
+	return nil
+}
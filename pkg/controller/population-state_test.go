@@ -0,0 +1,43 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Population state", func() {
+	table.DescribeTable("Should move between phases", func(from, to populationPhase, allowed bool) {
+		anno := map[string]string{}
+		if from != "" {
+			anno[AnnPopulationPhase] = string(from)
+		}
+		pvc := createPvc("testPvc1", "default", anno, nil)
+		err := transitionPopulation(pvc, to, populationStrategyUpload)
+		if !allowed {
+			Expect(err).To(HaveOccurred())
+			Expect(populationPhaseOf(pvc)).To(Equal(from))
+			return
+		}
+		Expect(err).ToNot(HaveOccurred())
+		Expect(populationPhaseOf(pvc)).To(Equal(to))
+		Expect(pvc.GetAnnotations()[AnnPopulationStrategy]).To(Equal(populationStrategyUpload))
+	},
+		table.Entry("from none to running", populationPhase(""), populationRunning, true),
+		table.Entry("from pending to queued", populationPending, populationQueued, true),
+		table.Entry("from queued to running", populationQueued, populationRunning, true),
+		table.Entry("from running back to pending for a retry", populationRunning, populationPending, true),
+		table.Entry("from running to succeeded", populationRunning, populationSucceeded, true),
+		table.Entry("not from pending to succeeded", populationPending, populationSucceeded, false),
+		table.Entry("not from succeeded to running", populationSucceeded, populationRunning, false),
+		table.Entry("not from failed to pending", populationFailed, populationPending, false),
+	)
+
+	It("Should count an attempt each time the population starts running", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		for _, phase := range []populationPhase{populationPending, populationRunning, populationRunning, populationPending, populationRunning} {
+			Expect(transitionPopulation(pvc, phase, populationStrategyUpload)).To(Succeed())
+		}
+		Expect(populationAttempts(pvc)).To(Equal(2))
+	})
+})
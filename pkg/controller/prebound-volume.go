@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// isPreBound returns true if the PVC of dv names the persistent volume it binds to, rather than being provisioned
+func isPreBound(dv *cdiv1.DataVolume) bool {
+	return dv.Spec.PVC != nil && dv.Spec.PVC.VolumeName != ""
+}
+
+// CheckPreBoundVolume returns why the PVC spec of the DataVolume namespace/name can not bind to the persistent
+// volume pv it names, nil if it can
+func CheckPreBoundVolume(pv *corev1.PersistentVolume, namespace, name string, spec *corev1.PersistentVolumeClaimSpec) error {
+	if ref := pv.Spec.ClaimRef; ref != nil && (ref.Namespace != namespace || ref.Name != name) {
+		return errors.Errorf("persistent volume %s is reserved for PVC %s/%s", pv.Name, ref.Namespace, ref.Name)
+	}
+	if pv.Status.Phase == corev1.VolumeReleased || pv.Status.Phase == corev1.VolumeFailed {
+		return errors.Errorf("persistent volume %s is %s, it has to be reclaimed first", pv.Name, pv.Status.Phase)
+	}
+	if spec.StorageClassName != nil && *spec.StorageClassName != pv.Spec.StorageClassName {
+		return errors.Errorf("persistent volume %s has storage class %q, not %q", pv.Name, pv.Spec.StorageClassName, *spec.StorageClassName)
+	}
+	volumeMode, pvcVolumeMode := corev1.PersistentVolumeFilesystem, corev1.PersistentVolumeFilesystem
+	if pv.Spec.VolumeMode != nil {
+		volumeMode = *pv.Spec.VolumeMode
+	}
+	if spec.VolumeMode != nil {
+		pvcVolumeMode = *spec.VolumeMode
+	}
+	if volumeMode != pvcVolumeMode {
+		return errors.Errorf("persistent volume %s has volume mode %s, not %s", pv.Name, volumeMode, pvcVolumeMode)
+	}
+	for _, accessMode := range spec.AccessModes {
+		if !containsAccessMode(pv.Spec.AccessModes, accessMode) {
+			return errors.Errorf("persistent volume %s does not support access mode %s", pv.Name, accessMode)
+		}
+	}
+	capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+	if requested, ok := spec.Resources.Requests[corev1.ResourceStorage]; ok && capacity.Cmp(requested) < 0 {
+		return errors.Errorf("persistent volume %s has a capacity of %s, less than the requested %s", pv.Name, capacity.String(), requested.String())
+	}
+	return nil
+}
+
+func containsAccessMode(accessModes []corev1.PersistentVolumeAccessMode, accessMode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range accessModes {
+		if m == accessMode {
+			return true
+		}
+	}
+	return false
+}
+
+// setVolumeNodeAffinity schedules a transfer pod to pvc on the nodes the persistent volume pvc is pre-bound to can
+// be used from, so the scratch space of the pod is provisioned there as well, before the volume is even bound
+func setVolumeNodeAffinity(c client.Client, pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod) error {
+	if pvc.Spec.VolumeName == "" {
+		return nil
+	}
+	pv := &corev1.PersistentVolume{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+		return IgnoreNotFound(err)
+	}
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return nil
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = pv.Spec.NodeAffinity.Required.DeepCopy()
+	return nil
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Pre-bound volume", func() {
+	newLocalVolume := func() *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "local-pv-1"},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				StorageClassName: "local",
+				NodeAffinity: &corev1.VolumeNodeAffinity{
+					Required: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{
+								Key:      "kubernetes.io/hostname",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{"node01"},
+							}},
+						}},
+					},
+				},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeAvailable},
+		}
+	}
+
+	newClaimSpec := func() *corev1.PersistentVolumeClaimSpec {
+		storageClass := "local"
+		return &corev1.PersistentVolumeClaimSpec{
+			VolumeName:       "local-pv-1",
+			StorageClassName: &storageClass,
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+			},
+		}
+	}
+
+	table.DescribeTable("Should check the PVC can bind to the volume", func(modify func(*corev1.PersistentVolume, *corev1.PersistentVolumeClaimSpec), expected string) {
+		pv, spec := newLocalVolume(), newClaimSpec()
+		modify(pv, spec)
+		err := CheckPreBoundVolume(pv, "default", "test-dv", spec)
+		if expected == "" {
+			Expect(err).ToNot(HaveOccurred())
+			return
+		}
+		Expect(err).To(MatchError(ContainSubstring(expected)))
+	},
+		table.Entry("accept an available volume", func(*corev1.PersistentVolume, *corev1.PersistentVolumeClaimSpec) {}, ""),
+		table.Entry("accept a volume reserved for the PVC", func(pv *corev1.PersistentVolume, _ *corev1.PersistentVolumeClaimSpec) {
+			pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "default", Name: "test-dv"}
+		}, ""),
+		table.Entry("reject a volume reserved for another PVC", func(pv *corev1.PersistentVolume, _ *corev1.PersistentVolumeClaimSpec) {
+			pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "default", Name: "other"}
+		}, "reserved for PVC default/other"),
+		table.Entry("reject a released volume", func(pv *corev1.PersistentVolume, _ *corev1.PersistentVolumeClaimSpec) {
+			pv.Status.Phase = corev1.VolumeReleased
+		}, "reclaimed first"),
+		table.Entry("reject another storage class", func(_ *corev1.PersistentVolume, spec *corev1.PersistentVolumeClaimSpec) {
+			storageClass := "standard"
+			spec.StorageClassName = &storageClass
+		}, "storage class \"local\""),
+		table.Entry("reject another volume mode", func(_ *corev1.PersistentVolume, spec *corev1.PersistentVolumeClaimSpec) {
+			block := corev1.PersistentVolumeBlock
+			spec.VolumeMode = &block
+		}, "volume mode Filesystem"),
+		table.Entry("reject an unsupported access mode", func(_ *corev1.PersistentVolume, spec *corev1.PersistentVolumeClaimSpec) {
+			spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+		}, "access mode ReadWriteMany"),
+		table.Entry("reject a volume that is too small", func(_ *corev1.PersistentVolume, spec *corev1.PersistentVolumeClaimSpec) {
+			spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("20Gi")
+		}, "capacity of 10Gi"),
+	)
+
+	It("Should schedule the transfer pod to the nodes of the volume", func() {
+		pv := newLocalVolume()
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, pv)
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Spec.VolumeName = pv.Name
+		pod := &corev1.Pod{}
+		Expect(setVolumeNodeAffinity(c, pvc, pod)).To(Succeed())
+		Expect(pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution).To(Equal(pv.Spec.NodeAffinity.Required))
+	})
+
+	It("Should leave the scheduling of a provisioned PVC to the scheduler", func() {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, newLocalVolume())
+		pod := &corev1.Pod{}
+		Expect(setVolumeNodeAffinity(c, createPvc("test-dv", metav1.NamespaceDefault, nil, nil), pod)).To(Succeed())
+		Expect(pod.Spec.Affinity).To(BeNil())
+	})
+
+	It("Should not fall back from a pre-bound PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{
+			AnnVolumeModeFallback:     "true",
+			AnnFallbackStorageClasses: "standard",
+		}
+		dv.Spec.PVC.VolumeName = "local-pv-1"
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Status.Phase = corev1.ClaimPending
+		Expect(canFallBackVolumeMode(dv, pvc)).To(BeFalse())
+		Expect(canFallBackStorageClass(dv)).To(BeFalse())
+	})
+})
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// getTransferPriority returns the AnnPriority annotation of pvc, normal if it has none
+func getTransferPriority(pvc *v1.PersistentVolumeClaim) cdiv1.DataVolumePriority {
+	switch priority := cdiv1.DataVolumePriority(pvc.GetAnnotations()[AnnPriority]); priority {
+	case cdiv1.DataVolumePriorityLow, cdiv1.DataVolumePriorityHigh:
+		return priority
+	}
+	return cdiv1.DataVolumePriorityNormal
+}
+
+// getPriorityClassName returns the PriorityClass of the transfer pods of pvc, "" for normal priority. High
+// priority pods preempt other pods, so they run with normal priority unless the namespace of pvc allows high
+// priority with the AnnAllowHighPriority annotation.
+func getPriorityClassName(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim) (string, error) {
+	switch getTransferPriority(pvc) {
+	case cdiv1.DataVolumePriorityLow:
+		return common.TransferLowPriorityClass, nil
+	case cdiv1.DataVolumePriorityHigh:
+		ns, err := client.CoreV1().Namespaces().Get(pvc.Namespace, metav1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return "", err
+		}
+		if err == nil && ns.Annotations[AnnAllowHighPriority] == "true" {
+			return common.TransferHighPriorityClass, nil
+		}
+		klog.V(1).Infof("Namespace %s does not allow high priority transfers, using normal priority for PVC %s", pvc.Namespace, pvc.Name)
+	}
+	return "", nil
+}
+
+func priorityRank(priority cdiv1.DataVolumePriority) int {
+	switch priority {
+	case cdiv1.DataVolumePriorityLow:
+		return 0
+	case cdiv1.DataVolumePriorityHigh:
+		return 2
+	}
+	return 1
+}
+
+// checkQueuedPriority returns why the transfer to pvc has to wait for a queued transfer of higher priority in
+// the namespace of pvc, or "" if there is none.
+func checkQueuedPriority(c client.Client, pvc *v1.PersistentVolumeClaim) (string, error) {
+	rank := priorityRank(getTransferPriority(pvc))
+	if rank == priorityRank(cdiv1.DataVolumePriorityHigh) {
+		return "", nil
+	}
+	pvcs := &v1.PersistentVolumeClaimList{}
+	if err := c.List(context.TODO(), pvcs, &client.ListOptions{Namespace: pvc.Namespace}); err != nil {
+		return "", err
+	}
+	for i := range pvcs.Items {
+		queued := &pvcs.Items[i]
+		if _, ok := queued.GetAnnotations()[AnnPodQueued]; !ok || queued.Name == pvc.Name || queued.DeletionTimestamp != nil {
+			continue
+		}
+		if priorityRank(getTransferPriority(queued)) > rank {
+			return fmt.Sprintf("Waiting for the %s priority transfer to PVC %s", getTransferPriority(queued), queued.Name), nil
+		}
+	}
+	return "", nil
+}
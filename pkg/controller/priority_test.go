@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+func createHighPriorityNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{AnnAllowHighPriority: "true"},
+		},
+	}
+}
+
+var _ = Describe("Transfer priority", func() {
+	It("Should copy the priority of the data volume to the PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Priority = cdiv1.DataVolumePriorityHigh
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.GetAnnotations()[AnnPriority]).To(Equal("high"))
+		Expect(getPriorityClassName(k8sfake.NewSimpleClientset(createHighPriorityNamespace(pvc.Namespace)), pvc)).To(Equal(common.TransferHighPriorityClass))
+	})
+
+	It("Should not set a PriorityClass for normal or unknown priorities", func() {
+		client := k8sfake.NewSimpleClientset()
+		Expect(getPriorityClassName(client, createPvc("testPvc1", "default", nil, nil))).To(BeEmpty())
+		Expect(getPriorityClassName(client, createPvc("testPvc1", "default", map[string]string{AnnPriority: "normal"}, nil))).To(BeEmpty())
+		Expect(getPriorityClassName(client, createPvc("testPvc1", "default", map[string]string{AnnPriority: "urgent"}, nil))).To(BeEmpty())
+		Expect(getPriorityClassName(client, createPvc("testPvc1", "default", map[string]string{AnnPriority: "low"}, nil))).To(Equal(common.TransferLowPriorityClass))
+	})
+
+	It("Should only use the high PriorityClass in namespaces that allow it", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnPriority: "high"}, nil)
+		Expect(getPriorityClassName(k8sfake.NewSimpleClientset(), pvc)).To(BeEmpty())
+		namespace := createHighPriorityNamespace("default")
+		namespace.Annotations[AnnAllowHighPriority] = "false"
+		Expect(getPriorityClassName(k8sfake.NewSimpleClientset(namespace), pvc)).To(BeEmpty())
+		Expect(getPriorityClassName(k8sfake.NewSimpleClientset(createHighPriorityNamespace("default")), pvc)).To(Equal(common.TransferHighPriorityClass))
+	})
+
+	It("Should start queued transfers of higher priority first", func() {
+		maxPods := int32(1)
+		reconciler := createImportReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPriority: "low"}, nil),
+			createPvc("testPvc2", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPriority: "high", AnnPodQueued: "CDIQuota quota allows 1 transfer pods, 1 exist"}, nil),
+			createCDIQuota("quota", "default", cdiv1.CDIQuotaSpec{MaxTransferPods: &maxPods}),
+		)
+		lowPvc := types.NamespacedName{Name: "testPvc1", Namespace: "default"}
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: lowPvc})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), lowPvc, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()[AnnPodQueued]).To(Equal("Waiting for the high priority transfer to PVC testPvc2"))
+
+		By("Creating the pod of the high priority transfer with its PriorityClass")
+		_, err = reconciler.K8sClient.CoreV1().Namespaces().Create(createHighPriorityNamespace("default"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc2", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pod := &corev1.Pod{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc2", Namespace: "default"}, pod)).To(Succeed())
+		Expect(pod.Spec.PriorityClassName).To(Equal(common.TransferHighPriorityClass))
+	})
+
+	It("Should not make a high priority transfer wait for queued transfers", func() {
+		maxPods := int32(1)
+		reconciler := createImportReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPriority: "high"}, nil),
+			createPvc("testPvc2", "default", map[string]string{AnnEndpoint: testEndPoint, AnnPriority: "high", AnnPodQueued: "CDIQuota quota allows 1 transfer pods, 1 exist"}, nil),
+			createCDIQuota("quota", "default", cdiv1.CDIQuotaSpec{MaxTransferPods: &maxPods}),
+		)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})).To(Succeed())
+	})
+})
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// TransferQueued provides a const to indicate the transfer to a PVC waits for a CDIQuota
+	TransferQueued = "TransferQueued"
+	// TransferStarted provides a const to indicate the transfer to a PVC no longer waits for a CDIQuota
+	TransferStarted = "TransferStarted"
+
+	// quotaRetryInterval is how often a queued transfer checks the CDIQuotas of its namespace again
+	quotaRetryInterval = 10 * time.Second
+	// scratchPvcLabel is the label of scratch space PVCs, with the name of the pod using them
+	scratchPvcLabel = "cdi-controller"
+)
+
+// transferUsage is what the transfer pods and scratch space PVCs of a namespace use
+type transferUsage struct {
+	pods     int32
+	scratch  resource.Quantity
+	requests v1.ResourceList
+}
+
+// isTransferPod returns true for the pods that exist and count against a CDIQuota
+func isTransferPod(pod *v1.Pod) bool {
+	switch pod.GetLabels()[common.CDIComponentLabel] {
+	case common.ImporterPodName, common.UploadServerCDILabel, common.ClonerSourcePodName:
+	default:
+		return false
+	}
+	return pod.DeletionTimestamp == nil && pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed
+}
+
+// getTransferUsage adds up the transfer pods of namespace, what they request, and the scratch space PVCs
+func getTransferUsage(c client.Client, namespace string) (*transferUsage, error) {
+	usage := &transferUsage{requests: v1.ResourceList{}}
+	pods := &v1.PodList{}
+	podSelector := labels.SelectorFromSet(map[string]string{common.CDILabelKey: common.CDILabelValue})
+	if err := c.List(context.TODO(), pods, &client.ListOptions{Namespace: namespace, LabelSelector: podSelector}); err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if !isTransferPod(&pods.Items[i]) {
+			continue
+		}
+		usage.pods++
+		for _, container := range pods.Items[i].Spec.Containers {
+			addResources(usage.requests, container.Resources.Requests)
+		}
+	}
+
+	pvcs := &v1.PersistentVolumeClaimList{}
+	pvcSelector, err := labels.Parse(common.CDILabelSelector + "," + scratchPvcLabel)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.List(context.TODO(), pvcs, &client.ListOptions{Namespace: namespace, LabelSelector: pvcSelector}); err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs.Items {
+		if pvc.DeletionTimestamp == nil {
+			usage.scratch.Add(pvc.Spec.Resources.Requests[v1.ResourceStorage])
+		}
+	}
+	return usage, nil
+}
+
+func addResources(total, resources v1.ResourceList) {
+	for name, quantity := range resources {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+// quotaExceeded returns why pods more transfer pods requesting requests in total, with scratch space of size
+// scratch if it is not nil, would exceed quota, or "" if they would not.
+func quotaExceeded(quota *cdiv1.CDIQuota, usage *transferUsage, pods int32, requests v1.ResourceList, scratch *resource.Quantity) string {
+	if max := quota.Spec.MaxTransferPods; max != nil && usage.pods+pods > *max {
+		if pods > 1 {
+			return fmt.Sprintf("CDIQuota %s allows %d transfer pods, %d exist and %d are needed", quota.Name, *max, usage.pods, pods)
+		}
+		return fmt.Sprintf("CDIQuota %s allows %d transfer pods, %d exist", quota.Name, *max, usage.pods)
+	}
+	if max := quota.Spec.MaxScratchSpace; max != nil && scratch != nil {
+		total := usage.scratch.DeepCopy()
+		total.Add(*scratch)
+		if total.Cmp(*max) > 0 {
+			return fmt.Sprintf("CDIQuota %s allows %s of scratch space, %s is used and %s is needed", quota.Name, max.String(), usage.scratch.String(), scratch.String())
+		}
+	}
+	names := make([]string, 0, len(quota.Spec.MaxPodRequests))
+	for name := range quota.Spec.MaxPodRequests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request, ok := requests[v1.ResourceName(name)]
+		if !ok {
+			continue
+		}
+		max := quota.Spec.MaxPodRequests[v1.ResourceName(name)]
+		used := usage.requests[v1.ResourceName(name)]
+		total := used.DeepCopy()
+		total.Add(request)
+		if total.Cmp(max) > 0 {
+			return fmt.Sprintf("CDIQuota %s allows %s of %s requests, %s is used and %s is needed", quota.Name, max.String(), name, used.String(), request.String())
+		}
+	}
+	return ""
+}
+
+// checkTransferQuota returns why the transfer pod of pvc, and its scratch space PVC if scratch is set, cannot be
+// created yet because of the CDIQuotas of the namespace of pvc, or "" if they can be created. Queued transfers
+// of higher priority are started first. The upload server of a clone target also makes room for the clone
+// source pod if it runs in the same namespace, so that a started clone cannot wait forever for its source pod.
+func checkTransferQuota(c client.Client, pvc *v1.PersistentVolumeClaim, scratch bool) (string, error) {
+	pods := int32(1)
+	if exists, namespace, _ := ParseCloneRequestAnnotation(pvc); exists && namespace == pvc.Namespace {
+		pods++
+	}
+	var scratchSize *resource.Quantity
+	if scratch {
+		maxSize, err := getScratchSpaceMaxSize(c)
+		if err != nil {
+			return "", err
+		}
+		size := scratchSizeFromPvc(pvc, maxSize)
+		scratchSize = &size
+	}
+	reason, quotas, err := checkNamespaceQuota(c, pvc.Namespace, pods, scratchSize)
+	if err != nil || reason != "" || !quotas {
+		return reason, err
+	}
+	return checkQueuedPriority(c, pvc)
+}
+
+// checkCloneSourceQuota returns why the clone source pod of the clone target pvc cannot be created yet because of
+// the CDIQuotas of the namespace of the source PVC, where the pod runs, or "" if it can be created. The upload
+// server of pvc already waited for its turn, the source pod does not wait for queued transfers of higher priority.
+func checkCloneSourceQuota(c client.Client, pvc *v1.PersistentVolumeClaim) (string, error) {
+	exists, namespace, _ := ParseCloneRequestAnnotation(pvc)
+	if !exists {
+		return "", errors.Errorf("bad CloneRequest Annotation")
+	}
+	reason, _, err := checkNamespaceQuota(c, namespace, 1, nil)
+	return reason, err
+}
+
+// checkNamespaceQuota returns why pods more transfer pods, and a scratch space PVC of size scratch if it is not
+// nil, would exceed a CDIQuota of namespace, or "" if they would not. It also returns whether namespace has
+// CDIQuotas at all.
+func checkNamespaceQuota(c client.Client, namespace string, pods int32, scratch *resource.Quantity) (string, bool, error) {
+	quotas := &cdiv1.CDIQuotaList{}
+	if err := c.List(context.TODO(), quotas, &client.ListOptions{Namespace: namespace}); err != nil {
+		return "", false, err
+	}
+	if len(quotas.Items) == 0 {
+		return "", false, nil
+	}
+	usage, err := getTransferUsage(c, namespace)
+	if err != nil {
+		return "", true, err
+	}
+	podResourceRequirements, err := GetDefaultPodResourceRequirements(c)
+	if err != nil {
+		return "", true, err
+	}
+	requests := v1.ResourceList{}
+	if podResourceRequirements != nil {
+		for i := int32(0); i < pods; i++ {
+			addResources(requests, podResourceRequirements.Requests)
+		}
+	}
+	for i := range quotas.Items {
+		if reason := quotaExceeded(&quotas.Items[i], usage, pods, requests, scratch); reason != "" {
+			return reason, true, nil
+		}
+	}
+	return "", true, nil
+}
+
+// queueTransfer records in the AnnPodQueued annotation of pvc why its transfer pod is not created, and
+// returns when to check the CDIQuotas again.
+func queueTransfer(c client.Client, recorder record.EventRecorder, pvc *v1.PersistentVolumeClaim, reason string) (reconcile.Result, error) {
+	if pvc.GetAnnotations()[AnnPodQueued] != reason {
+		if pvc.GetAnnotations() == nil {
+			pvc.SetAnnotations(make(map[string]string, 0))
+		}
+		pvc.GetAnnotations()[AnnPodQueued] = reason
+		if err := c.Update(context.TODO(), pvc); err != nil {
+			return reconcile.Result{}, err
+		}
+		recorder.Event(pvc, v1.EventTypeNormal, TransferQueued, reason)
+	}
+	return reconcile.Result{RequeueAfter: quotaRetryInterval}, nil
+}
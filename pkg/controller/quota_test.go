@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+func createCDIQuota(name, ns string, spec cdiv1.CDIQuotaSpec) *cdiv1.CDIQuota {
+	return &cdiv1.CDIQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: spec,
+	}
+}
+
+func createTransferPod(name, ns, component string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				common.CDILabelKey:       common.CDILabelValue,
+				common.CDIComponentLabel: component,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:      name,
+					Resources: *createDefaultPodResourceRequirements(0, 0, 1, 1024),
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+var _ = Describe("CDIQuota", func() {
+	maxPods := int32(1)
+	podQuota := cdiv1.CDIQuotaSpec{MaxTransferPods: &maxPods}
+	pvcName := types.NamespacedName{Name: "testPvc1", Namespace: "default"}
+
+	It("Should queue the importer pod while the namespace has the maximum of transfer pods", func() {
+		running := createTransferPod("importer-other", "default", common.ImporterPodName, corev1.PodRunning)
+		reconciler := createImportReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil),
+			createCDIQuota("quota", "default", podQuota),
+			running,
+		)
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()[AnnPodQueued]).To(Equal("CDIQuota quota allows 1 transfer pods, 1 exist"))
+		Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(TransferQueued))
+
+		By("Creating the pod once the other transfer completed")
+		running.Status.Phase = corev1.PodSucceeded
+		Expect(reconciler.Client.Update(context.TODO(), running)).To(Succeed())
+		result, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})).To(Succeed())
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		pvc = &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnPodQueued))
+	})
+
+	It("Should not count the pods of other namespaces and other components", func() {
+		reconciler := createImportReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil),
+			createCDIQuota("quota", "default", podQuota),
+			createTransferPod("importer-other", "other", common.ImporterPodName, corev1.PodRunning),
+			createTransferPod("cdi-export-other", "default", common.ExporterPodName, corev1.PodRunning),
+		)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})).To(Succeed())
+	})
+
+	It("Should queue the upload pod if its scratch space exceeds the quota", func() {
+		maxScratch := resource.MustParse("1500M")
+		other := createPvc("testPvc2", "default", nil, nil)
+		otherPod := createTransferPod("cdi-upload-testPvc2", "default", common.UploadServerCDILabel, corev1.PodRunning)
+		reconciler := createUploadReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil),
+			createCDIQuota("quota", "default", cdiv1.CDIQuotaSpec{MaxScratchSpace: &maxScratch}),
+			other, otherPod, createScratchPvc(other, otherPod, ""),
+		)
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		result, err := reconciler.reconcilePVC(reconciler.Log, pvc, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, &corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()[AnnPodQueued]).To(Equal("CDIQuota quota allows 1500M of scratch space, 1G is used and 1G is needed"))
+	})
+
+	It("Should not queue a clone target, which needs no scratch space", func() {
+		maxScratch := resource.MustParse("1500M")
+		other := createPvc("testPvc3", "default", nil, nil)
+		otherPod := createTransferPod("cdi-upload-testPvc3", "default", common.UploadServerCDILabel, corev1.PodRunning)
+		reconciler := createUploadReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnCloneRequest: "default/testPvc2"}, nil),
+			createPvc("testPvc2", "default", nil, nil),
+			createCDIQuota("quota", "default", cdiv1.CDIQuotaSpec{MaxScratchSpace: &maxScratch}),
+			other, otherPod, createScratchPvc(other, otherPod, ""),
+		)
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		_, err := reconciler.reconcilePVC(reconciler.Log, pvc, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, &corev1.Pod{})).To(Succeed())
+	})
+
+	It("Should make room for the clone source pod when creating the upload server of a clone target", func() {
+		maxPods := int32(2)
+		reconciler := createUploadReconciler(
+			createPvc("testPvc1", "default", map[string]string{AnnCloneRequest: "default/testPvc2"}, nil),
+			createPvc("testPvc2", "default", nil, nil),
+			createCDIQuota("quota", "default", cdiv1.CDIQuotaSpec{MaxTransferPods: &maxPods}),
+			createTransferPod("importer-other", "default", common.ImporterPodName, corev1.PodRunning),
+		)
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		result, err := reconciler.reconcilePVC(reconciler.Log, pvc, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()[AnnPodQueued]).To(Equal("CDIQuota quota allows 2 transfer pods, 1 exist and 2 are needed"))
+	})
+
+	It("Should queue the clone source pod while the namespace of the source has the maximum of transfer pods", func() {
+		running := createTransferPod("importer-other", "source-ns", common.ImporterPodName, corev1.PodRunning)
+		reconciler := createCloneReconciler(
+			createPvc("testPvc1", "default", map[string]string{
+				AnnCloneRequest: "source-ns/source", AnnPodReady: "true", AnnCloneToken: "foobaz", AnnUploadClientName: "uploadclient"}, nil),
+			createPvc("source", "source-ns", nil, nil),
+			createCDIQuota("quota", "source-ns", podQuota),
+			running,
+		)
+		reconciler.tokenValidator.(*FakeValidator).match = "foobaz"
+		reconciler.tokenValidator.(*FakeValidator).Name = "source"
+		reconciler.tokenValidator.(*FakeValidator).Namespace = "source-ns"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetNamespace"] = "default"
+		reconciler.tokenValidator.(*FakeValidator).Params["targetName"] = "testPvc1"
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()[AnnPodQueued]).To(Equal("CDIQuota quota allows 1 transfer pods, 1 exist"))
+		sourcePod, err := reconciler.findCloneSourcePod(pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).To(BeNil())
+
+		By("Creating the source pod once the other transfer completed")
+		running.Status.Phase = corev1.PodSucceeded
+		Expect(reconciler.Client.Update(context.TODO(), running)).To(Succeed())
+		result, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		pvc = &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnPodQueued))
+		sourcePod, err = reconciler.findCloneSourcePod(pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sourcePod).ToNot(BeNil())
+	})
+
+	table.DescribeTable("Should check the pod requests", func(maxRequests corev1.ResourceList, expected string) {
+		usage := &transferUsage{requests: corev1.ResourceList{}}
+		addResources(usage.requests, createDefaultPodResourceRequirements(0, 0, 1, 1024).Requests)
+		quota := createCDIQuota("quota", "default", cdiv1.CDIQuotaSpec{MaxPodRequests: maxRequests})
+		requests := createDefaultPodResourceRequirements(0, 0, 1, 1024).Requests
+		Expect(quotaExceeded(quota, usage, 1, requests, nil)).To(Equal(expected))
+	},
+		table.Entry("within the quota", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}, ""),
+		table.Entry("over the cpu quota", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m")}, "CDIQuota quota allows 1500m of cpu requests, 1 is used and 1 is needed"),
+		table.Entry("over the memory quota", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2k")}, "CDIQuota quota allows 2k of memory requests, 1024 is used and 1024 is needed"),
+		table.Entry("for a resource not requested", corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("1")}, ""),
+	)
+
+	It("Should reflect the queued annotation in the Queued condition of the data volume", func() {
+		dv := newImportDataVolume("test-dv")
+		pvc := createPvc("test-dv", "default", map[string]string{AnnPodQueued: "CDIQuota quota allows 1 transfer pods, 1 exist"}, nil)
+		propagateClaimConditions(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeQueued)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(TransferQueued))
+		Expect(condition.Message).To(Equal("PersistentVolumeClaim default/test-dv: CDIQuota quota allows 1 transfer pods, 1 exist"))
+
+		delete(pvc.Annotations, AnnPodQueued)
+		propagateClaimConditions(dv, pvc)
+		condition = conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeQueued)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(TransferStarted))
+	})
+
+	It("Should not add a Queued condition to a data volume that was never queued", func() {
+		dv := newImportDataVolume("test-dv")
+		propagateClaimConditions(dv, createPvc("test-dv", "default", nil, nil))
+		Expect(dv.Status.Conditions).To(BeEmpty())
+	})
+})
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// setReadOnlyRootFilesystem runs the containers of a transfer pod with a read-only root filesystem, as admission
+// policies commonly require. The transfer tools write their temporary files, such as certificates, ssh keys and the
+// blobs skopeo downloads, to an emptyDir mounted at the temporary directory instead.
+func setReadOnlyRootFilesystem(pod *corev1.Pod) {
+	readOnly := true
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: TmpVolName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if container.SecurityContext == nil {
+			container.SecurityContext = &corev1.SecurityContext{}
+		}
+		container.SecurityContext.ReadOnlyRootFilesystem = &readOnly
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      TmpVolName,
+			MountPath: common.TransferTmpDir,
+		})
+		// skopeo defaults to /var/tmp
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "TMPDIR",
+			Value: common.TransferTmpDir,
+		})
+	}
+}
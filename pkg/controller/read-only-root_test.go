@@ -0,0 +1,39 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Read-only root filesystem", func() {
+	It("Should give the containers of transfer pods a read-only root filesystem and a writable temporary directory", func() {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: common.ImporterPodName},
+					{
+						Name:            "sidecar",
+						SecurityContext: &corev1.SecurityContext{RunAsUser: &[]int64{107}[0]},
+					},
+				},
+			},
+		}
+		setReadOnlyRootFilesystem(pod)
+		Expect(pod.Spec.Volumes).To(ConsistOf(corev1.Volume{
+			Name: TmpVolName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		}))
+		for _, container := range pod.Spec.Containers {
+			Expect(*container.SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+			Expect(container.VolumeMounts).To(ConsistOf(corev1.VolumeMount{Name: TmpVolName, MountPath: common.TransferTmpDir}))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "TMPDIR", Value: common.TransferTmpDir}))
+		}
+		Expect(*pod.Spec.Containers[1].SecurityContext.RunAsUser).To(Equal(int64(107)))
+	})
+})
@@ -0,0 +1,278 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// AnnRegistryCache marks a registry import as a cache entry for the digest it was imported from
+	AnnRegistryCache = AnnAPIGroup + "/storage.import.registryCache"
+	// AnnRegistryDigest is the verified digest a cache entry was imported from, or a cache clone was requested for
+	AnnRegistryDigest = AnnAPIGroup + "/storage.import.registryDigest"
+	// AnnRegistryCacheSource is the name of the cache entry a PVC is cloned from instead of imported
+	AnnRegistryCacheSource = AnnAPIGroup + "/storage.import.registryCacheSource"
+
+	// LabelRegistryDigest is a PVC label used to find cache entries by digest, it holds a prefix of the digest hex
+	LabelRegistryDigest = AnnAPIGroup + "/registry-digest"
+)
+
+var (
+	// Matches a registry url pinned by digest, for instance docker://quay.io/image@sha256:<hex>
+	registryDigestRegExp = regexp.MustCompile("@(sha256:[a-f0-9]{64})$")
+
+	registryCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_registry_cache_hits_total",
+			Help: "The number of registry DataVolumes cloned from a cached import of the same digest",
+		},
+	)
+	registryCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_registry_cache_misses_total",
+			Help: "The number of registry DataVolumes pinned by digest that had to be imported",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(registryCacheHits, registryCacheMisses)
+}
+
+// RegistryCacheReconciler members
+type RegistryCacheReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+}
+
+// NewRegistryCacheController creates a new instance of the registry cache controller.
+func NewRegistryCacheController(mgr manager.Manager, log logr.Logger) (controller.Controller, error) {
+	reconciler := &RegistryCacheReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Log:    newSampledLogger(log.WithName("registry-cache-controller")),
+	}
+	registryCacheController, err := controller.New("registry-cache-controller", mgr, controller.Options{
+		Reconciler: reconciler,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := registryCacheController.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, &handler.EnqueueRequestForObject{}); err != nil {
+		return nil, err
+	}
+	return registryCacheController, nil
+}
+
+// registryDigest returns the digest a registry url is pinned to, if any.
+func registryDigest(url string) (string, bool) {
+	match := registryDigestRegExp.FindStringSubmatch(url)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// registryDigestLabelValue shortens a digest so it fits in a label value.
+func registryDigestLabelValue(digest string) string {
+	value := strings.TrimPrefix(digest, "sha256:")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return value
+}
+
+// isRegistryCacheCandidate returns true if the PVC was marked as a cache entry and imports a registry image by digest,
+// without encryption.
+func isRegistryCacheCandidate(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Annotations[AnnRegistryCache] != "true" || pvc.Annotations[AnnSource] != SourceRegistry {
+		return false
+	}
+	if metav1.HasAnnotation(pvc.ObjectMeta, AnnSourceKeySecret) || metav1.HasAnnotation(pvc.ObjectMeta, AnnTargetKeySecret) {
+		return false
+	}
+	_, ok := registryDigest(pvc.Annotations[AnnEndpoint])
+	return ok
+}
+
+// Reconcile the reconcile loop for PVCs that are registry cache entries.
+func (r *RegistryCacheReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
+	log := r.Log.WithValues(logKeyPVC, req.NamespacedName)
+	log.V(1).Info("reconciling registry cache PVCs")
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), req.NamespacedName, pvc); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if pvc.DeletionTimestamp != nil || !isRegistryCacheCandidate(pvc) || !podSucceededFromPVC(pvc) {
+		return reconcile.Result{}, nil
+	}
+	dv, err := getControllingDataVolume(r.Client, pvc)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !isRegistryCacheDataVolume(dv, pvc) {
+		log.V(1).Info("Not recording registry cache entry that was not imported for a DataVolume")
+		return reconcile.Result{}, nil
+	}
+
+	// Skopeo verifies every blob against the digest when pulling by digest, so a successful
+	// import means the content of the PVC matches it.
+	digest, _ := registryDigest(pvc.Annotations[AnnEndpoint])
+	if pvc.Annotations[AnnRegistryDigest] == digest && pvc.Labels[LabelRegistryDigest] == registryDigestLabelValue(digest) {
+		return reconcile.Result{}, nil
+	}
+
+	pvcCopy := pvc.DeepCopy()
+	if pvcCopy.Labels == nil {
+		pvcCopy.Labels = make(map[string]string)
+	}
+	pvcCopy.Annotations[AnnRegistryDigest] = digest
+	pvcCopy.Labels[LabelRegistryDigest] = registryDigestLabelValue(digest)
+	log.V(1).Info("Recording registry cache entry", "digest", digest)
+	return reconcile.Result{}, r.Client.Update(context.TODO(), pvcCopy)
+}
+
+// getControllingDataVolume returns the DataVolume the PVC was created for, or nil if it was not created for one.
+func getControllingDataVolume(c client.Client, pvc *corev1.PersistentVolumeClaim) (*cdiv1.DataVolume, error) {
+	ref := metav1.GetControllerOf(pvc)
+	if ref == nil || ref.Kind != "DataVolume" {
+		return nil, nil
+	}
+	dv := &cdiv1.DataVolume{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: pvc.Namespace, Name: ref.Name}, dv); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if dv.UID != ref.UID {
+		return nil, nil
+	}
+	return dv, nil
+}
+
+// isRegistryCacheDataVolume returns true if the cache entry PVC was created for the DataVolume, which imports the
+// same image into a cache entry.
+func isRegistryCacheDataVolume(dv *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) bool {
+	return dv != nil &&
+		dv.Annotations[AnnRegistryCache] == "true" &&
+		dv.Spec.Source.Registry != nil &&
+		dv.Spec.Source.Registry.URL == pvc.Annotations[AnnEndpoint]
+}
+
+// isVerifiedRegistryCache returns true if the PVC is a populated cache entry for the digest. The annotations of a PVC
+// can be changed by its users, so they are only trusted on a PVC the DataVolume controller created for a cache
+// import of the digest that the DataVolume controller saw succeed.
+func isVerifiedRegistryCache(c client.Client, pvc *corev1.PersistentVolumeClaim, digest string) (bool, error) {
+	if pvc.DeletionTimestamp != nil || pvc.Status.Phase != corev1.ClaimBound ||
+		!isRegistryCacheCandidate(pvc) || !podSucceededFromPVC(pvc) ||
+		pvc.Annotations[AnnRegistryDigest] != digest {
+		return false, nil
+	}
+	if endpointDigest, _ := registryDigest(pvc.Annotations[AnnEndpoint]); endpointDigest != digest {
+		return false, nil
+	}
+	dv, err := getControllingDataVolume(c, pvc)
+	if err != nil {
+		return false, err
+	}
+	return isRegistryCacheDataVolume(dv, pvc) && dv.Status.Phase == cdiv1.Succeeded, nil
+}
+
+// findRegistryCachePVC returns a populated cache entry in the namespace for the digest, or nil if there is none.
+func findRegistryCachePVC(c client.Client, namespace, digest string) (*corev1.PersistentVolumeClaim, error) {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	listOptions := client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{LabelRegistryDigest: registryDigestLabelValue(digest)}),
+	}
+	if err := c.List(context.TODO(), pvcs, &listOptions); err != nil {
+		return nil, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		verified, err := isVerifiedRegistryCache(c, pvc, digest)
+		if err != nil {
+			return nil, err
+		}
+		if verified {
+			return pvc, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateRegistryCacheSource checks the source of a clone is the cache entry the target was redirected to.
+// It replaces the clone token for these clones, which are only allowed within a namespace. Both sides are
+// checked against their DataVolumes: the source must be a verified cache entry, and the target must have been
+// created for a DataVolume importing the image pinned by the same digest, which it could import anyway.
+func validateRegistryCacheSource(c client.Client, source, target *corev1.PersistentVolumeClaim) error {
+	invalid := errors.New("invalid registry cache source")
+	digest := target.Annotations[AnnRegistryDigest]
+	if source.Namespace != target.Namespace ||
+		source.Name != target.Annotations[AnnRegistryCacheSource] ||
+		digest == "" ||
+		source.Labels[LabelRegistryDigest] != registryDigestLabelValue(digest) {
+		return invalid
+	}
+	verified, err := isVerifiedRegistryCache(c, source, digest)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return invalid
+	}
+	dv, err := getControllingDataVolume(c, target)
+	if err != nil {
+		return err
+	}
+	if dv == nil || dv.Spec.Source.Registry == nil || dv.Annotations[AnnRegistryCache] == "true" {
+		return invalid
+	}
+	if targetDigest, _ := registryDigest(dv.Spec.Source.Registry.URL); targetDigest != digest {
+		return invalid
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	testRegistryDigest    = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testRegistryDigestURL = "docker://registry.example.com/fedora@" + testRegistryDigest
+)
+
+var registryCacheLog = logf.Log.WithName("registry-cache-controller-test")
+
+var _ = Describe("Registry digest", func() {
+	table.DescribeTable("should parse", func(url, expectedDigest string, expectedOk bool) {
+		digest, ok := registryDigest(url)
+		Expect(ok).To(Equal(expectedOk))
+		Expect(digest).To(Equal(expectedDigest))
+	},
+		table.Entry("a url pinned by digest", testRegistryDigestURL, testRegistryDigest, true),
+		table.Entry("a url with a tag", "docker://registry.example.com/fedora:31", "", false),
+		table.Entry("a url with a short digest", "docker://registry.example.com/fedora@sha256:0123", "", false),
+	)
+
+	It("should shorten the digest to a valid label value", func() {
+		value := registryDigestLabelValue(testRegistryDigest)
+		Expect(value).To(HaveLen(63))
+		Expect(testRegistryDigest).To(HaveSuffix(value + "f"))
+	})
+})
+
+var _ = Describe("Registry cache controller reconcile loop", func() {
+	It("Should record the digest of a succeeded cache import", func() {
+		pvc := createBoundPvc("cache", "default", cacheImportAnnotations(string(corev1.PodSucceeded)))
+		dv := ownRegistryCachePvc(pvc)
+		reconciler := createRegistryCacheReconciler(dv, pvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "cache", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "cache", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.Annotations[AnnRegistryDigest]).To(Equal(testRegistryDigest))
+		Expect(resPvc.Labels[LabelRegistryDigest]).To(Equal(registryDigestLabelValue(testRegistryDigest)))
+	})
+
+	It("Should not record the digest of a PVC that was not created for a cache DataVolume", func() {
+		pvc := createBoundPvc("cache", "default", cacheImportAnnotations(string(corev1.PodSucceeded)))
+		reconciler := createRegistryCacheReconciler(pvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "cache", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "cache", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.Annotations).ToNot(HaveKey(AnnRegistryDigest))
+		Expect(resPvc.Labels).ToNot(HaveKey(LabelRegistryDigest))
+	})
+
+	It("Should not record the digest while the import is running", func() {
+		pvc := createBoundPvc("cache", "default", cacheImportAnnotations(string(corev1.PodRunning)))
+		dv := ownRegistryCachePvc(pvc)
+		reconciler := createRegistryCacheReconciler(dv, pvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "cache", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "cache", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.Annotations).ToNot(HaveKey(AnnRegistryDigest))
+		Expect(resPvc.Labels).ToNot(HaveKey(LabelRegistryDigest))
+	})
+
+	It("Should not record the digest of a PVC that is not a cache entry", func() {
+		annotations := cacheImportAnnotations(string(corev1.PodSucceeded))
+		delete(annotations, AnnRegistryCache)
+		pvc := createBoundPvc("cache", "default", annotations)
+		reconciler := createRegistryCacheReconciler(pvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "cache", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "cache", Namespace: "default"}, resPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resPvc.Annotations).ToNot(HaveKey(AnnRegistryDigest))
+	})
+})
+
+var _ = Describe("Registry cache lookup", func() {
+	var (
+		reconciler *DatavolumeReconciler
+	)
+	AfterEach(func() {
+		if reconciler != nil {
+			close(reconciler.recorder.(*record.FakeRecorder).Events)
+			reconciler = nil
+		}
+	})
+
+	It("Should clone a DataVolume pinned by digest from the cache entry", func() {
+		hits := readCounter(registryCacheHits)
+		dv := newRegistryDigestDataVolume("test-dv")
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		reconciler = createDatavolumeReconciler(dv, cacheDv, cachePvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: "default"}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnCloneRequest]).To(Equal("default/cache"))
+		Expect(pvc.Annotations[AnnRegistryCacheSource]).To(Equal("cache"))
+		Expect(pvc.Annotations[AnnRegistryDigest]).To(Equal(testRegistryDigest))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnEndpoint))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnSource))
+		Expect(readCounter(registryCacheHits)).To(Equal(hits + 1))
+	})
+
+	It("Should import a DataVolume pinned by digest if there is no cache entry", func() {
+		misses := readCounter(registryCacheMisses)
+		dv := newRegistryDigestDataVolume("test-dv")
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "other")
+		reconciler = createDatavolumeReconciler(dv, cacheDv, cachePvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: "default"}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnEndpoint]).To(Equal(testRegistryDigestURL))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnCloneRequest))
+		Expect(readCounter(registryCacheMisses)).To(Equal(misses + 1))
+	})
+
+	table.DescribeTable("Should import a DataVolume pinned by digest if the cache entry is not trusted", func(mutate func(*cdiv1.DataVolume, *corev1.PersistentVolumeClaim) []runtime.Object) {
+		dv := newRegistryDigestDataVolume("test-dv")
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		objs := append([]runtime.Object{dv}, mutate(cacheDv, cachePvc)...)
+		reconciler = createDatavolumeReconciler(objs...)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: "default"}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnEndpoint]).To(Equal(testRegistryDigestURL))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnCloneRequest))
+	},
+		table.Entry("with annotations set on a PVC not created for a DataVolume", func(cacheDv *cdiv1.DataVolume, cachePvc *corev1.PersistentVolumeClaim) []runtime.Object {
+			cachePvc.OwnerReferences = nil
+			return []runtime.Object{cacheDv, cachePvc}
+		}),
+		table.Entry("whose DataVolume no longer exists", func(cacheDv *cdiv1.DataVolume, cachePvc *corev1.PersistentVolumeClaim) []runtime.Object {
+			return []runtime.Object{cachePvc}
+		}),
+		table.Entry("whose DataVolume did not succeed", func(cacheDv *cdiv1.DataVolume, cachePvc *corev1.PersistentVolumeClaim) []runtime.Object {
+			cacheDv.Status.Phase = cdiv1.ImportInProgress
+			return []runtime.Object{cacheDv, cachePvc}
+		}),
+		table.Entry("whose DataVolume imports another image", func(cacheDv *cdiv1.DataVolume, cachePvc *corev1.PersistentVolumeClaim) []runtime.Object {
+			cacheDv.Spec.Source.Registry.URL = "docker://registry.example.com/other@" + testRegistryDigest
+			return []runtime.Object{cacheDv, cachePvc}
+		}),
+		table.Entry("that imports from another digest", func(cacheDv *cdiv1.DataVolume, cachePvc *corev1.PersistentVolumeClaim) []runtime.Object {
+			url := "docker://registry.example.com/fedora@sha256:" + strings.Repeat("1", 64)
+			cacheDv.Spec.Source.Registry.URL = url
+			cachePvc.Annotations[AnnEndpoint] = url
+			return []runtime.Object{cacheDv, cachePvc}
+		}),
+	)
+
+	It("Should clear the registry source annotations of a DataVolume cloned from the cache", func() {
+		dv := newRegistryDigestDataVolume("test-dv")
+		dv.Spec.Source.Registry.ArtifactMediaType = "application/x-qemu-disk"
+		dv.Spec.Source.Registry.DiskPath = "/disk/*.img"
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		reconciler = createDatavolumeReconciler(dv, cacheDv, cachePvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: "default"}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations).To(HaveKey(AnnCloneRequest))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnArtifactMediaType))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnDiskPath))
+	})
+
+	It("Should import a DataVolume that is itself a cache entry", func() {
+		dv := newRegistryDigestDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnRegistryCache: "true"}
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		reconciler = createDatavolumeReconciler(dv, cacheDv, cachePvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: "default"}, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnEndpoint]).To(Equal(testRegistryDigestURL))
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnCloneRequest))
+	})
+})
+
+var _ = Describe("Registry cache clone validation", func() {
+	var (
+		targetDv *cdiv1.DataVolume
+		target   *corev1.PersistentVolumeClaim
+	)
+	BeforeEach(func() {
+		targetDv = newRegistryDigestDataVolume("target")
+		targetDv.UID = "default-dv-target"
+		target = createPvc("target", "default", map[string]string{AnnRegistryCacheSource: "cache", AnnRegistryDigest: testRegistryDigest}, nil)
+		target.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(targetDv, cdiv1.SchemeGroupVersion.WithKind("DataVolume"))}
+	})
+
+	It("Should accept the cache entry the target was redirected to", func() {
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		c := createRegistryCacheClient(targetDv, cacheDv)
+		Expect(validateRegistryCacheSource(c, cachePvc, target)).To(Succeed())
+	})
+
+	It("Should reject a source in another namespace", func() {
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "other")
+		c := createRegistryCacheClient(targetDv, cacheDv)
+		Expect(validateRegistryCacheSource(c, cachePvc, target)).ToNot(Succeed())
+	})
+
+	It("Should reject a source that is not a cache entry", func() {
+		c := createRegistryCacheClient(targetDv)
+		Expect(validateRegistryCacheSource(c, createBoundPvc("cache", "default", map[string]string{}), target)).ToNot(Succeed())
+	})
+
+	It("Should reject a source with cache annotations that was not created for a DataVolume", func() {
+		c := createRegistryCacheClient(targetDv)
+		Expect(validateRegistryCacheSource(c, createRegistryCachePvc("cache", "default"), target)).ToNot(Succeed())
+	})
+
+	It("Should reject a target that was not created for a DataVolume", func() {
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		c := createRegistryCacheClient(cacheDv)
+		target.OwnerReferences = nil
+		Expect(validateRegistryCacheSource(c, cachePvc, target)).ToNot(Succeed())
+	})
+
+	It("Should reject a target whose DataVolume imports another digest", func() {
+		cacheDv, cachePvc := createRegistryCacheEntry("cache", "default")
+		targetDv.Spec.Source.Registry.URL = "docker://registry.example.com/fedora@sha256:" + strings.Repeat("1", 64)
+		c := createRegistryCacheClient(targetDv, cacheDv)
+		Expect(validateRegistryCacheSource(c, cachePvc, target)).ToNot(Succeed())
+	})
+})
+
+func cacheImportAnnotations(podPhase string) map[string]string {
+	return map[string]string{
+		AnnRegistryCache: "true",
+		AnnSource:        SourceRegistry,
+		AnnEndpoint:      testRegistryDigestURL,
+		AnnPodPhase:      podPhase,
+	}
+}
+
+func createRegistryCachePvc(name, ns string) *corev1.PersistentVolumeClaim {
+	annotations := cacheImportAnnotations(string(corev1.PodSucceeded))
+	annotations[AnnRegistryDigest] = testRegistryDigest
+	pvc := createPvc(name, ns, annotations, map[string]string{LabelRegistryDigest: registryDigestLabelValue(testRegistryDigest)})
+	pvc.Status.Phase = corev1.ClaimBound
+	return pvc
+}
+
+// createRegistryCacheEntry returns a populated cache entry PVC and the DataVolume it was imported for
+func createRegistryCacheEntry(name, ns string) (*cdiv1.DataVolume, *corev1.PersistentVolumeClaim) {
+	pvc := createRegistryCachePvc(name, ns)
+	return ownRegistryCachePvc(pvc), pvc
+}
+
+// ownRegistryCachePvc makes the PVC owned by a succeeded cache DataVolume, and returns the DataVolume
+func ownRegistryCachePvc(pvc *corev1.PersistentVolumeClaim) *cdiv1.DataVolume {
+	dv := newRegistryDigestDataVolume(pvc.Name)
+	dv.Namespace = pvc.Namespace
+	dv.UID = types.UID(pvc.Namespace + "-dv-" + pvc.Name)
+	dv.Annotations = map[string]string{AnnRegistryCache: "true"}
+	dv.Status.Phase = cdiv1.Succeeded
+	pvc.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(dv, cdiv1.SchemeGroupVersion.WithKind("DataVolume"))}
+	return dv
+}
+
+func newRegistryDigestDataVolume(name string) *cdiv1.DataVolume {
+	return &cdiv1.DataVolume{
+		TypeMeta: metav1.TypeMeta{APIVersion: cdiv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: metav1.NamespaceDefault,
+		},
+		Spec: cdiv1.DataVolumeSpec{
+			Source: cdiv1.DataVolumeSource{
+				Registry: &cdiv1.DataVolumeSourceRegistry{
+					URL: testRegistryDigestURL,
+				},
+			},
+			PVC: createPvc(name, metav1.NamespaceDefault, nil, nil).Spec.DeepCopy(),
+		},
+	}
+}
+
+func createRegistryCacheClient(objects ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	cdiv1.AddToScheme(s)
+	return fake.NewFakeClientWithScheme(s, objects...)
+}
+
+func createRegistryCacheReconciler(objects ...runtime.Object) *RegistryCacheReconciler {
+	s := scheme.Scheme
+	cdiv1.AddToScheme(s)
+	return &RegistryCacheReconciler{
+		Client: fake.NewFakeClientWithScheme(s, objects...),
+		Scheme: s,
+		Log:    registryCacheLog,
+	}
+}
+
+func readCounter(counter prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	Expect(counter.Write(metric)).To(Succeed())
+	return metric.GetCounter().GetValue()
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// RegistryCacheExpired provides a const to indicate a registry cache entry is not kept by the retention policy
+	RegistryCacheExpired = "RegistryCacheExpired"
+
+	// registryCacheCollectorInterval is how often the retention policy of the registry cache is enforced
+	registryCacheCollectorInterval = 10 * time.Minute
+)
+
+var (
+	registryCacheExpired = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cdi_registry_cache_entries_expired",
+			Help: "The number of registry cache entries the retention policy does not keep, found by the last pass of the registry cache collector",
+		},
+	)
+	registryCacheDeleted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_registry_cache_entries_deleted_total",
+			Help: "The number of registry cache entries deleted by the retention policy",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(registryCacheExpired, registryCacheDeleted)
+}
+
+// getRegistryCacheRetention returns the registry cache retention policy of the CDIConfig, or nil if cache entries are
+// kept until they are deleted by hand
+func getRegistryCacheRetention(c client.Client) (*cdiv1.RegistryCacheRetention, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		return nil, err
+	}
+	return cdiconfig.Spec.RegistryCacheRetention, nil
+}
+
+// RegistryCacheCollector periodically deletes the registry cache entries the retention policy of the CDIConfig does
+// not keep. The versions of an image are the cache entries of a namespace for the same repository with different
+// digests.
+type RegistryCacheCollector struct {
+	Client   client.Client
+	Log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// NewRegistryCacheCollector creates a new registry cache collector and adds it to the manager.
+func NewRegistryCacheCollector(mgr manager.Manager, log logr.Logger) (*RegistryCacheCollector, error) {
+	collector := &RegistryCacheCollector{
+		Client:   mgr.GetClient(),
+		Log:      log.WithName("registry-cache-collector"),
+		recorder: mgr.GetEventRecorderFor("registry-cache-collector"),
+	}
+	if err := mgr.Add(collector); err != nil {
+		return nil, err
+	}
+	return collector, nil
+}
+
+// Start runs the collector, right away and then every registryCacheCollectorInterval, until stop is closed.
+func (r *RegistryCacheCollector) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := r.collect(time.Now()); err != nil {
+			r.Log.Error(err, "Unable to enforce the registry cache retention policy")
+		}
+	}, registryCacheCollectorInterval, stop)
+	return nil
+}
+
+// collect deletes, or with a dry run reports, the cache entries the retention policy does not keep at now
+func (r *RegistryCacheCollector) collect(now time.Time) error {
+	retention, err := getRegistryCacheRetention(r.Client)
+	if err != nil {
+		return err
+	}
+	if retention == nil {
+		registryCacheExpired.Set(0)
+		return nil
+	}
+	dvs := &cdiv1.DataVolumeList{}
+	if err := r.Client.List(context.TODO(), dvs); err != nil {
+		return err
+	}
+
+	// The cache entries of each image in a namespace, and the digests the other DataVolumes import
+	versions := make(map[string][]*cdiv1.DataVolume)
+	referenced := make(map[string]bool)
+	for i := range dvs.Items {
+		dv := &dvs.Items[i]
+		if dv.DeletionTimestamp != nil || dv.Spec.Source.Registry == nil {
+			continue
+		}
+		url := dv.Spec.Source.Registry.URL
+		loc := registryDigestRegExp.FindStringSubmatchIndex(url)
+		if loc == nil {
+			continue
+		}
+		digest := url[loc[2]:loc[3]]
+		if dv.Annotations[AnnRegistryCache] != "true" {
+			referenced[dv.Namespace+"/"+digest] = true
+			continue
+		}
+		if dv.Status.Phase == cdiv1.Succeeded {
+			image := dv.Namespace + "/" + url[:loc[0]]
+			versions[image] = append(versions[image], dv)
+		}
+	}
+
+	expired := 0
+	for _, entries := range versions {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[j].CreationTimestamp.Before(&entries[i].CreationTimestamp)
+		})
+		for i, dv := range entries {
+			digest, _ := registryDigest(dv.Spec.Source.Registry.URL)
+			if isRegistryCacheKept(retention, i, now.Sub(dv.CreationTimestamp.Time), referenced[dv.Namespace+"/"+digest]) {
+				continue
+			}
+			expired++
+			if retention.DryRun {
+				r.recorder.Event(dv, corev1.EventTypeNormal, RegistryCacheExpired,
+					fmt.Sprintf("Registry cache entry for %s would be deleted by the retention policy", digest))
+				continue
+			}
+			r.Log.Info("Deleting registry cache entry", "namespace", dv.Namespace, "name", dv.Name, "digest", digest)
+			if err := r.Client.Delete(context.TODO(), dv); IgnoreNotFound(err) != nil {
+				return err
+			}
+			registryCacheDeleted.Inc()
+		}
+	}
+	registryCacheExpired.Set(float64(expired))
+	return nil
+}
+
+// isRegistryCacheKept returns true if a rule of the retention policy keeps a cache entry, index is its position among
+// the versions of its image, newest first
+func isRegistryCacheKept(retention *cdiv1.RegistryCacheRetention, index int, age time.Duration, referenced bool) bool {
+	return (retention.KeepLast != nil && index < int(*retention.KeepLast)) ||
+		(retention.KeepFor != nil && age < retention.KeepFor.Duration) ||
+		(retention.KeepReferenced && referenced)
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var registryCacheRetentionLog = logf.Log.WithName("registry-cache-retention-test")
+
+var _ = Describe("Registry cache retention", func() {
+	now := time.Now()
+	keepLast := int32(1)
+
+	// createVersion returns a succeeded cache entry for the digest of the test image, created age before now
+	createVersion := func(name, digestChar string, age time.Duration) *cdiv1.DataVolume {
+		dv := newRegistryDigestDataVolume(name)
+		dv.Spec.Source.Registry.URL = strings.TrimSuffix(testRegistryDigestURL, testRegistryDigest) + "sha256:" + strings.Repeat(digestChar, 64)
+		dv.Annotations = map[string]string{AnnRegistryCache: "true"}
+		dv.Status.Phase = cdiv1.Succeeded
+		dv.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		return dv
+	}
+
+	dataVolumeExists := func(c *RegistryCacheCollector, name string) bool {
+		err := c.Client.Get(context.TODO(), types.NamespacedName{Namespace: metav1.NamespaceDefault, Name: name}, &cdiv1.DataVolume{})
+		if err != nil {
+			Expect(IgnoreNotFound(err)).ToNot(HaveOccurred())
+			return false
+		}
+		return true
+	}
+
+	It("Should keep the newest versions", func() {
+		c := createRegistryCacheCollector(&cdiv1.RegistryCacheRetention{KeepLast: &keepLast},
+			createVersion("old", "a", 2*time.Hour), createVersion("new", "b", time.Hour))
+		deleted := readCounter(registryCacheDeleted)
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "new")).To(BeTrue())
+		Expect(dataVolumeExists(c, "old")).To(BeFalse())
+		Expect(readCounter(registryCacheDeleted)).To(Equal(deleted + 1))
+		Expect(readGauge(registryCacheExpired)).To(Equal(float64(1)))
+	})
+
+	It("Should count the versions of each image separately", func() {
+		other := createVersion("other", "a", 2*time.Hour)
+		other.Spec.Source.Registry.URL = "docker://quay.io/other@sha256:" + strings.Repeat("a", 64)
+		c := createRegistryCacheCollector(&cdiv1.RegistryCacheRetention{KeepLast: &keepLast},
+			other, createVersion("new", "b", time.Hour))
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "new")).To(BeTrue())
+		Expect(dataVolumeExists(c, "other")).To(BeTrue())
+	})
+
+	It("Should keep the versions younger than the duration", func() {
+		c := createRegistryCacheCollector(&cdiv1.RegistryCacheRetention{KeepFor: &metav1.Duration{Duration: 3 * time.Hour}},
+			createVersion("old", "a", 4*time.Hour), createVersion("new", "b", 2*time.Hour))
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "new")).To(BeTrue())
+		Expect(dataVolumeExists(c, "old")).To(BeFalse())
+	})
+
+	It("Should keep the versions other DataVolumes import", func() {
+		consumer := newRegistryDigestDataVolume("consumer")
+		consumer.Spec.Source.Registry.URL = strings.TrimSuffix(testRegistryDigestURL, testRegistryDigest) + "sha256:" + strings.Repeat("a", 64)
+		c := createRegistryCacheCollector(&cdiv1.RegistryCacheRetention{KeepLast: &keepLast, KeepReferenced: true},
+			createVersion("old", "a", 2*time.Hour), createVersion("older", "c", 3*time.Hour), createVersion("new", "b", time.Hour), consumer)
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "new")).To(BeTrue())
+		Expect(dataVolumeExists(c, "old")).To(BeTrue())
+		Expect(dataVolumeExists(c, "older")).To(BeFalse())
+	})
+
+	It("Should keep unfinished cache imports", func() {
+		importing := createVersion("importing", "a", 2*time.Hour)
+		importing.Status.Phase = cdiv1.ImportInProgress
+		c := createRegistryCacheCollector(&cdiv1.RegistryCacheRetention{KeepLast: &keepLast},
+			importing, createVersion("new", "b", time.Hour))
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "importing")).To(BeTrue())
+	})
+
+	It("Should only report the versions it would delete in a dry run", func() {
+		c := createRegistryCacheCollector(&cdiv1.RegistryCacheRetention{KeepLast: &keepLast, DryRun: true},
+			createVersion("old", "a", 2*time.Hour), createVersion("new", "b", time.Hour))
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "old")).To(BeTrue())
+		Expect(readGauge(registryCacheExpired)).To(Equal(float64(1)))
+		event := <-c.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(RegistryCacheExpired))
+		Expect(event).To(ContainSubstring("sha256:" + strings.Repeat("a", 64)))
+	})
+
+	It("Should keep all versions without a retention policy", func() {
+		c := createRegistryCacheCollector(nil, createVersion("old", "a", 2*time.Hour), createVersion("new", "b", time.Hour))
+		Expect(c.collect(now)).To(Succeed())
+		Expect(dataVolumeExists(c, "old")).To(BeTrue())
+		Expect(readGauge(registryCacheExpired)).To(BeZero())
+	})
+})
+
+func createRegistryCacheCollector(retention *cdiv1.RegistryCacheRetention, objects ...runtime.Object) *RegistryCacheCollector {
+	config := createCDIConfigWithStorageClass(common.ConfigName, "")
+	config.Spec.RegistryCacheRetention = retention
+	return &RegistryCacheCollector{
+		Client:   createRegistryCacheClient(append(objects, config)...),
+		Log:      registryCacheRetentionLog,
+		recorder: record.NewFakeRecorder(10),
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// PVCRetained provides a const to indicate the PVC of a deleted DataVolume was kept as a volume of its own
+	PVCRetained = "PVCRetained"
+
+	// retainPVCFinalizer keeps a DataVolume with the Retain PVC deletion policy until its PVC is released
+	retainPVCFinalizer = "cdi.kubevirt.io/retainPVC"
+)
+
+// hasRetainPVCFinalizer returns true if the finalizer that releases the PVC is set on the DataVolume
+func hasRetainPVCFinalizer(dv *cdiv1.DataVolume) bool {
+	for _, f := range dv.Finalizers {
+		if f == retainPVCFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutRetainPVCFinalizer returns finalizers without the finalizer that releases the PVC
+func withoutRetainPVCFinalizer(finalizers []string) []string {
+	var kept []string
+	for _, f := range finalizers {
+		if f != retainPVCFinalizer {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// reconcileRetainPVCFinalizer adds the finalizer that releases the PVC to a DataVolume with the Retain PVC deletion
+// policy, and removes it when the policy changes
+func (r *DatavolumeReconciler) reconcileRetainPVCFinalizer(dv *cdiv1.DataVolume) error {
+	retain := dv.Spec.PVCDeletionPolicy == cdiv1.DataVolumePVCDeletionPolicyRetain
+	if retain == hasRetainPVCFinalizer(dv) {
+		return nil
+	}
+	if retain {
+		dv.Finalizers = append(dv.Finalizers, retainPVCFinalizer)
+	} else {
+		dv.Finalizers = withoutRetainPVCFinalizer(dv.Finalizers)
+	}
+	return r.Client.Update(context.TODO(), dv)
+}
+
+// retainPVC releases the PVC of a deleted DataVolume with the Retain PVC deletion policy, so the garbage collector
+// does not delete it, and then lets the DataVolume go. The PVC of a DataVolume that did not succeed is partially
+// populated at best, it is deleted as usual.
+func (r *DatavolumeReconciler) retainPVC(dv *cdiv1.DataVolume) error {
+	if !hasRetainPVCFinalizer(dv) {
+		return nil
+	}
+	if dv.Spec.PVCDeletionPolicy == cdiv1.DataVolumePVCDeletionPolicyRetain && dv.Status.Phase == cdiv1.Succeeded {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: dv.Namespace, Name: dv.Name}, pvc)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(pvc, dv) {
+			releasePVC(pvc, dv)
+			if err := r.Client.Update(context.TODO(), pvc); err != nil {
+				return err
+			}
+			r.recorder.Event(dv, corev1.EventTypeNormal, PVCRetained, "PVC "+pvc.Name+" kept as a volume of its own")
+		}
+	}
+	dv.Finalizers = withoutRetainPVCFinalizer(dv.Finalizers)
+	return r.Client.Update(context.TODO(), dv)
+}
+
+// releasePVC removes the owner reference to the DataVolume and the CDI annotations and labels from pvc, which
+// leaves a PVC that is not managed by CDI anymore
+func releasePVC(pvc *corev1.PersistentVolumeClaim, dv *cdiv1.DataVolume) {
+	var refs []metav1.OwnerReference
+	for _, ref := range pvc.OwnerReferences {
+		if ref.UID != dv.UID {
+			refs = append(refs, ref)
+		}
+	}
+	pvc.OwnerReferences = refs
+	for key := range pvc.Annotations {
+		if isCDIKey(key) {
+			delete(pvc.Annotations, key)
+		}
+	}
+	for key, value := range pvc.Labels {
+		if isCDIKey(key) || key == "cdi-controller" || (key == common.CDILabelKey && value == common.CDILabelValue) {
+			delete(pvc.Labels, key)
+		}
+	}
+}
+
+// isCDIKey returns true if an annotation or label key belongs to CDI
+func isCDIKey(key string) bool {
+	return key == AnnAPIGroup || strings.HasPrefix(key, AnnAPIGroup+"/")
+}
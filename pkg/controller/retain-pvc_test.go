@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Retain PVC deletion policy", func() {
+	var (
+		reconciler *DatavolumeReconciler
+		request    = reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}}
+	)
+
+	getDataVolume := func() *cdiv1.DataVolume {
+		dv := &cdiv1.DataVolume{}
+		Expect(reconciler.Client.Get(context.TODO(), request.NamespacedName, dv)).To(Succeed())
+		return dv
+	}
+
+	getPvc := func() *corev1.PersistentVolumeClaim {
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), request.NamespacedName, pvc)).To(Succeed())
+		return pvc
+	}
+
+	// deleteDataVolume marks the DataVolume deleted in phase and reconciles it
+	deleteDataVolume := func(phase cdiv1.DataVolumePhase) {
+		dv := getDataVolume()
+		now := metav1.Now()
+		dv.DeletionTimestamp = &now
+		dv.Status.Phase = phase
+		Expect(reconciler.Client.Update(context.TODO(), dv)).To(Succeed())
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.PVCDeletionPolicy = cdiv1.DataVolumePVCDeletionPolicyRetain
+		reconciler = createDatavolumeReconciler(dv)
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		pvc := getPvc()
+		pvc.Annotations[AnnPodPhase] = string(corev1.PodSucceeded)
+		pvc.Annotations["example.com/keep"] = "true"
+		Expect(reconciler.Client.Update(context.TODO(), pvc)).To(Succeed())
+	})
+
+	It("Should add the finalizer to a DataVolume with the Retain policy", func() {
+		Expect(getDataVolume().Finalizers).To(ContainElement(retainPVCFinalizer))
+	})
+
+	It("Should remove the finalizer when the policy changes", func() {
+		dv := getDataVolume()
+		dv.Spec.PVCDeletionPolicy = cdiv1.DataVolumePVCDeletionPolicyDelete
+		Expect(reconciler.Client.Update(context.TODO(), dv)).To(Succeed())
+		_, err := reconciler.Reconcile(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(getDataVolume().Finalizers).ToNot(ContainElement(retainPVCFinalizer))
+	})
+
+	It("Should release the PVC of a deleted succeeded DataVolume", func() {
+		deleteDataVolume(cdiv1.Succeeded)
+		pvc := getPvc()
+		Expect(pvc.OwnerReferences).To(BeEmpty())
+		Expect(pvc.Annotations).To(Equal(map[string]string{"example.com/keep": "true"}))
+		Expect(pvc.Labels).To(BeEmpty())
+		Expect(getDataVolume().Finalizers).ToNot(ContainElement(retainPVCFinalizer))
+		Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(PVCRetained))
+	})
+
+	It("Should leave the PVC of a deleted failed DataVolume to the garbage collector", func() {
+		deleteDataVolume(cdiv1.Failed)
+		Expect(metav1.GetControllerOf(getPvc())).ToNot(BeNil())
+		Expect(getDataVolume().Finalizers).ToNot(ContainElement(retainPVCFinalizer))
+	})
+})
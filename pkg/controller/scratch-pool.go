@@ -0,0 +1,573 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// scratchPoolLabel is the label of the volumes of the scratch space pool, and of the PVCs provisioning them,
+	// with their size class
+	scratchPoolLabel = "cdi.kubevirt.io/scratchPool"
+
+	// scratchScrubLabel is the label of the PVCs scrubbing the volumes returned to the scratch space pool, with the
+	// name of the volume
+	scratchScrubLabel = "cdi.kubevirt.io/scratchPoolScrub"
+
+	// scratchPoolInterval is how often the scratch space pool is refilled and trimmed
+	scratchPoolInterval = 30 * time.Second
+)
+
+var (
+	scratchPoolVolumes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cdi_scratch_pool_volumes",
+			Help: "The number of volumes ready in the scratch space pool",
+		},
+		[]string{"size_class"},
+	)
+	scratchPoolCheckouts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_scratch_pool_checkouts_total",
+			Help: "The number of scratch spaces that got a volume from the scratch space pool",
+		},
+	)
+	scratchPoolMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_scratch_pool_misses_total",
+			Help: "The number of scratch spaces of a size class that had to be provisioned because the pool had no volume ready",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(scratchPoolVolumes, scratchPoolCheckouts, scratchPoolMisses)
+}
+
+// getScratchSpacePool returns the scratch space pool of the CDIConfig, or nil if scratch space volumes are not pooled
+func getScratchSpacePool(c client.Client) (*cdiv1.ScratchSpacePool, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		return nil, err
+	}
+	return cdiconfig.Spec.ScratchSpacePool, nil
+}
+
+// scratchPoolSizeClass returns the smallest size class of pool that fits size
+func scratchPoolSizeClass(pool *cdiv1.ScratchSpacePool, size resource.Quantity) (string, resource.Quantity, bool) {
+	var sizeClass *resource.Quantity
+	for i := range pool.SizeClasses {
+		if pool.SizeClasses[i].Cmp(size) >= 0 && (sizeClass == nil || pool.SizeClasses[i].Cmp(*sizeClass) < 0) {
+			sizeClass = &pool.SizeClasses[i]
+		}
+	}
+	if sizeClass == nil {
+		return "", resource.Quantity{}, false
+	}
+	return sizeClass.String(), sizeClass.DeepCopy(), true
+}
+
+// isScratchPoolVolumeReady returns true if pv is a volume of the scratch space pool of storageClassName that can be
+// checked out. Volumes return to the pool when their scratch space PVC is deleted, and stay released, bound to the
+// deleted PVC, until they are checked out. They are only ready once they were scrubbed after their last use.
+func isScratchPoolVolumeReady(pv *v1.PersistentVolume, storageClassName string) bool {
+	return isScratchPoolVolumeReturned(pv) && pv.Spec.StorageClassName == storageClassName &&
+		pv.Annotations[AnnScratchPoolScrubbed] == string(pv.Spec.ClaimRef.UID)
+}
+
+// isScratchPoolVolumeReturned returns true if pv is a volume of the scratch space pool whose last PVC was deleted
+func isScratchPoolVolumeReturned(pv *v1.PersistentVolume) bool {
+	return pv.DeletionTimestamp == nil && pv.Status.Phase == v1.VolumeReleased &&
+		pv.Spec.PersistentVolumeReclaimPolicy == v1.PersistentVolumeReclaimRetain &&
+		pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID != ""
+}
+
+// isScratchPoolVolumeScrubbing returns true if pv is claimed by its scrub PVC in namespace
+func isScratchPoolVolumeScrubbing(pv *v1.PersistentVolume, namespace string) bool {
+	return pv.DeletionTimestamp == nil && pv.Status.Phase != v1.VolumeReleased && pv.Spec.ClaimRef != nil &&
+		pv.Spec.ClaimRef.Namespace == namespace && pv.Spec.ClaimRef.Name == scratchScrubName(pv.Name)
+}
+
+// scratchScrubName returns the name of the PVC and the pod scrubbing the volume named pvName
+func scratchScrubName(pvName string) string {
+	return common.ScratchScrubPodName + "-" + pvName
+}
+
+// checkOutScratchVolume sizes scratchPvc to the smallest size class of pool that fits it, and binds it to a volume of
+// that size class from the pool if one is ready. Scratch spaces without a storage class, or larger than all size
+// classes, are not pooled.
+func checkOutScratchVolume(k8sClient kubernetes.Interface, pool *cdiv1.ScratchSpacePool, scratchPvc *v1.PersistentVolumeClaim) error {
+	if pool == nil || scratchPvc.Spec.StorageClassName == nil || *scratchPvc.Spec.StorageClassName == "" {
+		return nil
+	}
+	name, size, ok := scratchPoolSizeClass(pool, scratchPvc.Spec.Resources.Requests[v1.ResourceStorage])
+	if !ok {
+		return nil
+	}
+	scratchPvc.Spec.Resources.Requests[v1.ResourceStorage] = size
+	if scratchPvc.GetAnnotations() == nil {
+		scratchPvc.SetAnnotations(make(map[string]string))
+	}
+	scratchPvc.GetAnnotations()[AnnScratchPoolSizeClass] = name
+
+	selector := labels.SelectorFromSet(map[string]string{scratchPoolLabel: name})
+	volumes, err := k8sClient.CoreV1().PersistentVolumes().List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return err
+	}
+	for i := range volumes.Items {
+		pv := &volumes.Items[i]
+		if !isScratchPoolVolumeReady(pv, *scratchPvc.Spec.StorageClassName) {
+			continue
+		}
+		// Binding the volume to the scratch space PVC by name keeps other PVCs from claiming it
+		pv.Spec.ClaimRef = &v1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+			Namespace:  scratchPvc.Namespace,
+			Name:       scratchPvc.Name,
+		}
+		if _, err := k8sClient.CoreV1().PersistentVolumes().Update(pv); err != nil {
+			if k8serrors.IsConflict(err) || k8serrors.IsNotFound(err) {
+				// Checked out by another scratch space
+				continue
+			}
+			return err
+		}
+		scratchPvc.Spec.VolumeName = pv.Name
+		scratchPoolCheckouts.Inc()
+		return nil
+	}
+	scratchPoolMisses.Inc()
+	return nil
+}
+
+// ScratchPool keeps the volumes of scratch space PVCs of a size class of the ScratchSpacePool of the CDIConfig when
+// the PVCs are deleted, so that later scratch spaces of the size class are bound to them rather than waiting for a
+// volume to be provisioned. It provisions volumes until VolumesPerSizeClass are ready for each size class, and
+// deletes the volumes beyond that. A volume that returns to the pool is scrubbed by a pod in the CDI namespace
+// before it is ready, so the data of one transfer is never handed to the next.
+type ScratchPool struct {
+	Client     client.Client
+	Log        logr.Logger
+	namespace  string
+	image      string
+	pullPolicy string
+}
+
+// NewScratchPool creates a new scratch space pool, provisioning and scrubbing volumes in namespace with pods running
+// image, and adds it to the manager.
+func NewScratchPool(mgr manager.Manager, namespace, image, pullPolicy string, log logr.Logger) (*ScratchPool, error) {
+	pool := &ScratchPool{
+		Client:     mgr.GetClient(),
+		Log:        log.WithName("scratch-pool"),
+		namespace:  namespace,
+		image:      image,
+		pullPolicy: pullPolicy,
+	}
+	if err := mgr.Add(pool); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Start runs the pool, right away and then every scratchPoolInterval, until stop is closed.
+func (p *ScratchPool) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := p.reconcile(); err != nil {
+			p.Log.Error(err, "Unable to reconcile the scratch space pool")
+		}
+	}, scratchPoolInterval, stop)
+	return nil
+}
+
+// reconcile keeps the volumes of pooled scratch spaces, and refills and trims the pool
+func (p *ScratchPool) reconcile() error {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := p.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		return err
+	}
+	if err := p.retainVolumes(); err != nil {
+		return err
+	}
+
+	poolSelector, err := labels.Parse(scratchPoolLabel)
+	if err != nil {
+		return err
+	}
+	volumes := &v1.PersistentVolumeList{}
+	if err := p.Client.List(context.TODO(), volumes, &client.ListOptions{LabelSelector: poolSelector}); err != nil {
+		return err
+	}
+	provisioning := &v1.PersistentVolumeClaimList{}
+	if err := p.Client.List(context.TODO(), provisioning, &client.ListOptions{Namespace: p.namespace, LabelSelector: poolSelector}); err != nil {
+		return err
+	}
+
+	wanted := map[string]resource.Quantity{}
+	var perSizeClass int
+	storageClassName := cdiconfig.Status.ScratchSpaceStorageClass
+	if pool := cdiconfig.Spec.ScratchSpacePool; pool != nil {
+		perSizeClass = int(pool.VolumesPerSizeClass)
+		for _, size := range pool.SizeClasses {
+			wanted[size.String()] = size
+		}
+	}
+
+	ready := map[string][]*v1.PersistentVolume{}
+	pending := map[string]int{}
+	for i := range volumes.Items {
+		pv := &volumes.Items[i]
+		sizeClass := pv.Labels[scratchPoolLabel]
+		switch {
+		case isScratchPoolVolumeReady(pv, pv.Spec.StorageClassName):
+			ready[sizeClass] = append(ready[sizeClass], pv)
+		case isScratchPoolVolumeScrubbing(pv, p.namespace):
+			if err := p.finishScrub(pv); err != nil {
+				return err
+			}
+			pending[sizeClass]++
+		case isScratchPoolVolumeReturned(pv):
+			if _, isWanted := wanted[sizeClass]; !isWanted || pv.Spec.StorageClassName != storageClassName {
+				// Not worth scrubbing, it would be deleted once it is ready
+				if err := p.deleteVolume(pv); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := p.startScrub(pv); err != nil {
+				return err
+			}
+			pending[sizeClass]++
+		}
+	}
+	for _, pvc := range provisioning.Items {
+		if pvc.DeletionTimestamp == nil {
+			pending[pvc.Labels[scratchPoolLabel]]++
+		}
+	}
+
+	scratchPoolVolumes.Reset()
+	for sizeClass, pvs := range ready {
+		_, isWanted := wanted[sizeClass]
+		// Volumes of another storage class than the scratch space uses now are not checked out anymore
+		var usable, surplus []*v1.PersistentVolume
+		for _, pv := range pvs {
+			if isWanted && pv.Spec.StorageClassName == storageClassName {
+				usable = append(usable, pv)
+			} else {
+				surplus = append(surplus, pv)
+			}
+		}
+		if len(usable) > perSizeClass {
+			// Delete the same volumes on every run
+			sort.Slice(usable, func(i, j int) bool { return usable[i].Name < usable[j].Name })
+			surplus = append(surplus, usable[perSizeClass:]...)
+			usable = usable[:perSizeClass]
+		}
+		for _, pv := range surplus {
+			if err := p.deleteVolume(pv); err != nil {
+				return err
+			}
+		}
+		scratchPoolVolumes.WithLabelValues(sizeClass).Set(float64(len(usable)))
+		ready[sizeClass] = usable
+	}
+	for sizeClass := range wanted {
+		if _, ok := ready[sizeClass]; !ok {
+			scratchPoolVolumes.WithLabelValues(sizeClass).Set(0)
+		}
+	}
+
+	if storageClassName == "" {
+		// The pool is only refilled for a known storage class, a scratch space without one is not pooled
+		return nil
+	}
+	for sizeClass, size := range wanted {
+		for n := len(ready[sizeClass]) + pending[sizeClass]; n < perSizeClass; n++ {
+			if err := p.provisionVolume(sizeClass, size, storageClassName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// retainVolumes keeps the volumes of bound pooled scratch spaces, and of provisioning PVCs, from being deleted with
+// their PVC, and labels them with their size class so they join the pool once their PVC is gone. Provisioning PVCs
+// are deleted once their volume is kept.
+func (p *ScratchPool) retainVolumes() error {
+	scratchSelector, err := labels.Parse(scratchPvcLabel)
+	if err != nil {
+		return err
+	}
+	poolSelector, err := labels.Parse(scratchPoolLabel)
+	if err != nil {
+		return err
+	}
+	pvcs := &v1.PersistentVolumeClaimList{}
+	if err := p.Client.List(context.TODO(), pvcs, &client.ListOptions{LabelSelector: scratchSelector}); err != nil {
+		return err
+	}
+	provisioning := &v1.PersistentVolumeClaimList{}
+	if err := p.Client.List(context.TODO(), provisioning, &client.ListOptions{Namespace: p.namespace, LabelSelector: poolSelector}); err != nil {
+		return err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		sizeClass, ok := pvc.GetAnnotations()[AnnScratchPoolSizeClass]
+		if !ok || pvc.Status.Phase != v1.ClaimBound {
+			continue
+		}
+		if err := p.retainVolume(pvc.Spec.VolumeName, sizeClass, ""); err != nil {
+			return err
+		}
+	}
+	for i := range provisioning.Items {
+		pvc := &provisioning.Items[i]
+		if pvc.Status.Phase != v1.ClaimBound || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		// A new volume holds no data, it is ready without scrubbing it
+		if err := p.retainVolume(pvc.Spec.VolumeName, pvc.Labels[scratchPoolLabel], pvc.UID); err != nil {
+			return err
+		}
+		if err := p.Client.Delete(context.TODO(), pvc); IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retainVolume sets the reclaim policy of the volume named name to retain, and labels it with sizeClass. The volume
+// is marked as scrubbed for the claim scrubbedFor, unless it is empty.
+func (p *ScratchPool) retainVolume(name, sizeClass string, scrubbedFor types.UID) error {
+	pv := &v1.PersistentVolume{}
+	if err := p.Client.Get(context.TODO(), types.NamespacedName{Name: name}, pv); err != nil {
+		return IgnoreNotFound(err)
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy == v1.PersistentVolumeReclaimRetain && pv.Labels[scratchPoolLabel] == sizeClass &&
+		(scrubbedFor == "" || pv.Annotations[AnnScratchPoolScrubbed] == string(scrubbedFor)) {
+		return nil
+	}
+	if pv.Labels == nil {
+		pv.Labels = make(map[string]string)
+	}
+	pv.Labels[scratchPoolLabel] = sizeClass
+	if scrubbedFor != "" {
+		if pv.Annotations == nil {
+			pv.Annotations = make(map[string]string)
+		}
+		pv.Annotations[AnnScratchPoolScrubbed] = string(scrubbedFor)
+	}
+	pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimRetain
+	p.Log.V(1).Info("Keeping scratch space volume for the pool", "volume", pv.Name, "sizeClass", sizeClass)
+	return p.Client.Update(context.TODO(), pv)
+}
+
+// deleteVolume has the volume pv deleted, as it would have been with its scratch space PVC without the pool
+func (p *ScratchPool) deleteVolume(pv *v1.PersistentVolume) error {
+	p.Log.V(1).Info("Deleting volume from the scratch space pool", "volume", pv.Name)
+	delete(pv.Labels, scratchPoolLabel)
+	pv.Spec.PersistentVolumeReclaimPolicy = v1.PersistentVolumeReclaimDelete
+	return IgnoreNotFound(p.Client.Update(context.TODO(), pv))
+}
+
+// provisionVolume creates a PVC of sizeClass in storageClassName, its volume joins the pool once it is bound
+func (p *ScratchPool) provisionVolume(sizeClass string, size resource.Quantity, storageClassName string) error {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "cdi-scratch-pool-",
+			Namespace:    p.namespace,
+			Labels: map[string]string{
+				common.CDILabelKey: common.CDILabelValue,
+				scratchPoolLabel:   sizeClass,
+			},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: size,
+				},
+			},
+			StorageClassName: &storageClassName,
+		},
+	}
+	p.Log.V(1).Info("Provisioning volume for the scratch space pool", "sizeClass", sizeClass)
+	return p.Client.Create(context.TODO(), pvc)
+}
+
+// startScrub claims the volume pv, that returned to the pool, for a scrub PVC in the CDI namespace
+func (p *ScratchPool) startScrub(pv *v1.PersistentVolume) error {
+	name := scratchScrubName(pv.Name)
+	p.Log.V(1).Info("Scrubbing volume returned to the scratch space pool", "volume", pv.Name)
+	pv.Spec.ClaimRef = &v1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  p.namespace,
+		Name:       name,
+	}
+	if err := p.Client.Update(context.TODO(), pv); err != nil {
+		return IgnoreNotFound(err)
+	}
+	storageClassName := pv.Spec.StorageClassName
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.namespace,
+			Labels: map[string]string{
+				common.CDILabelKey: common.CDILabelValue,
+				scratchScrubLabel:  pv.Name,
+			},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: pv.Spec.AccessModes,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: pv.Spec.Capacity[v1.ResourceStorage],
+				},
+			},
+			StorageClassName: &storageClassName,
+			VolumeMode:       pv.Spec.VolumeMode,
+			VolumeName:       pv.Name,
+		},
+	}
+	if err := p.Client.Create(context.TODO(), pvc); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// finishScrub runs the pod scrubbing the volume pv once it is bound to its scrub PVC, and marks the volume as scrubbed
+// for the scrub PVC once the pod succeeded. The volume is ready when the scrub PVC is deleted after that.
+func (p *ScratchPool) finishScrub(pv *v1.PersistentVolume) error {
+	if pv.Status.Phase != v1.VolumeBound {
+		return nil
+	}
+	name := scratchScrubName(pv.Name)
+	pod := &v1.Pod{}
+	err := p.Client.Get(context.TODO(), types.NamespacedName{Namespace: p.namespace, Name: name}, pod)
+	if k8serrors.IsNotFound(err) {
+		if pv.Annotations[AnnScratchPoolScrubbed] == string(pv.Spec.ClaimRef.UID) {
+			return p.deleteScrubClaim(name)
+		}
+		return p.createScrubPod(name)
+	}
+	if err != nil {
+		return err
+	}
+	switch pod.Status.Phase {
+	case v1.PodSucceeded:
+		if pv.Annotations == nil {
+			pv.Annotations = make(map[string]string)
+		}
+		pv.Annotations[AnnScratchPoolScrubbed] = string(pv.Spec.ClaimRef.UID)
+		if err := p.Client.Update(context.TODO(), pv); err != nil {
+			return IgnoreNotFound(err)
+		}
+		if err := p.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return err
+		}
+		return p.deleteScrubClaim(name)
+	case v1.PodFailed:
+		// Scrubbed by a new pod on the next run
+		return IgnoreNotFound(p.Client.Delete(context.TODO(), pod))
+	}
+	return nil
+}
+
+// createScrubPod creates the pod scrubbing the scrub PVC name
+func (p *ScratchPool) createScrubPod(name string) error {
+	podResourceRequirements, err := GetDefaultPodResourceRequirements(p.Client)
+	if err != nil {
+		return err
+	}
+	pod := makeScratchScrubPodSpec(p.image, p.pullPolicy, p.namespace, name, podResourceRequirements)
+	if err := setSecurityProfiles(p.Client, pod, importerSeccompProfile); err != nil {
+		return err
+	}
+	_, err = createPodIfNotExists(p.Client, pod)
+	return err
+}
+
+// deleteScrubClaim deletes the scrub PVC name, its volume is released back to the pool
+func (p *ScratchPool) deleteScrubClaim(name string) error {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.namespace,
+		},
+	}
+	return IgnoreNotFound(p.Client.Delete(context.TODO(), pvc))
+}
+
+// makeScratchScrubPodSpec creates the spec of the pod running the importer image to scrub the scratch space volume of
+// the PVC name.
+func makeScratchScrubPodSpec(image, pullPolicy, namespace, name string, podResourceRequirements *v1.ResourceRequirements) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				common.CDILabelKey:       common.CDILabelValue,
+				common.CDIComponentLabel: common.ScratchScrubPodName,
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:            common.ScratchScrubPodName,
+					Image:           image,
+					ImagePullPolicy: v1.PullPolicy(pullPolicy),
+					Env: []v1.EnvVar{
+						{
+							Name:  common.ImporterScrubScratch,
+							Value: "true",
+						},
+					},
+					VolumeMounts: []v1.VolumeMount{
+						{
+							Name:      ScratchVolName,
+							MountPath: common.ScratchDataDir,
+						},
+					},
+				},
+			},
+			RestartPolicy: v1.RestartPolicyOnFailure,
+			Volumes: []v1.Volume{
+				{
+					Name: ScratchVolName,
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: name,
+						},
+					},
+				},
+			},
+		},
+	}
+	if podResourceRequirements != nil {
+		pod.Spec.Containers[0].Resources = *podResourceRequirements
+	}
+	setReadOnlyRootFilesystem(pod)
+	return pod
+}
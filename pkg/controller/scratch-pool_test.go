@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var scratchPoolLog = logf.Log.WithName("scratch-pool-test")
+
+var _ = Describe("Scratch space pool", func() {
+	pool := &cdiv1.ScratchSpacePool{
+		SizeClasses:         []resource.Quantity{resource.MustParse("10Gi"), resource.MustParse("1Gi")},
+		VolumesPerSizeClass: 1,
+	}
+
+	It("Should pick the smallest size class that fits", func() {
+		name, size, ok := scratchPoolSizeClass(pool, resource.MustParse("1G"))
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("1Gi"))
+		Expect(size.Cmp(resource.MustParse("1Gi"))).To(BeZero())
+		name, _, ok = scratchPoolSizeClass(pool, resource.MustParse("2Gi"))
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("10Gi"))
+		_, _, ok = scratchPoolSizeClass(pool, resource.MustParse("11Gi"))
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("checking out", func() {
+		var scratchPvc *corev1.PersistentVolumeClaim
+
+		BeforeEach(func() {
+			pvc := createPvc("testPvc1", "default", nil, nil)
+			pod := createImporterTestPod(pvc, "testPvc1", nil)
+			scratchPvc = newScratchPersistentVolumeClaimSpec(pvc, pod, scratchNameFromPvc(pvc), "scratch", nil)
+		})
+
+		It("Should bind the scratch space to a ready volume of its size class", func() {
+			k8sClient := k8sfake.NewSimpleClientset(createScratchPoolVolume("pv1", "1Gi", "scratch"))
+			checkouts := readCounter(scratchPoolCheckouts)
+			Expect(checkOutScratchVolume(k8sClient, pool, scratchPvc)).To(Succeed())
+			Expect(scratchPvc.Spec.VolumeName).To(Equal("pv1"))
+			Expect(scratchPvc.GetAnnotations()[AnnScratchPoolSizeClass]).To(Equal("1Gi"))
+			request := scratchPvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(request.Cmp(resource.MustParse("1Gi"))).To(BeZero())
+			Expect(readCounter(scratchPoolCheckouts)).To(Equal(checkouts + 1))
+			pv, err := k8sClient.CoreV1().PersistentVolumes().Get("pv1", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pv.Spec.ClaimRef.Namespace).To(Equal("default"))
+			Expect(pv.Spec.ClaimRef.Name).To(Equal(scratchPvc.Name))
+			Expect(pv.Spec.ClaimRef.UID).To(BeEmpty())
+		})
+
+		It("Should not bind the scratch space to a volume of another storage class", func() {
+			k8sClient := k8sfake.NewSimpleClientset(createScratchPoolVolume("pv1", "1Gi", "other"))
+			misses := readCounter(scratchPoolMisses)
+			Expect(checkOutScratchVolume(k8sClient, pool, scratchPvc)).To(Succeed())
+			Expect(scratchPvc.Spec.VolumeName).To(BeEmpty())
+			Expect(scratchPvc.GetAnnotations()[AnnScratchPoolSizeClass]).To(Equal("1Gi"))
+			Expect(readCounter(scratchPoolMisses)).To(Equal(misses + 1))
+		})
+
+		It("Should not bind the scratch space to a volume that was checked out", func() {
+			pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+			pv.Spec.ClaimRef.UID = ""
+			k8sClient := k8sfake.NewSimpleClientset(pv)
+			Expect(checkOutScratchVolume(k8sClient, pool, scratchPvc)).To(Succeed())
+			Expect(scratchPvc.Spec.VolumeName).To(BeEmpty())
+		})
+
+		It("Should not bind the scratch space to a volume that was not scrubbed", func() {
+			pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+			pv.Annotations = nil
+			k8sClient := k8sfake.NewSimpleClientset(pv)
+			Expect(checkOutScratchVolume(k8sClient, pool, scratchPvc)).To(Succeed())
+			Expect(scratchPvc.Spec.VolumeName).To(BeEmpty())
+		})
+
+		It("Should leave the scratch space alone without a pool", func() {
+			k8sClient := k8sfake.NewSimpleClientset(createScratchPoolVolume("pv1", "1Gi", "scratch"))
+			Expect(checkOutScratchVolume(k8sClient, nil, scratchPvc)).To(Succeed())
+			Expect(scratchPvc.Spec.VolumeName).To(BeEmpty())
+			Expect(scratchPvc.GetAnnotations()).ToNot(HaveKey(AnnScratchPoolSizeClass))
+		})
+	})
+
+	Context("reconciling", func() {
+		getVolume := func(p *ScratchPool, name string) *corev1.PersistentVolume {
+			pv := &corev1.PersistentVolume{}
+			Expect(p.Client.Get(context.TODO(), types.NamespacedName{Name: name}, pv)).To(Succeed())
+			return pv
+		}
+
+		listProvisioning := func(p *ScratchPool) []corev1.PersistentVolumeClaim {
+			pvcs := &corev1.PersistentVolumeClaimList{}
+			Expect(p.Client.List(context.TODO(), pvcs, &client.ListOptions{Namespace: "cdi"})).To(Succeed())
+			return pvcs.Items
+		}
+
+		It("Should keep the volume of a pooled scratch space", func() {
+			pvc := createPvc("testPvc1", "default", nil, nil)
+			pod := createImporterTestPod(pvc, "testPvc1", nil)
+			scratchPvc := createScratchPvc(pvc, pod, "scratch")
+			scratchPvc.Annotations = map[string]string{AnnScratchPoolSizeClass: "1Gi"}
+			scratchPvc.Spec.VolumeName = "pv1"
+			scratchPvc.Status.Phase = corev1.ClaimBound
+			pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+			pv.Labels = nil
+			pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+			pv.Status.Phase = corev1.VolumeBound
+			p := createScratchPool(pool, "", scratchPvc, pv)
+			Expect(p.reconcile()).To(Succeed())
+			pv = getVolume(p, "pv1")
+			Expect(pv.Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+			Expect(pv.Labels[scratchPoolLabel]).To(Equal("1Gi"))
+		})
+
+		It("Should keep the volume of a bound provisioning PVC and delete the PVC", func() {
+			provisioningPvc := createPvc("cdi-scratch-pool-x", "cdi", nil, map[string]string{scratchPoolLabel: "1Gi"})
+			provisioningPvc.UID = "provisioning-uid"
+			provisioningPvc.Spec.VolumeName = "pv1"
+			provisioningPvc.Status.Phase = corev1.ClaimBound
+			pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+			pv.Labels = nil
+			pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+			pv.Status.Phase = corev1.VolumeBound
+			p := createScratchPool(pool, "", provisioningPvc, pv)
+			Expect(p.reconcile()).To(Succeed())
+			pv = getVolume(p, "pv1")
+			Expect(pv.Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+			// The new volume is empty
+			Expect(pv.Annotations[AnnScratchPoolScrubbed]).To(Equal("provisioning-uid"))
+			Expect(listProvisioning(p)).To(BeEmpty())
+		})
+
+		It("Should provision the volumes missing from the pool", func() {
+			p := createScratchPool(&cdiv1.ScratchSpacePool{SizeClasses: []resource.Quantity{resource.MustParse("1Gi")}, VolumesPerSizeClass: 1}, "scratch")
+			Expect(p.reconcile()).To(Succeed())
+			pvcs := listProvisioning(p)
+			Expect(pvcs).To(HaveLen(1))
+			Expect(pvcs[0].Labels[scratchPoolLabel]).To(Equal("1Gi"))
+			Expect(*pvcs[0].Spec.StorageClassName).To(Equal("scratch"))
+			request := pvcs[0].Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(request.Cmp(resource.MustParse("1Gi"))).To(BeZero())
+		})
+
+		It("Should not provision volumes without a scratch space storage class", func() {
+			p := createScratchPool(pool, "")
+			Expect(p.reconcile()).To(Succeed())
+			Expect(listProvisioning(p)).To(BeEmpty())
+		})
+
+		It("Should delete the volumes beyond the size of the pool", func() {
+			p := createScratchPool(pool, "scratch",
+				createScratchPoolVolume("pv1", "1Gi", "scratch"),
+				createScratchPoolVolume("pv2", "1Gi", "scratch"),
+				createScratchPoolVolume("pv3", "5Gi", "scratch"),
+				createScratchPoolVolume("pv4", "10Gi", "scratch"))
+			Expect(p.reconcile()).To(Succeed())
+			Expect(getVolume(p, "pv1").Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+			Expect(getVolume(p, "pv2").Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimDelete))
+			// 5Gi is no longer a size class
+			Expect(getVolume(p, "pv3").Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimDelete))
+			Expect(getVolume(p, "pv4").Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+			Expect(listProvisioning(p)).To(BeEmpty())
+		})
+
+		Context("scrubbing", func() {
+			singleClassPool := &cdiv1.ScratchSpacePool{SizeClasses: []resource.Quantity{resource.MustParse("1Gi")}, VolumesPerSizeClass: 1}
+			scrubName := scratchScrubName("pv1")
+
+			getPod := func(p *ScratchPool) (*corev1.Pod, error) {
+				pod := &corev1.Pod{}
+				err := p.Client.Get(context.TODO(), types.NamespacedName{Namespace: "cdi", Name: scrubName}, pod)
+				return pod, err
+			}
+
+			scrubbingVolume := func() *corev1.PersistentVolume {
+				pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+				pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "cdi", Name: scrubName, UID: "scrub-uid"}
+				pv.Status.Phase = corev1.VolumeBound
+				return pv
+			}
+
+			It("Should claim a returned volume for scrubbing instead of keeping it ready", func() {
+				pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+				pv.Annotations = nil
+				p := createScratchPool(singleClassPool, "scratch", pv)
+				Expect(p.reconcile()).To(Succeed())
+				pv = getVolume(p, "pv1")
+				Expect(pv.Spec.ClaimRef.Namespace).To(Equal("cdi"))
+				Expect(pv.Spec.ClaimRef.Name).To(Equal(scrubName))
+				Expect(pv.Spec.ClaimRef.UID).To(BeEmpty())
+				pvcs := listProvisioning(p)
+				// Scrubbed volumes count as pending, none is provisioned meanwhile
+				Expect(pvcs).To(HaveLen(1))
+				Expect(pvcs[0].Name).To(Equal(scrubName))
+				Expect(pvcs[0].Spec.VolumeName).To(Equal("pv1"))
+				Expect(pvcs[0].Labels[scratchScrubLabel]).To(Equal("pv1"))
+				Expect(*pvcs[0].Spec.StorageClassName).To(Equal("scratch"))
+			})
+
+			It("Should delete a returned volume the pool does not keep without scrubbing it", func() {
+				pv := createScratchPoolVolume("pv1", "1Gi", "other")
+				pv.Annotations = nil
+				p := createScratchPool(singleClassPool, "scratch", pv)
+				Expect(p.reconcile()).To(Succeed())
+				Expect(getVolume(p, "pv1").Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimDelete))
+			})
+
+			It("Should scrub a volume bound to its scrub PVC with a pod", func() {
+				p := createScratchPool(singleClassPool, "scratch", scrubbingVolume())
+				p.image = "importer"
+				Expect(p.reconcile()).To(Succeed())
+				pod, err := getPod(p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pod.Spec.Containers[0].Image).To(Equal("importer"))
+				Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.ImporterScrubScratch, Value: "true"}))
+				Expect(pod.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: ScratchVolName, MountPath: common.ScratchDataDir}))
+				Expect(pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(scrubName))
+				Expect(*pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+				Expect(getVolume(p, "pv1").Annotations[AnnScratchPoolScrubbed]).ToNot(Equal("scrub-uid"))
+			})
+
+			It("Should mark the volume scrubbed and release it once the pod succeeded", func() {
+				scrubPvc := createPvc(scrubName, "cdi", nil, nil)
+				pod := makeScratchScrubPodSpec("importer", "", "cdi", scrubName, nil)
+				pod.Status.Phase = corev1.PodSucceeded
+				p := createScratchPool(singleClassPool, "scratch", scrubbingVolume(), scrubPvc, pod)
+				Expect(p.reconcile()).To(Succeed())
+				Expect(getVolume(p, "pv1").Annotations[AnnScratchPoolScrubbed]).To(Equal("scrub-uid"))
+				_, err := getPod(p)
+				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+				Expect(listProvisioning(p)).To(BeEmpty())
+			})
+
+			It("Should check out a volume released by its scrub PVC", func() {
+				pv := createScratchPoolVolume("pv1", "1Gi", "scratch")
+				pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "cdi", Name: scrubName, UID: "scrub-uid"}
+				pv.Annotations = map[string]string{AnnScratchPoolScrubbed: "scrub-uid"}
+				Expect(isScratchPoolVolumeReady(pv, "scratch")).To(BeTrue())
+			})
+
+			It("Should replace a failed scrub pod", func() {
+				pod := makeScratchScrubPodSpec("importer", "", "cdi", scrubName, nil)
+				pod.Status.Phase = corev1.PodFailed
+				p := createScratchPool(singleClassPool, "scratch", scrubbingVolume(), pod)
+				Expect(p.reconcile()).To(Succeed())
+				_, err := getPod(p)
+				Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+				Expect(p.reconcile()).To(Succeed())
+				pod, err = getPod(p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pod.Status.Phase).To(BeEmpty())
+			})
+		})
+
+		It("Should delete all volumes when the pool is removed", func() {
+			p := createScratchPool(nil, "scratch", createScratchPoolVolume("pv1", "1Gi", "scratch"))
+			Expect(p.reconcile()).To(Succeed())
+			Expect(getVolume(p, "pv1").Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimDelete))
+		})
+	})
+})
+
+// createScratchPoolVolume creates a released volume of the scratch space pool, that was scrubbed
+func createScratchPoolVolume(name, sizeClass, storageClassName string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				scratchPoolLabel: sizeClass,
+			},
+			Annotations: map[string]string{
+				AnnScratchPoolScrubbed: "old-scratch-uid",
+			},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse(sizeClass),
+			},
+			AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              storageClassName,
+			ClaimRef: &corev1.ObjectReference{
+				Namespace: "default",
+				Name:      "old-scratch",
+				UID:       "old-scratch-uid",
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{
+			Phase: corev1.VolumeReleased,
+		},
+	}
+}
+
+func createScratchPool(pool *cdiv1.ScratchSpacePool, storageClassName string, objects ...runtime.Object) *ScratchPool {
+	cdiv1.AddToScheme(scheme.Scheme)
+	config := createCDIConfigWithStorageClass(common.ConfigName, storageClassName)
+	config.Spec.ScratchSpacePool = pool
+	return &ScratchPool{
+		Client:    fake.NewFakeClientWithScheme(scheme.Scheme, append(objects, config)...),
+		Log:       scratchPoolLog,
+		namespace: "cdi",
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// InsufficientScratchSpace provides a const to indicate an import ran out of scratch space
+	InsufficientScratchSpace = "InsufficientScratchSpace"
+
+	// scratchRetryInterval is how often an import waits for its old scratch space PVC to be deleted
+	scratchRetryInterval = 2 * time.Second
+)
+
+// parseScratchSpaceExhausted returns the scratch space required and available from the termination message of
+// an importer pod that ran out of scratch space.
+func parseScratchSpaceExhausted(message string) (required, available int64, ok bool) {
+	if _, err := fmt.Sscanf(message, common.ScratchSpaceExhaustedMessage, &required, &available); err != nil {
+		return 0, 0, false
+	}
+	return required, available, true
+}
+
+// scratchSizeFromPvc returns the size of the scratch space PVC of pvc, the size of pvc unless the scratch space
+// was enlarged. Users can set the AnnScratchSize annotation themselves, so it is only honored up to maxSize, the
+// ScratchSpaceMaxSize of the CDIConfig, and ignored if scratch space is not enlarged at all.
+func scratchSizeFromPvc(pvc *v1.PersistentVolumeClaim, maxSize *resource.Quantity) resource.Quantity {
+	if size, err := resource.ParseQuantity(pvc.GetAnnotations()[AnnScratchSize]); err == nil && maxSize != nil {
+		if size.Cmp(*maxSize) > 0 {
+			return maxSize.DeepCopy()
+		}
+		return size
+	}
+	return pvc.Spec.Resources.Requests[v1.ResourceStorage]
+}
+
+// getScratchSpaceMaxSize returns the largest scratch space an import may be retried with, or nil if scratch
+// space is not enlarged.
+func getScratchSpaceMaxSize(c client.Client) (*resource.Quantity, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		return nil, err
+	}
+	return cdiconfig.Spec.ScratchSpaceMaxSize, nil
+}
+
+// enlargeScratchSpace records in the AnnScratchSize annotation of pvc the size of the scratch space to retry
+// its import with, after the importer reported message because it ran out of scratch space. The scratch
+// space is at least doubled, up to ScratchSpaceMaxSize of the CDIConfig. An import is retried once, false is
+// returned if it is not retried.
+func enlargeScratchSpace(c client.Client, pvc *v1.PersistentVolumeClaim, message string) (bool, error) {
+	if _, ok := pvc.GetAnnotations()[AnnScratchSize]; ok {
+		return false, nil
+	}
+	maxSize, err := getScratchSpaceMaxSize(c)
+	if err != nil || maxSize == nil {
+		return false, err
+	}
+	current := scratchSizeFromPvc(pvc, maxSize)
+	size := current.DeepCopy()
+	size.Add(current)
+	if required, _, ok := parseScratchSpaceExhausted(message); ok && size.CmpInt64(required) < 0 {
+		size = *resource.NewQuantity(required, current.Format)
+	}
+	if size.Cmp(*maxSize) > 0 {
+		size = maxSize.DeepCopy()
+	}
+	if size.Cmp(current) <= 0 {
+		return false, nil
+	}
+	pvc.GetAnnotations()[AnnScratchSize] = size.String()
+	return true, nil
+}
+
+// releaseScratchPvc deletes the scratch space PVC of pvc, when pvc is deleted mid-transfer. The scratch space PVC is
+// owned by the transfer pod, but it would only be garbage collected once that pod is gone.
+func releaseScratchPvc(c client.Client, pvc *v1.PersistentVolumeClaim) error {
+	scratchPvc := &v1.PersistentVolumeClaim{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: pvc.Namespace, Name: scratchNameFromPvc(pvc)}, scratchPvc); err != nil {
+		return IgnoreNotFound(err)
+	}
+	if _, ok := scratchPvc.Labels[scratchPvcLabel]; !ok || scratchPvc.Labels[LabelImportPvc] != pvc.Name || scratchPvc.DeletionTimestamp != nil {
+		// Not the scratch space of pvc, or it is being deleted already
+		return nil
+	}
+	return IgnoreNotFound(c.Delete(context.TODO(), scratchPvc))
+}
+
+// scratchSpaceExhaustedMessage describes the AnnScratchExhausted annotation of a PVC for its InsufficientScratchSpace
+// condition
+func scratchSpaceExhaustedMessage(exhausted string) string {
+	required, available, ok := parseScratchSpaceExhausted(exhausted)
+	if !ok {
+		return exhausted
+	}
+	return fmt.Sprintf("Scratch space ran out, %s required and %s available",
+		resource.NewQuantity(required, resource.BinarySI).String(), resource.NewQuantity(available, resource.BinarySI).String())
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Scratch space", func() {
+	exhausted := func(required, available int64) string {
+		return fmt.Sprintf(common.ScratchSpaceExhaustedMessage, required, available)
+	}
+
+	It("Should parse the termination message of an importer that ran out of scratch space", func() {
+		required, available, ok := parseScratchSpaceExhausted(exhausted(2048, 1024))
+		Expect(ok).To(BeTrue())
+		Expect(required).To(Equal(int64(2048)))
+		Expect(available).To(Equal(int64(1024)))
+		_, _, ok = parseScratchSpaceExhausted("Unable to process data")
+		Expect(ok).To(BeFalse())
+	})
+
+	table.DescribeTable("Should enlarge the scratch space", func(maxSize, scratchSize, message string, expectedResized bool, expectedSize string) {
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		if maxSize != "" {
+			size := resource.MustParse(maxSize)
+			cdiConfig.Spec.ScratchSpaceMaxSize = &size
+		}
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		if scratchSize != "" {
+			pvc.Annotations[AnnScratchSize] = scratchSize
+		}
+		resized, err := enlargeScratchSpace(c, pvc, message)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resized).To(Equal(expectedResized))
+		Expect(pvc.Annotations[AnnScratchSize]).To(Equal(expectedSize))
+	},
+		table.Entry("by doubling it", "10G", "", exhausted(1500000000, 1000000000), true, "2G"),
+		table.Entry("to the size required", "10G", "", exhausted(3000000000, 1000000000), true, "3G"),
+		table.Entry("up to the configured maximum", "1500M", "", exhausted(3000000000, 1000000000), true, "1500M"),
+		table.Entry("with an unknown required size", "10G", "", "No space left on device", true, "2G"),
+		table.Entry("not without a configured maximum", "", "", exhausted(1500000000, 1000000000), false, ""),
+		table.Entry("not beyond the configured maximum", "1G", "", exhausted(1500000000, 1000000000), false, ""),
+		table.Entry("not twice", "10G", "2G", exhausted(3000000000, 2000000000), false, "2G"),
+	)
+
+	It("Should create the scratch space PVC with the enlarged size", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnScratchSize: "2G"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		maxSize := resource.MustParse("10G")
+		scratchPvc := newScratchPersistentVolumeClaimSpec(pvc, pod, scratchNameFromPvc(pvc), "", &maxSize)
+		size := scratchPvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).To(Equal("2G"))
+		By("Verifying the target PVC is not changed")
+		size = pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).To(Equal("1G"))
+	})
+
+	table.DescribeTable("Should bound the scratch size annotation", func(maxSize, expected string) {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnScratchSize: "500G"}, nil)
+		pod := createImporterTestPod(pvc, "testPvc1", nil)
+		var max *resource.Quantity
+		if maxSize != "" {
+			quantity := resource.MustParse(maxSize)
+			max = &quantity
+		}
+		scratchPvc := newScratchPersistentVolumeClaimSpec(pvc, pod, scratchNameFromPvc(pvc), "", max)
+		size := scratchPvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		Expect(size.String()).To(Equal(expected))
+	},
+		table.Entry("by the configured maximum", "10G", "10G"),
+		table.Entry("by the size of the PVC without a configured maximum", "", "1G"),
+	)
+
+	It("Should set the InsufficientScratchSpace condition while the scratch space is exhausted", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnScratchExhausted: exhausted(2147483648, 1073741824)}, nil)
+		dv := newImportDataVolume("testDv")
+		propagateClaimConditions(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeInsufficientScratchSpace)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(InsufficientScratchSpace))
+		Expect(condition.Message).To(Equal("PersistentVolumeClaim default/testPvc1: Scratch space ran out, 2Gi required and 1Gi available"))
+
+		delete(pvc.Annotations, AnnScratchExhausted)
+		propagateClaimConditions(dv, pvc)
+		condition = conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeInsufficientScratchSpace)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+	})
+
+	It("Should not set the InsufficientScratchSpace condition if the scratch space was not exhausted", func() {
+		dv := newImportDataVolume("testDv")
+		propagateClaimConditions(dv, createPvc("testPvc1", "default", map[string]string{}, nil))
+		Expect(dv.Status.Conditions).To(BeEmpty())
+	})
+})
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// AnnAppArmorProfilePrefix prefixes the name of a container in the annotation that tells its AppArmor profile
+	AnnAppArmorProfilePrefix = "container.apparmor.security.beta.kubernetes.io/"
+	// localhostProfilePrefix prefixes a seccomp or AppArmor profile installed on the node
+	localhostProfilePrefix = "localhost/"
+
+	// importerSeccompProfile is the seccomp profile of importer pods, relative to the seccomp directory of the kubelet
+	importerSeccompProfile = "cdi-importer.json"
+	// uploadServerSeccompProfile is the seccomp profile of upload server pods
+	uploadServerSeccompProfile = "cdi-uploadserver.json"
+	// clonerSeccompProfile is the seccomp profile of clone source pods
+	clonerSeccompProfile = "cdi-cloner.json"
+	// transferAppArmorProfile is the AppArmor profile of the containers of all transfer pods
+	transferAppArmorProfile = "cdi-transfer"
+)
+
+// getSecurityProfiles returns the security profiles the CDIConfig applies to transfer pods, nil if it applies none
+func getSecurityProfiles(c client.Client) (*cdiv1.SecurityProfiles, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cdiconfig.Spec.SecurityProfiles, nil
+}
+
+// setSecurityProfiles confines the containers of a transfer pod to seccompProfile, one of the seccomp profiles CDI
+// ships, and to the AppArmor profile of transfer pods if the CDIConfig asks for them. Kubernetes only takes them as
+// annotations, a node without the profile fails to start the pod.
+func setSecurityProfiles(c client.Client, pod *corev1.Pod, seccompProfile string) error {
+	profiles, err := getSecurityProfiles(c)
+	if err != nil || profiles == nil {
+		return err
+	}
+	if profiles.Seccomp {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[corev1.SeccompPodAnnotationKey] = localhostProfilePrefix + seccompProfile
+	}
+	if profiles.AppArmor {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			pod.Annotations[AnnAppArmorProfilePrefix+container.Name] = localhostProfilePrefix + transferAppArmorProfile
+		}
+	}
+	return nil
+}
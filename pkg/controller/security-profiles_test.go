@@ -0,0 +1,62 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Security profiles", func() {
+	newTransferPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init"}},
+				Containers:     []corev1.Container{{Name: common.ImporterPodName}},
+			},
+		}
+	}
+
+	It("Should confine transfer pods to the profiles the CDIConfig asks for", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.SecurityProfiles = &cdiv1.SecurityProfiles{Seccomp: true, AppArmor: true}
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pod := newTransferPod()
+		Expect(setSecurityProfiles(c, pod, importerSeccompProfile)).To(Succeed())
+		Expect(pod.Annotations).To(HaveKeyWithValue(corev1.SeccompPodAnnotationKey, "localhost/cdi-importer.json"))
+		Expect(pod.Annotations).To(HaveKeyWithValue(AnnAppArmorProfilePrefix+"init", "localhost/cdi-transfer"))
+		Expect(pod.Annotations).To(HaveKeyWithValue(AnnAppArmorProfilePrefix+common.ImporterPodName, "localhost/cdi-transfer"))
+	})
+
+	It("Should only apply the seccomp profile if the CDIConfig only asks for it", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.SecurityProfiles = &cdiv1.SecurityProfiles{Seccomp: true}
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pod := newTransferPod()
+		Expect(setSecurityProfiles(c, pod, uploadServerSeccompProfile)).To(Succeed())
+		Expect(pod.Annotations).To(Equal(map[string]string{corev1.SeccompPodAnnotationKey: "localhost/cdi-uploadserver.json"}))
+	})
+
+	It("Should leave transfer pods unconfined by default", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, MakeEmptyCDIConfigSpec(common.ConfigName))
+		pod := newTransferPod()
+		Expect(setSecurityProfiles(c, pod, clonerSeccompProfile)).To(Succeed())
+		Expect(pod.Annotations).To(BeEmpty())
+	})
+
+	It("Should leave transfer pods unconfined without a CDIConfig", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		profiles, err := getSecurityProfiles(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(profiles).To(BeNil())
+	})
+})
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// AnnIstioInject tells Istio whether to inject its sidecar into a pod
+	AnnIstioInject = "sidecar.istio.io/inject"
+	// AnnLinkerdInject tells Linkerd whether to inject its proxy into a pod
+	AnnLinkerdInject = "linkerd.io/inject"
+)
+
+// getExcludeFromServiceMesh returns whether the CDIConfig keeps transfer pods out of service meshes
+func getExcludeFromServiceMesh(c client.Client) (bool, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return cdiconfig.Spec.ExcludeFromServiceMesh, nil
+}
+
+// setServiceMeshAnnotations keeps the sidecars of Istio and Linkerd out of a transfer pod if the CDIConfig asks for it.
+// A sidecar keeps running after the transfer, so the pod never completes, and it wraps the TLS connections between the
+// upload proxy, clone source pods and upload servers in its own mTLS.
+func setServiceMeshAnnotations(c client.Client, pod *corev1.Pod) error {
+	exclude, err := getExcludeFromServiceMesh(c)
+	if err != nil || !exclude {
+		return err
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[AnnIstioInject] = "false"
+	pod.Annotations[AnnLinkerdInject] = "disabled"
+	return nil
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Service mesh", func() {
+	It("Should keep the sidecars out of transfer pods if the CDIConfig asks for it", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.ExcludeFromServiceMesh = true
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pod := &corev1.Pod{}
+		Expect(setServiceMeshAnnotations(c, pod)).To(Succeed())
+		Expect(pod.Annotations).To(HaveKeyWithValue(AnnIstioInject, "false"))
+		Expect(pod.Annotations).To(HaveKeyWithValue(AnnLinkerdInject, "disabled"))
+	})
+
+	It("Should leave transfer pods to the service mesh by default", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pod := &corev1.Pod{}
+		Expect(setServiceMeshAnnotations(c, pod)).To(Succeed())
+		Expect(pod.Annotations).To(BeEmpty())
+	})
+
+	It("Should leave transfer pods to the service mesh without a CDIConfig", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		exclude, err := getExcludeFromServiceMesh(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exclude).To(BeFalse())
+	})
+})
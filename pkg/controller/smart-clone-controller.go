@@ -46,7 +46,7 @@ func NewSmartCloneController(mgr manager.Manager, log logr.Logger) (controller.C
 	reconciler := &SmartCloneReconciler{
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
-		Log:      log.WithName("smartclone-controller"),
+		Log:      newSampledLogger(log.WithName("smartclone-controller")),
 		recorder: mgr.GetEventRecorderFor("smartclone-controller"),
 	}
 	smartCloneController, err := controller.New("smartclone-controller", mgr, controller.Options{
@@ -128,7 +128,7 @@ func shouldReconcileSnapshot(snapshot *csiv1.VolumeSnapshot) bool {
 	if !ok {
 		return false
 	}
-	return snapshot.Status.ReadyToUse
+	return snapshot.Status.ReadyToUse || snapshot.Status.Error != nil
 }
 
 func shouldReconcilePvc(pvc *corev1.PersistentVolumeClaim) bool {
@@ -142,7 +142,7 @@ func shouldReconcilePvc(pvc *corev1.PersistentVolumeClaim) bool {
 
 // Reconcile the reconcile loop for smart cloning.
 func (r *SmartCloneReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
-	log := r.Log.WithValues("Datavolume", req.NamespacedName)
+	log := r.Log.WithValues(logKeyDataVolume, req.NamespacedName)
 	log.Info("reconciling smart clone")
 	pvc := &corev1.PersistentVolumeClaim{}
 	if err := r.Client.Get(context.TODO(), req.NamespacedName, pvc); err != nil {
@@ -163,7 +163,7 @@ func (r *SmartCloneReconciler) Reconcile(req reconcile.Request) (reconcile.Resul
 }
 
 func (r *SmartCloneReconciler) reconcilePvc(log logr.Logger, pvc *corev1.PersistentVolumeClaim) (reconcile.Result, error) {
-	log.WithValues("pvc.Name", pvc.Name).WithValues("pvc.Namespace", pvc.Namespace).Info("PVC created from snapshot, updating datavolume status")
+	log.WithValues(logKeyPVC, logObjectKey(pvc)).Info("PVC created from snapshot, updating datavolume status")
 	snapshotName := pvc.Spec.DataSource.Name
 
 	datavolume := &cdiv1.DataVolume{}
@@ -202,12 +202,19 @@ func (r *SmartCloneReconciler) reconcilePvc(log logr.Logger, pvc *corev1.Persist
 }
 
 func (r *SmartCloneReconciler) reconcileSnapshot(log logr.Logger, snapshot *csiv1.VolumeSnapshot) (reconcile.Result, error) {
-	log.WithValues("snapshot.Name", snapshot.Name).WithValues("snapshot.Namespace", snapshot.Namespace).Info("Updating datavolume status using snapshot")
+	log.WithValues("snapshot", logObjectKey(snapshot)).Info("Updating datavolume status using snapshot")
 	datavolume := &cdiv1.DataVolume{}
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: snapshot.Name, Namespace: snapshot.Namespace}, datavolume); err != nil {
 		return reconcile.Result{}, err
 	}
 
+	if !snapshot.Status.ReadyToUse && snapshot.Status.Error != nil {
+		// The snapshotter retries failed snapshots, surface the error on the DataVolume meanwhile
+		r.recorder.Event(datavolume, corev1.EventTypeWarning, SmartCloneSnapshotFailed,
+			fmt.Sprintf(MessageSmartCloneSnapshotFailed, datavolume.Spec.Source.PVC.Namespace, datavolume.Spec.Source.PVC.Name, snapshot.Status.Error.Message))
+		return reconcile.Result{}, nil
+	}
+
 	// Update DV phase and emit PVC in progress event
 	if err := r.updateSmartCloneStatusPhase(SmartClonePVCInProgress, datavolume, nil); err != nil {
 		// Have not properly updated the data volume status, don't delete the snapshot so we retry.
@@ -220,7 +227,7 @@ func (r *SmartCloneReconciler) reconcileSnapshot(log logr.Logger, snapshot *csiv
 		return reconcile.Result{}, errors.New("error creating new pvc from snapshot object, snapshot has no owner")
 	}
 
-	log.V(3).Info("Creating PVC from snapshot", "pvc.Namespace", newPvc.Namespace, "pvc.Name", newPvc.Name)
+	log.V(3).Info("Creating PVC from snapshot", logKeyPVC, logObjectKey(newPvc))
 	if err := r.Client.Create(context.TODO(), newPvc); err != nil {
 		log.Error(err, "error creating pvc from snapshot")
 		return reconcile.Result{}, err
@@ -250,6 +257,7 @@ func (r *SmartCloneReconciler) updateSmartCloneStatusPhase(phase cdiv1.DataVolum
 }
 
 func (r *SmartCloneReconciler) emitEvent(dataVolume *cdiv1.DataVolume, dataVolumeCopy *cdiv1.DataVolume, event *DataVolumeEvent, newPVC *corev1.PersistentVolumeClaim) error {
+	recordPhaseTransition(dataVolume, dataVolumeCopy)
 	// Only update the object if something actually changed in the status.
 	if !reflect.DeepEqual(dataVolume.Status, dataVolumeCopy.Status) {
 		if err := r.Client.Update(context.TODO(), dataVolumeCopy); err == nil {
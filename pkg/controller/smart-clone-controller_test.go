@@ -28,6 +28,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -64,6 +65,18 @@ var _ = Describe("Smart-clone reconcile functions", func() {
 		table.Entry("should not reconcile if annotation does not exist and not ready", "", false, false),
 	)
 
+	It("should reconcile a failed snapshot", func() {
+		val := &csiv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AnnSmartCloneRequest: "true"},
+			},
+			Status: csiv1.VolumeSnapshotStatus{
+				Error: &storagev1beta1.VolumeError{Message: "snapshot failed"},
+			},
+		}
+		Expect(shouldReconcileSnapshot(val)).To(BeTrue())
+	})
+
 	table.DescribeTable("pvc", func(key, value string, phase corev1.PersistentVolumeClaimPhase, expectSuccess bool) {
 		annotations := make(map[string]string)
 		if key != "" {
@@ -214,6 +227,22 @@ var _ = Describe("Smart-clone controller reconcileSnapshot loop", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(pvc.Spec.DataSource).ToNot(BeNil())
 	})
+
+	It("Should report a failed snapshot on the dv, and not create the pvc", func() {
+		controller := true
+		reconciler := createSmartCloneReconciler(newCloneDataVolume("test-dv"))
+		snapshot := createSnapshotVolume("test-dv", metav1.NamespaceDefault, &metav1.OwnerReference{
+			Controller: &controller,
+		})
+		snapshot.Status.Error = &storagev1beta1.VolumeError{Message: "snapshot failed"}
+		_, err := reconciler.reconcileSnapshot(reconciler.Log, snapshot)
+		Expect(err).ToNot(HaveOccurred())
+		event := <-reconciler.recorder.(*record.FakeRecorder).Events
+		Expect(event).To(ContainSubstring(SmartCloneSnapshotFailed))
+		Expect(event).To(ContainSubstring("snapshot failed"))
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}, &corev1.PersistentVolumeClaim{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
 })
 
 func createSmartCloneReconciler(objects ...runtime.Object) *SmartCloneReconciler {
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	csiv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// smartCloneJanitorInterval is how often the janitor looks for leaked smart-clone snapshots
+	smartCloneJanitorInterval = 5 * time.Minute
+	// smartCloneJanitorGracePeriod is the age a smart-clone snapshot needs before the janitor considers it, so it
+	// does not race the DataVolume controller on a DataVolume that is not in the cache yet
+	smartCloneJanitorGracePeriod = time.Minute
+	// smartCloneSnapshotStaleAge is the age after which the snapshot of a smart-clone still in progress is reported
+	// as stale
+	smartCloneSnapshotStaleAge = time.Hour
+)
+
+var (
+	leakedSmartCloneSnapshots = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_smart_clone_snapshots_leaked_total",
+			Help: "The number of temporary smart-clone snapshots deleted by the janitor because their DataVolume no longer needs them",
+		},
+	)
+	staleSmartCloneSnapshots = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cdi_smart_clone_snapshots_stale",
+			Help: "The number of temporary smart-clone snapshots older than an hour whose clone is still in progress",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(leakedSmartCloneSnapshots, staleSmartCloneSnapshots)
+}
+
+// SmartCloneJanitor periodically deletes the temporary snapshots of smart-clones that were left behind, for
+// instance by DataVolumes created before the DataVolume controller added a finalizer for them, or whose finalizer
+// was removed by hand.
+type SmartCloneJanitor struct {
+	Client client.Client
+	Log    logr.Logger
+}
+
+// NewSmartCloneJanitor creates a new smart-clone janitor and adds it to the manager.
+func NewSmartCloneJanitor(mgr manager.Manager, log logr.Logger) (*SmartCloneJanitor, error) {
+	janitor := &SmartCloneJanitor{
+		Client: mgr.GetClient(),
+		Log:    log.WithName("smart-clone-janitor"),
+	}
+	if err := mgr.Add(janitor); err != nil {
+		return nil, err
+	}
+	return janitor, nil
+}
+
+// Start runs the janitor, right away and then every smartCloneJanitorInterval, until stop is closed.
+func (j *SmartCloneJanitor) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := j.cleanupLeaks(time.Now()); err != nil {
+			j.Log.Error(err, "Unable to clean up leaked smart-clone snapshots")
+		}
+	}, smartCloneJanitorInterval, stop)
+	return nil
+}
+
+// cleanupLeaks deletes the smart-clone snapshots older than the grace period at now that are leaked, and counts
+// the stale ones
+func (j *SmartCloneJanitor) cleanupLeaks(now time.Time) error {
+	snapshots := &csiv1.VolumeSnapshotList{}
+	selector := labels.SelectorFromSet(map[string]string{common.CDIComponentLabel: common.SmartClonerCDILabel})
+	if err := j.Client.List(context.TODO(), snapshots, &client.ListOptions{LabelSelector: selector}); err != nil {
+		if meta.IsNoMatchError(err) {
+			// No volume snapshots in this cluster, so no smart-clones either
+			return nil
+		}
+		return err
+	}
+	stale := 0
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		age := now.Sub(snapshot.CreationTimestamp.Time)
+		if snapshot.DeletionTimestamp != nil || !metav1.HasAnnotation(snapshot.ObjectMeta, AnnSmartCloneRequest) || age < smartCloneJanitorGracePeriod {
+			continue
+		}
+		leaked, err := j.isLeaked(snapshot)
+		if err != nil {
+			return err
+		}
+		if !leaked {
+			if age >= smartCloneSnapshotStaleAge {
+				stale++
+			}
+			continue
+		}
+		j.Log.Info("Deleting leaked smart-clone snapshot", "namespace", snapshot.Namespace, "name", snapshot.Name)
+		if err := j.Client.Delete(context.TODO(), snapshot); IgnoreNotFound(err) != nil {
+			return err
+		}
+		leakedSmartCloneSnapshots.Inc()
+	}
+	staleSmartCloneSnapshots.Set(float64(stale))
+	return nil
+}
+
+// isLeaked returns true if the DataVolume of a smart-clone snapshot no longer exists, was replaced by one with the
+// same name, or no longer needs the snapshot and has no finalizer to delete it.
+func (j *SmartCloneJanitor) isLeaked(snapshot *csiv1.VolumeSnapshot) (bool, error) {
+	dv := &cdiv1.DataVolume{}
+	if err := j.Client.Get(context.TODO(), types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name}, dv); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !isSmartCloneSnapshotOf(snapshot, dv) {
+		return true, nil
+	}
+	if hasSmartCloneSnapshotFinalizer(dv) {
+		// The DataVolume controller deletes the snapshot when it removes the finalizer
+		return false, nil
+	}
+	return smartCloneDone(j.Client, dv)
+}
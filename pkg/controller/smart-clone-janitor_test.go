@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	csiv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var smartCloneJanitorLog = logf.Log.WithName("smart-clone-janitor-test")
+
+var _ = Describe("Smart-clone janitor", func() {
+	snapshotName := types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}
+	now := time.Now()
+
+	createDataVolume := func(phase cdiv1.DataVolumePhase, finalizer bool) *cdiv1.DataVolume {
+		dv := newCloneDataVolume("test-dv")
+		dv.UID = "dv-uid"
+		dv.Status.Phase = phase
+		if finalizer {
+			dv.Finalizers = []string{smartCloneSnapshotFinalizer}
+		}
+		return dv
+	}
+
+	createSnapshot := func(dv *cdiv1.DataVolume, age time.Duration) *csiv1.VolumeSnapshot {
+		snapshot := newSnapshot(dv, "snap-class")
+		snapshot.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		return snapshot
+	}
+
+	snapshotExists := func(j *SmartCloneJanitor) bool {
+		err := j.Client.Get(context.TODO(), snapshotName, &csiv1.VolumeSnapshot{})
+		if k8serrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ToNot(HaveOccurred())
+		return true
+	}
+
+	It("Should delete the snapshot of a deleted data volume", func() {
+		j := createSmartCloneJanitor(createSnapshot(createDataVolume(cdiv1.SmartClonePVCInProgress, true), 2*time.Minute))
+		before := readCounter(leakedSmartCloneSnapshots)
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(snapshotExists(j)).To(BeFalse())
+		Expect(readCounter(leakedSmartCloneSnapshots)).To(Equal(before + 1))
+	})
+
+	It("Should delete the snapshot of a replaced data volume", func() {
+		dv := createDataVolume(cdiv1.SmartClonePVCInProgress, true)
+		snapshot := createSnapshot(dv, 2*time.Minute)
+		dv.UID = "new-uid"
+		j := createSmartCloneJanitor(dv, snapshot)
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(snapshotExists(j)).To(BeFalse())
+	})
+
+	It("Should delete the snapshot of a done data volume without finalizer", func() {
+		dv := createDataVolume(cdiv1.Succeeded, false)
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Status.Phase = corev1.ClaimBound
+		j := createSmartCloneJanitor(dv, pvc, createSnapshot(dv, 2*time.Minute))
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(snapshotExists(j)).To(BeFalse())
+	})
+
+	It("Should leave the snapshot to the data volume controller when the finalizer is set", func() {
+		dv := createDataVolume(cdiv1.Failed, true)
+		j := createSmartCloneJanitor(dv, createSnapshot(dv, 2*time.Minute))
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(snapshotExists(j)).To(BeTrue())
+	})
+
+	It("Should not delete a snapshot in the grace period", func() {
+		j := createSmartCloneJanitor(createSnapshot(createDataVolume(cdiv1.SmartClonePVCInProgress, true), 0))
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(snapshotExists(j)).To(BeTrue())
+	})
+
+	It("Should count the stale snapshots of clones in progress", func() {
+		dv := createDataVolume(cdiv1.SnapshotForSmartCloneInProgress, true)
+		j := createSmartCloneJanitor(dv, createSnapshot(dv, 2*time.Hour))
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(snapshotExists(j)).To(BeTrue())
+		Expect(readGauge(staleSmartCloneSnapshots)).To(Equal(float64(1)))
+
+		Expect(j.Client.Delete(context.TODO(), dv)).To(Succeed())
+		Expect(j.cleanupLeaks(now)).To(Succeed())
+		Expect(readGauge(staleSmartCloneSnapshots)).To(BeZero())
+	})
+})
+
+func createSmartCloneJanitor(objects ...runtime.Object) *SmartCloneJanitor {
+	cdiv1.AddToScheme(scheme.Scheme)
+	csiv1.AddToScheme(scheme.Scheme)
+	return &SmartCloneJanitor{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		Log:    smartCloneJanitorLog,
+	}
+}
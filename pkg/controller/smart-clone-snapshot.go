@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+
+	csiv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// SmartCloneSnapshotFailed provides a const to indicate the snapshot for smart-clone reports an error
+	SmartCloneSnapshotFailed = "SmartCloneSnapshotFailed"
+	// MessageSmartCloneSnapshotFailed provides a const to form the snapshot for smart-clone failed message
+	MessageSmartCloneSnapshotFailed = "Snapshot for smart-clone of pvc %s/%s failed: %s"
+
+	// smartCloneSnapshotFinalizer keeps a smart-cloned DataVolume until its temporary snapshot is deleted
+	smartCloneSnapshotFinalizer = "cdi.kubevirt.io/smartCloneSnapshot"
+)
+
+// hasSmartCloneSnapshotFinalizer returns true if the finalizer that deletes the temporary snapshot is set on the
+// DataVolume
+func hasSmartCloneSnapshotFinalizer(dv *cdiv1.DataVolume) bool {
+	for _, f := range dv.Finalizers {
+		if f == smartCloneSnapshotFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// addSmartCloneSnapshotFinalizer adds the finalizer that deletes the temporary snapshot to a DataVolume, before the
+// snapshot is created
+func (r *DatavolumeReconciler) addSmartCloneSnapshotFinalizer(dv *cdiv1.DataVolume) error {
+	if hasSmartCloneSnapshotFinalizer(dv) {
+		return nil
+	}
+	dv.Finalizers = append(dv.Finalizers, smartCloneSnapshotFinalizer)
+	return r.Client.Update(context.TODO(), dv)
+}
+
+// reconcileSmartCloneSnapshot deletes the temporary snapshot of a smart-cloned DataVolume once the DataVolume is
+// deleted, failed, or succeeded with a bound PVC, and then removes the finalizer.
+func (r *DatavolumeReconciler) reconcileSmartCloneSnapshot(dv *cdiv1.DataVolume) error {
+	if !hasSmartCloneSnapshotFinalizer(dv) {
+		return nil
+	}
+	if dv.DeletionTimestamp == nil {
+		done, err := smartCloneDone(r.Client, dv)
+		if err != nil || !done {
+			return err
+		}
+	}
+	snapshot, err := getSmartCloneSnapshot(r.Client, dv.Namespace, dv.Name)
+	if err != nil {
+		return err
+	}
+	if snapshot != nil && isSmartCloneSnapshotOf(snapshot, dv) {
+		r.Log.V(3).Info("Deleting snapshot for smart-clone", "namespace", snapshot.Namespace, "name", snapshot.Name)
+		if err := r.Client.Delete(context.TODO(), snapshot); IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	var kept []string
+	for _, f := range dv.Finalizers {
+		if f != smartCloneSnapshotFinalizer {
+			kept = append(kept, f)
+		}
+	}
+	dv.Finalizers = kept
+	return r.Client.Update(context.TODO(), dv)
+}
+
+// smartCloneDone returns true if a DataVolume no longer needs its temporary snapshot: the clone failed, or it
+// succeeded and the PVC restored from the snapshot is bound.
+func smartCloneDone(c client.Client, dv *cdiv1.DataVolume) (bool, error) {
+	switch dv.Status.Phase {
+	case cdiv1.Failed:
+		return true, nil
+	case cdiv1.Succeeded:
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: dv.Namespace, Name: dv.Name}, pvc); err != nil {
+			if k8serrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return pvc.Status.Phase == corev1.ClaimBound, nil
+	}
+	return false, nil
+}
+
+// getSmartCloneSnapshot returns the snapshot with the given name if it was created for a smart-clone, nil otherwise
+func getSmartCloneSnapshot(c client.Client, namespace, name string) (*csiv1.VolumeSnapshot, error) {
+	snapshot := &csiv1.VolumeSnapshot{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, snapshot); err != nil {
+		return nil, IgnoreNotFound(err)
+	}
+	if !metav1.HasAnnotation(snapshot.ObjectMeta, AnnSmartCloneRequest) {
+		return nil, nil
+	}
+	return snapshot, nil
+}
+
+// isSmartCloneSnapshotOf returns true if snapshot is the temporary snapshot of dv. The garbage collector removes the
+// owner reference when the DataVolume is deleted with orphan propagation, such a snapshot still belongs to the
+// DataVolume with its name.
+func isSmartCloneSnapshotOf(snapshot *csiv1.VolumeSnapshot, dv *cdiv1.DataVolume) bool {
+	if snapshot.Name != dv.Name {
+		return false
+	}
+	ref := metav1.GetControllerOf(snapshot)
+	return ref == nil || ref.UID == dv.UID
+}
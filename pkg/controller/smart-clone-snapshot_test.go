@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+
+	csiv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Smart-clone snapshot finalizer", func() {
+	dvName := types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}
+
+	createSmartCloneDataVolume := func(phase cdiv1.DataVolumePhase) *cdiv1.DataVolume {
+		dv := newCloneDataVolume("test-dv")
+		dv.UID = "dv-uid"
+		dv.Finalizers = []string{smartCloneSnapshotFinalizer}
+		dv.Status.Phase = phase
+		return dv
+	}
+
+	snapshotExists := func(r *DatavolumeReconciler) bool {
+		err := r.Client.Get(context.TODO(), dvName, &csiv1.VolumeSnapshot{})
+		if k8serrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).ToNot(HaveOccurred())
+		return true
+	}
+
+	getDataVolume := func(r *DatavolumeReconciler) *cdiv1.DataVolume {
+		dv := &cdiv1.DataVolume{}
+		Expect(r.Client.Get(context.TODO(), dvName, dv)).To(Succeed())
+		return dv
+	}
+
+	table.DescribeTable("Should delete the snapshot once the clone is done", func(phase cdiv1.DataVolumePhase, claimPhase corev1.PersistentVolumeClaimPhase, deleted bool) {
+		dv := createSmartCloneDataVolume(phase)
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Status.Phase = claimPhase
+		reconciler := createDatavolumeReconciler(dv, pvc, newSnapshot(dv, "snap-class"))
+		Expect(reconciler.reconcileSmartCloneSnapshot(dv)).To(Succeed())
+		Expect(snapshotExists(reconciler)).To(Equal(!deleted))
+		if deleted {
+			Expect(getDataVolume(reconciler).Finalizers).ToNot(ContainElement(smartCloneSnapshotFinalizer))
+		} else {
+			Expect(getDataVolume(reconciler).Finalizers).To(ContainElement(smartCloneSnapshotFinalizer))
+		}
+	},
+		table.Entry("when the restored PVC is bound", cdiv1.Succeeded, corev1.ClaimBound, true),
+		table.Entry("but not before the restored PVC is bound", cdiv1.Succeeded, corev1.ClaimPending, false),
+		table.Entry("when the restore failed", cdiv1.Failed, corev1.ClaimLost, true),
+		table.Entry("but not while the snapshot is taken", cdiv1.SnapshotForSmartCloneInProgress, corev1.ClaimPending, false),
+	)
+
+	It("Should delete the snapshot when the data volume is deleted mid-clone", func() {
+		dv := createSmartCloneDataVolume(cdiv1.SmartClonePVCInProgress)
+		now := metav1.Now()
+		dv.DeletionTimestamp = &now
+		// The garbage collector removed the owner reference
+		snapshot := newSnapshot(dv, "snap-class")
+		snapshot.OwnerReferences = nil
+		reconciler := createDatavolumeReconciler(dv, snapshot)
+		Expect(reconciler.reconcileSmartCloneSnapshot(dv)).To(Succeed())
+		Expect(snapshotExists(reconciler)).To(BeFalse())
+		Expect(getDataVolume(reconciler).Finalizers).To(BeEmpty())
+	})
+
+	It("Should not delete the snapshot of another data volume with the same name", func() {
+		dv := createSmartCloneDataVolume(cdiv1.Failed)
+		other := dv.DeepCopy()
+		other.UID = "other-uid"
+		reconciler := createDatavolumeReconciler(dv, newSnapshot(other, "snap-class"))
+		Expect(reconciler.reconcileSmartCloneSnapshot(dv)).To(Succeed())
+		Expect(snapshotExists(reconciler)).To(BeTrue())
+		Expect(getDataVolume(reconciler).Finalizers).To(BeEmpty())
+	})
+})
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// AnnAllowedSources is the namespace annotation with the comma separated source types DataVolumes may use, e.g. registry,upload
+	AnnAllowedSources = AnnAPIGroup + "/allowedSources"
+	// AnnAllowedSourceHosts is the namespace annotation with the comma separated hosts the URLs of DataVolume sources
+	// may point to, an entry starting with *. allows the subdomains of the rest of the entry
+	AnnAllowedSourceHosts = AnnAPIGroup + "/allowedSourceHosts"
+
+	// SourceNotAllowed provides a const to indicate the source of a PVC is not allowed in its namespace
+	SourceNotAllowed = "SourceNotAllowed"
+
+	// sourcePVC and sourceUpload are the source types of clones and uploads in AnnAllowedSources
+	sourcePVC    = "pvc"
+	sourceUpload = "upload"
+)
+
+// splitAnnotationList returns the trimmed, non empty entries of the comma separated value
+func splitAnnotationList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func isHostAllowed(host string, allowedHosts []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSourcePolicy checks sourceType, the json name of a DataVolume source, and the urls it reads from against
+// the AnnAllowedSources and AnnAllowedSourceHosts annotations of namespace
+func ValidateSourcePolicy(annotations map[string]string, namespace, sourceType string, urls []string) error {
+	if value, ok := annotations[AnnAllowedSources]; ok {
+		allowedSources := splitAnnotationList(value)
+		allowed := false
+		for _, allowedSource := range allowedSources {
+			if strings.EqualFold(allowedSource, sourceType) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return errors.Errorf("Source %s is not allowed in namespace %s, allowed sources: %s", sourceType, namespace, strings.Join(allowedSources, ", "))
+		}
+	}
+
+	if value, ok := annotations[AnnAllowedSourceHosts]; ok {
+		allowedHosts := splitAnnotationList(value)
+		for _, sourceURL := range urls {
+			url, err := url.Parse(sourceURL)
+			if err != nil || !isHostAllowed(url.Hostname(), allowedHosts) {
+				return errors.Errorf("Source URL %s is not allowed in namespace %s, allowed hosts: %s", sourceURL, namespace, strings.Join(allowedHosts, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// importSourcePolicy returns the source type and the urls the import to pvc reads from, as ValidateSourcePolicy
+// expects them
+func importSourcePolicy(pvc *v1.PersistentVolumeClaim) (string, []string) {
+	source := getSource(pvc)
+	if source == SourceNone {
+		return "blank", nil
+	}
+	urls := []string{pvc.Annotations[AnnEndpoint]}
+	if value := pvc.Annotations[AnnBackingFileURLs]; value != "" && (source == SourceHTTP || source == SourceSSH) {
+		urls = append(urls, strings.Split(value, ",")...)
+	}
+	return source, urls
+}
+
+// checkSourcePolicy returns why the source of pvc is not allowed by the annotations of its namespace, "" if it is.
+// The webhook checks DataVolumes when they are created, this also covers the PVCs created without a DataVolume
+// and the DataVolumes created before the namespace was annotated.
+func checkSourcePolicy(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim, sourceType string, urls []string) (string, error) {
+	ns, err := client.CoreV1().Namespaces().Get(pvc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "could not get namespace %s", pvc.Namespace)
+	}
+	if err := ValidateSourcePolicy(ns.Annotations, pvc.Namespace, sourceType, urls); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// rejectSource records why the source of pvc is not allowed, and checks again after quotaRetryInterval in case
+// the namespace policy changes
+func rejectSource(recorder record.EventRecorder, pvc *v1.PersistentVolumeClaim, reason string) (reconcile.Result, error) {
+	recorder.Event(pvc, v1.EventTypeWarning, SourceNotAllowed, fmt.Sprintf("Not starting the transfer: %s", reason))
+	return reconcile.Result{RequeueAfter: quotaRetryInterval}, nil
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnSourceETag is the ETag of the HTTP response the image of the PVC was imported from
+	AnnSourceETag = AnnAPIGroup + "/storage.import.sourceETag"
+	// AnnSourceLastModified is the Last-Modified time of the HTTP response the image of the PVC was imported from
+	AnnSourceLastModified = AnnAPIGroup + "/storage.import.sourceLastModified"
+)
+
+// updateSourceValidators records the validators of the HTTP response the succeeded importer in pod imported from in
+// annotations, they tell if the image at the endpoint changed since
+func updateSourceValidators(pod *v1.Pod, annotations map[string]string) {
+	if pod.Status.Phase != v1.PodSucceeded {
+		return
+	}
+	message, ok := succeededTerminationMessage(pod)
+	if !ok {
+		return
+	}
+	if message.ETag != "" {
+		annotations[AnnSourceETag] = message.ETag
+	}
+	if message.LastModified != "" {
+		annotations[AnnSourceLastModified] = message.LastModified
+	}
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Source validators", func() {
+	createTerminatedPod := func(phase corev1.PodPhase, message string) *corev1.Pod {
+		return &corev1.Pod{
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{Message: message},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	It("Should record the validators of the response the image was imported from", func() {
+		annotations := map[string]string{}
+		updateSourceValidators(createTerminatedPod(corev1.PodSucceeded, encodeTerminationMessage(&util.TerminationMessage{
+			Result:       util.TerminationSucceeded,
+			ETag:         `"5e8c-5a0d3f"`,
+			LastModified: "Tue, 14 Apr 2020 08:12:31 GMT",
+		})), annotations)
+		Expect(annotations).To(HaveKeyWithValue(AnnSourceETag, `"5e8c-5a0d3f"`))
+		Expect(annotations).To(HaveKeyWithValue(AnnSourceLastModified, "Tue, 14 Apr 2020 08:12:31 GMT"))
+	})
+
+	It("Should not record validators the server did not send", func() {
+		annotations := map[string]string{}
+		updateSourceValidators(createTerminatedPod(corev1.PodSucceeded, encodeTerminationMessage(&util.TerminationMessage{
+			Result: util.TerminationSucceeded,
+			ETag:   `W/"5e8c"`,
+		})), annotations)
+		Expect(annotations).To(HaveKeyWithValue(AnnSourceETag, `W/"5e8c"`))
+		Expect(annotations).ToNot(HaveKey(AnnSourceLastModified))
+	})
+
+	It("Should not record validators of a failed pod", func() {
+		annotations := map[string]string{}
+		updateSourceValidators(createTerminatedPod(corev1.PodFailed, "Unable to process data"), annotations)
+		Expect(annotations).To(BeEmpty())
+	})
+})
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// StorageClassFallback provides a const to indicate the PVC of a DataVolume is recreated with the next fallback
+	// storage class
+	StorageClassFallback = "StorageClassFallback"
+	// MessageStorageClassFallback provides a const to form the message of a storage class fallback
+	MessageStorageClassFallback = "PVC %s %s with storage class %s, retrying with storage class %s"
+
+	// defaultStorageClassFallbackDeadline is how long the PVC of a DataVolume may stay pending before the next
+	// fallback storage class is tried, if the DataVolume does not tell
+	defaultStorageClassFallbackDeadline = 5 * time.Minute
+)
+
+// fallbackStorageClasses returns the storage classes the PVC of dv falls back to, in order
+func fallbackStorageClasses(dv *cdiv1.DataVolume) []string {
+	var storageClasses []string
+	for _, storageClass := range strings.Split(dv.Annotations[AnnFallbackStorageClasses], ",") {
+		if storageClass = strings.TrimSpace(storageClass); storageClass != "" {
+			storageClasses = append(storageClasses, storageClass)
+		}
+	}
+	return storageClasses
+}
+
+// dataVolumeStorageClass returns the storage class the PVC of dv is created with, nil for the default storage class
+func dataVolumeStorageClass(dv *cdiv1.DataVolume) *string {
+	if storageClass, ok := dv.Annotations[AnnFallbackStorageClass]; ok {
+		return &storageClass
+	}
+	return dv.Spec.PVC.StorageClassName
+}
+
+// nextFallbackStorageClass returns the storage class that follows the one the PVC of dv is created with, false once
+// all were tried
+func nextFallbackStorageClass(dv *cdiv1.DataVolume) (string, bool) {
+	storageClasses := fallbackStorageClasses(dv)
+	next := 0
+	if current := dataVolumeStorageClass(dv); current != nil {
+		for i, storageClass := range storageClasses {
+			if storageClass == *current {
+				next = i + 1
+			}
+		}
+	}
+	if next >= len(storageClasses) {
+		return "", false
+	}
+	return storageClasses[next], true
+}
+
+// storageClassFallbackDeadline returns how long the PVC of dv may stay pending before it falls back
+func storageClassFallbackDeadline(dv *cdiv1.DataVolume) time.Duration {
+	if value, ok := dv.Annotations[AnnFallbackStorageClassDeadline]; ok {
+		if deadline, err := time.ParseDuration(value); err == nil && deadline > 0 {
+			return deadline
+		}
+	}
+	return defaultStorageClassFallbackDeadline
+}
+
+// canFallBackStorageClass returns true if the PVC of dv may be recreated with another storage class: the DataVolume
+// has fallback storage classes, did not adopt the PVC, is not pre-bound to a volume of its storage class and does not
+// clone, as a smart clone restores a snapshot of the storage class of its source
+func canFallBackStorageClass(dv *cdiv1.DataVolume) bool {
+	return len(fallbackStorageClasses(dv)) > 0 && !adoptsPVC(dv) && !isPreBound(dv) && dv.Spec.Source.PVC == nil
+}
+
+// storageClassName returns the name of storageClass for messages
+func storageClassName(storageClass *string) string {
+	if storageClass == nil || *storageClass == "" {
+		return "default"
+	}
+	return *storageClass
+}
+
+// fallBackStorageClass records the next fallback storage class on dv, the PVC is then created with it. It returns
+// false if all storage classes were tried.
+func (r *DatavolumeReconciler) fallBackStorageClass(dv *cdiv1.DataVolume, pvcName, failure string) (bool, error) {
+	next, ok := nextFallbackStorageClass(dv)
+	if !ok {
+		r.Log.V(1).Info("No storage class left to fall back to", "namespace", dv.Namespace, "name", dv.Name, "failure", failure)
+		return false, nil
+	}
+	current := storageClassName(dataVolumeStorageClass(dv))
+	r.Log.V(1).Info("Falling back to the next storage class", "namespace", dv.Namespace, "name", dv.Name, "failure", failure, "storageClass", next)
+
+	if dv.Annotations == nil {
+		dv.Annotations = make(map[string]string)
+	}
+	dv.Annotations[AnnFallbackStorageClass] = next
+	if err := r.Client.Update(context.TODO(), dv); err != nil {
+		return false, err
+	}
+	r.recorder.Event(dv, corev1.EventTypeWarning, StorageClassFallback, fmt.Sprintf(MessageStorageClassFallback, pvcName, failure, current, next))
+	return true, nil
+}
+
+// reconcileStorageClassFallbackOnCreate falls back to the next storage class if the PVC of dv was not created with
+// err, such as by a quota of the storage class. It returns true if it fell back.
+func (r *DatavolumeReconciler) reconcileStorageClassFallbackOnCreate(dv *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim, err error) (bool, error) {
+	if !canFallBackStorageClass(dv) || !(k8serrors.IsForbidden(err) || k8serrors.IsInvalid(err)) {
+		return false, nil
+	}
+	return r.fallBackStorageClass(dv, pvc.Name, fmt.Sprintf("could not be created (%v)", err))
+}
+
+// reconcileStorageClassFallback deletes the PVC of dv if it stayed pending longer than the fallback deadline and
+// records the next storage class on the DataVolume, the PVC is then recreated with it. It returns true if the PVC
+// was deleted, otherwise when to check the PVC again, 0 if it need not be checked.
+func (r *DatavolumeReconciler) reconcileStorageClassFallback(dv *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) (bool, time.Duration, error) {
+	if !canFallBackStorageClass(dv) || pvc.Status.Phase != corev1.ClaimPending || pvc.DeletionTimestamp != nil {
+		return false, 0, nil
+	}
+	if _, ok := nextFallbackStorageClass(dv); !ok {
+		return false, 0, nil
+	}
+	deadline := storageClassFallbackDeadline(dv)
+	if pending := time.Since(pvc.CreationTimestamp.Time); pending < deadline {
+		return false, deadline - pending, nil
+	}
+	fellBack, err := r.fallBackStorageClass(dv, pvc.Name, fmt.Sprintf("stayed pending for %s", deadline))
+	if err != nil || !fellBack {
+		return false, 0, err
+	}
+	if err := r.Client.Delete(context.TODO(), pvc); err != nil && !k8serrors.IsNotFound(err) {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Storage class fallback", func() {
+	dvName := types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}
+
+	keepPending := func(r *DatavolumeReconciler, since time.Time) {
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		pvc.Status.Phase = corev1.ClaimPending
+		pvc.CreationTimestamp = metav1.NewTime(since)
+		Expect(r.Client.Update(context.TODO(), pvc)).To(Succeed())
+	}
+
+	reconcileDV := func(r *DatavolumeReconciler) {
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: dvName})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("Should recreate the PVC with the next storage class if it stays pending past the deadline", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnFallbackStorageClasses: "zone-b, standard"}
+		r := createDatavolumeReconciler(dv)
+		reconcileDV(r)
+		keepPending(r, time.Now().Add(-defaultStorageClassFallbackDeadline-time.Minute))
+
+		reconcileDV(r)
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Client.Get(context.TODO(), dvName, pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(r.Client.Get(context.TODO(), dvName, dv)).To(Succeed())
+		Expect(dv.Annotations).To(HaveKeyWithValue(AnnFallbackStorageClass, "zone-b"))
+
+		reconcileDV(r)
+		Expect(r.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		Expect(pvc.Spec.StorageClassName).ToNot(BeNil())
+		Expect(*pvc.Spec.StorageClassName).To(Equal("zone-b"))
+	})
+
+	It("Should check the PVC again at the deadline", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{
+			AnnFallbackStorageClasses:       "zone-b",
+			AnnFallbackStorageClassDeadline: "10m",
+		}
+		r := createDatavolumeReconciler(dv)
+		reconcileDV(r)
+		keepPending(r, time.Now().Add(-time.Minute))
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		deleted, requeueAfter, err := r.reconcileStorageClassFallback(dv, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deleted).To(BeFalse())
+		Expect(requeueAfter).To(BeNumerically("~", 9*time.Minute, time.Second))
+	})
+
+	It("Should not recreate the PVC without fallback storage classes", func() {
+		r := createDatavolumeReconciler(newImportDataVolume("test-dv"))
+		reconcileDV(r)
+		keepPending(r, time.Now().Add(-time.Hour))
+
+		reconcileDV(r)
+		Expect(r.Client.Get(context.TODO(), dvName, &corev1.PersistentVolumeClaim{})).To(Succeed())
+	})
+
+	It("Should keep the PVC once all storage classes were tried", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{
+			AnnFallbackStorageClasses: "zone-b,standard",
+			AnnFallbackStorageClass:   "standard",
+		}
+		r := createDatavolumeReconciler(dv)
+		reconcileDV(r)
+		keepPending(r, time.Now().Add(-time.Hour))
+
+		reconcileDV(r)
+		Expect(r.Client.Get(context.TODO(), dvName, &corev1.PersistentVolumeClaim{})).To(Succeed())
+	})
+
+	It("Should fall back if the PVC can not be created", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnFallbackStorageClasses: "zone-b"}
+		r := createDatavolumeReconciler(dv)
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		quotaErr := k8serrors.NewForbidden(schema.GroupResource{Resource: "persistentvolumeclaims"}, pvc.Name, errors.New("exceeded quota"))
+
+		fellBack, err := r.reconcileStorageClassFallbackOnCreate(dv, pvc, quotaErr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fellBack).To(BeTrue())
+		Expect(r.Client.Get(context.TODO(), dvName, dv)).To(Succeed())
+		Expect(dv.Annotations).To(HaveKeyWithValue(AnnFallbackStorageClass, "zone-b"))
+
+		fellBack, err = r.reconcileStorageClassFallbackOnCreate(dv, pvc, k8serrors.NewServiceUnavailable("try again"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fellBack).To(BeFalse())
+	})
+
+	It("Should not fall back for clones", func() {
+		dv := newCloneDataVolume("test-dv")
+		dv.Annotations[AnnFallbackStorageClasses] = "zone-b"
+		Expect(canFallBackStorageClass(dv)).To(BeFalse())
+	})
+
+	It("Should pick the storage class after the current one", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnFallbackStorageClasses: "zone-a,zone-b,standard"}
+		next, ok := nextFallbackStorageClass(dv)
+		Expect(ok).To(BeTrue())
+		Expect(next).To(Equal("zone-a"))
+
+		zoneA := "zone-a"
+		dv.Spec.PVC.StorageClassName = &zoneA
+		next, _ = nextFallbackStorageClass(dv)
+		Expect(next).To(Equal("zone-b"))
+
+		dv.Annotations[AnnFallbackStorageClass] = "zone-b"
+		next, _ = nextFallbackStorageClass(dv)
+		Expect(next).To(Equal("standard"))
+
+		dv.Annotations[AnnFallbackStorageClass] = "standard"
+		_, ok = nextFallbackStorageClass(dv)
+		Expect(ok).To(BeFalse())
+	})
+})
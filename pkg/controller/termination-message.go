@@ -0,0 +1,61 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// exitCodeErrors are the error codes of the exit codes that tell why a transfer pod failed, for termination messages
+// that are not json
+var exitCodeErrors = map[int32]string{
+	common.ScratchSpaceExhaustedExitCode:   util.TerminationErrorScratchSpaceExhausted,
+	common.DestinationNotWritableExitCode:  util.TerminationErrorDestinationNotWritable,
+	common.CloneVerificationFailedExitCode: util.TerminationErrorCloneVerificationFailed,
+}
+
+// terminationMessage returns the termination message of the terminated transfer container. A message that is not
+// json, the log of a container that failed before it wrote one, is the message of the result its exit code tells.
+func terminationMessage(terminated *v1.ContainerStateTerminated) *util.TerminationMessage {
+	if message, ok := util.ParseTerminationMessage(terminated.Message); ok {
+		return message
+	}
+	if terminated.ExitCode == 0 {
+		return &util.TerminationMessage{Result: util.TerminationSucceeded, Message: terminated.Message}
+	}
+	return &util.TerminationMessage{
+		Result:    util.TerminationFailed,
+		Message:   terminated.Message,
+		ErrorCode: exitCodeErrors[terminated.ExitCode],
+		Retryable: terminated.ExitCode != common.PermanentFailureExitCode,
+	}
+}
+
+// succeededTerminationMessage returns the termination message of the transfer container of pod if it succeeded
+func succeededTerminationMessage(pod *v1.Pod) (*util.TerminationMessage, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode == 0 {
+			if message := terminationMessage(terminated); message.Result == util.TerminationSucceeded {
+				return message, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// failedTerminationMessage returns the termination message of the last failure of the transfer container of pod
+// with errorCode
+func failedTerminationMessage(pod *v1.Pod, errorCode string) (*util.TerminationMessage, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			if message := terminationMessage(terminated); message.ErrorCode == errorCode {
+				return message, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// encodeTerminationMessage returns message as the json a transfer pod writes
+func encodeTerminationMessage(message *util.TerminationMessage) string {
+	b, _ := json.Marshal(message)
+	return string(b)
+}
+
+var _ = Describe("Termination message", func() {
+	It("Should read the json of a transfer pod", func() {
+		message := &util.TerminationMessage{
+			Result:    util.TerminationFailed,
+			Message:   "Unable to process data: not found",
+			ErrorCode: util.PermanentErrorNotFound,
+		}
+		terminated := &corev1.ContainerStateTerminated{ExitCode: 1, Message: encodeTerminationMessage(message)}
+		Expect(terminationMessage(terminated)).To(Equal(message))
+	})
+
+	table.DescribeTable("Should tell the result of a message that is not json by the exit code", func(exitCode int32, expected *util.TerminationMessage) {
+		terminated := &corev1.ContainerStateTerminated{ExitCode: exitCode, Message: "I went poof"}
+		Expect(terminationMessage(terminated)).To(Equal(expected))
+	},
+		table.Entry("of a succeeded pod", int32(0), &util.TerminationMessage{Result: util.TerminationSucceeded, Message: "I went poof"}),
+		table.Entry("of a failed pod", int32(1), &util.TerminationMessage{Result: util.TerminationFailed, Message: "I went poof", Retryable: true}),
+		table.Entry("of a pod that failed permanently", int32(common.PermanentFailureExitCode), &util.TerminationMessage{Result: util.TerminationFailed, Message: "I went poof"}),
+		table.Entry("of an upload server that can not write", int32(common.DestinationNotWritableExitCode),
+			&util.TerminationMessage{Result: util.TerminationFailed, Message: "I went poof", ErrorCode: util.TerminationErrorDestinationNotWritable, Retryable: true}),
+	)
+
+	It("Should only read the results of a succeeded pod", func() {
+		pod := &corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: 1,
+								Message:  encodeTerminationMessage(&util.TerminationMessage{Result: util.TerminationSucceeded, Digest: "sha256:00"}),
+							},
+						},
+					},
+				},
+			},
+		}
+		_, ok := succeededTerminationMessage(pod)
+		Expect(ok).To(BeFalse())
+	})
+})
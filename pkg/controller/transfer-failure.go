@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	v1 "k8s.io/api/core/v1"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// TransferFailed provides a const to indicate a transfer pod of a DataVolume failed
+	TransferFailed = "TransferFailed"
+	// TransferSucceeded provides a const to indicate a transfer pod of a DataVolume succeeded after it failed
+	TransferSucceeded = "TransferSucceeded"
+
+	// transferFailureMaxLines is how many of the last lines of the termination message of a failed transfer pod are
+	// kept, a message that fell back to the log of the pod can have 80
+	transferFailureMaxLines = 10
+	// transferFailureMaxBytes is the most of the termination message of a failed transfer pod that is kept, events
+	// are truncated beyond it
+	transferFailureMaxBytes = 1024
+)
+
+var (
+	// ansiEscape matches the escape sequences that color or move the cursor in a log
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+	// urlCredentials matches the user and password of a URL
+	urlCredentials = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/@\s]+@`)
+	// secretParameters matches the values of the query parameters of a URL that hold credentials, such as those of a
+	// presigned S3 URL
+	secretParameters = regexp.MustCompile(`(?i)([?&][a-z0-9_.-]*(signature|token|secret|password|credential|key)[a-z0-9_.-]*=)[^&\s"']+`)
+)
+
+// transferFailureMessage returns the termination message of the last failure of the transfer container of pod, or
+// its reason and exit code if it wrote no message
+func transferFailureMessage(pod *v1.Pod) (string, bool) {
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return "", false
+	}
+	status := pod.Status.ContainerStatuses[0]
+	for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		if message := sanitizeTransferFailure(terminationMessage(terminated).Message); message != "" {
+			return message, true
+		}
+		return fmt.Sprintf("%s, exit code %d", terminated.Reason, terminated.ExitCode), true
+	}
+	return "", false
+}
+
+// sanitizeTransferFailure prepares the termination message of a transfer pod for a DataVolume event and condition:
+// it removes escape sequences, control characters and blank lines, masks the credentials of URLs, and keeps the end of
+// the message, at most transferFailureMaxLines lines and transferFailureMaxBytes bytes.
+func sanitizeTransferFailure(message string) string {
+	message = ansiEscape.ReplaceAllString(message, "")
+	message = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t') {
+			return -1
+		}
+		return r
+	}, message)
+	message = urlCredentials.ReplaceAllString(message, "${1}***@")
+	message = secretParameters.ReplaceAllString(message, "${1}***")
+
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimRight(line, " \t"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	truncated := false
+	if len(lines) > transferFailureMaxLines {
+		lines, truncated = lines[len(lines)-transferFailureMaxLines:], true
+	}
+	message = strings.Join(lines, "\n")
+	if len(message) > transferFailureMaxBytes-len("...") {
+		start := len(message) - (transferFailureMaxBytes - len("..."))
+		for start < len(message) && !utf8.RuneStart(message[start]) {
+			start++
+		}
+		message, truncated = message[start:], true
+	}
+	if truncated {
+		message = "..." + message
+	}
+	return message
+}
+
+// updateTransferFailure records the termination message of the failed transfer pod in the AnnTransferFailure
+// annotation of its PVC, and removes it once the pod succeeded. It returns the message if it is new.
+func updateTransferFailure(pod *v1.Pod, anno map[string]string) (string, bool) {
+	if pod.Status.Phase == v1.PodSucceeded {
+		delete(anno, AnnTransferFailure)
+		return "", false
+	}
+	message, ok := transferFailureMessage(pod)
+	if !ok || anno[AnnTransferFailure] == message {
+		return "", false
+	}
+	anno[AnnTransferFailure] = message
+	return message, true
+}
+
+// updateTransferFailedCondition reflects the last failure of a transfer pod of pvc in the TransferFailed condition of
+// dataVolume. It returns the message of the failure if it is new.
+func updateTransferFailedCondition(dataVolume *cdiv1.DataVolume, pvc *v1.PersistentVolumeClaim) (string, bool) {
+	current := conditions.FindStatusCondition(dataVolume.Status.Conditions, cdiv1.DataVolumeTransferFailed)
+	message, ok := pvc.GetAnnotations()[AnnTransferFailure]
+	if !ok {
+		if current != nil && current.Status != v1.ConditionFalse {
+			conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+				Type:   cdiv1.DataVolumeTransferFailed,
+				Status: v1.ConditionFalse,
+				Reason: TransferSucceeded,
+			})
+		}
+		return "", false
+	}
+	if current != nil && current.Status == v1.ConditionTrue && current.Message == message {
+		return "", false
+	}
+	conditions.SetStatusCondition(&dataVolume.Status.Conditions, conditions.Condition{
+		Type:    cdiv1.DataVolumeTransferFailed,
+		Status:  v1.ConditionTrue,
+		Reason:  TransferFailed,
+		Message: message,
+	})
+	return message, true
+}
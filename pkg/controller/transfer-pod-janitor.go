@@ -0,0 +1,219 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// transferPodJanitorInterval is how often the janitor looks for completed transfer pods to delete
+	transferPodJanitorInterval = time.Minute
+	// transferLogTailLines is how many lines of the log of a transfer pod are kept in the AnnTransferLog annotation
+	transferLogTailLines = 20
+	// transferLogMaxBytes is the most of the log of a transfer pod kept in the AnnTransferLog annotation
+	transferLogMaxBytes = 4096
+)
+
+var (
+	completedTransferPodsDeleted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdi_completed_transfer_pods_deleted_total",
+			Help: "The number of succeeded transfer pods deleted after the completed pod retention",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(completedTransferPodsDeleted)
+}
+
+// getCompletedPodRetention returns how long succeeded transfer pods are kept, 0 if they are deleted right away, and
+// whether the end of their logs is kept when they are deleted
+func getCompletedPodRetention(c client.Client) (time.Duration, bool, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if cdiconfig.Spec.CompletedPodRetention == nil || cdiconfig.Spec.CompletedPodRetention.Duration <= 0 {
+		return 0, false, nil
+	}
+	return cdiconfig.Spec.CompletedPodRetention.Duration, cdiconfig.Spec.PreserveTransferLogs, nil
+}
+
+// TransferPodJanitor periodically deletes the importer, clone source and upload server pods that succeeded longer
+// than the CompletedPodRetention of the CDIConfig ago, and the services of the upload servers. Without a retention
+// the controllers delete them as soon as they succeed.
+type TransferPodJanitor struct {
+	Client client.Client
+	Log    logr.Logger
+	// podLogs returns the last lines of the log of a pod
+	podLogs func(pod *corev1.Pod, lines int64) ([]byte, error)
+}
+
+// NewTransferPodJanitor creates a new transfer pod janitor and adds it to the manager.
+func NewTransferPodJanitor(mgr manager.Manager, k8sClient kubernetes.Interface, log logr.Logger) (*TransferPodJanitor, error) {
+	janitor := &TransferPodJanitor{
+		Client: mgr.GetClient(),
+		Log:    log.WithName("transfer-pod-janitor"),
+		podLogs: func(pod *corev1.Pod, lines int64) ([]byte, error) {
+			return k8sClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{TailLines: &lines}).DoRaw()
+		},
+	}
+	if err := mgr.Add(janitor); err != nil {
+		return nil, err
+	}
+	return janitor, nil
+}
+
+// Start runs the janitor, right away and then every transferPodJanitorInterval, until stop is closed.
+func (j *TransferPodJanitor) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := j.cleanupCompleted(time.Now()); err != nil {
+			j.Log.Error(err, "Unable to clean up completed transfer pods")
+		}
+	}, transferPodJanitorInterval, stop)
+	return nil
+}
+
+// cleanupCompleted deletes the transfer pods whose retention ended at now
+func (j *TransferPodJanitor) cleanupCompleted(now time.Time) error {
+	retention, preserveLogs, err := getCompletedPodRetention(j.Client)
+	if err != nil || retention == 0 {
+		return err
+	}
+	pods := &corev1.PodList{}
+	selector := labels.SelectorFromSet(map[string]string{common.CDILabelKey: common.CDILabelValue})
+	if err := j.Client.List(context.TODO(), pods, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isCompletedTransferPod(pod) || now.Sub(completionTime(pod)) < retention {
+			continue
+		}
+		if preserveLogs {
+			if err := j.preserveLog(pod); err != nil {
+				// The log is a convenience, it does not keep the pod
+				j.Log.Error(err, "Unable to keep the log of completed transfer pod", "namespace", pod.Namespace, "name", pod.Name)
+			}
+		}
+		j.Log.V(1).Info("Deleting completed transfer pod", "namespace", pod.Namespace, "name", pod.Name)
+		if err := j.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return err
+		}
+		completedTransferPodsDeleted.Inc()
+		if pod.Labels[common.CDIComponentLabel] == common.UploadServerCDILabel {
+			// The service of an upload server is named after it
+			service := &corev1.Service{}
+			if err := j.Client.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, service); err == nil {
+				if err := j.Client.Delete(context.TODO(), service); IgnoreNotFound(err) != nil {
+					return err
+				}
+			} else if !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// preserveLog keeps the end of the log of pod in the AnnTransferLog annotation of the PVC it transferred to
+func (j *TransferPodJanitor) preserveLog(pod *corev1.Pod) error {
+	pvcName, ok := transferPodPvc(pod)
+	if !ok {
+		return nil
+	}
+	log, err := j.podLogs(pod, transferLogTailLines)
+	if err != nil {
+		return err
+	}
+	if len(log) > transferLogMaxBytes {
+		log = log[len(log)-transferLogMaxBytes:]
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := j.Client.Get(context.TODO(), pvcName, pvc); err != nil {
+		return IgnoreNotFound(err)
+	}
+	if pvc.GetAnnotations() == nil {
+		pvc.SetAnnotations(make(map[string]string))
+	}
+	pvc.GetAnnotations()[AnnTransferLog] = string(log)
+	return j.Client.Update(context.TODO(), pvc)
+}
+
+// isCompletedTransferPod returns true for importer, clone source and upload server pods that succeeded
+func isCompletedTransferPod(pod *corev1.Pod) bool {
+	switch pod.Labels[common.CDIComponentLabel] {
+	case common.ImporterPodName, common.ClonerSourcePodName, common.UploadServerCDILabel:
+	default:
+		return false
+	}
+	return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodSucceeded
+}
+
+// completionTime returns when the containers of pod terminated, or else when pod was created
+func completionTime(pod *corev1.Pod) time.Time {
+	completed := pod.CreationTimestamp.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.FinishedAt.After(completed) {
+			completed = status.State.Terminated.FinishedAt.Time
+		}
+	}
+	return completed
+}
+
+// transferPodPvc returns the PVC a transfer pod transferred to
+func transferPodPvc(pod *corev1.Pod) (types.NamespacedName, bool) {
+	switch pod.Labels[common.CDIComponentLabel] {
+	case common.ImporterPodName:
+		if name, ok := pod.Labels[LabelImportPvc]; ok {
+			return types.NamespacedName{Namespace: pod.Namespace, Name: name}, true
+		}
+	case common.ClonerSourcePodName:
+		if namespace, name, err := cache.SplitMetaNamespaceKey(pod.Annotations[AnnOwnerRef]); err == nil && name != "" {
+			return types.NamespacedName{Namespace: namespace, Name: name}, true
+		}
+	case common.UploadServerCDILabel:
+		for _, ref := range pod.OwnerReferences {
+			if ref.Kind == "PersistentVolumeClaim" {
+				return types.NamespacedName{Namespace: pod.Namespace, Name: ref.Name}, true
+			}
+		}
+	}
+	return types.NamespacedName{}, false
+}
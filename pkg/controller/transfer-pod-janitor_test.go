@@ -0,0 +1,174 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var transferPodJanitorLog = logf.Log.WithName("transfer-pod-janitor-test")
+
+var _ = Describe("Transfer pod janitor", func() {
+	const retention = 10 * time.Minute
+
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	exists := func(janitor *TransferPodJanitor, name types.NamespacedName, obj runtime.Object) bool {
+		err := janitor.Client.Get(context.TODO(), name, obj)
+		if err != nil {
+			Expect(IgnoreNotFound(err)).ToNot(HaveOccurred())
+			return false
+		}
+		return true
+	}
+
+	completePod := func(pod *corev1.Pod, finished time.Time) *corev1.Pod {
+		pod.CreationTimestamp = metav1.NewTime(finished.Add(-time.Hour))
+		pod.Status.Phase = corev1.PodSucceeded
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(finished)},
+				},
+			},
+		}
+		return pod
+	}
+
+	It("Should delete an importer pod that succeeded longer than the retention ago", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		pod := completePod(createImporterTestPod(pvc, "testPvc1", nil), now.Add(-2*retention))
+		janitor := createTransferPodJanitor(retention, false, pvc, pod)
+		deleted := readCounter(completedTransferPodsDeleted)
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		Expect(exists(janitor, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(BeFalse())
+		Expect(readCounter(completedTransferPodsDeleted)).To(Equal(deleted + 1))
+	})
+
+	It("Should keep an importer pod that succeeded within the retention", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		pod := completePod(createImporterTestPod(pvc, "testPvc1", nil), now.Add(-retention/2))
+		janitor := createTransferPodJanitor(retention, false, pvc, pod)
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		Expect(exists(janitor, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(BeTrue())
+	})
+
+	It("Should keep an importer pod that did not succeed", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		pod := completePod(createImporterTestPod(pvc, "testPvc1", nil), now.Add(-2*retention))
+		pod.Status.Phase = corev1.PodFailed
+		janitor := createTransferPodJanitor(retention, false, pvc, pod)
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		Expect(exists(janitor, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(BeTrue())
+	})
+
+	It("Should keep completed pods without a retention", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		pod := completePod(createImporterTestPod(pvc, "testPvc1", nil), now.Add(-2*retention))
+		janitor := createTransferPodJanitor(0, false, pvc, pod)
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		Expect(exists(janitor, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(BeTrue())
+	})
+
+	It("Should delete an upload server pod and its service", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		pod := completePod(createUploadPod(pvc), now.Add(-2*retention))
+		service := createUploadService(pvc)
+		janitor := createTransferPodJanitor(retention, false, pvc, pod, service)
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		Expect(exists(janitor, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(BeFalse())
+		Expect(exists(janitor, types.NamespacedName{Namespace: service.Namespace, Name: service.Name}, &corev1.Service{})).To(BeFalse())
+	})
+
+	It("Should keep the end of the log in the PVC when logs are preserved", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", nil, nil)
+		pod := completePod(createJanitorSourcePod(pvc, now), now.Add(-2*retention))
+		janitor := createTransferPodJanitor(retention, true, pvc, pod)
+		log := append([]byte("head"), bytes.Repeat([]byte{'l'}, transferLogMaxBytes)...)
+		janitor.podLogs = func(p *corev1.Pod, lines int64) ([]byte, error) {
+			Expect(p.Name).To(Equal(pod.Name))
+			Expect(lines).To(BeEquivalentTo(transferLogTailLines))
+			return log, nil
+		}
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		Expect(exists(janitor, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(BeFalse())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		Expect(exists(janitor, types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, resPvc)).To(BeTrue())
+		Expect(resPvc.GetAnnotations()[AnnTransferLog]).To(Equal(string(bytes.Repeat([]byte{'l'}, transferLogMaxBytes))))
+	})
+
+	It("Should not look at the logs when they are not preserved", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: testEndPoint}, nil)
+		pod := completePod(createImporterTestPod(pvc, "testPvc1", nil), now.Add(-2*retention))
+		janitor := createTransferPodJanitor(retention, false, pvc, pod)
+		janitor.podLogs = func(*corev1.Pod, int64) ([]byte, error) {
+			Fail("logs should not be read")
+			return nil, nil
+		}
+		Expect(janitor.cleanupCompleted(now)).To(Succeed())
+		resPvc := &corev1.PersistentVolumeClaim{}
+		Expect(exists(janitor, types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}, resPvc)).To(BeTrue())
+		Expect(resPvc.GetAnnotations()).ToNot(HaveKey(AnnTransferLog))
+	})
+
+	It("Should keep a succeeded clone source pod from the clone janitor during the retention", func() {
+		pvc := createClonePvc("source-ns", "source", "default", "target", map[string]string{AnnCloneOf: "true"}, nil)
+		pod := createJanitorSourcePod(pvc, now.Add(-2*cloneJanitorGracePeriod))
+		pod.Status.Phase = corev1.PodSucceeded
+		config := createCDIConfig(common.ConfigName)
+		config.Spec.CompletedPodRetention = &metav1.Duration{Duration: retention}
+		janitor := createCloneJanitor(config, pvc, pod)
+		Expect(janitor.cleanupOrphans(now)).To(Succeed())
+		Expect(janitor.Client.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})).To(Succeed())
+	})
+})
+
+func createTransferPodJanitor(retention time.Duration, preserveLogs bool, objects ...runtime.Object) *TransferPodJanitor {
+	cdiv1.AddToScheme(scheme.Scheme)
+	config := createCDIConfig(common.ConfigName)
+	if retention > 0 {
+		config.Spec.CompletedPodRetention = &metav1.Duration{Duration: retention}
+	}
+	config.Spec.PreserveTransferLogs = preserveLogs
+	return &TransferPodJanitor{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, append(objects, config)...),
+		Log:    transferPodJanitorLog,
+		podLogs: func(*corev1.Pod, int64) ([]byte, error) {
+			return nil, nil
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// TransferWindowClosed provides a const to indicate a low priority transfer waits for a transfer window to open
+	TransferWindowClosed = "TransferWindowClosed"
+	// TransferWindowOpen provides a const to indicate a low priority transfer no longer waits for a transfer window
+	TransferWindowOpen = "TransferWindowOpen"
+
+	// transferWindowRetryInterval is how often a transfer waiting for a transfer window checks the windows again
+	transferWindowRetryInterval = time.Minute
+)
+
+// getTransferWindows returns the transfer windows the low priority transfers to pvc start in, nil if they start at
+// any time, and who set them. The AnnTransferWindows annotation of the namespace of pvc replaces the TransferWindows
+// of the CDIConfig.
+func getTransferWindows(c client.Client, k8sClient kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) ([]cdiv1.TransferWindow, string, error) {
+	ns, err := k8sClient.CoreV1().Namespaces().Get(pvc.Namespace, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, "", err
+	}
+	if err == nil {
+		if value, ok := ns.Annotations[AnnTransferWindows]; ok {
+			var windows []cdiv1.TransferWindow
+			if err := json.Unmarshal([]byte(value), &windows); err != nil {
+				return nil, "", errors.Wrapf(err, "invalid %s annotation of namespace %s", AnnTransferWindows, pvc.Namespace)
+			}
+			return windows, "namespace " + pvc.Namespace, nil
+		}
+	}
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		return nil, "", IgnoreNotFound(err)
+	}
+	return cdiconfig.Spec.TransferWindows, "the CDIConfig", nil
+}
+
+// transferWindowOpen returns true if t is in window. A window with invalid days or times never opens.
+func transferWindowOpen(window cdiv1.TransferWindow, t time.Time) bool {
+	start, err := util.ParseTimeOfDay(window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := util.ParseTimeOfDay(window.End)
+	if err != nil {
+		return false
+	}
+	days := make([]time.Weekday, 0, len(window.Days))
+	for _, value := range window.Days {
+		day, err := util.ParseWeekday(value)
+		if err != nil {
+			return false
+		}
+		days = append(days, day)
+	}
+	return util.InWeeklyWindow(t, days, start, end)
+}
+
+// checkTransferWindow returns why the low priority transfer to pvc cannot start before a transfer window opens, or
+// "" if it can start. Transfers of normal and high priority start at any time.
+func checkTransferWindow(c client.Client, k8sClient kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if getTransferPriority(pvc) != cdiv1.DataVolumePriorityLow {
+		return "", nil
+	}
+	windows, owner, err := getTransferWindows(c, k8sClient, pvc)
+	if err != nil || len(windows) == 0 {
+		return "", err
+	}
+	now := time.Now()
+	for _, window := range windows {
+		if transferWindowOpen(window, now) {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("Low priority transfers start in the transfer windows of %s, none is open", owner), nil
+}
+
+// waitForTransferWindow records in the AnnTransferWindowClosed annotation of pvc why its transfer pod is not created,
+// and returns when to check the transfer windows again
+func waitForTransferWindow(c client.Client, recorder record.EventRecorder, pvc *corev1.PersistentVolumeClaim, reason string) (reconcile.Result, error) {
+	if pvc.GetAnnotations()[AnnTransferWindowClosed] != reason {
+		if pvc.GetAnnotations() == nil {
+			pvc.SetAnnotations(make(map[string]string))
+		}
+		pvc.GetAnnotations()[AnnTransferWindowClosed] = reason
+		if err := c.Update(context.TODO(), pvc); err != nil {
+			return reconcile.Result{}, err
+		}
+		recorder.Event(pvc, corev1.EventTypeNormal, TransferWindowClosed, reason)
+	}
+	return reconcile.Result{RequeueAfter: transferWindowRetryInterval}, nil
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	conditions "github.com/openshift/custom-resource-status/conditions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Transfer windows", func() {
+	pvcName := types.NamespacedName{Name: "testPvc1", Namespace: "default"}
+	lowPriority := map[string]string{AnnEndpoint: testEndPoint, AnnPriority: string(cdiv1.DataVolumePriorityLow)}
+	// A whole day window three days from now is never open while the test runs
+	closedWindow := cdiv1.TransferWindow{Days: []string{time.Now().UTC().AddDate(0, 0, 3).Format("Mon")}, Start: "00:00", End: "00:00"}
+	openWindow := cdiv1.TransferWindow{Start: "00:00", End: "00:00"}
+
+	createConfig := func(windows ...cdiv1.TransferWindow) *cdiv1.CDIConfig {
+		cdiv1.AddToScheme(scheme.Scheme)
+		config := createCDIConfig(common.ConfigName)
+		config.Spec.TransferWindows = windows
+		return config
+	}
+
+	// 2020-06-01 is a Monday
+	table.DescribeTable("Should tell if the window is open", func(window cdiv1.TransferWindow, t time.Time, expected bool) {
+		Expect(transferWindowOpen(window, t)).To(Equal(expected))
+	},
+		table.Entry("at night", cdiv1.TransferWindow{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, time.Date(2020, 6, 6, 2, 0, 0, 0, time.UTC), true),
+		table.Entry("but not in the morning", cdiv1.TransferWindow{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, time.Date(2020, 6, 6, 7, 0, 0, 0, time.UTC), false),
+		table.Entry("all day on the weekend", cdiv1.TransferWindow{Days: []string{"Sat", "Sun"}, Start: "00:00", End: "00:00"}, time.Date(2020, 6, 7, 12, 0, 0, 0, time.UTC), true),
+		table.Entry("never with an invalid day", cdiv1.TransferWindow{Days: []string{"Sunday"}, Start: "00:00", End: "00:00"}, time.Date(2020, 6, 7, 12, 0, 0, 0, time.UTC), false),
+		table.Entry("never with an invalid time", cdiv1.TransferWindow{Start: "0:00 AM", End: "00:00"}, time.Date(2020, 6, 7, 12, 0, 0, 0, time.UTC), false),
+	)
+
+	It("Should prefer the transfer windows of the namespace", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{AnnTransferWindows: `[{"days":["Sat","Sun"],"start":"00:00","end":"00:00"}]`},
+		}}
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, createConfig(closedWindow))
+		windows, owner, err := getTransferWindows(c, k8sfake.NewSimpleClientset(ns), createPvc("testPvc1", "default", nil, nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(windows).To(Equal([]cdiv1.TransferWindow{{Days: []string{"Sat", "Sun"}, Start: "00:00", End: "00:00"}}))
+		Expect(owner).To(Equal("namespace default"))
+
+		windows, owner, err = getTransferWindows(c, k8sfake.NewSimpleClientset(), createPvc("testPvc1", "default", nil, nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(windows).To(Equal([]cdiv1.TransferWindow{closedWindow}))
+		Expect(owner).To(Equal("the CDIConfig"))
+	})
+
+	It("Should refuse invalid transfer windows of the namespace", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{AnnTransferWindows: "Sat 00:00-06:00"},
+		}}
+		_, _, err := getTransferWindows(fake.NewFakeClientWithScheme(scheme.Scheme), k8sfake.NewSimpleClientset(ns), createPvc("testPvc1", "default", nil, nil))
+		Expect(err).To(HaveOccurred())
+	})
+
+	table.DescribeTable("Should hold low priority transfers outside of the windows", func(priority cdiv1.DataVolumePriority, windows []cdiv1.TransferWindow, held bool) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, createConfig(windows...))
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnPriority: string(priority)}, nil)
+		reason, err := checkTransferWindow(c, k8sfake.NewSimpleClientset(), pvc)
+		Expect(err).ToNot(HaveOccurred())
+		if held {
+			Expect(reason).To(Equal("Low priority transfers start in the transfer windows of the CDIConfig, none is open"))
+		} else {
+			Expect(reason).To(BeEmpty())
+		}
+	},
+		table.Entry("when no window is open", cdiv1.DataVolumePriorityLow, []cdiv1.TransferWindow{closedWindow}, true),
+		table.Entry("but not when a window is open", cdiv1.DataVolumePriorityLow, []cdiv1.TransferWindow{closedWindow, openWindow}, false),
+		table.Entry("but not without windows", cdiv1.DataVolumePriorityLow, nil, false),
+		table.Entry("but not for normal priority", cdiv1.DataVolumePriorityNormal, []cdiv1.TransferWindow{closedWindow}, false),
+	)
+
+	It("Should hold the importer pod until a transfer window opens", func() {
+		reconciler := createImportReconciler(createPvc("testPvc1", "default", lowPriority, nil))
+		config := &cdiv1.CDIConfig{}
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, config)).To(Succeed())
+		config.Spec.TransferWindows = []cdiv1.TransferWindow{closedWindow}
+		Expect(reconciler.Client.Update(context.TODO(), config)).To(Succeed())
+
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(transferWindowRetryInterval))
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()).To(HaveKey(AnnTransferWindowClosed))
+		Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(TransferWindowClosed))
+
+		By("Creating the pod once a window is open")
+		config.Spec.TransferWindows = append(config.Spec.TransferWindows, openWindow)
+		Expect(reconciler.Client.Update(context.TODO(), config)).To(Succeed())
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "importer-testPvc1", Namespace: "default"}, &corev1.Pod{})).To(Succeed())
+		_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: pvcName})
+		Expect(err).ToNot(HaveOccurred())
+		pvc = &corev1.PersistentVolumeClaim{}
+		Expect(reconciler.Client.Get(context.TODO(), pvcName, pvc)).To(Succeed())
+		Expect(pvc.GetAnnotations()).ToNot(HaveKey(AnnTransferWindowClosed))
+	})
+
+	It("Should reflect the closed window in the WaitingForTransferWindow condition of the data volume", func() {
+		dv := newImportDataVolume("test-dv")
+		reason := "Low priority transfers start in the transfer windows of the CDIConfig, none is open"
+		pvc := createPvc("test-dv", "default", map[string]string{AnnTransferWindowClosed: reason}, nil)
+		propagateClaimConditions(dv, pvc)
+		condition := conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeWaitingForTransferWindow)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(TransferWindowClosed))
+
+		delete(pvc.Annotations, AnnTransferWindowClosed)
+		propagateClaimConditions(dv, pvc)
+		condition = conditions.FindStatusCondition(dv.Status.Conditions, cdiv1.DataVolumeWaitingForTransferWindow)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(TransferWindowOpen))
+	})
+})
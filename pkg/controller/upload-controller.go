@@ -19,8 +19,11 @@ package controller
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"path"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -41,8 +44,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	cdiclientset "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned"
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/generator"
 )
@@ -60,8 +65,14 @@ const (
 
 	uploadServerCertDuration = 365 * 24 * time.Hour
 
+	// uploadServerTrustDomain is the trust domain of the identities of the upload servers
+	uploadServerTrustDomain = "upload-server.cdi.kubevirt.io"
+
 	// UploadSucceededPVC provides a const to indicate an import to the PVC failed
 	UploadSucceededPVC = "UploadSucceeded"
+
+	// DestinationNotWritable provides a const to indicate the upload server could not write to the PVC
+	DestinationNotWritable = "DestinationNotWritable"
 )
 
 // UploadReconciler members
@@ -86,12 +97,17 @@ type UploadPodArgs struct {
 	PVC                             *v1.PersistentVolumeClaim
 	ScratchPVCName                  string
 	ClientName                      string
+	PriorityClassName               string
+	Verification                    cdiv1.DataVolumeVerification
+	Timeouts                        util.ServerTimeouts
+	ContentEncodings                []string
+	MaxConcurrentRequests           int32
 	ServerCert, ServerKey, ClientCA []byte
 }
 
 // Reconcile the reconcile loop for the CDIConfig object.
 func (r *UploadReconciler) Reconcile(req reconcile.Request) (reconcile.Result, error) {
-	log := r.Log.WithValues("PVC", req.NamespacedName)
+	log := r.Log.WithValues(logKeyPVC, req.NamespacedName)
 	log.V(1).Info("reconciling Upload PVCs")
 
 	// Get the PVC.
@@ -103,6 +119,11 @@ func (r *UploadReconciler) Reconcile(req reconcile.Request) (reconcile.Result, e
 		return reconcile.Result{}, err
 	}
 
+	if isForceCleanup(pvc) {
+		log.V(1).Info("Forcing the cleanup of the PVC")
+		return reconcile.Result{}, r.forceCleanup(pvc)
+	}
+
 	_, isUpload := pvc.Annotations[AnnUploadRequest]
 	_, isCloneTarget := pvc.Annotations[AnnCloneRequest]
 
@@ -112,9 +133,19 @@ func (r *UploadReconciler) Reconcile(req reconcile.Request) (reconcile.Result, e
 	}
 
 	// force cleanup if PVC pending delete and pod running or the upload/clone annotation was removed
-	if (!isUpload && !isCloneTarget) || podSucceededFromPVC(pvc) || pvc.DeletionTimestamp != nil {
+	if (!isUpload && !isCloneTarget) || podSucceededFromPVC(pvc) || populationPhaseOf(pvc) == populationSucceeded || pvc.DeletionTimestamp != nil {
 		log.V(1).Info("not doing anything with PVC", "isUpload", isUpload, "isCloneTarget", isCloneTarget, "podSucceededFromPVC",
 			podSucceededFromPVC(pvc), "deletionTimeStamp set?", pvc.DeletionTimestamp != nil)
+		if (isUpload || isCloneTarget) && pvc.DeletionTimestamp == nil {
+			retention, _, err := getCompletedPodRetention(r.Client)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if retention > 0 {
+				// The transfer pod janitor deletes the upload server and its service once the retention is over
+				return reconcile.Result{}, nil
+			}
+		}
 		if err := r.cleanup(pvc); err != nil {
 			return reconcile.Result{}, err
 		}
@@ -129,6 +160,12 @@ func (r *UploadReconciler) reconcilePVC(log logr.Logger, pvc *corev1.PersistentV
 	var uploadClientName, scratchPVCName string
 	pvcCopy := pvc.DeepCopy()
 
+	if isTransferTimedOut(pvc) {
+		// The clone controller deleted the upload server of the clone target and marked the PVC failed
+		log.V(1).Info("Clone ran longer than its deadline too often, not retrying")
+		return reconcile.Result{}, nil
+	}
+
 	if isCloneTarget {
 		source, err := r.getCloneRequestSourcePVC(pvc)
 		if err != nil {
@@ -144,22 +181,100 @@ func (r *UploadReconciler) reconcilePVC(log logr.Logger, pvc *corev1.PersistentV
 		pvcCopy.Annotations[AnnUploadClientName] = uploadClientName
 	} else {
 		uploadClientName = uploadServerClientName
+		if clientName, ok := pvc.Annotations[AnnUploadClientName]; ok {
+			// The upload server of a fan-out target only accepts the importer pod of its import
+			uploadClientName = clientName
+		}
 
-		// TODO revisit naming, could overflow
-		scratchPVCName = pvc.Name + "-scratch"
+		// A block volume needs no scratch space, the upload server converts a qcow2 image while it is uploaded. State
+		// blobs are written as they are.
+		if getVolumeMode(pvc) != corev1.PersistentVolumeBlock && !isStateUpload(pvc) {
+			// TODO revisit naming, could overflow
+			scratchPVCName = pvc.Name + "-scratch"
+		}
 	}
 
 	resourceName := getUploadResourceName(pvc.Name)
 
-	pod, err := r.getOrCreateUploadPod(pvc, resourceName, scratchPVCName, uploadClientName)
+	pod, err := r.findUploadPod(pvc, resourceName)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if pod != nil && !isCloneTarget && pod.Status.Phase != corev1.PodSucceeded && uploadPodClientName(pod) != uploadClientName {
+		// The upload target became a fan-out target, a new upload server accepts the importer pod instead
+		log.V(1).Info("Deleting upload pod accepting another client", "clientName", uploadPodClientName(pod))
+		if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{Requeue: true}, nil
+	}
+	if pod == nil {
+		if populationPhaseOf(pvc) == populationRunning {
+			// The upload server is gone, the one created next is a new attempt
+			setUploadPopulationPhase(log, pvcCopy, populationPending, isCloneTarget)
+		}
+		sourceType := sourceUpload
+		if isCloneTarget {
+			sourceType = sourcePVC
+		}
+		reason, err := checkSourcePolicy(r.K8sClient, pvc, sourceType, nil)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if reason != "" {
+			log.V(1).Info("Source is not allowed", "reason", reason)
+			return rejectSource(r.recorder, pvc, reason)
+		}
+		if isCloneTarget {
+			// Uploads wait for the user, only clones wait for a transfer window
+			reason, err = checkTransferWindow(r.Client, r.K8sClient, pvc)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if reason != "" {
+				log.V(1).Info("Upload pod waits for a transfer window", "reason", reason)
+				return waitForTransferWindow(r.Client, r.recorder, pvcCopy, reason)
+			}
+		}
+		reason, err = checkTransferQuota(r.Client, pvc, scratchPVCName != "")
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if reason != "" {
+			log.V(1).Info("Upload pod is queued", "reason", reason)
+			setUploadPopulationPhase(log, pvcCopy, populationQueued, isCloneTarget)
+			return queueTransfer(r.Client, r.recorder, pvcCopy, reason)
+		}
+		reason, err = checkPinnedNode(r.Client, pvc)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if reason != "" {
+			log.V(1).Info("Upload pod waits for its node", "reason", reason)
+			return waitForNode(r.Client, r.recorder, pvcCopy, reason)
+		}
+	}
+
+	created := pod == nil
+	pod, err = r.getOrCreateUploadPod(pvc, resourceName, scratchPVCName, uploadClientName)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if created || !isCloneTarget {
+		// Once the upload server of a clone target exists, the clone controller queues the clone source pod
+		delete(pvcCopy.Annotations, AnnPodQueued)
+	}
+	delete(pvcCopy.Annotations, AnnNodeUnschedulable)
+	delete(pvcCopy.Annotations, AnnTransferWindowClosed)
 
 	if _, err = r.getOrCreateUploadService(pvc, resourceName); err != nil {
 		return reconcile.Result{}, err
 	}
 
+	if err := reconcileDisruptionBudget(r.Client, pvc, pod); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	podPhase := pod.Status.Phase
 	pvcCopy.Annotations[AnnPodPhase] = string(podPhase)
 	pvcCopy.Annotations[AnnPodReady] = strconv.FormatBool(isPodReady(pod))
@@ -174,6 +289,28 @@ func (r *UploadReconciler) reconcilePVC(log logr.Logger, pvc *corev1.PersistentV
 		}
 	}
 
+	if message, ok := destinationNotWritableMessage(pod); ok && pvcCopy.Annotations[AnnDestinationNotWritable] != message {
+		pvcCopy.Annotations[AnnDestinationNotWritable] = message
+		r.recorder.Event(pvc, corev1.EventTypeWarning, DestinationNotWritable, message)
+	} else if podPhase == corev1.PodSucceeded {
+		delete(pvcCopy.Annotations, AnnDestinationNotWritable)
+	}
+	updateContentDigest(pod, pvcCopy.Annotations)
+	updatePartialWriteAnnotation(pvc, pod, pvcCopy.Annotations)
+	updateTransferFailure(pod, pvcCopy.Annotations)
+	if isCloneTarget {
+		updateCloneVerification(r.recorder, pvc, pod, pvcCopy.Annotations)
+	}
+
+	switch podPhase {
+	case corev1.PodSucceeded:
+		setUploadPopulationPhase(log, pvcCopy, populationSucceeded, isCloneTarget)
+	case corev1.PodFailed:
+		setUploadPopulationPhase(log, pvcCopy, populationFailed, isCloneTarget)
+	default:
+		setUploadPopulationPhase(log, pvcCopy, populationRunning, isCloneTarget)
+	}
+
 	if !reflect.DeepEqual(pvc, pvcCopy) {
 		if err := r.updatePVC(pvcCopy); err != nil {
 			return reconcile.Result{}, err
@@ -187,8 +324,29 @@ func (r *UploadReconciler) reconcilePVC(log logr.Logger, pvc *corev1.PersistentV
 	return reconcile.Result{}, nil
 }
 
+// setUploadPopulationPhase records the population phase of an upload PVC, the clone controller records it for
+// clone targets
+func setUploadPopulationPhase(log logr.Logger, pvc *corev1.PersistentVolumeClaim, phase populationPhase, isCloneTarget bool) {
+	if isCloneTarget {
+		return
+	}
+	if err := transitionPopulation(pvc, phase, populationStrategyUpload); err != nil {
+		log.Error(err, "Not recording the population phase")
+	}
+}
+
+// destinationNotWritableMessage returns the termination message of the upload server in pod if it exited because
+// it could not write to the PVC
+func destinationNotWritableMessage(pod *corev1.Pod) (string, bool) {
+	if message, ok := failedTerminationMessage(pod, util.TerminationErrorDestinationNotWritable); ok {
+		return message.Message, true
+	}
+	return "", false
+}
+
 func (r *UploadReconciler) updatePVC(pvc *corev1.PersistentVolumeClaim) error {
 	r.Log.V(1).Info("Phase is now", "pvc.anno.Phase", pvc.GetAnnotations()[AnnPodPhase])
+	publishClaimConditions(pvc, "")
 	if err := r.Client.Update(context.TODO(), pvc); err != nil {
 		return err
 	}
@@ -196,9 +354,6 @@ func (r *UploadReconciler) updatePVC(pvc *corev1.PersistentVolumeClaim) error {
 }
 
 func (r *UploadReconciler) getCloneRequestSourcePVC(targetPvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
-	sourceVolumeMode := corev1.PersistentVolumeFilesystem
-	targetVolumeMode := corev1.PersistentVolumeFilesystem
-
 	exists, namespace, name := ParseCloneRequestAnnotation(targetPvc)
 	if !exists {
 		return nil, errors.New("error parsing clone request annotation")
@@ -207,15 +362,6 @@ func (r *UploadReconciler) getCloneRequestSourcePVC(targetPvc *corev1.Persistent
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, sourcePvc); err != nil {
 		return nil, errors.Wrap(err, "error getting clone source PVC")
 	}
-	if sourcePvc.Spec.VolumeMode != nil {
-		sourceVolumeMode = *sourcePvc.Spec.VolumeMode
-	}
-	if targetPvc.Spec.VolumeMode != nil {
-		targetVolumeMode = *targetPvc.Spec.VolumeMode
-	}
-	if sourceVolumeMode != targetVolumeMode {
-		return nil, errors.New("Source and target volume Modes do not match")
-	}
 	return sourcePvc, nil
 }
 
@@ -229,20 +375,59 @@ func (r *UploadReconciler) cleanup(pvc *v1.PersistentVolumeClaim) error {
 
 	// delete pod
 	pod := &corev1.Pod{}
-	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: resourceName, Namespace: pvc.Namespace}, pod); err != nil {
-		if k8serrors.IsNotFound(err) {
-			return nil
-		}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: resourceName, Namespace: pvc.Namespace}, pod); IgnoreNotFound(err) != nil {
 		return err
-	}
-	if pod.DeletionTimestamp == nil {
+	} else if err == nil && pod.DeletionTimestamp == nil {
 		if err := r.Client.Delete(context.TODO(), pod); IgnoreNotFound(err) != nil {
 			return err
 		}
 	}
+	if pvc.DeletionTimestamp != nil {
+		// release the scratch space of an upload that was interrupted
+		return releaseScratchPvc(r.Client, pvc)
+	}
 	return nil
 }
 
+// forceCleanup removes the upload server, its service and the scratch space of pvc
+func (r *UploadReconciler) forceCleanup(pvc *corev1.PersistentVolumeClaim) error {
+	cleanup := newForceCleanup(r.Client, pvc)
+	name := types.NamespacedName{Namespace: pvc.Namespace, Name: getUploadResourceName(pvc.Name)}
+	pod := &corev1.Pod{}
+	cleanup.delete("pod", name, pod, func() bool {
+		return metav1.IsControlledBy(pod, pvc)
+	})
+	service := &corev1.Service{}
+	cleanup.delete("service", name, service, func() bool {
+		return metav1.IsControlledBy(service, pvc)
+	})
+	cleanup.deleteScratchPvc()
+	return cleanup.done(r.recorder)
+}
+
+func (r *UploadReconciler) findUploadPod(pvc *v1.PersistentVolumeClaim, podName string) (*v1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: pvc.Namespace}, pod); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "error getting upload pod %s/%s", pvc.Namespace, podName)
+	}
+	return pod, nil
+}
+
+// uploadPodClientName returns the name of the client certificate the upload server of pod accepts
+func uploadPodClientName(pod *corev1.Pod) string {
+	for _, container := range pod.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "CLIENT_NAME" {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
 func (r *UploadReconciler) getOrCreateUploadPod(pvc *v1.PersistentVolumeClaim, podName, scratchPVCName, clientName string) (*v1.Pod, error) {
 	pod := &corev1.Pod{}
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: podName, Namespace: pvc.Namespace}, pod); err != nil {
@@ -250,7 +435,7 @@ func (r *UploadReconciler) getOrCreateUploadPod(pvc *v1.PersistentVolumeClaim, p
 			return nil, errors.Wrapf(err, "error getting upload pod %s/%s", pvc.Namespace, podName)
 		}
 
-		serverCert, serverKey, err := r.serverCertGenerator.MakeServerCert(pvc.Namespace, podName, uploadServerCertDuration)
+		serverCert, serverKey, err := r.serverCertGenerator.MakeServerCert(pvc.Namespace, podName, uploadServerCertDuration, UploadServerIdentity(pvc.Namespace, pvc.Name))
 		if err != nil {
 			return nil, err
 		}
@@ -260,14 +445,44 @@ func (r *UploadReconciler) getOrCreateUploadPod(pvc *v1.PersistentVolumeClaim, p
 			return nil, err
 		}
 
+		priorityClassName, err := getPriorityClassName(r.K8sClient, pvc)
+		if err != nil {
+			return nil, err
+		}
+
+		verification, err := getVerification(r.Client, pvc)
+		if err != nil {
+			return nil, err
+		}
+
+		timeouts, err := getUploadTimeouts(r.Client)
+		if err != nil {
+			return nil, err
+		}
+
+		contentEncodings, err := getUploadContentEncodings(r.Client)
+		if err != nil {
+			return nil, err
+		}
+
+		maxConcurrentRequests, err := getUploadMaxConcurrentRequests(r.Client)
+		if err != nil {
+			return nil, err
+		}
+
 		args := UploadPodArgs{
-			Name:           podName,
-			PVC:            pvc,
-			ScratchPVCName: scratchPVCName,
-			ClientName:     clientName,
-			ServerCert:     serverCert,
-			ServerKey:      serverKey,
-			ClientCA:       clientCA,
+			Name:                  podName,
+			PVC:                   pvc,
+			ScratchPVCName:        scratchPVCName,
+			ClientName:            clientName,
+			PriorityClassName:     priorityClassName,
+			Verification:          verification,
+			Timeouts:              UploadServerTimeouts(timeouts),
+			ContentEncodings:      contentEncodings,
+			MaxConcurrentRequests: maxConcurrentRequests,
+			ServerCert:            serverCert,
+			ServerKey:             serverKey,
+			ClientCA:              clientCA,
 		}
 
 		r.Log.V(3).Info("Creating upload pod")
@@ -300,9 +515,17 @@ func (r *UploadReconciler) getOrCreateScratchPvc(pvc *v1.PersistentVolumeClaim,
 		}
 
 		storageClassName := GetScratchPvcStorageClass(r.K8sClient, r.CdiClient, pvc)
+		maxSize, err := getScratchSpaceMaxSize(r.Client)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := getScratchSpacePool(r.Client)
+		if err != nil {
+			return nil, err
+		}
 
 		// Scratch PVC doesn't exist yet, create it.
-		scratchPvc, err = CreateScratchPersistentVolumeClaim(r.K8sClient, pvc, pod, name, storageClassName)
+		scratchPvc, err = CreateScratchPersistentVolumeClaim(r.K8sClient, pvc, pod, name, storageClassName, maxSize, pool)
 		if err != nil {
 			return nil, err
 		}
@@ -429,17 +652,27 @@ func (r *UploadReconciler) createUploadPod(args UploadPodArgs) (*v1.Pod, error)
 	}
 
 	pod := r.makeUploadPodSpec(args, podResourceRequirements)
+	if err := setServiceMeshAnnotations(r.Client, pod); err != nil {
+		return nil, err
+	}
+	if err := setSecurityProfiles(r.Client, pod, uploadServerSeccompProfile); err != nil {
+		return nil, err
+	}
+	if err := setVolumeNodeAffinity(r.Client, args.PVC, pod); err != nil {
+		return nil, err
+	}
+	setPinnedNodeAffinity(args.PVC, pod)
 
 	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: args.Name, Namespace: ns}, pod); err != nil {
 		if !k8serrors.IsNotFound(err) {
 			return nil, errors.Wrap(err, "upload pod should exist but couldn't retrieve it")
 		}
-		if err := r.Client.Create(context.TODO(), pod); err != nil {
+		if pod, err = createPodIfNotExists(r.Client, pod); err != nil {
 			return nil, err
 		}
 	}
 
-	r.Log.V(1).Info("upload pod created\n", "Namespace", pod.Namespace, "Name", pod.Name, "Image name", r.Image)
+	r.Log.V(1).Info("upload pod created", logKeyPod, logObjectKey(pod), "image", r.Image)
 	return pod, nil
 }
 
@@ -450,7 +683,7 @@ func NewUploadController(mgr manager.Manager, cdiClient *cdiclientset.Clientset,
 		Scheme:              mgr.GetScheme(),
 		CdiClient:           cdiClient,
 		K8sClient:           k8sClient,
-		Log:                 log.WithName("upload-controller"),
+		Log:                 newSampledLogger(log.WithName("upload-controller")),
 		Image:               uploadImage,
 		Verbose:             verbose,
 		PullPolicy:          pullPolicy,
@@ -492,6 +725,11 @@ func addUploadControllerWatches(mgr manager.Manager, importController controller
 	return nil
 }
 
+// isStateUpload returns true if a blob of VM state is uploaded to pvc, which is written to the volume as it is
+func isStateUpload(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.GetAnnotations()[AnnContentType] == string(cdiv1.DataVolumeKubeVirtState)
+}
+
 // getUploadResourceName returns the name given to upload resources
 func getUploadResourceName(name string) string {
 	// TODO revisit naming, could overflow
@@ -506,6 +744,16 @@ func UploadPossibleForPVC(pvc *v1.PersistentVolumeClaim) error {
 	return nil
 }
 
+// UploadServerIdentity returns the identity of the upload server of a particular pvc, the URI SAN of its certificate.
+// The proxy checks it, so an upload cannot reach the server of another pvc even if the Service of the server is changed.
+func UploadServerIdentity(namespace, pvc string) *url.URL {
+	return &url.URL{
+		Scheme: "spiffe",
+		Host:   uploadServerTrustDomain,
+		Path:   path.Join("/ns", namespace, "pvc", pvc),
+	}
+}
+
 // GetUploadServerURL returns the url the proxy should post to for a particular pvc
 func GetUploadServerURL(namespace, pvc, uploadPath string) string {
 	return fmt.Sprintf("https://%s.%s.svc%s", getUploadResourceName(pvc), namespace, uploadPath)
@@ -540,9 +788,10 @@ func (r *UploadReconciler) makeUploadPodSpec(args UploadPodArgs, resourceRequire
 			},
 			Containers: []v1.Container{
 				{
-					Name:            common.UploadServerPodname,
-					Image:           r.Image,
-					ImagePullPolicy: v1.PullPolicy(r.PullPolicy),
+					Name:                     common.UploadServerPodname,
+					Image:                    r.Image,
+					ImagePullPolicy:          v1.PullPolicy(r.PullPolicy),
+					TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 					Env: []v1.EnvVar{
 						{
 							Name:  "TLS_KEY",
@@ -564,6 +813,14 @@ func (r *UploadReconciler) makeUploadPodSpec(args UploadPodArgs, resourceRequire
 							Name:  "CLIENT_NAME",
 							Value: args.ClientName,
 						},
+						{
+							Name:  common.UploadVerification,
+							Value: string(args.Verification),
+						},
+						{
+							Name:  common.UploadBlockWipe,
+							Value: args.PVC.GetAnnotations()[AnnBlockWipe],
+						},
 					},
 					Args: []string{"-v=" + r.Verbose},
 					ReadinessProbe: &v1.Probe{
@@ -581,7 +838,8 @@ func (r *UploadReconciler) makeUploadPodSpec(args UploadPodArgs, resourceRequire
 					},
 				},
 			},
-			RestartPolicy: v1.RestartPolicyOnFailure,
+			RestartPolicy:     v1.RestartPolicyOnFailure,
+			PriorityClassName: args.PriorityClassName,
 			Volumes: []v1.Volume{
 				{
 					Name: DataVolName,
@@ -598,12 +856,53 @@ func (r *UploadReconciler) makeUploadPodSpec(args UploadPodArgs, resourceRequire
 
 	if !checkPVC(args.PVC, AnnCloneRequest) {
 		pod.Spec.SecurityContext.FSGroup = &fsGroup
+
+		// The heartbeat of a clone comes from its source pod
+		ownerUID := args.PVC.UID
+		if len(args.PVC.OwnerReferences) == 1 {
+			ownerUID = args.PVC.OwnerReferences[0].UID
+		}
+		pod.Labels[common.PrometheusLabel] = ""
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, v1.EnvVar{
+			Name:  common.OwnerUID,
+			Value: string(ownerUID),
+		})
+		pod.Spec.Containers[0].Ports = []v1.ContainerPort{
+			{
+				Name:          "metrics",
+				ContainerPort: 8444,
+				Protocol:      v1.ProtocolTCP,
+			},
+		}
 	}
 
 	if resourceRequirements != nil {
 		pod.Spec.Containers[0].Resources = *resourceRequirements
 	}
 
+	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, uploadTimeoutsEnv(args.Timeouts)...)
+	if len(args.ContentEncodings) > 0 {
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, v1.EnvVar{
+			Name:  common.UploadContentEncodings,
+			Value: strings.Join(args.ContentEncodings, ","),
+		})
+	}
+	if isStateUpload(args.PVC) {
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, v1.EnvVar{
+			Name:  common.UploadContentType,
+			Value: string(cdiv1.DataVolumeKubeVirtState),
+		}, v1.EnvVar{
+			Name:  common.UploadChecksum,
+			Value: args.PVC.GetAnnotations()[AnnChecksum],
+		})
+	}
+	if args.MaxConcurrentRequests > 0 {
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, v1.EnvVar{
+			Name:  common.UploadMaxConcurrentRequests,
+			Value: strconv.Itoa(int(args.MaxConcurrentRequests)),
+		})
+	}
+
 	if getVolumeMode(args.PVC) == v1.PersistentVolumeBlock {
 		pod.Spec.Containers[0].VolumeDevices = []v1.VolumeDevice{
 			{
@@ -622,6 +921,12 @@ func (r *UploadReconciler) makeUploadPodSpec(args UploadPodArgs, resourceRequire
 				MountPath: common.UploadServerDataDir,
 			},
 		}
+		if imageName := args.PVC.GetAnnotations()[AnnImageName]; imageName != "" {
+			pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, v1.EnvVar{
+				Name:  "DESTINATION",
+				Value: path.Join(common.UploadServerDataDir, imageName),
+			})
+		}
 	}
 
 	if args.ScratchPVCName != "" {
@@ -641,5 +946,31 @@ func (r *UploadReconciler) makeUploadPodSpec(args UploadPodArgs, resourceRequire
 		})
 	}
 
+	setReadOnlyRootFilesystem(pod)
 	return pod
 }
+
+// getUploadContentEncodings returns the UploadContentEncodings of the CDIConfig, the upload servers decompress
+func getUploadContentEncodings(c client.Client) ([]string, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cdiconfig.Spec.UploadContentEncodings, nil
+}
+
+// getUploadMaxConcurrentRequests returns the UploadMaxConcurrentRequests of the CDIConfig, 0 for the default of the
+// upload servers
+func getUploadMaxConcurrentRequests(c client.Client) (int32, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return cdiconfig.Spec.UploadMaxConcurrentRequests, nil
+}
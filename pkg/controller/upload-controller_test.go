@@ -29,12 +29,15 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 
 	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	cdifake "kubevirt.io/containerized-data-importer/pkg/client/clientset/versioned/fake"
@@ -156,16 +159,42 @@ var _ = Describe("Upload controller reconcile loop", func() {
 
 	})
 
+	It("Should not create a pod if the recorded population succeeded", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: "", AnnPopulationPhase: string(populationSucceeded)}, nil)
+		reconciler := createUploadReconciler(testPvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		podList := &corev1.PodList{}
+		err = reconciler.Client.List(context.TODO(), podList, &client.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(podList.Items).To(BeEmpty())
+	})
+
+	It("Should record the population of an upload", func() {
+		testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		reconciler := createUploadReconciler(testPvc)
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
+		Expect(err).ToNot(HaveOccurred())
+		resultPvc := &corev1.PersistentVolumeClaim{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resultPvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resultPvc.GetAnnotations()[AnnPopulationPhase]).To(Equal(string(populationRunning)))
+		Expect(resultPvc.GetAnnotations()[AnnPopulationStrategy]).To(Equal(populationStrategyUpload))
+		Expect(resultPvc.GetAnnotations()[AnnPopulationAttempts]).To(Equal("1"))
+	})
+
 	It("Should return nil and remove any service and pod if pvc marked for deletion", func() {
 		testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: "", AnnPodPhase: string(corev1.PodPending)}, nil)
 		now := metav1.NewTime(time.Now())
 		testPvc.DeletionTimestamp = &now
+		uploadPod := createUploadPod(testPvc)
 		reconciler := createUploadReconciler(testPvc,
-			createUploadPod(testPvc),
+			uploadPod,
 			createUploadService(testPvc),
+			newScratchPersistentVolumeClaimSpec(testPvc, uploadPod, scratchNameFromPvc(testPvc), "", nil),
 		)
 		By("Verifying the pod and service exists")
-		uploadPod := &corev1.Pod{}
+		uploadPod = &corev1.Pod{}
 		err := reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(uploadPod.Name).To(Equal(getUploadResourceName(testPvc.Name)))
@@ -188,19 +217,23 @@ var _ = Describe("Upload controller reconcile loop", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(len(serviceList.Items)).To(Equal(0))
 
+		By("Verifying the scratch space was released")
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: scratchNameFromPvc(testPvc), Namespace: "default"}, &corev1.PersistentVolumeClaim{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
 	})
 
-	It("Should return err and not clone if validation error occurs", func() {
+	It("Should not clone if validation error occurs", func() {
 		storageClassName := "test"
 		testPvc := createPvcInStorageClass("testPvc1", "default", &storageClassName, map[string]string{cloneRequestAnnotation: "default/sourcePvc"}, nil)
 		sourcePvc := createPvcInStorageClass("sourcePvc", "default", &storageClassName, nil, nil)
-		vm := corev1.PersistentVolumeBlock
-		sourcePvc.Spec.VolumeMode = &vm
+		sourcePvc.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("2G")
 		reconciler := createUploadReconciler(testPvc, sourcePvc)
 
 		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "testPvc1", Namespace: "default"}})
-		Expect(err).To(HaveOccurred())
-		Expect(err.Error()).To(ContainSubstring("Source and target volume Modes do not match"))
+		Expect(err).ToNot(HaveOccurred())
+		uploadPod := &corev1.Pod{}
+		err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
 	})
 
 	It("Should return nil and create a pod and service when a clone pvc", func() {
@@ -255,6 +288,7 @@ var _ = Describe("reconcilePVC loop", func() {
 			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(uploadPod.Name).To(Equal(getUploadResourceName(testPvc.Name)))
+			Expect(uploadPod.Labels).ToNot(HaveKey(common.PrometheusLabel))
 
 			uploadService = &corev1.Service{}
 			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadService)
@@ -272,6 +306,26 @@ var _ = Describe("reconcilePVC loop", func() {
 	Context("Is upload", func() {
 		isClone := false
 
+		It("Should not create the pod if the namespace does not allow uploads", func() {
+			testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+			reconciler := createUploadReconciler(testPvc)
+			_, err := reconciler.K8sClient.CoreV1().Namespaces().Create(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "default",
+					Annotations: map[string]string{AnnAllowedSources: "registry"},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			result, err := reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(quotaRetryInterval))
+			uploadPod := &corev1.Pod{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(err).To(HaveOccurred())
+			Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring("Source upload is not allowed in namespace default"))
+		})
+
 		It("Should create the service and pod", func() {
 			testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
 			reconciler := createUploadReconciler(testPvc)
@@ -291,6 +345,9 @@ var _ = Describe("reconcilePVC loop", func() {
 			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(uploadPod.Name).To(Equal(getUploadResourceName(testPvc.Name)))
+			By("Verifying the pod reports a heartbeat")
+			Expect(uploadPod.Labels).To(HaveKey(common.PrometheusLabel))
+			Expect(uploadPod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.OwnerUID, Value: string(testPvc.UID)}))
 
 			uploadService = &corev1.Service{}
 			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadService)
@@ -301,6 +358,143 @@ var _ = Describe("reconcilePVC loop", func() {
 			_, err = reconciler.K8sClient.CoreV1().PersistentVolumeClaims("default").Get("testPvc1-scratch", metav1.GetOptions{})
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("Should create no scratch PVC for a block volume", func() {
+			testPvc := createBlockPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+			reconciler := createUploadReconciler(testPvc)
+			_, err := reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			uploadPod := &corev1.Pod{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(err).ToNot(HaveOccurred())
+			for _, volume := range uploadPod.Spec.Volumes {
+				Expect(volume.Name).ToNot(Equal(ScratchVolName))
+			}
+			_, err = reconciler.K8sClient.CoreV1().PersistentVolumeClaims("default").Get("testPvc1-scratch", metav1.GetOptions{})
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("Should write a state blob without scratch space and verify it against its checksum", func() {
+			testPvc := createPvc("testPvc1", "default", map[string]string{
+				AnnUploadRequest: "",
+				AnnContentType:   string(cdiv1.DataVolumeKubeVirtState),
+				AnnChecksum:      "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			}, nil)
+			reconciler := createUploadReconciler(testPvc)
+			_, err := reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			uploadPod := &corev1.Pod{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uploadPod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.UploadContentType, Value: string(cdiv1.DataVolumeKubeVirtState)}))
+			Expect(uploadPod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.UploadChecksum, Value: testPvc.Annotations[AnnChecksum]}))
+			for _, volume := range uploadPod.Spec.Volumes {
+				Expect(volume.Name).ToNot(Equal(ScratchVolName))
+			}
+			_, err = reconciler.K8sClient.CoreV1().PersistentVolumeClaims("default").Get("testPvc1-scratch", metav1.GetOptions{})
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("Should pass the content encodings of the CDIConfig to the upload server", func() {
+			testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+			reconciler := createUploadReconciler(testPvc)
+			cdiConfig := &cdiv1.CDIConfig{}
+			err := reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiConfig)
+			Expect(err).ToNot(HaveOccurred())
+			cdiConfig.Spec.UploadContentEncodings = []string{"gzip"}
+			err = reconciler.Client.Update(context.TODO(), cdiConfig)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			uploadPod := &corev1.Pod{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uploadPod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.UploadContentEncodings, Value: "gzip"}))
+		})
+
+		It("Should pass the max concurrent requests of the CDIConfig to the upload server", func() {
+			testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+			reconciler := createUploadReconciler(testPvc)
+			cdiConfig := &cdiv1.CDIConfig{}
+			err := reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiConfig)
+			Expect(err).ToNot(HaveOccurred())
+			cdiConfig.Spec.UploadMaxConcurrentRequests = 8
+			err = reconciler.Client.Update(context.TODO(), cdiConfig)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			uploadPod := &corev1.Pod{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uploadPod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.UploadMaxConcurrentRequests, Value: "8"}))
+		})
+
+		It("Should report an upload server that can not write to the pvc", func() {
+			testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+			uploadPod := createUploadPod(testPvc)
+			uploadPod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: common.DestinationNotWritableExitCode,
+							Message:  "Destination /data is not writable: read-only file system",
+						},
+					},
+				},
+			}
+			reconciler := createUploadReconciler(testPvc, uploadPod)
+
+			_, err := reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			resultPvc := &corev1.PersistentVolumeClaim{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: "testPvc1", Namespace: "default"}, resultPvc)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resultPvc.GetAnnotations()[AnnDestinationNotWritable]).To(ContainSubstring("read-only file system"))
+			Expect(<-reconciler.recorder.(*record.FakeRecorder).Events).To(ContainSubstring(DestinationNotWritable))
+		})
+
+		It("Should restart the upload server of a fan-out target to accept the importer pod", func() {
+			clientName := "fan-out/default/vm-1-default/testPvc1"
+			testPvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: "", AnnUploadClientName: clientName}, nil)
+			reconciler := createUploadReconciler(testPvc, createUploadPod(testPvc))
+
+			By("Deleting the upload pod that accepts the upload proxy")
+			result, err := reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Requeue).To(BeTrue())
+			uploadPod := &corev1.Pod{}
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+
+			By("Creating an upload pod that accepts the importer pod")
+			_, err = reconciler.reconcilePVC(reconciler.Log, testPvc, isClone)
+			Expect(err).ToNot(HaveOccurred())
+			err = reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: getUploadResourceName("testPvc1"), Namespace: "default"}, uploadPod)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uploadPodClientName(uploadPod)).To(Equal(clientName))
+		})
+	})
+})
+
+var _ = Describe("State upload", func() {
+	It("Should annotate the PVC of a state upload with its content type and checksum", func() {
+		dv := newUploadDataVolume("test-dv")
+		dv.Spec.ContentType = cdiv1.DataVolumeKubeVirtState
+		dv.Spec.Source.Upload.Checksum = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations).To(HaveKeyWithValue(AnnContentType, string(cdiv1.DataVolumeKubeVirtState)))
+		Expect(pvc.Annotations).To(HaveKeyWithValue(AnnChecksum, dv.Spec.Source.Upload.Checksum))
+		Expect(isStateUpload(pvc)).To(BeTrue())
+	})
+
+	It("Should not annotate the PVC of a disk upload with a content type", func() {
+		pvc, err := newPersistentVolumeClaim(newUploadDataVolume("test-dv"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations).ToNot(HaveKey(AnnContentType))
+		Expect(isStateUpload(pvc)).To(BeFalse())
 	})
 })
 
@@ -401,6 +595,7 @@ func createUploadReconciler(objects ...runtime.Object) *UploadReconciler {
 		K8sClient:           k8sfakeclientset,
 		serverCertGenerator: &fakeCertGenerator{},
 		clientCAFetcher:     &fetcher.MemCertBundleFetcher{Bundle: []byte("baz")},
+		recorder:            record.NewFakeRecorder(10),
 	}
 	return r
 }
@@ -0,0 +1,142 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// uploadJanitorInterval is how often the janitor looks for orphaned upload services
+	uploadJanitorInterval = 5 * time.Minute
+	// uploadJanitorGracePeriod is the age an upload service needs before the janitor considers it, so it does not
+	// race the upload controller on a PVC that is not in the cache yet
+	uploadJanitorGracePeriod = time.Minute
+)
+
+var (
+	orphanedUploadServices = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cdi_upload_services_orphaned",
+			Help: "The number of upload server services whose PVC no longer exists, found by the last pass of the upload janitor",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedUploadServices)
+}
+
+// UploadJanitor periodically deletes upload server services whose PVC no longer exists. They are owned by their
+// PVC, but are left behind when the PVC is deleted without deleting its dependents, or when the garbage collector
+// does not get to them.
+type UploadJanitor struct {
+	Client client.Client
+	Log    logr.Logger
+}
+
+// NewUploadJanitor creates a new upload janitor and adds it to the manager.
+func NewUploadJanitor(mgr manager.Manager, log logr.Logger) (*UploadJanitor, error) {
+	janitor := &UploadJanitor{
+		Client: mgr.GetClient(),
+		Log:    log.WithName("upload-janitor"),
+	}
+	if err := mgr.Add(janitor); err != nil {
+		return nil, err
+	}
+	return janitor, nil
+}
+
+// Start runs the janitor, right away and then every uploadJanitorInterval, until stop is closed.
+func (j *UploadJanitor) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := j.cleanupOrphans(time.Now()); err != nil {
+			j.Log.Error(err, "Unable to clean up orphaned upload services")
+		}
+	}, uploadJanitorInterval, stop)
+	return nil
+}
+
+// cleanupOrphans deletes the upload services older than the grace period at now that are orphaned
+func (j *UploadJanitor) cleanupOrphans(now time.Time) error {
+	services := &corev1.ServiceList{}
+	selector := labels.SelectorFromSet(map[string]string{common.CDIComponentLabel: common.UploadServerCDILabel})
+	if err := j.Client.List(context.TODO(), services, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return err
+	}
+	orphans := 0
+	for i := range services.Items {
+		service := &services.Items[i]
+		if service.DeletionTimestamp != nil || now.Sub(service.CreationTimestamp.Time) < uploadJanitorGracePeriod {
+			continue
+		}
+		orphaned, err := j.isOrphaned(service)
+		if err != nil {
+			return err
+		}
+		if !orphaned {
+			continue
+		}
+		orphans++
+		j.Log.Info("Deleting orphaned upload service", "namespace", service.Namespace, "name", service.Name)
+		if err := j.Client.Delete(context.TODO(), service); IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	orphanedUploadServices.Set(float64(orphans))
+	return nil
+}
+
+// isOrphaned returns true if the PVC of an upload service no longer exists, or was replaced by one with the same
+// name. The PVC is the owner of the service, or, if the owner reference was removed, the PVC the service is named
+// after.
+func (j *UploadJanitor) isOrphaned(service *corev1.Service) (bool, error) {
+	if _, ok := service.Annotations[annCreatedByUpload]; !ok {
+		// Not created by the upload controller
+		return false, nil
+	}
+	var uid types.UID
+	name := strings.TrimPrefix(service.Name, getUploadResourceName(""))
+	for _, ref := range service.OwnerReferences {
+		if ref.Kind == "PersistentVolumeClaim" {
+			name, uid = ref.Name, ref.UID
+		}
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := j.Client.Get(context.TODO(), types.NamespacedName{Namespace: service.Namespace, Name: name}, pvc); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return uid != "" && pvc.UID != uid, nil
+}
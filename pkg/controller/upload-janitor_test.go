@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var uploadJanitorLog = logf.Log.WithName("upload-janitor-test")
+
+var _ = Describe("Upload janitor", func() {
+	now := time.Now()
+
+	createService := func(pvc *corev1.PersistentVolumeClaim) *corev1.Service {
+		service := createUploadService(pvc)
+		service.Labels[common.CDIComponentLabel] = common.UploadServerCDILabel
+		service.CreationTimestamp = metav1.NewTime(now.Add(-2 * uploadJanitorGracePeriod))
+		return service
+	}
+
+	serviceExists := func(j *UploadJanitor, name string) bool {
+		err := j.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, &corev1.Service{})
+		if err != nil {
+			Expect(IgnoreNotFound(err)).ToNot(HaveOccurred())
+			return false
+		}
+		return true
+	}
+
+	It("Should delete the service of a deleted PVC", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		j := createUploadJanitor(createService(pvc))
+		Expect(j.cleanupOrphans(now)).To(Succeed())
+		Expect(serviceExists(j, "cdi-upload-testPvc1")).To(BeFalse())
+		Expect(readGauge(orphanedUploadServices)).To(Equal(float64(1)))
+	})
+
+	It("Should delete the service of a recreated PVC", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		service := createService(pvc)
+		service.OwnerReferences[0].UID = "old-uid"
+		j := createUploadJanitor(pvc, service)
+		Expect(j.cleanupOrphans(now)).To(Succeed())
+		Expect(serviceExists(j, "cdi-upload-testPvc1")).To(BeFalse())
+	})
+
+	It("Should delete the service of a deleted PVC without an owner reference", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		service := createService(pvc)
+		service.OwnerReferences = nil
+		j := createUploadJanitor(service)
+		Expect(j.cleanupOrphans(now)).To(Succeed())
+		Expect(serviceExists(j, "cdi-upload-testPvc1")).To(BeFalse())
+	})
+
+	It("Should keep the service of an existing PVC", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		j := createUploadJanitor(pvc, createService(pvc))
+		Expect(j.cleanupOrphans(now)).To(Succeed())
+		Expect(serviceExists(j, "cdi-upload-testPvc1")).To(BeTrue())
+		Expect(readGauge(orphanedUploadServices)).To(BeZero())
+	})
+
+	It("Should keep a service within the grace period", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		service := createService(pvc)
+		service.CreationTimestamp = metav1.NewTime(now)
+		j := createUploadJanitor(service)
+		Expect(j.cleanupOrphans(now)).To(Succeed())
+		Expect(serviceExists(j, "cdi-upload-testPvc1")).To(BeTrue())
+	})
+
+	It("Should keep a service not created by the upload controller", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		service := createService(pvc)
+		service.Annotations = nil
+		j := createUploadJanitor(service)
+		Expect(j.cleanupOrphans(now)).To(Succeed())
+		Expect(serviceExists(j, "cdi-upload-testPvc1")).To(BeTrue())
+	})
+})
+
+func createUploadJanitor(objects ...runtime.Object) *UploadJanitor {
+	return &UploadJanitor{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		Log:    uploadJanitorLog,
+	}
+}
+
+func readGauge(gauge prometheus.Gauge) float64 {
+	metric := &dto.Metric{}
+	Expect(gauge.Write(metric)).To(Succeed())
+	return metric.GetGauge().GetValue()
+}
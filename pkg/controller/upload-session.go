@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/keys"
+)
+
+const (
+	// AnnUploadSession is the annotation of a PVC with the upload session to it, as JSON
+	AnnUploadSession = AnnAPIGroup + "/storage.upload.session"
+
+	// UploadSessionParam is the parameter of an upload token with the ID of its upload session
+	UploadSessionParam = "uploadSession"
+
+	// UploadSessionLifetime is how long an upload session, and the token for it, can be used. The token outlives no
+	// other token signed with the key, the previous key is kept for it after a rotation.
+	UploadSessionLifetime = keys.MaxTokenLifetime
+)
+
+// UploadSession is an upload to a PVC that can be resumed from its offset and sent over several connections. The
+// apiserver creates it with the token for it, the upload proxies record the progress the upload server reports, so
+// that any of them can tell a client where to resume.
+type UploadSession struct {
+	// ID identifies the session, a new session replaces the session of the PVC
+	ID string `json:"id"`
+	// Expires is when the session can no longer be used
+	Expires metav1.Time `json:"expires"`
+	// Offset is the offset up to which the upload server wrote the upload
+	Offset int64 `json:"offset"`
+	// Digest is the sha256 digest of the data up to Offset
+	Digest string `json:"digest,omitempty"`
+	// Complete is set once the upload server wrote the last chunk of the upload
+	Complete bool `json:"complete,omitempty"`
+}
+
+// Expired tells whether the session can no longer be used
+func (s *UploadSession) Expired() bool {
+	return !time.Now().Before(s.Expires.Time)
+}
+
+// GetUploadSession returns the upload session of pvc, nil if it has none
+func GetUploadSession(pvc *v1.PersistentVolumeClaim) (*UploadSession, error) {
+	value, ok := pvc.GetAnnotations()[AnnUploadSession]
+	if !ok {
+		return nil, nil
+	}
+	session := &UploadSession{}
+	if err := json.Unmarshal([]byte(value), session); err != nil {
+		return nil, errors.Wrapf(err, "Invalid upload session of PVC %s/%s", pvc.Namespace, pvc.Name)
+	}
+	return session, nil
+}
+
+// SetUploadSession sets the upload session of pvc
+func SetUploadSession(pvc *v1.PersistentVolumeClaim, session *UploadSession) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return errors.Wrap(err, "Error encoding upload session")
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = make(map[string]string)
+	}
+	pvc.Annotations[AnnUploadSession] = string(value)
+	return nil
+}
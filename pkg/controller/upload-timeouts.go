@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// defaultUploadProxyRequestTimeout is how long the upload proxy waits for an upload server to take an upload, unless
+// the CDIConfig sets another timeout
+const defaultUploadProxyRequestTimeout = 24 * time.Hour
+
+// getUploadTimeouts returns the UploadTimeouts of the CDIConfig, nil if there is none
+func getUploadTimeouts(c client.Client) (*cdiv1.UploadTimeouts, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cdiconfig.Spec.UploadTimeouts, nil
+}
+
+// UploadServerTimeouts returns the timeouts of the connections of the upload proxy and upload servers set by timeouts,
+// durations that are not positive are not set
+func UploadServerTimeouts(timeouts *cdiv1.UploadTimeouts) util.ServerTimeouts {
+	if timeouts == nil {
+		return util.ServerTimeouts{}
+	}
+	return util.ServerTimeouts{
+		Read:      positiveDuration(timeouts.ReadTimeout),
+		Write:     positiveDuration(timeouts.WriteTimeout),
+		Idle:      positiveDuration(timeouts.IdleTimeout),
+		KeepAlive: positiveDuration(timeouts.KeepAlivePeriod),
+	}
+}
+
+// UploadProxyRequestTimeout returns how long the upload proxy waits for an upload server to take an upload
+func UploadProxyRequestTimeout(timeouts *cdiv1.UploadTimeouts) time.Duration {
+	if timeouts == nil {
+		return defaultUploadProxyRequestTimeout
+	}
+	if timeout := positiveDuration(timeouts.ProxyRequestTimeout); timeout > 0 {
+		return timeout
+	}
+	return defaultUploadProxyRequestTimeout
+}
+
+// uploadTimeoutsEnv returns the env variables that pass timeouts to an upload server
+func uploadTimeoutsEnv(timeouts util.ServerTimeouts) []v1.EnvVar {
+	var env []v1.EnvVar
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{common.UploadReadTimeout, timeouts.Read},
+		{common.UploadWriteTimeout, timeouts.Write},
+		{common.UploadIdleTimeout, timeouts.Idle},
+		{common.UploadKeepAlivePeriod, timeouts.KeepAlive},
+	} {
+		if timeout.value > 0 {
+			env = append(env, v1.EnvVar{Name: timeout.name, Value: timeout.value.String()})
+		}
+	}
+	return env
+}
+
+func positiveDuration(duration *metav1.Duration) time.Duration {
+	if duration == nil || duration.Duration < 0 {
+		return 0
+	}
+	return duration.Duration
+}
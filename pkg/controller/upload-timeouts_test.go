@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+var _ = Describe("Upload timeouts", func() {
+	It("Should get the upload timeouts of the CDIConfig", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.UploadTimeouts = &cdiv1.UploadTimeouts{ReadTimeout: &metav1.Duration{Duration: 72 * time.Hour}}
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		timeouts, err := getUploadTimeouts(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeouts).To(Equal(cdiConfig.Spec.UploadTimeouts))
+	})
+
+	It("Should get no upload timeouts without a CDIConfig", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		timeouts, err := getUploadTimeouts(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(timeouts).To(BeNil())
+	})
+
+	It("Should convert the upload timeouts, ignoring negative ones", func() {
+		timeouts := &cdiv1.UploadTimeouts{
+			ReadTimeout:     &metav1.Duration{Duration: 72 * time.Hour},
+			IdleTimeout:     &metav1.Duration{Duration: -time.Minute},
+			KeepAlivePeriod: &metav1.Duration{Duration: 30 * time.Second},
+		}
+		Expect(UploadServerTimeouts(timeouts)).To(Equal(util.ServerTimeouts{Read: 72 * time.Hour, KeepAlive: 30 * time.Second}))
+		Expect(UploadServerTimeouts(nil)).To(Equal(util.ServerTimeouts{}))
+	})
+
+	It("Should default the proxy request timeout", func() {
+		Expect(UploadProxyRequestTimeout(nil)).To(Equal(24 * time.Hour))
+		Expect(UploadProxyRequestTimeout(&cdiv1.UploadTimeouts{})).To(Equal(24 * time.Hour))
+		Expect(UploadProxyRequestTimeout(&cdiv1.UploadTimeouts{ProxyRequestTimeout: &metav1.Duration{Duration: 96 * time.Hour}})).To(Equal(96 * time.Hour))
+	})
+
+	It("Should pass the set timeouts to the upload server", func() {
+		env := uploadTimeoutsEnv(util.ServerTimeouts{Read: 72 * time.Hour, KeepAlive: 30 * time.Second})
+		Expect(env).To(Equal([]corev1.EnvVar{
+			{Name: common.UploadReadTimeout, Value: "72h0m0s"},
+			{Name: common.UploadKeepAlivePeriod, Value: "30s"},
+		}))
+	})
+
+	It("Should create the upload pod with the timeouts", func() {
+		pvc := createPvc("testPvc1", "default", map[string]string{AnnUploadRequest: ""}, nil)
+		r := createUploadReconciler(pvc)
+		pod := r.makeUploadPodSpec(UploadPodArgs{
+			Name:     "cdi-upload-testPvc1",
+			PVC:      pvc,
+			Timeouts: util.ServerTimeouts{Write: time.Hour},
+		}, nil)
+		Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: common.UploadWriteTimeout, Value: "1h0m0s"}))
+	})
+})
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// getImportUserAgent returns the ImportUserAgent of the CDIConfig, empty for the default of the importer
+func getImportUserAgent(c client.Client) (string, error) {
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return cdiconfig.Spec.ImportUserAgent, nil
+}
+
+// getClusterID returns the ID importers identify the cluster with, the UID of the kube-system namespace, which lives
+// as long as the cluster
+func getClusterID(client kubernetes.Interface) (string, error) {
+	namespace, err := client.CoreV1().Namespaces().Get(metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(namespace.UID), nil
+}
+
+// setUserAgentEnvVar sets what an importer that requests its source identifies itself with
+func setUserAgentEnvVar(c client.Client, k8sClient kubernetes.Interface, podEnvVar *importPodEnvVar) error {
+	if podEnvVar.source == SourceNone {
+		return nil
+	}
+	var err error
+	if podEnvVar.userAgent, err = getImportUserAgent(c); err != nil {
+		return err
+	}
+	podEnvVar.clusterID, err = getClusterID(k8sClient)
+	return err
+}
+
+// userAgentEnv returns the env of the importer container with what it identifies itself with
+func userAgentEnv(podEnvVar *importPodEnvVar) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	if podEnvVar.userAgent != "" {
+		env = append(env, corev1.EnvVar{Name: common.ImporterUserAgent, Value: podEnvVar.userAgent})
+	}
+	if podEnvVar.clusterID != "" {
+		env = append(env, corev1.EnvVar{Name: common.ImporterClusterID, Value: podEnvVar.clusterID})
+	}
+	return env
+}
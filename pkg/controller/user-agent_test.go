@@ -0,0 +1,51 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("User-Agent", func() {
+	It("Should identify an importer with the product of the CDIConfig and the cluster", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.ImportUserAgent = "example-importer/1.0"
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		k8sClient := k8sfake.NewSimpleClientset(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: "cluster-uid"},
+		})
+		podEnvVar := &importPodEnvVar{source: SourceHTTP}
+		Expect(setUserAgentEnvVar(c, k8sClient, podEnvVar)).To(Succeed())
+		Expect(userAgentEnv(podEnvVar)).To(ConsistOf(
+			corev1.EnvVar{Name: common.ImporterUserAgent, Value: "example-importer/1.0"},
+			corev1.EnvVar{Name: common.ImporterClusterID, Value: "cluster-uid"},
+		))
+	})
+
+	It("Should leave the importer its defaults without a CDIConfig and a kube-system namespace", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		podEnvVar := &importPodEnvVar{source: SourceHTTP}
+		Expect(setUserAgentEnvVar(c, k8sfake.NewSimpleClientset(), podEnvVar)).To(Succeed())
+		Expect(userAgentEnv(podEnvVar)).To(BeEmpty())
+	})
+
+	It("Should not identify a blank importer", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.ImportUserAgent = "example-importer/1.0"
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		podEnvVar := &importPodEnvVar{source: SourceNone}
+		Expect(setUserAgentEnvVar(c, k8sfake.NewSimpleClientset(), podEnvVar)).To(Succeed())
+		Expect(userAgentEnv(podEnvVar)).To(BeEmpty())
+	})
+})
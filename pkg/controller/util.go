@@ -3,17 +3,20 @@ package controller
 import (
 	"context"
 	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/ghodss/yaml"
 	crdv1alpha1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1alpha1"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	extclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -36,9 +39,24 @@ const (
 	// CertVolName is the name of the volumecontaining certs
 	CertVolName = "cdi-cert-vol"
 
+	// SSHKeyVolName is the name of the volume containing the ssh key
+	SSHKeyVolName = "cdi-ssh-key-vol"
+
+	// SourceKeyVolName is the name of the volume containing the passphrase of an encrypted source image
+	SourceKeyVolName = "cdi-source-key-vol"
+
+	// TargetKeyVolName is the name of the volume containing the passphrase the imported image is encrypted with
+	TargetKeyVolName = "cdi-target-key-vol"
+
+	// PullSecretVolName is the prefix of the names of the volumes containing registry pull secrets
+	PullSecretVolName = "cdi-pull-secret-vol"
+
 	// ScratchVolName provides a const to use for creating scratch pvc volumes in pod specs
 	ScratchVolName = "cdi-scratch-vol"
 
+	// TmpVolName is the name of the volume transfer pods write their temporary files to
+	TmpVolName = "cdi-tmp-vol"
+
 	// ImagePathName provides a const to use for creating volumes in pod specs
 	ImagePathName  = "image-path"
 	socketPathName = "socket-path"
@@ -65,8 +83,143 @@ const (
 	AnnOwnerRef = AnnAPIGroup + "/storage.ownerRef"
 	// AnnPodRestarts is a PVC annotation that tells how many times a related pod was restarted
 	AnnPodRestarts = AnnAPIGroup + "/storage.pod.restarts"
+	// AnnPodQueued is a PVC annotation with the reason the related pod is not created yet because of a CDIQuota
+	AnnPodQueued = AnnAPIGroup + "/storage.pod.queued"
+	// AnnScratchExhausted is a PVC annotation with the termination message of an importer that ran out of scratch space
+	AnnScratchExhausted = AnnAPIGroup + "/storage.import.scratchExhausted"
+	// AnnDestinationNotWritable is a PVC annotation with the termination message of an upload server that could not
+	// write to the PVC
+	AnnDestinationNotWritable = AnnAPIGroup + "/storage.upload.destinationNotWritable"
+	// AnnCloneVerify asks a host-assisted clone to verify the data written to the target PVC against the checksums of
+	// the source
+	AnnCloneVerify = AnnAPIGroup + "/storage.clone.verify"
+	// AnnCloneVerified is a PVC annotation with the termination message of an upload server that verified the data of
+	// a clone
+	AnnCloneVerified = AnnAPIGroup + "/storage.clone.verified"
+	// AnnCloneVerificationFailed is a PVC annotation with the termination message of an upload server that found the
+	// data of a clone does not match the source
+	AnnCloneVerificationFailed = AnnAPIGroup + "/storage.clone.verificationFailed"
+	// AnnPartialWrite is a PVC annotation that tells a transfer pod failed after it may have written part of the data
+	// to the block device of the PVC, it is removed once a transfer pod succeeds
+	AnnPartialWrite = AnnAPIGroup + "/storage.partialWrite"
+	// AnnContentDigest is a PVC annotation with the digest of the raw image an importer or upload server wrote to the PVC
+	AnnContentDigest = AnnAPIGroup + "/storage.contentDigest"
+	// AnnVerification is a PVC annotation with how thoroughly the data written to the PVC is verified
+	AnnVerification = AnnAPIGroup + "/storage.verification"
+	// AnnBlockWipe is a PVC annotation with how the block device of the PVC is wiped before it is populated, "discard"
+	// or "zero"
+	AnnBlockWipe = AnnAPIGroup + "/storage.blockWipe"
+	// AnnBlockTargetOffset is a PVC annotation with the byte offset of the block device of the PVC the image is written at
+	AnnBlockTargetOffset = AnnAPIGroup + "/storage.blockTarget.offset"
+	// AnnBlockTargetPartition is a PVC annotation with the number of the partition of the block device of the PVC the
+	// image is written into
+	AnnBlockTargetPartition = AnnAPIGroup + "/storage.blockTarget.partition"
+	// AnnImageName is a PVC annotation with the name of the file the image is written to on the file system of the PVC,
+	// instead of disk.img
+	AnnImageName = AnnAPIGroup + "/storage.imageName"
+	// AnnPodDNS is a PVC annotation with the host aliases and DNS settings of the importer pod of the PVC, a
+	// DataVolumeDNS in JSON
+	AnnPodDNS = AnnAPIGroup + "/storage.pod.dns"
+	// AnnPopulationPhase is a PVC annotation with the phase of populating the PVC
+	AnnPopulationPhase = AnnAPIGroup + "/storage.population.phase"
+	// AnnPopulationAttempts is a PVC annotation that tells how often transfer pods were created to populate the PVC
+	AnnPopulationAttempts = AnnAPIGroup + "/storage.population.attempts"
+	// AnnPopulationStrategy is a PVC annotation that tells how the PVC is populated
+	AnnPopulationStrategy = AnnAPIGroup + "/storage.population.strategy"
+	// AnnAllowEviction is a PVC annotation that, if "true", allows evicting the transfer pods of the PVC, such as by
+	// a node drain, while they move data
+	AnnAllowEviction = AnnAPIGroup + "/storage.allowEviction"
+	// AnnPermanentFailure is a PVC annotation with the termination message of an importer that failed for a reason
+	// retrying does not resolve, the import is not retried
+	AnnPermanentFailure = AnnAPIGroup + "/storage.import.permanentFailure"
+	// AnnTransferLog is a PVC annotation with the end of the log of the transfer pod that populated the PVC, kept
+	// when the pod is deleted after the completed pod retention
+	AnnTransferLog = AnnAPIGroup + "/storage.transferLog"
+	// AnnTransferFailure is a PVC annotation with the sanitized end of the termination message of the last failed
+	// transfer pod of the PVC, it is removed once a transfer pod succeeds
+	AnnTransferFailure = AnnAPIGroup + "/storage.transferFailure"
+	// AnnStageDurations is a PVC annotation with how long the stages of the import to the PVC took, like
+	// "download=1m4s,convert=12.5s"
+	AnnStageDurations = AnnAPIGroup + "/storage.import.stageDurations"
+	// AnnHeartbeat is a PVC annotation with the last heartbeat of the running transfer pod of the PVC, the time it
+	// was reported by the pod and the bytes it transferred by then, like "2020-10-16T10:00:00Z,1048576"
+	AnnHeartbeat = AnnAPIGroup + "/storage.transfer.heartbeat"
+	// AnnTransferIdle is a PVC annotation with the heartbeat time since which the running transfer pod of the PVC
+	// transferred no data, and the progress at that time, like "2020-10-16T10:00:00Z,13.45%"
+	AnnTransferIdle = AnnAPIGroup + "/storage.transfer.idleSince"
+	// AnnTransferStalled is a PVC annotation that tells the running transfer pod of the PVC transferred no data and
+	// made no progress for transferStallTimeout
+	AnnTransferStalled = AnnAPIGroup + "/storage.transfer.stalled"
+	// AnnForceCleanup is a PVC annotation that, if "true", makes the controllers remove the CDI artifacts of a stuck
+	// PVC, its transfer pods, services and scratch space, and the CDI finalizers of the PVC
+	AnnForceCleanup = AnnAPIGroup + "/forceCleanup"
+	// AnnVolumeModeFallback is a DataVolume annotation that, if "true", retries the DataVolume once with the other
+	// volume mode if the storage class fails to provision its PVC with the volume mode it asks for
+	AnnVolumeModeFallback = AnnAPIGroup + "/storage.volumeModeFallback"
+	// AnnFallbackVolumeMode is a DataVolume annotation with the volume mode its PVC is recreated with after the
+	// storage class failed to provision the PVC with the volume mode of the DataVolume
+	AnnFallbackVolumeMode = AnnAPIGroup + "/storage.fallbackVolumeMode"
+	// AnnFallbackStorageClasses is a DataVolume annotation with the comma separated storage classes its PVC is
+	// recreated with, in order, if the PVC can not be created or stays pending with the storage class it asks for
+	AnnFallbackStorageClasses = AnnAPIGroup + "/storage.fallbackStorageClasses"
+	// AnnFallbackStorageClassDeadline is a DataVolume annotation with how long its PVC may stay pending before the
+	// next fallback storage class is tried, such as 10m
+	AnnFallbackStorageClassDeadline = AnnAPIGroup + "/storage.fallbackStorageClassDeadline"
+	// AnnFallbackStorageClass is a DataVolume annotation with the fallback storage class its PVC is recreated with
+	AnnFallbackStorageClass = AnnAPIGroup + "/storage.fallbackStorageClass"
+	// AnnAdoptPVC is a DataVolume annotation that, if "true", has the DataVolume populate an existing PVC of its name,
+	// which is not controlled by another object and was never populated by CDI, instead of creating one
+	AnnAdoptPVC = AnnAPIGroup + "/storage.adoptPVC"
+	// AnnNodeName is a PVC annotation with the node its transfer pod is pinned to
+	AnnNodeName = AnnAPIGroup + "/storage.nodeName"
+	// AnnNodeUnschedulable is a PVC annotation with why the node its transfer pod is pinned to can not run the pod
+	AnnNodeUnschedulable = AnnAPIGroup + "/storage.pod.nodeUnschedulable"
+	// AnnScratchSize is a PVC annotation with the size of the enlarged scratch space its import is retried with
+	AnnScratchSize = AnnAPIGroup + "/storage.import.scratchSize"
+	// AnnScratchPoolSizeClass is a scratch space PVC annotation with the size class of the scratch space pool its
+	// volume returns to when the PVC is deleted
+	AnnScratchPoolSizeClass = AnnAPIGroup + "/storage.scratchPoolSizeClass"
+
+	// AnnScratchPoolScrubbed is an annotation of a volume of the scratch space pool, with the UID of the claim it was
+	// scrubbed for. The volume is only checked out while that is the claim it was last bound to.
+	AnnScratchPoolScrubbed = AnnAPIGroup + "/storage.scratchPoolScrubbed"
+	// AnnPriority is a PVC annotation with the priority of the transfer to the PVC, low, normal or high
+	AnnPriority = AnnAPIGroup + "/storage.transfer.priority"
+	// AnnTransferDeadline is a PVC annotation with how long the transfer pods of the PVC may run, such as 2h
+	AnnTransferDeadline = AnnAPIGroup + "/storage.transfer.deadline"
+	// AnnDeadlineExceeded is a PVC annotation with how often the transfer pods of the PVC ran longer than their deadline
+	AnnDeadlineExceeded = AnnAPIGroup + "/storage.transfer.deadlineExceeded"
+	// AnnImporterServiceAccount is a namespace annotation with the service account the importer pods of the
+	// namespace run as, so they get its pull secrets and the cloud identity bound to it
+	AnnImporterServiceAccount = AnnAPIGroup + "/importerServiceAccount"
+	// AnnPodNetworks is a PVC annotation with the Multus networks its importer pod is attached to, in the format of
+	// AnnMultusNetworks
+	AnnPodNetworks = AnnAPIGroup + "/storage.pod.networks"
+	// AnnImporterNetworks is a namespace annotation with the Multus networks the importer pods of the namespace are
+	// attached to, unless their PVC has AnnPodNetworks
+	AnnImporterNetworks = AnnAPIGroup + "/importerNetworks"
+	// AnnImportBandwidthLimit is a namespace annotation with the JSON of the DataVolumeBandwidthLimit the importer pods
+	// of the namespace read their source with, unless their PVC has AnnBandwidthLimit
+	AnnImportBandwidthLimit = AnnAPIGroup + "/importBandwidthLimit"
+	// AnnMultusNetworks is the pod annotation Multus attaches the secondary networks of a pod by, a comma separated
+	// list of NetworkAttachmentDefinitions, namespace/name or name, or their JSON selection
+	AnnMultusNetworks = "k8s.v1.cni.cncf.io/networks"
+	// AnnAllowHighPriority is a namespace annotation that lets the transfers of the namespace run with high
+	// priority when set to "true", as their pods preempt the pods of other namespaces
+	AnnAllowHighPriority = AnnAPIGroup + "/allowHighPriority"
+	// AnnTransferWindows is a namespace annotation with the JSON list of the TransferWindows the low priority
+	// transfers of the namespace start in, instead of those of the CDIConfig
+	AnnTransferWindows = AnnAPIGroup + "/transferWindows"
+	// AnnTransferWindowClosed is a PVC annotation with why its low priority transfer waits for a transfer window
+	AnnTransferWindowClosed = AnnAPIGroup + "/storage.pod.transferWindowClosed"
+	// AnnInitiator is a DataVolume annotation with the user that created the DataVolume
+	AnnInitiator = AnnAPIGroup + "/storage.initiator"
 	// SourceImageio is the source type ovirt-imageio
 	SourceImageio = "imageio"
+	// SourceSSH is the source type of a file or block device read over ssh
+	SourceSSH = "ssh"
+	// SourceNutanix is the source type of the Nutanix AHV image service
+	SourceNutanix = "nutanix"
 )
 
 type podDeleteRequest struct {
@@ -104,6 +257,12 @@ func getDiskID(pvc *v1.PersistentVolumeClaim) string {
 	return diskID
 }
 
+// returns the architecture requested for a registry import
+func getArchitecture(pvc *v1.PersistentVolumeClaim) string {
+	arch, _ := pvc.Annotations[AnnArchitecture]
+	return arch
+}
+
 func getRequestedImageSize(pvc *v1.PersistentVolumeClaim) (string, error) {
 	pvcSize, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
 	if !found {
@@ -125,7 +284,9 @@ func getSource(pvc *v1.PersistentVolumeClaim) string {
 		SourceGlance,
 		SourceNone,
 		SourceRegistry,
-		SourceImageio:
+		SourceImageio,
+		SourceSSH,
+		SourceNutanix:
 		klog.V(2).Infof("pvc source annotation found for pvc \"%s/%s\", value %s\n", pvc.Namespace, pvc.Name, source)
 	default:
 		klog.V(2).Infof("No valid source annotation found for pvc \"%s/%s\", default to http\n", pvc.Namespace, pvc.Name)
@@ -143,7 +304,9 @@ func getContentType(pvc *v1.PersistentVolumeClaim) string {
 	switch contentType {
 	case
 		string(cdiv1.DataVolumeKubeVirt),
-		string(cdiv1.DataVolumeArchive):
+		string(cdiv1.DataVolumeArchive),
+		string(cdiv1.DataVolumeImages),
+		string(cdiv1.DataVolumeKubeVirtState):
 		klog.V(2).Infof("pvc content type annotation found for pvc \"%s/%s\", value %s\n", pvc.Namespace, pvc.Name, contentType)
 	default:
 		klog.V(2).Infof("No content type annotation found for pvc \"%s/%s\", default to kubevirt\n", pvc.Namespace, pvc.Name)
@@ -250,14 +413,14 @@ func checkIfLabelExists(pvc *v1.PersistentVolumeClaim, lbl string, val string) b
 	return false
 }
 
-// newScratchPersistentVolumeClaimSpec creates a new PVC based on the size of the passed in PVC.
-// It also sets the appropriate OwnerReferences on the resource
+// newScratchPersistentVolumeClaimSpec creates a new PVC based on the size of the passed in PVC, or its enlarged
+// scratch size up to maxSize. It also sets the appropriate OwnerReferences on the resource
 // which allows handleObject to discover the pod resource that 'owns' it, and clean up when needed.
-func newScratchPersistentVolumeClaimSpec(pvc *v1.PersistentVolumeClaim, pod *v1.Pod, name, storageClassName string) *v1.PersistentVolumeClaim {
+func newScratchPersistentVolumeClaimSpec(pvc *v1.PersistentVolumeClaim, pod *v1.Pod, name, storageClassName string, maxSize *resource.Quantity) *v1.PersistentVolumeClaim {
 	labels := map[string]string{
-		"cdi-controller": pod.Name,
-		"app":            "containerized-data-importer",
-		LabelImportPvc:   pvc.Name,
+		scratchPvcLabel: pod.Name,
+		"app":           "containerized-data-importer",
+		LabelImportPvc:  pvc.Name,
 	}
 
 	annotations := make(map[string]string, 0)
@@ -282,9 +445,15 @@ func newScratchPersistentVolumeClaimSpec(pvc *v1.PersistentVolumeClaim, pod *v1.
 		},
 		Spec: v1.PersistentVolumeClaimSpec{
 			AccessModes: []v1.PersistentVolumeAccessMode{"ReadWriteOnce"},
-			Resources:   pvc.Spec.Resources,
+			Resources:   *pvc.Spec.Resources.DeepCopy(),
 		},
 	}
+	if _, ok := pvc.GetAnnotations()[AnnScratchSize]; ok {
+		if pvcDef.Spec.Resources.Requests == nil {
+			pvcDef.Spec.Resources.Requests = v1.ResourceList{}
+		}
+		pvcDef.Spec.Resources.Requests[v1.ResourceStorage] = scratchSizeFromPvc(pvc, maxSize)
+	}
 	if storageClassName != "" {
 		pvcDef.Spec.StorageClassName = &storageClassName
 	}
@@ -292,9 +461,13 @@ func newScratchPersistentVolumeClaimSpec(pvc *v1.PersistentVolumeClaim, pod *v1.
 }
 
 // CreateScratchPersistentVolumeClaim creates and returns a pointer to a scratch PVC which is created based on the passed-in pvc and storage class name.
-func CreateScratchPersistentVolumeClaim(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim, pod *v1.Pod, name, storageClassName string) (*v1.PersistentVolumeClaim, error) {
+// maxSize is the ScratchSpaceMaxSize and pool the ScratchSpacePool of the CDIConfig.
+func CreateScratchPersistentVolumeClaim(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim, pod *v1.Pod, name, storageClassName string, maxSize *resource.Quantity, pool *cdiv1.ScratchSpacePool) (*v1.PersistentVolumeClaim, error) {
 	ns := pvc.Namespace
-	scratchPvcSpec := newScratchPersistentVolumeClaimSpec(pvc, pod, name, storageClassName)
+	scratchPvcSpec := newScratchPersistentVolumeClaimSpec(pvc, pod, name, storageClassName, maxSize)
+	if err := checkOutScratchVolume(client, pool, scratchPvcSpec); err != nil {
+		return nil, errors.Wrap(err, "scratch space pool check out errored")
+	}
 	scratchPvc, err := client.CoreV1().PersistentVolumeClaims(ns).Create(scratchPvcSpec)
 	if err != nil {
 		return nil, errors.Wrap(err, "scratch PVC API create errored")
@@ -409,6 +582,38 @@ func MakePodOwnerReference(pod *v1.Pod) metav1.OwnerReference {
 	}
 }
 
+// createPodIfNotExists creates pod, or returns the pod of the same name if an earlier reconcile already created
+// it. Transfer pods have names derived from their PVC, so a racing reconcile cannot create a second one. A pod of
+// the same name created for another owner, e.g. for a deleted PVC of the same name, is not adopted, the error
+// makes the reconcile retry until it is gone.
+func createPodIfNotExists(c client.Client, pod *v1.Pod) (*v1.Pod, error) {
+	err := c.Create(context.TODO(), pod)
+	if err == nil {
+		return pod, nil
+	}
+	if !k8serrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	existing := &v1.Pod{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, existing); err != nil {
+		return nil, err
+	}
+	if !hasSameOwner(existing, pod) {
+		return nil, errors.Errorf("pod %s/%s already exists and is not owned by the same PVC", pod.Namespace, pod.Name)
+	}
+	return existing, nil
+}
+
+// hasSameOwner returns true if existing has the controller of pod, or for pods without one, like the clone source
+// pods outside the namespace of their PVC, the same AnnOwnerRef annotation
+func hasSameOwner(existing, pod *v1.Pod) bool {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		existingOwner := metav1.GetControllerOf(existing)
+		return existingOwner != nil && existingOwner.UID == owner.UID
+	}
+	return existing.GetAnnotations()[AnnOwnerRef] == pod.GetAnnotations()[AnnOwnerRef]
+}
+
 func deletePod(req podDeleteRequest) error {
 	pod, err := req.podLister.Pods(req.namespace).Get(req.podName)
 	if k8serrors.IsNotFound(err) {
@@ -430,6 +635,10 @@ func createImportEnvVar(client kubernetes.Interface, pvc *v1.PersistentVolumeCla
 	podEnvVar := &importPodEnvVar{}
 	podEnvVar.source = getSource(pvc)
 	podEnvVar.contentType = getContentType(pvc)
+	podEnvVar.blockWipe = pvc.Annotations[AnnBlockWipe]
+	podEnvVar.blockTargetOffset = pvc.Annotations[AnnBlockTargetOffset]
+	podEnvVar.blockTargetPartition = pvc.Annotations[AnnBlockTargetPartition]
+	podEnvVar.imageName = pvc.Annotations[AnnImageName]
 
 	var err error
 	if podEnvVar.source != SourceNone {
@@ -453,12 +662,75 @@ func createImportEnvVar(client kubernetes.Interface, pvc *v1.PersistentVolumeCla
 			return nil, err
 		}
 		podEnvVar.diskID = getDiskID(pvc)
+		podEnvVar.imageCheck = pvc.Annotations[AnnImageCheck]
+		if value := pvc.Annotations[AnnBackingFileURLs]; value != "" && (podEnvVar.source == SourceHTTP || podEnvVar.source == SourceSSH) {
+			podEnvVar.backingFileURLs = strings.Split(value, ",")
+		}
+		if podEnvVar.source == SourceHTTP || podEnvVar.source == SourceS3 {
+			podEnvVar.checksum = pvc.Annotations[AnnChecksum]
+		}
+		if podEnvVar.source == SourceHTTP && podEnvVar.contentType == string(cdiv1.DataVolumeImages) {
+			podEnvVar.additionalImages = pvc.Annotations[AnnAdditionalImages]
+		}
+		if podEnvVar.source == SourceSSH {
+			podEnvVar.sshInsecureSkipHostKeyCheck = pvc.Annotations[AnnSSHInsecureSkipHostKeyCheck] == "true"
+		}
+		if podEnvVar.source == SourceNutanix {
+			podEnvVar.nutanixImageUUID = pvc.Annotations[AnnNutanixImageUUID]
+		}
+		podEnvVar.architecture = getArchitecture(pvc)
+		if podEnvVar.source == SourceRegistry {
+			podEnvVar.artifactMediaType = pvc.Annotations[AnnArtifactMediaType]
+			podEnvVar.artifactAnnotation = pvc.Annotations[AnnArtifactAnnotation]
+			podEnvVar.diskPath = pvc.Annotations[AnnDiskPath]
+			podEnvVar.registryMirrors, err = getRegistryMirrors(client, podEnvVar.ep)
+			if err != nil {
+				return nil, err
+			}
+			for _, mirror := range podEnvVar.registryMirrors {
+				insecure, err := isInsecureTLSEndpoint(client, mirror)
+				if err != nil {
+					return nil, err
+				}
+				if insecure {
+					podEnvVar.insecureRegistryMirrors = append(podEnvVar.insecureRegistryMirrors, mirror)
+				}
+			}
+		}
+		podEnvVar.serviceAccount, err = getImporterServiceAccount(client, pvc.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		podEnvVar.bandwidthLimit, err = getImportBandwidthLimit(client, pvc)
+		if err != nil {
+			return nil, err
+		}
+		podEnvVar.sourceKeySecret = pvc.Annotations[AnnSourceKeySecret]
+		podEnvVar.targetKeySecret = pvc.Annotations[AnnTargetKeySecret]
+		if podEnvVar.source == SourceRegistry {
+			podEnvVar.pullSecrets, err = getPullSecrets(client, pvc.Namespace, podEnvVar.serviceAccount)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 	//get the requested image size.
 	podEnvVar.imageSize, err = getRequestedImageSize(pvc)
 	if err != nil {
 		return nil, err
 	}
+	podEnvVar.priorityClassName, err = getPriorityClassName(client, pvc)
+	if err != nil {
+		return nil, err
+	}
+	podEnvVar.networks, err = getImporterNetworks(client, pvc)
+	if err != nil {
+		return nil, err
+	}
+	podEnvVar.dns, err = getImporterDNS(pvc)
+	if err != nil {
+		return nil, err
+	}
 	return podEnvVar, nil
 }
 
@@ -511,14 +783,18 @@ func IsOpenshift(client kubernetes.Interface) bool {
 }
 
 func isInsecureTLS(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim) (bool, error) {
-	var configMapName string
-
 	value, ok := pvc.Annotations[AnnEndpoint]
 	if !ok || value == "" {
 		return false, nil
 	}
+	return isInsecureTLSEndpoint(client, value)
+}
+
+// isInsecureTLSEndpoint checks if the registry host of the endpoint is listed in the insecure registry configmap.
+func isInsecureTLSEndpoint(client kubernetes.Interface, endpoint string) (bool, error) {
+	var configMapName string
 
-	url, err := url.Parse(value)
+	url, err := url.Parse(endpoint)
 	if err != nil {
 		return false, err
 	}
@@ -553,6 +829,143 @@ func isInsecureTLS(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim) (
 	return false, nil
 }
 
+// registryMirror describes the mirrors of a registry or repository, in the style of an ImageContentSourcePolicy
+type registryMirror struct {
+	Source  string   `json:"source"`
+	Mirrors []string `json:"mirrors"`
+}
+
+// getRegistryMirrors returns the mirrored locations of a registry endpoint, in order of preference, as configured
+// in the registry mirror configmap. When several sources match the endpoint the most specific one is used.
+func getRegistryMirrors(client kubernetes.Interface, endpoint string) ([]string, error) {
+	const scheme = "docker://"
+	if !strings.HasPrefix(endpoint, scheme) {
+		return nil, nil
+	}
+	ref := strings.TrimPrefix(endpoint, scheme)
+
+	cm, err := client.CoreV1().ConfigMaps(util.GetNamespace()).Get(common.RegistryMirrorConfigMap, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			klog.V(3).Infof("Configmap %s does not exist", common.RegistryMirrorConfigMap)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var match *registryMirror
+	for key, value := range cm.Data {
+		mirror := &registryMirror{}
+		if err := yaml.Unmarshal([]byte(value), mirror); err != nil {
+			klog.Warningf("Ignoring invalid registry mirror %s: %v", key, err)
+			continue
+		}
+		source := strings.TrimSuffix(mirror.Source, "/")
+		if source == "" || !strings.HasPrefix(ref, source) {
+			continue
+		}
+		// Only match whole path components, the remainder must start a path, tag or digest
+		if rest := ref[len(source):]; rest != "" && !strings.ContainsAny(rest[:1], "/:@") {
+			continue
+		}
+		if match == nil || len(source) > len(match.Source) {
+			mirror.Source = source
+			match = mirror
+		}
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	rest := ref[len(match.Source):]
+	var mirrors []string
+	for _, m := range match.Mirrors {
+		mirrors = append(mirrors, scheme+strings.TrimSuffix(m, "/")+rest)
+	}
+	klog.V(3).Infof("Using mirrors %v for %s", mirrors, endpoint)
+	return mirrors, nil
+}
+
+// getImporterServiceAccount returns the service account the importer pods of namespace run as, "" for the
+// default one. It is set by the AnnImporterServiceAccount annotation of the namespace.
+func getImporterServiceAccount(client kubernetes.Interface, namespace string) (string, error) {
+	ns, err := client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return ns.Annotations[AnnImporterServiceAccount], nil
+}
+
+// getImporterNetworks returns the Multus networks the importer pod of pvc is attached to, "" for none. They are set
+// by the AnnPodNetworks annotation of pvc, or else the AnnImporterNetworks annotation of its namespace.
+func getImporterNetworks(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim) (string, error) {
+	if networks, ok := pvc.Annotations[AnnPodNetworks]; ok {
+		return networks, nil
+	}
+	ns, err := client.CoreV1().Namespaces().Get(pvc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return ns.Annotations[AnnImporterNetworks], nil
+}
+
+// getImportBandwidthLimit returns the JSON of the bandwidth limit the importer pod of pvc reads its source with, "" for
+// none. It is set by the AnnBandwidthLimit annotation of pvc, or else the AnnImportBandwidthLimit annotation of its
+// namespace.
+func getImportBandwidthLimit(client kubernetes.Interface, pvc *v1.PersistentVolumeClaim) (string, error) {
+	if limit, ok := pvc.Annotations[AnnBandwidthLimit]; ok {
+		return limit, nil
+	}
+	ns, err := client.CoreV1().Namespaces().Get(pvc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return ns.Annotations[AnnImportBandwidthLimit], nil
+}
+
+// getImporterDNS returns the host aliases and DNS settings of the importer pod of pvc, set by its AnnPodDNS
+// annotation, or nil to keep those of the cluster
+func getImporterDNS(pvc *v1.PersistentVolumeClaim) (*cdiv1.DataVolumeDNS, error) {
+	value, ok := pvc.Annotations[AnnPodDNS]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	dns := &cdiv1.DataVolumeDNS{}
+	if err := json.Unmarshal([]byte(value), dns); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s annotation", AnnPodDNS)
+	}
+	return dns, nil
+}
+
+// getPullSecrets returns the image pull secrets of serviceAccount, or of the default service account of the
+// namespace if it is "", so registry imports can use the same credentials as the pods in that namespace.
+func getPullSecrets(client kubernetes.Interface, namespace, serviceAccount string) ([]string, error) {
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(serviceAccount, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var secrets []string
+	for _, ref := range sa.ImagePullSecrets {
+		secrets = append(secrets, ref.Name)
+	}
+	return secrets, nil
+}
+
 // IsCsiCrdsDeployed checks whether the CSI snapshotter CRD are deployed
 func IsCsiCrdsDeployed(c extclientset.Interface) bool {
 	vsClass := crdv1alpha1.VolumeSnapshotClassResourcePlural + "." + crdv1alpha1.GroupName
@@ -598,3 +1011,29 @@ func podPhaseFromPVC(pvc *v1.PersistentVolumeClaim) v1.PodPhase {
 func podSucceededFromPVC(pvc *v1.PersistentVolumeClaim) bool {
 	return (podPhaseFromPVC(pvc) == v1.PodSucceeded)
 }
+
+// updatePartialWriteAnnotation sets the AnnPartialWrite annotation in anno if pod failed writing to the block mode
+// pvc, and removes it once pod succeeded. The pod itself wipes the device before it writes it again.
+func updatePartialWriteAnnotation(pvc *v1.PersistentVolumeClaim, pod *v1.Pod, anno map[string]string) {
+	if getVolumeMode(pvc) != v1.PersistentVolumeBlock {
+		return
+	}
+	if pod.Status.Phase == v1.PodSucceeded {
+		delete(anno, AnnPartialWrite)
+		return
+	}
+	failed := pod.Status.Phase == v1.PodFailed
+	for _, status := range pod.Status.ContainerStatuses {
+		for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			// These exit codes are returned before any data is written
+			if terminated != nil && terminated.ExitCode > 0 &&
+				terminated.ExitCode != common.ScratchSpaceNeededExitCode &&
+				terminated.ExitCode != common.DestinationNotWritableExitCode {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		anno[AnnPartialWrite] = "true"
+	}
+}
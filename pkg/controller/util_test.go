@@ -16,8 +16,10 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/cache"
 	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	crdv1 "github.com/kubernetes-csi/external-snapshotter/pkg/apis/volumesnapshot/v1alpha1"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
@@ -229,6 +231,62 @@ func Test_getVolumeMode(t *testing.T) {
 	}
 }
 
+func Test_updatePartialWriteAnnotation(t *testing.T) {
+	failedContainer := []v1.ContainerStatus{
+		{LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1}}},
+	}
+	scratchContainer := []v1.ContainerStatus{
+		{LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: common.ScratchSpaceNeededExitCode}}},
+	}
+
+	tests := []struct {
+		name       string
+		pvc        *v1.PersistentVolumeClaim
+		phase      v1.PodPhase
+		containers []v1.ContainerStatus
+		want       bool
+	}{
+		{
+			name:       "expected a failed pod of a block pvc to be marked",
+			pvc:        createBlockPvc("testPvc", "default", map[string]string{}, nil),
+			phase:      v1.PodRunning,
+			containers: failedContainer,
+			want:       true,
+		},
+		{
+			name:       "expected a failed pod of a filesystem pvc not to be marked",
+			pvc:        createPvc("testPvc", "default", map[string]string{}, nil),
+			phase:      v1.PodRunning,
+			containers: failedContainer,
+			want:       false,
+		},
+		{
+			name:       "expected a pod that needs scratch space not to be marked",
+			pvc:        createBlockPvc("testPvc", "default", map[string]string{}, nil),
+			phase:      v1.PodRunning,
+			containers: scratchContainer,
+			want:       false,
+		},
+		{
+			name:       "expected the mark to be removed once the pod succeeded",
+			pvc:        createBlockPvc("testPvc", "default", map[string]string{AnnPartialWrite: "true"}, nil),
+			phase:      v1.PodSucceeded,
+			containers: failedContainer,
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{Status: v1.PodStatus{Phase: tt.phase, ContainerStatuses: tt.containers}}
+			anno := tt.pvc.GetAnnotations()
+			updatePartialWriteAnnotation(tt.pvc, pod, anno)
+			if _, got := anno[AnnPartialWrite]; got != tt.want {
+				t.Errorf("updatePartialWriteAnnotation() marked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_getContentType(t *testing.T) {
 	type args struct {
 		pvc *v1.PersistentVolumeClaim
@@ -731,6 +789,204 @@ func Test_getInsecureTLS(t *testing.T) {
 	}
 }
 
+func Test_getRegistryMirrors(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.RegistryMirrorConfigMap,
+			Namespace: "cdi",
+		},
+		Data: map[string]string{
+			"registry":   "source: quay.io\nmirrors:\n- mirror.example.com:5000/quay\n",
+			"repository": "source: quay.io/kubevirt/\nmirrors:\n- mirror1.example.com/kubevirt\n- mirror2.example.com/kubevirt/\n",
+			"invalid":    "source: [",
+		},
+	}
+
+	type args struct {
+		endpoint       string
+		confiMapExists bool
+		result         []string
+	}
+
+	for _, arg := range []args{
+		{"docker://quay.io/kubevirt/fedora:31", true, []string{"docker://mirror1.example.com/kubevirt/fedora:31", "docker://mirror2.example.com/kubevirt/fedora:31"}},
+		{"docker://quay.io/other/fedora@sha256:1234", true, []string{"docker://mirror.example.com:5000/quay/other/fedora@sha256:1234"}},
+		{"docker://quay.io/kubevirtfoo/fedora", true, []string{"docker://mirror.example.com:5000/quay/kubevirtfoo/fedora"}},
+		{"docker://quay.io.example.com/fedora", true, nil},
+		{"docker://docker.io/fedora", true, nil},
+		{"http://quay.io/kubevirt/fedora", true, nil},
+		{"docker://quay.io/kubevirt/fedora", false, nil},
+	} {
+		var objs []runtime.Object
+		if arg.confiMapExists {
+			objs = append(objs, cm)
+		}
+		client := k8sfake.NewSimpleClientset(objs...)
+
+		result, err := getRegistryMirrors(client, arg.endpoint)
+
+		if err != nil {
+			t.Errorf("Enexpected error %+v", err)
+		}
+
+		if !reflect.DeepEqual(result, arg.result) {
+			t.Errorf("Expected %v got %v", arg.result, result)
+		}
+	}
+}
+
+func Test_createImportEnvVarInsecureRegistryMirrors(t *testing.T) {
+	mirrors := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.RegistryMirrorConfigMap,
+			Namespace: "cdi",
+		},
+		Data: map[string]string{
+			"registry": "source: quay.io\nmirrors:\n- secure.example.com\n- insecure.example.com:5000\n",
+		},
+	}
+	insecure := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.InsecureRegistryConfigMap,
+			Namespace: "cdi",
+		},
+		Data: map[string]string{
+			"mirror": "insecure.example.com:5000",
+		},
+	}
+	pvc := createPvc("testPvc1", "default", map[string]string{AnnEndpoint: "docker://quay.io/fedora", AnnSource: SourceRegistry}, nil)
+	client := k8sfake.NewSimpleClientset(mirrors, insecure)
+
+	podEnvVar, err := createImportEnvVar(client, pvc)
+
+	if err != nil {
+		t.Errorf("Unexpected error %+v", err)
+	}
+	if podEnvVar.insecureTLS {
+		t.Errorf("Expected the endpoint to be secure")
+	}
+	if !reflect.DeepEqual(podEnvVar.insecureRegistryMirrors, []string{"docker://insecure.example.com:5000/fedora"}) {
+		t.Errorf("Unexpected insecure mirrors %v", podEnvVar.insecureRegistryMirrors)
+	}
+}
+
+func Test_getPullSecrets(t *testing.T) {
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "default",
+			Namespace: "test",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "first"}, {Name: "second"}},
+	}
+
+	egress := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "egress",
+			Namespace: "test",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "egress"}},
+	}
+
+	type args struct {
+		namespace      string
+		serviceAccount string
+		result         []string
+	}
+
+	for _, arg := range []args{
+		{"test", "", []string{"first", "second"}},
+		{"test", "egress", []string{"egress"}},
+		{"other", "", nil},
+	} {
+		client := k8sfake.NewSimpleClientset(sa, egress)
+
+		result, err := getPullSecrets(client, arg.namespace, arg.serviceAccount)
+
+		if err != nil {
+			t.Errorf("Enexpected error %+v", err)
+		}
+
+		if !reflect.DeepEqual(result, arg.result) {
+			t.Errorf("Expected %v got %v", arg.result, result)
+		}
+	}
+}
+
+func Test_getImporterServiceAccount(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: map[string]string{AnnImporterServiceAccount: "egress"},
+		},
+	}
+	client := k8sfake.NewSimpleClientset(ns, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "plain"}})
+
+	for namespace, expected := range map[string]string{"test": "egress", "plain": "", "other": ""} {
+		result, err := getImporterServiceAccount(client, namespace)
+		if err != nil {
+			t.Errorf("Unexpected error %+v", err)
+		}
+		if result != expected {
+			t.Errorf("Expected %q got %q", expected, result)
+		}
+	}
+}
+
+func Test_getImportBandwidthLimit(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: map[string]string{AnnImportBandwidthLimit: `{"rate":"50Mi"}`},
+		},
+	}
+	client := k8sfake.NewSimpleClientset(ns)
+
+	for _, arg := range []struct {
+		pvc    *corev1.PersistentVolumeClaim
+		result string
+	}{
+		{createPvc("pvc", "test", nil, nil), `{"rate":"50Mi"}`},
+		{createPvc("pvc", "test", map[string]string{AnnBandwidthLimit: `{"rate":"1Gi"}`}, nil), `{"rate":"1Gi"}`},
+		{createPvc("pvc", "other", nil, nil), ""},
+	} {
+		result, err := getImportBandwidthLimit(client, arg.pvc)
+		if err != nil {
+			t.Errorf("Unexpected error %+v", err)
+		}
+		if result != arg.result {
+			t.Errorf("Expected %q got %q", arg.result, result)
+		}
+	}
+}
+
+func Test_getImporterNetworks(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: map[string]string{AnnImporterNetworks: "storage/san"},
+		},
+	}
+	client := k8sfake.NewSimpleClientset(ns)
+
+	for _, arg := range []struct {
+		pvc    *corev1.PersistentVolumeClaim
+		result string
+	}{
+		{createPvc("pvc", "test", nil, nil), "storage/san"},
+		{createPvc("pvc", "test", map[string]string{AnnPodNetworks: "backup"}, nil), "backup"},
+		{createPvc("pvc", "test", map[string]string{AnnPodNetworks: ""}, nil), ""},
+		{createPvc("pvc", "other", nil, nil), ""},
+	} {
+		result, err := getImporterNetworks(client, arg.pvc)
+		if err != nil {
+			t.Errorf("Unexpected error %+v", err)
+		}
+		if result != arg.result {
+			t.Errorf("Expected %q got %q", arg.result, result)
+		}
+	}
+}
+
 func Test_GetScratchPvcStorageClassDefault(t *testing.T) {
 	var objs []runtime.Object
 	objs = append(objs, createStorageClass("test1", nil))
@@ -845,7 +1101,7 @@ func Test_TokenValidation(t *testing.T) {
 	missingParams.Params = nil
 
 	g := token.NewGenerator(common.CloneTokenIssuer, getAPIServerKey(), 5*time.Minute)
-	v := newCloneTokenValidator(&getAPIServerKey().PublicKey)
+	v := newCloneTokenValidator(token.PublicKeyList{&getAPIServerKey().PublicKey})
 
 	payloads := []*token.Payload{
 		goodTokenData(),
@@ -1123,3 +1379,32 @@ func createDefaultPodResourceRequirements(limitCPUValue int64, limitMemoryValue
 			corev1.ResourceMemory: *resource.NewQuantity(requestMemoryValue, resource.DecimalSI)},
 	}
 }
+
+func Test_createPodIfNotExists(t *testing.T) {
+	pvc := createPvc("testPvc1", "default", nil, nil)
+	pvc.UID = "pvc-uid"
+	newPod := func(owner *v1.PersistentVolumeClaim) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "importer-testPvc1",
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{MakePVCOwnerReference(owner)},
+			},
+		}
+	}
+	otherPvc := pvc.DeepCopy()
+	otherPvc.UID = "old-pvc-uid"
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, newPod(pvc))
+	pod, err := createPodIfNotExists(c, newPod(pvc))
+	if err != nil {
+		t.Errorf("the pod of the same PVC was not adopted: %v", err)
+	} else if pod.OwnerReferences[0].UID != pvc.UID {
+		t.Errorf("wrong pod returned: %+v", pod)
+	}
+
+	c = fake.NewFakeClientWithScheme(scheme.Scheme, newPod(otherPvc))
+	if _, err := createPodIfNotExists(c, newPod(pvc)); err == nil {
+		t.Error("the pod of another PVC of the same name was adopted")
+	}
+}
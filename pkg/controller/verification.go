@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// isValidVerification returns true if verification is one of the verification levels of a data volume
+func isValidVerification(verification cdiv1.DataVolumeVerification) bool {
+	switch verification {
+	case cdiv1.DataVolumeVerificationNone, cdiv1.DataVolumeVerificationFast, cdiv1.DataVolumeVerificationFull:
+		return true
+	}
+	return false
+}
+
+// getVerification returns how thoroughly the data written to pvc is verified, the AnnVerification annotation of pvc
+// or else the DefaultVerification of the CDIConfig, full if neither is set
+func getVerification(c client.Client, pvc *v1.PersistentVolumeClaim) (cdiv1.DataVolumeVerification, error) {
+	if verification := cdiv1.DataVolumeVerification(pvc.GetAnnotations()[AnnVerification]); isValidVerification(verification) {
+		return verification, nil
+	}
+	cdiconfig := &cdiv1.CDIConfig{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: common.ConfigName}, cdiconfig); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return cdiv1.DataVolumeVerificationFull, nil
+		}
+		return "", err
+	}
+	if isValidVerification(cdiconfig.Spec.DefaultVerification) {
+		return cdiconfig.Spec.DefaultVerification, nil
+	}
+	return cdiv1.DataVolumeVerificationFull, nil
+}
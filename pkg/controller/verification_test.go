@@ -0,0 +1,56 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+var _ = Describe("Verification", func() {
+	table.DescribeTable("Should get the verification", func(annotation string, configured, expected cdiv1.DataVolumeVerification) {
+		cdiv1.AddToScheme(scheme.Scheme)
+		cdiConfig := MakeEmptyCDIConfigSpec(common.ConfigName)
+		cdiConfig.Spec.DefaultVerification = configured
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, cdiConfig)
+		pvc := createPvc("testPvc1", "default", map[string]string{}, nil)
+		if annotation != "" {
+			pvc.Annotations[AnnVerification] = annotation
+		}
+		verification, err := getVerification(c, pvc)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verification).To(Equal(expected))
+	},
+		table.Entry("of the data volume", "none", cdiv1.DataVolumeVerificationFast, cdiv1.DataVolumeVerificationNone),
+		table.Entry("of the CDIConfig", "", cdiv1.DataVolumeVerificationFast, cdiv1.DataVolumeVerificationFast),
+		table.Entry("of the CDIConfig if the annotation is invalid", "thorough", cdiv1.DataVolumeVerificationFast, cdiv1.DataVolumeVerificationFast),
+		table.Entry("full if neither is set", "", cdiv1.DataVolumeVerification(""), cdiv1.DataVolumeVerificationFull),
+	)
+
+	It("Should get full verification without a CDIConfig", func() {
+		cdiv1.AddToScheme(scheme.Scheme)
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		verification, err := getVerification(c, createPvc("testPvc1", "default", map[string]string{}, nil))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verification).To(Equal(cdiv1.DataVolumeVerificationFull))
+	})
+
+	It("Should pass the verification to the importer", func() {
+		env := makeImportEnv(&importPodEnvVar{verification: string(cdiv1.DataVolumeVerificationFast)}, "1111-1111-1111-1111")
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: common.ImporterVerification, Value: "fast"}))
+	})
+
+	It("Should set the verification of the data volume on the PVC", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Spec.Verification = cdiv1.DataVolumeVerificationNone
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Annotations[AnnVerification]).To(Equal("none"))
+	})
+})
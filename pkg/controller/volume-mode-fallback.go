@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// VolumeModeFallback provides a const to indicate the PVC of a DataVolume is recreated with the other volume mode
+	VolumeModeFallback = "VolumeModeFallback"
+	// MessageVolumeModeFallback provides a const to form the message of a volume mode fallback
+	MessageVolumeModeFallback = "Storage class failed to provision PVC %s with volume mode %s, retrying with volume mode %s"
+
+	// provisioningFailedReason is the reason of the events of a PVC the provisioner failed to provision
+	provisioningFailedReason = "ProvisioningFailed"
+)
+
+// volumeModeErrors are the parts of the messages of provisioners that reject the volume mode of a PVC
+var volumeModeErrors = []string{
+	"block",
+	"volume mode",
+	"volumemode",
+	"access type",
+}
+
+// otherVolumeMode returns the volume mode a PVC with mode falls back to
+func otherVolumeMode(mode corev1.PersistentVolumeMode) corev1.PersistentVolumeMode {
+	if mode == corev1.PersistentVolumeBlock {
+		return corev1.PersistentVolumeFilesystem
+	}
+	return corev1.PersistentVolumeBlock
+}
+
+// isVolumeModeError returns true if the message of a provisioning failure blames the volume mode
+func isVolumeModeError(message string) bool {
+	message = strings.ToLower(message)
+	for _, e := range volumeModeErrors {
+		if strings.Contains(message, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// dataVolumeVolumeMode returns the volume mode the PVC of dv is created with
+func dataVolumeVolumeMode(dv *cdiv1.DataVolume) *corev1.PersistentVolumeMode {
+	if mode, ok := dv.Annotations[AnnFallbackVolumeMode]; ok {
+		volumeMode := corev1.PersistentVolumeMode(mode)
+		return &volumeMode
+	}
+	return dv.Spec.PVC.VolumeMode
+}
+
+// canFallBackVolumeMode returns true if the PVC of dv may be recreated with the other volume mode: the DataVolume
+// asks for it, was not retried yet, did not adopt the PVC, is not pre-bound to a volume and does not clone, as a
+// clone needs the volume mode of its source
+func canFallBackVolumeMode(dv *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) bool {
+	if dv.Annotations[AnnVolumeModeFallback] != "true" || adoptsPVC(dv) || isPreBound(dv) {
+		return false
+	}
+	if _, ok := dv.Annotations[AnnFallbackVolumeMode]; ok {
+		return false
+	}
+	if _, ok := pvc.Annotations[AnnCloneRequest]; ok {
+		return false
+	}
+	return pvc.Status.Phase == corev1.ClaimPending && pvc.DeletionTimestamp == nil
+}
+
+// volumeModeProvisioningFailure returns the message of the event of the provisioner that failed to provision pvc
+// with its volume mode
+func (r *DatavolumeReconciler) volumeModeProvisioningFailure(pvc *corev1.PersistentVolumeClaim) (string, bool, error) {
+	selector := fields.Set{
+		"involvedObject.kind": "PersistentVolumeClaim",
+		"involvedObject.uid":  string(pvc.UID),
+		"reason":              provisioningFailedReason,
+	}.AsSelector().String()
+	events, err := r.K8sClient.CoreV1().Events(pvc.Namespace).List(metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return "", false, err
+	}
+	for _, event := range events.Items {
+		if event.InvolvedObject.UID != pvc.UID || event.Reason != provisioningFailedReason {
+			continue
+		}
+		if isVolumeModeError(event.Message) {
+			return event.Message, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// reconcileVolumeModeFallback deletes the PVC of dv if the storage class failed to provision it with its volume mode
+// and records the other volume mode on the DataVolume, the PVC is then recreated with it. It returns true if the PVC
+// was deleted.
+func (r *DatavolumeReconciler) reconcileVolumeModeFallback(dv *cdiv1.DataVolume, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if !canFallBackVolumeMode(dv, pvc) {
+		return false, nil
+	}
+	failure, ok, err := r.volumeModeProvisioningFailure(pvc)
+	if err != nil || !ok {
+		return false, err
+	}
+	mode := getVolumeMode(pvc)
+	fallback := otherVolumeMode(mode)
+	r.Log.V(1).Info("Falling back to the other volume mode", "namespace", pvc.Namespace, "name", pvc.Name, "failure", failure, "volumeMode", fallback)
+
+	if dv.Annotations == nil {
+		dv.Annotations = make(map[string]string)
+	}
+	dv.Annotations[AnnFallbackVolumeMode] = string(fallback)
+	if err := r.Client.Update(context.TODO(), dv); err != nil {
+		return false, err
+	}
+	if err := r.Client.Delete(context.TODO(), pvc); err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	r.recorder.Event(dv, corev1.EventTypeWarning, VolumeModeFallback, fmt.Sprintf(MessageVolumeModeFallback, pvc.Name, mode, fallback))
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Volume mode fallback", func() {
+	dvName := types.NamespacedName{Name: "test-dv", Namespace: metav1.NamespaceDefault}
+
+	failProvisioning := func(r *DatavolumeReconciler, message string) {
+		pvc := &corev1.PersistentVolumeClaim{}
+		Expect(r.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		pvc.Status.Phase = corev1.ClaimPending
+		Expect(r.Client.Update(context.TODO(), pvc)).To(Succeed())
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-dv.failed", Namespace: metav1.NamespaceDefault},
+			InvolvedObject: corev1.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: pvc.Namespace,
+				Name:      pvc.Name,
+				UID:       pvc.UID,
+			},
+			Reason:  provisioningFailedReason,
+			Message: message,
+		}
+		_, err := r.K8sClient.CoreV1().Events(metav1.NamespaceDefault).Create(event)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	reconcileDV := func(r *DatavolumeReconciler) {
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: dvName})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("Should recreate the PVC with the other volume mode if the storage class rejects the volume mode", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnVolumeModeFallback: "true"}
+		r := createDatavolumeReconciler(dv)
+		reconcileDV(r)
+		failProvisioning(r, "failed to provision volume with StorageClass \"local\": rpc error: code = InvalidArgument desc = Block Volume not supported")
+
+		reconcileDV(r)
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Client.Get(context.TODO(), dvName, pvc)
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+		Expect(r.Client.Get(context.TODO(), dvName, dv)).To(Succeed())
+		Expect(dv.Annotations).To(HaveKeyWithValue(AnnFallbackVolumeMode, string(corev1.PersistentVolumeBlock)))
+
+		reconcileDV(r)
+		Expect(r.Client.Get(context.TODO(), dvName, pvc)).To(Succeed())
+		Expect(pvc.Spec.VolumeMode).ToNot(BeNil())
+		Expect(*pvc.Spec.VolumeMode).To(Equal(corev1.PersistentVolumeBlock))
+	})
+
+	It("Should not recreate the PVC without the annotation", func() {
+		r := createDatavolumeReconciler(newImportDataVolume("test-dv"))
+		reconcileDV(r)
+		failProvisioning(r, "Block Volume not supported")
+
+		reconcileDV(r)
+		Expect(r.Client.Get(context.TODO(), dvName, &corev1.PersistentVolumeClaim{})).To(Succeed())
+	})
+
+	It("Should not recreate the PVC if the provisioning failed for another reason", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{AnnVolumeModeFallback: "true"}
+		r := createDatavolumeReconciler(dv)
+		reconcileDV(r)
+		failProvisioning(r, "rpc error: code = ResourceExhausted desc = out of space")
+
+		reconcileDV(r)
+		Expect(r.Client.Get(context.TODO(), dvName, &corev1.PersistentVolumeClaim{})).To(Succeed())
+	})
+
+	It("Should fall back only once", func() {
+		dv := newImportDataVolume("test-dv")
+		dv.Annotations = map[string]string{
+			AnnVolumeModeFallback: "true",
+			AnnFallbackVolumeMode: string(corev1.PersistentVolumeBlock),
+		}
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, nil, nil)
+		pvc.Status.Phase = corev1.ClaimPending
+		Expect(canFallBackVolumeMode(dv, pvc)).To(BeFalse())
+	})
+
+	It("Should not fall back for clones", func() {
+		dv := newCloneDataVolume("test-dv")
+		dv.Annotations[AnnVolumeModeFallback] = "true"
+		pvc := createPvc("test-dv", metav1.NamespaceDefault, map[string]string{AnnCloneRequest: "default/source"}, nil)
+		pvc.Status.Phase = corev1.ClaimPending
+		Expect(canFallBackVolumeMode(dv, pvc)).To(BeFalse())
+	})
+
+	It("Should pick the other volume mode", func() {
+		Expect(otherVolumeMode(corev1.PersistentVolumeBlock)).To(Equal(corev1.PersistentVolumeFilesystem))
+		Expect(otherVolumeMode(corev1.PersistentVolumeFilesystem)).To(Equal(corev1.PersistentVolumeBlock))
+		Expect(otherVolumeMode("")).To(Equal(corev1.PersistentVolumeBlock))
+	})
+
+	It("Should create the PVC with the volume mode of the DataVolume without a fallback", func() {
+		dv := newImportDataVolume("test-dv")
+		mode := corev1.PersistentVolumeBlock
+		dv.Spec.PVC.VolumeMode = &mode
+		pvc, err := newPersistentVolumeClaim(dv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*pvc.Spec.VolumeMode).To(Equal(corev1.PersistentVolumeBlock))
+		Expect(dv.Spec.PVC.VolumeMode).To(Equal(&mode))
+	})
+})
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// ociLayoutFile marks the root of an OCI image layout, and thus an oci-archive
+	ociLayoutFile = "oci-layout"
+	// dockerArchiveManifest is the manifest of a docker-archive, as written by docker save or podman save
+	dockerArchiveManifest = "manifest.json"
+)
+
+// ArchiveTransport returns the skopeo reference of an image tarball, using the oci-archive transport
+// if the tarball contains an OCI image layout and the docker-archive transport if it contains
+// a docker save manifest.
+func ArchiveTransport(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", errors.Wrap(err, "could not open image archive")
+	}
+	defer f.Close()
+
+	isDockerArchive := false
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "could not read image archive, only uncompressed tarballs are supported")
+		}
+		switch path.Clean(hdr.Name) {
+		case ociLayoutFile:
+			klog.V(1).Infof("Found %s in %s, reading it as an oci-archive", ociLayoutFile, file)
+			return "oci-archive:" + file, nil
+		case dockerArchiveManifest:
+			// An OCI layout may contain any file, keep looking for oci-layout
+			isDockerArchive = true
+		}
+	}
+	if isDockerArchive {
+		klog.V(1).Infof("Found %s in %s, reading it as a docker-archive", dockerArchiveManifest, file)
+		return "docker-archive:" + file, nil
+	}
+	return "", errors.Errorf("%s is neither a docker-archive nor an oci-archive", path.Base(file))
+}
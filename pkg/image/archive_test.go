@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package image
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Image archive transport", func() {
+	var tmpDir string
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "archive-test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	writeArchive := func(names ...string) string {
+		file := filepath.Join(tmpDir, "image.tar")
+		f, err := os.Create(file)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		tw := tar.NewWriter(f)
+		for _, name := range names {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 2})).To(Succeed())
+			_, err = tw.Write([]byte("{}"))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+		return file
+	}
+
+	table.DescribeTable("should detect", func(transport string, names ...string) {
+		file := writeArchive(names...)
+		ref, err := ArchiveTransport(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal(transport + ":" + file))
+	},
+		table.Entry("an oci-archive", "oci-archive", "oci-layout", "index.json", "blobs/sha256/0123"),
+		table.Entry("an oci-archive containing a manifest.json", "oci-archive", "manifest.json", "./oci-layout", "index.json"),
+		table.Entry("a docker-archive", "docker-archive", "0123.json", "0123/layer.tar", "manifest.json", "repositories"),
+	)
+
+	It("should fail on a tarball that is not an image archive", func() {
+		_, err := ArchiveTransport(writeArchive("disk.img"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("neither a docker-archive nor an oci-archive"))
+	})
+
+	It("should remove the archive before extracting the image", func() {
+		file := writeArchive("oci-layout", "index.json")
+		extracted := false
+		replaceSkopeoFunctions(mockSkopeoExecFunction(singleManifest, mockExecFunction("", "", nil, "oci-archive:"+file)), func() {
+			extractImageLayers = func(dest string, artifact ArtifactSelector, arg ...string) error {
+				_, err := os.Stat(file)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+				extracted = true
+				return nil
+			}
+			Expect(CopyArchiveImage(file, tmpDir, "", "", "", ArtifactSelector{})).To(Succeed())
+		})
+		Expect(extracted).To(BeTrue())
+	})
+
+	It("should fail on a file that is not a tarball", func() {
+		file := filepath.Join(tmpDir, "image.tar.gz")
+		Expect(ioutil.WriteFile(file, []byte("\x1f\x8b\x08 compressed data"), 0644)).To(Succeed())
+		_, err := ArchiveTransport(file)
+		Expect(err).To(HaveOccurred())
+	})
+})
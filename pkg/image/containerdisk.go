@@ -0,0 +1,229 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	ociLayoutVersion = "1.0.0"
+
+	// MediaTypeOCIManifest is the media type of an OCI image manifest
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeOCIConfig is the media type of an OCI image config
+	MediaTypeOCIConfig = "application/vnd.oci.image.config.v1+json"
+	// MediaTypeOCILayer is the media type of an uncompressed OCI image layer
+	MediaTypeOCILayer = "application/vnd.oci.image.layer.v1.tar"
+
+	// containerDiskFile is the location of the disk image inside the containerDisk
+	containerDiskFile = "disk/disk.img"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type ociConfig struct {
+	Created      time.Time `json:"created"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	RootFS       ociRootFS `json:"rootfs"`
+}
+
+// BuildContainerDiskLayout writes an OCI image layout into layoutDir. The image consists of a single
+// layer containing the disk image found at diskImage, stored at /disk/disk.img and owned by the qemu
+// user, as described in https://github.com/kubevirt/kubevirt/blob/master/docs/container-register-disks.md
+func BuildContainerDiskLayout(diskImage, layoutDir string) error {
+	blobDir := filepath.Join(layoutDir, "blobs", "sha256")
+	if err := os.MkdirAll(blobDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "could not create OCI layout")
+	}
+
+	layer, err := writeDiskLayer(diskImage, blobDir)
+	if err != nil {
+		return err
+	}
+
+	config, err := writeJSONBlob(blobDir, MediaTypeOCIConfig, &ociConfig{
+		Created:      time.Now().UTC(),
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		RootFS: ociRootFS{
+			Type:    "layers",
+			DiffIDs: []string{layer.Digest},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest, err := writeJSONBlob(blobDir, MediaTypeOCIManifest, &ociManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        *config,
+		Layers:        []ociDescriptor{*layer},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSONFile(filepath.Join(layoutDir, "index.json"), &ociIndex{
+		SchemaVersion: 2,
+		Manifests:     []ociDescriptor{*manifest},
+	}); err != nil {
+		return err
+	}
+	return writeJSONFile(filepath.Join(layoutDir, "oci-layout"), map[string]string{"imageLayoutVersion": ociLayoutVersion})
+}
+
+// writeDiskLayer writes an uncompressed tar layer containing the disk image into blobDir, and
+// returns the descriptor of the resulting blob.
+func writeDiskLayer(diskImage, blobDir string) (*ociDescriptor, error) {
+	src, err := os.Open(diskImage)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open disk image")
+	}
+	defer src.Close()
+
+	// Seeking to the end works for both regular files and block devices, where Stat reports no size.
+	size, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not determine disk image size")
+	}
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "could not determine disk image size")
+	}
+
+	tmp, err := ioutil.TempFile(blobDir, "layer")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create layer file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	tw := tar.NewWriter(io.MultiWriter(tmp, hash, counter))
+	qemuID := int(common.QemuSubGid)
+	if err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     filepath.Dir(containerDiskFile) + "/",
+		Mode:     0555,
+		Uid:      qemuID,
+		Gid:      qemuID,
+	}); err != nil {
+		return nil, errors.Wrap(err, "could not write layer")
+	}
+	if err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     containerDiskFile,
+		Mode:     0440,
+		Uid:      qemuID,
+		Gid:      qemuID,
+		Size:     size,
+	}); err != nil {
+		return nil, errors.Wrap(err, "could not write layer")
+	}
+	klog.V(1).Infof("Writing %d bytes of %s to containerDisk layer", size, diskImage)
+	if _, err = io.Copy(tw, src); err != nil {
+		return nil, errors.Wrap(err, "could not write layer")
+	}
+	if err = tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "could not write layer")
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, errors.Wrap(err, "could not write layer")
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if err = os.Rename(tmp.Name(), filepath.Join(blobDir, sum)); err != nil {
+		return nil, errors.Wrap(err, "could not write layer")
+	}
+	return &ociDescriptor{
+		MediaType: MediaTypeOCILayer,
+		Digest:    "sha256:" + sum,
+		Size:      counter.n,
+	}, nil
+}
+
+func writeJSONBlob(blobDir, mediaType string, obj interface{}) (*ociDescriptor, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not marshal %s", mediaType)
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if err = ioutil.WriteFile(filepath.Join(blobDir, digest), data, 0644); err != nil {
+		return nil, errors.Wrapf(err, "could not write %s", mediaType)
+	}
+	return &ociDescriptor{
+		MediaType: mediaType,
+		Digest:    "sha256:" + digest,
+		Size:      int64(len(data)),
+	}, nil
+}
+
+func writeJSONFile(path string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal %s", filepath.Base(path))
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, data, 0644), "could not write %s", filepath.Base(path))
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package image
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ContainerDisk layout", func() {
+	var tmpDir string
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "containerdisk-test")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	readJSON := func(path string, obj interface{}) {
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.Unmarshal(data, obj)).To(Succeed())
+	}
+
+	blobPath := func(layoutDir, digest string) string {
+		return filepath.Join(layoutDir, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+	}
+
+	It("Should build a single layer image containing the disk", func() {
+		diskImage := filepath.Join(tmpDir, "disk.img")
+		Expect(ioutil.WriteFile(diskImage, []byte("disk contents"), 0644)).To(Succeed())
+		layoutDir := filepath.Join(tmpDir, "layout")
+
+		Expect(BuildContainerDiskLayout(diskImage, layoutDir)).To(Succeed())
+
+		index := &ociIndex{}
+		readJSON(filepath.Join(layoutDir, "index.json"), index)
+		Expect(index.Manifests).To(HaveLen(1))
+		Expect(index.Manifests[0].MediaType).To(Equal(MediaTypeOCIManifest))
+
+		manifest := &ociManifest{}
+		readJSON(blobPath(layoutDir, index.Manifests[0].Digest), manifest)
+		Expect(manifest.Config.MediaType).To(Equal(MediaTypeOCIConfig))
+		Expect(manifest.Layers).To(HaveLen(1))
+
+		config := &ociConfig{}
+		readJSON(blobPath(layoutDir, manifest.Config.Digest), config)
+		Expect(config.RootFS.DiffIDs).To(Equal([]string{manifest.Layers[0].Digest}))
+
+		layerFile := blobPath(layoutDir, manifest.Layers[0].Digest)
+		info, err := os.Stat(layerFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(Equal(manifest.Layers[0].Size))
+
+		f, err := os.Open(layerFile)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+		tr := tar.NewReader(f)
+		hdr, err := tr.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hdr.Name).To(Equal("disk/"))
+		hdr, err = tr.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hdr.Name).To(Equal(containerDiskFile))
+		Expect(hdr.Uid).To(Equal(107))
+		contents, err := ioutil.ReadAll(tr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("disk contents"))
+	})
+
+	It("Should fail if the disk image does not exist", func() {
+		err = BuildContainerDiskLayout(filepath.Join(tmpDir, "missing.img"), filepath.Join(tmpDir, "layout"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// LUKSHeaderSize is the space set aside for the header of the LUKS image qemu-img writes when the target is
+	// encrypted, in front of the data. The header of qemu-img takes about 2MiB, the rest is headroom.
+	LUKSHeaderSize = 16 << 20
+
+	sourceSecretID = "sourcekey"
+	targetSecretID = "targetkey"
+)
+
+var (
+	// sourceKeyFile and targetKeyFile hold the passphrases, qemu-img reads them itself, so they are not on its command
+	// line
+	sourceKeyFile string
+	targetKeyFile string
+)
+
+// SetSourceKeyFile sets the file with the passphrase that decrypts LUKS and encrypted qcow2 source images, "" for
+// none. A LUKS image is only told apart from a raw image with a passphrase, without one it is imported as it is.
+func SetSourceKeyFile(path string) {
+	sourceKeyFile = path
+}
+
+// SetTargetKeyFile sets the file with the passphrase the converted image is encrypted with as LUKS, "" to write it
+// raw.
+func SetTargetKeyFile(path string) {
+	targetKeyFile = path
+}
+
+// DecryptsSource returns true if a passphrase for encrypted source images is set.
+func DecryptsSource() bool {
+	return sourceKeyFile != ""
+}
+
+// EncryptsTarget returns true if the converted image is encrypted.
+func EncryptsTarget() bool {
+	return targetKeyFile != ""
+}
+
+// isEncrypted returns true if the image needs a passphrase to read its data
+func isEncrypted(info *ImgInfo) bool {
+	return info.Encrypted || info.Format == "luks"
+}
+
+// keySecretOption returns the option of the format of an encrypted image that names the secret with its passphrase
+func keySecretOption(format string) string {
+	if format == "luks" {
+		return "key-secret"
+	}
+	return "encrypt.key-secret"
+}
+
+// secretObject returns the --object arguments of a qemu-img secret read from file
+func secretObject(id, file string) []string {
+	return []string{"--object", fmt.Sprintf("secret,id=%s,file=%s,format=raw", id, file)}
+}
+
+// encryptedSourceArgs returns the --object arguments and the image argument qemu-img opens the encrypted image at url
+// with, decrypted with the source passphrase. If no passphrase is set or the image is not encrypted, the image is
+// opened like any other and nothing is returned.
+func (o *qemuOperations) encryptedSourceArgs(url *url.URL) ([]string, string, error) {
+	if !DecryptsSource() {
+		return nil, "", nil
+	}
+	info, err := o.Info(url)
+	if err != nil {
+		return nil, "", err
+	}
+	if !isEncrypted(info) {
+		klog.V(1).Infof("Image %s is not encrypted, the passphrase is not used", url.String())
+		return nil, "", nil
+	}
+	opts := map[string]interface{}{
+		"driver":                     info.Format,
+		keySecretOption(info.Format): sourceSecretID,
+	}
+	if len(url.Scheme) > 0 {
+		opts["file.driver"] = url.Scheme
+		opts["file.url"] = url.String()
+		opts["file.timeout"] = networkTimeoutSecs
+	} else {
+		opts["file.driver"] = "file"
+		opts["file.filename"] = url.String()
+	}
+	image, err := json.Marshal(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	return secretObject(sourceSecretID, sourceKeyFile), "json:" + string(image), nil
+}
+
+// targetArgs returns the arguments of qemu-img convert that set the format of the converted image
+func targetArgs() []string {
+	if !EncryptsTarget() {
+		return []string{"-O", "raw"}
+	}
+	args := secretObject(targetSecretID, targetKeyFile)
+	return append(args, "-O", "luks", "-o", "key-secret="+targetSecretID)
+}
+
+// asWrongPassphraseError returns a permanent error if the output of qemu-img tells the passphrase does not unlock the
+// image, err otherwise
+func asWrongPassphraseError(output []byte, err error) error {
+	if bytes.Contains(output, []byte("Invalid password")) {
+		return util.NewPermanentError(util.PermanentErrorImageEncrypted, errors.New("The passphrase does not decrypt the image"))
+	}
+	return err
+}
+
+// convertEncrypted converts the image at url to dest, decrypting it with the source passphrase and encrypting it with
+// the target passphrase, if they are set.
+func (o *qemuOperations) convertEncrypted(url *url.URL, dest string) error {
+	objects, image, err := o.encryptedSourceArgs(url)
+	if err != nil {
+		return err
+	}
+	if image == "" {
+		image = url.String()
+		if len(url.Scheme) > 0 {
+			image = fmt.Sprintf("json: {\"file.driver\": \"%s\", \"file.url\": \"%s\", \"file.timeout\": %d}", url.Scheme, url, networkTimeoutSecs)
+		}
+	}
+	args := append([]string{"convert", "-t", "none", "-p"}, objects...)
+	args = append(args, targetArgs()...)
+	args = append(args, image, dest)
+	output, err := qemuExecFunction(nil, reportProgress, "qemu-img", args...)
+	if err != nil {
+		os.Remove(dest)
+		return errors.Wrap(asWrongPassphraseError(output, err), "could not convert encrypted image")
+	}
+	return nil
+}
+
+// resizeEncrypted resizes the LUKS image written with the target passphrase
+func (o *qemuOperations) resizeEncrypted(image string, size resource.Quantity) error {
+	opts, err := json.Marshal(map[string]interface{}{
+		"driver":        "luks",
+		"key-secret":    targetSecretID,
+		"file.driver":   "file",
+		"file.filename": image,
+	})
+	if err != nil {
+		return err
+	}
+	args := append([]string{"resize"}, secretObject(targetSecretID, targetKeyFile)...)
+	args = append(args, "json:"+string(opts), convertQuantityToQemuSize(size))
+	if _, err := qemuExecFunction(nil, nil, "qemu-img", args...); err != nil {
+		return errors.Wrapf(err, "Error resizing encrypted image %s", image)
+	}
+	return nil
+}
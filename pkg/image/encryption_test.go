@@ -0,0 +1,177 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package image
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"kubevirt.io/containerized-data-importer/pkg/system"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const encryptedQcow2InfoJSON = `
+{
+    "virtual-size": 1073741824,
+    "filename": "encrypted.qcow2",
+    "format": "qcow2",
+    "actual-size": 262152192,
+    "encrypted": true
+}
+`
+
+const luksInfoJSON = `
+{
+    "virtual-size": 1073741824,
+    "filename": "encrypted.luks",
+    "format": "luks",
+    "actual-size": 1075838976,
+    "encrypted": true
+}
+`
+
+// encryptionExecFunction answers qemu-img info with info and records the arguments of the other commands, which fail
+// with output and errString if errString is set
+func encryptionExecFunction(info, output, errString string, commands *[][]string) execFunctionType {
+	return func(limits *system.ProcessLimitValues, f func(string), cmd string, args ...string) ([]byte, error) {
+		if args[0] == "info" {
+			return []byte(info), nil
+		}
+		*commands = append(*commands, args)
+		if errString != "" {
+			return []byte(output), errors.New(errString)
+		}
+		return []byte(output), nil
+	}
+}
+
+var _ = Describe("Encrypted images", func() {
+	var commands [][]string
+
+	BeforeEach(func() {
+		commands = nil
+	})
+
+	AfterEach(func() {
+		SetSourceKeyFile("")
+		SetTargetKeyFile("")
+	})
+
+	It("Should decrypt an encrypted qcow2 image with the source passphrase", func() {
+		SetSourceKeyFile("/source-key/passphrase")
+		replaceExecFunction(encryptionExecFunction(encryptedQcow2InfoJSON, "", "", &commands), func() {
+			Expect(ConvertToRawStream(&url.URL{Path: "/scratch/tmpimage"}, "dest")).To(Succeed())
+		})
+		Expect(commands).To(HaveLen(1))
+		Expect(commands[0]).To(ContainElement("secret,id=sourcekey,file=/source-key/passphrase,format=raw"))
+		Expect(commands[0]).To(ContainElement(`json:{"driver":"qcow2","encrypt.key-secret":"sourcekey","file.driver":"file","file.filename":"/scratch/tmpimage"}`))
+		Expect(commands[0]).To(ContainElement("raw"))
+	})
+
+	It("Should decrypt a LUKS image read from a url", func() {
+		SetSourceKeyFile("/source-key/passphrase")
+		ep, err := url.Parse("https://images.example.com/disk.luks")
+		Expect(err).ToNot(HaveOccurred())
+		replaceExecFunction(encryptionExecFunction(luksInfoJSON, "", "", &commands), func() {
+			Expect(ConvertToRawStream(ep, "dest")).To(Succeed())
+		})
+		Expect(commands).To(HaveLen(1))
+		Expect(commands[0]).To(ContainElement(`json:{"driver":"luks","file.driver":"https","file.timeout":3600,"file.url":"https://images.example.com/disk.luks","key-secret":"sourcekey"}`))
+	})
+
+	It("Should convert an image that is not encrypted as it is", func() {
+		SetSourceKeyFile("/source-key/passphrase")
+		replaceExecFunction(encryptionExecFunction(goodValidateJSON, "", "", &commands), func() {
+			Expect(ConvertToRawStream(&url.URL{Path: "/scratch/tmpimage"}, "dest")).To(Succeed())
+		})
+		Expect(commands).To(HaveLen(1))
+		Expect(commands[0]).ToNot(ContainElement("--object"))
+		Expect(commands[0]).To(ContainElement("/scratch/tmpimage"))
+	})
+
+	It("Should encrypt the converted image with the target passphrase", func() {
+		SetTargetKeyFile("/target-key/passphrase")
+		replaceExecFunction(encryptionExecFunction(goodValidateJSON, "", "", &commands), func() {
+			Expect(ConvertToRawStream(&url.URL{Path: "/scratch/tmpimage"}, "dest")).To(Succeed())
+		})
+		Expect(commands).To(HaveLen(1))
+		Expect(commands[0]).To(ContainElement("secret,id=targetkey,file=/target-key/passphrase,format=raw"))
+		Expect(commands[0]).To(ContainElement("luks"))
+		Expect(commands[0]).To(ContainElement("key-secret=targetkey"))
+	})
+
+	It("Should fail permanently if the passphrase does not decrypt the image", func() {
+		SetSourceKeyFile("/source-key/passphrase")
+		replaceExecFunction(encryptionExecFunction(luksInfoJSON, "qemu-img: Invalid password, cannot unlock any keyslot", "exit 1", &commands), func() {
+			err := ConvertToRawStream(&url.URL{Path: "/scratch/tmpimage"}, "dest")
+			Expect(err).To(HaveOccurred())
+			permErr, ok := util.AsPermanentError(err)
+			Expect(ok).To(BeTrue())
+			Expect(permErr.Reason).To(Equal(util.PermanentErrorImageEncrypted))
+		})
+	})
+
+	It("Should fail permanently to validate an encrypted image without a passphrase", func() {
+		replaceExecFunction(encryptionExecFunction(encryptedQcow2InfoJSON, "", "", &commands), func() {
+			err := Validate(&url.URL{Path: "/scratch/tmpimage"}, 2147483648)
+			Expect(err).To(HaveOccurred())
+			permErr, ok := util.AsPermanentError(err)
+			Expect(ok).To(BeTrue())
+			Expect(permErr.Reason).To(Equal(util.PermanentErrorImageEncrypted))
+		})
+	})
+
+	It("Should validate a LUKS image with a passphrase", func() {
+		SetSourceKeyFile("/source-key/passphrase")
+		replaceExecFunction(encryptionExecFunction(luksInfoJSON, "", "", &commands), func() {
+			Expect(Validate(&url.URL{Path: "/scratch/tmpimage"}, 2147483648)).To(Succeed())
+		})
+	})
+
+	It("Should check an encrypted qcow2 image with the source passphrase", func() {
+		SetSourceKeyFile("/source-key/passphrase")
+		replaceExecFunction(encryptionExecFunction(encryptedQcow2InfoJSON, `{"corruptions": 0, "leaks": 0, "check-errors": 0}`, "", &commands), func() {
+			o := NewQEMUOperations()
+			check, err := o.Check(&url.URL{Path: "/scratch/tmpimage"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(check.Corruptions).To(BeZero())
+		})
+		Expect(commands).To(HaveLen(1))
+		Expect(commands[0]).ToNot(ContainElement("-f"))
+		Expect(commands[0]).To(ContainElement("leaks"))
+		Expect(commands[0]).To(ContainElement("secret,id=sourcekey,file=/source-key/passphrase,format=raw"))
+	})
+
+	It("Should resize the encrypted target with the target passphrase", func() {
+		SetTargetKeyFile("/target-key/passphrase")
+		quantity := resource.MustParse("10Gi")
+		replaceExecFunction(encryptionExecFunction("", "", "", &commands), func() {
+			o := NewQEMUOperations()
+			Expect(o.Resize("/data/disk.img", quantity)).To(Succeed())
+		})
+		Expect(commands).To(Equal([][]string{{
+			"resize", "--object", "secret,id=targetkey,file=/target-key/passphrase,format=raw",
+			`json:{"driver":"luks","file.driver":"file","file.filename":"/data/disk.img","key-secret":"targetkey"}`,
+			convertQuantityToQemuSize(quantity),
+		}}))
+	})
+})
@@ -25,6 +25,13 @@ var knownHeaders = Headers{
 		SizeOff: 0,
 		SizeLen: 0,
 	},
+	"luks": Header{
+		Format:      "luks",
+		magicNumber: []byte{'L', 'U', 'K', 'S', 0xba, 0xbe},
+		// the size of the data is not in the header
+		SizeOff: 0,
+		SizeLen: 0,
+	},
 	"qcow2": Header{
 		Format:      "qcow2",
 		magicNumber: []byte{'Q', 'F', 'I', 0xfb},
@@ -0,0 +1,211 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// maxLayoutCandidates is the number of files listed when an image does not follow the containerDisk layout
+const maxLayoutCandidates = 5
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// layerFile is a regular file found in the layers of an image
+type layerFile struct {
+	name string
+	size int64
+}
+
+// cleanLayerPath turns a path in a layer, or a glob, into a relative path without leading ./ or /
+func cleanLayerPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// walkImageLayers calls fn for every entry of the image layers in srcDir, from the bottom layer up.
+// Layers that are not tarballs, or that are compressed with something else than gzip, are skipped.
+func walkImageLayers(srcDir string, manifest *manifest, fn func(hdr *tar.Header, r io.Reader) error) error {
+	layers := manifest.Layers
+	if manifest.SchemaVersion == 1 {
+		// v1 manifests list the top layer first
+		layers = nil
+		for i := len(manifest.FsLayers) - 1; i >= 0; i-- {
+			layers = append(layers, manifest.FsLayers[i])
+		}
+	}
+	for _, l := range layers {
+		layerID := l.Digest
+		if manifest.SchemaVersion == 1 {
+			layerID = l.BlobSum
+		}
+		if err := walkLayer(filepath.Join(srcDir, strings.TrimPrefix(layerID, "sha256:")), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkLayer(file string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Wrap(err, "could not open layer")
+	}
+	defer f.Close()
+
+	var reader io.Reader = bufio.NewReader(f)
+	if magic, _ := reader.(*bufio.Reader).Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return errors.Wrap(err, "could not decompress layer")
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			klog.Warningf("Skipping layer %s, it is not a readable tarball: %v", filepath.Base(file), err)
+			return nil
+		}
+		if err = fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// extractImageFiles extracts the single file matching the diskPath glob from the image layers into destDir.
+// It replaces the containerDisk layout for images that store the disk image elsewhere.
+func extractImageFiles(dest, diskPath, destDir string) error {
+	srcDir := filepath.Join(dest, dataTmpDir)
+	manifest, err := getImageManifest(srcDir)
+	if err != nil {
+		return err
+	}
+	if isArtifact(manifest) {
+		return errors.New("diskPath only applies to container images, the registry image is an OCI artifact")
+	}
+	pattern := cleanLayerPath(diskPath)
+	destDir = filepath.Join(dest, destDir)
+	if err = os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "could not create disk image directory")
+	}
+
+	// A later layer can replace or delete a file of an earlier layer, only the final state counts.
+	matches := make(map[string]string)
+	err = walkImageLayers(srcDir, manifest, func(hdr *tar.Header, r io.Reader) error {
+		name := cleanLayerPath(hdr.Name)
+		if base := path.Base(name); strings.HasPrefix(base, whFilePrefix) {
+			deleted := path.Join(path.Dir(name), strings.TrimPrefix(base, whFilePrefix))
+			if file, ok := matches[deleted]; ok {
+				os.Remove(file)
+				delete(matches, deleted)
+			}
+			return nil
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return nil
+		}
+		if ok, _ := path.Match(pattern, name); !ok {
+			return nil
+		}
+		file := filepath.Join(destDir, path.Base(name))
+		out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return errors.Wrap(err, "could not create disk image")
+		}
+		defer out.Close()
+		if _, err = io.Copy(out, r); err != nil {
+			return errors.Wrapf(err, "could not extract %s", name)
+		}
+		klog.V(1).Infof("Extracted %s matching disk path %s", name, diskPath)
+		matches[name] = file
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch len(matches) {
+	case 0:
+		return describeImageLayout(srcDir, manifest, fmt.Sprintf("no file in the image matches disk path %q", diskPath))
+	case 1:
+		return nil
+	}
+	var names []string
+	for name := range matches {
+		names = append(names, "/"+name)
+	}
+	sort.Strings(names)
+	return errors.Errorf("disk path %q matches %d files in the image, expected 1: %s", diskPath, len(names), strings.Join(names, ", "))
+}
+
+// describeImageLayout explains why no disk image was found in the image layers: it lists the largest files
+// found in the image, and how the image or the DataVolume can be fixed.
+func describeImageLayout(srcDir string, manifest *manifest, reason string) error {
+	files := make(map[string]int64)
+	err := walkImageLayers(srcDir, manifest, func(hdr *tar.Header, r io.Reader) error {
+		name := cleanLayerPath(hdr.Name)
+		if base := path.Base(name); strings.HasPrefix(base, whFilePrefix) {
+			delete(files, path.Join(path.Dir(name), strings.TrimPrefix(base, whFilePrefix)))
+		} else if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			files[name] = hdr.Size
+		}
+		return nil
+	})
+	if err != nil || len(files) == 0 {
+		return errors.Errorf("%s, and the image layers contain no files. Rebuild the image with the disk image in /disk", reason)
+	}
+
+	var candidates []layerFile
+	for name, size := range files {
+		candidates = append(candidates, layerFile{name: "/" + name, size: size})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].size != candidates[j].size {
+			return candidates[i].size > candidates[j].size
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	var found []string
+	for i, c := range candidates {
+		if i == maxLayoutCandidates {
+			found = append(found, fmt.Sprintf("and %d more", len(candidates)-maxLayoutCandidates))
+			break
+		}
+		found = append(found, fmt.Sprintf("%s (%d bytes)", c.name, c.size))
+	}
+	return errors.Errorf("%s. Largest files in the image layers: %s. Rebuild the image with the disk image in /disk, "+
+		"or set diskPath in the registry source of the DataVolume to a glob matching the disk image, for instance %q",
+		reason, strings.Join(found, ", "), candidates[0].name)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package image
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Image layout", func() {
+	var tmpDir string
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "layout-test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(tmpDir, dataTmpDir), os.ModePerm)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	// writeLayers writes an image in skopeo dir format, each layer is a list of name, content pairs
+	writeLayers := func(layers ...[]string) {
+		m := manifest{SchemaVersion: 2}
+		for i, files := range layers {
+			digest := "sha256:" + string(rune('a'+i))
+			f, err := os.Create(filepath.Join(tmpDir, dataTmpDir, digest[len("sha256:"):]))
+			Expect(err).NotTo(HaveOccurred())
+			var w io.WriteCloser = f
+			if i%2 == 0 {
+				// Mix compressed and uncompressed layers
+				w = gzip.NewWriter(f)
+			}
+			tw := tar.NewWriter(w)
+			for j := 0; j < len(files); j += 2 {
+				Expect(tw.WriteHeader(&tar.Header{Name: files[j], Mode: 0644, Size: int64(len(files[j+1])), Typeflag: tar.TypeReg})).To(Succeed())
+				_, err = tw.Write([]byte(files[j+1]))
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(tw.Close()).To(Succeed())
+			Expect(w.Close()).To(Succeed())
+			f.Close()
+			m.Layers = append(m.Layers, layer{Digest: digest, MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip"})
+		}
+		data, err := json.Marshal(m)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, dataTmpDir, "manifest.json"), data, 0644)).To(Succeed())
+	}
+
+	It("Should extract the file matching the disk path", func() {
+		writeLayers(
+			[]string{"etc/os-release", "fedora", "./images/fedora.qcow2", "old disk"},
+			[]string{"images/fedora.qcow2", "new disk", "images/README", "readme"},
+		)
+		Expect(extractImageFiles(tmpDir, "/images/*.qcow2", "disk")).To(Succeed())
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, "disk", "fedora.qcow2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("new disk"))
+		entries, err := ioutil.ReadDir(filepath.Join(tmpDir, "disk"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("Should fail if the disk path matches several files", func() {
+		writeLayers([]string{"images/a.qcow2", "a", "images/b.qcow2", "b"})
+		err := extractImageFiles(tmpDir, "images/*.qcow2", "disk")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("matches 2 files in the image, expected 1: /images/a.qcow2, /images/b.qcow2"))
+	})
+
+	It("Should describe the image if a later layer deleted the matching file", func() {
+		writeLayers(
+			[]string{"images/fedora.qcow2", "disk", "usr/bin/tool", "binary"},
+			[]string{"images/.wh.fedora.qcow2", ""},
+		)
+		err := extractImageFiles(tmpDir, "images/*.qcow2", "disk")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`no file in the image matches disk path "images/*.qcow2"`))
+		Expect(err.Error()).To(ContainSubstring("/usr/bin/tool (6 bytes)"))
+		Expect(err.Error()).ToNot(ContainSubstring("/images/fedora.qcow2 ("))
+		_, err = os.Stat(filepath.Join(tmpDir, "disk", "fedora.qcow2"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("Should list the largest files and suggest a disk path", func() {
+		writeLayers(
+			[]string{"a", "1", "b", "22", "c", "333"},
+			[]string{"d", "4444", "e", "55555", "vm/disk.raw", "666666"},
+		)
+		m, err := getImageManifest(filepath.Join(tmpDir, dataTmpDir))
+		Expect(err).NotTo(HaveOccurred())
+		err = describeImageLayout(filepath.Join(tmpDir, dataTmpDir), m, "no disk")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("no disk. Largest files in the image layers: /vm/disk.raw (6 bytes), /e (5 bytes), /d (4 bytes), /c (3 bytes), /b (2 bytes), and 1 more."))
+		Expect(err.Error()).To(HaveSuffix(`for instance "/vm/disk.raw"`))
+	})
+
+	It("Should tell when the image has no files", func() {
+		writeLayers([]string{})
+		m, err := getImageManifest(filepath.Join(tmpDir, dataTmpDir))
+		Expect(err).NotTo(HaveOccurred())
+		err = describeImageLayout(filepath.Join(tmpDir, dataTmpDir), m, "no disk")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("the image layers contain no files"))
+	})
+})
@@ -0,0 +1,522 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// Qcow2MaxStreamBuffer is the most memory a qcow2 image converted while it is read holds on to, for the clusters
+	// it reads before the tables that map them
+	Qcow2MaxStreamBuffer = 256 << 20
+
+	// qcow2Magic starts the header of a qcow2 image, "QFI\xfb"
+	qcow2Magic = 0x514649fb
+	// qcow2HeaderSize is the size of the header of a version 3 qcow2 image, version 2 uses the first 72 bytes
+	qcow2HeaderSize = 104
+	// qcow2OffsetMask masks the host offset of an L1 entry or of a standard L2 entry
+	qcow2OffsetMask = 0x00fffffffffffe00
+	// qcow2CompressedFlag marks an L2 entry of a compressed cluster
+	qcow2CompressedFlag = 1 << 62
+	// qcow2ZeroFlag marks an L2 entry of a cluster that reads as zeros, in version 3 images
+	qcow2ZeroFlag = 1
+	// qcow2IncompatibleDirty is the only incompatible feature a qcow2 image is converted with, its refcounts are
+	// not used
+	qcow2IncompatibleDirty = 1
+	// qcow2ZeroWriteSize is the size of the writes that zero the clusters the image does not allocate
+	qcow2ZeroWriteSize = 1 << 20
+)
+
+// Qcow2NotStreamableError indicates a qcow2 image cannot be converted while it is read, it has to be converted from
+// scratch space instead.
+type Qcow2NotStreamableError struct {
+	Reason string
+}
+
+func (e *Qcow2NotStreamableError) Error() string {
+	return fmt.Sprintf("the qcow2 image cannot be converted while it is uploaded: %s", e.Reason)
+}
+
+// qcow2CompressedExtent is the compressed data of a guest cluster
+type qcow2CompressedExtent struct {
+	offset int64
+	length int64
+	guest  int64
+}
+
+// qcow2Stream converts a qcow2 image to raw while it is read in order. A cluster is written to the guest offset it
+// maps to once the L2 table that maps it is read, the clusters read before the table are held in memory until then.
+type qcow2Stream struct {
+	r           io.Reader
+	w           io.WriterAt
+	maxBuffered int64
+
+	version     uint32
+	clusterBits uint32
+	clusterSize int64
+	virtualSize int64
+	l1Offset    int64
+	l1Bytes     []byte
+	l1Read      int64
+	// refcountTable is the location of the refcount table, its refcount blocks are skipped
+	refcountTableOffset int64
+	refcountTableSize   int64
+	refcountTable       []byte
+	refcountTableRead   int64
+
+	// pos is the offset in the image of the next cluster read
+	pos int64
+	// skipped are the host clusters that hold metadata that is not needed for the conversion
+	skipped map[int64]bool
+	// l2Tables maps the host clusters of L2 tables to the guest offset of their first entry
+	l2Tables map[int64]int64
+	// data maps host clusters to the guest offsets they are written to
+	data map[int64][]int64
+	// needed counts the compressed extents not decompressed yet that a host cluster holds data of
+	needed map[int64]int
+	// pendingByLast are the compressed extents not decompressed yet, by the last host cluster they need
+	pendingByLast map[int64][]*qcow2CompressedExtent
+	// buffered are the host clusters read before it was known what they hold
+	buffered     map[int64][]byte
+	bufferedSize int64
+	// zeros are the host clusters read that only hold zeros, they are not buffered
+	zeros map[int64]bool
+	// written has a bit set for each guest cluster that was written
+	written []uint64
+}
+
+// ConvertQcow2Stream converts the qcow2 image read from r to a raw image written to w, without seeking r. It is meant
+// for images whose tables are stored before the data they map, like those written by qemu-img convert. Clusters that
+// come before the tables mapping them are held in memory, a Qcow2NotStreamableError is returned if they need more
+// than maxBuffered bytes. The guest clusters the image does not allocate are written with zeros.
+func ConvertQcow2Stream(r io.Reader, w io.WriterAt, maxBuffered int64) error {
+	s := &qcow2Stream{
+		r:             r,
+		w:             w,
+		maxBuffered:   maxBuffered,
+		skipped:       map[int64]bool{},
+		l2Tables:      map[int64]int64{},
+		data:          map[int64][]int64{},
+		needed:        map[int64]int{},
+		pendingByLast: map[int64][]*qcow2CompressedExtent{},
+		buffered:      map[int64][]byte{},
+		zeros:         map[int64]bool{},
+	}
+	if err := s.readHeader(); err != nil {
+		return err
+	}
+	cluster := make([]byte, s.clusterSize)
+	for {
+		n, err := io.ReadFull(s.r, cluster)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return errors.Wrap(err, "could not read the qcow2 image")
+		}
+		for i := n; i < len(cluster); i++ {
+			cluster[i] = 0
+		}
+		if err := s.processCluster(cluster); err != nil {
+			return err
+		}
+		s.pos += s.clusterSize
+		if n < len(cluster) {
+			break
+		}
+	}
+	return s.finish()
+}
+
+// readHeader reads the header of the image from the first cluster, the rest of the cluster holds header extensions
+// that are not needed for the conversion.
+func (s *qcow2Stream) readHeader() error {
+	header := make([]byte, qcow2HeaderSize)
+	n, err := io.ReadFull(s.r, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return errors.Wrap(err, "could not read the qcow2 header")
+	}
+	if n < 72 || binary.BigEndian.Uint32(header[0:4]) != qcow2Magic {
+		return errors.New("not a qcow2 image")
+	}
+	s.version = binary.BigEndian.Uint32(header[4:8])
+	if s.version != 2 && s.version != 3 {
+		return &Qcow2NotStreamableError{Reason: fmt.Sprintf("qcow2 version %d is not supported", s.version)}
+	}
+	if binary.BigEndian.Uint64(header[8:16]) != 0 {
+		return &Qcow2NotStreamableError{Reason: "the image has a backing file"}
+	}
+	s.clusterBits = binary.BigEndian.Uint32(header[20:24])
+	if s.clusterBits < 9 || s.clusterBits > 21 {
+		return errors.Errorf("invalid qcow2 cluster bits %d", s.clusterBits)
+	}
+	s.clusterSize = 1 << s.clusterBits
+	s.virtualSize = int64(binary.BigEndian.Uint64(header[24:32]))
+	if binary.BigEndian.Uint32(header[32:36]) != 0 {
+		return &Qcow2NotStreamableError{Reason: "the image is encrypted"}
+	}
+	l1Size := int64(binary.BigEndian.Uint32(header[36:40]))
+	s.l1Offset = int64(binary.BigEndian.Uint64(header[40:48]))
+	s.refcountTableOffset = int64(binary.BigEndian.Uint64(header[48:56]))
+	s.refcountTableSize = int64(binary.BigEndian.Uint32(header[56:60])) * s.clusterSize
+	if s.version == 3 {
+		if n < qcow2HeaderSize {
+			return errors.New("the qcow2 version 3 header is truncated")
+		}
+		if incompatible := binary.BigEndian.Uint64(header[72:80]); incompatible&^qcow2IncompatibleDirty != 0 {
+			return &Qcow2NotStreamableError{Reason: fmt.Sprintf("the image has incompatible features 0x%x", incompatible)}
+		}
+	}
+	if s.l1Offset%s.clusterSize != 0 || s.refcountTableOffset%s.clusterSize != 0 {
+		return errors.New("the qcow2 tables are not aligned to clusters")
+	}
+	if l1Size*8 > s.maxBuffered {
+		return errors.Errorf("invalid qcow2 L1 table size %d", l1Size)
+	}
+	if (l1Size > 0 && s.l1Offset < s.clusterSize) || s.refcountTableOffset < s.clusterSize {
+		return errors.New("the qcow2 tables overlap the header")
+	}
+	s.l1Bytes = make([]byte, l1Size*8)
+	s.refcountTable = make([]byte, s.refcountTableSize)
+	clusters := (s.virtualSize + s.clusterSize - 1) / s.clusterSize
+	s.written = make([]uint64, (clusters+63)/64)
+	klog.V(3).Infof("Converting a qcow2 image of %d bytes with %d byte clusters while reading it", s.virtualSize, s.clusterSize)
+
+	rest := make([]byte, s.clusterSize-qcow2HeaderSize)
+	if _, err := io.ReadFull(s.r, rest); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return errors.Wrap(err, "could not read the qcow2 header")
+	}
+	s.pos = s.clusterSize
+	return nil
+}
+
+// processCluster handles the host cluster at s.pos by what it is known to hold
+func (s *qcow2Stream) processCluster(cluster []byte) error {
+	c := s.pos
+	switch {
+	case c >= s.l1Offset && c < s.l1Offset+int64(len(s.l1Bytes)):
+		copy(s.l1Bytes[c-s.l1Offset:], cluster)
+		s.l1Read += s.clusterSize
+		if s.l1Read >= int64(len(s.l1Bytes)) {
+			return s.readL1()
+		}
+	case c >= s.refcountTableOffset && c < s.refcountTableOffset+s.refcountTableSize:
+		copy(s.refcountTable[c-s.refcountTableOffset:], cluster)
+		s.refcountTableRead += s.clusterSize
+		if s.refcountTableRead >= s.refcountTableSize {
+			s.readRefcountTable()
+		}
+	case s.skipped[c]:
+	default:
+		if guest, ok := s.l2Tables[c]; ok {
+			delete(s.l2Tables, c)
+			return s.readL2(cluster, guest)
+		}
+		if guests, ok := s.data[c]; ok {
+			delete(s.data, c)
+			return s.writeData(cluster, guests)
+		}
+		if s.needed[c] > 0 {
+			s.buffer(c, cluster)
+			return s.decompressPending(c)
+		}
+		if isZeros(cluster) {
+			s.zeros[c] = true
+			return nil
+		}
+		s.buffer(c, cluster)
+		if s.bufferedSize > s.maxBuffered {
+			return &Qcow2NotStreamableError{Reason: fmt.Sprintf("more than %d bytes of the image come before the tables that map them", s.maxBuffered)}
+		}
+	}
+	return nil
+}
+
+// buffer holds a copy of the host cluster c
+func (s *qcow2Stream) buffer(c int64, cluster []byte) {
+	s.buffered[c] = append([]byte(nil), cluster...)
+	s.bufferedSize += s.clusterSize
+}
+
+// release drops the buffered host cluster c
+func (s *qcow2Stream) release(c int64) {
+	if _, ok := s.buffered[c]; ok {
+		delete(s.buffered, c)
+		s.bufferedSize -= s.clusterSize
+	}
+}
+
+// passed returns the host cluster c that was read already, nil if it only holds zeros
+func (s *qcow2Stream) passed(c int64) ([]byte, error) {
+	if cluster, ok := s.buffered[c]; ok {
+		return cluster, nil
+	}
+	if s.zeros[c] {
+		return nil, nil
+	}
+	return nil, errors.Errorf("the qcow2 cluster at %d was not kept, the image is corrupt", c)
+}
+
+// readL1 registers the L2 tables the L1 table maps, and reads those already buffered
+func (s *qcow2Stream) readL1() error {
+	entriesPerTable := s.clusterSize / 8
+	for i := 0; i*8 < len(s.l1Bytes); i++ {
+		offset := int64(binary.BigEndian.Uint64(s.l1Bytes[i*8:]) & qcow2OffsetMask)
+		if offset == 0 {
+			continue
+		}
+		guest := int64(i) * entriesPerTable * s.clusterSize
+		if offset >= s.pos {
+			s.l2Tables[offset] = guest
+			continue
+		}
+		cluster, err := s.passed(offset)
+		if err != nil {
+			return err
+		}
+		if cluster == nil {
+			continue
+		}
+		s.release(offset)
+		if err := s.readL2(cluster, guest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRefcountTable skips the refcount blocks of the image, and drops those already buffered
+func (s *qcow2Stream) readRefcountTable() {
+	for i := 0; i*8 < len(s.refcountTable); i++ {
+		offset := int64(binary.BigEndian.Uint64(s.refcountTable[i*8:]) &^ (uint64(s.clusterSize) - 1))
+		if offset == 0 {
+			continue
+		}
+		s.skipped[offset] = true
+		s.release(offset)
+	}
+	s.refcountTable = nil
+}
+
+// readL2 maps the data clusters of the L2 table, whose first entry maps the guest offset guest
+func (s *qcow2Stream) readL2(table []byte, guest int64) error {
+	for i := 0; i*8 < len(table); i++ {
+		g := guest + int64(i)*s.clusterSize
+		if g >= s.virtualSize {
+			break
+		}
+		entry := binary.BigEndian.Uint64(table[i*8:])
+		switch {
+		case entry&qcow2CompressedFlag != 0:
+			shift := 62 - (s.clusterBits - 8)
+			offset := int64(entry & (1<<shift - 1))
+			sectors := int64((entry>>shift)&(1<<(s.clusterBits-8)-1)) + 1
+			if err := s.addCompressed(&qcow2CompressedExtent{offset: offset, length: sectors*512 - offset%512, guest: g}); err != nil {
+				return err
+			}
+		case s.version == 3 && entry&qcow2ZeroFlag != 0:
+			// Left to the zeroing of the clusters not written
+		case entry&qcow2OffsetMask != 0:
+			offset := int64(entry & qcow2OffsetMask)
+			if offset >= s.pos {
+				s.data[offset] = append(s.data[offset], g)
+				continue
+			}
+			cluster, err := s.passed(offset)
+			if err != nil {
+				return err
+			}
+			if cluster == nil {
+				continue
+			}
+			if err := s.writeData(cluster, []int64{g}); err != nil {
+				return err
+			}
+			s.release(offset)
+		}
+	}
+	return nil
+}
+
+// writeData writes the data cluster to the guest offsets it maps to
+func (s *qcow2Stream) writeData(cluster []byte, guests []int64) error {
+	for _, g := range guests {
+		if err := s.writeGuest(cluster, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGuest writes the guest cluster at guest, the last cluster is cut to the virtual size
+func (s *qcow2Stream) writeGuest(cluster []byte, guest int64) error {
+	if guest+int64(len(cluster)) > s.virtualSize {
+		cluster = cluster[:s.virtualSize-guest]
+	}
+	if _, err := s.w.WriteAt(cluster, guest); err != nil {
+		return errors.Wrapf(err, "could not write the guest cluster at %d", guest)
+	}
+	c := guest / s.clusterSize
+	s.written[c/64] |= 1 << uint(c%64)
+	return nil
+}
+
+// addCompressed registers the compressed extent, it is decompressed once the last host cluster it needs is read
+func (s *qcow2Stream) addCompressed(extent *qcow2CompressedExtent) error {
+	first := extent.offset &^ (s.clusterSize - 1)
+	last := (extent.offset + extent.length - 1) &^ (s.clusterSize - 1)
+	for c := first; c <= last; c += s.clusterSize {
+		s.needed[c]++
+	}
+	if last >= s.pos {
+		s.pendingByLast[last] = append(s.pendingByLast[last], extent)
+		return nil
+	}
+	return s.decompress(extent)
+}
+
+// decompressPending decompresses the extents whose last host cluster is c
+func (s *qcow2Stream) decompressPending(c int64) error {
+	extents := s.pendingByLast[c]
+	delete(s.pendingByLast, c)
+	for _, extent := range extents {
+		if err := s.decompress(extent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decompress writes the guest cluster of the compressed extent, and drops the host clusters no other extent needs. The
+// host clusters past the end of the image read as zeros, the last extent may be shorter than its entry says.
+func (s *qcow2Stream) decompress(extent *qcow2CompressedExtent) error {
+	var compressed bytes.Buffer
+	first := extent.offset &^ (s.clusterSize - 1)
+	for c := first; c < extent.offset+extent.length; c += s.clusterSize {
+		cluster := s.buffered[c]
+		if cluster == nil && c < s.pos {
+			var err error
+			if cluster, err = s.passed(c); err != nil {
+				return err
+			}
+		}
+		if cluster == nil {
+			cluster = make([]byte, s.clusterSize)
+		}
+		start, end := int64(0), s.clusterSize
+		if c < extent.offset {
+			start = extent.offset - c
+		}
+		if c+end > extent.offset+extent.length {
+			end = extent.offset + extent.length - c
+		}
+		compressed.Write(cluster[start:end])
+		if s.needed[c]--; s.needed[c] <= 0 {
+			delete(s.needed, c)
+			s.release(c)
+		}
+	}
+	cluster := make([]byte, s.clusterSize)
+	if _, err := io.ReadFull(flate.NewReader(&compressed), cluster); err != nil {
+		return errors.Wrapf(err, "could not decompress the guest cluster at %d", extent.guest)
+	}
+	return s.writeGuest(cluster, extent.guest)
+}
+
+// finish decompresses the extents that reach past the end of the image, and zeros the guest clusters not written
+func (s *qcow2Stream) finish() error {
+	if len(s.l1Bytes) > 0 && s.l1Read < int64(len(s.l1Bytes)) {
+		return errors.New("the qcow2 image ends before its L1 table")
+	}
+	for c := range s.l2Tables {
+		if c >= s.pos {
+			return errors.Errorf("the qcow2 image ends before its L2 table at %d", c)
+		}
+	}
+	for c := range s.data {
+		if c >= s.pos {
+			return errors.Errorf("the qcow2 image ends before its data cluster at %d", c)
+		}
+	}
+	for c, extents := range s.pendingByLast {
+		for _, extent := range extents {
+			if extent.offset >= s.pos {
+				return errors.Errorf("the qcow2 image ends before its compressed cluster at %d", extent.offset)
+			}
+		}
+		if err := s.decompressPending(c); err != nil {
+			return err
+		}
+	}
+	zeros := make([]byte, qcow2ZeroWriteSize)
+	clusters := (s.virtualSize + s.clusterSize - 1) / s.clusterSize
+	for c := int64(0); c < clusters; c++ {
+		if s.isWritten(c) {
+			continue
+		}
+		start := c
+		for c+1 < clusters && !s.isWritten(c+1) {
+			c++
+		}
+		end := (c + 1) * s.clusterSize
+		if end > s.virtualSize {
+			end = s.virtualSize
+		}
+		if err := s.writeZeros(start*s.clusterSize, end, zeros); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isWritten returns true if the guest cluster c was written
+func (s *qcow2Stream) isWritten(c int64) bool {
+	return s.written[c/64]&(1<<uint(c%64)) != 0
+}
+
+// writeZeros writes zeros from start to end
+func (s *qcow2Stream) writeZeros(start, end int64, zeros []byte) error {
+	for start < end {
+		n := end - start
+		if n > int64(len(zeros)) {
+			n = int64(len(zeros))
+		}
+		if _, err := s.w.WriteAt(zeros[:n], start); err != nil {
+			return errors.Wrapf(err, "could not zero the guest clusters at %d", start)
+		}
+		start += n
+	}
+	return nil
+}
+
+// isZeros returns true if b only holds zeros
+func isZeros(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
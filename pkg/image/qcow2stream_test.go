@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testQcow2ClusterSize = 512
+
+// testQcow2Image returns a version 3 qcow2 image with 512 byte clusters of virtualSize bytes, that maps the guest
+// clusters of data. Its header, refcount table and refcount block, and L1 table take the first four clusters, the L2
+// table follows them, or the data clusters if dataFirst is set.
+func testQcow2Image(virtualSize int64, data map[int64][]byte, dataFirst bool) []byte {
+	cluster := func() []byte {
+		return make([]byte, testQcow2ClusterSize)
+	}
+	header := cluster()
+	binary.BigEndian.PutUint32(header[0:], qcow2Magic)
+	binary.BigEndian.PutUint32(header[4:], 3)
+	binary.BigEndian.PutUint32(header[20:], 9)
+	binary.BigEndian.PutUint64(header[24:], uint64(virtualSize))
+	binary.BigEndian.PutUint32(header[36:], 1)
+	binary.BigEndian.PutUint64(header[40:], 3*testQcow2ClusterSize)
+	binary.BigEndian.PutUint64(header[48:], testQcow2ClusterSize)
+	binary.BigEndian.PutUint32(header[56:], 1)
+	binary.BigEndian.PutUint32(header[100:], qcow2HeaderSize)
+	refcountTable := cluster()
+	binary.BigEndian.PutUint64(refcountTable, 2*testQcow2ClusterSize)
+	refcountBlock := bytes.Repeat([]byte{0, 1}, testQcow2ClusterSize/2)
+
+	l2Offset := int64(4 * testQcow2ClusterSize)
+	dataOffset := l2Offset + testQcow2ClusterSize
+	if dataFirst {
+		l2Offset = int64(4+len(data)) * testQcow2ClusterSize
+		dataOffset = 4 * testQcow2ClusterSize
+	}
+	l1 := cluster()
+	binary.BigEndian.PutUint64(l1, uint64(l2Offset)|1<<63)
+	l2 := cluster()
+	var dataClusters []byte
+	for guest := int64(0); guest*testQcow2ClusterSize < virtualSize; guest++ {
+		if content, ok := data[guest]; ok {
+			binary.BigEndian.PutUint64(l2[guest*8:], uint64(dataOffset+int64(len(dataClusters)))|1<<63)
+			dataClusters = append(dataClusters, content...)
+		}
+	}
+	image := append(append(append(header, refcountTable...), refcountBlock...), l1...)
+	if dataFirst {
+		return append(append(image, dataClusters...), l2...)
+	}
+	return append(append(image, l2...), dataClusters...)
+}
+
+var _ = Describe("Qcow2 stream conversion", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "qcow2-stream")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	convert := func(image []byte, target string, maxBuffered int64) error {
+		f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE, 0644)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		return ConvertQcow2Stream(bytes.NewReader(image), f, maxBuffered)
+	}
+
+	It("Should convert an image with compressed clusters to the same raw image as qemu-img", func() {
+		image, err := ioutil.ReadFile(filepath.Join(testImagesDir, "cirros-qcow2.img"))
+		Expect(err).ToNot(HaveOccurred())
+		expected, err := ioutil.ReadFile(filepath.Join(testImagesDir, "cirros.raw"))
+		Expect(err).ToNot(HaveOccurred())
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(convert(image, target, Qcow2MaxStreamBuffer)).To(Succeed())
+		converted, err := ioutil.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(bytes.Equal(converted, expected)).To(BeTrue())
+	})
+
+	It("Should zero the clusters the image does not allocate", func() {
+		image := testQcow2Image(4*testQcow2ClusterSize, map[int64][]byte{1: bytes.Repeat([]byte{'a'}, testQcow2ClusterSize)}, false)
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(ioutil.WriteFile(target, bytes.Repeat([]byte{'x'}, 4*testQcow2ClusterSize), 0644)).To(Succeed())
+		Expect(convert(image, target, testQcow2ClusterSize)).To(Succeed())
+		converted, err := ioutil.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		expected := append(append(make([]byte, testQcow2ClusterSize), bytes.Repeat([]byte{'a'}, testQcow2ClusterSize)...), make([]byte, 2*testQcow2ClusterSize)...)
+		Expect(converted).To(Equal(expected))
+	})
+
+	It("Should hold the data clusters that come before their L2 table", func() {
+		data := map[int64][]byte{
+			0: bytes.Repeat([]byte{'a'}, testQcow2ClusterSize),
+			2: bytes.Repeat([]byte{'b'}, testQcow2ClusterSize),
+		}
+		image := testQcow2Image(3*testQcow2ClusterSize, data, true)
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(convert(image, target, 2*testQcow2ClusterSize)).To(Succeed())
+		converted, err := ioutil.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted).To(Equal(append(append(data[0], make([]byte, testQcow2ClusterSize)...), data[2]...)))
+	})
+
+	It("Should refuse an image that needs more memory than allowed", func() {
+		data := map[int64][]byte{
+			0: bytes.Repeat([]byte{'a'}, testQcow2ClusterSize),
+			2: bytes.Repeat([]byte{'b'}, testQcow2ClusterSize),
+		}
+		image := testQcow2Image(3*testQcow2ClusterSize, data, true)
+		err := convert(image, filepath.Join(tmpDir, "disk.img"), testQcow2ClusterSize)
+		Expect(err).To(BeAssignableToTypeOf(&Qcow2NotStreamableError{}))
+	})
+
+	It("Should refuse an image with a backing file", func() {
+		image := testQcow2Image(testQcow2ClusterSize, nil, false)
+		binary.BigEndian.PutUint64(image[8:], 4096)
+		err := convert(image, filepath.Join(tmpDir, "disk.img"), testQcow2ClusterSize)
+		Expect(err).To(BeAssignableToTypeOf(&Qcow2NotStreamableError{}))
+		Expect(err.Error()).To(ContainSubstring("backing file"))
+	})
+
+	It("Should fail if the image ends before the data it maps", func() {
+		image := testQcow2Image(2*testQcow2ClusterSize, map[int64][]byte{1: bytes.Repeat([]byte{'a'}, testQcow2ClusterSize)}, false)
+		err := convert(image[:len(image)-testQcow2ClusterSize], filepath.Join(tmpDir, "disk.img"), testQcow2ClusterSize)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("ends before its data cluster"))
+	})
+})
@@ -17,6 +17,7 @@ limitations under the License.
 package image
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -47,10 +48,36 @@ type ImgInfo struct {
 	Format string `json:"format"`
 	// BackingFile is the file name of the backing file
 	BackingFile string `json:"backing-filename"`
+	// BackingFormat is the format of the backing file, when recorded in the image
+	BackingFormat string `json:"backing-filename-format"`
 	// VirtualSize is the disk size of the image which will be read by vm
 	VirtualSize int64 `json:"virtual-size"`
 	// ActualSize is the size of the qcow2 image
 	ActualSize int64 `json:"actual-size"`
+	// Encrypted is true if the data of the image is encrypted
+	Encrypted bool `json:"encrypted"`
+}
+
+// ImgCheck contains the result of checking the consistency of an image.
+type ImgCheck struct {
+	// Corruptions is the number of corruptions found, the image data may be wrong
+	Corruptions int64 `json:"corruptions"`
+	// Leaks is the number of leaked clusters, allocated but unused, they waste space but do not harm the data
+	Leaks int64 `json:"leaks"`
+	// CheckErrors is the number of errors that kept parts of the image from being checked
+	CheckErrors int64 `json:"check-errors"`
+	// LeaksFixed is the number of leaked clusters that were repaired
+	LeaksFixed int64 `json:"leaks-fixed"`
+}
+
+// ImageTooLargeError indicates that the virtual size of an image is larger than the space available to it.
+type ImageTooLargeError struct {
+	VirtualSize   int64
+	AvailableSize int64
+}
+
+func (e *ImageTooLargeError) Error() string {
+	return fmt.Sprintf("Virtual image size %d is larger than available size %d, shrink not yet supported.", e.VirtualSize, e.AvailableSize)
 }
 
 // QEMUOperations defines the interface for executing qemu subprocesses
@@ -60,6 +87,8 @@ type QEMUOperations interface {
 	Info(url *url.URL) (*ImgInfo, error)
 	Validate(*url.URL, int64) error
 	CreateBlankImage(string, resource.Quantity) error
+	Rebase(image, backingFile, backingFormat string) error
+	Check(url *url.URL, repairLeaks bool) (*ImgCheck, error)
 }
 
 type qemuOperations struct{}
@@ -109,6 +138,9 @@ func convertToRaw(src, dest string) error {
 }
 
 func (o *qemuOperations) ConvertToRawStream(url *url.URL, dest string) error {
+	if DecryptsSource() || EncryptsTarget() {
+		return o.convertEncrypted(url, dest)
+	}
 	if len(url.Scheme) == 0 {
 		// File, instead of URL
 		return convertToRaw(url.String(), dest)
@@ -136,6 +168,9 @@ func convertQuantityToQemuSize(size resource.Quantity) string {
 }
 
 func (o *qemuOperations) Resize(image string, size resource.Quantity) error {
+	if EncryptsTarget() {
+		return o.resizeEncrypted(image, size)
+	}
 	_, err := qemuExecFunction(nil, nil, "qemu-img", "resize", "-f", "raw", image, convertQuantityToQemuSize(size))
 	if err != nil {
 		return errors.Wrapf(err, "Error resizing image %s", image)
@@ -168,7 +203,7 @@ func (o *qemuOperations) Info(url *url.URL) (*ImgInfo, error) {
 
 func isSupportedFormat(value string) bool {
 	switch value {
-	case "raw", "qcow2":
+	case "raw", "qcow2", "luks":
 		return true
 	default:
 		return false
@@ -181,8 +216,12 @@ func (o *qemuOperations) Validate(url *url.URL, availableSize int64) error {
 		return err
 	}
 
+	if isEncrypted(info) && !DecryptsSource() {
+		return util.NewPermanentError(util.PermanentErrorImageEncrypted, errors.Errorf("Image %s is encrypted, a passphrase is needed to import it", url.String()))
+	}
+
 	if !isSupportedFormat(info.Format) {
-		return errors.Errorf("Invalid format %s for image %s", info.Format, url.String())
+		return util.NewPermanentError(util.PermanentErrorUnsupportedFormat, errors.Errorf("Invalid format %s for image %s", info.Format, url.String()))
 	}
 
 	if len(info.BackingFile) > 0 {
@@ -190,11 +229,64 @@ func (o *qemuOperations) Validate(url *url.URL, availableSize int64) error {
 	}
 
 	if availableSize < info.VirtualSize {
-		return errors.Errorf("Virtual image size %d is larger than available size %d, shrink not yet supported.", info.VirtualSize, availableSize)
+		return &ImageTooLargeError{VirtualSize: info.VirtualSize, AvailableSize: availableSize}
+	}
+	return nil
+}
+
+// Rebase points the qcow2 image to a backing file containing the same data, without reading the data.
+// An empty backingFile copies the data of the backing chain into the image instead, flattening it.
+func (o *qemuOperations) Rebase(image, backingFile, backingFormat string) error {
+	var err error
+	if backingFile == "" {
+		_, err = qemuExecFunction(nil, nil, "qemu-img", "rebase", "-t", "none", "-f", "qcow2", "-b", "", image)
+	} else {
+		_, err = qemuExecFunction(nil, nil, "qemu-img", "rebase", "-u", "-f", "qcow2", "-b", backingFile, "-F", backingFormat, image)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not rebase image %s", image)
 	}
 	return nil
 }
 
+// Check checks the consistency of the qcow2 image at url. Leaked clusters of a local image are repaired if
+// repairLeaks is true, an image read from a URL is only checked.
+func (o *qemuOperations) Check(url *url.URL, repairLeaks bool) (*ImgCheck, error) {
+	objects, image, err := o.encryptedSourceArgs(url)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"check", "--output=json"}
+	if image == "" {
+		// An encrypted image has its format in its options
+		args = append(args, "-f", "qcow2")
+		image = url.String()
+		if len(url.Scheme) > 0 {
+			image = fmt.Sprintf("json: {\"file.driver\": \"%s\", \"file.url\": \"%s\", \"file.timeout\": %d}", url.Scheme, url, networkTimeoutSecs)
+		}
+	}
+	if repairLeaks && len(url.Scheme) == 0 {
+		args = append(args, "-r", "leaks")
+	}
+	args = append(append(args, objects...), image)
+	// qemu-img check exits with 2 on corruptions and 3 on leaks, the result is in the output all the same
+	output, execErr := qemuExecFunction(nil, nil, "qemu-img", args...)
+	// Errors found are printed next to the json result
+	start, end := bytes.IndexByte(output, '{'), bytes.LastIndexByte(output, '}')
+	if start < 0 || end < start {
+		if execErr != nil {
+			return nil, errors.Wrapf(execErr, "Error checking image %s", url.String())
+		}
+		return nil, errors.Errorf("No check result for image %s", url.String())
+	}
+	var check ImgCheck
+	if err := json.Unmarshal(output[start:end+1], &check); err != nil {
+		klog.Errorf("Invalid JSON:\n%s\n", string(output))
+		return nil, errors.Wrapf(err, "Invalid json for image %s", url.String())
+	}
+	return &check, nil
+}
+
 // ConvertToRawStream converts an http accessible image to raw format without locally caching the image
 func ConvertToRawStream(url *url.URL, dest string) error {
 	return qemuIterface.ConvertToRawStream(url, dest)
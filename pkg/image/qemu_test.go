@@ -284,6 +284,82 @@ var _ = Describe("quantity to qemu", func() {
 	})
 })
 
+var _ = Describe("Rebase", func() {
+	It("Should point the image to the local copy of its backing file", func() {
+		replaceExecFunction(mockExecFunction("", "", nil, "rebase", "-u", "-b", "/scratch/backing-1", "-F", "raw", "image"), func() {
+			Expect(NewQEMUOperations().Rebase("image", "/scratch/backing-1", "raw")).To(Succeed())
+		})
+	})
+
+	It("Should flatten the image without a backing file", func() {
+		replaceExecFunction(func(limits *system.ProcessLimitValues, f func(string), cmd string, args ...string) ([]byte, error) {
+			Expect(args).To(Equal([]string{"rebase", "-t", "none", "-f", "qcow2", "-b", "", "image"}))
+			return nil, nil
+		}, func() {
+			Expect(NewQEMUOperations().Rebase("image", "", "")).To(Succeed())
+		})
+	})
+
+	It("Should fail if qemu-img rebase fails", func() {
+		replaceExecFunction(mockExecFunction("", "exit 1", nil, "rebase"), func() {
+			err := NewQEMUOperations().Rebase("image", "", "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("could not rebase image image"))
+		})
+	})
+})
+
+var _ = Describe("Check", func() {
+	It("Should return the result of a clean image", func() {
+		replaceExecFunction(mockExecFunction(`{"check-errors": 0, "filename": "image", "format": "qcow2"}`, "", nil, "check", "--output=json", "image"), func() {
+			check, err := NewQEMUOperations().Check(&url.URL{Path: "image"}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*check).To(Equal(ImgCheck{}))
+		})
+	})
+
+	It("Should return the corruptions and leaks reported next to errors", func() {
+		output := "ERROR cluster 5 refcount=0 reference=1\nLeaked cluster 9 refcount=1 reference=0\n{\"check-errors\": 0, \"corruptions\": 1, \"leaks\": 2}\n"
+		replaceExecFunction(mockExecFunction(output, "exit status 2", nil, "check"), func() {
+			check, err := NewQEMUOperations().Check(&url.URL{Path: "image"}, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(check.Corruptions).To(Equal(int64(1)))
+			Expect(check.Leaks).To(Equal(int64(2)))
+		})
+	})
+
+	It("Should repair the leaks of a local image", func() {
+		replaceExecFunction(func(limits *system.ProcessLimitValues, f func(string), cmd string, args ...string) ([]byte, error) {
+			Expect(args).To(Equal([]string{"check", "--output=json", "-f", "qcow2", "-r", "leaks", "image"}))
+			return []byte(`{"check-errors": 0, "leaks-fixed": 2}`), nil
+		}, func() {
+			check, err := NewQEMUOperations().Check(&url.URL{Path: "image"}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(check.LeaksFixed).To(Equal(int64(2)))
+		})
+	})
+
+	It("Should only check an image read from a url", func() {
+		replaceExecFunction(func(limits *system.ProcessLimitValues, f func(string), cmd string, args ...string) ([]byte, error) {
+			Expect(args).ToNot(ContainElement("-r"))
+			Expect(args[len(args)-1]).To(ContainSubstring(`"file.url": "https://images.example.com/disk.qcow2"`))
+			return []byte(`{"check-errors": 0}`), nil
+		}, func() {
+			imageURL, _ := url.Parse("https://images.example.com/disk.qcow2")
+			_, err := NewQEMUOperations().Check(imageURL, true)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	It("Should fail if qemu-img check fails without a result", func() {
+		replaceExecFunction(mockExecFunction("qemu-img: Could not open 'image'", "exit status 1", nil, "check"), func() {
+			_, err := NewQEMUOperations().Check(&url.URL{Path: "image"}, false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Error checking image image"))
+		})
+	})
+})
+
 var _ = Describe("Create blank image", func() {
 	It("Should complete successfully if qemu-img resize succeeds", func() {
 		quantity, err := resource.ParseQuantity("10Gi")
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -32,21 +33,60 @@ import (
 const dataTmpDir string = "/data_tmp"
 const whFilePrefix string = ".wh."
 
+// ociTitleAnnotation is the annotation holding the file name of an artifact blob
+const ociTitleAnnotation = "org.opencontainers.image.title"
+
+// artifactDiskFile is the name of an artifact blob without title
+const artifactDiskFile = "disk.img"
+
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
 // SkopeoOperations defines the interface for executing skopeo subprocesses
 type SkopeoOperations interface {
-	CopyImage(string, string, string, string, string, bool) error
+	CopyImage(string, string, string, string, string, string, string, bool) error
+	PushImage(string, string, string, string, string, bool) error
+	InspectRawManifest(string, string, string, string, string, bool) ([]byte, error)
 }
 
 type skopeoOperations struct{}
 
 type manifest struct {
 	SchemaVersion int     `json:"schemaVersion"`
+	Config        layer   `json:"config"`   // schemaVersion v2
 	Layers        []layer `json:"layers"`   // schemaVersion v2
 	FsLayers      []layer `json:"fsLayers"` // schemaVersion v1
 }
 type layer struct {
-	Digest  string `json:"digest"`  // schemaVersion v2
-	BlobSum string `json:"blobSum"` // schemaVersion v1
+	MediaType   string            `json:"mediaType"`   // schemaVersion v2
+	Digest      string            `json:"digest"`      // schemaVersion v2
+	Annotations map[string]string `json:"annotations"` // schemaVersion v2
+	BlobSum     string            `json:"blobSum"`     // schemaVersion v1
+}
+
+// manifestList is a docker manifest list or an OCI image index
+type manifestList struct {
+	MediaType string             `json:"mediaType"`
+	Manifests []manifestListItem `json:"manifests"`
+}
+type manifestListItem struct {
+	Digest   string   `json:"digest"`
+	Platform platform `json:"platform"`
+}
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
 }
 
 var (
@@ -60,12 +100,19 @@ func NewSkopeoOperations() SkopeoOperations {
 	return &skopeoOperations{}
 }
 
-func (o *skopeoOperations) CopyImage(url, dest, accessKey, secKey, certDir string, insecureRegistry bool) error {
+func (o *skopeoOperations) CopyImage(url, dest, accessKey, secKey, authFile, certDir, arch string, insecureRegistry bool) error {
 	var err error
-	args := []string{"copy", url, dest}
+	var args []string
+	if arch != "" {
+		// Selects the image to copy when the source is a manifest list
+		args = append(args, "--override-arch="+arch)
+	}
+	args = append(args, "copy", url, dest)
 	if accessKey != "" && secKey != "" {
 		creds := "--src-creds=" + accessKey + ":" + secKey
 		args = append(args, creds)
+	} else if authFile != "" {
+		args = append(args, "--authfile="+authFile)
 	}
 	if certDir != "" {
 		klog.Infof("Using user specified TLS certs at %s", certDir)
@@ -81,24 +128,136 @@ func (o *skopeoOperations) CopyImage(url, dest, accessKey, secKey, certDir strin
 	return nil
 }
 
+func (o *skopeoOperations) PushImage(src, url, accessKey, secKey, certDir string, insecureRegistry bool) error {
+	var err error
+	args := []string{"copy", src, url}
+	if accessKey != "" && secKey != "" {
+		creds := "--dest-creds=" + accessKey + ":" + secKey
+		args = append(args, creds)
+	}
+	if certDir != "" {
+		klog.Infof("Using user specified TLS certs at %s", certDir)
+		args = append(args, "--dest-cert-dir="+certDir)
+	} else if insecureRegistry {
+		klog.Infof("Disabling TLS verification for URL %s", url)
+		args = append(args, "--dest-tls-verify=false")
+	}
+	_, err = skopeoExecFunction(nil, nil, "skopeo", args...)
+	if err != nil {
+		return errors.Wrap(err, "could not push image")
+	}
+	return nil
+}
+
+func (o *skopeoOperations) InspectRawManifest(url, accessKey, secKey, authFile, certDir string, insecureRegistry bool) ([]byte, error) {
+	args := []string{"inspect", "--raw"}
+	if accessKey != "" && secKey != "" {
+		args = append(args, "--creds="+accessKey+":"+secKey)
+	} else if authFile != "" {
+		args = append(args, "--authfile="+authFile)
+	}
+	if certDir != "" {
+		args = append(args, "--cert-dir="+certDir)
+	} else if insecureRegistry {
+		args = append(args, "--tls-verify=false")
+	}
+	args = append(args, url)
+	output, err := skopeoExecFunction(nil, nil, "skopeo", args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not inspect image")
+	}
+	return output, nil
+}
+
+// ArtifactSelector selects the blob containing the disk image when the registry image is an OCI artifact
+type ArtifactSelector struct {
+	// MediaType selects the blob with this media type
+	MediaType string
+	// Annotation selects the blob with this annotation, in the form key=value
+	Annotation string
+}
+
+// IsEmpty returns true if the selector does not select any blob
+func (s ArtifactSelector) IsEmpty() bool {
+	return s.MediaType == "" && s.Annotation == ""
+}
+
+func (s ArtifactSelector) matches(l layer) bool {
+	if s.MediaType != "" && s.MediaType != l.MediaType {
+		return false
+	}
+	if s.Annotation != "" {
+		kv := strings.SplitN(s.Annotation, "=", 2)
+		value, ok := l.Annotations[kv[0]]
+		if !ok || (len(kv) == 2 && value != kv[1]) {
+			return false
+		}
+	}
+	return true
+}
+
 // CopyRegistryImage download image from registry with skopeo
 // url: source registry url.
 // dest: the scratch space destination.
 // accessKey: accessKey for the registry described in url.
 // secKey: secretKey for the registry described in url.
+// authFile: registry auth file used when no accessKey and secKey are given.
 // certDir: directory public CA keys are stored for registry identity verification
+// arch: architecture to select if url is a manifest list, defaults to the architecture of the importer.
+// diskPath: glob matching the disk image in the image layers, replaces the containerDisk layout when set.
+// artifact: selects the disk image blob if url is an OCI artifact.
 // insecureRegistry: boolean if true will allow insecure registries.
-func CopyRegistryImage(url, dest, destFile, accessKey, secKey, certDir string, insecureRegistry bool) error {
+func CopyRegistryImage(url, dest, destFile, accessKey, secKey, authFile, certDir, arch, diskPath string, artifact ArtifactSelector, insecureRegistry bool) error {
+	return copyImage(url, dest, destFile, accessKey, secKey, authFile, certDir, arch, diskPath, artifact, insecureRegistry, nil)
+}
+
+// CopyArchiveImage extracts the disk image from a docker-archive or oci-archive tarball in scratch space, like
+// CopyRegistryImage does for an image in a registry. The tarball is removed as soon as skopeo copied the image out of
+// it, so the tarball, the copied image and the extracted disk image never all take up scratch space at once.
+func CopyArchiveImage(archiveFile, dest, destFile, arch, diskPath string, artifact ArtifactSelector) error {
+	defer os.Remove(archiveFile)
+	ref, err := ArchiveTransport(archiveFile)
+	if err != nil {
+		return err
+	}
+	return copyImage(ref, dest, destFile, "", "", "", "", arch, diskPath, artifact, false, func() {
+		if err := os.Remove(archiveFile); err != nil {
+			klog.Warningf("Unable to remove image archive %s: %v", archiveFile, err)
+		}
+	})
+}
+
+// copyImage copies the image at url to scratch space with skopeo, calls copied if it is set, and then extracts
+// the disk image from the copy.
+func copyImage(url, dest, destFile, accessKey, secKey, authFile, certDir, arch, diskPath string, artifact ArtifactSelector, insecureRegistry bool, copied func()) error {
 	skopeoDest := "dir:" + filepath.Join(dest, dataTmpDir)
 
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	rawManifest, err := SkopeoInterface.InspectRawManifest(url, accessKey, secKey, authFile, certDir, insecureRegistry)
+	if err != nil {
+		return errors.Wrap(err, "Failed to inspect registry image")
+	}
+	if err = checkManifestListPlatform(rawManifest, arch); err != nil {
+		return err
+	}
+
 	// Copy to scratch space
-	err := SkopeoInterface.CopyImage(url, skopeoDest, accessKey, secKey, certDir, insecureRegistry)
+	err = SkopeoInterface.CopyImage(url, skopeoDest, accessKey, secKey, authFile, certDir, arch, insecureRegistry)
 	if err != nil {
 		os.RemoveAll(filepath.Join(dest, dataTmpDir))
 		return errors.Wrap(err, "Failed to download from registry")
 	}
+	if copied != nil {
+		copied()
+	}
 	// Extract image layers to target space.
-	err = extractImageLayers(dest, destFile)
+	if diskPath != "" && artifact.IsEmpty() {
+		err = extractImageFiles(dest, diskPath, destFile)
+	} else {
+		err = extractImageLayers(dest, artifact, destFile)
+	}
 	if err != nil {
 		return errors.Wrap(err, "Failed to extract image layers")
 	}
@@ -108,6 +267,9 @@ func CopyRegistryImage(url, dest, destFile, accessKey, secKey, certDir string, i
 		if _, err = os.Stat(filepath.Join(dest, destFile)); err != nil {
 			klog.Errorf("Failed to find VM disk image file in the container image")
 			err = errors.New("Failed to find VM disk image file in the container image")
+			if manifest, merr := getImageManifest(filepath.Join(dest, dataTmpDir)); merr == nil {
+				err = describeImageLayout(filepath.Join(dest, dataTmpDir), manifest, "the image does not follow the containerDisk layout, there is no disk image in /disk")
+			}
 		}
 	}
 	// Clean scratch space
@@ -116,7 +278,49 @@ func CopyRegistryImage(url, dest, destFile, accessKey, secKey, certDir string, i
 	return err
 }
 
-var extractImageLayers = func(dest string, arg ...string) error {
+// PushRegistryImage wraps a disk image into a containerDisk and pushes it to a registry with skopeo
+// src: the disk image to export.
+// scratch: the scratch space used to build the container image.
+// url: destination registry url.
+// accessKey: accessKey for the registry described in url.
+// secKey: secretKey for the registry described in url.
+// certDir: directory public CA keys are stored for registry identity verification
+// insecureRegistry: boolean if true will allow insecure registries.
+func PushRegistryImage(src, scratch, url, accessKey, secKey, certDir string, insecureRegistry bool) error {
+	layoutDir := filepath.Join(scratch, dataTmpDir)
+	defer os.RemoveAll(layoutDir)
+
+	if err := BuildContainerDiskLayout(src, layoutDir); err != nil {
+		return errors.Wrap(err, "Failed to build containerDisk image")
+	}
+	if err := SkopeoInterface.PushImage("oci:"+layoutDir, url, accessKey, secKey, certDir, insecureRegistry); err != nil {
+		return errors.Wrap(err, "Failed to push to registry")
+	}
+	return nil
+}
+
+// checkManifestListPlatform verifies that a manifest list contains an image for the requested
+// architecture, single image manifests are accepted as is.
+func checkManifestListPlatform(rawManifest []byte, arch string) error {
+	var list manifestList
+	if err := json.Unmarshal(rawManifest, &list); err != nil {
+		return errors.Wrap(err, "could not parse image manifest")
+	}
+	if list.MediaType != mediaTypeDockerManifestList && list.MediaType != mediaTypeOCIIndex && len(list.Manifests) == 0 {
+		return nil
+	}
+	var available []string
+	for _, m := range list.Manifests {
+		if m.Platform.Architecture == arch && (m.Platform.OS == "" || m.Platform.OS == "linux") {
+			klog.V(1).Infof("Selected %s image %s from manifest list", m.Platform, m.Digest)
+			return nil
+		}
+		available = append(available, m.Platform.String())
+	}
+	return errors.Errorf("no image for architecture %s in manifest list, available platforms: %s", arch, strings.Join(available, ", "))
+}
+
+var extractImageLayers = func(dest string, artifact ArtifactSelector, arg ...string) error {
 	klog.V(1).Infof("extracting image layers to %q\n", dest)
 	// Parse manifest file
 	manifest, err := getImageManifest(dest + dataTmpDir)
@@ -124,6 +328,14 @@ var extractImageLayers = func(dest string, arg ...string) error {
 		return err
 	}
 
+	if !artifact.IsEmpty() || isArtifact(manifest) {
+		destDir := dest
+		if len(arg) > 0 {
+			destDir = filepath.Join(dest, arg[0])
+		}
+		return extractArtifact(manifest, filepath.Join(dest, dataTmpDir), destDir, artifact)
+	}
+
 	// Extract layers
 	var layers []layer
 	if manifest.SchemaVersion == 1 {
@@ -156,6 +368,68 @@ var extractImageLayers = func(dest string, arg ...string) error {
 	return err
 }
 
+// isImageLayer returns true if mediaType is the media type of a container image layer
+func isImageLayer(mediaType string) bool {
+	switch mediaType {
+	case "", // schemaVersion v1
+		MediaTypeOCILayer,
+		"application/vnd.oci.image.layer.v1.tar+gzip",
+		"application/vnd.oci.image.layer.v1.tar+zstd",
+		"application/vnd.oci.image.layer.nondistributable.v1.tar",
+		"application/vnd.oci.image.layer.nondistributable.v1.tar+gzip",
+		"application/vnd.docker.image.rootfs.diff.tar",
+		"application/vnd.docker.image.rootfs.diff.tar.gzip",
+		"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip":
+		return true
+	}
+	return false
+}
+
+// isArtifact returns true if the manifest describes an OCI artifact rather than a container image
+func isArtifact(manifest *manifest) bool {
+	for _, l := range manifest.Layers {
+		if !isImageLayer(l.MediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractArtifact copies the blob selected by artifact from the OCI artifact in srcDir into destDir. Without a
+// selector the artifact must contain a single blob that is not a container image layer.
+func extractArtifact(manifest *manifest, srcDir, destDir string, artifact ArtifactSelector) error {
+	var candidates []layer
+	var available []string
+	for _, l := range manifest.Layers {
+		available = append(available, l.MediaType)
+		if artifact.IsEmpty() {
+			if !isImageLayer(l.MediaType) {
+				candidates = append(candidates, l)
+			}
+		} else if artifact.matches(l) {
+			candidates = append(candidates, l)
+		}
+	}
+	if len(candidates) != 1 {
+		return errors.Errorf("found %d matching blobs in artifact, expected 1, available media types: %s", len(candidates), strings.Join(available, ", "))
+	}
+
+	blob := candidates[0]
+	name := filepath.Base(blob.Annotations[ociTitleAnnotation])
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = artifactDiskFile
+	}
+	klog.V(1).Infof("Extracting artifact blob %s with media type %s as %s", blob.Digest, blob.MediaType, name)
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "could not create artifact directory")
+	}
+	src := filepath.Join(srcDir, strings.TrimPrefix(blob.Digest, "sha256:"))
+	if err := os.Rename(src, filepath.Join(destDir, name)); err != nil {
+		return errors.Wrap(err, "could not extract artifact blob")
+	}
+	return nil
+}
+
 func getImageManifest(dest string) (*manifest, error) {
 	// Open Manifest.json
 	manifestFile, err := ioutil.ReadFile(dest + "/manifest.json")
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/pkg/errors"
 
@@ -27,11 +28,24 @@ import (
 	"github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	"kubevirt.io/containerized-data-importer/pkg/system"
 	"kubevirt.io/containerized-data-importer/pkg/util"
 )
 
 const testImagesDir = "../../tests/images"
 
+const singleManifest = `{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.v2+json", "layers": []}`
+
+const manifestListJSON = `{
+	"schemaVersion": 2,
+	"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+	"manifests": [
+		{"digest": "sha256:1111", "platform": {"architecture": "amd64", "os": "linux"}},
+		{"digest": "sha256:2222", "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}},
+		{"digest": "sha256:3333", "platform": {"architecture": "ppc64le", "os": "linux"}}
+	]
+}`
+
 var _ = Describe("Registry Importer", func() {
 	source := "docker://docker.io/fedora"
 	dest := "/data"
@@ -51,14 +65,74 @@ var _ = Describe("Registry Importer", func() {
 			}
 		})
 	},
-		table.Entry("copy success", mockExecFunction("", "", nil), "", func() error { return CopyRegistryImage(source, dest, "", "", "", "", false) }),
-		table.Entry("copy success with certs", mockExecFunction("", "", nil, "--src-cert-dir=/foo/bar"), "", func() error { return CopyRegistryImage(source, dest, "", "", "", "/foo/bar", false) }),
-		table.Entry("copy success insecure", mockExecFunction("", "", nil, "--src-tls-verify=false"), "", func() error { return CopyRegistryImage(source, dest, "", "", "", "", true) }),
-		table.Entry("copy failure", mockExecFunction("", "Failed to find VM disk image file in the container image", nil), "Failed to find VM disk image file in the container image", func() error { return CopyRegistryImage(source, dest, "", "", "", "", false) }),
+		table.Entry("copy success", mockSkopeoExecFunction(singleManifest, mockExecFunction("", "", nil)), "", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "", "", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("copy success with certs", mockSkopeoExecFunction(singleManifest, mockExecFunction("", "", nil, "--src-cert-dir=/foo/bar")), "", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "/foo/bar", "", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("copy success insecure", mockSkopeoExecFunction(singleManifest, mockExecFunction("", "", nil, "--src-tls-verify=false")), "", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "", "", "", ArtifactSelector{}, true)
+		}),
+		table.Entry("copy failure", mockSkopeoExecFunction(singleManifest, mockExecFunction("", "Failed to find VM disk image file in the container image", nil)), "Failed to find VM disk image file in the container image", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "", "", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("copy success from manifest list", mockSkopeoExecFunction(manifestListJSON, mockExecFunction("", "", nil, "--override-arch=arm64")), "", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "", "arm64", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("copy success from manifest list with default architecture", mockSkopeoExecFunction(manifestListJSON, mockExecFunction("", "", nil, "--override-arch="+runtime.GOARCH)), "", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "", "", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("copy failure on missing platform", mockSkopeoExecFunction(manifestListJSON, nil), "no image for architecture s390x in manifest list, available platforms: linux/amd64, linux/arm64/v8, linux/ppc64le", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "", "", "s390x", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("copy success with auth file", mockSkopeoExecFunction(singleManifest, mockExecFunction("", "", nil, "--authfile=/auth.json")), "", func() error {
+			return CopyRegistryImage(source, dest, "", "", "", "/auth.json", "", "", "", ArtifactSelector{}, false)
+		}),
+		table.Entry("inspect failure", mockExecFunction("", "unauthorized", nil, "inspect", "--raw", "--creds=user:pass", source), "unauthorized", func() error {
+			return CopyRegistryImage(source, dest, "", "user", "pass", "", "", "", "", ArtifactSelector{}, false)
+		}),
 	)
 
 })
 
+var _ = Describe("Registry Exporter", func() {
+	dest := "docker://registry.example.com/vmdisk:latest"
+	var tmpDir, diskImage string
+	var err error
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "registry-export-test")
+		Expect(err).NotTo(HaveOccurred())
+		diskImage = filepath.Join(tmpDir, "disk.img")
+		Expect(ioutil.WriteFile(diskImage, []byte("disk"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	table.DescribeTable("with export destination should", func(execfunc execFunctionType, errString string, accessKey, secKey, certDir string, insecure bool) {
+		replaceSkopeoFunctions(execfunc, func() {
+			err := PushRegistryImage(diskImage, tmpDir, dest, accessKey, secKey, certDir, insecure)
+			if errString == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+				Expect(errors.Cause(err).Error()).To(Equal(errString))
+			}
+			_, err = os.Stat(filepath.Join(tmpDir, dataTmpDir))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	},
+		table.Entry("push success", mockExecFunction("", "", nil, dest), "", "", "", "", false),
+		table.Entry("push success with creds", mockExecFunction("", "", nil, "--dest-creds=user:pass"), "", "user", "pass", "", false),
+		table.Entry("push success with certs", mockExecFunction("", "", nil, "--dest-cert-dir=/foo/bar"), "", "", "", "/foo/bar", false),
+		table.Entry("push success insecure", mockExecFunction("", "", nil, "--dest-tls-verify=false"), "", "", "", "", true),
+		table.Entry("push failure", mockExecFunction("", "unauthorized", nil), "unauthorized", "", "", "", false),
+	)
+})
+
 var _ = Describe("Extract image layers", func() {
 	var destTmpDir, dataTmpPath string
 	var err error
@@ -78,16 +152,62 @@ var _ = Describe("Extract image layers", func() {
 	It("Should not fail on, no layers v2 manifest", func() {
 		err = util.CopyFile(filepath.Join(testImagesDir, "valid_manifest/manifest.json"), filepath.Join(dataTmpPath, "manifest.json"))
 		Expect(err).NotTo(HaveOccurred())
-		err := extractImageLayers(destTmpDir)
+		err := extractImageLayers(destTmpDir, ArtifactSelector{})
 		Expect(err).NotTo(HaveOccurred())
 	})
 
 	It("Should not fail on, layered image", func() {
 		err = util.UnArchiveLocalTar(filepath.Join(testImagesDir, "docker-image.tar"), destTmpDir)
 		Expect(err).NotTo(HaveOccurred())
-		err := extractImageLayers(filepath.Join(destTmpDir, "data"))
+		err := extractImageLayers(filepath.Join(destTmpDir, "data"), ArtifactSelector{})
 		Expect(err).NotTo(HaveOccurred())
 	})
+
+	writeArtifact := func(layers string) {
+		manifest := `{"schemaVersion": 2, "config": {"mediaType": "application/vnd.oras.config.v1+json", "digest": "sha256:cccc"}, "layers": [` + layers + `]}`
+		Expect(ioutil.WriteFile(filepath.Join(dataTmpPath, "manifest.json"), []byte(manifest), 0644)).To(Succeed())
+		for _, blob := range []string{"aaaa", "bbbb"} {
+			Expect(ioutil.WriteFile(filepath.Join(dataTmpPath, blob), []byte(blob), 0644)).To(Succeed())
+		}
+	}
+
+	readDisk := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(destTmpDir, "disk", name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("Should extract the only blob of an artifact", func() {
+		writeArtifact(`{"mediaType": "application/x-qemu-disk", "digest": "sha256:aaaa", "annotations": {"org.opencontainers.image.title": "fedora.qcow2"}}`)
+		Expect(extractImageLayers(destTmpDir, ArtifactSelector{}, "disk")).To(Succeed())
+		Expect(readDisk("fedora.qcow2")).To(Equal("aaaa"))
+	})
+
+	It("Should extract the blob of an artifact matching the media type", func() {
+		writeArtifact(`{"mediaType": "text/plain", "digest": "sha256:aaaa"}, {"mediaType": "application/x-qemu-disk", "digest": "sha256:bbbb"}`)
+		Expect(extractImageLayers(destTmpDir, ArtifactSelector{MediaType: "application/x-qemu-disk"}, "disk")).To(Succeed())
+		Expect(readDisk(artifactDiskFile)).To(Equal("bbbb"))
+	})
+
+	It("Should extract the blob of an artifact matching the annotation", func() {
+		writeArtifact(`{"mediaType": "application/x-qemu-disk", "digest": "sha256:aaaa", "annotations": {"kind": "data"}}, {"mediaType": "application/x-qemu-disk", "digest": "sha256:bbbb", "annotations": {"kind": "root", "org.opencontainers.image.title": "../root.img"}}`)
+		Expect(extractImageLayers(destTmpDir, ArtifactSelector{Annotation: "kind=root"}, "disk")).To(Succeed())
+		Expect(readDisk("root.img")).To(Equal("bbbb"))
+	})
+
+	It("Should fail if several blobs of an artifact match", func() {
+		writeArtifact(`{"mediaType": "application/x-qemu-disk", "digest": "sha256:aaaa"}, {"mediaType": "application/x-qemu-disk", "digest": "sha256:bbbb"}`)
+		err := extractImageLayers(destTmpDir, ArtifactSelector{}, "disk")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("found 2 matching blobs"))
+	})
+
+	It("Should fail if no blob of an artifact matches", func() {
+		writeArtifact(`{"mediaType": "application/x-qemu-disk", "digest": "sha256:aaaa"}`)
+		err := extractImageLayers(destTmpDir, ArtifactSelector{Annotation: "kind"}, "disk")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("available media types: application/x-qemu-disk"))
+	})
 })
 
 var _ = Describe("Image manifest", func() {
@@ -108,6 +228,28 @@ var _ = Describe("Image manifest", func() {
 	})
 })
 
+var _ = Describe("Manifest list platform", func() {
+	It("Should accept a single image manifest", func() {
+		Expect(checkManifestListPlatform([]byte(singleManifest), "s390x")).To(Succeed())
+	})
+
+	It("Should accept an OCI index with a matching platform", func() {
+		index := `{"schemaVersion": 2, "manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:1111", "platform": {"architecture": "arm64", "os": "linux"}}]}`
+		Expect(checkManifestListPlatform([]byte(index), "arm64")).To(Succeed())
+	})
+
+	It("Should ignore images for other operating systems", func() {
+		list := `{"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json", "manifests": [{"digest": "sha256:1111", "platform": {"architecture": "amd64", "os": "windows"}}]}`
+		err := checkManifestListPlatform([]byte(list), "amd64")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("windows/amd64"))
+	})
+
+	It("Should fail on an invalid manifest", func() {
+		Expect(checkManifestListPlatform([]byte("invalid"), "amd64")).ToNot(Succeed())
+	})
+})
+
 var _ = Describe("Clean whiteout files", func() {
 	var tmpDir string
 	var err error
@@ -168,6 +310,19 @@ func replaceSkopeoFunctions(mockSkopeoExecFunction execFunctionType, f func()) {
 	f()
 }
 
-func mockExtractImageLayers(dest string, arg ...string) error {
+// mockSkopeoExecFunction returns rawManifest for skopeo inspect, and calls copyExec for any other command
+func mockSkopeoExecFunction(rawManifest string, copyExec execFunctionType) execFunctionType {
+	return func(limits *system.ProcessLimitValues, f func(string), cmd string, args ...string) ([]byte, error) {
+		if len(args) > 0 && args[0] == "inspect" {
+			return []byte(rawManifest), nil
+		}
+		if copyExec == nil {
+			Fail("skopeo copy should not be called")
+		}
+		return copyExec(limits, f, cmd, args...)
+	}
+}
+
+func mockExtractImageLayers(dest string, artifact ArtifactSelector, arg ...string) error {
 	return nil
 }
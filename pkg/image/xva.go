@@ -0,0 +1,320 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// xvaMetadata is the first file of an XVA export, it describes the VM and its disks
+	xvaMetadata = "ova.xml"
+	// xvaChunkSize is the size of the chunks the disks of an XVA export are split into
+	xvaChunkSize = 1 << 20
+	// xvaMaxMetadataSize limits the size of ova.xml read into memory
+	xvaMaxMetadataSize = 64 << 20
+)
+
+// IsXVA returns true if hdr, the first tar header of a stream, is the header of an XVA export
+func IsXVA(hdr []byte) bool {
+	if len(hdr) < 100 {
+		return false
+	}
+	return string(bytes.TrimRight(hdr[:100], "\x00")) == xvaMetadata
+}
+
+// xvaValue is an XML-RPC value, as used by ova.xml
+type xvaValue struct {
+	Text   string      `xml:",chardata"`
+	String *string     `xml:"string"`
+	Struct []xvaMember `xml:"struct>member"`
+	Array  []xvaValue  `xml:"array>data>value"`
+}
+
+type xvaMember struct {
+	Name  string   `xml:"name"`
+	Value xvaValue `xml:"value"`
+}
+
+func (v *xvaValue) str() string {
+	if v.String != nil {
+		return *v.String
+	}
+	return strings.TrimSpace(v.Text)
+}
+
+func (v *xvaValue) member(name string) *xvaValue {
+	for i := range v.Struct {
+		if v.Struct[i].Name == name {
+			return &v.Struct[i].Value
+		}
+	}
+	return &xvaValue{}
+}
+
+// xvaDisk is a disk of the VM in an XVA export
+type xvaDisk struct {
+	ref        string
+	userdevice int
+	size       int64
+}
+
+// xvaDisks returns the disks of the exported VM, CD-ROM drives excluded, from the content of ova.xml
+func xvaDisks(metadata []byte) ([]xvaDisk, error) {
+	var root xvaValue
+	if err := xml.Unmarshal(metadata, &root); err != nil {
+		return nil, errors.Wrap(err, "could not parse "+xvaMetadata)
+	}
+	objects := root.member("objects").Array
+	snapshots := make(map[string]*xvaValue)
+	for i := range objects {
+		snapshots[objects[i].member("id").str()] = objects[i].member("snapshot")
+	}
+
+	var disks []xvaDisk
+	for i := range objects {
+		if objects[i].member("class").str() != "VBD" {
+			continue
+		}
+		vbd := objects[i].member("snapshot")
+		if vbd.member("type").str() != "Disk" {
+			continue
+		}
+		ref := vbd.member("VDI").str()
+		vdi, ok := snapshots[ref]
+		if !ok {
+			continue
+		}
+		size, err := strconv.ParseInt(vdi.member("virtual_size").str(), 10, 64)
+		if err != nil || size <= 0 {
+			return nil, errors.Errorf("invalid virtual_size of disk %s in %s", ref, xvaMetadata)
+		}
+		userdevice, err := strconv.Atoi(vbd.member("userdevice").str())
+		if err != nil {
+			userdevice = len(disks)
+		}
+		disks = append(disks, xvaDisk{ref: ref, userdevice: userdevice, size: size})
+	}
+	return disks, nil
+}
+
+// XVAReader reads the boot disk of a XenServer/XCP-ng XVA export as a raw disk image. An XVA export
+// is a tar archive of ova.xml followed, for every disk, by the numbered 1MiB chunks of the disk in a
+// directory named after the disk. Chunks that only contain zeros are left out. Every chunk may be
+// followed by its sha1 in a .checksum file, which is verified.
+type XVAReader struct {
+	tr   *tar.Reader
+	disk xvaDisk
+	// pos is the offset in the disk of the next byte returned
+	pos int64
+	// next is the offset of the next chunk, pos up to next is zeros
+	next int64
+	// chunk is the chunk being read, if pos equals next
+	chunk io.Reader
+	// hash is the hash of the last chunk read, with its index
+	hash      hash.Hash
+	hashIndex string
+	eof       bool
+}
+
+// NewXVAReader reads ova.xml from the XVA export in r, and returns the reader of its boot disk
+func NewXVAReader(r io.Reader) (*XVAReader, error) {
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read XVA export")
+	}
+	if hdr.Name != xvaMetadata {
+		return nil, errors.Errorf("XVA export starts with %s instead of %s", hdr.Name, xvaMetadata)
+	}
+	metadata, err := ioutil.ReadAll(io.LimitReader(tr, xvaMaxMetadataSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read "+xvaMetadata)
+	}
+	disks, err := xvaDisks(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(disks) == 0 {
+		return nil, errors.New("the XVA export has no disk")
+	}
+	boot := disks[0]
+	for _, disk := range disks[1:] {
+		if disk.userdevice < boot.userdevice {
+			boot = disk
+		}
+	}
+	if len(disks) > 1 {
+		klog.Warningf("The XVA export has %d disks, only importing disk %s at position %d", len(disks), boot.ref, boot.userdevice)
+	}
+	klog.V(1).Infof("Reading disk %s of %d bytes from the XVA export", boot.ref, boot.size)
+	return &XVAReader{tr: tr, disk: boot, next: -1}, nil
+}
+
+// Size returns the size of the disk
+func (x *XVAReader) Size() int64 {
+	return x.disk.size
+}
+
+// Read reads the disk, filling the chunks left out with zeros
+func (x *XVAReader) Read(p []byte) (int, error) {
+	for {
+		if x.pos == x.disk.size {
+			// Verify the checksum of the last chunk
+			if err := x.drain(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		if x.next < x.pos {
+			if err := x.nextChunk(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if x.next > x.pos {
+			n := len(p)
+			if int64(n) > x.next-x.pos {
+				n = int(x.next - x.pos)
+			}
+			for i := range p[:n] {
+				p[i] = 0
+			}
+			x.pos += int64(n)
+			return n, nil
+		}
+		n, err := x.chunk.Read(p)
+		x.pos += int64(n)
+		x.next = x.pos
+		if err == io.EOF {
+			// Look for the next chunk once the data read is returned
+			x.next = -1
+			err = nil
+		}
+		if err != nil {
+			return n, errors.Wrap(err, "could not read XVA export")
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// nextChunk moves to the next chunk of the disk, verifying checksums on the way. x.next is set to the
+// size of the disk once there is none left.
+func (x *XVAReader) nextChunk() error {
+	for !x.eof {
+		hdr, err := x.nextEntry()
+		if err != nil {
+			return err
+		}
+		if hdr == nil {
+			continue
+		}
+		name := path.Base(hdr.Name)
+		if strings.HasSuffix(name, ".checksum") {
+			if err := x.verify(strings.TrimSuffix(name, ".checksum")); err != nil {
+				return err
+			}
+			continue
+		}
+		index, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			// .xxhash checksums of newer exports are not verified
+			continue
+		}
+		offset := index * xvaChunkSize
+		if offset < x.pos || offset+hdr.Size > x.disk.size {
+			return errors.Errorf("chunk %s of disk %s is out of order or beyond the end of the disk", name, x.disk.ref)
+		}
+		x.hash = sha1.New()
+		x.hashIndex = name
+		x.chunk = io.TeeReader(x.tr, x.hash)
+		x.next = offset
+		return nil
+	}
+	x.next = x.disk.size
+	return nil
+}
+
+// nextEntry finishes hashing the current chunk and moves to the next file of the export. It returns nil
+// for the files of other disks.
+func (x *XVAReader) nextEntry() (*tar.Header, error) {
+	if x.chunk != nil {
+		if _, err := io.Copy(ioutil.Discard, x.chunk); err != nil {
+			return nil, errors.Wrap(err, "could not read XVA export")
+		}
+		x.chunk = nil
+	}
+	hdr, err := x.tr.Next()
+	if err == io.EOF {
+		x.eof = true
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read XVA export")
+	}
+	if path.Dir(path.Clean(hdr.Name)) != x.disk.ref {
+		return nil, nil
+	}
+	return hdr, nil
+}
+
+// verify compares the sha1 of the chunk read last with the checksum of chunk index, the current file of the export
+func (x *XVAReader) verify(index string) error {
+	if x.hash == nil || index != x.hashIndex {
+		return nil
+	}
+	checksum, err := ioutil.ReadAll(io.LimitReader(x.tr, 1024))
+	if err != nil {
+		return errors.Wrap(err, "could not read XVA export")
+	}
+	if sum := hex.EncodeToString(x.hash.Sum(nil)); sum != strings.TrimSpace(string(checksum)) {
+		return errors.Errorf("checksum of chunk %s of disk %s does not match, got %s, expected %s", index, x.disk.ref, sum, strings.TrimSpace(string(checksum)))
+	}
+	x.hash = nil
+	return nil
+}
+
+// drain verifies the checksum of the last chunk of the disk, which directly follows the chunk
+func (x *XVAReader) drain() error {
+	if x.hash == nil || x.eof {
+		return nil
+	}
+	hdr, err := x.nextEntry()
+	if err != nil || hdr == nil {
+		return err
+	}
+	if path.Base(hdr.Name) == x.hashIndex+".checksum" {
+		return x.verify(x.hashIndex)
+	}
+	x.hash = nil
+	return nil
+}
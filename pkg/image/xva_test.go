@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// xvaObject returns the ova.xml object of class with id and the snapshot members
+func xvaObject(class, id string, snapshot map[string]string) string {
+	members := ""
+	for name, value := range snapshot {
+		members += fmt.Sprintf("<member><name>%s</name><value>%s</value></member>", name, value)
+	}
+	return fmt.Sprintf(`<value><struct><member><name>class</name><value>%s</value></member>
+<member><name>id</name><value>%s</value></member>
+<member><name>snapshot</name><value><struct>%s</struct></value></member></struct></value>`, class, id, members)
+}
+
+func xvaMetadataXML(objects ...string) []byte {
+	xml := `<value><struct><member><name>version</name><value><struct></struct></value></member>
+<member><name>objects</name><value><array><data>`
+	for _, object := range objects {
+		xml += object
+	}
+	return []byte(xml + `</data></array></value></member></struct></value>`)
+}
+
+type xvaFile struct {
+	name    string
+	content []byte
+}
+
+func xvaChunk(dir string, index int, content []byte) []xvaFile {
+	sum := sha1.Sum(content)
+	name := fmt.Sprintf("%s/%08d", dir, index)
+	return []xvaFile{{name, content}, {name + ".checksum", []byte(hex.EncodeToString(sum[:]))}}
+}
+
+func xvaExport(metadata []byte, files ...xvaFile) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, file := range append([]xvaFile{{xvaMetadata, metadata}}, files...) {
+		Expect(tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))})).To(Succeed())
+		_, err := tw.Write(file.content)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("XVA reader", func() {
+	diskSize := 3*xvaChunkSize + 512
+	metadata := xvaMetadataXML(
+		xvaObject("VM", "Ref:1", map[string]string{"name_label": "vm"}),
+		xvaObject("VBD", "Ref:2", map[string]string{"type": "CD", "userdevice": "3", "VDI": "Ref:5"}),
+		xvaObject("VBD", "Ref:3", map[string]string{"type": "Disk", "userdevice": "1", "VDI": "Ref:6"}),
+		xvaObject("VBD", "Ref:4", map[string]string{"type": "Disk", "userdevice": "0", "VDI": "Ref:7"}),
+		xvaObject("VDI", "Ref:5", map[string]string{"virtual_size": "1024"}),
+		xvaObject("VDI", "Ref:6", map[string]string{"virtual_size": "<string>1048576</string>"}),
+		xvaObject("VDI", "Ref:7", map[string]string{"virtual_size": fmt.Sprintf("%d", diskSize)}),
+	)
+	first := bytes.Repeat([]byte{'a'}, xvaChunkSize)
+	last := bytes.Repeat([]byte{'b'}, 512)
+
+	It("Should detect an XVA export from its first tar header", func() {
+		Expect(IsXVA(xvaExport(metadata)[:MaxExpectedHdrSize])).To(BeTrue())
+		Expect(IsXVA(make([]byte, MaxExpectedHdrSize))).To(BeFalse())
+	})
+
+	It("Should read the boot disk and fill the chunks left out with zeros", func() {
+		var files []xvaFile
+		files = append(files, xvaChunk("Ref:6", 0, bytes.Repeat([]byte{'c'}, xvaChunkSize))...)
+		files = append(files, xvaChunk("Ref:7", 0, first)...)
+		files = append(files, xvaChunk("Ref:7", 3, last)...)
+		xva, err := NewXVAReader(bytes.NewReader(xvaExport(metadata, files...)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(xva.Size()).To(Equal(int64(diskSize)))
+		disk, err := ioutil.ReadAll(xva)
+		Expect(err).NotTo(HaveOccurred())
+		expected := append(append(first, make([]byte, 2*xvaChunkSize)...), last...)
+		Expect(disk).To(Equal(expected))
+	})
+
+	It("Should fill the end of the disk with zeros", func() {
+		xva, err := NewXVAReader(bytes.NewReader(xvaExport(metadata, xvaChunk("Ref:7", 0, first)...)))
+		Expect(err).NotTo(HaveOccurred())
+		disk, err := ioutil.ReadAll(xva)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disk).To(HaveLen(diskSize))
+		Expect(disk[xvaChunkSize:]).To(Equal(make([]byte, diskSize-xvaChunkSize)))
+	})
+
+	It("Should fail if a checksum does not match", func() {
+		files := xvaChunk("Ref:7", 0, first)
+		files[0].content = bytes.Repeat([]byte{'x'}, xvaChunkSize)
+		xva, err := NewXVAReader(bytes.NewReader(xvaExport(metadata, files...)))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ioutil.ReadAll(xva)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("checksum of chunk 00000000 of disk Ref:7 does not match"))
+	})
+
+	It("Should verify the checksum of the last chunk", func() {
+		files := xvaChunk("Ref:7", 3, last)
+		files[1].content = []byte("0000")
+		xva, err := NewXVAReader(bytes.NewReader(xvaExport(metadata, files...)))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ioutil.ReadAll(xva)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("checksum of chunk 00000003"))
+	})
+
+	It("Should fail if a chunk is beyond the end of the disk", func() {
+		xva, err := NewXVAReader(bytes.NewReader(xvaExport(metadata, xvaChunk("Ref:7", 4, last)...)))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ioutil.ReadAll(xva)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("beyond the end of the disk"))
+	})
+
+	It("Should fail if the export has no disk", func() {
+		_, err := NewXVAReader(bytes.NewReader(xvaExport(xvaMetadataXML(xvaObject("VM", "Ref:1", nil)))))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("the XVA export has no disk"))
+	})
+})
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+)
+
+// maxBackingChainLength is the maximum number of backing files fetched for an image, it protects against loops
+const maxBackingChainLength = 16
+
+// fetchBackingFunc downloads the backing file at the url to the dest file
+type fetchBackingFunc func(u *url.URL, dest string) error
+
+// resolveBackingURL returns the url of the backing file name recorded in the image downloaded from imageURL.
+// Absolute and relative file names are resolved against the url of the image, like qemu resolves them against
+// the path of the image.
+func resolveBackingURL(imageURL *url.URL, name string) (*url.URL, error) {
+	ref, err := url.Parse(name)
+	if err == nil && ref.Scheme != "" {
+		if ref.Scheme != imageURL.Scheme {
+			return nil, errors.Errorf("backing file %s must use the %s scheme, like the image referencing it", name, imageURL.Scheme)
+		}
+		return ref, nil
+	}
+	// A path, resolve it as such even if it contains ? or #
+	return imageURL.ResolveReference(&url.URL{Path: name}), nil
+}
+
+// isAllowedBackingURL returns true if u, without its user, equals one of the allowed urls, or is below one of them
+func isAllowedBackingURL(u *url.URL, allowed []string) bool {
+	withoutUser := *u
+	withoutUser.User = nil
+	s := withoutUser.String()
+	for _, prefix := range allowed {
+		if s == prefix || strings.HasPrefix(s, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchBackingChain downloads the backing chain of the qcow2 image downloaded from imageURL to the same directory,
+// and flattens it into the image. Every backing file must be allowed by allowedURLs, and the chain must be shorter
+// than maxBackingChainLength.
+func fetchBackingChain(image string, imageURL *url.URL, allowedURLs []string, fetch fetchBackingFunc) error {
+	current, currentURL := image, imageURL
+	var backingFiles []string
+	defer func() {
+		for _, file := range backingFiles {
+			os.Remove(file)
+		}
+	}()
+
+	for {
+		info, err := qemuOperations.Info(&url.URL{Path: current})
+		if err != nil {
+			return err
+		}
+		if info.BackingFile == "" {
+			break
+		}
+		if len(backingFiles) == maxBackingChainLength {
+			return errors.Errorf("the backing chain of %s is longer than %d images", imageURL, maxBackingChainLength)
+		}
+		backingURL, err := resolveBackingURL(currentURL, info.BackingFile)
+		if err != nil {
+			return err
+		}
+		if !isAllowedBackingURL(backingURL, allowedURLs) {
+			return errors.Errorf("backing file %s of %s is not in backingFileURLs", backingURL, currentURL)
+		}
+
+		backing := filepath.Join(filepath.Dir(image), fmt.Sprintf("backing-%d", len(backingFiles)+1))
+		backingFiles = append(backingFiles, backing)
+		klog.V(1).Infof("Fetching backing file %s of %s", backingURL, currentURL)
+		if err = fetch(backingURL, backing); err != nil {
+			return errors.Wrapf(err, "could not fetch backing file %s", backingURL)
+		}
+		backingInfo, err := qemuOperations.Info(&url.URL{Path: backing})
+		if err != nil {
+			return err
+		}
+		if backingInfo.Format != "raw" && backingInfo.Format != "qcow2" {
+			return errors.Errorf("backing file %s has unsupported format %s", backingURL, backingInfo.Format)
+		}
+		if info.BackingFormat != "" && info.BackingFormat != backingInfo.Format {
+			return errors.Errorf("backing file %s is %s, %s expects %s", backingURL, backingInfo.Format, currentURL, info.BackingFormat)
+		}
+		if err = qemuOperations.Rebase(current, backing, backingInfo.Format); err != nil {
+			return err
+		}
+		current, currentURL = backing, backingURL
+	}
+
+	if len(backingFiles) == 0 {
+		return nil
+	}
+	klog.V(1).Infof("Flattening the backing chain of %d files into %s", len(backingFiles), image)
+	return qemuOperations.Rebase(image, "", "")
+}
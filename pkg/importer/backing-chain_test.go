@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"kubevirt.io/containerized-data-importer/pkg/image"
+)
+
+// chainQEMUOperations fakes qemu-img for images whose info is known by file content
+type chainQEMUOperations struct {
+	// infos maps the content of a file to its info
+	infos   map[string]*image.ImgInfo
+	rebases []string
+}
+
+func (o *chainQEMUOperations) ConvertToRawStream(*url.URL, string) error {
+	return nil
+}
+
+func (o *chainQEMUOperations) Validate(*url.URL, int64) error {
+	return nil
+}
+
+func (o *chainQEMUOperations) Resize(string, resource.Quantity) error {
+	return nil
+}
+
+func (o *chainQEMUOperations) Info(u *url.URL) (*image.ImgInfo, error) {
+	content, err := ioutil.ReadFile(u.Path)
+	if err != nil {
+		return nil, err
+	}
+	return o.infos[string(content)], nil
+}
+
+func (o *chainQEMUOperations) CreateBlankImage(string, resource.Quantity) error {
+	return nil
+}
+
+func (o *chainQEMUOperations) Check(*url.URL, bool) (*image.ImgCheck, error) {
+	return &image.ImgCheck{}, nil
+}
+
+func (o *chainQEMUOperations) Rebase(image, backingFile, backingFormat string) error {
+	o.rebases = append(o.rebases, fmt.Sprintf("%s->%s(%s)", filepath.Base(image), filepath.Base(backingFile), backingFormat))
+	return nil
+}
+
+var _ = Describe("Backing chain", func() {
+	var (
+		tmpDir  string
+		top     string
+		fetched []string
+		files   map[string]string
+		qemu    *chainQEMUOperations
+	)
+	topURL, _ := url.Parse("https://images.example.com/vms/top.qcow2")
+
+	fetch := func(u *url.URL, dest string) error {
+		fetched = append(fetched, u.String())
+		content, ok := files[u.String()]
+		if !ok {
+			return fmt.Errorf("not found")
+		}
+		return ioutil.WriteFile(dest, []byte(content), 0644)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "backing-chain")
+		Expect(err).NotTo(HaveOccurred())
+		top = filepath.Join(tmpDir, tempFile)
+		Expect(ioutil.WriteFile(top, []byte("top"), 0644)).To(Succeed())
+		fetched = nil
+		files = map[string]string{
+			"https://images.example.com/vms/middle.qcow2": "middle",
+			"https://images.example.com/base/fedora.raw":  "base",
+		}
+		qemu = &chainQEMUOperations{infos: map[string]*image.ImgInfo{
+			"top":    {Format: "qcow2", BackingFile: "middle.qcow2", BackingFormat: "qcow2"},
+			"middle": {Format: "qcow2", BackingFile: "/base/fedora.raw"},
+			"base":   {Format: "raw"},
+		}}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("Should fetch the chain, rebase it on the local copies and flatten it", func() {
+		replaceQEMUOperations(qemu, func() {
+			err := fetchBackingChain(top, topURL, []string{"https://images.example.com/vms", "https://images.example.com/base/"}, fetch)
+			Expect(err).NotTo(HaveOccurred())
+		})
+		Expect(fetched).To(Equal([]string{"https://images.example.com/vms/middle.qcow2", "https://images.example.com/base/fedora.raw"}))
+		Expect(qemu.rebases).To(Equal([]string{"tmpimage->backing-1(qcow2)", "backing-1->backing-2(raw)", "tmpimage->.()"}))
+		entries, err := ioutil.ReadDir(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("Should not touch an image without backing file", func() {
+		qemu.infos["top"] = &image.ImgInfo{Format: "qcow2"}
+		replaceQEMUOperations(qemu, func() {
+			Expect(fetchBackingChain(top, topURL, []string{"https://images.example.com/"}, fetch)).To(Succeed())
+		})
+		Expect(fetched).To(BeEmpty())
+		Expect(qemu.rebases).To(BeEmpty())
+	})
+
+	It("Should reject a backing file that is not allowed", func() {
+		replaceQEMUOperations(qemu, func() {
+			err := fetchBackingChain(top, topURL, []string{"https://images.example.com/vms"}, fetch)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("backing file https://images.example.com/base/fedora.raw of https://images.example.com/vms/middle.qcow2 is not in backingFileURLs"))
+		})
+		Expect(fetched).To(HaveLen(1))
+	})
+
+	It("Should stop following a chain that loops", func() {
+		qemu.infos["middle"] = &image.ImgInfo{Format: "qcow2", BackingFile: "middle.qcow2"}
+		replaceQEMUOperations(qemu, func() {
+			err := fetchBackingChain(top, topURL, []string{"https://images.example.com/"}, fetch)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("longer than %d images", maxBackingChainLength)))
+		})
+		Expect(fetched).To(HaveLen(maxBackingChainLength))
+	})
+
+	It("Should reject a backing file with another format than recorded in the image", func() {
+		qemu.infos["middle"] = &image.ImgInfo{Format: "raw"}
+		replaceQEMUOperations(qemu, func() {
+			err := fetchBackingChain(top, topURL, []string{"https://images.example.com/"}, fetch)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is raw, https://images.example.com/vms/top.qcow2 expects qcow2"))
+		})
+	})
+
+	table.DescribeTable("Should resolve backing file", func(name, expected string) {
+		u, err := resolveBackingURL(topURL, name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(u.String()).To(Equal(expected))
+	},
+		table.Entry("relative to the image", "base.qcow2", "https://images.example.com/vms/base.qcow2"),
+		table.Entry("in a parent directory", "../base/base.qcow2", "https://images.example.com/base/base.qcow2"),
+		table.Entry("with an absolute path", "/var/lib/libvirt/images/base.qcow2", "https://images.example.com/var/lib/libvirt/images/base.qcow2"),
+		table.Entry("with a url", "https://mirror.example.com/base.qcow2", "https://mirror.example.com/base.qcow2"),
+		table.Entry("with a question mark in its name", "base?.qcow2", "https://images.example.com/vms/base%3F.qcow2"),
+	)
+
+	It("Should reject a backing url with another scheme", func() {
+		_, err := resolveBackingURL(topURL, "json:{\"file.filename\": \"/etc/shadow\"}")
+		Expect(err).To(HaveOccurred())
+	})
+
+	table.DescribeTable("Should check the allowed urls", func(backing string, expected bool) {
+		u, err := url.Parse(backing)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isAllowedBackingURL(u, []string{"https://images.example.com/base", "ssh://libvirt.example.com/var/lib/libvirt/images/"})).To(Equal(expected))
+	},
+		table.Entry("below an allowed url", "https://images.example.com/base/fedora.qcow2", true),
+		table.Entry("equal to an allowed url", "https://images.example.com/base", true),
+		table.Entry("sharing a prefix with an allowed url", "https://images.example.com/base-evil/fedora.qcow2", false),
+		table.Entry("on a host sharing a prefix", "https://images.example.com.evil.com/base/fedora.qcow2", false),
+		table.Entry("with a user", "ssh://root@libvirt.example.com/var/lib/libvirt/images/base.qcow2", true),
+	)
+})
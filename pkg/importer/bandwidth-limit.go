@@ -0,0 +1,115 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// bandwidthLimit is the rate the data sources of the importer read their source at, nil for no limit
+var bandwidthLimit *cdiv1.DataVolumeBandwidthLimit
+
+// bandwidthBurst is the most bytes a limited source is read at once, may be overridden in tests
+var bandwidthBurst = 64 << 10
+
+// SetBandwidthLimit limits the rate the data sources of the importer read their source at, nil for no limit. An
+// import with a limit streams its source through the importer, rather than converting it from the endpoint.
+func SetBandwidthLimit(limit *cdiv1.DataVolumeBandwidthLimit) {
+	bandwidthLimit = limit
+}
+
+// bandwidthRate returns the bytes per second limit allows at t, 0 for no limit. The first window of the schedule t is
+// in applies, the rate of limit otherwise. Windows with invalid days or times, which the webhook refuses, never apply.
+func bandwidthRate(limit *cdiv1.DataVolumeBandwidthLimit, t time.Time) int64 {
+	for _, window := range limit.Schedule {
+		start, err := util.ParseTimeOfDay(window.Start)
+		if err != nil {
+			continue
+		}
+		end, err := util.ParseTimeOfDay(window.End)
+		if err != nil {
+			continue
+		}
+		var days []time.Weekday
+		for _, value := range window.Days {
+			if day, err := util.ParseWeekday(value); err == nil {
+				days = append(days, day)
+			}
+		}
+		if len(days) < len(window.Days) {
+			continue
+		}
+		if util.InWeeklyWindow(t, days, start, end) {
+			return quantityRate(window.Rate)
+		}
+	}
+	return quantityRate(limit.Rate)
+}
+
+// quantityRate returns the bytes per second of q, 0 for no limit
+func quantityRate(q *resource.Quantity) int64 {
+	if q == nil {
+		return 0
+	}
+	return q.Value()
+}
+
+// bandwidthLimitedReader reads its stream no faster than the rate its limit allows at the time of the read, as a token
+// bucket that holds up to bandwidthBurst bytes
+type bandwidthLimitedReader struct {
+	stream  io.ReadCloser
+	limit   *cdiv1.DataVolumeBandwidthLimit
+	limiter *rate.Limiter
+	rate    int64
+}
+
+// newBandwidthLimitedReader returns stream read at the rate of the bandwidth limit of the importer, stream itself if
+// there is none
+func newBandwidthLimitedReader(stream io.ReadCloser) io.ReadCloser {
+	if bandwidthLimit == nil {
+		return stream
+	}
+	return &bandwidthLimitedReader{
+		stream:  stream,
+		limit:   bandwidthLimit,
+		limiter: rate.NewLimiter(rate.Inf, bandwidthBurst),
+	}
+}
+
+func (r *bandwidthLimitedReader) Read(p []byte) (int, error) {
+	now := nowFunc()
+	if current := bandwidthRate(r.limit, now); current != r.rate {
+		if current == 0 {
+			klog.Infof("Reading the source without a bandwidth limit")
+			r.limiter.SetLimitAt(now, rate.Inf)
+		} else {
+			klog.Infof("Reading the source at %d bytes per second at most", current)
+			r.limiter.SetLimitAt(now, rate.Limit(current))
+		}
+		r.rate = current
+	}
+	if r.rate == 0 {
+		return r.stream.Read(p)
+	}
+	if len(p) > bandwidthBurst {
+		p = p[:bandwidthBurst]
+	}
+	n, err := r.stream.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(context.Background(), n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (r *bandwidthLimitedReader) Close() error {
+	return r.stream.Close()
+}
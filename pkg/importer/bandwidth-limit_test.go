@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Bandwidth limit", func() {
+	quantity := func(value string) *resource.Quantity {
+		q := resource.MustParse(value)
+		return &q
+	}
+	limit := &cdiv1.DataVolumeBandwidthLimit{
+		Rate: quantity("100Mi"),
+		Schedule: []cdiv1.DataVolumeBandwidthWindow{
+			{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}, Start: "08:00", End: "18:00", Rate: quantity("10Mi")},
+			{Start: "22:00", End: "06:00"},
+		},
+	}
+
+	// 2020-06-01 is a Monday
+	table.DescribeTable("Should limit the rate by the schedule", func(t time.Time, expected int64) {
+		Expect(bandwidthRate(limit, t)).To(Equal(expected))
+	},
+		table.Entry("in business hours", time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC), int64(10<<20)),
+		table.Entry("outside of the windows", time.Date(2020, 6, 1, 19, 0, 0, 0, time.UTC), int64(100<<20)),
+		table.Entry("on the weekend", time.Date(2020, 6, 6, 9, 0, 0, 0, time.UTC), int64(100<<20)),
+		table.Entry("not at night", time.Date(2020, 6, 2, 1, 0, 0, 0, time.UTC), int64(0)),
+	)
+
+	It("Should skip windows with invalid times", func() {
+		invalid := &cdiv1.DataVolumeBandwidthLimit{
+			Schedule: []cdiv1.DataVolumeBandwidthWindow{{Days: []string{"Monday"}, Start: "00:00", End: "23:59", Rate: quantity("1Mi")}},
+		}
+		Expect(bandwidthRate(invalid, time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC))).To(BeZero())
+	})
+
+	It("Should not wrap the stream without a limit", func() {
+		SetBandwidthLimit(nil)
+		stream := ioutil.NopCloser(bytes.NewReader(nil))
+		Expect(newBandwidthLimitedReader(stream)).To(BeIdenticalTo(stream))
+	})
+
+	It("Should read the stream at the rate of the limit", func() {
+		defer SetBandwidthLimit(nil)
+		origBurst := bandwidthBurst
+		bandwidthBurst = 1024
+		defer func() {
+			bandwidthBurst = origBurst
+		}()
+		SetBandwidthLimit(&cdiv1.DataVolumeBandwidthLimit{Rate: quantity("16Ki")})
+		data := bytes.Repeat([]byte("bandwidth"), 1024)
+		start := time.Now()
+		read, err := ioutil.ReadAll(newBandwidthLimitedReader(ioutil.NopCloser(bytes.NewReader(data))))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(data))
+		// The burst is read right away, the rest of the 9KiB at 16KiB per second
+		Expect(time.Since(start)).To(BeNumerically(">=", 400*time.Millisecond))
+	})
+})
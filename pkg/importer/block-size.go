@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"k8s.io/klog"
+)
+
+// blkGetSize64 is the BLKGETSIZE64 ioctl of linux/fs.h, it returns the size of a block device in bytes
+const blkGetSize64 = 0x80081272
+
+// blockDeviceSizeFunc probes the size of a block device, may be overridden in tests
+var blockDeviceSizeFunc = BlockDeviceSize
+
+// BlockDeviceSize returns the size in bytes of the block device at device, probed with the BLKGETSIZE64 ioctl
+func BlockDeviceSize(device string) (int64, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not open %s", device)
+	}
+	defer f.Close()
+	var size uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), blkGetSize64, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, errors.Wrapf(errno, "could not get the size of %s", device)
+	}
+	return int64(size), nil
+}
+
+// sourceSizer is implemented by the data sources that know the virtual size of the image before they transfer it.
+type sourceSizer interface {
+	// SourceSize returns the virtual size of the image, or 0 if it is not known.
+	SourceSize() int64
+}
+
+// ImageTooLargeError indicates that the image is larger than the block device it is written to.
+type ImageTooLargeError struct {
+	Device string
+	// Required is the virtual size of the image
+	Required int64
+	// Available is the size of the block device
+	Available int64
+}
+
+func (e *ImageTooLargeError) Error() string {
+	return fmt.Sprintf("image of %d bytes does not fit the block device %s of %d bytes, it is %d bytes short",
+		e.Required, e.Device, e.Available, e.Required-e.Available)
+}
+
+// checkImageFits returns an ImageTooLargeError if the source knows the virtual size of its image, and the block
+// device or the window of the block target the image is written to is smaller, so that the transfer fails before any
+// data is written rather than when the device fills up.
+func (dp *DataProcessor) checkImageFits() error {
+	sizer, ok := dp.source.(sourceSizer)
+	if !ok || sizer.SourceSize() <= 0 {
+		return nil
+	}
+	if dp.blockTargetWindow != nil {
+		if sizer.SourceSize() > dp.blockTargetWindow.Size {
+			return &ImageTooLargeError{Device: dp.blockTargetDescription(), Required: sizer.SourceSize(), Available: dp.blockTargetWindow.Size}
+		}
+		return nil
+	}
+	available, err := blockDeviceSizeFunc(dp.dataFile)
+	if err != nil {
+		klog.Warningf("Not checking whether the image fits: %v", err)
+		return nil
+	}
+	klog.V(1).Infof("Image of %d bytes, block device of %d bytes", sizer.SourceSize(), available)
+	if sizer.SourceSize() > available {
+		return &ImageTooLargeError{Device: dp.dataFile, Required: sizer.SourceSize(), Available: available}
+	}
+	return nil
+}
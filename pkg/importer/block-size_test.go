@@ -0,0 +1,135 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// sizedDataProvider is a MockDataProvider that knows the virtual size of its image
+type sizedDataProvider struct {
+	MockDataProvider
+	sourceSize int64
+}
+
+func (s *sizedDataProvider) SourceSize() int64 {
+	return s.sourceSize
+}
+
+var _ = Describe("Block device size", func() {
+	var (
+		tmpDir string
+		device string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "block-size")
+		Expect(err).ToNot(HaveOccurred())
+		device = filepath.Join(tmpDir, "device")
+		Expect(ioutil.WriteFile(device, make([]byte, 4*sectorSize), 0644)).To(Succeed())
+		isBlockDeviceFunc = func(string) bool {
+			return true
+		}
+		blockDeviceSizeFunc = func(string) (int64, error) {
+			return 1024 * 1024, nil
+		}
+	})
+
+	AfterEach(func() {
+		isBlockDeviceFunc = isBlockDevice
+		blockDeviceSizeFunc = BlockDeviceSize
+		os.RemoveAll(tmpDir)
+	})
+
+	newSizedDataProvider := func(size int64) *sizedDataProvider {
+		return &sizedDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			},
+			sourceSize: size,
+		}
+	}
+
+	It("Should fail before the transfer if the image does not fit the block device", func() {
+		mdp := newSizedDataProvider(1024*1024 + 512)
+		dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+		err := dp.ProcessDataWithPause()
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(Equal(&ImageTooLargeError{Device: device, Required: 1024*1024 + 512, Available: 1024 * 1024}))
+		Expect(err.Error()).To(ContainSubstring("it is 512 bytes short"))
+		Expect(mdp.calledPhases).To(Equal([]ProcessingPhase{ProcessingPhaseInfo}))
+	})
+
+	It("Should transfer an image that fits the block device", func() {
+		mdp := newSizedDataProvider(1024 * 1024)
+		dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+		Expect(dp.ProcessDataWithPause()).To(Succeed())
+		Expect(mdp.calledPhases).To(Equal([]ProcessingPhase{ProcessingPhaseInfo, ProcessingPhaseTransferDataFile}))
+	})
+
+	It("Should transfer an image of unknown size", func() {
+		mdp := newSizedDataProvider(0)
+		dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+		Expect(dp.ProcessDataWithPause()).To(Succeed())
+	})
+
+	It("Should not check a file system target", func() {
+		isBlockDeviceFunc = func(string) bool {
+			return false
+		}
+		mdp := newSizedDataProvider(1024*1024 + 512)
+		dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+		Expect(dp.ProcessDataWithPause()).To(Succeed())
+	})
+
+	It("Should fail to probe the size of a file", func() {
+		_, err := BlockDeviceSize(device)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Upload source size", func() {
+	raw := bytes.Repeat([]byte{'r'}, 4096)
+
+	It("Should take the content length of raw data", func() {
+		uds := NewUploadDataSource(ioutil.NopCloser(bytes.NewReader(raw)))
+		uds.SetUploadSize(UploadSize{ContentLength: int64(len(raw))})
+		_, err := uds.Info()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(uds.SourceSize()).To(Equal(int64(len(raw))))
+	})
+
+	It("Should prefer the declared size", func() {
+		uds := NewUploadDataSource(ioutil.NopCloser(bytes.NewReader(raw)))
+		uds.SetUploadSize(UploadSize{Source: 8192, ContentLength: int64(len(raw))})
+		_, err := uds.Info()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(uds.SourceSize()).To(Equal(int64(8192)))
+	})
+
+	It("Should not take the content length of compressed data", func() {
+		var compressed bytes.Buffer
+		gzw := gzip.NewWriter(&compressed)
+		_, err := gzw.Write(bytes.Repeat(raw, 256))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gzw.Close()).To(Succeed())
+		uds := NewAsyncUploadDataSource(ioutil.NopCloser(bytes.NewReader(compressed.Bytes())))
+		uds.SetUploadSize(UploadSize{ContentLength: int64(compressed.Len())})
+		_, err = uds.Info()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(uds.SourceSize()).To(BeZero())
+	})
+
+	It("Should not know the size before the header is read", func() {
+		uds := NewUploadDataSource(ioutil.NopCloser(bytes.NewReader(raw)))
+		uds.SetUploadSize(UploadSize{Source: 8192})
+		Expect(uds.SourceSize()).To(BeZero())
+	})
+})
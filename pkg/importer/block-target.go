@@ -0,0 +1,233 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+)
+
+const (
+	// blockTargetImageName is the file in the scratch space an image written to a block target is staged in
+	blockTargetImageName = "block-target.img"
+
+	// gptSignature starts the header of a GPT partition table, in the second logical block of the device
+	gptSignature = "EFI PART"
+	// mbrSignatureOffset is the offset of the 0x55 0xAA signature of an MBR
+	mbrSignatureOffset = 510
+	// mbrPartitionsOffset is the offset of the four primary partition entries of an MBR
+	mbrPartitionsOffset = 446
+	// mbrPartitionEntrySize is the size of an MBR partition entry
+	mbrPartitionEntrySize = 16
+	// mbrProtectiveType is the partition type of the MBR that protects a GPT partition table
+	mbrProtectiveType = 0xee
+	// maxGPTPartitions is the most partitions a GPT partition table is read for
+	maxGPTPartitions = 128
+)
+
+// gptLogicalBlockSizes are the logical block sizes the GPT header is looked for with
+var gptLogicalBlockSizes = []int64{512, 4096}
+
+// BlockTarget is where an image is written to a block device, at Offset or into Partition, at most one of them is set.
+type BlockTarget struct {
+	Offset    int64
+	Partition int32
+}
+
+// BlockTargetWindow is the region of a block device an image is written to.
+type BlockTargetWindow struct {
+	// Offset is the byte offset of the region
+	Offset int64
+	// Size is the size of the region in bytes
+	Size int64
+	// Partition is the number of the partition the region is, 0 if it was given by offset
+	Partition int32
+	// Table is the type of the partition table the partition is in, "gpt" or "mbr"
+	Table string
+}
+
+// Layout describes the window, like "partition=1,table=gpt,offset=1048576,size=10736369664".
+func (w *BlockTargetWindow) Layout() string {
+	layout := fmt.Sprintf("offset=%d,size=%d", w.Offset, w.Size)
+	if w.Partition > 0 {
+		layout = fmt.Sprintf("partition=%d,table=%s,%s", w.Partition, w.Table, layout)
+	}
+	return layout
+}
+
+// ResolveBlockTarget returns the window of the block device at device that target refers to, a partition is looked up
+// in the GPT or MBR partition table of the device.
+func ResolveBlockTarget(device string, target BlockTarget) (*BlockTargetWindow, error) {
+	size, err := blockDeviceSizeFunc(device)
+	if err != nil {
+		return nil, err
+	}
+	if target.Partition <= 0 {
+		if target.Offset < 0 || target.Offset >= size {
+			return nil, errors.Errorf("offset %d is outside of the block device %s of %d bytes", target.Offset, device, size)
+		}
+		return &BlockTargetWindow{Offset: target.Offset, Size: size - target.Offset}, nil
+	}
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", device)
+	}
+	defer f.Close()
+	window, err := findPartition(f, size, target.Partition)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find partition %d of %s", target.Partition, device)
+	}
+	if window.Offset+window.Size > size {
+		return nil, errors.Errorf("partition %d of %s ends at %d, past the end of the device at %d", target.Partition, device, window.Offset+window.Size, size)
+	}
+	return window, nil
+}
+
+// findPartition returns the window of partition in the GPT partition table of r, or in its MBR if it has no GPT.
+func findPartition(r io.ReaderAt, size int64, partition int32) (*BlockTargetWindow, error) {
+	for _, blockSize := range gptLogicalBlockSizes {
+		header := make([]byte, 92)
+		if blockSize+int64(len(header)) > size {
+			continue
+		}
+		if _, err := r.ReadAt(header, blockSize); err != nil {
+			return nil, errors.Wrap(err, "could not read the GPT header")
+		}
+		if bytes.Equal(header[:8], []byte(gptSignature)) {
+			return findGPTPartition(r, header, blockSize, partition)
+		}
+	}
+	if size < sectorSize {
+		return nil, errors.New("the device has no partition table")
+	}
+	mbr := make([]byte, sectorSize)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return nil, errors.Wrap(err, "could not read the MBR")
+	}
+	if mbr[mbrSignatureOffset] != 0x55 || mbr[mbrSignatureOffset+1] != 0xaa {
+		return nil, errors.New("the device has no partition table")
+	}
+	return findMBRPartition(mbr, partition)
+}
+
+// findGPTPartition returns the window of partition in the entries of the GPT partition table whose header is header.
+func findGPTPartition(r io.ReaderAt, header []byte, blockSize int64, partition int32) (*BlockTargetWindow, error) {
+	entriesLBA := int64(binary.LittleEndian.Uint64(header[72:80]))
+	entries := int64(binary.LittleEndian.Uint32(header[80:84]))
+	entrySize := int64(binary.LittleEndian.Uint32(header[84:88]))
+	if entrySize < 128 || entries > maxGPTPartitions {
+		return nil, errors.Errorf("the GPT header lists %d entries of %d bytes", entries, entrySize)
+	}
+	if int64(partition) > entries {
+		return nil, errors.Errorf("the GPT partition table has %d entries", entries)
+	}
+	entry := make([]byte, entrySize)
+	if _, err := r.ReadAt(entry, entriesLBA*blockSize+int64(partition-1)*entrySize); err != nil {
+		return nil, errors.Wrap(err, "could not read the GPT partition entry")
+	}
+	if bytes.Equal(entry[:16], make([]byte, 16)) {
+		return nil, errors.New("the GPT partition entry is unused")
+	}
+	first := int64(binary.LittleEndian.Uint64(entry[32:40]))
+	last := int64(binary.LittleEndian.Uint64(entry[40:48]))
+	if last < first {
+		return nil, errors.Errorf("the GPT partition entry ends at block %d before it starts at block %d", last, first)
+	}
+	return &BlockTargetWindow{Offset: first * blockSize, Size: (last - first + 1) * blockSize, Partition: partition, Table: "gpt"}, nil
+}
+
+// findMBRPartition returns the window of partition in the primary partitions of mbr, logical partitions are not
+// supported.
+func findMBRPartition(mbr []byte, partition int32) (*BlockTargetWindow, error) {
+	if partition > 4 {
+		return nil, errors.New("only the four primary partitions of an MBR partition table are supported")
+	}
+	entry := mbr[mbrPartitionsOffset+int(partition-1)*mbrPartitionEntrySize:]
+	switch entry[4] {
+	case 0:
+		return nil, errors.New("the MBR partition entry is unused")
+	case mbrProtectiveType:
+		return nil, errors.New("the MBR protects a GPT partition table that could not be read")
+	}
+	first := int64(binary.LittleEndian.Uint32(entry[8:12]))
+	sectors := int64(binary.LittleEndian.Uint32(entry[12:16]))
+	if sectors == 0 {
+		return nil, errors.New("the MBR partition entry is empty")
+	}
+	return &BlockTargetWindow{Offset: first * sectorSize, Size: sectors * sectorSize, Partition: partition, Table: "mbr"}, nil
+}
+
+// startBlockTarget resolves the window of the block target of the target device, and has the image written to the
+// scratch space instead, it is copied into the window by finishBlockTarget once it is complete. Unlike a whole
+// device, the device is neither wiped nor marked as partially written, that would overwrite the partitioning of the
+// user.
+func (dp *DataProcessor) startBlockTarget() error {
+	window, err := ResolveBlockTarget(dp.dataFile, *dp.blockTarget)
+	if err != nil {
+		return errors.Wrap(err, "Unable to prepare the block target")
+	}
+	if getAvailableSpaceFunc(dp.scratchDataDir) <= 0 {
+		return ErrRequiresScratchSpace
+	}
+	klog.V(1).Infof("Writing the image to %s at %s", dp.dataFile, window.Layout())
+	dp.blockTargetDevice = dp.dataFile
+	dp.blockTargetWindow = window
+	dp.dataFile = filepath.Join(dp.scratchDataDir, blockTargetImageName)
+	dp.availableSpace = window.Size
+	return nil
+}
+
+// finishBlockTarget copies the image staged in the scratch space into the window of the block target.
+func (dp *DataProcessor) finishBlockTarget() error {
+	src, err := os.Open(dp.dataFile)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", dp.dataFile)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "could not stat %s", dp.dataFile)
+	}
+	if info.Size() > dp.blockTargetWindow.Size {
+		return &ImageTooLargeError{Device: dp.blockTargetDescription(), Required: info.Size(), Available: dp.blockTargetWindow.Size}
+	}
+	dst, err := os.OpenFile(dp.blockTargetDevice, os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", dp.blockTargetDevice)
+	}
+	defer dst.Close()
+	if _, err := dst.Seek(dp.blockTargetWindow.Offset, io.SeekStart); err != nil {
+		return errors.Wrapf(err, "could not seek %s", dp.blockTargetDevice)
+	}
+	if _, err := io.CopyBuffer(dst, src, make([]byte, wipeBufferSize)); err != nil {
+		return errors.Wrapf(err, "could not write the image to %s", dp.blockTargetDescription())
+	}
+	return dst.Sync()
+}
+
+// blockTargetDescription describes the block target in errors.
+func (dp *DataProcessor) blockTargetDescription() string {
+	return fmt.Sprintf("%s at %s", dp.blockTargetDevice, dp.blockTargetWindow.Layout())
+}
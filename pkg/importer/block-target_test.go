@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// writingDataProvider is a MockDataProvider that writes data to the file it transfers to
+type writingDataProvider struct {
+	MockDataProvider
+	data []byte
+}
+
+func (w *writingDataProvider) TransferFile(fileName string) (ProcessingPhase, error) {
+	if err := ioutil.WriteFile(fileName, w.data, 0644); err != nil {
+		return ProcessingPhaseError, err
+	}
+	return w.MockDataProvider.TransferFile(fileName)
+}
+
+// writeGPT writes a GPT partition table with 512 byte blocks to device, with a partition from block first to block
+// last as its second entry
+func writeGPT(device string, first, last uint64) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	header := make([]byte, 92)
+	copy(header, gptSignature)
+	binary.LittleEndian.PutUint64(header[72:], 2)
+	binary.LittleEndian.PutUint32(header[80:], 128)
+	binary.LittleEndian.PutUint32(header[84:], 128)
+	_, err = f.WriteAt(header, 512)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = f.WriteAt(make([]byte, 128*128), 2*512)
+	Expect(err).ToNot(HaveOccurred())
+	entry := make([]byte, 128)
+	entry[0] = 0xaf
+	binary.LittleEndian.PutUint64(entry[32:], first)
+	binary.LittleEndian.PutUint64(entry[40:], last)
+	_, err = f.WriteAt(entry, 2*512+128)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+// writeMBR writes an MBR to device, with a partition of type partitionType from sector first of sectors sectors as its
+// first entry
+func writeMBR(device string, partitionType byte, first, sectors uint32) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	mbr := make([]byte, sectorSize)
+	entry := mbr[mbrPartitionsOffset:]
+	entry[4] = partitionType
+	binary.LittleEndian.PutUint32(entry[8:], first)
+	binary.LittleEndian.PutUint32(entry[12:], sectors)
+	mbr[mbrSignatureOffset], mbr[mbrSignatureOffset+1] = 0x55, 0xaa
+	_, err = f.WriteAt(mbr, 0)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+var _ = Describe("Block target", func() {
+	const deviceSize = 4 * 1024 * 1024
+
+	var (
+		tmpDir     string
+		scratchDir string
+		device     string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "block-target")
+		Expect(err).ToNot(HaveOccurred())
+		scratchDir = filepath.Join(tmpDir, "scratch")
+		Expect(os.Mkdir(scratchDir, 0755)).To(Succeed())
+		device = filepath.Join(tmpDir, "device")
+		Expect(ioutil.WriteFile(device, bytes.Repeat([]byte{'d'}, deviceSize), 0644)).To(Succeed())
+		isBlockDeviceFunc = func(string) bool {
+			return true
+		}
+		blockDeviceSizeFunc = func(string) (int64, error) {
+			return deviceSize, nil
+		}
+	})
+
+	AfterEach(func() {
+		isBlockDeviceFunc = isBlockDevice
+		blockDeviceSizeFunc = BlockDeviceSize
+		os.RemoveAll(tmpDir)
+	})
+
+	table.DescribeTable("Should describe the layout", func(window *BlockTargetWindow, expectedLayout string) {
+		Expect(window.Layout()).To(Equal(expectedLayout))
+	},
+		table.Entry("of an offset", &BlockTargetWindow{Offset: 1048576, Size: 3145728}, "offset=1048576,size=3145728"),
+		table.Entry("of a partition", &BlockTargetWindow{Offset: 1048576, Size: 1048576, Partition: 2, Table: "gpt"}, "partition=2,table=gpt,offset=1048576,size=1048576"),
+	)
+
+	It("Should resolve an offset to the rest of the device", func() {
+		window, err := ResolveBlockTarget(device, BlockTarget{Offset: 1048576})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(window).To(Equal(&BlockTargetWindow{Offset: 1048576, Size: deviceSize - 1048576}))
+	})
+
+	It("Should reject an offset past the end of the device", func() {
+		_, err := ResolveBlockTarget(device, BlockTarget{Offset: deviceSize})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should resolve a partition of a GPT partition table", func() {
+		writeGPT(device, 2048, 4095)
+		window, err := ResolveBlockTarget(device, BlockTarget{Partition: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(window).To(Equal(&BlockTargetWindow{Offset: 1048576, Size: 1048576, Partition: 2, Table: "gpt"}))
+	})
+
+	It("Should reject an unused entry of a GPT partition table", func() {
+		writeGPT(device, 2048, 4095)
+		_, err := ResolveBlockTarget(device, BlockTarget{Partition: 1})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unused"))
+	})
+
+	It("Should reject a partition past the end of the device", func() {
+		writeGPT(device, 2048, 16383)
+		_, err := ResolveBlockTarget(device, BlockTarget{Partition: 2})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("past the end of the device"))
+	})
+
+	It("Should resolve a partition of an MBR partition table", func() {
+		writeMBR(device, 0x83, 2048, 2048)
+		window, err := ResolveBlockTarget(device, BlockTarget{Partition: 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(window).To(Equal(&BlockTargetWindow{Offset: 1048576, Size: 1048576, Partition: 1, Table: "mbr"}))
+	})
+
+	It("Should reject the protective MBR of an unreadable GPT partition table", func() {
+		writeMBR(device, mbrProtectiveType, 1, 8191)
+		_, err := ResolveBlockTarget(device, BlockTarget{Partition: 1})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("protects a GPT partition table"))
+	})
+
+	It("Should reject a device without a partition table", func() {
+		_, err := ResolveBlockTarget(device, BlockTarget{Partition: 1})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("no partition table"))
+	})
+
+	It("Should write the image into the partition only", func() {
+		writeGPT(device, 2048, 4095)
+		image := bytes.Repeat([]byte{'i'}, 4096)
+		mdp := &writingDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseResize,
+			},
+			data: image,
+		}
+		dp := NewDataProcessor(mdp, device, "dataDir", scratchDir, "1Gi")
+		dp.SetBlockTarget(BlockTarget{Partition: 2})
+		Expect(dp.ProcessDataWithPause()).To(Succeed())
+		Expect(mdp.transferFile).To(Equal(filepath.Join(scratchDir, blockTargetImageName)))
+		Expect(dp.BlockTargetWindow()).To(Equal(&BlockTargetWindow{Offset: 1048576, Size: 1048576, Partition: 2, Table: "gpt"}))
+		written, err := ioutil.ReadFile(device)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written[1048576 : 1048576+len(image)]).To(Equal(image))
+		Expect(written[1048576+len(image) : 1048576+len(image)+sectorSize]).To(Equal(bytes.Repeat([]byte{'d'}, sectorSize)))
+		Expect(written[deviceSize-sectorSize:]).To(Equal(bytes.Repeat([]byte{'d'}, sectorSize)), "the last sector holds no partial write marker")
+		Expect(written[512:520]).To(Equal([]byte(gptSignature)))
+	})
+
+	It("Should require scratch space", func() {
+		mdp := &writingDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			},
+		}
+		dp := NewDataProcessor(mdp, device, "dataDir", filepath.Join(tmpDir, "none"), "")
+		dp.SetBlockTarget(BlockTarget{Offset: 1048576})
+		Expect(dp.ProcessDataWithPause()).To(Equal(ErrRequiresScratchSpace))
+		Expect(mdp.calledPhases).To(BeEmpty())
+	})
+
+	It("Should fail before the transfer if the image does not fit the window", func() {
+		mdp := &sizedDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			},
+			sourceSize: deviceSize,
+		}
+		dp := NewDataProcessor(mdp, device, "dataDir", scratchDir, "")
+		dp.SetBlockTarget(BlockTarget{Offset: 1048576})
+		err := dp.ProcessDataWithPause()
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(BeAssignableToTypeOf(&ImageTooLargeError{}))
+		Expect(err.Error()).To(ContainSubstring("offset=1048576,size=3145728"))
+		Expect(mdp.calledPhases).To(Equal([]ProcessingPhase{ProcessingPhaseInfo}))
+	})
+
+	It("Should fail if the written image does not fit the window", func() {
+		mdp := &writingDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			},
+			data: make([]byte, deviceSize),
+		}
+		dp := NewDataProcessor(mdp, device, "dataDir", scratchDir, "")
+		dp.SetBlockTarget(BlockTarget{Offset: 1048576})
+		err := dp.ProcessDataWithPause()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("does not fit the block device"))
+	})
+})
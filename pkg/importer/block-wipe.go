@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"k8s.io/klog"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// blkDiscard is the BLKDISCARD ioctl of linux/fs.h, it discards a range of a block device
+	blkDiscard = 0x1277
+	// blkZeroOut is the BLKZEROOUT ioctl of linux/fs.h, it zeroes a range of a block device
+	blkZeroOut = 0x127f
+)
+
+// blockRangeIoctlFunc issues an ioctl that takes the range of a block device, may be overridden in tests
+var blockRangeIoctlFunc = blockRangeIoctl
+
+func blockRangeIoctl(f *os.File, request uintptr, size int64) error {
+	r := [2]uint64{0, uint64(size)}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), request, uintptr(unsafe.Pointer(&r[0]))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// WipeBlockDevice zeroes the whole block device at device, so data an earlier user of the device left behind does not
+// show through the regions an image leaves unwritten. With the discard block wipe the device is discarded first, which
+// frees the blocks of thin provisioned storage. A discarded block only reads back as zeros if the storage guarantees
+// it, so the device is zeroed after that too. A device that does not support BLKZEROOUT is zeroed by writing zeros.
+func WipeBlockDevice(device string, blockWipe cdiv1.DataVolumeBlockWipe) error {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", device)
+	}
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrapf(err, "could not get the size of %s", device)
+	}
+	if blockWipe == cdiv1.DataVolumeBlockWipeDiscard {
+		klog.Infof("Discarding %d bytes of %s", size, device)
+		if err = blockRangeIoctlFunc(f, blkDiscard, size); err != nil {
+			klog.Warningf("Could not discard %s: %v", device, err)
+		}
+	}
+	klog.Infof("Zeroing %d bytes of %s", size, device)
+	if err = blockRangeIoctlFunc(f, blkZeroOut, size); err == nil {
+		return nil
+	}
+	klog.Warningf("Could not zero %s with BLKZEROOUT, writing zeros instead: %v", device, err)
+	if err := wipe(f, size); err != nil {
+		return errors.Wrapf(err, "could not zero %s", device)
+	}
+	return f.Sync()
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Block wipe", func() {
+	var (
+		tmpDir   string
+		device   string
+		requests []uintptr
+	)
+
+	const deviceSize = 4 * sectorSize
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "block-wipe")
+		Expect(err).ToNot(HaveOccurred())
+		device = filepath.Join(tmpDir, "device")
+		Expect(ioutil.WriteFile(device, bytes.Repeat([]byte{'d'}, deviceSize), 0644)).To(Succeed())
+		requests = nil
+	})
+
+	AfterEach(func() {
+		blockRangeIoctlFunc = blockRangeIoctl
+		os.RemoveAll(tmpDir)
+	})
+
+	// supportIoctls makes the ioctls in supported succeed without touching the device, and the others fail
+	supportIoctls := func(supported ...uintptr) {
+		blockRangeIoctlFunc = func(f *os.File, request uintptr, size int64) error {
+			Expect(size).To(Equal(int64(deviceSize)))
+			requests = append(requests, request)
+			for _, r := range supported {
+				if r == request {
+					return nil
+				}
+			}
+			return syscall.EOPNOTSUPP
+		}
+	}
+
+	table.DescribeTable("Should use the ioctls the device supports", func(blockWipe cdiv1.DataVolumeBlockWipe, supported []uintptr, expected []uintptr) {
+		supportIoctls(supported...)
+		Expect(WipeBlockDevice(device, blockWipe)).To(Succeed())
+		Expect(requests).To(Equal(expected))
+	},
+		table.Entry("discard, then zero since discarded blocks may not read back as zeros", cdiv1.DataVolumeBlockWipeDiscard, []uintptr{blkDiscard, blkZeroOut}, []uintptr{blkDiscard, blkZeroOut}),
+		table.Entry("zero when discard is not supported", cdiv1.DataVolumeBlockWipeDiscard, []uintptr{blkZeroOut}, []uintptr{blkDiscard, blkZeroOut}),
+		table.Entry("zero", cdiv1.DataVolumeBlockWipeZero, []uintptr{blkDiscard, blkZeroOut}, []uintptr{blkZeroOut}),
+	)
+
+	It("Should write zeros if the device supports neither ioctl", func() {
+		supportIoctls()
+		Expect(WipeBlockDevice(device, cdiv1.DataVolumeBlockWipeDiscard)).To(Succeed())
+		Expect(requests).To(Equal([]uintptr{blkDiscard, blkZeroOut}))
+		content, err := ioutil.ReadFile(device)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal(make([]byte, deviceSize)))
+	})
+
+	It("Should zero a file, which supports no block device ioctls", func() {
+		Expect(WipeBlockDevice(device, cdiv1.DataVolumeBlockWipeZero)).To(Succeed())
+		content, err := ioutil.ReadFile(device)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal(make([]byte, deviceSize)))
+	})
+
+	Context("with the data processor", func() {
+		BeforeEach(func() {
+			isBlockDeviceFunc = func(string) bool {
+				return true
+			}
+		})
+
+		AfterEach(func() {
+			isBlockDeviceFunc = isBlockDevice
+		})
+
+		It("Should wipe the device before the transfer", func() {
+			supportIoctls()
+			mdp := &MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			}
+			dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+			dp.SetBlockWipe(cdiv1.DataVolumeBlockWipeZero)
+			Expect(dp.ProcessDataWithPause()).To(Succeed())
+			Expect(requests).To(Equal([]uintptr{blkZeroOut}))
+		})
+
+		It("Should not wipe the device without a block wipe", func() {
+			supportIoctls()
+			mdp := &MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			}
+			dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+			Expect(dp.ProcessDataWithPause()).To(Succeed())
+			Expect(requests).To(BeEmpty())
+		})
+	})
+})
@@ -19,11 +19,14 @@ package importer
 import (
 	"fmt"
 	"net/url"
+	"os"
 
 	"github.com/pkg/errors"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/image"
 	"kubevirt.io/containerized-data-importer/pkg/util"
 )
@@ -63,6 +66,22 @@ var ErrRequiresScratchSpace = fmt.Errorf("scratch space required and none found"
 // ErrInvalidPath indicates that the path is invalid.
 var ErrInvalidPath = fmt.Errorf("invalid transfer path")
 
+// ScratchSpaceExhaustedError indicates that the scratch space filled up while the data was written to it.
+type ScratchSpaceExhaustedError struct {
+	// Required is the scratch space the data needs, or the space it filled if the size of the data is not known.
+	Required int64
+	// Available is the scratch space that was available before the data was written to it.
+	Available int64
+}
+
+func (e *ScratchSpaceExhaustedError) Error() string {
+	return fmt.Sprintf(common.ScratchSpaceExhaustedMessage, e.Required, e.Available)
+}
+
+// scratchSpaceFullThreshold is the free scratch space below which a write that ran out of space is assumed to have
+// filled the scratch space rather than the target.
+const scratchSpaceFullThreshold = int64(1024 * 1024)
+
 // may be overridden in tests
 var getAvailableSpaceBlockFunc = util.GetAvailableSpaceBlock
 var getAvailableSpaceFunc = util.GetAvailableSpace
@@ -83,6 +102,26 @@ type DataSourceInterface interface {
 	Close() error
 }
 
+// scratchSizer is implemented by the data sources that know how much scratch space their data needs.
+type scratchSizer interface {
+	// ScratchSize returns the scratch space the data needs, or 0 if it is not known.
+	ScratchSize() int64
+}
+
+// convertedSizer is implemented by the data sources that know the size the converted image must have at least.
+type convertedSizer interface {
+	// ConvertedSize returns the virtual size of the image before the conversion, or 0 if it is not known.
+	ConvertedSize() int64
+}
+
+// transferResumer is implemented by the data sources that checkpoint the transfer to the target, so the transfer
+// resumes where an earlier attempt, such as an evicted importer pod, stopped.
+type transferResumer interface {
+	// ResumeTransfer returns the phase the transfer to dataFile, or to dataDir for an archive, resumes in, false if
+	// there is no checkpoint of the same data to resume at
+	ResumeTransfer(dataFile, dataDir string) (ProcessingPhase, bool)
+}
+
 //ResumableDataSource is the interface all resumeable data sources should implement
 type ResumableDataSource interface {
 	DataSourceInterface
@@ -105,6 +144,26 @@ type DataProcessor struct {
 	requestImageSize string
 	// available space is the available space before downloading the image
 	availableSpace int64
+	// availableScratchSpace is the available scratch space before the data is written to it
+	availableScratchSpace int64
+	// imageCheck is how the consistency of the image is checked before it is converted, empty to not check it
+	imageCheck cdiv1.DataVolumeImageCheck
+	// imageCheckResult describes the result of checking the image
+	imageCheckResult string
+	// verification is how thoroughly the written data is verified
+	verification cdiv1.DataVolumeVerification
+	// blockWipe is how a target block device is wiped before it is written, empty to not wipe it
+	blockWipe cdiv1.DataVolumeBlockWipe
+	// blockTarget is where the image is written to a target block device, nil to write the whole device
+	blockTarget *BlockTarget
+	// blockTargetDevice is the target block device, once the image is staged in the scratch space for blockTarget
+	blockTargetDevice string
+	// blockTargetWindow is the region of the target block device blockTarget refers to
+	blockTargetWindow *BlockTargetWindow
+	// keepDataDir keeps the other files in the target directory, only an earlier data file is removed
+	keepDataDir bool
+	// stageDurations are how long the stages of processing took
+	stageDurations StageDurations
 }
 
 // NewDataProcessor create a new instance of a data processor using the passed in data provider.
@@ -122,6 +181,49 @@ func NewDataProcessor(dataSource DataSourceInterface, dataFile, dataDir, scratch
 	return dp
 }
 
+// SetImageCheck sets how the consistency of the image is checked before it is converted.
+func (dp *DataProcessor) SetImageCheck(imageCheck cdiv1.DataVolumeImageCheck) {
+	dp.imageCheck = imageCheck
+}
+
+// SetVerification sets how thoroughly the written data is verified, the size of the converted image is not checked
+// with DataVolumeVerificationNone.
+func (dp *DataProcessor) SetVerification(verification cdiv1.DataVolumeVerification) {
+	dp.verification = verification
+}
+
+// SetBlockWipe sets how a target block device is wiped before it is written.
+func (dp *DataProcessor) SetBlockWipe(blockWipe cdiv1.DataVolumeBlockWipe) {
+	dp.blockWipe = blockWipe
+}
+
+// SetBlockTarget sets where the image is written to a target block device, it is ignored if the target is a file.
+func (dp *DataProcessor) SetBlockTarget(blockTarget BlockTarget) {
+	dp.blockTarget = &blockTarget
+}
+
+// SetKeepDataDir sets whether the other files in the target directory are kept, so several named images can be
+// imported to one file system volume.
+func (dp *DataProcessor) SetKeepDataDir(keepDataDir bool) {
+	dp.keepDataDir = keepDataDir
+}
+
+// BlockTargetWindow returns the region of the target block device the image was written to, or nil if no block target
+// was set.
+func (dp *DataProcessor) BlockTargetWindow() *BlockTargetWindow {
+	return dp.blockTargetWindow
+}
+
+// ImageCheckResult returns the result of checking the image, or an empty string if it was not checked.
+func (dp *DataProcessor) ImageCheckResult() string {
+	return dp.imageCheckResult
+}
+
+// StageDurations returns how long the stages of processing took.
+func (dp *DataProcessor) StageDurations() StageDurations {
+	return dp.stageDurations
+}
+
 // ProcessData is the main synchronous processing loop
 func (dp *DataProcessor) ProcessData() error {
 	if util.GetAvailableSpace(dp.scratchDataDir) > int64(0) {
@@ -132,15 +234,37 @@ func (dp *DataProcessor) ProcessData() error {
 		// Attempt to be a good citizen and clean up my mess at the end.
 		defer CleanDir(dp.scratchDataDir)
 	}
+	if phase, ok := dp.resumeTransfer(); ok {
+		// The data an earlier attempt transferred stays
+		dp.currentPhase = phase
+		return dp.ProcessDataWithPause()
+	}
 	if util.GetAvailableSpace(dp.dataDir) > int64(0) {
-		// Clean up data dir before trying to write in case a previous attempt failed and left some stuff behind.
-		if err := CleanDir(dp.dataDir); err != nil {
-			return errors.Wrap(err, "Failure cleaning up target space")
+		if dp.keepDataDir {
+			// Only replace the data file, the other images in the data dir belong to the same volume.
+			if err := os.Remove(dp.dataFile); err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, "Failure cleaning up target file")
+			}
+		} else {
+			// Clean up data dir before trying to write in case a previous attempt failed and left some stuff behind.
+			if err := CleanDir(dp.dataDir); err != nil {
+				return errors.Wrap(err, "Failure cleaning up target space")
+			}
 		}
 	}
 	return dp.ProcessDataWithPause()
 }
 
+// resumeTransfer resumes the transfer of an earlier attempt if the source checkpointed it, and returns the phase it
+// resumes in
+func (dp *DataProcessor) resumeTransfer() (ProcessingPhase, bool) {
+	resumer, ok := dp.source.(transferResumer)
+	if !ok || dp.blockTarget != nil {
+		return ProcessingPhaseInfo, false
+	}
+	return resumer.ResumeTransfer(dp.dataFile, dp.dataDir)
+}
+
 // ProcessDataResume Resume a paused processor, assumes the provided data source is ResumableDataSource
 func (dp *DataProcessor) ProcessDataResume() error {
 	rds, ok := dp.source.(ResumableDataSource)
@@ -155,18 +279,42 @@ func (dp *DataProcessor) ProcessDataResume() error {
 // ProcessDataWithPause is the main processing loop.
 func (dp *DataProcessor) ProcessDataWithPause() error {
 	var err error
+	writesBlockDevice := isBlockDeviceFunc(dp.dataFile)
+	if writesBlockDevice && dp.blockTarget != nil {
+		// Not wrapped, the caller tells ErrRequiresScratchSpace apart
+		if err = dp.startBlockTarget(); err != nil {
+			return err
+		}
+		writesBlockDevice = false
+	}
+	if writesBlockDevice && dp.currentPhase == ProcessingPhaseInfo {
+		if dp.blockWipe != "" {
+			if err = WipeBlockDevice(dp.dataFile, dp.blockWipe); err != nil {
+				return errors.Wrap(err, "Unable to wipe the target block device")
+			}
+		}
+		if err = startPartialWrite(dp.dataFile); err != nil {
+			return errors.Wrap(err, "Unable to prepare the target block device")
+		}
+	}
 	for dp.currentPhase != ProcessingPhaseComplete && dp.currentPhase != ProcessingPhasePause {
+		phase, started := dp.currentPhase, nowFunc()
 		switch dp.currentPhase {
 		case ProcessingPhaseInfo:
 			dp.currentPhase, err = dp.source.Info()
 			if err != nil {
 				err = errors.Wrap(err, "Unable to obtain information about data source")
+			} else if writesBlockDevice || dp.blockTargetWindow != nil {
+				err = dp.checkImageFits()
 			}
 		case ProcessingPhaseTransferScratch:
+			dp.availableScratchSpace = getAvailableSpaceFunc(dp.scratchDataDir)
 			dp.currentPhase, err = dp.source.Transfer(dp.scratchDataDir)
 			if err == ErrInvalidPath {
 				// Passed in invalid scratch space path, return scratch space needed error.
 				err = ErrRequiresScratchSpace
+			} else if isNoSpaceError(err) {
+				err = dp.scratchSpaceExhausted()
 			} else if err != nil {
 				err = errors.Wrap(err, "Unable to transfer source data to scratch space")
 			}
@@ -182,7 +330,11 @@ func (dp *DataProcessor) ProcessDataWithPause() error {
 			}
 		case ProcessingPhaseProcess:
 			dp.currentPhase, err = dp.source.Process()
-			if err != nil {
+			if dp.availableScratchSpace > 0 && isNoSpaceError(err) && dp.scratchSpaceFull() {
+				// Processing, such as extracting an archive, writes to the scratch space too, but it may also
+				// write to the target, so only blame the scratch space if that is what filled up
+				err = dp.scratchSpaceExhausted()
+			} else if err != nil {
 				err = errors.Wrap(err, "Unable to process source data to intermediate state before transferring to target")
 			}
 		case ProcessingPhaseConvert:
@@ -198,43 +350,147 @@ func (dp *DataProcessor) ProcessDataWithPause() error {
 		default:
 			return errors.Errorf("Unknown processing phase %s", dp.currentPhase)
 		}
+		dp.stageDurations.add(phase, nowFunc().Sub(started))
 		if err != nil {
 			klog.Errorf("%+v", err)
 			return err
 		}
 		klog.V(1).Infof("New phase: %s\n", dp.currentPhase)
 	}
+	if writesBlockDevice && dp.currentPhase == ProcessingPhaseComplete {
+		if err = finishPartialWrite(dp.dataFile); err != nil {
+			return errors.Wrap(err, "Unable to finish writing the target block device")
+		}
+	}
+	if dp.blockTargetWindow != nil && dp.currentPhase == ProcessingPhaseComplete {
+		if err = dp.finishBlockTarget(); err != nil {
+			return errors.Wrap(err, "Unable to write the image to the block target")
+		}
+	}
 	return err
 }
 
+// scratchSpaceFull returns true if there is next to no space left in the scratch space.
+func (dp *DataProcessor) scratchSpaceFull() bool {
+	return getAvailableSpaceFunc(dp.scratchDataDir) < scratchSpaceFullThreshold
+}
+
+// scratchSpaceExhausted returns the error for a scratch space that filled up, with the best known size of the data.
+func (dp *DataProcessor) scratchSpaceExhausted() error {
+	required := dirSize(dp.scratchDataDir)
+	if sizer, ok := dp.source.(scratchSizer); ok && sizer.ScratchSize() > required {
+		required = sizer.ScratchSize()
+	}
+	return &ScratchSpaceExhaustedError{Required: required, Available: dp.availableScratchSpace}
+}
+
 func (dp *DataProcessor) validate(url *url.URL) error {
 	klog.V(1).Infoln("Validating image")
 	err := qemuOperations.Validate(url, dp.availableSpace)
+	if tooLarge, ok := errors.Cause(err).(*image.ImageTooLargeError); ok {
+		// A larger volume is the only way out, tell how large
+		filesystem := getAvailableSpaceBlockFunc(dp.dataFile) < 0 && dp.blockTargetWindow == nil
+		required := tooLarge.VirtualSize
+		if image.EncryptsTarget() {
+			required += image.LUKSHeaderSize
+		}
+		return util.NewPermanentError(util.PermanentErrorTargetTooSmall, errors.Errorf(
+			"The image has a virtual size of %s, %s are available to it, the volume has to be at least %s",
+			formatSize(tooLarge.VirtualSize), formatSize(tooLarge.AvailableSize),
+			formatSize(util.MinimumTargetSize(required, filesystem))))
+	}
 	if err != nil {
 		return errors.Wrap(err, "Image validation failed")
 	}
 	return nil
 }
 
+// formatSize returns size in bytes as a quantity, such as 10Gi
+func formatSize(size int64) string {
+	return resource.NewQuantity(size, resource.BinarySI).String()
+}
+
 // convert is called when convert the image from the url to a RAW disk image. Source formats include RAW/QCOW2 (Raw to raw conversion is a copy)
 func (dp *DataProcessor) convert(url *url.URL) (ProcessingPhase, error) {
 	err := dp.validate(url)
 	if err != nil {
 		return ProcessingPhaseError, err
 	}
+	if err = dp.checkImage(url); err != nil {
+		return ProcessingPhaseError, err
+	}
 	klog.V(3).Infoln("Converting to Raw")
 	err = qemuOperations.ConvertToRawStream(url, dp.dataFile)
 	if err != nil {
 		return ProcessingPhaseError, errors.Wrap(err, "Conversion to Raw failed")
 	}
+	if err = dp.verifyConverted(); err != nil {
+		return ProcessingPhaseError, err
+	}
 
 	return ProcessingPhaseResize, nil
 }
 
+// verifyConverted returns a ByteCountError if the converted image is smaller than the virtual size of the image the
+// source recorded before the conversion.
+func (dp *DataProcessor) verifyConverted() error {
+	sizer, ok := dp.source.(convertedSizer)
+	if !ok || sizer.ConvertedSize() <= 0 || dp.verification == cdiv1.DataVolumeVerificationNone {
+		return nil
+	}
+	info, err := qemuOperations.Info(&url.URL{Path: dp.dataFile})
+	if err != nil {
+		return errors.Wrap(err, "Unable to get the size of the converted image")
+	}
+	if info.VirtualSize < sizer.ConvertedSize() {
+		return &ByteCountError{Counted: "converted image has", Actual: info.VirtualSize, Expected: sizer.ConvertedSize()}
+	}
+	return nil
+}
+
+// checkImage checks the consistency of the qcow2 image at url, if it is to be checked, and records the result. A corrupt
+// image fails permanently, converting it would only produce a disk with wrong data.
+func (dp *DataProcessor) checkImage(url *url.URL) error {
+	if dp.imageCheck == "" {
+		return nil
+	}
+	info, err := qemuOperations.Info(url)
+	if err != nil {
+		return errors.Wrap(err, "Image check failed")
+	}
+	if info.Format != "qcow2" {
+		dp.imageCheckResult = fmt.Sprintf("not checked, a %s image has no metadata to check", info.Format)
+		return nil
+	}
+	klog.V(1).Infoln("Checking image")
+	check, err := qemuOperations.Check(url, dp.imageCheck == cdiv1.DataVolumeImageCheckRepairLeaks)
+	if err != nil {
+		return errors.Wrap(err, "Image check failed")
+	}
+	if check.Corruptions > 0 {
+		return util.NewPermanentError(util.PermanentErrorImageCorrupt, errors.Errorf("qemu-img check found %d corruptions on the image", check.Corruptions))
+	}
+	if check.CheckErrors > 0 {
+		return errors.Errorf("qemu-img check could not check the image, %d errors", check.CheckErrors)
+	}
+	switch {
+	case check.LeaksFixed > 0 && check.Leaks > 0:
+		dp.imageCheckResult = fmt.Sprintf("%d leaked clusters repaired, %d left", check.LeaksFixed, check.Leaks)
+	case check.LeaksFixed > 0:
+		dp.imageCheckResult = fmt.Sprintf("%d leaked clusters repaired", check.LeaksFixed)
+	case check.Leaks > 0:
+		dp.imageCheckResult = fmt.Sprintf("%d leaked clusters found", check.Leaks)
+	default:
+		dp.imageCheckResult = "no errors found"
+	}
+	return nil
+}
+
 func (dp *DataProcessor) resize() (ProcessingPhase, error) {
-	// Resize only if we have a resize request, and if the image is on a file system pvc.
+	// Resize only if we have a resize request, and if the image is on a file system pvc, an image staged for a block
+	// target is written to a block device too.
 	klog.V(3).Infof("Available space in dataFile: %d", getAvailableSpaceBlockFunc(dp.dataFile))
-	if dp.requestImageSize != "" && getAvailableSpaceBlockFunc(dp.dataFile) < int64(0) {
+	if dp.requestImageSize != "" && getAvailableSpaceBlockFunc(dp.dataFile) < int64(0) && dp.blockTargetWindow == nil {
 		klog.V(3).Infoln("Resizing image")
 		err := ResizeImage(dp.dataFile, dp.requestImageSize, dp.availableSpace)
 		if err != nil {
@@ -291,5 +547,9 @@ func (dp *DataProcessor) calculateTargetSize() int64 {
 	}
 	klog.V(1).Infof("Target size %s.\n", targetQuantity.String())
 	targetSize, _ := targetQuantity.AsInt64()
+	if image.EncryptsTarget() {
+		// The LUKS header of the encrypted image takes space in front of the data
+		targetSize -= image.LUKSHeaderSize
+	}
 	return targetSize
 }
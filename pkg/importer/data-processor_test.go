@@ -4,6 +4,8 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path/filepath"
+	"syscall"
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/extensions/table"
@@ -13,7 +15,9 @@ import (
 
 	"github.com/pkg/errors"
 
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	"kubevirt.io/containerized-data-importer/pkg/image"
+	"kubevirt.io/containerized-data-importer/pkg/util"
 )
 
 type fakeInfoOpRetVal struct {
@@ -41,6 +45,8 @@ type fakeQEMUOperations struct {
 	e5             error
 	e6             error
 	resizeQuantity *resource.Quantity
+	check          *image.ImgCheck
+	repairedLeaks  bool
 }
 
 type MockDataProvider struct {
@@ -277,6 +283,67 @@ var _ = Describe("Data Processor", func() {
 	})
 })
 
+var _ = Describe("Data Processor scratch space", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "scratch")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	table.DescribeTable("should report the scratch space required and available when it runs out", func(scratchSize, expectedRequired int64) {
+		mdp := &NoSpaceDataProvider{
+			MockDataProvider: MockDataProvider{infoResponse: ProcessingPhaseTransferScratch},
+			written:          1000,
+			scratchSize:      scratchSize,
+		}
+		dp := NewDataProcessor(mdp, "dest", "dataDir", tmpDir, "1G")
+		replaceAvailableSpaceFunc(func(string) int64 {
+			return 1024
+		}, func() {
+			err := dp.ProcessData()
+			Expect(err).To(HaveOccurred())
+			exhausted, ok := err.(*ScratchSpaceExhaustedError)
+			Expect(ok).To(BeTrue())
+			Expect(exhausted.Required).To(Equal(expectedRequired))
+			Expect(exhausted.Available).To(Equal(int64(1024)))
+		})
+	},
+		table.Entry("with the size of the data if the source knows it", int64(4096), int64(4096)),
+		table.Entry("with the data written if the source does not know the size", int64(0), int64(1000)),
+	)
+
+	table.DescribeTable("should only report the scratch space exhausted if it filled up while processing", func(scratchLeft int64, expectExhausted bool) {
+		mdp := &NoSpaceProcessDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferScratch,
+				transferResponse: ProcessingPhaseProcess,
+			},
+		}
+		dp := NewDataProcessor(mdp, "dest", "dataDir", tmpDir, "1G")
+		available := int64(1024 * 1024 * 1024)
+		mdp.fill = func() {
+			available = scratchLeft
+		}
+		replaceAvailableSpaceFunc(func(string) int64 {
+			return available
+		}, func() {
+			err := dp.ProcessData()
+			Expect(err).To(HaveOccurred())
+			_, ok := err.(*ScratchSpaceExhaustedError)
+			Expect(ok).To(Equal(expectExhausted))
+		})
+	},
+		table.Entry("if the scratch space is full", int64(4096), true),
+		table.Entry("not if the target is full", int64(512*1024*1024), false),
+	)
+})
+
 var _ = Describe("Convert", func() {
 	It("Should successfully convert and return resize", func() {
 		url, err := url.Parse("http://fakeurl-notreal.fake")
@@ -324,6 +391,141 @@ var _ = Describe("Convert", func() {
 	})
 })
 
+var _ = Describe("Converted size", func() {
+	imageURL := &url.URL{Path: "/scratch/disk.img"}
+
+	table.DescribeTable("Should compare the converted image to the virtual size of the source", func(convertedSize, virtualSize int64, wantErr bool) {
+		dp := NewDataProcessor(&SizedDataProvider{MockDataProvider: MockDataProvider{url: imageURL}, size: virtualSize}, "dest", "dataDir", "scratchDataDir", "1G")
+		qemu := &fakeQEMUOperations{ret4: fakeInfoOpRetVal{&image.ImgInfo{Format: "raw", VirtualSize: convertedSize}, nil}}
+		replaceQEMUOperations(qemu, func() {
+			nextPhase, err := dp.convert(imageURL)
+			if !wantErr {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(nextPhase).To(Equal(ProcessingPhaseResize))
+				return
+			}
+			Expect(nextPhase).To(Equal(ProcessingPhaseError))
+			Expect(err).To(Equal(&ByteCountError{Counted: "converted image has", Actual: convertedSize, Expected: virtualSize}))
+		})
+	},
+		table.Entry("succeed when the sizes match", int64(1024), int64(1024), false),
+		table.Entry("succeed when the target is larger", int64(2048), int64(1024), false),
+		table.Entry("succeed when the virtual size is not known", int64(1024), int64(0), false),
+		table.Entry("fail when the converted image is smaller", int64(512), int64(1024), true),
+	)
+
+	It("Should not compare the sizes without verification", func() {
+		dp := NewDataProcessor(&SizedDataProvider{MockDataProvider: MockDataProvider{url: imageURL}, size: 1024}, "dest", "dataDir", "scratchDataDir", "1G")
+		dp.SetVerification(cdiv1.DataVolumeVerificationNone)
+		qemu := &fakeQEMUOperations{ret4: fakeInfoOpRetVal{&image.ImgInfo{Format: "raw", VirtualSize: 512}, nil}}
+		replaceQEMUOperations(qemu, func() {
+			nextPhase, err := dp.convert(imageURL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextPhase).To(Equal(ProcessingPhaseResize))
+		})
+	})
+})
+
+var _ = Describe("Validate", func() {
+	imageURL := &url.URL{Path: "/scratch/disk.img"}
+
+	It("Should fail permanently, telling the minimum size, when the image is larger than the volume", func() {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		qemu := &fakeQEMUOperations{e5: &image.ImageTooLargeError{VirtualSize: 1024 * 1024 * 1024, AvailableSize: 512 * 1024 * 1024}}
+		replaceQEMUOperations(qemu, func() {
+			replaceAvailableSpaceBlockFunc(func(string) int64 { return int64(-1) }, func() {
+				err := dp.validate(imageURL)
+				permanentErr, ok := errors.Cause(err).(*util.PermanentError)
+				Expect(ok).To(BeTrue())
+				Expect(permanentErr.Reason).To(Equal(util.PermanentErrorTargetTooSmall))
+				minimum := resource.NewQuantity(util.MinimumTargetSize(1024*1024*1024, true), resource.BinarySI)
+				Expect(err.Error()).To(ContainSubstring("virtual size of 1Gi, 512Mi are available"))
+				Expect(err.Error()).To(ContainSubstring("at least " + minimum.String()))
+			})
+		})
+	})
+
+	It("Should not fail permanently on other validation errors", func() {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		qemu := &fakeQEMUOperations{e5: errors.New("backing file not supported")}
+		replaceQEMUOperations(qemu, func() {
+			err := dp.validate(imageURL)
+			Expect(err).To(HaveOccurred())
+			_, ok := errors.Cause(err).(*util.PermanentError)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("Image check", func() {
+	qcow2Info := fakeInfoOpRetVal{&image.ImgInfo{Format: "qcow2", VirtualSize: 1024}, nil}
+	imageURL := &url.URL{Path: "/scratch/disk.img"}
+
+	table.DescribeTable("Should convert a checked image", func(imageCheck cdiv1.DataVolumeImageCheck, check image.ImgCheck, expectedResult string) {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		dp.SetImageCheck(imageCheck)
+		qemu := &fakeQEMUOperations{ret4: qcow2Info, check: &check}
+		replaceQEMUOperations(qemu, func() {
+			nextPhase, err := dp.convert(imageURL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextPhase).To(Equal(ProcessingPhaseResize))
+		})
+		Expect(qemu.repairedLeaks).To(Equal(imageCheck == cdiv1.DataVolumeImageCheckRepairLeaks))
+		Expect(dp.ImageCheckResult()).To(Equal(expectedResult))
+	},
+		table.Entry("without errors", cdiv1.DataVolumeImageCheckOnly, image.ImgCheck{}, "no errors found"),
+		table.Entry("with leaks", cdiv1.DataVolumeImageCheckOnly, image.ImgCheck{Leaks: 3}, "3 leaked clusters found"),
+		table.Entry("with repaired leaks", cdiv1.DataVolumeImageCheckRepairLeaks, image.ImgCheck{LeaksFixed: 3}, "3 leaked clusters repaired"),
+		table.Entry("with leaks left after the repair", cdiv1.DataVolumeImageCheckRepairLeaks, image.ImgCheck{LeaksFixed: 3, Leaks: 1}, "3 leaked clusters repaired, 1 left"),
+	)
+
+	It("Should fail permanently on a corrupt image", func() {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		dp.SetImageCheck(cdiv1.DataVolumeImageCheckOnly)
+		qemu := &fakeQEMUOperations{e2: errors.New("a corrupt image should not be converted"), ret4: qcow2Info, check: &image.ImgCheck{Corruptions: 2}}
+		replaceQEMUOperations(qemu, func() {
+			nextPhase, err := dp.convert(imageURL)
+			Expect(nextPhase).To(Equal(ProcessingPhaseError))
+			permanentErr, ok := errors.Cause(err).(*util.PermanentError)
+			Expect(ok).To(BeTrue())
+			Expect(permanentErr.Reason).To(Equal(util.PermanentErrorImageCorrupt))
+		})
+	})
+
+	It("Should fail if the check could not complete", func() {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		dp.SetImageCheck(cdiv1.DataVolumeImageCheckOnly)
+		qemu := &fakeQEMUOperations{ret4: qcow2Info, check: &image.ImgCheck{CheckErrors: 1}}
+		replaceQEMUOperations(qemu, func() {
+			_, err := dp.convert(imageURL)
+			Expect(err).To(HaveOccurred())
+			_, ok := errors.Cause(err).(*util.PermanentError)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	It("Should not check a raw image", func() {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		dp.SetImageCheck(cdiv1.DataVolumeImageCheckOnly)
+		qemu := &fakeQEMUOperations{ret4: fakeInfoOpRetVal{&image.ImgInfo{Format: "raw"}, nil}, e6: errors.New("a raw image should not be checked")}
+		replaceQEMUOperations(qemu, func() {
+			_, err := dp.convert(imageURL)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		Expect(dp.ImageCheckResult()).To(Equal("not checked, a raw image has no metadata to check"))
+	})
+
+	It("Should not check the image unless asked to", func() {
+		dp := NewDataProcessor(&MockDataProvider{url: imageURL}, "dest", "dataDir", "scratchDataDir", "1G")
+		qemu := &fakeQEMUOperations{ret4: qcow2Info, e6: errors.New("the image should not be checked")}
+		replaceQEMUOperations(qemu, func() {
+			_, err := dp.convert(imageURL)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		Expect(dp.ImageCheckResult()).To(BeEmpty())
+	})
+})
+
 var _ = Describe("Resize", func() {
 	It("Should not resize and return complete, when requestedSize is blank", func() {
 		url, err := url.Parse("http://fakeurl-notreal.fake")
@@ -447,7 +649,7 @@ func replaceQEMUOperations(replacement image.QEMUOperations, f func()) {
 }
 
 func NewFakeQEMUOperations(e2, e3 error, ret4 fakeInfoOpRetVal, e5 error, e6 error, targetResize *resource.Quantity) image.QEMUOperations {
-	return &fakeQEMUOperations{e2, e3, ret4, e5, e6, targetResize}
+	return &fakeQEMUOperations{e2: e2, e3: e3, ret4: ret4, e5: e5, e6: e6, resizeQuantity: targetResize}
 }
 
 func (o *fakeQEMUOperations) ConvertToRawStream(*url.URL, string) error {
@@ -473,6 +675,18 @@ func (o *fakeQEMUOperations) CreateBlankImage(dest string, size resource.Quantit
 	return o.e6
 }
 
+func (o *fakeQEMUOperations) Rebase(image, backingFile, backingFormat string) error {
+	return o.e6
+}
+
+func (o *fakeQEMUOperations) Check(url *url.URL, repairLeaks bool) (*image.ImgCheck, error) {
+	o.repairedLeaks = repairLeaks
+	if o.check == nil {
+		return nil, o.e6
+	}
+	return o.check, nil
+}
+
 func NewQEMUAllErrors() image.QEMUOperations {
 	err := errors.New("qemu should not be called from this test override with replaceQEMUOperations")
 	return NewFakeQEMUOperations(err, err, fakeInfoOpRetVal{nil, err}, err, err, nil)
@@ -495,3 +709,115 @@ func replaceAvailableSpaceFunc(replacement func(string) int64, f func()) {
 	}()
 	f()
 }
+
+// SizedDataProvider knows the virtual size of the image before the conversion.
+type SizedDataProvider struct {
+	MockDataProvider
+	size int64
+}
+
+// ConvertedSize returns the virtual size of the image.
+func (m *SizedDataProvider) ConvertedSize() int64 {
+	return m.size
+}
+
+// NoSpaceDataProvider fills the scratch space while transferring to it.
+type NoSpaceDataProvider struct {
+	MockDataProvider
+	written     int
+	scratchSize int64
+}
+
+// Transfer writes some data to path, then fails as if the file system is full.
+func (m *NoSpaceDataProvider) Transfer(path string) (ProcessingPhase, error) {
+	m.MockDataProvider.Transfer(path)
+	fileName := filepath.Join(path, "disk.img")
+	if err := ioutil.WriteFile(fileName, make([]byte, m.written), 0644); err != nil {
+		return ProcessingPhaseError, err
+	}
+	return ProcessingPhaseError, errors.Wrap(&os.PathError{Op: "write", Path: fileName, Err: syscall.ENOSPC}, "unable to write to file")
+}
+
+// ScratchSize returns the size of the data.
+func (m *NoSpaceDataProvider) ScratchSize() int64 {
+	return m.scratchSize
+}
+
+// NoSpaceProcessDataProvider runs out of space while processing the data.
+type NoSpaceProcessDataProvider struct {
+	MockDataProvider
+	fill func()
+}
+
+// Process calls fill, then fails as if the file system is full.
+func (m *NoSpaceProcessDataProvider) Process() (ProcessingPhase, error) {
+	m.MockDataProvider.Process()
+	m.fill()
+	return ProcessingPhaseError, errors.Wrap(&os.PathError{Op: "write", Path: "disk.img", Err: syscall.ENOSPC}, "unable to write to file")
+}
+
+// ResumingDataProvider resumes the transfer of an earlier attempt in resumePhase, if it is set.
+type ResumingDataProvider struct {
+	MockDataProvider
+	resumePhase ProcessingPhase
+}
+
+// ResumeTransfer returns resumePhase, true if it is set.
+func (m *ResumingDataProvider) ResumeTransfer(dataFile, dataDir string) (ProcessingPhase, bool) {
+	return m.resumePhase, m.resumePhase != ""
+}
+
+var _ = Describe("Data Processor keep data dir", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "keep-data-dir")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "kernel"), []byte("kernel"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "disk.img"), []byte("stale"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	table.DescribeTable("should clean up the data dir before writing", func(keepDataDir, expectKept bool) {
+		mdp := &writingDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			},
+			data: []byte("image"),
+		}
+		dp := NewDataProcessor(mdp, filepath.Join(tmpDir, "disk.img"), tmpDir, filepath.Join(tmpDir, "none"), "")
+		dp.SetKeepDataDir(keepDataDir)
+		Expect(dp.ProcessData()).To(Succeed())
+		written, err := ioutil.ReadFile(filepath.Join(tmpDir, "disk.img"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written).To(Equal([]byte("image")))
+		_, err = os.Stat(filepath.Join(tmpDir, "kernel"))
+		Expect(err == nil).To(Equal(expectKept))
+	},
+		table.Entry("by removing everything", false, false),
+		table.Entry("by only replacing the data file if the other images are kept", true, true),
+	)
+
+	table.DescribeTable("should resume the transfer of an earlier attempt", func(resumePhase ProcessingPhase, expectedPhases []ProcessingPhase, expectKept bool) {
+		mdp := &ResumingDataProvider{
+			MockDataProvider: MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			},
+			resumePhase: resumePhase,
+		}
+		dp := NewDataProcessor(mdp, filepath.Join(tmpDir, "disk.img"), tmpDir, filepath.Join(tmpDir, "none"), "")
+		Expect(dp.ProcessData()).To(Succeed())
+		Expect(mdp.calledPhases).To(Equal(expectedPhases))
+		_, err := os.Stat(filepath.Join(tmpDir, "disk.img"))
+		Expect(err == nil).To(Equal(expectKept))
+	},
+		table.Entry("keeping the data transferred so far", ProcessingPhaseTransferDataFile, []ProcessingPhase{ProcessingPhaseTransferDataFile}, true),
+		table.Entry("unless there is no checkpoint", ProcessingPhase(""), []ProcessingPhase{ProcessingPhaseInfo, ProcessingPhaseTransferDataFile}, false),
+	)
+})
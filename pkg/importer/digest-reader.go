@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+// DigestReader computes the digest of the data read through it while it is streamed, to verify the data against the
+// digest the source is pinned to
+type DigestReader struct {
+	io.ReadCloser
+	hash   hash.Hash
+	digest string
+	sum    []byte
+}
+
+// NewDigestReader returns a reader of readCloser whose data is verified against digest, in the form sha256:hex or
+// sha512:hex
+func NewDigestReader(readCloser io.ReadCloser, digest string) (*DigestReader, error) {
+	h, sum, err := checksum.ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return &DigestReader{
+		ReadCloser: readCloser,
+		hash:       h,
+		digest:     digest,
+		sum:        sum,
+	}, nil
+}
+
+func (r *DigestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	return n, err
+}
+
+// hashFile adds the first size bytes of the file at path to the digest, the data of a resumed transfer that was
+// written before it was interrupted
+func (r *DigestReader) hashFile(path string, size uint64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", path)
+	}
+	defer f.Close()
+	if n, err := io.Copy(r.hash, io.LimitReader(f, int64(size))); err != nil || uint64(n) != size {
+		return errors.Errorf("could not read the first %d bytes of %s: %v", size, path, err)
+	}
+	return nil
+}
+
+// Verify reads the data that was not read yet, such as the padding after an archive, and returns a permanent error
+// with the reason ChecksumMismatch if the data does not match the digest
+func (r *DigestReader) Verify() error {
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return errors.Wrap(err, "unable to read the rest of the source")
+	}
+	if sum := r.hash.Sum(nil); !bytes.Equal(sum, r.sum) {
+		return util.NewPermanentError(util.PermanentErrorChecksumMismatch,
+			errors.Errorf("source has digest %s:%s instead of %s, the published image changed", strings.SplitN(r.digest, ":", 2)[0], hex.EncodeToString(sum), r.digest))
+	}
+	klog.V(1).Infof("Source matches digest %s", r.digest)
+	return nil
+}
+
+// verifyDigest verifies the data of the source read through reader if it is a DigestReader
+func verifyDigest(reader io.Reader) error {
+	if r, ok := reader.(*DigestReader); ok {
+		return r.Verify()
+	}
+	return nil
+}
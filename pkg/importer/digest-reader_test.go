@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// sha256Digest returns the sha256 digest of data
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+var _ = Describe("Digest reader", func() {
+	newReader := func(data, digest string) *DigestReader {
+		reader, err := NewDigestReader(ioutil.NopCloser(bytes.NewBufferString(data)), digest)
+		Expect(err).ToNot(HaveOccurred())
+		return reader
+	}
+
+	It("Should verify data that matches its sha256 digest", func() {
+		reader := newReader("image", sha256Digest([]byte("image")))
+		data, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("image"))
+		Expect(reader.Verify()).To(Succeed())
+	})
+
+	It("Should verify data that matches its sha512 digest", func() {
+		sum := sha512.Sum512([]byte("image"))
+		reader := newReader("image", "sha512:"+hex.EncodeToString(sum[:]))
+		Expect(reader.Verify()).To(Succeed())
+	})
+
+	It("Should include the data that was not read in the digest", func() {
+		reader := newReader("image and padding", sha256Digest([]byte("image and padding")))
+		_, err := reader.Read(make([]byte, 5))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Verify()).To(Succeed())
+	})
+
+	It("Should fail permanently when the data does not match its digest", func() {
+		reader := newReader("changed image", sha256Digest([]byte("image")))
+		err := reader.Verify()
+		Expect(err).To(HaveOccurred())
+		permanentErr, ok := util.AsPermanentError(err)
+		Expect(ok).To(BeTrue())
+		Expect(permanentErr.Reason).To(Equal(util.PermanentErrorChecksumMismatch))
+		Expect(err.Error()).To(ContainSubstring(sha256Digest([]byte("changed image"))))
+	})
+
+	It("Should reject an invalid digest", func() {
+		_, err := NewDigestReader(ioutil.NopCloser(bytes.NewBufferString("image")), "md5:1234")
+		Expect(err).To(HaveOccurred())
+	})
+})
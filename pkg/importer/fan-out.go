@@ -0,0 +1,146 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+)
+
+// NewFanOutClients returns the clients the imported image is streamed to the upload servers of the fan-out targets
+// with, one for each of the PEM encoded client certificates and keys, in their order. They trust the server CA bundle.
+func NewFanOutClients(clientCerts, clientKeys, serverCA []byte) ([]*http.Client, error) {
+	certs, keys := splitPEM(clientCerts), splitPEM(clientKeys)
+	if len(certs) != len(keys) {
+		return nil, errors.Errorf("%d fan-out client certificates for %d keys", len(certs), len(keys))
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(serverCA) {
+		return nil, errors.New("unable to load the fan-out server CA bundle")
+	}
+	var clients []*http.Client
+	for i := range certs {
+		keyPair, err := tls.X509KeyPair(certs[i], keys[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load the fan-out client certificate")
+		}
+		tlsConfig := &tls.Config{
+			Certificates: []tls.Certificate{keyPair},
+			RootCAs:      caCertPool,
+		}
+		clients = append(clients, &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}})
+	}
+	return clients, nil
+}
+
+// splitPEM returns the PEM blocks of data, each encoded on its own
+func splitPEM(data []byte) [][]byte {
+	var blocks [][]byte
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			return blocks
+		}
+		blocks = append(blocks, pem.EncodeToMemory(block))
+	}
+}
+
+// FanOut streams the image at path, a file or a block device, to the upload servers at urls at once, each with the
+// client of the same index. The image is read a single time, a slow upload server slows down the others. If one of
+// them fails, all of them fail.
+func FanOut(clients []*http.Client, path string, urls []string) error {
+	if len(clients) != len(urls) {
+		return errors.Errorf("%d fan-out clients for %d upload servers", len(clients), len(urls))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "unable to open the image")
+	}
+	defer f.Close()
+	// Seeking works for block devices, whose size Stat does not tell
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrap(err, "unable to size the image")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "unable to rewind the image")
+	}
+
+	klog.V(1).Infof("Streaming %d bytes to %d fan-out targets", size, len(urls))
+	writers := make([]io.Writer, len(urls))
+	pipes := make([]*io.PipeWriter, len(urls))
+	results := make(chan error, len(urls))
+	for i, url := range urls {
+		pr, pw := io.Pipe()
+		writers[i], pipes[i] = pw, pw
+		go func(client *http.Client, url string, body *io.PipeReader) {
+			err := postImage(client, url, body, size)
+			// Fail the writes to an upload server that gave up, which stops the stream to the others as well
+			body.CloseWithError(errors.Errorf("fan-out to %s ended", url))
+			results <- err
+		}(clients[i], url, pr)
+	}
+	_, copyErr := io.Copy(io.MultiWriter(writers...), f)
+	for _, pw := range pipes {
+		pw.CloseWithError(copyErr)
+	}
+
+	var failures []string
+	for range urls {
+		if err := <-results; err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("fan-out failed: %s", strings.Join(failures, "; "))
+	}
+	if copyErr != nil {
+		return errors.Wrap(copyErr, "unable to read the image")
+	}
+	klog.V(1).Infof("Streamed the image to %d fan-out targets", len(urls))
+	return nil
+}
+
+// postImage posts the image read from body, of size bytes, to the upload server at url
+func postImage(client *http.Client, url string, body io.Reader, size int64) error {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	response, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "unable to post to %s", url)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(response.Body)
+		return errors.Errorf("%s answered with status code %d: %s", url, response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
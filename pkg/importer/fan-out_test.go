@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/triple"
+)
+
+// pemBlockBytes returns the bytes of the first PEM block of data
+func pemBlockBytes(data []byte) []byte {
+	block, _ := pem.Decode(data)
+	return block.Bytes
+}
+
+var _ = Describe("Fan-out", func() {
+	var (
+		tmpDir string
+		image  string
+		data   []byte
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "fan-out")
+		Expect(err).ToNot(HaveOccurred())
+		image = filepath.Join(tmpDir, "disk.img")
+		data = bytes.Repeat([]byte("fan-out"), 64*1024)
+		Expect(ioutil.WriteFile(image, data, 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	// newUploadServer returns an upload server that records what was posted to it with the right length, or answers
+	// with status
+	newUploadServer := func(status int, received *[]byte, lock *sync.Mutex) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				w.Write([]byte("destination not writable"))
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.ContentLength != int64(len(body)) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			lock.Lock()
+			*received = body
+			lock.Unlock()
+		}))
+	}
+
+	It("Should stream the image to all upload servers", func() {
+		var lock sync.Mutex
+		received := make([][]byte, 3)
+		var urls []string
+		var clients []*http.Client
+		for i := range received {
+			server := newUploadServer(http.StatusOK, &received[i], &lock)
+			defer server.Close()
+			urls = append(urls, server.URL)
+			clients = append(clients, http.DefaultClient)
+		}
+		Expect(FanOut(clients, image, urls)).To(Succeed())
+		for _, body := range received {
+			Expect(body).To(Equal(data))
+		}
+	})
+
+	It("Should fail if an upload server fails", func() {
+		var lock sync.Mutex
+		var received []byte
+		ok := newUploadServer(http.StatusOK, &received, &lock)
+		defer ok.Close()
+		failing := newUploadServer(http.StatusServiceUnavailable, &received, &lock)
+		defer failing.Close()
+		err := FanOut([]*http.Client{http.DefaultClient, http.DefaultClient}, image, []string{ok.URL, failing.URL})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("status code 503: destination not writable"))
+	})
+
+	It("Should fail without the image", func() {
+		Expect(FanOut([]*http.Client{http.DefaultClient}, filepath.Join(tmpDir, "missing.img"), []string{"http://localhost"})).ToNot(Succeed())
+	})
+
+	It("Should fail without a client for each upload server", func() {
+		Expect(FanOut([]*http.Client{http.DefaultClient}, image, []string{"http://localhost", "http://localhost"})).ToNot(Succeed())
+	})
+
+	It("Should create a client for each client certificate", func() {
+		ca, err := triple.NewCA("upload-server.cdi.kubevirt.io")
+		Expect(err).ToNot(HaveOccurred())
+		var clientCerts, clientKeys []byte
+		for _, name := range []string{"fan-out/default/vm-1-default/vm-2", "fan-out/default/vm-1-default/vm-3"} {
+			keyPair, err := triple.NewClientKeyPair(ca, name, nil)
+			Expect(err).ToNot(HaveOccurred())
+			clientCerts = append(clientCerts, cert.EncodeCertPEM(keyPair.Cert)...)
+			clientKeys = append(clientKeys, cert.EncodePrivateKeyPEM(keyPair.Key)...)
+		}
+		serverCA := cert.EncodeCertPEM(ca.Cert)
+
+		clients, err := NewFanOutClients(clientCerts, clientKeys, serverCA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clients).To(HaveLen(2))
+		for i, client := range clients {
+			certificates := client.Transport.(*http.Transport).TLSClientConfig.Certificates
+			Expect(certificates).To(HaveLen(1))
+			Expect(certificates[0].Certificate[0]).To(Equal(pemBlockBytes(splitPEM(clientCerts)[i])))
+		}
+
+		_, err = NewFanOutClients(clientCerts, splitPEM(clientKeys)[0], serverCA)
+		Expect(err).To(HaveOccurred())
+	})
+})
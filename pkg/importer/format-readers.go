@@ -71,6 +71,9 @@ type FormatReaders struct {
 	buf            []byte // holds file headers
 	Convert        bool
 	Archived       bool
+	XVA            bool
+	Size           int64 // size of the data read from the top reader if the format records it, 0 otherwise
+	VirtualSize    int64 // virtual size of a qcow2 image from its header, 0 for other formats
 	progressReader *prometheusutil.ProgressReader
 }
 
@@ -79,6 +82,7 @@ const (
 	rdrMulti
 	rdrXz
 	rdrStream
+	rdrXva
 )
 
 // map scheme and format to rdrType
@@ -86,6 +90,7 @@ var rdrTypM = map[string]int{
 	"gz":     rdrGz,
 	"xz":     rdrXz,
 	"stream": rdrStream,
+	"tar":    rdrXva,
 }
 
 // NewFormatReaders creates a new instance of FormatReaders using the input stream and content type passed in.
@@ -94,6 +99,7 @@ func NewFormatReaders(stream io.ReadCloser, total uint64) (*FormatReaders, error
 	readers := &FormatReaders{
 		buf: make([]byte, image.MaxExpectedHdrSize),
 	}
+	stream = prometheusutil.NewHeartbeatReader(newBandwidthLimitedReader(stream))
 	if total > uint64(0) {
 		readers.progressReader = prometheusutil.NewProgressReader(stream, total, progress, ownerUID)
 		err = readers.constructReaders(readers.progressReader)
@@ -103,6 +109,20 @@ func NewFormatReaders(stream io.ReadCloser, total uint64) (*FormatReaders, error
 	return readers, err
 }
 
+// newResumedFormatReaders creates the readers of a stream whose data starts at offset, the format of the data was
+// detected by the transfer that is resumed
+func newResumedFormatReaders(stream io.ReadCloser, total, offset uint64) *FormatReaders {
+	readers := &FormatReaders{}
+	stream = prometheusutil.NewHeartbeatReader(newBandwidthLimitedReader(stream))
+	if total > uint64(0) {
+		readers.progressReader = prometheusutil.NewProgressReader(stream, total, progress, ownerUID)
+		readers.progressReader.Current = offset
+		stream = readers.progressReader
+	}
+	readers.appendReader(rdrTypM["stream"], stream)
+	return readers
+}
+
 func (fr *FormatReaders) constructReaders(r io.ReadCloser) error {
 	fr.appendReader(rdrTypM["stream"], r)
 	knownHdrs := image.CopyKnownHdrs() // need local copy since keys are removed
@@ -117,12 +137,18 @@ func (fr *FormatReaders) constructReaders(r io.ReadCloser) error {
 		}
 		klog.V(2).Infof("found header of type %q\n", hdr.Format)
 		// create format-specific reader and append it to dataStream readers stack
-		fr.fileFormatSelector(hdr)
-		// exit loop if hdr is qcow2
-		if hdr.Format == "qcow2" {
+		if err = fr.fileFormatSelector(hdr); err != nil {
+			return err
+		}
+		// exit loop if hdr is qcow2 or decrypted LUKS, or the disk of a XVA export is read
+		if fr.Convert || fr.XVA {
 			break
 		}
 	}
+	if image.EncryptsTarget() {
+		// The image is encrypted while it is converted, a raw image too
+		fr.Convert = true
+	}
 
 	return nil
 }
@@ -154,8 +180,10 @@ func (fr *FormatReaders) TopReader() io.ReadCloser {
 }
 
 // Based on the passed in header, append the format-specific reader to the readers stack,
-// and update the receiver Size field. Note: a bool is set in the receiver for qcow2 files.
-func (fr *FormatReaders) fileFormatSelector(hdr *image.Header) {
+// and update the receiver Size field. Note: a bool is set in the receiver for qcow2 files, LUKS images with a
+// passphrase and XVA exports.
+// Only errors reading XVA exports are returned, their metadata has been consumed from the stream.
+func (fr *FormatReaders) fileFormatSelector(hdr *image.Header) error {
 	var r io.Reader
 	var err error
 	fFmt := hdr.Format
@@ -168,15 +196,34 @@ func (fr *FormatReaders) fileFormatSelector(hdr *image.Header) {
 	case "qcow2":
 		r, err = fr.qcow2NopReader(hdr)
 		fr.Convert = true
+	case "luks":
+		// Without a passphrase a LUKS image is data like any other, that is decrypted by the guest
+		if image.DecryptsSource() {
+			fr.Convert = true
+		}
 	case "xz":
 		r, err = fr.xzReader()
 		if err == nil {
 			fr.Archived = true
 		}
+	case "tar":
+		if !image.IsXVA(fr.buf) {
+			return nil
+		}
+		var xva *image.XVAReader
+		xva, err = fr.xvaReader()
+		if err != nil {
+			return err
+		}
+		// The disk is usually much larger than the export, which leaves out the chunks holding only zeros
+		fr.Size = xva.Size()
+		fr.XVA = true
+		r = xva
 	}
 	if err == nil && r != nil {
 		fr.appendReader(rdrTypM[fFmt], r)
 	}
+	return nil
 }
 
 // Return the gz reader and the size of the endpoint "through the eye" of the previous reader.
@@ -195,13 +242,14 @@ func (fr *FormatReaders) gzReader() (io.ReadCloser, error) {
 
 // Return the size of the endpoint "through the eye" of the previous reader. Note: there is no
 // qcow2 reader so nil is returned so that nothing is appended to the reader stack.
-// Note: size is stored at offset 24 in the qcow2 header.
+// Note: size is stored at offset 24 in the qcow2 header, it is recorded as the virtual size.
 func (fr *FormatReaders) qcow2NopReader(h *image.Header) (io.Reader, error) {
 	s := hex.EncodeToString(fr.buf[h.SizeOff : h.SizeOff+h.SizeLen])
-	_, err := strconv.ParseInt(s, 16, 64)
+	size, err := strconv.ParseInt(s, 16, 64)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to determine original qcow2 file size from %+v", s)
 	}
+	fr.VirtualSize = size
 	return nil, nil
 }
 
@@ -219,6 +267,11 @@ func (fr *FormatReaders) xzReader() (io.Reader, error) {
 	return xz, nil
 }
 
+// Return the reader of the boot disk of the XVA export read by the previous reader, converting it to raw.
+func (fr *FormatReaders) xvaReader() (*image.XVAReader, error) {
+	return image.NewXVAReader(fr.TopReader())
+}
+
 // Return the matching header, if one is found, from the passed-in map of known headers. After a
 // successful read append a multi-reader to the receiver's reader stack.
 // Note: .iso files are not detected here but rather in the Size() function.
@@ -1,8 +1,12 @@
 package importer
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -89,4 +93,63 @@ var _ = Describe("Format Readers", func() {
 		table.Entry("should append io.reader", rdrGz, stringRdr, 3, false),
 		table.Entry("should append io.Multireader", rdrMulti, stringRdr, 3, false),
 	)
+
+	It("should read the disk of a gzipped XVA export", func() {
+		metadata := `<value><struct><member><name>objects</name><value><array><data>
+<value><struct><member><name>class</name><value>VBD</value></member><member><name>id</name><value>Ref:1</value></member>
+<member><name>snapshot</name><value><struct><member><name>type</name><value>Disk</value></member><member><name>userdevice</name><value>0</value></member>
+<member><name>VDI</name><value>Ref:2</value></member></struct></value></member></struct></value>
+<value><struct><member><name>class</name><value>VDI</value></member><member><name>id</name><value>Ref:2</value></member>
+<member><name>snapshot</name><value><struct><member><name>virtual_size</name><value>2097152</value></member></struct></value></member></struct></value>
+</data></array></value></member></struct></value>`
+		chunk := bytes.Repeat([]byte{'a'}, 1<<20)
+		buf := &bytes.Buffer{}
+		gz := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gz)
+		for _, file := range []struct {
+			name    string
+			content []byte
+		}{{"ova.xml", []byte(metadata)}, {"Ref:2/00000001", chunk}} {
+			Expect(tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content))})).To(Succeed())
+			_, err := tw.Write(file.content)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+
+		var err error
+		fr, err = NewFormatReaders(ioutil.NopCloser(buf), uint64(0))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fr.XVA).To(BeTrue())
+		Expect(fr.Size).To(Equal(int64(2097152)))
+		Expect(fr.Convert).To(BeFalse())
+		disk, err := ioutil.ReadAll(fr.TopReader())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(disk).To(Equal(append(make([]byte, 1<<20), chunk...)))
+	})
+
+	It("should only convert a LUKS image with a source passphrase", func() {
+		defer image.SetSourceKeyFile("")
+		luks := append([]byte("LUKS\xba\xbe"), make([]byte, 1<<20)...)
+
+		var err error
+		fr, err = NewFormatReaders(ioutil.NopCloser(bytes.NewReader(luks)), uint64(0))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fr.Convert).To(BeFalse())
+
+		image.SetSourceKeyFile("/source-key/passphrase")
+		fr, err = NewFormatReaders(ioutil.NopCloser(bytes.NewReader(luks)), uint64(0))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fr.Convert).To(BeTrue())
+	})
+
+	It("should convert a raw image to encrypt it with a target passphrase", func() {
+		defer image.SetTargetKeyFile("")
+		image.SetTargetKeyFile("/target-key/passphrase")
+
+		var err error
+		fr, err = NewFormatReaders(ioutil.NopCloser(bytes.NewReader(make([]byte, 1<<20))), uint64(0))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fr.Convert).To(BeTrue())
+	})
 })
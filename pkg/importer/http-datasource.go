@@ -25,9 +25,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -45,12 +47,12 @@ const (
 
 // HTTPDataSource is the data provider for http(s) endpoints.
 // Sequence of phases:
-// 1a. Info -> Convert (In Info phase the format readers are configured), if the source Reader image is not archived, and no custom CA is used, and can be converted by QEMU-IMG (RAW/QCOW2)
+// 1a. Info -> Convert (In Info phase the format readers are configured), if the source Reader image is not archived, and no custom CA is used, and can be converted by QEMU-IMG (RAW/QCOW2), and backing files are not allowed
 // 1b. Info -> TransferArchive if the content type is archive
 // 1c. Info -> Transfer in all other cases.
 // 2a. Transfer -> Process if content type is kube virt
 // 2b. Transfer -> Complete if content type is archive (Transfer is called with the target instead of the scratch space). Non block PVCs only.
-// 3. Process -> Convert
+// 3. Process -> Convert, after fetching the backing files of the image
 type HTTPDataSource struct {
 	httpReader io.ReadCloser
 	ctx        context.Context
@@ -68,16 +70,29 @@ type HTTPDataSource struct {
 	customCA bool
 	// the content length reported by the http server.
 	contentLength uint64
+	// the directory containing the custom CA, also used to fetch backing files
+	certDir string
+	// the url prefixes backing files may be fetched from
+	backingFileURLs []string
+	// the digest the data is verified against, the data is then streamed through the importer
+	digest string
+	// the ETag and Last-Modified validators of the response the data is read from
+	etag, lastModified string
+	// the checkpoint the transfer resumed at, nil if it started at the beginning of the data
+	resumed *transferCheckpoint
+	// countingReader counts the data read from the response, it reads the response of a resumed transfer once the
+	// transfer is resumed
+	countingReader *util.CountingReader
 }
 
 // NewHTTPDataSource creates a new instance of the http data provider.
-func NewHTTPDataSource(endpoint, accessKey, secKey, certDir string, contentType cdiv1.DataVolumeContentType) (*HTTPDataSource, error) {
+func NewHTTPDataSource(endpoint, accessKey, secKey, certDir string, contentType cdiv1.DataVolumeContentType, backingFileURLs []string, digest string) (*HTTPDataSource, error) {
 	ep, err := ParseEndpoint(endpoint)
 	if err != nil {
 		return nil, errors.Wrapf(err, fmt.Sprintf("unable to parse endpoint %q", endpoint))
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-	httpReader, contentLength, err := createHTTPReader(ctx, ep, accessKey, secKey, certDir)
+	httpReader, contentLength, header, err := createHTTPReader(ctx, ep, accessKey, secKey, certDir)
 	if err != nil {
 		cancel()
 		return nil, err
@@ -86,21 +101,46 @@ func NewHTTPDataSource(endpoint, accessKey, secKey, certDir string, contentType
 	if accessKey != "" && secKey != "" {
 		ep.User = url.UserPassword(accessKey, secKey)
 	}
-	httpSource := &HTTPDataSource{
-		ctx:           ctx,
-		cancel:        cancel,
-		httpReader:    httpReader,
-		contentType:   contentType,
-		endpoint:      ep,
-		customCA:      certDir != "",
-		contentLength: contentLength,
-	}
 	// We know this is a counting reader, so no need to check.
 	countingReader := httpReader.(*util.CountingReader)
+	if digest != "" {
+		httpReader, err = NewDigestReader(httpReader, digest)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	httpSource := &HTTPDataSource{
+		ctx:             ctx,
+		cancel:          cancel,
+		httpReader:      httpReader,
+		countingReader:  countingReader,
+		contentType:     contentType,
+		endpoint:        ep,
+		customCA:        certDir != "",
+		contentLength:   contentLength,
+		certDir:         certDir,
+		backingFileURLs: backingFileURLs,
+		digest:          digest,
+		etag:            header.Get("ETag"),
+		lastModified:    header.Get("Last-Modified"),
+	}
 	go httpSource.pollProgress(countingReader, 10*time.Minute, time.Second)
 	return httpSource, nil
 }
 
+// ScratchSize returns the size of the data read from the stream if it is known, otherwise the content length of the
+// data, unless it is compressed and expands in scratch space.
+func (hs *HTTPDataSource) ScratchSize() int64 {
+	if hs.readers != nil && hs.readers.Size > 0 {
+		return hs.readers.Size
+	}
+	if hs.readers == nil || hs.readers.Archived {
+		return 0
+	}
+	return int64(hs.contentLength)
+}
+
 // Info is called to get initial information about the data.
 func (hs *HTTPDataSource) Info() (ProcessingPhase, error) {
 	var err error
@@ -114,7 +154,7 @@ func (hs *HTTPDataSource) Info() (ProcessingPhase, error) {
 	}
 	// The readers now contain all the information needed to determine if we can stream directly or if we need scratch space to download
 	// the file to, before converting.
-	if !hs.readers.Archived && !hs.customCA && hs.readers.Convert {
+	if !hs.readers.Archived && !hs.readers.XVA && !hs.customCA && hs.readers.Convert && len(hs.backingFileURLs) == 0 && hs.digest == "" && bandwidthLimit == nil {
 		// We can pass straight to conversion from the endpoint. No scratch required.
 		hs.url = hs.endpoint
 		return ProcessingPhaseConvert, nil
@@ -137,13 +177,30 @@ func (hs *HTTPDataSource) Transfer(path string) (ProcessingPhase, error) {
 		if err != nil {
 			return ProcessingPhaseError, err
 		}
+		if err := verifyDigest(hs.httpReader); err != nil {
+			return ProcessingPhaseError, err
+		}
 		// If we successfully wrote to the file, then the parse will succeed.
 		hs.url, _ = url.Parse(file)
 		return ProcessingPhaseProcess, nil
 	} else if hs.contentType == cdiv1.DataVolumeArchive {
-		if err := util.UnArchiveTar(hs.readers.TopReader(), path); err != nil {
+		var reader io.Reader = hs.readers.TopReader()
+		var checkpoints *tarCheckpointReader
+		if cp := hs.archiveCheckpoint(); cp != nil {
+			checkpoints = newTarCheckpointReader(reader, path, cp, newCheckpointStore(path))
+			reader = checkpoints
+		}
+		if err := util.UnArchiveTar(reader, path); err != nil {
 			return ProcessingPhaseError, errors.Wrap(err, "unable to untar files from endpoint")
 		}
+		if err := verifyDigest(hs.httpReader); err != nil {
+			return ProcessingPhaseError, err
+		}
+		if checkpoints != nil {
+			if err := checkpoints.store.clear(); err != nil {
+				return ProcessingPhaseError, err
+			}
+		}
 		hs.url = nil
 		return ProcessingPhaseComplete, nil
 	}
@@ -153,18 +210,172 @@ func (hs *HTTPDataSource) Transfer(path string) (ProcessingPhase, error) {
 // TransferFile is called to transfer the data from the source to the passed in file.
 func (hs *HTTPDataSource) TransferFile(fileName string) (ProcessingPhase, error) {
 	hs.readers.StartProgressUpdate()
-	err := util.StreamDataToFile(hs.readers.TopReader(), fileName)
+	var err error
+	if cp := hs.rawCheckpoint(); cp != nil {
+		err = streamWithCheckpoints(hs.readers.TopReader(), fileName, cp, newCheckpointStore(fileName))
+	} else {
+		err = util.StreamDataToFile(hs.readers.TopReader(), fileName)
+	}
 	if err != nil {
 		return ProcessingPhaseError, err
 	}
+	if err := verifyDigest(hs.httpReader); err != nil {
+		return ProcessingPhaseError, err
+	}
 	return ProcessingPhaseResize, nil
 }
 
+// ResumeTransfer resumes the transfer to dataFile, or to dataDir for an archive, at the checkpoint an earlier attempt
+// saved, and returns the phase the transfer continues in. It returns false if there is no checkpoint of the same data,
+// or the server does not resume the data there.
+func (hs *HTTPDataSource) ResumeTransfer(dataFile, dataDir string) (ProcessingPhase, bool) {
+	phase, target := ProcessingPhaseTransferDataFile, dataFile
+	if hs.contentType == cdiv1.DataVolumeArchive {
+		if hs.digest != "" {
+			// The digest of the data extracted earlier can not be computed again
+			return ProcessingPhaseInfo, false
+		}
+		phase, target = ProcessingPhaseTransferDataDir, dataDir
+	}
+	start := hs.checkpoint()
+	if start == nil {
+		return ProcessingPhaseInfo, false
+	}
+	cp, err := newCheckpointStore(target).load()
+	if err != nil {
+		klog.Warningf("Unable to read the transfer checkpoint, starting over: %v", err)
+		return ProcessingPhaseInfo, false
+	}
+	if cp == nil || !cp.resumes(start) {
+		return ProcessingPhaseInfo, false
+	}
+	if phase == ProcessingPhaseTransferDataFile && !isBlockDeviceFunc(target) {
+		if info, err := os.Stat(target); err != nil || uint64(info.Size()) < cp.Offset {
+			klog.Warningf("The data before the transfer checkpoint is missing from %s, starting over", target)
+			return ProcessingPhaseInfo, false
+		}
+	}
+	if err := hs.resumeAt(cp, target); err != nil {
+		klog.Warningf("Unable to resume the transfer at byte %d, starting over: %v", cp.Offset, err)
+		return ProcessingPhaseInfo, false
+	}
+	klog.Infof("Resuming the transfer at byte %d of %d", cp.Offset, cp.Size)
+	return phase, true
+}
+
+// resumeAt replaces the response the data is read from with the data from the offset of cp, the digest of the data
+// written to target before the offset is computed again
+func (hs *HTTPDataSource) resumeAt(cp *transferCheckpoint, target string) error {
+	accessKey, secKey := "", ""
+	if hs.endpoint.User != nil {
+		accessKey = hs.endpoint.User.Username()
+		secKey, _ = hs.endpoint.User.Password()
+	}
+	body, err := createHTTPRangeReader(hs.ctx, hs.endpoint, accessKey, secKey, hs.certDir, cp.Offset, hs.rangeValidator())
+	if err != nil {
+		return err
+	}
+	var reader io.ReadCloser = hs.countingReader
+	if hs.digest != "" {
+		digestReader, err := NewDigestReader(hs.countingReader, hs.digest)
+		if err != nil {
+			body.Close()
+			return err
+		}
+		if err := digestReader.hashFile(target, cp.Offset); err != nil {
+			body.Close()
+			return err
+		}
+		reader = digestReader
+	}
+	// The progress of the resumed response is polled through the same counting reader
+	hs.countingReader.Reader.Close()
+	hs.countingReader.Reader = body
+	hs.httpReader = reader
+	hs.readers = newResumedFormatReaders(reader, hs.contentLength, cp.Offset)
+	hs.resumed = cp
+	return nil
+}
+
+// checkpoint returns the checkpoint at the beginning of the data, nil if the transfer can not be resumed since the
+// server does not tell the size of the data, or whether it changed
+func (hs *HTTPDataSource) checkpoint() *transferCheckpoint {
+	validator := hs.rangeValidator()
+	if validator == "" || hs.contentLength == 0 {
+		return nil
+	}
+	u := *hs.endpoint
+	u.User = nil
+	return newTransferCheckpoint(u.String(), validator, hs.contentLength)
+}
+
+// rawCheckpoint returns the checkpoint a transfer to a file starts at, nil if it can not be resumed since the data
+// written is not the data read
+func (hs *HTTPDataSource) rawCheckpoint() *transferCheckpoint {
+	if hs.resumed != nil {
+		return hs.resumed
+	}
+	if hs.readers.Archived || hs.readers.XVA {
+		return nil
+	}
+	return hs.checkpoint()
+}
+
+// archiveCheckpoint returns the checkpoint the extraction of an archive starts at, nil if it can not be resumed
+func (hs *HTTPDataSource) archiveCheckpoint() *transferCheckpoint {
+	if hs.resumed != nil {
+		return hs.resumed
+	}
+	if hs.digest != "" || hs.readers.Archived {
+		return nil
+	}
+	return hs.checkpoint()
+}
+
+// rangeValidator returns the validator a range request is made with, so the server sends the whole data if it
+// changed. Weak ETags can not be used for it.
+func (hs *HTTPDataSource) rangeValidator() string {
+	if hs.etag != "" && !strings.HasPrefix(hs.etag, "W/") {
+		return hs.etag
+	}
+	return hs.lastModified
+}
+
 // Process is called to do any special processing before giving the URI to the data back to the processor
 func (hs *HTTPDataSource) Process() (ProcessingPhase, error) {
+	if len(hs.backingFileURLs) > 0 {
+		// Resolve backing files without the credentials, so that they are not sent to other hosts
+		imageURL := *hs.endpoint
+		imageURL.User = nil
+		if err := fetchBackingChain(hs.url.Path, &imageURL, hs.backingFileURLs, hs.fetchBackingFile); err != nil {
+			return ProcessingPhaseError, err
+		}
+	}
 	return ProcessingPhaseConvert, nil
 }
 
+// fetchBackingFile downloads a backing file of the image, the credentials are only used on the host of the image.
+func (hs *HTTPDataSource) fetchBackingFile(u *url.URL, dest string) error {
+	accessKey, secKey := "", ""
+	if hs.endpoint.User != nil && u.Host == hs.endpoint.Host {
+		accessKey = hs.endpoint.User.Username()
+		secKey, _ = hs.endpoint.User.Password()
+	}
+	// Not hs.ctx, it is cancelled once the image has not made progress for a while
+	reader, _, _, err := createHTTPReader(context.Background(), u, accessKey, secKey, hs.certDir)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return util.StreamDataToFile(reader, dest)
+}
+
+// CacheValidators returns the ETag and Last-Modified validators of the image at the endpoint, which tell if it changed
+// since the import, empty if the server sent none
+func (hs *HTTPDataSource) CacheValidators() (string, string) {
+	return hs.etag, hs.lastModified
+}
+
 // GetURL returns the URI that the data processor can use when converting the data.
 func (hs *HTTPDataSource) GetURL() *url.URL {
 	return hs.url
@@ -188,6 +399,7 @@ func (hs *HTTPDataSource) Close() error {
 func createHTTPClient(certDir string) (*http.Client, error) {
 	client := &http.Client{
 		// Don't set timeout here, since that will be an absolute timeout, we need a relative to last progress timeout.
+		Transport: newIdentifyingTransport(http.DefaultTransport),
 	}
 
 	if certDir == "" {
@@ -224,19 +436,19 @@ func createHTTPClient(certDir string) (*http.Client, error) {
 		}
 	}
 
-	client.Transport = &http.Transport{
+	client.Transport = newIdentifyingTransport(&http.Transport{
 		TLSClientConfig: &tls.Config{
 			RootCAs: certPool,
 		},
-	}
+	})
 
 	return client, nil
 }
 
-func createHTTPReader(ctx context.Context, ep *url.URL, accessKey, secKey, certDir string) (io.ReadCloser, uint64, error) {
+func createHTTPReader(ctx context.Context, ep *url.URL, accessKey, secKey, certDir string) (io.ReadCloser, uint64, http.Header, error) {
 	client, err := createHTTPClient(certDir)
 	if err != nil {
-		return nil, uint64(0), errors.Wrap(err, "Error creating http client")
+		return nil, uint64(0), nil, errors.Wrap(err, "Error creating http client")
 	}
 
 	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
@@ -248,7 +460,7 @@ func createHTTPReader(ctx context.Context, ep *url.URL, accessKey, secKey, certD
 
 	total, err := getContentLength(client, ep, accessKey, secKey)
 	if err != nil {
-		return nil, total, err
+		return nil, total, nil, err
 	}
 	// http.NewRequest can only return error on invalid METHOD, or invalid url. Here the METHOD is always GET, and the url is always valid, thus error cannot happen.
 	req, _ := http.NewRequest("GET", ep.String(), nil)
@@ -260,17 +472,55 @@ func createHTTPReader(ctx context.Context, ep *url.URL, accessKey, secKey, certD
 	klog.V(2).Infof("Attempting to get object %q via http client\n", ep.String())
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, uint64(0), errors.Wrap(err, "HTTP request errored")
+		return nil, uint64(0), nil, errors.Wrap(err, "HTTP request errored")
 	}
 	if resp.StatusCode != 200 {
 		klog.Errorf("http: expected status code 200, got %d", resp.StatusCode)
-		return nil, uint64(0), errors.Errorf("expected status code 200, got %d. Status: %s", resp.StatusCode, resp.Status)
+		return nil, uint64(0), nil, httpStatusError(resp)
 	}
 	countingReader := &util.CountingReader{
 		Reader:  resp.Body,
 		Current: 0,
 	}
-	return countingReader, total, nil
+	return countingReader, total, resp.Header, nil
+}
+
+// createHTTPRangeReader returns the body of the response with the data of ep from offset, if it did not change since
+// the validator was sent
+func createHTTPRangeReader(ctx context.Context, ep *url.URL, accessKey, secKey, certDir string, offset uint64, validator string) (io.ReadCloser, error) {
+	client, err := createHTTPClient(certDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating http client")
+	}
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if len(accessKey) > 0 && len(secKey) > 0 {
+			r.SetBasicAuth(accessKey, secKey) // Redirects will lose basic auth, so reset them manually
+		}
+		return nil
+	}
+	u := *ep
+	u.User = nil
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	req = req.WithContext(ctx)
+	if len(accessKey) > 0 && len(secKey) > 0 {
+		req.SetBasicAuth(accessKey, secKey)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	req.Header.Set("If-Range", validator)
+	klog.V(2).Infof("Attempting to get object %q from byte %d via http client\n", u.String(), offset)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request errored")
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, errors.Errorf("expected status code 206, got %d. Status: %s", resp.StatusCode, resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+		resp.Body.Close()
+		return nil, errors.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+	}
+	return resp.Body, nil
 }
 
 func (hs *HTTPDataSource) pollProgress(reader *util.CountingReader, idleTime, pollInterval time.Duration) {
@@ -300,6 +550,19 @@ func (hs *HTTPDataSource) pollProgress(reader *util.CountingReader, idleTime, po
 	}
 }
 
+// httpStatusError returns the error of the unexpected status code of resp, a permanent error if retrying the request
+// does not help
+func httpStatusError(resp *http.Response) error {
+	err := errors.Errorf("expected status code 200, got %d. Status: %s", resp.StatusCode, resp.Status)
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return util.NewPermanentError(util.PermanentErrorUnauthorized, err)
+	case http.StatusNotFound:
+		return util.NewPermanentError(util.PermanentErrorNotFound, err)
+	}
+	return err
+}
+
 func getContentLength(client *http.Client, ep *url.URL, accessKey, secKey string) (uint64, error) {
 	req, err := http.NewRequest("HEAD", ep.String(), nil)
 	if err != nil {
@@ -317,7 +580,7 @@ func getContentLength(client *http.Client, ep *url.URL, accessKey, secKey string
 
 	if resp.StatusCode != 200 {
 		klog.Errorf("http: expected status code 200, got %d", resp.StatusCode)
-		return uint64(0), errors.Errorf("expected status code 200, got %d. Status: %s", resp.StatusCode, resp.Status)
+		return uint64(0), httpStatusError(resp)
 	}
 
 	for k, v := range resp.Header {
@@ -70,14 +70,14 @@ var _ = Describe("Http data source", func() {
 	})
 
 	It("NewHTTPDataSource should fail when called with an invalid endpoint", func() {
-		_, err = NewHTTPDataSource("httpd://!@#$%^&*()dgsdd&3r53/invalid", "", "", "", cdiv1.DataVolumeKubeVirt)
+		_, err = NewHTTPDataSource("httpd://!@#$%^&*()dgsdd&3r53/invalid", "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).To(HaveOccurred())
 		Expect(strings.Contains(err.Error(), "unable to parse endpoint")).To(BeTrue())
 	})
 
 	It("endpoint User object should be set when accessKey and secKey are not blank", func() {
 		image := ts.URL + "/" + cirrosFileName
-		dp, err = NewHTTPDataSource(image, "user", "password", "", cdiv1.DataVolumeKubeVirt)
+		dp, err = NewHTTPDataSource(image, "user", "password", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		user := dp.endpoint.User
 		Expect("user").To(Equal(user.Username()))
@@ -88,7 +88,7 @@ var _ = Describe("Http data source", func() {
 
 	It("NewHTTPDataSource should fail when called with an invalid certdir", func() {
 		image := ts.URL + "/" + cirrosFileName
-		_, err = NewHTTPDataSource(image, "", "", "/invaliddir", cdiv1.DataVolumeKubeVirt)
+		_, err = NewHTTPDataSource(image, "", "", "/invaliddir", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).To(HaveOccurred())
 	})
 
@@ -97,7 +97,7 @@ var _ = Describe("Http data source", func() {
 		if image != "" {
 			image = ts.URL + "/" + image
 		}
-		dp, err = NewHTTPDataSource(image, "", "", "", contentType)
+		dp, err = NewHTTPDataSource(image, "", "", "", contentType, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		newPhase, err := dp.Info()
 		if !wantErr {
@@ -118,7 +118,7 @@ var _ = Describe("Http data source", func() {
 	)
 
 	It("calling info with raw image should return TransferDataFile", func() {
-		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt)
+		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		newPhase, err := dp.Info()
 		Expect(err).NotTo(HaveOccurred())
@@ -133,7 +133,7 @@ var _ = Describe("Http data source", func() {
 		if image != "" {
 			image = ts.URL + "/" + image
 		}
-		dp, err = NewHTTPDataSource(image, "", "", "", contentType)
+		dp, err = NewHTTPDataSource(image, "", "", "", contentType, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		_, err := dp.Info()
 		Expect(err).NotTo(HaveOccurred())
@@ -164,7 +164,7 @@ var _ = Describe("Http data source", func() {
 	)
 
 	It("TransferFile should succeed when writing to valid file, and reading raw gz", func() {
-		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt)
+		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		result, err := dp.Info()
 		Expect(err).NotTo(HaveOccurred())
@@ -175,7 +175,7 @@ var _ = Describe("Http data source", func() {
 	})
 
 	It("TransferFile should succeed when writing to valid file and reading raw xz", func() {
-		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreXz, "", "", "", cdiv1.DataVolumeKubeVirt)
+		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreXz, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		result, err := dp.Info()
 		Expect(err).NotTo(HaveOccurred())
@@ -186,7 +186,7 @@ var _ = Describe("Http data source", func() {
 	})
 
 	It("TransferFile should fail on streaming error", func() {
-		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt)
+		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		result, err := dp.Info()
 		Expect(err).NotTo(HaveOccurred())
@@ -196,9 +196,35 @@ var _ = Describe("Http data source", func() {
 		Expect(ProcessingPhaseError).To(Equal(result))
 	})
 
+	It("Info should stream an image pinned to a digest rather than convert it from the endpoint", func() {
+		flushRead = cirrosData
+		dp, err = NewHTTPDataSource(ts.URL+"/"+cirrosFileName, "", "", "", cdiv1.DataVolumeKubeVirt, nil, sha256Digest(cirrosData))
+		Expect(err).NotTo(HaveOccurred())
+		newPhase, err := dp.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newPhase).To(Equal(ProcessingPhaseTransferScratch))
+		newPhase, err = dp.Transfer(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newPhase).To(Equal(ProcessingPhaseProcess))
+	})
+
+	It("TransferFile should fail permanently when the image does not match its digest", func() {
+		dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreGz, "", "", "", cdiv1.DataVolumeKubeVirt, nil, sha256Digest([]byte("changed")))
+		Expect(err).NotTo(HaveOccurred())
+		result, err := dp.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ProcessingPhaseTransferDataFile).To(Equal(result))
+		result, err = dp.TransferFile(filepath.Join(tmpDir, "file"))
+		Expect(err).To(HaveOccurred())
+		Expect(ProcessingPhaseError).To(Equal(result))
+		permanentErr, ok := util.AsPermanentError(err)
+		Expect(ok).To(BeTrue())
+		Expect(permanentErr.Reason).To(Equal(util.PermanentErrorChecksumMismatch))
+	})
+
 	It("calling Process should return Convert", func() {
 		flushRead = cirrosData
-		dp, err = NewHTTPDataSource(ts.URL+"/"+cirrosFileName, "", "", "", cdiv1.DataVolumeKubeVirt)
+		dp, err = NewHTTPDataSource(ts.URL+"/"+cirrosFileName, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
 		Expect(err).NotTo(HaveOccurred())
 		_, err := dp.Info()
 		Expect(err).NotTo(HaveOccurred())
@@ -206,6 +232,115 @@ var _ = Describe("Http data source", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(ProcessingPhaseConvert).To(Equal(newPhase))
 	})
+
+	Context("resuming a transfer", func() {
+		var (
+			target string
+			data   []byte
+		)
+
+		BeforeEach(func() {
+			flushRead = nil
+			target = filepath.Join(tmpDir, "disk.img")
+			data, err = readFile(tinyCoreFilePath)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		saveCheckpoint := func(target string, offset uint64) {
+			cp := dp.checkpoint()
+			Expect(cp).ToNot(BeNil())
+			cp.Offset = offset
+			Expect(newCheckpointStore(target).save(cp)).To(Succeed())
+		}
+
+		table.DescribeTable("should resume a raw image at the checkpoint", func(verified bool) {
+			digest := ""
+			if verified {
+				digest = sha256Digest(data)
+			}
+			dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreFileName, "", "", "", cdiv1.DataVolumeKubeVirt, nil, digest)
+			Expect(err).ToNot(HaveOccurred())
+			offset := uint64(4 * 1024 * 1024)
+			Expect(ioutil.WriteFile(target, data[:offset], 0644)).To(Succeed())
+			saveCheckpoint(target, offset)
+
+			phase, ok := dp.ResumeTransfer(target, tmpDir)
+			Expect(ok).To(BeTrue())
+			Expect(phase).To(Equal(ProcessingPhaseTransferDataFile))
+			phase, err = dp.TransferFile(target)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(phase).To(Equal(ProcessingPhaseResize))
+			content, err := ioutil.ReadFile(target)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(Equal(data))
+			cp, err := newCheckpointStore(target).load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cp).To(BeNil())
+		},
+			table.Entry("without a digest", false),
+			table.Entry("with a digest, computed over the data written before the checkpoint too", true),
+		)
+
+		It("should not resume when the data before the checkpoint is missing", func() {
+			dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreFileName, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(target, data[:1024], 0644)).To(Succeed())
+			saveCheckpoint(target, 4096)
+			_, ok := dp.ResumeTransfer(target, tmpDir)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should not resume the checkpoint of other data", func() {
+			dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreFileName, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(target, data[:4096], 0644)).To(Succeed())
+			cp := newTransferCheckpoint(ts.URL+"/"+tinyCoreFileName, "Tue, 14 Apr 2020 08:12:31 GMT", uint64(len(data)))
+			cp.Offset = 4096
+			Expect(newCheckpointStore(target).save(cp)).To(Succeed())
+			_, ok := dp.ResumeTransfer(target, tmpDir)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should not resume when the server sends the whole data", func() {
+			ts.Close()
+			files := http.FileServer(http.Dir(imageDir))
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.Header.Del("Range")
+				files.ServeHTTP(w, r)
+			}))
+			dp, err = NewHTTPDataSource(ts.URL+"/"+tinyCoreFileName, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(target, data[:4096], 0644)).To(Succeed())
+			saveCheckpoint(target, 4096)
+			_, ok := dp.ResumeTransfer(target, tmpDir)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should resume an archive at the entry of the checkpoint", func() {
+			archive, err := readFile(filepath.Join(imageDir, "archive.tar"))
+			Expect(err).ToNot(HaveOccurred())
+			headers := newTarCheckpointReader(nil, tmpDir, &transferCheckpoint{}, &recordingCheckpointStore{})
+			headers.scan(archive)
+			Expect(headers.restarts).To(HaveLen(3))
+
+			dp, err = NewHTTPDataSource(ts.URL+"/archive.tar", "", "", "", cdiv1.DataVolumeArchive, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+			saveCheckpoint(tmpDir, headers.restarts[1])
+			phase, ok := dp.ResumeTransfer(target, tmpDir)
+			Expect(ok).To(BeTrue())
+			Expect(phase).To(Equal(ProcessingPhaseTransferDataDir))
+			phase, err = dp.Transfer(tmpDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(phase).To(Equal(ProcessingPhaseComplete))
+			files, err := ioutil.ReadDir(tmpDir)
+			Expect(err).ToNot(HaveOccurred())
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name())
+			}
+			Expect(names).To(Equal([]string{"util_suite_test.go", "util_test.go"}))
+		})
+	})
 })
 
 var _ = Describe("Http client", func() {
@@ -236,7 +371,7 @@ var _ = Describe("Http client", func() {
 		client, err := createHTTPClient(tempDir)
 		Expect(err).ToNot(HaveOccurred())
 
-		transport := client.Transport.(*http.Transport)
+		transport := client.Transport.(*identifyingTransport).transport.(*http.Transport)
 		Expect(transport).ToNot(BeNil())
 
 		activeCAs := transport.TLSClientConfig.RootCAs
@@ -252,7 +387,7 @@ var _ = Describe("Http client", func() {
 
 var _ = Describe("Http reader", func() {
 	It("should fail when passed an invalid cert directory", func() {
-		_, total, err := createHTTPReader(context.Background(), nil, "", "", "/invalid")
+		_, total, _, err := createHTTPReader(context.Background(), nil, "", "", "/invalid")
 		Expect(err).To(HaveOccurred())
 		Expect(uint64(0)).To(Equal(total))
 	})
@@ -269,13 +404,36 @@ var _ = Describe("Http reader", func() {
 		defer ts.Close()
 		ep, err := url.Parse(ts.URL)
 		Expect(err).ToNot(HaveOccurred())
-		r, total, err := createHTTPReader(context.Background(), ep, "user", "password", "")
+		r, total, _, err := createHTTPReader(context.Background(), ep, "user", "password", "")
 		Expect(err).ToNot(HaveOccurred())
 		Expect(uint64(25)).To(Equal(total))
 		err = r.Close()
 		Expect(err).ToNot(HaveOccurred())
 	})
 
+	table.DescribeTable("should fail permanently if retrying the request does not help", func(statusCode int, expectedReason string) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+		defer ts.Close()
+		ep, err := url.Parse(ts.URL)
+		Expect(err).ToNot(HaveOccurred())
+		_, _, _, err = createHTTPReader(context.Background(), ep, "user", "password", "")
+		Expect(err).To(HaveOccurred())
+		permanentErr, ok := util.AsPermanentError(err)
+		if expectedReason == "" {
+			Expect(ok).To(BeFalse())
+			return
+		}
+		Expect(ok).To(BeTrue())
+		Expect(permanentErr.Reason).To(Equal(expectedReason))
+	},
+		table.Entry("if unauthorized", http.StatusUnauthorized, util.PermanentErrorUnauthorized),
+		table.Entry("if forbidden", http.StatusForbidden, util.PermanentErrorUnauthorized),
+		table.Entry("if not found", http.StatusNotFound, util.PermanentErrorNotFound),
+		table.Entry("not if the server is unavailable", http.StatusServiceUnavailable, ""),
+	)
+
 	It("should pass auth info in request if set and redirected", func() {
 		redirTs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user, pass, ok := r.BasicAuth()
@@ -292,7 +450,7 @@ var _ = Describe("Http reader", func() {
 		defer ts.Close()
 		ep, err := url.Parse(ts.URL)
 		Expect(err).ToNot(HaveOccurred())
-		r, total, err := createHTTPReader(context.Background(), ep, "user", "password", "")
+		r, total, _, err := createHTTPReader(context.Background(), ep, "user", "password", "")
 		Expect(err).ToNot(HaveOccurred())
 		Expect(uint64(25)).To(Equal(total))
 		err = r.Close()
@@ -313,7 +471,7 @@ var _ = Describe("Http reader", func() {
 		defer ts.Close()
 		ep, err := url.Parse(ts.URL)
 		Expect(err).ToNot(HaveOccurred())
-		r, total, err := createHTTPReader(context.Background(), ep, "", "", "")
+		r, total, _, err := createHTTPReader(context.Background(), ep, "", "", "")
 		Expect(err).ToNot(HaveOccurred())
 		Expect(uint64(25)).To(Equal(total))
 		err = r.Close()
@@ -327,11 +485,25 @@ var _ = Describe("Http reader", func() {
 		defer ts.Close()
 		ep, err := url.Parse(ts.URL)
 		Expect(err).ToNot(HaveOccurred())
-		_, total, err := createHTTPReader(context.Background(), ep, "", "", "")
+		_, total, _, err := createHTTPReader(context.Background(), ep, "", "", "")
 		Expect(err).To(HaveOccurred())
 		Expect(uint64(0)).To(Equal(total))
 		Expect("expected status code 200, got 500. Status: 500 Internal Server Error").To(Equal(err.Error()))
 	})
+
+	It("should return the validators of the response", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"5e8c-5a0d3f"`)
+			w.Header().Set("Last-Modified", "Tue, 14 Apr 2020 08:12:31 GMT")
+		}))
+		defer ts.Close()
+		dp, err := NewHTTPDataSource(ts.URL, "", "", "", cdiv1.DataVolumeKubeVirt, nil, "")
+		Expect(err).ToNot(HaveOccurred())
+		defer dp.Close()
+		etag, lastModified := dp.CacheValidators()
+		Expect(etag).To(Equal(`"5e8c-5a0d3f"`))
+		Expect(lastModified).To(Equal("Tue, 14 Apr 2020 08:12:31 GMT"))
+	})
 })
 
 var _ = Describe("http pollprogress", func() {
@@ -0,0 +1,273 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// nutanixImagesPath is the path of the images of the Prism Central v3 API
+	nutanixImagesPath = "/api/nutanix/v3/images"
+	// nutanixImageComplete is the state of an image that is ready to be downloaded
+	nutanixImageComplete = "COMPLETE"
+)
+
+// nutanixIdleTime is the time a download may make no progress before it is resumed, replaced in tests.
+var nutanixIdleTime = 10 * time.Minute
+
+// nutanixImage is the part of the image resource of the Prism Central v3 API CDI uses
+type nutanixImage struct {
+	Status struct {
+		Name      string `json:"name"`
+		State     string `json:"state"`
+		Resources struct {
+			SizeBytes uint64 `json:"size_bytes"`
+		} `json:"resources"`
+	} `json:"status"`
+}
+
+// NutanixDataSource is the data provider for images of the Nutanix AHV image service.
+// Sequence of phases:
+// 1a. Info -> TransferScratch if the image needs to be converted by QEMU-IMG (QCOW2)
+// 1b. Info -> TransferDataFile in all other cases, the data is streamed to the target.
+// 2. Transfer -> Process
+// 3. Process -> Convert
+type NutanixDataSource struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// the resumable reader of the image file
+	diskReader *resumableReader
+	// stack of readers
+	readers *FormatReaders
+	// url the url to report to the caller of getURL, a file in scratch space.
+	url *url.URL
+}
+
+// NewNutanixDataSource creates a new instance of the Nutanix data provider. endpoint is the url of the
+// Prism Central API.
+func NewNutanixDataSource(endpoint, accessKey, secKey, certDir, imageUUID string) (*NutanixDataSource, error) {
+	ep, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("unable to parse endpoint %q", endpoint))
+	}
+	if imageUUID == "" {
+		return nil, errors.New("the uuid of the image is missing")
+	}
+	client, err := createHTTPClient(certDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating http client")
+	}
+	imageURL := *ep
+	imageURL.Path = path.Join(ep.Path, nutanixImagesPath, imageUUID)
+	fileURL := imageURL
+	fileURL.Path = path.Join(imageURL.Path, "file")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	image, err := getNutanixImage(ctx, client, &imageURL, accessKey, secKey)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	klog.V(1).Infof("Reading %d bytes of image %s (%s) from the Nutanix image service", image.Status.Resources.SizeBytes, image.Status.Name, imageUUID)
+	return &NutanixDataSource{
+		ctx:    ctx,
+		cancel: cancel,
+		diskReader: &resumableReader{
+			size: image.Status.Resources.SizeBytes,
+			open: func(offset uint64) (io.ReadCloser, error) {
+				return openNutanixStream(ctx, client, &fileURL, accessKey, secKey, offset)
+			},
+		},
+	}, nil
+}
+
+// Info is called to get initial information about the data.
+func (nd *NutanixDataSource) Info() (ProcessingPhase, error) {
+	var err error
+	nd.readers, err = NewFormatReaders(nd.diskReader, nd.diskReader.size)
+	if err != nil {
+		klog.Errorf("Error creating readers: %v", err)
+		return ProcessingPhaseError, err
+	}
+	if !nd.readers.Convert {
+		return ProcessingPhaseTransferDataFile, nil
+	}
+	return ProcessingPhaseTransferScratch, nil
+}
+
+// Transfer is called to transfer the data from the source to a scratch location.
+func (nd *NutanixDataSource) Transfer(path string) (ProcessingPhase, error) {
+	if util.GetAvailableSpace(path) <= int64(0) {
+		//Path provided is invalid.
+		return ProcessingPhaseError, ErrInvalidPath
+	}
+	file := filepath.Join(path, tempFile)
+	err := util.StreamDataToFile(nd.readers.TopReader(), file)
+	if err != nil {
+		return ProcessingPhaseError, err
+	}
+	// If we successfully wrote to the file, then the parse will succeed.
+	nd.url, _ = url.Parse(file)
+	return ProcessingPhaseProcess, nil
+}
+
+// TransferFile is called to transfer the data from the source to the passed in file.
+func (nd *NutanixDataSource) TransferFile(fileName string) (ProcessingPhase, error) {
+	nd.readers.StartProgressUpdate()
+	err := util.StreamDataToFile(nd.readers.TopReader(), fileName)
+	if err != nil {
+		return ProcessingPhaseError, err
+	}
+	return ProcessingPhaseResize, nil
+}
+
+// Process is called to do any special processing before giving the URI to the data back to the processor
+func (nd *NutanixDataSource) Process() (ProcessingPhase, error) {
+	return ProcessingPhaseConvert, nil
+}
+
+// GetURL returns the URI that the data processor can use when converting the data.
+func (nd *NutanixDataSource) GetURL() *url.URL {
+	return nd.url
+}
+
+// Close all readers.
+func (nd *NutanixDataSource) Close() error {
+	nd.cancel()
+	if nd.readers != nil {
+		return nd.readers.Close()
+	}
+	return nd.diskReader.Close()
+}
+
+// getNutanixImage returns the image at imageURL, it has to be ready to be downloaded.
+func getNutanixImage(ctx context.Context, client *http.Client, imageURL *url.URL, accessKey, secKey string) (*nutanixImage, error) {
+	req, _ := http.NewRequest("GET", imageURL.String(), nil)
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(accessKey, secKey)
+	req.Header.Set("Accept", "application/json")
+	klog.V(2).Infof("Attempting to get image %q from Prism Central", imageURL.String())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request errored")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("could not get image %s, expected status code 200, got %d. Status: %s", path.Base(imageURL.Path), resp.StatusCode, resp.Status)
+	}
+	image := &nutanixImage{}
+	if err = json.NewDecoder(resp.Body).Decode(image); err != nil {
+		return nil, errors.Wrap(err, "could not decode the image")
+	}
+	if image.Status.State != nutanixImageComplete {
+		return nil, errors.Errorf("image %s is %s, it can only be imported once %s", path.Base(imageURL.Path), image.Status.State, nutanixImageComplete)
+	}
+	if image.Status.Resources.SizeBytes == 0 {
+		return nil, errors.Errorf("image %s has no size", path.Base(imageURL.Path))
+	}
+	return image, nil
+}
+
+// nutanixStream is the body of an image download, closing it stops the watch for progress.
+type nutanixStream struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (s *nutanixStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.cancel()
+	return err
+}
+
+// openNutanixStream starts downloading the image file from offset on. The image service is asked for the
+// range starting at offset, if it returns the whole file the bytes before offset are skipped.
+func openNutanixStream(ctx context.Context, client *http.Client, fileURL *url.URL, accessKey, secKey string, offset uint64) (io.ReadCloser, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	req, _ := http.NewRequest("GET", fileURL.String(), nil)
+	req = req.WithContext(streamCtx)
+	req.SetBasicAuth(accessKey, secKey)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "HTTP request errored")
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			klog.Warningf("The image service does not support ranged reads, skipping %d bytes", offset)
+			if _, err = io.CopyN(ioutil.Discard, resp.Body, int64(offset)); err != nil {
+				resp.Body.Close()
+				cancel()
+				return nil, errors.Wrap(err, "could not skip to the resumed offset")
+			}
+		}
+	default:
+		resp.Body.Close()
+		cancel()
+		return nil, errors.Errorf("expected status code 200, got %d. Status: %s", resp.StatusCode, resp.Status)
+	}
+	countingReader := &util.CountingReader{
+		Reader:  resp.Body,
+		Current: 0,
+	}
+	go cancelWhenIdle(streamCtx, cancel, countingReader, nutanixIdleTime, time.Second)
+	return &nutanixStream{ReadCloser: countingReader, cancel: cancel}, nil
+}
+
+// cancelWhenIdle cancels the download read by reader once it made no progress for idleTime, so that it is resumed.
+func cancelWhenIdle(ctx context.Context, cancel context.CancelFunc, reader *util.CountingReader, idleTime, pollInterval time.Duration) {
+	count := reader.Current
+	lastUpdate := time.Now()
+	for {
+		if count < reader.Current {
+			// Some progress was made, reset now.
+			lastUpdate = time.Now()
+			count = reader.Current
+		}
+		if time.Until(lastUpdate.Add(idleTime)).Nanoseconds() < 0 {
+			klog.Warningf("The download made no progress for %v", idleTime)
+			cancel()
+		}
+		select {
+		case <-time.After(pollInterval):
+			continue
+		case <-ctx.Done():
+			return // Don't leak, once the download is cancelled or completed this is called.
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testImageUUID = "c3e5b4a2-7d8e-4f10-9a6b-0123456789ab"
+
+// nutanixServer serves the image file at filePath as image testImageUUID. It drops the connection of the
+// first download after dropAfter bytes if dropAfter is set, and ignores ranges if noRanges is set.
+type nutanixServer struct {
+	filePath  string
+	state     string
+	dropAfter int
+	noRanges  bool
+	ranges    []string
+}
+
+func (ns *nutanixServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "nutanix/4u" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	content, err := ioutil.ReadFile(ns.filePath)
+	Expect(err).NotTo(HaveOccurred())
+	switch r.URL.Path {
+	case "/prism/api/nutanix/v3/images/" + testImageUUID:
+		fmt.Fprintf(w, `{"status": {"name": "disk", "state": %q, "resources": {"image_type": "DISK_IMAGE", "size_bytes": %d}}}`, ns.state, len(content))
+	case "/prism/api/nutanix/v3/images/" + testImageUUID + "/file":
+		ns.ranges = append(ns.ranges, r.Header.Get("Range"))
+		if ns.dropAfter > 0 {
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+			w.Write(content[:ns.dropAfter])
+			ns.dropAfter = 0
+			panic(http.ErrAbortHandler)
+		}
+		if ns.noRanges {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("Nutanix data source", func() {
+	var (
+		tmpDir string
+		ns     *nutanixServer
+		server *httptest.Server
+		nd     *NutanixDataSource
+		err    error
+	)
+
+	BeforeEach(func() {
+		resumeRetryDelay = 0
+		nd = nil
+		tmpDir, err = ioutil.TempDir("", "nutanix-test")
+		Expect(err).NotTo(HaveOccurred())
+		ns = &nutanixServer{filePath: tinyCoreFilePath, state: "COMPLETE"}
+		server = httptest.NewServer(ns)
+	})
+
+	AfterEach(func() {
+		if nd != nil {
+			nd.Close()
+		}
+		server.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	It("Should stream a raw image to the target", func() {
+		nd, err = NewNutanixDataSource(server.URL+"/prism", "admin", "nutanix/4u", "", testImageUUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nd.Info()).To(Equal(ProcessingPhaseTransferDataFile))
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(nd.TransferFile(target)).To(Equal(ProcessingPhaseResize))
+		expected, err := ioutil.ReadFile(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadFile(target)).To(Equal(expected))
+	})
+
+	It("Should download a qcow2 image to scratch space", func() {
+		ns.filePath = cirrosFilePath
+		nd, err = NewNutanixDataSource(server.URL+"/prism", "admin", "nutanix/4u", "", testImageUUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nd.Info()).To(Equal(ProcessingPhaseTransferScratch))
+		Expect(nd.Transfer(tmpDir)).To(Equal(ProcessingPhaseProcess))
+		Expect(nd.GetURL().String()).To(Equal(filepath.Join(tmpDir, tempFile)))
+	})
+
+	It("Should resume an interrupted download with a ranged read", func() {
+		ns.dropAfter = 4096
+		nd, err = NewNutanixDataSource(server.URL+"/prism", "admin", "nutanix/4u", "", testImageUUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nd.Info()).To(Equal(ProcessingPhaseTransferDataFile))
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(nd.TransferFile(target)).To(Equal(ProcessingPhaseResize))
+		expected, err := ioutil.ReadFile(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadFile(target)).To(Equal(expected))
+		Expect(ns.ranges).To(Equal([]string{"", "bytes=4096-"}))
+	})
+
+	It("Should skip to the offset if the image service ignores ranges", func() {
+		ns.dropAfter = 4096
+		ns.noRanges = true
+		nd, err = NewNutanixDataSource(server.URL+"/prism", "admin", "nutanix/4u", "", testImageUUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nd.Info()).To(Equal(ProcessingPhaseTransferDataFile))
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(nd.TransferFile(target)).To(Equal(ProcessingPhaseResize))
+		expected, err := ioutil.ReadFile(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadFile(target)).To(Equal(expected))
+	})
+
+	It("Should fail if the image is not ready", func() {
+		ns.state = "PENDING"
+		_, err = NewNutanixDataSource(server.URL+"/prism", "admin", "nutanix/4u", "", testImageUUID)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("is PENDING"))
+	})
+
+	It("Should fail if the image does not exist", func() {
+		_, err = NewNutanixDataSource(server.URL+"/prism", "admin", "nutanix/4u", "", "missing")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("got 404"))
+	})
+
+	It("Should fail with wrong credentials", func() {
+		_, err = NewNutanixDataSource(server.URL+"/prism", "admin", "wrong", "", testImageUUID)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("got 401"))
+	})
+})
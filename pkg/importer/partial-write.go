@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+)
+
+const (
+	// sectorSize is the size of the sector holding the partial write marker
+	sectorSize = 512
+
+	// wipeBufferSize is how much is zeroed at once when a block device is wiped
+	wipeBufferSize = 1024 * 1024
+)
+
+// partialWriteMarker is kept in the last sector of a block device while data is written to it. Disk images rarely
+// reach the end of the device, so a marker that is still there when a transfer starts tells that an earlier attempt
+// was interrupted and left a partially written device behind.
+var partialWriteMarker = []byte("CDI partial write, do not use this device\n")
+
+// isBlockDeviceFunc is used to tell whether the data file is a block device, may be overridden in tests
+var isBlockDeviceFunc = isBlockDevice
+
+func isBlockDevice(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeDevice != 0
+}
+
+// startPartialWrite wipes the device if an earlier write to it was interrupted, so the retry overwrites it fully,
+// and marks the device as partially written until finishPartialWrite is called.
+func startPartialWrite(device string) error {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", device)
+	}
+	defer f.Close()
+	offset, err := markerOffset(f)
+	if err != nil {
+		return err
+	}
+	sector := make([]byte, sectorSize)
+	if _, err := f.ReadAt(sector, offset); err != nil {
+		return errors.Wrapf(err, "could not read the last sector of %s", device)
+	}
+	if bytes.HasPrefix(sector, partialWriteMarker) {
+		klog.Warningf("An earlier write to %s was interrupted, wiping the device before writing it again", device)
+		if err := wipe(f, offset+sectorSize); err != nil {
+			return errors.Wrapf(err, "could not wipe %s", device)
+		}
+	}
+	copy(sector, partialWriteMarker)
+	if _, err := f.WriteAt(sector, offset); err != nil {
+		return errors.Wrapf(err, "could not mark %s as partially written", device)
+	}
+	return f.Sync()
+}
+
+// finishPartialWrite removes the marker startPartialWrite left on the device, unless the data overwrote it.
+func finishPartialWrite(device string) error {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", device)
+	}
+	defer f.Close()
+	offset, err := markerOffset(f)
+	if err != nil {
+		return err
+	}
+	sector := make([]byte, sectorSize)
+	if _, err := f.ReadAt(sector, offset); err != nil {
+		return errors.Wrapf(err, "could not read the last sector of %s", device)
+	}
+	if !bytes.HasPrefix(sector, partialWriteMarker) {
+		return nil
+	}
+	if _, err := f.WriteAt(make([]byte, sectorSize), offset); err != nil {
+		return errors.Wrapf(err, "could not remove the partial write marker of %s", device)
+	}
+	return f.Sync()
+}
+
+// markerOffset returns the offset of the last sector of f
+func markerOffset(f *os.File) (int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get the size of %s", f.Name())
+	}
+	if size < sectorSize {
+		return 0, errors.Errorf("%s is smaller than a sector", f.Name())
+	}
+	return size - sectorSize, nil
+}
+
+// wipe zeroes the first size bytes of f
+func wipe(f *os.File, size int64) error {
+	zeroes := make([]byte, wipeBufferSize)
+	for offset := int64(0); offset < size; offset += wipeBufferSize {
+		n := int64(wipeBufferSize)
+		if size-offset < n {
+			n = size - offset
+		}
+		if _, err := f.WriteAt(zeroes[:n], offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
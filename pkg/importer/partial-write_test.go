@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Partial write marker", func() {
+	var (
+		tmpDir string
+		device string
+	)
+
+	const deviceSize = 4 * sectorSize
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "partial-write")
+		Expect(err).ToNot(HaveOccurred())
+		device = filepath.Join(tmpDir, "device")
+		Expect(ioutil.WriteFile(device, bytes.Repeat([]byte{'d'}, deviceSize), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	readDevice := func() []byte {
+		content, err := ioutil.ReadFile(device)
+		Expect(err).ToNot(HaveOccurred())
+		return content
+	}
+
+	It("Should mark the last sector while writing and remove the marker when done", func() {
+		Expect(startPartialWrite(device)).To(Succeed())
+		content := readDevice()
+		Expect(content[deviceSize-sectorSize:]).To(HavePrefix(string(partialWriteMarker)))
+		Expect(content[:sectorSize]).To(Equal(bytes.Repeat([]byte{'d'}, sectorSize)))
+
+		Expect(finishPartialWrite(device)).To(Succeed())
+		content = readDevice()
+		Expect(content[deviceSize-sectorSize:]).To(Equal(make([]byte, sectorSize)))
+		Expect(content[:sectorSize]).To(Equal(bytes.Repeat([]byte{'d'}, sectorSize)))
+	})
+
+	It("Should wipe the device when an earlier write was interrupted", func() {
+		Expect(startPartialWrite(device)).To(Succeed())
+		Expect(startPartialWrite(device)).To(Succeed())
+		content := readDevice()
+		Expect(content[:deviceSize-sectorSize]).To(Equal(make([]byte, deviceSize-sectorSize)))
+		Expect(content[deviceSize-sectorSize:]).To(HavePrefix(string(partialWriteMarker)))
+	})
+
+	It("Should not clear a last sector the data overwrote", func() {
+		Expect(startPartialWrite(device)).To(Succeed())
+		f, err := os.OpenFile(device, os.O_WRONLY, 0)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteAt(bytes.Repeat([]byte{'i'}, deviceSize), 0)
+		Expect(err).ToNot(HaveOccurred())
+		f.Close()
+
+		Expect(finishPartialWrite(device)).To(Succeed())
+		Expect(readDevice()).To(Equal(bytes.Repeat([]byte{'i'}, deviceSize)))
+	})
+
+	Context("with the data processor", func() {
+		BeforeEach(func() {
+			isBlockDeviceFunc = func(string) bool {
+				return true
+			}
+		})
+
+		AfterEach(func() {
+			isBlockDeviceFunc = isBlockDevice
+		})
+
+		It("Should leave the marker on the device when the transfer fails", func() {
+			mdp := &MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseError,
+			}
+			dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+			Expect(dp.ProcessDataWithPause()).ToNot(Succeed())
+			Expect(readDevice()[deviceSize-sectorSize:]).To(HavePrefix(string(partialWriteMarker)))
+		})
+
+		It("Should remove the marker from the device when the transfer completes", func() {
+			mdp := &MockDataProvider{
+				infoResponse:     ProcessingPhaseTransferDataFile,
+				transferResponse: ProcessingPhaseComplete,
+			}
+			dp := NewDataProcessor(mdp, device, "dataDir", "scratchDataDir", "")
+			Expect(dp.ProcessDataWithPause()).To(Succeed())
+			Expect(readDevice()[deviceSize-sectorSize:]).To(Equal(make([]byte, sectorSize)))
+		})
+	})
+})
@@ -17,16 +17,22 @@ limitations under the License.
 package importer
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 
 	"k8s.io/klog"
 
+	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/image"
 	"kubevirt.io/containerized-data-importer/pkg/util"
 )
@@ -35,6 +41,14 @@ const (
 	//containerDiskImageDir - Expected disk image location in container image as described in
 	//https://github.com/kubevirt/kubevirt/blob/master/docs/container-register-disks.md
 	containerDiskImageDir = "disk"
+	// pullSecretFile is the key of the docker config in a kubernetes.io/dockerconfigjson secret
+	pullSecretFile = ".dockerconfigjson"
+	// legacyPullSecretFile is the key of the docker config in a kubernetes.io/dockercfg secret
+	legacyPullSecretFile = ".dockercfg"
+	// authFileName is the name of the merged registry auth file in scratch space
+	authFileName = "auth.json"
+	// imageArchiveFileName is the name of a downloaded docker-archive or oci-archive in scratch space
+	imageArchiveFileName = "image-archive.tar"
 )
 
 // RegistryDataSource is the struct containing the information needed to import from a registry data source.
@@ -47,23 +61,52 @@ type RegistryDataSource struct {
 	accessKey   string
 	secKey      string
 	certDir     string
+	arch        string
+	diskPath    string
+	artifact    image.ArtifactSelector
 	insecureTLS bool
 	imageDir    string
+	// mirrors are tried in order before the endpoint itself
+	mirrors []string
+	// insecureMirrors are the mirrors that are accessed without TLS verification, insecureTLS applies to the
+	// endpoint only
+	insecureMirrors []string
+	// pullSecretDir contains the mounted pull secrets, one directory per secret in order of preference
+	pullSecretDir string
 	//The discovered image file in scratch space.
 	url *url.URL
 }
 
 // NewRegistryDataSource creates a new instance of the Registry Data Source.
-func NewRegistryDataSource(endpoint, accessKey, secKey, certDir string, insecureTLS bool) *RegistryDataSource {
+func NewRegistryDataSource(endpoint, accessKey, secKey, certDir, arch, diskPath string, mirrors, insecureMirrors []string, artifact image.ArtifactSelector, insecureTLS bool) *RegistryDataSource {
 	return &RegistryDataSource{
-		endpoint:    endpoint,
-		accessKey:   accessKey,
-		secKey:      secKey,
-		certDir:     certDir,
-		insecureTLS: insecureTLS,
+		endpoint:        endpoint,
+		accessKey:       accessKey,
+		secKey:          secKey,
+		certDir:         certDir,
+		arch:            arch,
+		diskPath:        diskPath,
+		artifact:        artifact,
+		insecureTLS:     insecureTLS,
+		mirrors:         mirrors,
+		insecureMirrors: insecureMirrors,
+		pullSecretDir:   common.ImporterPullSecretDir,
 	}
 }
 
+// isInsecure returns true if TLS verification is disabled for the registry of location.
+func (rd *RegistryDataSource) isInsecure(location string) bool {
+	if location == rd.endpoint {
+		return rd.insecureTLS
+	}
+	for _, mirror := range rd.insecureMirrors {
+		if mirror == location {
+			return true
+		}
+	}
+	return false
+}
+
 // Info is called to get initial information about the data. No information available for registry currently.
 func (rd *RegistryDataSource) Info() (ProcessingPhase, error) {
 	return ProcessingPhaseTransferScratch, nil
@@ -77,8 +120,42 @@ func (rd *RegistryDataSource) Transfer(path string) (ProcessingPhase, error) {
 	}
 	rd.imageDir = filepath.Join(path, containerDiskImageDir)
 
-	klog.V(1).Infof("Copying registry image to scratch space.")
-	err := image.CopyRegistryImage(rd.endpoint, path, containerDiskImageDir, rd.accessKey, rd.secKey, rd.certDir, rd.insecureTLS)
+	if isArchiveEndpoint(rd.endpoint) {
+		if err := rd.transferArchive(path); err != nil {
+			return ProcessingPhaseError, errors.Wrapf(err, "Failed to read image archive")
+		}
+		return ProcessingPhaseProcess, nil
+	}
+
+	authFile, err := mergePullSecrets(rd.pullSecretDir, filepath.Join(path, authFileName))
+	if err != nil {
+		return ProcessingPhaseError, errors.Wrapf(err, "Failed to read pull secrets")
+	}
+	if authFile != "" {
+		defer os.Remove(authFile)
+	}
+
+	// Mirrors are preferred over the endpoint, the explicit credentials are preferred over the pull secrets.
+	locations := append(append([]string{}, rd.mirrors...), rd.endpoint)
+	for _, location := range locations {
+		klog.V(1).Infof("Copying registry image %s to scratch space.", location)
+		insecure := rd.isInsecure(location)
+		if rd.accessKey != "" && rd.secKey != "" {
+			err = image.CopyRegistryImage(location, path, containerDiskImageDir, rd.accessKey, rd.secKey, "", rd.certDir, rd.arch, rd.diskPath, rd.artifact, insecure)
+			if err == nil {
+				break
+			}
+			klog.Warningf("Failed to copy %s with the provided credentials: %v", location, err)
+			if authFile == "" {
+				continue
+			}
+		}
+		err = image.CopyRegistryImage(location, path, containerDiskImageDir, "", "", authFile, rd.certDir, rd.arch, rd.diskPath, rd.artifact, insecure)
+		if err == nil {
+			break
+		}
+		klog.Warningf("Failed to copy %s: %v", location, err)
+	}
 	if err != nil {
 		return ProcessingPhaseError, errors.Wrapf(err, "Failed to read registry image")
 	}
@@ -86,6 +163,41 @@ func (rd *RegistryDataSource) Transfer(path string) (ProcessingPhase, error) {
 	return ProcessingPhaseProcess, nil
 }
 
+// transferArchive downloads a docker-archive or oci-archive tarball to scratch space, and extracts the image
+// from it like from a registry. Mirrors and pull secrets only apply to registries.
+func (rd *RegistryDataSource) transferArchive(path string) error {
+	ep, err := ParseEndpoint(rd.endpoint)
+	if err != nil {
+		return err
+	}
+	archiveFile := filepath.Join(path, imageArchiveFileName)
+	defer os.Remove(archiveFile)
+
+	klog.V(1).Infof("Downloading image archive %s to scratch space.", ep.String())
+	reader, _, _, err := createHTTPReader(context.Background(), ep, rd.accessKey, rd.secKey, rd.certDir)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	out, err := os.OpenFile(archiveFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "could not create image archive")
+	}
+	_, err = io.Copy(out, reader)
+	out.Close()
+	if err != nil {
+		return errors.Wrap(err, "could not download image archive")
+	}
+
+	return image.CopyArchiveImage(archiveFile, path, containerDiskImageDir, rd.arch, rd.diskPath, rd.artifact)
+}
+
+// isArchiveEndpoint returns true if the registry source points to an image tarball served over http(s),
+// instead of to an image in a registry.
+func isArchiveEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")
+}
+
 // TransferFile is called to transfer the data from the source to the passed in file.
 func (rd *RegistryDataSource) TransferFile(fileName string) (ProcessingPhase, error) {
 	return ProcessingPhaseError, errors.New("Transferfile should not be called")
@@ -149,3 +261,76 @@ func getImageFileName(dir string) (string, error) {
 
 	return filename, nil
 }
+
+// mergePullSecrets merges the docker configs of the pull secrets mounted in dir into a single registry
+// auth file at dest. Secrets are mounted in numbered directories, when several secrets contain credentials
+// for the same registry the lowest numbered one wins. Both kubernetes.io/dockerconfigjson and the legacy
+// kubernetes.io/dockercfg secrets are supported. Returns an empty path if there are no credentials.
+func mergePullSecrets(dir, dest string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "could not read %s", dir)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, _ := strconv.Atoi(entries[i].Name())
+		b, _ := strconv.Atoi(entries[j].Name())
+		return a < b
+	})
+
+	auths := make(map[string]json.RawMessage)
+	for _, entry := range entries {
+		config, err := readPullSecret(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", errors.Wrapf(err, "could not read pull secret %s", entry.Name())
+		}
+		for registry, auth := range config {
+			if _, ok := auths[registry]; !ok {
+				auths[registry] = auth
+			}
+		}
+	}
+	if len(auths) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal registry auth file")
+	}
+	if err = ioutil.WriteFile(dest, data, 0600); err != nil {
+		return "", errors.Wrap(err, "could not write registry auth file")
+	}
+	return dest, nil
+}
+
+// readPullSecret returns the registry credentials of the pull secret mounted in dir, nil if it contains none.
+func readPullSecret(dir string) (map[string]json.RawMessage, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, pullSecretFile))
+	if err == nil {
+		var config struct {
+			Auths map[string]json.RawMessage `json:"auths"`
+		}
+		if err = json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		return config.Auths, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The legacy format holds the credentials without the auths wrapper
+	data, err = ioutil.ReadFile(filepath.Join(dir, legacyPullSecretFile))
+	if os.IsNotExist(err) {
+		// Pull secrets are optional
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config map[string]json.RawMessage
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
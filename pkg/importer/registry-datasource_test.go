@@ -1,7 +1,10 @@
 package importer
 
 import (
+	"archive/tar"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -41,7 +44,7 @@ var _ = Describe("Registry data source", func() {
 	})
 
 	It("should return transfer after info is called", func() {
-		ds = NewRegistryDataSource("", "", "", "", true)
+		ds = NewRegistryDataSource("", "", "", "", "", "", nil, nil, image.ArtifactSelector{}, true)
 		result, err := ds.Info()
 		Expect(err).NotTo(HaveOccurred())
 		Expect(ProcessingPhaseTransferScratch).To(Equal(result))
@@ -51,7 +54,7 @@ var _ = Describe("Registry data source", func() {
 		if scratchPath == "" {
 			scratchPath = tmpDir
 		}
-		ds = NewRegistryDataSource(ep, accKey, secKey, certDir, insecureRegistry)
+		ds = NewRegistryDataSource(ep, accKey, secKey, certDir, "arm64", "", nil, nil, image.ArtifactSelector{}, insecureRegistry)
 		By("Replacing Skopeo Operations")
 		replaceSkopeoOperations(skopeoOperations, func() {
 			// Need to pass in a real path if we don't want scratch space needed error.
@@ -73,7 +76,7 @@ var _ = Describe("Registry data source", func() {
 	)
 
 	table.DescribeTable("Process should ", func(scratchPath string, wantErr bool) {
-		ds = NewRegistryDataSource("", "", "", "", true)
+		ds = NewRegistryDataSource("", "", "", "", "", "", nil, nil, image.ArtifactSelector{}, true)
 		if scratchPath == "" {
 			scratchPath = tmpDir
 			err := os.Mkdir(filepath.Join(scratchPath, containerDiskImageDir), os.ModeDir)
@@ -98,8 +101,126 @@ var _ = Describe("Registry data source", func() {
 		table.Entry("return Error on invalid image file", "/invalid", true),
 	)
 
+	It("Transfer should try the mirrors before the endpoint", func() {
+		ds = NewRegistryDataSource("docker://quay.io/image", "user", "password", "", "", "", []string{"docker://mirror1/image", "docker://mirror2/image"}, nil, image.ArtifactSelector{}, false)
+		ds.pullSecretDir = filepath.Join(tmpDir, "pull-secrets")
+		writePullSecret(ds.pullSecretDir, "0", `{"auths": {"mirror2": {"auth": "Zmlyc3Q6c2VjcmV0"}}}`)
+		skopeo := &recordingSkopeoOperations{succeed: "docker://mirror2/image"}
+		replaceSkopeoOperations(skopeo, func() {
+			result, err := ds.Transfer(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ProcessingPhaseProcess).To(Equal(result))
+		})
+		authFile := filepath.Join(tmpDir, authFileName)
+		Expect(skopeo.calls).To(Equal([]string{
+			"docker://mirror1/image user ",
+			"docker://mirror1/image  " + authFile,
+			"docker://mirror2/image user ",
+			"docker://mirror2/image  " + authFile,
+		}))
+		_, err = os.Stat(authFile)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("Transfer should only skip TLS verification for insecure locations", func() {
+		ds = NewRegistryDataSource("docker://quay.io/image", "", "", "", "", "", []string{"docker://mirror1/image", "docker://mirror2/image"}, []string{"docker://mirror2/image"}, image.ArtifactSelector{}, true)
+		ds.pullSecretDir = filepath.Join(tmpDir, "pull-secrets")
+		writePullSecret(ds.pullSecretDir, "0", `{"auths": {"quay.io": {"auth": "Zmlyc3Q6c2VjcmV0"}}}`)
+		skopeo := &recordingSkopeoOperations{succeed: "docker://quay.io/image"}
+		replaceSkopeoOperations(skopeo, func() {
+			_, err := ds.Transfer(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+		Expect(skopeo.insecure).To(Equal([]string{"docker://mirror2/image", "docker://quay.io/image"}))
+	})
+
+	It("Transfer should fail if no location can be copied", func() {
+		ds = NewRegistryDataSource("docker://quay.io/image", "", "", "", "", "", []string{"docker://mirror1/image"}, nil, image.ArtifactSelector{}, false)
+		ds.pullSecretDir = filepath.Join(tmpDir, "pull-secrets")
+		skopeo := &recordingSkopeoOperations{}
+		replaceSkopeoOperations(skopeo, func() {
+			result, err := ds.Transfer(tmpDir)
+			Expect(err).To(HaveOccurred())
+			Expect(ProcessingPhaseError).To(Equal(result))
+		})
+		Expect(skopeo.calls).To(Equal([]string{
+			"docker://mirror1/image  ",
+			"docker://quay.io/image  ",
+		}))
+	})
+
+	It("Transfer should copy an oci-archive served over http", func() {
+		archive := filepath.Join(tmpDir, "served", "image.tar")
+		writeImageArchive(archive, "oci-layout", "index.json")
+		ts := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(archive))))
+		defer ts.Close()
+		scratch := filepath.Join(tmpDir, "scratch")
+		Expect(os.MkdirAll(scratch, os.ModePerm)).To(Succeed())
+		ds = NewRegistryDataSource(ts.URL+"/image.tar", "", "", "", "arm64", "", []string{"docker://mirror1/image"}, nil, image.ArtifactSelector{}, false)
+		skopeo := NewFakeSkopeoOperations("oci-archive:"+filepath.Join(scratch, imageArchiveFileName), "", "", "", false, nil)
+		replaceSkopeoOperations(skopeo, func() {
+			result, err := ds.Transfer(scratch)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ProcessingPhaseProcess).To(Equal(result))
+		})
+		_, err = os.Stat(filepath.Join(scratch, imageArchiveFileName))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("Transfer should fail on a tarball that is not an image archive", func() {
+		archive := filepath.Join(tmpDir, "served", "image.tar")
+		writeImageArchive(archive, "disk.img")
+		ts := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(archive))))
+		defer ts.Close()
+		ds = NewRegistryDataSource(ts.URL+"/image.tar", "", "", "", "", "", nil, nil, image.ArtifactSelector{}, false)
+		replaceSkopeoOperations(NewSkopeoAllErrors(), func() {
+			result, err := ds.Transfer(tmpDir)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("neither a docker-archive nor an oci-archive"))
+			Expect(ProcessingPhaseError).To(Equal(result))
+		})
+	})
+
+	It("mergePullSecrets should prefer the first pull secret", func() {
+		dir := filepath.Join(tmpDir, "pull-secrets")
+		writePullSecret(dir, "10", `{"auths": {"quay.io": {"auth": "last"}}}`)
+		writePullSecret(dir, "2", `{"auths": {"quay.io": {"auth": "second"}, "docker.io": {"auth": "second"}}}`)
+		writePullSecret(dir, "1", `{"auths": {"quay.io": {"auth": "first"}}}`)
+		Expect(os.MkdirAll(filepath.Join(dir, "3"), os.ModePerm)).To(Succeed())
+		authFile, err := mergePullSecrets(dir, filepath.Join(tmpDir, authFileName))
+		Expect(err).NotTo(HaveOccurred())
+		data, err := ioutil.ReadFile(authFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"auths": {"quay.io": {"auth": "first"}, "docker.io": {"auth": "second"}}}`))
+	})
+
+	It("mergePullSecrets should read legacy dockercfg pull secrets", func() {
+		dir := filepath.Join(tmpDir, "pull-secrets")
+		writePullSecret(dir, "0", `{"auths": {"quay.io": {"auth": "first"}}}`)
+		Expect(os.MkdirAll(filepath.Join(dir, "1"), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "1", legacyPullSecretFile), []byte(`{"quay.io": {"auth": "second"}, "docker.io": {"auth": "second"}}`), 0600)).To(Succeed())
+		authFile, err := mergePullSecrets(dir, filepath.Join(tmpDir, authFileName))
+		Expect(err).NotTo(HaveOccurred())
+		data, err := ioutil.ReadFile(authFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{"auths": {"quay.io": {"auth": "first"}, "docker.io": {"auth": "second"}}}`))
+	})
+
+	It("mergePullSecrets should return no auth file without pull secrets", func() {
+		authFile, err := mergePullSecrets(filepath.Join(tmpDir, "pull-secrets"), filepath.Join(tmpDir, authFileName))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authFile).To(BeEmpty())
+	})
+
+	It("mergePullSecrets should fail on an invalid pull secret", func() {
+		dir := filepath.Join(tmpDir, "pull-secrets")
+		writePullSecret(dir, "0", "invalid")
+		_, err := mergePullSecrets(dir, filepath.Join(tmpDir, authFileName))
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("TransferFile should not be called", func() {
-		ds = NewRegistryDataSource("", "", "", "", true)
+		ds = NewRegistryDataSource("", "", "", "", "", "", nil, nil, image.ArtifactSelector{}, true)
 		result, err := ds.TransferFile("file")
 		Expect(err).To(HaveOccurred())
 		Expect(ProcessingPhaseError).To(Equal(result))
@@ -184,7 +305,7 @@ func NewFakeSkopeoOperations(ep, accKey, secKey, certDir string, insecureRegistr
 	}
 }
 
-func (o *fakeSkopeoOperations) CopyImage(url, dest, accessKey, secKey, certDir string, insecureRegistry bool) error {
+func (o *fakeSkopeoOperations) CopyImage(url, dest, accessKey, secKey, authFile, certDir, arch string, insecureRegistry bool) error {
 	if o.e1 != nil {
 		return o.e1
 	}
@@ -198,5 +319,64 @@ func (o *fakeSkopeoOperations) CopyImage(url, dest, accessKey, secKey, certDir s
 	Expect(o.secKey).To(Equal(secKey))
 	Expect(o.certDir).To(Equal(certDir))
 	Expect(o.insecureRegistry).To(Equal(insecureRegistry))
+	Expect(arch).To(Equal("arm64"))
 	return nil
 }
+
+func (o *fakeSkopeoOperations) InspectRawManifest(url, accessKey, secKey, authFile, certDir string, insecureRegistry bool) ([]byte, error) {
+	if o.e1 != nil {
+		return nil, o.e1
+	}
+	return []byte(`{"schemaVersion": 2, "layers": []}`), nil
+}
+
+func (o *fakeSkopeoOperations) PushImage(src, url, accessKey, secKey, certDir string, insecureRegistry bool) error {
+	return o.e1
+}
+
+func writeImageArchive(file string, names ...string) {
+	Expect(os.MkdirAll(filepath.Dir(file), os.ModePerm)).To(Succeed())
+	f, err := os.Create(file)
+	Expect(err).NotTo(HaveOccurred())
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, name := range names {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 2})).To(Succeed())
+		_, err = tw.Write([]byte("{}"))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+}
+
+func writePullSecret(dir, name, config string) {
+	Expect(os.MkdirAll(filepath.Join(dir, name), os.ModePerm)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, name, pullSecretFile), []byte(config), 0600)).To(Succeed())
+}
+
+// recordingSkopeoOperations records the location and credentials of every copy, and only succeeds to copy from succeed
+// using the pull secrets
+type recordingSkopeoOperations struct {
+	succeed  string
+	calls    []string
+	insecure []string
+}
+
+func (o *recordingSkopeoOperations) CopyImage(url, dest, accessKey, secKey, authFile, certDir, arch string, insecureRegistry bool) error {
+	o.calls = append(o.calls, url+" "+accessKey+" "+authFile)
+	if insecureRegistry {
+		o.insecure = append(o.insecure, url)
+	}
+	if url != o.succeed || authFile == "" {
+		return errors.New("unauthorized")
+	}
+	dest = strings.Replace(filepath.Dir(dest), "dir:", "", 1)
+	return util.UnArchiveLocalTar(imageFile, dest)
+}
+
+func (o *recordingSkopeoOperations) PushImage(src, url, accessKey, secKey, certDir string, insecureRegistry bool) error {
+	return nil
+}
+
+func (o *recordingSkopeoOperations) InspectRawManifest(url, accessKey, secKey, authFile, certDir string, insecureRegistry bool) ([]byte, error) {
+	return []byte(`{"schemaVersion": 2, "layers": []}`), nil
+}
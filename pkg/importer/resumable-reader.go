@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+)
+
+// resumeMaxRetries is the number of times a transfer is resumed without making progress before giving up
+const resumeMaxRetries = 5
+
+// resumeRetryDelay is the time to wait before resuming an interrupted transfer the first time, it doubles with every
+// attempt that makes no progress. Replaced in tests.
+var resumeRetryDelay = 5 * time.Second
+
+// resumableReader reads size bytes from the streams returned by open. When a stream is interrupted, a new one is
+// opened at the current offset, so that a dropped connection does not restart the whole transfer.
+type resumableReader struct {
+	size    uint64
+	offset  uint64
+	open    func(offset uint64) (io.ReadCloser, error)
+	current io.ReadCloser
+	// retries counts the interruptions since the last progress
+	retries    int
+	lastFailed uint64
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		if r.offset >= r.size {
+			return 0, io.EOF
+		}
+		if r.current == nil {
+			stream, err := r.open(r.offset)
+			if err != nil {
+				if err = r.retry(err); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			r.current = stream
+		}
+		n, err := r.current.Read(p)
+		r.offset += uint64(n)
+		if err == nil {
+			return n, nil
+		}
+		closeErr := r.current.Close()
+		r.current = nil
+		if r.offset >= r.size {
+			return n, nil
+		}
+		if err == io.EOF && closeErr == nil {
+			return n, errors.Errorf("the disk ended at byte %d, expected %d bytes", r.offset, r.size)
+		}
+		if closeErr != nil {
+			err = closeErr
+		}
+		if err = r.retry(err); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// retry counts the interruption of the transfer at the current offset, and waits before the transfer is resumed.
+// It returns an error once resumeMaxRetries attempts in a row made no progress.
+func (r *resumableReader) retry(err error) error {
+	if r.offset > r.lastFailed {
+		r.retries = 0
+	}
+	r.lastFailed = r.offset
+	r.retries++
+	if r.retries > resumeMaxRetries {
+		return errors.Wrapf(err, "transfer failed at byte %d of %d after %d attempts", r.offset, r.size, resumeMaxRetries)
+	}
+	klog.Warningf("Transfer interrupted at byte %d of %d, resuming: %v", r.offset, r.size, err)
+	time.Sleep(resumeRetryDelay << uint(r.retries-1))
+	return nil
+}
+
+// Close stops the current stream.
+func (r *resumableReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
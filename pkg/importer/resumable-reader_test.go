@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+)
+
+// flakyStream reports a dropped connection on close when fail is set
+type flakyStream struct {
+	io.Reader
+	fail bool
+}
+
+func (f *flakyStream) Close() error {
+	if f.fail {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+var _ = Describe("Resumable reader", func() {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	BeforeEach(func() {
+		resumeRetryDelay = time.Duration(0)
+	})
+
+	It("Should resume an interrupted transfer at the current offset", func() {
+		var offsets []uint64
+		r := &resumableReader{
+			size: uint64(len(data)),
+			open: func(offset uint64) (io.ReadCloser, error) {
+				offsets = append(offsets, offset)
+				end := offset + 10
+				if end >= uint64(len(data)) {
+					return &flakyStream{Reader: bytes.NewReader(data[offset:])}, nil
+				}
+				return &flakyStream{Reader: bytes.NewReader(data[offset:end]), fail: true}, nil
+			},
+		}
+		Expect(ioutil.ReadAll(r)).To(Equal(data))
+		Expect(offsets).To(Equal([]uint64{0, 10, 20, 30}))
+	})
+
+	It("Should give up when resuming makes no progress", func() {
+		attempts := 0
+		r := &resumableReader{
+			size: uint64(len(data)),
+			open: func(offset uint64) (io.ReadCloser, error) {
+				attempts++
+				if offset == 0 {
+					return &flakyStream{Reader: bytes.NewReader(data[:5]), fail: true}, nil
+				}
+				return &flakyStream{Reader: bytes.NewReader(nil), fail: true}, nil
+			},
+		}
+		_, err := ioutil.ReadAll(r)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("transfer failed at byte 5 of 36"))
+		Expect(attempts).To(Equal(resumeMaxRetries + 1))
+	})
+
+	It("Should retry when the stream cannot be opened", func() {
+		attempts := 0
+		r := &resumableReader{
+			size: uint64(len(data)),
+			open: func(offset uint64) (io.ReadCloser, error) {
+				attempts++
+				if attempts < resumeMaxRetries {
+					return nil, errors.New("connection refused")
+				}
+				return &flakyStream{Reader: bytes.NewReader(data[offset:])}, nil
+			},
+		}
+		Expect(ioutil.ReadAll(r)).To(Equal(data))
+		Expect(attempts).To(Equal(resumeMaxRetries))
+	})
+
+	It("Should give up when the stream cannot be opened", func() {
+		attempts := 0
+		r := &resumableReader{
+			size: uint64(len(data)),
+			open: func(offset uint64) (io.ReadCloser, error) {
+				attempts++
+				return nil, errors.New("connection refused")
+			},
+		}
+		_, err := ioutil.ReadAll(r)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("transfer failed at byte 0 of 36"))
+		Expect(err.Error()).To(ContainSubstring("connection refused"))
+		Expect(attempts).To(Equal(resumeMaxRetries + 1))
+	})
+
+	It("Should fail if the disk is shorter than expected", func() {
+		r := &resumableReader{
+			size: uint64(len(data)) + 1,
+			open: func(offset uint64) (io.ReadCloser, error) {
+				return &flakyStream{Reader: bytes.NewReader(data[offset:])}, nil
+			},
+		}
+		_, err := ioutil.ReadAll(r)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("the disk ended at byte 36, expected 37 bytes"))
+	})
+})
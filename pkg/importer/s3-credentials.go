@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/pkg/credentials"
+	"github.com/pkg/errors"
+)
+
+const (
+	// awsRoleARNEnv and awsWebIdentityTokenFileEnv are set in the importer pod by cloud workload identity
+	// webhooks, like IAM roles for service accounts, for the service account the pod runs as
+	awsRoleARNEnv              = "AWS_ROLE_ARN"
+	awsWebIdentityTokenFileEnv = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	awsRoleSessionNameEnv      = "AWS_ROLE_SESSION_NAME"
+
+	defaultSTSEndpoint     = "https://sts.amazonaws.com"
+	defaultRoleSessionName = "cdi-importer"
+)
+
+// may be overridden in tests
+var stsEndpoint = defaultSTSEndpoint
+
+// webIdentity retrieves temporary S3 credentials by exchanging the projected service account token of the
+// importer pod for the role set in its environment.
+type webIdentity struct {
+	credentials.Expiry
+	client *http.Client
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+// Retrieve calls AssumeRoleWithWebIdentity with the token in the file AWS_WEB_IDENTITY_TOKEN_FILE
+func (w *webIdentity) Retrieve() (credentials.Value, error) {
+	roleARN := os.Getenv(awsRoleARNEnv)
+	tokenFile := os.Getenv(awsWebIdentityTokenFileEnv)
+	if roleARN == "" || tokenFile == "" {
+		return credentials.Value{}, errors.Errorf("%s and %s are not set", awsRoleARNEnv, awsWebIdentityTokenFileEnv)
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "could not read web identity token")
+	}
+	sessionName := os.Getenv(awsRoleSessionNameEnv)
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+
+	v := url.Values{}
+	v.Set("Action", "AssumeRoleWithWebIdentity")
+	v.Set("Version", "2011-06-15")
+	v.Set("RoleArn", roleARN)
+	v.Set("RoleSessionName", sessionName)
+	v.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	resp, err := w.client.PostForm(stsEndpoint, v)
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "could not assume role with web identity")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return credentials.Value{}, errors.Errorf("could not assume role %s with web identity: %s", roleARN, resp.Status)
+	}
+	result := &assumeRoleWithWebIdentityResponse{}
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return credentials.Value{}, errors.Wrap(err, "could not decode web identity credentials")
+	}
+	creds := result.Result.Credentials
+	w.SetExpiration(creds.Expiration, credentials.DefaultExpiryWindow)
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// newAmbientCredentials returns the credentials of the importer pod itself, used when the import has no
+// S3 secret: the AWS environment variables, or the web identity of the service account the pod runs as.
+// Without either the import is anonymous.
+func newAmbientCredentials() *credentials.Credentials {
+	return credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&webIdentity{client: &http.Client{Transport: http.DefaultTransport}},
+	})
+}
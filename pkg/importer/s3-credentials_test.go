@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const stsResponse = `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAEXAMPLE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`
+
+var _ = Describe("Web identity S3 credentials", func() {
+	var (
+		tmpDir string
+		ts     *httptest.Server
+		form   map[string]string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "web-identity")
+		Expect(err).NotTo(HaveOccurred())
+		form = map[string]string{}
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			for key := range r.PostForm {
+				form[key] = r.PostForm.Get(key)
+			}
+			if form["WebIdentityToken"] != "projected-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, stsResponse)
+		}))
+		stsEndpoint = ts.URL
+	})
+
+	AfterEach(func() {
+		stsEndpoint = defaultSTSEndpoint
+		ts.Close()
+		os.RemoveAll(tmpDir)
+		os.Unsetenv(awsRoleARNEnv)
+		os.Unsetenv(awsWebIdentityTokenFileEnv)
+	})
+
+	It("Should exchange the projected token for credentials", func() {
+		tokenFile := filepath.Join(tmpDir, "token")
+		Expect(ioutil.WriteFile(tokenFile, []byte("projected-token\n"), 0600)).To(Succeed())
+		os.Setenv(awsRoleARNEnv, "arn:aws:iam::123456789012:role/importer")
+		os.Setenv(awsWebIdentityTokenFileEnv, tokenFile)
+
+		w := &webIdentity{client: http.DefaultClient}
+		value, err := w.Retrieve()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.AccessKeyID).To(Equal("ASIAEXAMPLE"))
+		Expect(value.SecretAccessKey).To(Equal("secret"))
+		Expect(value.SessionToken).To(Equal("session"))
+		Expect(w.IsExpired()).To(BeFalse())
+		Expect(form["RoleArn"]).To(Equal("arn:aws:iam::123456789012:role/importer"))
+		Expect(form["RoleSessionName"]).To(Equal(defaultRoleSessionName))
+	})
+
+	It("Should fail without a role in the environment", func() {
+		w := &webIdentity{client: http.DefaultClient}
+		_, err := w.Retrieve()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should fail if the role is not granted", func() {
+		tokenFile := filepath.Join(tmpDir, "token")
+		Expect(ioutil.WriteFile(tokenFile, []byte("other-token"), 0600)).To(Succeed())
+		os.Setenv(awsRoleARNEnv, "arn:aws:iam::123456789012:role/importer")
+		os.Setenv(awsWebIdentityTokenFileEnv, tokenFile)
+
+		w := &webIdentity{client: http.DefaultClient}
+		_, err := w.Retrieve()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("403"))
+	})
+})
@@ -3,6 +3,7 @@ package importer
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"path/filepath"
 	"strings"
@@ -45,7 +46,7 @@ type S3DataSource struct {
 }
 
 // NewS3DataSource creates a new instance of the S3DataSource
-func NewS3DataSource(endpoint, accessKey, secKey string) (*S3DataSource, error) {
+func NewS3DataSource(endpoint, accessKey, secKey, digest string) (*S3DataSource, error) {
 	ep, err := ParseEndpoint(endpoint)
 	if err != nil {
 		return nil, errors.Wrapf(err, fmt.Sprintf("unable to parse endpoint %q", endpoint))
@@ -54,6 +55,11 @@ func NewS3DataSource(endpoint, accessKey, secKey string) (*S3DataSource, error)
 	if err != nil {
 		return nil, err
 	}
+	if digest != "" {
+		if s3Reader, err = NewDigestReader(s3Reader, digest); err != nil {
+			return nil, err
+		}
+	}
 	return &S3DataSource{
 		ep:        ep,
 		accessKey: accessKey,
@@ -89,6 +95,9 @@ func (sd *S3DataSource) Transfer(path string) (ProcessingPhase, error) {
 	if err != nil {
 		return ProcessingPhaseError, err
 	}
+	if err := verifyDigest(sd.s3Reader); err != nil {
+		return ProcessingPhaseError, err
+	}
 	// If streaming succeeded, then parsing the file into URL will also succeed, no need to check error status
 	sd.url, _ = url.Parse(file)
 	return ProcessingPhaseProcess, nil
@@ -100,6 +109,9 @@ func (sd *S3DataSource) TransferFile(fileName string) (ProcessingPhase, error) {
 	if err != nil {
 		return ProcessingPhaseError, err
 	}
+	if err := verifyDigest(sd.s3Reader); err != nil {
+		return ProcessingPhaseError, err
+	}
 	return ProcessingPhaseResize, nil
 }
 
@@ -139,5 +151,18 @@ func createS3Reader(ep *url.URL, accessKey, secKey string) (io.ReadCloser, error
 }
 
 func getS3Client(accessKey, secKey string, secure bool) (S3Client, error) {
-	return minio.NewV4(common.ImporterS3Host, accessKey, secKey, secure)
+	var client *minio.Client
+	var err error
+	if accessKey == "" && secKey == "" {
+		klog.V(2).Infoln("No S3 secret, using the credentials of the importer pod")
+		client, err = minio.NewWithCredentials(common.ImporterS3Host, newAmbientCredentials(), secure, "")
+	} else {
+		client, err = minio.NewV4(common.ImporterS3Host, accessKey, secKey, secure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The User-Agent is not signed, the transport may replace it
+	client.SetCustomTransport(newIdentifyingTransport(http.DefaultTransport))
+	return client, nil
 }
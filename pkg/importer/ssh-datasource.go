@@ -0,0 +1,317 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+const (
+	// sshPrivateKey is the key of the private key in a kubernetes.io/ssh-auth secret
+	sshPrivateKey = "ssh-privatekey"
+	// sshKnownHosts is the optional key holding the known_hosts of the remote host
+	sshKnownHosts = "known_hosts"
+)
+
+// sshCommand creates the ssh process, replaced in tests.
+var sshCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+// SSHDataSource is the data provider for files and block devices read over ssh.
+// Sequence of phases:
+// 1a. Info -> TransferScratch if the disk needs to be converted by QEMU-IMG (QCOW2)
+// 1b. Info -> TransferDataFile in all other cases, the data is streamed to the target.
+// 2. Transfer -> Process
+// 3. Process -> Convert, after fetching the backing files of the image
+type SSHDataSource struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// the resumable reader of the remote disk
+	diskReader *resumableReader
+	// stack of readers
+	readers *FormatReaders
+	// url the url to report to the caller of getURL, a file in scratch space.
+	url *url.URL
+	// keyDir is the private copy of the ssh key, removed on close
+	keyDir string
+	// the url of the disk, and the ssh arguments to reach its host
+	endpoint *url.URL
+	args     []string
+	// the url prefixes backing files may be fetched from
+	backingFileURLs []string
+}
+
+// NewSSHDataSource creates a new instance of the ssh data provider. secretDir is the directory containing
+// the mounted ssh-auth secret. The key of the host is only left unverified when the secret has no known_hosts and
+// insecureSkipHostKeyCheck is set.
+func NewSSHDataSource(endpoint, secretDir string, backingFileURLs []string, insecureSkipHostKeyCheck bool) (*SSHDataSource, error) {
+	ep, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, fmt.Sprintf("unable to parse endpoint %q", endpoint))
+	}
+	if ep.Scheme != "ssh" || ep.Host == "" || ep.Path == "" {
+		return nil, errors.Errorf("invalid ssh url %q, expected ssh://[user@]host[:port]/path", endpoint)
+	}
+	keyDir, err := ioutil.TempDir("", "ssh-key")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create ssh key directory")
+	}
+	args, err := sshArgs(ep, secretDir, keyDir, insecureSkipHostKeyCheck)
+	if err != nil {
+		os.RemoveAll(keyDir)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	size, err := remoteDiskSize(ctx, args, ep.Path)
+	if err != nil {
+		cancel()
+		os.RemoveAll(keyDir)
+		return nil, err
+	}
+	klog.V(1).Infof("Reading %d bytes from %s over ssh", size, ep.Path)
+	return &SSHDataSource{
+		ctx:    ctx,
+		cancel: cancel,
+		diskReader: &resumableReader{
+			size: size,
+			open: func(offset uint64) (io.ReadCloser, error) {
+				return startSSHStream(ctx, args, ddCommand(ep.Path, offset))
+			},
+		},
+		keyDir:          keyDir,
+		endpoint:        ep,
+		args:            args,
+		backingFileURLs: backingFileURLs,
+	}, nil
+}
+
+// Info is called to get initial information about the data.
+func (sd *SSHDataSource) Info() (ProcessingPhase, error) {
+	var err error
+	sd.readers, err = NewFormatReaders(sd.diskReader, sd.diskReader.size)
+	if err != nil {
+		klog.Errorf("Error creating readers: %v", err)
+		return ProcessingPhaseError, err
+	}
+	if !sd.readers.Convert {
+		return ProcessingPhaseTransferDataFile, nil
+	}
+	return ProcessingPhaseTransferScratch, nil
+}
+
+// Transfer is called to transfer the data from the source to a scratch location.
+func (sd *SSHDataSource) Transfer(path string) (ProcessingPhase, error) {
+	if util.GetAvailableSpace(path) <= int64(0) {
+		//Path provided is invalid.
+		return ProcessingPhaseError, ErrInvalidPath
+	}
+	file := filepath.Join(path, tempFile)
+	err := util.StreamDataToFile(sd.readers.TopReader(), file)
+	if err != nil {
+		return ProcessingPhaseError, err
+	}
+	// If we successfully wrote to the file, then the parse will succeed.
+	sd.url, _ = url.Parse(file)
+	return ProcessingPhaseProcess, nil
+}
+
+// TransferFile is called to transfer the data from the source to the passed in file.
+func (sd *SSHDataSource) TransferFile(fileName string) (ProcessingPhase, error) {
+	sd.readers.StartProgressUpdate()
+	err := util.StreamDataToFile(sd.readers.TopReader(), fileName)
+	if err != nil {
+		return ProcessingPhaseError, err
+	}
+	return ProcessingPhaseResize, nil
+}
+
+// Process is called to do any special processing before giving the URI to the data back to the processor
+func (sd *SSHDataSource) Process() (ProcessingPhase, error) {
+	if len(sd.backingFileURLs) > 0 {
+		if err := fetchBackingChain(sd.url.Path, sd.endpoint, sd.backingFileURLs, sd.fetchBackingFile); err != nil {
+			return ProcessingPhaseError, err
+		}
+	}
+	return ProcessingPhaseConvert, nil
+}
+
+// fetchBackingFile copies a backing file of the disk, it has to be on the same host as the disk.
+func (sd *SSHDataSource) fetchBackingFile(u *url.URL, dest string) error {
+	if u.Host != sd.endpoint.Host || u.User.Username() != sd.endpoint.User.Username() {
+		return errors.Errorf("backing file %s is not on the host of %s", u, sd.endpoint)
+	}
+	size, err := remoteDiskSize(sd.ctx, sd.args, u.Path)
+	if err != nil {
+		return err
+	}
+	reader := &resumableReader{
+		size: size,
+		open: func(offset uint64) (io.ReadCloser, error) {
+			return startSSHStream(sd.ctx, sd.args, ddCommand(u.Path, offset))
+		},
+	}
+	defer reader.Close()
+	return util.StreamDataToFile(reader, dest)
+}
+
+// GetURL returns the URI that the data processor can use when converting the data.
+func (sd *SSHDataSource) GetURL() *url.URL {
+	return sd.url
+}
+
+// Close all readers, stop the ssh process and remove the copy of the key.
+func (sd *SSHDataSource) Close() error {
+	// Stop the ssh process first, an unfinished stream would otherwise block closing the readers
+	sd.cancel()
+	var err error
+	if sd.readers != nil {
+		err = sd.readers.Close()
+	} else {
+		err = sd.diskReader.Close()
+	}
+	os.RemoveAll(sd.keyDir)
+	return err
+}
+
+// sshArgs returns the ssh options and destination to reach the host of the url. The private key is copied
+// to keyDir, ssh refuses keys that other users can read and secret volumes are group readable.
+func sshArgs(ep *url.URL, secretDir, keyDir string, insecureSkipHostKeyCheck bool) ([]string, error) {
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=30",
+		// Detect dead connections, so that the transfer can be resumed
+		"-o", "ServerAliveInterval=15",
+		"-o", "ServerAliveCountMax=4",
+	}
+	if secretDir != "" {
+		key, err := ioutil.ReadFile(filepath.Join(secretDir, sshPrivateKey))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read %s from the ssh secret", sshPrivateKey)
+		}
+		// OpenSSH does not load keys missing the final newline, which is easily lost when creating the secret
+		if !bytes.HasSuffix(key, []byte("\n")) {
+			key = append(key, '\n')
+		}
+		keyFile := filepath.Join(keyDir, "id")
+		if err = ioutil.WriteFile(keyFile, key, 0600); err != nil {
+			return nil, errors.Wrap(err, "could not copy the ssh key")
+		}
+		args = append(args, "-i", keyFile, "-o", "IdentitiesOnly=yes")
+	}
+	knownHosts := filepath.Join(secretDir, sshKnownHosts)
+	if _, err := os.Stat(knownHosts); secretDir != "" && err == nil {
+		args = append(args, "-o", "UserKnownHostsFile="+knownHosts, "-o", "StrictHostKeyChecking=yes")
+	} else if insecureSkipHostKeyCheck {
+		// Without a known_hosts there is nothing to check the host key against
+		klog.Warningf("The ssh secret has no %s, the key of %s is not verified", sshKnownHosts, ep.Hostname())
+		args = append(args, "-o", "UserKnownHostsFile=/dev/null", "-o", "StrictHostKeyChecking=no")
+	} else {
+		return nil, errors.Errorf("the ssh secret has no %s to verify the key of %s, add it or set insecureSkipHostKeyCheck", sshKnownHosts, ep.Hostname())
+	}
+	if ep.Port() != "" {
+		args = append(args, "-p", ep.Port())
+	}
+	if ep.User != nil && ep.User.Username() != "" {
+		args = append(args, "-l", ep.User.Username())
+	}
+	// The host ends the options, it cannot be mistaken for one
+	return append(args, "--", ep.Hostname()), nil
+}
+
+// shellQuote quotes s for the remote shell ssh runs the command with
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// ddCommand returns the remote command writing the disk to stdout from offset on
+func ddCommand(path string, offset uint64) string {
+	return fmt.Sprintf("dd if=%s bs=1M iflag=skip_bytes skip=%d status=none", shellQuote(path), offset)
+}
+
+// remoteDiskSize returns the size of the file or block device at path on the remote host
+func remoteDiskSize(ctx context.Context, args []string, path string) (uint64, error) {
+	p := shellQuote(path)
+	cmd := fmt.Sprintf("if [ -b %s ]; then blockdev --getsize64 %s; else stat -L -c %%s %s; fi", p, p, p)
+	stream, err := startSSHStream(ctx, args, cmd)
+	if err != nil {
+		return 0, err
+	}
+	out, err := ioutil.ReadAll(stream)
+	if closeErr := stream.Close(); closeErr != nil {
+		return 0, errors.Wrapf(closeErr, "could not get the size of %s", path)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get the size of %s", path)
+	}
+	size, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse the size of %s", path)
+	}
+	if size == 0 {
+		return 0, errors.Errorf("%s is empty", path)
+	}
+	return size, nil
+}
+
+// sshStream is the output of a command run over ssh
+type sshStream struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func startSSHStream(ctx context.Context, args []string, command string) (*sshStream, error) {
+	cmd := sshCommand(ctx, append(args, command)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create ssh pipe")
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	klog.V(3).Infof("Running %q over ssh", command)
+	if err = cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "could not start ssh")
+	}
+	return &sshStream{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// Close waits for the command, its error contains what ssh or the remote command printed.
+func (s *sshStream) Close() error {
+	s.ReadCloser.Close()
+	if err := s.cmd.Wait(); err != nil {
+		return errors.Wrapf(err, "ssh failed: %s", strings.TrimSpace(s.stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// runLocally replaces ssh, it runs the remote command on the local host
+func runLocally(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", args[len(args)-1])
+}
+
+var _ = Describe("SSH data source", func() {
+	var (
+		tmpDir    string
+		secretDir string
+		sd        *SSHDataSource
+		err       error
+	)
+
+	BeforeEach(func() {
+		sshCommand = runLocally
+		resumeRetryDelay = 0
+		sd = nil
+		tmpDir, err = ioutil.TempDir("", "ssh-test")
+		Expect(err).NotTo(HaveOccurred())
+		secretDir = filepath.Join(tmpDir, "secret")
+		Expect(os.Mkdir(secretDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(secretDir, sshPrivateKey), []byte("private key"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(secretDir, sshKnownHosts), []byte("host ssh-ed25519 AAAA"), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if sd != nil {
+			sd.Close()
+		}
+		os.RemoveAll(tmpDir)
+	})
+
+	It("Should stream a raw disk to the target", func() {
+		sd, err = NewSSHDataSource("ssh://root@host"+tinyCoreFilePath, secretDir, nil, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sd.Info()).To(Equal(ProcessingPhaseTransferDataFile))
+		target := filepath.Join(tmpDir, "disk.img")
+		Expect(sd.TransferFile(target)).To(Equal(ProcessingPhaseResize))
+		expected, err := ioutil.ReadFile(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadFile(target)).To(Equal(expected))
+	})
+
+	It("Should download a qcow2 disk to scratch space", func() {
+		sd, err = NewSSHDataSource("ssh://host"+cirrosFilePath, secretDir, nil, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sd.Info()).To(Equal(ProcessingPhaseTransferScratch))
+		Expect(sd.Transfer(tmpDir)).To(Equal(ProcessingPhaseProcess))
+		Expect(sd.GetURL().String()).To(Equal(filepath.Join(tmpDir, tempFile)))
+	})
+
+	It("Should fail if the disk does not exist", func() {
+		_, err = NewSSHDataSource("ssh://host"+filepath.Join(tmpDir, "missing"), secretDir, nil, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("could not get the size of"))
+	})
+
+	It("Should reject urls that are not ssh urls", func() {
+		_, err = NewSSHDataSource("http://host/disk.img", secretDir, nil, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid ssh url"))
+	})
+
+	It("Should log in with a private copy of the key and check the host key", func() {
+		ep, _ := url.Parse("ssh://admin@host:2222/dev/vg/disk")
+		keyDir := filepath.Join(tmpDir, "key")
+		Expect(os.Mkdir(keyDir, 0700)).To(Succeed())
+		args, err := sshArgs(ep, secretDir, keyDir, true)
+		Expect(err).NotTo(HaveOccurred())
+		cmdline := strings.Join(args, " ")
+		Expect(cmdline).To(ContainSubstring("-i " + filepath.Join(keyDir, "id")))
+		Expect(cmdline).To(ContainSubstring("UserKnownHostsFile=" + filepath.Join(secretDir, sshKnownHosts)))
+		Expect(cmdline).To(ContainSubstring("StrictHostKeyChecking=yes"))
+		Expect(cmdline).To(HaveSuffix("-p 2222 -l admin -- host"))
+		key, err := ioutil.ReadFile(filepath.Join(keyDir, "id"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(key)).To(Equal("private key\n"))
+		info, err := os.Stat(filepath.Join(keyDir, "id"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("Should refuse to connect without known_hosts", func() {
+		Expect(os.Remove(filepath.Join(secretDir, sshKnownHosts))).To(Succeed())
+		ep, _ := url.Parse("ssh://host/disk.img")
+		_, err := sshArgs(ep, secretDir, tmpDir, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("the ssh secret has no known_hosts"))
+	})
+
+	It("Should only skip the host key check without known_hosts when asked to", func() {
+		Expect(os.Remove(filepath.Join(secretDir, sshKnownHosts))).To(Succeed())
+		ep, _ := url.Parse("ssh://host/disk.img")
+		args, err := sshArgs(ep, secretDir, tmpDir, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Join(args, " ")).To(ContainSubstring("StrictHostKeyChecking=no"))
+	})
+
+	It("Should quote the path for the remote shell", func() {
+		Expect(ddCommand("/var/lib/it's here.img", 42)).To(Equal(`dd if='/var/lib/it'\''s here.img' bs=1M iflag=skip_bytes skip=42 status=none`))
+	})
+})
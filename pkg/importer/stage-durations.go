@@ -0,0 +1,66 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// nowFunc is used to time the processing phases, may be overridden in tests
+var nowFunc = time.Now
+
+// StageDurations are how long the stages of processing data took
+type StageDurations struct {
+	// Download is how long the data source took to transfer the data
+	Download time.Duration
+	// Convert is how long extracting the data and converting the image took
+	Convert time.Duration
+	// Resize is how long resizing the image took
+	Resize time.Duration
+}
+
+// add counts that phase took duration to the stage it belongs to, the Info phase belongs to none
+func (s *StageDurations) add(phase ProcessingPhase, duration time.Duration) {
+	switch phase {
+	case ProcessingPhaseTransferScratch, ProcessingPhaseTransferDataDir, ProcessingPhaseTransferDataFile:
+		s.Download += duration
+	case ProcessingPhaseProcess, ProcessingPhaseConvert:
+		s.Convert += duration
+	case ProcessingPhaseResize:
+		s.Resize += duration
+	}
+}
+
+// String describes the stages that took time, like "download=1m4s,convert=12.5s"
+func (s StageDurations) String() string {
+	var stages []string
+	for _, stage := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"download", s.Download},
+		{"convert", s.Convert},
+		{"resize", s.Resize},
+	} {
+		if stage.duration > 0 {
+			stages = append(stages, fmt.Sprintf("%s=%s", stage.name, stage.duration.Round(time.Millisecond)))
+		}
+	}
+	return strings.Join(stages, ",")
+}
@@ -0,0 +1,41 @@
+package importer
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stage durations", func() {
+	AfterEach(func() {
+		nowFunc = time.Now
+	})
+
+	table.DescribeTable("Should describe the stages that took time", func(stages StageDurations, expected string) {
+		Expect(stages.String()).To(Equal(expected))
+	},
+		table.Entry("with no stages", StageDurations{}, ""),
+		table.Entry("with all stages", StageDurations{Download: time.Minute + 4*time.Second, Convert: 12500 * time.Millisecond, Resize: time.Millisecond},
+			"download=1m4s,convert=12.5s,resize=1ms"),
+		table.Entry("without the stages that took no time", StageDurations{Convert: 3 * time.Second}, "convert=3s"),
+		table.Entry("rounded to milliseconds", StageDurations{Download: 1500 * time.Microsecond}, "download=2ms"),
+	)
+
+	It("Should time the stages of processing", func() {
+		now := time.Now()
+		nowFunc = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+		mdp := &MockDataProvider{
+			infoResponse:     ProcessingPhaseTransferScratch,
+			transferResponse: ProcessingPhaseProcess,
+			processResponse:  ProcessingPhaseComplete,
+		}
+		dp := NewDataProcessor(mdp, "dest", "dataDir", "scratchDataDir", "1G")
+		Expect(dp.ProcessData()).To(Succeed())
+		Expect(dp.StageDurations()).To(Equal(StageDurations{Download: time.Second, Convert: time.Second}))
+	})
+})
@@ -0,0 +1,484 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"k8s.io/klog"
+)
+
+const (
+	// checkpointBufferSize is how much is copied at once by a checkpointed transfer
+	checkpointBufferSize = 1024 * 1024
+
+	// tarBlockSize is the size of the headers and the data blocks of a tar archive
+	tarBlockSize = 512
+
+	// tarReadAhead is how much data tar may have read from the pipe, without having written it yet
+	tarReadAhead = 1024 * 1024
+)
+
+// checkpointInterval is how often a transfer records how far it got, may be overridden in tests
+var checkpointInterval = 30 * time.Second
+
+// transferCheckpoint records how far the transfer of a source to the target got, the importer pod that replaces an
+// evicted one resumes the transfer there instead of transferring everything again.
+type transferCheckpoint struct {
+	// Source identifies the data, it is a hash of the url and the validators of the source
+	Source string `json:"source"`
+	// Size is the size of the data
+	Size uint64 `json:"size"`
+	// Offset is where the transfer resumes, the data before it is on the target
+	Offset uint64 `json:"offset"`
+}
+
+// newTransferCheckpoint returns the checkpoint at the start of the data of size at the url, the validator tells if
+// the data changed since the checkpoint. The credentials in the url are not part of the identity of the data.
+func newTransferCheckpoint(u string, validator string, size uint64) *transferCheckpoint {
+	sum := sha256.Sum256([]byte(u + "\n" + validator))
+	return &transferCheckpoint{
+		Source: hex.EncodeToString(sum[:]),
+		Size:   size,
+	}
+}
+
+// resumes returns true if the transfer of the checkpoint resumes the transfer of the same data as cp
+func (cp *transferCheckpoint) resumes(other *transferCheckpoint) bool {
+	return other != nil && cp.Source == other.Source && cp.Size == other.Size && cp.Offset > 0 && cp.Offset < cp.Size
+}
+
+// checkpointStore keeps the checkpoint of the transfer to a target.
+type checkpointStore interface {
+	// load returns the checkpoint that was saved, nil if there is none
+	load() (*transferCheckpoint, error)
+	// save replaces the checkpoint with cp
+	save(cp *transferCheckpoint) error
+	// clear removes the checkpoint once the transfer completed
+	clear() error
+}
+
+// newCheckpointStore returns the store of the checkpoints of the transfers to target. The checkpoint of a file is kept
+// next to it, the one of a directory in it, the one of a block device in the sector that holds the partial write
+// marker.
+func newCheckpointStore(target string) checkpointStore {
+	if isBlockDeviceFunc(target) {
+		return &blockCheckpointStore{device: target}
+	}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		return &fileCheckpointStore{path: filepath.Join(target, ".cdi-archive.checkpoint")}
+	}
+	return &fileCheckpointStore{path: filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".checkpoint")}
+}
+
+// fileCheckpointStore keeps the checkpoint in a file.
+type fileCheckpointStore struct {
+	path string
+}
+
+func (s *fileCheckpointStore) load() (*transferCheckpoint, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the checkpoint %s", s.path)
+	}
+	cp := &transferCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, errors.Wrapf(err, "could not parse the checkpoint %s", s.path)
+	}
+	return cp, nil
+}
+
+func (s *fileCheckpointStore) save(cp *transferCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	// Replaced in one step, so an eviction while it is written leaves the earlier checkpoint
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not create the checkpoint %s", tmp)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "could not write the checkpoint %s", tmp)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "could not write the checkpoint %s", tmp)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "could not write the checkpoint %s", tmp)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errors.Wrapf(err, "could not replace the checkpoint %s", s.path)
+	}
+	return syncDir(filepath.Dir(s.path))
+}
+
+func (s *fileCheckpointStore) clear() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove the checkpoint %s", s.path)
+	}
+	return nil
+}
+
+// blockCheckpointStore keeps the checkpoint after the partial write marker in the last sector of a block device.
+// The data overwrites the sector if it reaches the end of the device, the transfer is not checkpointed after that.
+type blockCheckpointStore struct {
+	device string
+}
+
+func (s *blockCheckpointStore) load() (*transferCheckpoint, error) {
+	f, sector, _, err := s.readSector(os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if !bytes.HasPrefix(sector, partialWriteMarker) {
+		return nil, nil
+	}
+	data := sector[len(partialWriteMarker):]
+	if end := bytes.IndexByte(data, '\n'); end >= 0 {
+		data = data[:end]
+	} else {
+		return nil, nil
+	}
+	cp := &transferCheckpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, errors.Wrapf(err, "could not parse the checkpoint on %s", s.device)
+	}
+	return cp, nil
+}
+
+func (s *blockCheckpointStore) save(cp *transferCheckpoint) error {
+	return s.write(cp)
+}
+
+func (s *blockCheckpointStore) clear() error {
+	return s.write(nil)
+}
+
+// write replaces the checkpoint after the partial write marker with cp, or removes it if cp is nil. The sector is left
+// as it is once the data overwrote the marker.
+func (s *blockCheckpointStore) write(cp *transferCheckpoint) error {
+	f, sector, offset, err := s.readSector(os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !bytes.HasPrefix(sector, partialWriteMarker) || (cp != nil && int64(cp.Offset) > offset) {
+		return nil
+	}
+	record := make([]byte, sectorSize)
+	copy(record, partialWriteMarker)
+	if cp != nil {
+		data, err := json.Marshal(cp)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if len(partialWriteMarker)+len(data) > sectorSize {
+			return errors.Errorf("the checkpoint does not fit the last sector of %s", s.device)
+		}
+		copy(record[len(partialWriteMarker):], data)
+	}
+	if _, err := f.WriteAt(record, offset); err != nil {
+		return errors.Wrapf(err, "could not write the checkpoint to %s", s.device)
+	}
+	return f.Sync()
+}
+
+// readSector opens the device and returns the last sector and its offset
+func (s *blockCheckpointStore) readSector(flag int) (*os.File, []byte, int64, error) {
+	f, err := os.OpenFile(s.device, flag, 0)
+	if err != nil {
+		return nil, nil, 0, errors.Wrapf(err, "could not open %s", s.device)
+	}
+	offset, err := markerOffset(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, 0, err
+	}
+	sector := make([]byte, sectorSize)
+	if _, err := f.ReadAt(sector, offset); err != nil {
+		f.Close()
+		return nil, nil, 0, errors.Wrapf(err, "could not read the last sector of %s", s.device)
+	}
+	return f, sector, offset, nil
+}
+
+// syncDir flushes the directory to its storage, so a file renamed in it is there after a restart
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", dir)
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// syncFileSystem flushes the file system of dir to its storage, so the files tar extracted to it so far are there
+// after a restart
+func syncFileSystem(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", dir)
+	}
+	defer d.Close()
+	return unix.Syncfs(int(d.Fd()))
+}
+
+// streamWithCheckpoints writes the data of r to fileName starting at the offset of cp, and saves how far it got to
+// store every checkpointInterval. The checkpoint is removed once all the data is written.
+func streamWithCheckpoints(r io.Reader, fileName string, cp *transferCheckpoint, store checkpointStore) error {
+	flag := os.O_WRONLY
+	if cp.Offset == 0 && !isBlockDeviceFunc(fileName) {
+		flag |= os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(fileName, flag, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "could not open file %q", fileName)
+	}
+	defer f.Close()
+	if _, err := f.Seek(int64(cp.Offset), io.SeekStart); err != nil {
+		return errors.Wrapf(err, "could not seek to %d in %q", cp.Offset, fileName)
+	}
+	klog.V(1).Infof("Writing data from byte %d...\n", cp.Offset)
+	buf := make([]byte, checkpointBufferSize)
+	offset, saved := cp.Offset, time.Now()
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				return errors.Wrapf(err, "unable to write to file")
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			// The data written so far stays, the next attempt resumes at the last checkpoint
+			return errors.Wrapf(readErr, "unable to write to file")
+		}
+		if time.Since(saved) >= checkpointInterval {
+			if err := f.Sync(); err != nil {
+				return errors.Wrapf(err, "unable to write to file")
+			}
+			cp.Offset = offset
+			if err := store.save(cp); err != nil {
+				klog.Warningf("Unable to save the transfer checkpoint: %v", err)
+			}
+			saved = time.Now()
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return store.clear()
+}
+
+// tarCheckpointReader saves checkpoints of an archive that is read through it while tar extracts it to dir. The
+// transfer resumes at the start of an entry that tar extracted completely before the checkpoint, so it tracks where
+// the headers of the archive are.
+type tarCheckpointReader struct {
+	io.Reader
+	dir   string
+	cp    *transferCheckpoint
+	store checkpointStore
+	saved time.Time
+	// read is the offset of the data read so far
+	read uint64
+	// next is the offset of the next header
+	next uint64
+	// header collects the next header
+	header []byte
+	// extended is true if the next header follows an extended header, that applies to it
+	extended bool
+	// restarts are the offsets of the headers tar can resume at, that were not checkpointed yet
+	restarts []uint64
+	// ended is true once the end of the archive is read, or the archive can not be resumed after the current offset
+	ended bool
+}
+
+// newTarCheckpointReader returns the reader of the archive read by r from the offset of cp, which is the start of a
+// header
+func newTarCheckpointReader(r io.Reader, dir string, cp *transferCheckpoint, store checkpointStore) *tarCheckpointReader {
+	return &tarCheckpointReader{
+		Reader: r,
+		dir:    dir,
+		cp:     cp,
+		store:  store,
+		saved:  time.Now(),
+		read:   cp.Offset,
+		next:   cp.Offset,
+		header: make([]byte, 0, tarBlockSize),
+	}
+}
+
+func (r *tarCheckpointReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.scan(p[:n])
+	if time.Since(r.saved) >= checkpointInterval {
+		r.checkpoint()
+		r.saved = time.Now()
+	}
+	return n, err
+}
+
+// scan tracks the headers in data, the next bytes of the archive
+func (r *tarCheckpointReader) scan(data []byte) {
+	for len(data) > 0 {
+		if r.ended {
+			r.read += uint64(len(data))
+			return
+		}
+		if r.read < r.next {
+			skip := r.next - r.read
+			if skip > uint64(len(data)) {
+				skip = uint64(len(data))
+			}
+			r.read += skip
+			data = data[skip:]
+			continue
+		}
+		take := tarBlockSize - len(r.header)
+		if take > len(data) {
+			take = len(data)
+		}
+		r.header = append(r.header, data[:take]...)
+		r.read += uint64(take)
+		data = data[take:]
+		if len(r.header) == tarBlockSize {
+			r.parseHeader()
+			r.header = r.header[:0]
+		}
+	}
+}
+
+// parseHeader records where the header collected at the offset next is a place to resume at, and where the next
+// header starts
+func (r *tarCheckpointReader) parseHeader() {
+	start := r.next
+	size, ok := tarEntrySize(r.header)
+	if !ok {
+		// The end of the archive, or not an archive tar can resume
+		r.ended = true
+		return
+	}
+	typeflag := r.header[156]
+	switch typeflag {
+	case 'g':
+		// A global extended header applies to all the entries after it, resuming after it would lose it
+		r.ended = true
+		return
+	case 'x', 'L', 'K':
+		r.extended = true
+	default:
+		if !r.extended {
+			r.restarts = append(r.restarts, start)
+		}
+		r.extended = false
+	}
+	switch typeflag {
+	case '1', '2', '3', '4', '5', '6':
+		size = 0
+	}
+	r.next = start + tarBlockSize + (size+tarBlockSize-1)/tarBlockSize*tarBlockSize
+}
+
+// checkpoint saves the last place to resume at that tar extracted completely
+func (r *tarCheckpointReader) checkpoint() {
+	var offset uint64
+	for len(r.restarts) > 0 && r.restarts[0]+tarBlockSize+tarReadAhead <= r.read {
+		offset = r.restarts[0]
+		r.restarts = r.restarts[1:]
+	}
+	if offset <= r.cp.Offset {
+		return
+	}
+	if err := syncFileSystem(r.dir); err != nil {
+		klog.Warningf("Unable to save the transfer checkpoint: %v", err)
+		return
+	}
+	r.cp.Offset = offset
+	if err := r.store.save(r.cp); err != nil {
+		klog.Warningf("Unable to save the transfer checkpoint: %v", err)
+	}
+}
+
+// tarEntrySize returns the size of the data of the entry of the tar header, false if it is not a valid header
+func tarEntrySize(header []byte) (uint64, bool) {
+	var sum int64
+	for i, b := range header {
+		if i >= 148 && i < 156 {
+			b = ' '
+		}
+		sum += int64(b)
+	}
+	recorded, err := parseTarNumber(header[148:156])
+	if err != nil || sum != int64(recorded) {
+		return 0, false
+	}
+	size, err := parseTarNumber(header[124:136])
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// parseTarNumber parses a numeric field of a tar header, in octal or in base-256
+func parseTarNumber(field []byte) (uint64, error) {
+	if len(field) > 0 && field[0]&0x80 != 0 {
+		if field[0]&0x40 != 0 {
+			return 0, errors.New("negative number")
+		}
+		var n uint64
+		for i, b := range field {
+			if i == 0 {
+				b &= 0x7f
+			}
+			if n > (1<<56)-1 {
+				return 0, errors.New("number out of range")
+			}
+			n = n<<8 | uint64(b)
+		}
+		return n, nil
+	}
+	s := strings.Trim(string(field), " \x00")
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 8, 64)
+}
@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingCheckpointStore records the checkpoints saved to it
+type recordingCheckpointStore struct {
+	saved   []uint64
+	cleared bool
+}
+
+func (s *recordingCheckpointStore) load() (*transferCheckpoint, error) {
+	return nil, nil
+}
+
+func (s *recordingCheckpointStore) save(cp *transferCheckpoint) error {
+	s.saved = append(s.saved, cp.Offset)
+	return nil
+}
+
+func (s *recordingCheckpointStore) clear() error {
+	s.cleared = true
+	return nil
+}
+
+// failingReader returns the data of its reader, then err
+type failingReader struct {
+	io.Reader
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+var _ = Describe("Transfer checkpoints", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "checkpoint")
+		Expect(err).ToNot(HaveOccurred())
+		checkpointInterval = 0
+	})
+
+	AfterEach(func() {
+		checkpointInterval = 30 * time.Second
+		os.RemoveAll(tmpDir)
+	})
+
+	It("Should resume only the transfer of the same data", func() {
+		cp := newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", 4096)
+		cp.Offset = 1024
+		Expect(cp.resumes(newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", 4096))).To(BeTrue())
+		Expect(cp.resumes(newTransferCheckpoint("http://example.com/disk.img", "\"changed\"", 4096))).To(BeFalse())
+		Expect(cp.resumes(newTransferCheckpoint("http://example.com/other.img", "\"etag\"", 4096))).To(BeFalse())
+		Expect(cp.resumes(newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", 8192))).To(BeFalse())
+		cp.Offset = 0
+		Expect(cp.resumes(newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", 4096))).To(BeFalse())
+	})
+
+	It("Should keep the checkpoint of a file next to it", func() {
+		target := filepath.Join(tmpDir, "disk.img")
+		store := newCheckpointStore(target)
+		Expect(store.(*fileCheckpointStore).path).To(Equal(filepath.Join(tmpDir, ".disk.img.checkpoint")))
+		Expect(newCheckpointStore(tmpDir).(*fileCheckpointStore).path).To(Equal(filepath.Join(tmpDir, ".cdi-archive.checkpoint")))
+
+		cp, err := store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(BeNil())
+		saved := newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", 4096)
+		saved.Offset = 2048
+		Expect(store.save(saved)).To(Succeed())
+		cp, err = store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(Equal(saved))
+		Expect(store.clear()).To(Succeed())
+		cp, err = store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(BeNil())
+	})
+
+	It("Should keep the checkpoint of a block device after the partial write marker", func() {
+		const deviceSize = 4 * sectorSize
+		device := filepath.Join(tmpDir, "device")
+		Expect(ioutil.WriteFile(device, make([]byte, deviceSize), 0644)).To(Succeed())
+		store := &blockCheckpointStore{device: device}
+		saved := newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", 4096)
+		saved.Offset = sectorSize
+
+		By("Not saving it without the marker")
+		Expect(store.save(saved)).To(Succeed())
+		cp, err := store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(BeNil())
+
+		By("Saving it after the marker")
+		Expect(startPartialWrite(device)).To(Succeed())
+		Expect(store.save(saved)).To(Succeed())
+		cp, err = store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(Equal(saved))
+
+		By("Not saving it once the data reaches the marker")
+		later := *saved
+		later.Offset = deviceSize
+		Expect(store.save(&later)).To(Succeed())
+		cp, err = store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(Equal(saved))
+
+		By("Keeping the marker when it is cleared")
+		Expect(store.clear()).To(Succeed())
+		cp, err = store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cp).To(BeNil())
+		content, err := ioutil.ReadFile(device)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content[deviceSize-sectorSize:]).To(HavePrefix(string(partialWriteMarker)))
+	})
+
+	It("Should checkpoint a stream written to a file and remove the checkpoint once it is written", func() {
+		data := bytes.Repeat([]byte("0123456789abcdef"), checkpointBufferSize/4)
+		target := filepath.Join(tmpDir, "disk.img")
+		store := &recordingCheckpointStore{}
+		cp := newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", uint64(len(data)))
+		Expect(streamWithCheckpoints(bytes.NewReader(data), target, cp, store)).To(Succeed())
+		content, err := ioutil.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal(data))
+		Expect(store.saved).To(Equal([]uint64{checkpointBufferSize, 2 * checkpointBufferSize, 3 * checkpointBufferSize, 4 * checkpointBufferSize}))
+		Expect(store.cleared).To(BeTrue())
+	})
+
+	It("Should keep the data and the checkpoint when the stream fails, and resume writing at the checkpoint", func() {
+		data := bytes.Repeat([]byte("0123456789abcdef"), checkpointBufferSize/4)
+		target := filepath.Join(tmpDir, "disk.img")
+		store := newCheckpointStore(target)
+		cp := newTransferCheckpoint("http://example.com/disk.img", "\"etag\"", uint64(len(data)))
+		failing := &failingReader{Reader: bytes.NewReader(data[:2*checkpointBufferSize+100]), err: io.ErrUnexpectedEOF}
+		Expect(streamWithCheckpoints(failing, target, cp, store)).ToNot(Succeed())
+		saved, err := store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(saved.Offset).To(Equal(uint64(2*checkpointBufferSize + 100)))
+
+		Expect(streamWithCheckpoints(bytes.NewReader(data[saved.Offset:]), target, saved, store)).To(Succeed())
+		content, err := ioutil.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal(data))
+		saved, err = store.load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(saved).To(BeNil())
+	})
+
+	It("Should checkpoint an archive at the entries tar extracted, but not inside an entry with an extended header", func() {
+		longName := strings.Repeat("d", 150)
+		var archive bytes.Buffer
+		w := tar.NewWriter(&archive)
+		names := []string{"a", "b", longName, "c", "e"}
+		for _, name := range names {
+			content := bytes.Repeat([]byte(name[:1]), 700*1024)
+			Expect(w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+			_, err := w.Write(content)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(w.Close()).To(Succeed())
+		data := archive.Bytes()
+
+		store := &recordingCheckpointStore{}
+		cp := newTransferCheckpoint("http://example.com/archive.tar", "\"etag\"", uint64(len(data)))
+		reader := newTarCheckpointReader(bytes.NewReader(data), tmpDir, cp, store)
+		buf := make([]byte, 64*1024)
+		for {
+			_, err := reader.Read(buf)
+			if err == io.EOF {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(store.saved).ToNot(BeEmpty())
+		var resumed []string
+		for _, offset := range store.saved {
+			r := tar.NewReader(bytes.NewReader(data[offset:]))
+			header, err := r.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names).To(ContainElement(header.Name))
+			Expect(offset + tarBlockSize + tarReadAhead).To(BeNumerically("<=", len(data)))
+			resumed = append(resumed, header.Name)
+		}
+		Expect(resumed).To(Equal([]string{"b", "c"}))
+	})
+})
@@ -1,11 +1,16 @@
 package importer
 
 import (
+	"fmt"
 	"io"
 	"net/url"
+	"os"
 	"path/filepath"
 
+	"github.com/pkg/errors"
 	"k8s.io/klog"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/image"
 	"kubevirt.io/containerized-data-importer/pkg/util"
 )
 
@@ -23,12 +28,46 @@ type UploadDataSource struct {
 	readers *FormatReaders
 	// url to a file in scratch space.
 	url *url.URL
+	// received counts the bytes read from the stream
+	received *util.CountingReader
+	// written counts the bytes read from the top reader, the bytes written to the target or scratch space
+	written *util.CountingReader
+	// verification is how thoroughly the written data is verified
+	verification cdiv1.DataVolumeVerification
+	// size is what the client tells about the size of the image
+	size UploadSize
+	// streamConversion converts a qcow2 image while it is received, instead of in the scratch space
+	streamConversion bool
+}
+
+// UploadSize is what the client of an upload tells about the size of the image before it is transferred
+type UploadSize struct {
+	// Source is the size of the raw image the client declares, such as the size of the source device of a clone, 0
+	// if it is not declared
+	Source int64
+	// ContentLength is the length of the uploaded data, the size of the raw image if the data is neither compressed
+	// nor archived, 0 if it is not known
+	ContentLength int64
+}
+
+// ByteCountError indicates that the bytes of an upload received, written or converted disagree in a way the format of
+// the data does not explain, the data was lost on the way.
+type ByteCountError struct {
+	// Counted tells which bytes were counted
+	Counted  string
+	Actual   int64
+	Expected int64
+}
+
+func (e *ByteCountError) Error() string {
+	return fmt.Sprintf("%s %d bytes, expected %d bytes", e.Counted, e.Actual, e.Expected)
 }
 
 // NewUploadDataSource creates a new instance of an UploadDataSource
 func NewUploadDataSource(stream io.ReadCloser) *UploadDataSource {
 	return &UploadDataSource{
-		stream: stream,
+		stream:   stream,
+		received: &util.CountingReader{Reader: stream},
 	}
 }
 
@@ -36,13 +75,14 @@ func NewUploadDataSource(stream io.ReadCloser) *UploadDataSource {
 func (ud *UploadDataSource) Info() (ProcessingPhase, error) {
 	var err error
 	// Hardcoded to only accept kubevirt content type.
-	ud.readers, err = NewFormatReaders(ud.stream, uint64(0))
+	ud.readers, err = NewFormatReaders(ud.received, uint64(0))
 	if err != nil {
 		klog.Errorf("Error creating readers: %v", err)
 		return ProcessingPhaseError, err
 	}
-	if !ud.readers.Convert {
-		// Uploading a raw file, we can write that directly to the target.
+	ud.written = &util.CountingReader{Reader: ud.readers.TopReader()}
+	if !ud.readers.Convert || ud.streamConversion {
+		// Uploading a raw file, or a qcow2 image converted on the fly, we can write that directly to the target.
 		return ProcessingPhaseTransferDataFile, nil
 	}
 	return ProcessingPhaseTransferScratch, nil
@@ -55,10 +95,13 @@ func (ud *UploadDataSource) Transfer(path string) (ProcessingPhase, error) {
 		return ProcessingPhaseError, ErrInvalidPath
 	}
 	file := filepath.Join(path, tempFile)
-	err := util.StreamDataToFile(ud.readers.TopReader(), file)
+	err := util.StreamDataToFile(ud.written, file)
 	if err != nil {
 		return ProcessingPhaseError, err
 	}
+	if err = ud.verifyWritten(); err != nil {
+		return ProcessingPhaseError, err
+	}
 	// If we successfully wrote to the file, then the parse will succeed.
 	ud.url, _ = url.Parse(file)
 	return ProcessingPhaseProcess, nil
@@ -66,13 +109,86 @@ func (ud *UploadDataSource) Transfer(path string) (ProcessingPhase, error) {
 
 // TransferFile is called to transfer the data from the source to the passed in file.
 func (ud *UploadDataSource) TransferFile(fileName string) (ProcessingPhase, error) {
-	err := util.StreamDataToFile(ud.readers.TopReader(), fileName)
-	if err != nil {
+	if err := ud.writeDataFile(fileName); err != nil {
 		return ProcessingPhaseError, err
 	}
 	return ProcessingPhaseResize, nil
 }
 
+// writeDataFile writes the data to fileName, a qcow2 image is converted to raw on the way.
+func (ud *UploadDataSource) writeDataFile(fileName string) error {
+	if !ud.readers.Convert {
+		if err := util.StreamDataToFile(ud.written, fileName); err != nil {
+			return err
+		}
+		return ud.verifyWritten()
+	}
+	klog.V(1).Infof("Converting the qcow2 image to %s while it is uploaded", fileName)
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", fileName)
+	}
+	defer f.Close()
+	if err := image.ConvertQcow2Stream(ud.written, f, image.Qcow2MaxStreamBuffer); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// SetStreamConversion sets whether a qcow2 image is converted while it is received, it is written to the target
+// directly then. The image is neither validated nor checked with qemu-img, which needs the whole image.
+func (ud *UploadDataSource) SetStreamConversion(streamConversion bool) {
+	ud.streamConversion = streamConversion
+}
+
+// SetVerification sets how thoroughly the written data is verified, the bytes written are not compared to the bytes
+// received with DataVolumeVerificationNone.
+func (ud *UploadDataSource) SetVerification(verification cdiv1.DataVolumeVerification) {
+	ud.verification = verification
+}
+
+// verifyWritten returns a ByteCountError if the bytes written differ from the bytes received. Compressed data and XVA
+// exports are written with a different size.
+func (ud *UploadDataSource) verifyWritten() error {
+	if ud.readers.Archived || ud.readers.XVA || ud.verification == cdiv1.DataVolumeVerificationNone {
+		return nil
+	}
+	if ud.written.Current != ud.received.Current {
+		return &ByteCountError{Counted: "wrote", Actual: int64(ud.written.Current), Expected: int64(ud.received.Current)}
+	}
+	return nil
+}
+
+// SetUploadSize sets what the client tells about the size of the image
+func (ud *UploadDataSource) SetUploadSize(size UploadSize) {
+	ud.size = size
+}
+
+// SourceSize returns the virtual size of the image once the header of the data is read: the virtual size of a qcow2
+// image, the size the client declares, or the length of raw data that is neither compressed nor archived. It
+// returns 0 if the size is not known.
+func (ud *UploadDataSource) SourceSize() int64 {
+	switch {
+	case ud.readers == nil:
+		return 0
+	case ud.readers.VirtualSize > 0:
+		return ud.readers.VirtualSize
+	case ud.size.Source > 0:
+		return ud.size.Source
+	case !ud.readers.Convert && !ud.readers.Archived && !ud.readers.XVA:
+		return ud.size.ContentLength
+	}
+	return 0
+}
+
+// ConvertedSize returns the virtual size of an uploaded qcow2 image, the converted image must have at least this size.
+func (ud *UploadDataSource) ConvertedSize() int64 {
+	if ud.readers == nil {
+		return 0
+	}
+	return ud.readers.VirtualSize
+}
+
 // Process is called to do any special processing before giving the url to the data back to the processor
 func (ud *UploadDataSource) Process() (ProcessingPhase, error) {
 	return ProcessingPhaseConvert, nil
@@ -102,13 +218,21 @@ type AsyncUploadDataSource struct {
 // NewAsyncUploadDataSource creates a new instance of an UploadDataSource
 func NewAsyncUploadDataSource(stream io.ReadCloser) *AsyncUploadDataSource {
 	return &AsyncUploadDataSource{
-		uploadDataSource: UploadDataSource{
-			stream: stream,
-		},
-		ResumePhase: ProcessingPhaseInfo,
+		uploadDataSource: *NewUploadDataSource(stream),
+		ResumePhase:      ProcessingPhaseInfo,
 	}
 }
 
+// SetVerification sets how thoroughly the written data is verified
+func (aud *AsyncUploadDataSource) SetVerification(verification cdiv1.DataVolumeVerification) {
+	aud.uploadDataSource.SetVerification(verification)
+}
+
+// SetStreamConversion sets whether a qcow2 image is converted while it is received
+func (aud *AsyncUploadDataSource) SetStreamConversion(streamConversion bool) {
+	aud.uploadDataSource.SetStreamConversion(streamConversion)
+}
+
 // Info is called to get initial information about the data.
 func (aud *AsyncUploadDataSource) Info() (ProcessingPhase, error) {
 	return aud.uploadDataSource.Info()
@@ -121,10 +245,13 @@ func (aud *AsyncUploadDataSource) Transfer(path string) (ProcessingPhase, error)
 		return ProcessingPhaseError, ErrInvalidPath
 	}
 	file := filepath.Join(path, tempFile)
-	err := util.StreamDataToFile(aud.uploadDataSource.readers.TopReader(), file)
+	err := util.StreamDataToFile(aud.uploadDataSource.written, file)
 	if err != nil {
 		return ProcessingPhaseError, err
 	}
+	if err = aud.uploadDataSource.verifyWritten(); err != nil {
+		return ProcessingPhaseError, err
+	}
 	// If we successfully wrote to the file, then the parse will succeed.
 	aud.uploadDataSource.url, _ = url.Parse(file)
 	aud.ResumePhase = ProcessingPhaseProcess
@@ -133,8 +260,7 @@ func (aud *AsyncUploadDataSource) Transfer(path string) (ProcessingPhase, error)
 
 // TransferFile is called to transfer the data from the source to the passed in file.
 func (aud *AsyncUploadDataSource) TransferFile(fileName string) (ProcessingPhase, error) {
-	err := util.StreamDataToFile(aud.uploadDataSource.readers.TopReader(), fileName)
-	if err != nil {
+	if err := aud.uploadDataSource.writeDataFile(fileName); err != nil {
 		return ProcessingPhaseError, err
 	}
 	aud.ResumePhase = ProcessingPhaseResize
@@ -156,6 +282,21 @@ func (aud *AsyncUploadDataSource) GetURL() *url.URL {
 	return aud.uploadDataSource.GetURL()
 }
 
+// SetUploadSize sets what the client tells about the size of the image
+func (aud *AsyncUploadDataSource) SetUploadSize(size UploadSize) {
+	aud.uploadDataSource.SetUploadSize(size)
+}
+
+// SourceSize returns the virtual size of the image once the header of the data is read, 0 if it is not known
+func (aud *AsyncUploadDataSource) SourceSize() int64 {
+	return aud.uploadDataSource.SourceSize()
+}
+
+// ConvertedSize returns the virtual size of an uploaded qcow2 image, the converted image must have at least this size.
+func (aud *AsyncUploadDataSource) ConvertedSize() int64 {
+	return aud.uploadDataSource.ConvertedSize()
+}
+
 // GetResumePhase returns the next phase to process when resuming
 func (aud *AsyncUploadDataSource) GetResumePhase() ProcessingPhase {
 	return aud.ResumePhase
@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -9,6 +10,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 )
 
 var _ = Describe("Upload data source", func() {
@@ -124,6 +127,25 @@ var _ = Describe("Upload data source", func() {
 		Expect(ProcessingPhaseResize).To(Equal(result))
 	})
 
+	It("TransferFile should convert a qcow2 image while it is received with stream conversion", func() {
+		// Don't need to defer close, since ud.Close will close the reader
+		sourceFile, err := os.Open(cirrosFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		ud = NewUploadDataSource(sourceFile)
+		ud.SetStreamConversion(true)
+		result, err := ud.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ProcessingPhaseTransferDataFile).To(Equal(result))
+		result, err = ud.TransferFile(filepath.Join(tmpDir, "file"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ProcessingPhaseResize).To(Equal(result))
+		converted, err := ioutil.ReadFile(filepath.Join(tmpDir, "file"))
+		Expect(err).ToNot(HaveOccurred())
+		expected, err := ioutil.ReadFile(filepath.Join(imageDir, "cirros.raw"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(bytes.Equal(converted, expected)).To(BeTrue())
+	})
+
 	It("TransferFile should fail on streaming error", func() {
 		// Don't need to defer close, since ud.Close will close the reader
 		sourceFile, err := os.Open(tinyCoreFilePath)
@@ -137,6 +159,57 @@ var _ = Describe("Upload data source", func() {
 		Expect(ProcessingPhaseError).To(Equal(result))
 	})
 
+	It("TransferFile should fail when the bytes written differ from the bytes received", func() {
+		// Don't need to defer close, since ud.Close will close the reader
+		sourceFile, err := os.Open(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		ud = NewUploadDataSource(sourceFile)
+		_, err = ud.Info()
+		Expect(err).NotTo(HaveOccurred())
+		// Bytes that were received but lost in the reader stack
+		ud.received.Current += 512
+		result, err := ud.TransferFile(filepath.Join(tmpDir, "file"))
+		Expect(ProcessingPhaseError).To(Equal(result))
+		byteCountErr, ok := err.(*ByteCountError)
+		Expect(ok).To(BeTrue())
+		Expect(byteCountErr.Actual + 512).To(Equal(byteCountErr.Expected))
+		Expect(err.Error()).To(HavePrefix("wrote "))
+	})
+
+	It("TransferFile should not compare the bytes written to the bytes received without verification", func() {
+		sourceFile, err := os.Open(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		ud = NewUploadDataSource(sourceFile)
+		ud.SetVerification(cdiv1.DataVolumeVerificationNone)
+		_, err = ud.Info()
+		Expect(err).NotTo(HaveOccurred())
+		ud.received.Current += 512
+		result, err := ud.TransferFile(filepath.Join(tmpDir, "file"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ProcessingPhaseResize).To(Equal(result))
+	})
+
+	It("ConvertedSize should return the virtual size of a qcow2 image", func() {
+		// Don't need to defer close, since ud.Close will close the reader
+		file, err := os.Open(cirrosFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		ud = NewUploadDataSource(file)
+		Expect(ud.ConvertedSize()).To(BeZero())
+		_, err = ud.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ud.ConvertedSize()).To(Equal(int64(46137344)))
+	})
+
+	It("ConvertedSize should return 0 for a raw image", func() {
+		// Don't need to defer close, since ud.Close will close the reader
+		file, err := os.Open(tinyCoreFilePath)
+		Expect(err).NotTo(HaveOccurred())
+		ud = NewUploadDataSource(file)
+		_, err = ud.Info()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ud.ConvertedSize()).To(BeZero())
+	})
+
 	It("Process should return Convert", func() {
 		// Don't need to defer close, since ud.Close will close the reader
 		file, err := os.Open(cirrosFilePath)
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// DefaultUserAgent is the product the importer names in the User-Agent of its requests if none is configured
+const DefaultUserAgent = "containerized-data-importer"
+
+// rateLimitHeaders are the response headers servers tell their rate limits with
+var rateLimitHeaders = []string{
+	"Retry-After",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+}
+
+// userAgent is the User-Agent of the HTTP and S3 requests of the importer
+var userAgent = DefaultUserAgent
+
+// SetUserAgent sets the User-Agent of the HTTP and S3 requests of the importer to product, or DefaultUserAgent,
+// followed by the cluster and the data volume the import is for, so image providers can tell where traffic comes from
+func SetUserAgent(product, clusterID, dataVolumeUID string) {
+	userAgent = formatUserAgent(product, clusterID, dataVolumeUID)
+}
+
+// formatUserAgent returns the User-Agent of product, like "containerized-data-importer (cluster 5b4e...; datavolume
+// 9a1c...)", leaving out what is not known
+func formatUserAgent(product, clusterID, dataVolumeUID string) string {
+	if product == "" {
+		product = DefaultUserAgent
+	}
+	var comments []string
+	if clusterID != "" {
+		comments = append(comments, "cluster "+clusterID)
+	}
+	if dataVolumeUID != "" {
+		comments = append(comments, "datavolume "+dataVolumeUID)
+	}
+	if len(comments) == 0 {
+		return product
+	}
+	return fmt.Sprintf("%s (%s)", product, strings.Join(comments, "; "))
+}
+
+// identifyingTransport sets the User-Agent of the requests it sends, and logs the rate limits servers answer with so
+// admins can tell why a server refuses the importer
+type identifyingTransport struct {
+	transport http.RoundTripper
+}
+
+func newIdentifyingTransport(transport http.RoundTripper) http.RoundTripper {
+	return &identifyingTransport{transport: transport}
+}
+
+// RoundTrip sends a copy of req with the User-Agent of the importer, a RoundTripper must not modify its request
+func (t *identifyingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	identified := new(http.Request)
+	*identified = *req
+	identified.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		identified.Header[k] = v
+	}
+	identified.Header.Set("User-Agent", userAgent)
+
+	resp, err := t.transport.RoundTrip(identified)
+	if err == nil {
+		logRateLimit(resp)
+	}
+	return resp, err
+}
+
+// logRateLimit logs the rate limit headers of resp, as a warning if the request was rate limited
+func logRateLimit(resp *http.Response) {
+	var limits []string
+	for _, header := range rateLimitHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			limits = append(limits, header+"="+value)
+		}
+	}
+	host := resp.Request.URL.Host
+	if resp.StatusCode == http.StatusTooManyRequests {
+		klog.Warningf("%s rate limited the importer: %s %v", host, resp.Status, limits)
+	} else if len(limits) > 0 {
+		klog.V(2).Infof("Rate limit of %s: %v", host, limits)
+	}
+}
@@ -0,0 +1,49 @@
+package importer
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("User-Agent", func() {
+	AfterEach(func() {
+		userAgent = DefaultUserAgent
+	})
+
+	table.DescribeTable("Should format the User-Agent", func(product, clusterID, dataVolumeUID, expected string) {
+		Expect(formatUserAgent(product, clusterID, dataVolumeUID)).To(Equal(expected))
+	},
+		table.Entry("with the cluster and data volume", "example-importer/1.0", "cluster-uid", "dv-uid", "example-importer/1.0 (cluster cluster-uid; datavolume dv-uid)"),
+		table.Entry("with the default product", "", "cluster-uid", "dv-uid", "containerized-data-importer (cluster cluster-uid; datavolume dv-uid)"),
+		table.Entry("without the cluster", "", "", "dv-uid", "containerized-data-importer (datavolume dv-uid)"),
+		table.Entry("with the product only", "", "", "", "containerized-data-importer"),
+	)
+
+	It("Should identify the requests of the http client", func() {
+		var received string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.UserAgent()
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		SetUserAgent("", "cluster-uid", "dv-uid")
+		client, err := createHTTPClient("")
+		Expect(err).ToNot(HaveOccurred())
+		req, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("User-Agent", "Go-http-client/1.1")
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(received).To(Equal("containerized-data-importer (cluster cluster-uid; datavolume dv-uid)"))
+		Expect(req.Header.Get("User-Agent")).To(Equal("Go-http-client/1.1"))
+	})
+})
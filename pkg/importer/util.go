@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
@@ -43,3 +45,45 @@ func CleanDir(dest string) error {
 	}
 	return nil
 }
+
+// ScrubScratchSpace removes what an earlier use of the scratch space volume mounted at dir left behind, the
+// volumes of the scratch space pool are reused by other transfers. Nothing is done if no scratch space is mounted.
+func ScrubScratchSpace(dir string) error {
+	if util.GetAvailableSpace(dir) <= int64(0) {
+		return nil
+	}
+	return errors.Wrap(CleanDir(dir), "Failure scrubbing scratch space")
+}
+
+// isNoSpaceError returns true if err is caused by a full file system. Errors of qemu-img and other commands
+// only contain the message of the error.
+func isNoSpaceError(err error) bool {
+	if err == nil {
+		return false
+	}
+	cause := errors.Cause(err)
+	switch e := cause.(type) {
+	case *os.PathError:
+		cause = e.Err
+	case *os.LinkError:
+		cause = e.Err
+	case *os.SyscallError:
+		cause = e.Err
+	}
+	if cause == syscall.ENOSPC {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), syscall.ENOSPC.Error())
+}
+
+// dirSize returns the total size of the files in the directory dir and its sub directories.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
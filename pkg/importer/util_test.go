@@ -7,10 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/util"
@@ -119,4 +121,30 @@ var _ = Describe("Clean dir", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(0).To(Equal(len(dir)))
 	})
+
+	It("Should scrub what an earlier use left in the scratch space", func() {
+		Expect(os.MkdirAll(filepath.Join(tmpDir, "layers", "disk"), 0755)).To(Succeed())
+		_, err = os.Create(filepath.Join(tmpDir, "layers", "disk", "disk.img"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ScrubScratchSpace(tmpDir)).To(Succeed())
+		dir, err := ioutil.ReadDir(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dir).To(BeEmpty())
+	})
+
+	It("Should not fail without a scratch space", func() {
+		Expect(ScrubScratchSpace("/invalid")).To(Succeed())
+	})
+})
+
+var _ = Describe("No space errors", func() {
+	table.DescribeTable("should be detected", func(err error, expected bool) {
+		Expect(isNoSpaceError(err)).To(Equal(expected))
+	},
+		table.Entry("with nil", nil, false),
+		table.Entry("with ENOSPC", syscall.ENOSPC, true),
+		table.Entry("with a wrapped path error", errors.Wrap(&os.PathError{Op: "write", Path: "/scratch/disk.img", Err: syscall.ENOSPC}, "unable to write"), true),
+		table.Entry("with the output of a command", errors.New("qemu-img: error while writing sector 2048: No space left on device"), true),
+		table.Entry("with another error", &os.PathError{Op: "open", Path: "/scratch/disk.img", Err: syscall.ENOENT}, false),
+	)
 })
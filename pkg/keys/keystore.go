@@ -17,6 +17,7 @@ limitations under the License.
 package keys
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 
@@ -32,10 +33,10 @@ import (
 )
 
 const (
-	// KeyStorePrivateKeyFile is the key in a secret containing an RSA private key
+	// KeyStorePrivateKeyFile is the key in a secret containing an RSA or ECDSA private key
 	KeyStorePrivateKeyFile = "id_rsa"
 
-	// KeyStorePublicKeyFile is the key in a secret containing an RSA publis key
+	// KeyStorePublicKeyFile is the key in a secret containing RSA or ECDSA public keys
 	KeyStorePublicKeyFile = "id_rsa.pub"
 )
 
@@ -80,9 +81,12 @@ func GetOrCreatePrivateKey(client kubernetes.Interface, namespace, secretName st
 }
 
 // newPrivateKeySecret returns a new private key secret
-func newPrivateKeySecret(client kubernetes.Interface, namespace, secretName string, privateKey *rsa.PrivateKey) (*v1.Secret, error) {
-	privateKeyBytes := cert.EncodePrivateKeyPEM(privateKey)
-	publicKeyBytes, err := cert.EncodePublicKeyPEM(&privateKey.PublicKey)
+func newPrivateKeySecret(client kubernetes.Interface, namespace, secretName string, privateKey crypto.Signer) (*v1.Secret, error) {
+	privateKeyBytes, err := encodePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyBytes, err := cert.EncodePublicKeyPEM(privateKey.Public())
 	if err != nil {
 		return nil, errors.Wrap(err, "Error encoding public key")
 	}
@@ -0,0 +1,244 @@
+/*
+Copyright 2018 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"kubevirt.io/containerized-data-importer/pkg/token"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+)
+
+const (
+	// KeyStorePreviousPrivateKeyFile is the key in a secret containing the private key replaced by a rotation
+	KeyStorePreviousPrivateKeyFile = "id_rsa.previous"
+
+	// AnnSigningKeyRotated is the annotation of a signing key secret with the time its key was last rotated
+	AnnSigningKeyRotated = "cdi.kubevirt.io/signingKeyRotated"
+
+	// KeyPropagationDelay is how long the kubelets take to update the public key mounted in the validators of the
+	// tokens, after the secret was updated
+	KeyPropagationDelay = 2 * time.Minute
+
+	// MaxTokenLifetime is the lifetime of the longest lived tokens signed with the key, the tokens of upload sessions
+	MaxTokenLifetime = 24 * time.Hour
+
+	// TokenLeewayMargin outlasts the leeway the validators of the tokens give their expiry
+	TokenLeewayMargin = time.Minute
+
+	// SigningKeyRotationPeriod is how long after a rotation the public key of the previous key is kept in the secret,
+	// past the propagation of the new key and the lifetime of the tokens signed with the previous key
+	SigningKeyRotationPeriod = KeyPropagationDelay + MaxTokenLifetime + TokenLeewayMargin
+)
+
+// SigningKey is the private key tokens are signed with, and the key it replaced while it is rotated
+type SigningKey struct {
+	Key crypto.Signer
+	// Previous is the key to sign with until ActiveAt, nil unless Key is rotated
+	Previous crypto.Signer
+	// ActiveAt is when the validators of the tokens have the public key of Key
+	ActiveAt time.Time
+}
+
+// Generator returns a token Generator signing with the key that is active
+func (k *SigningKey) Generator(issuer string, lifetime time.Duration) token.Generator {
+	generator := token.NewGenerator(issuer, k.Key, lifetime)
+	if k.Previous == nil {
+		return generator
+	}
+	return token.NewRotatingGenerator(token.NewGenerator(issuer, k.Previous, lifetime), generator, k.ActiveAt)
+}
+
+// GetOrCreateSigningKey gets or creates a signing key secret with a private key for algorithm. The key of a secret
+// with a key for another algorithm is rotated: the public key of the new key is added to the secret next to the
+// public key of the previous key, which keeps signing for KeyPropagationDelay. A rotation older than
+// SigningKeyRotationPeriod is completed by removing the previous key.
+func GetOrCreateSigningKey(client kubernetes.Interface, namespace, secretName, algorithm string) (*SigningKey, error) {
+	if algorithm == "" {
+		algorithm = token.AlgorithmPS256
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return nil, errors.Wrap(err, "Error getting secret")
+		}
+
+		privateKey, err := generateSigningKey(algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		secret, err = newPrivateKeySecret(client, namespace, secretName, privateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating prvate key secret")
+		}
+
+		secret, err = client.CoreV1().Secrets(namespace).Create(secret)
+		if err != nil {
+			if !k8serrors.IsAlreadyExists(err) {
+				return nil, errors.Wrap(err, "Error creating secret")
+			}
+
+			secret, err = client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+			if err != nil {
+				return nil, errors.Wrap(err, "Error getting secret, second time")
+			}
+		}
+	}
+
+	privateKey, err := parseSigningKey(secret, KeyStorePrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyAlgorithm, err := token.Algorithm(privateKey); err != nil || keyAlgorithm != algorithm {
+		return rotateSigningKey(client, secret, privateKey, algorithm)
+	}
+
+	rotated, err := time.Parse(time.RFC3339, secret.Annotations[AnnSigningKeyRotated])
+	if err != nil {
+		return &SigningKey{Key: privateKey}, nil
+	}
+	if time.Since(rotated) >= SigningKeyRotationPeriod {
+		if err := PruneSigningKey(client, namespace, secretName); err != nil {
+			return nil, err
+		}
+		return &SigningKey{Key: privateKey}, nil
+	}
+
+	previousKey, err := parseSigningKey(secret, KeyStorePreviousPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{Key: privateKey, Previous: previousKey, ActiveAt: rotated.Add(KeyPropagationDelay)}, nil
+}
+
+// rotateSigningKey replaces the private key of secret by a new key for algorithm, keeping the previous key and its
+// public key
+func rotateSigningKey(client kubernetes.Interface, secret *v1.Secret, previousKey crypto.Signer, algorithm string) (*SigningKey, error) {
+	privateKey, err := generateSigningKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	privateKeyBytes, err := encodePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyBytes, err := cert.EncodePublicKeyPEM(privateKey.Public())
+	if err != nil {
+		return nil, errors.Wrap(err, "Error encoding public key")
+	}
+
+	rotated := time.Now()
+	secret = secret.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[AnnSigningKeyRotated] = rotated.UTC().Format(time.RFC3339)
+	secret.Data[KeyStorePreviousPrivateKeyFile] = secret.Data[KeyStorePrivateKeyFile]
+	secret.Data[KeyStorePrivateKeyFile] = privateKeyBytes
+	secret.Data[KeyStorePublicKeyFile] = append(publicKeyBytes, secret.Data[KeyStorePublicKeyFile]...)
+	if _, err := client.CoreV1().Secrets(secret.Namespace).Update(secret); err != nil {
+		return nil, errors.Wrap(err, "Error updating secret")
+	}
+
+	return &SigningKey{Key: privateKey, Previous: previousKey, ActiveAt: rotated.Add(KeyPropagationDelay)}, nil
+}
+
+// PruneSigningKey completes the rotation of the key of a signing key secret, removing the previous key and its
+// public key
+func PruneSigningKey(client kubernetes.Interface, namespace, secretName string) error {
+	secret, err := client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "Error getting secret")
+	}
+	if _, ok := secret.Annotations[AnnSigningKeyRotated]; !ok {
+		return nil
+	}
+	privateKey, err := parseSigningKey(secret, KeyStorePrivateKeyFile)
+	if err != nil {
+		return err
+	}
+	publicKeyBytes, err := cert.EncodePublicKeyPEM(privateKey.Public())
+	if err != nil {
+		return errors.Wrap(err, "Error encoding public key")
+	}
+
+	secret = secret.DeepCopy()
+	delete(secret.Annotations, AnnSigningKeyRotated)
+	delete(secret.Data, KeyStorePreviousPrivateKeyFile)
+	secret.Data[KeyStorePublicKeyFile] = publicKeyBytes
+	if _, err := client.CoreV1().Secrets(namespace).Update(secret); err != nil {
+		return errors.Wrap(err, "Error updating secret")
+	}
+	return nil
+}
+
+func generateSigningKey(algorithm string) (crypto.Signer, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch algorithm {
+	case token.AlgorithmPS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case token.AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, errors.Errorf("Unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Error generating key")
+	}
+	return privateKey, nil
+}
+
+func encodePrivateKey(privateKey crypto.Signer) ([]byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return cert.EncodePrivateKeyPEM(key), nil
+	case *ecdsa.PrivateKey:
+		return cert.EncodeECPrivateKeyPEM(key)
+	}
+	return nil, errors.Errorf("Unsupported private key %T", privateKey)
+}
+
+func parseSigningKey(secret *v1.Secret, file string) (crypto.Signer, error) {
+	bytes, ok := secret.Data[file]
+	if !ok {
+		return nil, errors.Errorf("Secret missing %s", file)
+	}
+	obj, err := cert.ParsePrivateKeyPEM(bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing secret")
+	}
+	key, ok := obj.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("Invalid pem format")
+	}
+	return key, nil
+}
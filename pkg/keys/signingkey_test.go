@@ -0,0 +1,115 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"kubevirt.io/containerized-data-importer/pkg/keys/keystest"
+	"kubevirt.io/containerized-data-importer/pkg/token"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+)
+
+var _ = Describe("Signing key", func() {
+	namespace := "default"
+	secretName := "mysecret"
+
+	var (
+		rsaKey *rsa.PrivateKey
+		client *k8sfake.Clientset
+	)
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		secret, err := keystest.NewPrivateKeySecret(namespace, secretName, rsaKey)
+		Expect(err).NotTo(HaveOccurred())
+		client = k8sfake.NewSimpleClientset(secret)
+	})
+
+	publicKeys := func() []interface{} {
+		secret, err := client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		keys, err := cert.ParsePublicKeysPEM(secret.Data[KeyStorePublicKeyFile])
+		Expect(err).NotTo(HaveOccurred())
+		return keys
+	}
+
+	It("Should create an ECDSA key", func() {
+		client = k8sfake.NewSimpleClientset()
+		signingKey, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signingKey.Key).To(BeAssignableToTypeOf(&ecdsa.PrivateKey{}))
+		Expect(signingKey.Previous).To(BeNil())
+		Expect(publicKeys()).To(Equal([]interface{}{signingKey.Key.Public()}))
+	})
+
+	It("Should get the existing RSA key by default", func() {
+		signingKey, err := GetOrCreateSigningKey(client, namespace, secretName, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signingKey.Key).To(Equal(rsaKey))
+		Expect(signingKey.Previous).To(BeNil())
+	})
+
+	It("Should rotate the key to another algorithm", func() {
+		signingKey, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signingKey.Key).To(BeAssignableToTypeOf(&ecdsa.PrivateKey{}))
+		Expect(signingKey.Previous).To(Equal(rsaKey))
+		Expect(signingKey.ActiveAt).To(BeTemporally("~", time.Now().Add(KeyPropagationDelay), time.Minute))
+		Expect(publicKeys()).To(Equal([]interface{}{signingKey.Key.Public(), &rsaKey.PublicKey}))
+
+		By("Keeping the rotation when the key is read again")
+		again, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again.Key).To(Equal(signingKey.Key))
+		Expect(again.Previous).To(Equal(rsaKey))
+	})
+
+	It("Should keep the previous key until the longest lived tokens signed with it expired", func() {
+		_, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		secret, err := client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		secret.Annotations[AnnSigningKeyRotated] = time.Now().Add(-KeyPropagationDelay - MaxTokenLifetime).UTC().Format(time.RFC3339)
+		_, err = client.CoreV1().Secrets(namespace).Update(secret)
+		Expect(err).NotTo(HaveOccurred())
+
+		signingKey, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signingKey.Previous).To(Equal(rsaKey))
+		Expect(publicKeys()).To(ContainElement(&rsaKey.PublicKey))
+	})
+
+	It("Should complete a rotation after the rotation period", func() {
+		_, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		secret, err := client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		secret.Annotations[AnnSigningKeyRotated] = time.Now().Add(-SigningKeyRotationPeriod).UTC().Format(time.RFC3339)
+		_, err = client.CoreV1().Secrets(namespace).Update(secret)
+		Expect(err).NotTo(HaveOccurred())
+
+		signingKey, err := GetOrCreateSigningKey(client, namespace, secretName, token.AlgorithmES256)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signingKey.Previous).To(BeNil())
+		Expect(publicKeys()).To(Equal([]interface{}{signingKey.Key.Public()}))
+		secret, err = client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.Annotations).ToNot(HaveKey(AnnSigningKeyRotated))
+		Expect(secret.Data).ToNot(HaveKey(KeyStorePreviousPrivateKeyFile))
+	})
+
+	It("Should fail with an unsupported algorithm", func() {
+		_, err := GetOrCreateSigningKey(client, namespace, secretName, "HS256")
+		Expect(err).To(HaveOccurred())
+	})
+})
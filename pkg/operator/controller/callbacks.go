@@ -89,6 +89,7 @@ func addReconcileCallbacks(r *ReconcileCDI) {
 	r.addCallback(&corev1.ServiceAccount{}, reconcileServiceAccountRead)
 	r.addCallback(&corev1.ServiceAccount{}, reconcileServiceAccounts)
 	r.addCallback(&appsv1.Deployment{}, reconcileCreateRoute)
+	r.addCallback(&appsv1.Deployment{}, reconcileCreatePodMonitors)
 	r.addCallback(&appsv1.Deployment{}, reconcileDeleteSecrets)
 }
 
@@ -145,13 +146,26 @@ func reconcileCreateRoute(args *ReconcileCallbackArgs) error {
 		return nil
 	}
 
-	if err := ensureUploadProxyRouteExists(args.Logger, args.Client, args.Scheme, deployment); err != nil {
+	if err := ensureUploadProxyRouteExists(args.Logger, args.Client, args.Scheme, deployment, args.Resource.Spec.UploadProxyIngress); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+func reconcileCreatePodMonitors(args *ReconcileCallbackArgs) error {
+	if args.State != ReconcileStatePostRead {
+		return nil
+	}
+
+	deployment := args.CurrentObject.(*appsv1.Deployment)
+	if !isControllerDeployment(deployment) || !checkDeploymentReady(deployment) {
+		return nil
+	}
+
+	return ensurePodMonitorsExist(args.Logger, args.Client, args.Scheme, deployment)
+}
+
 func reconcileServiceAccountRead(args *ReconcileCallbackArgs) error {
 	if args.State != ReconcileStatePostRead {
 		return nil
@@ -81,6 +81,13 @@ func checkCerts(client kubernetes.Interface, namespace string, exists bool) {
 	checkSecret(client, namespace, "cdi-uploadserver-client-signer", exists)
 	checkConfigMap(client, namespace, "cdi-uploadserver-client-signer-bundle", exists)
 	checkSecret(client, namespace, "cdi-uploadserver-client-cert", exists)
+
+	checkSecret(client, namespace, "cdi-metrics-signer", exists)
+	checkConfigMap(client, namespace, "cdi-metrics-signer-bundle", exists)
+
+	checkSecret(client, namespace, "cdi-metrics-client-signer", exists)
+	checkConfigMap(client, namespace, "cdi-metrics-client-signer-bundle", exists)
+	checkSecret(client, namespace, "cdi-metrics-client-cert", exists)
 }
 
 var _ = Describe("Cert rotation tests", func() {
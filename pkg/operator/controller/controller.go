@@ -733,6 +733,10 @@ func (r *ReconcileCDI) watchDependantResources(cr *cdiv1alpha1.CDI) error {
 		return err
 	}
 
+	if err = r.watchIngresses(); err != nil {
+		return err
+	}
+
 	if err = r.watchSecurityContextConstraints(); err != nil {
 		return err
 	}
@@ -820,6 +824,9 @@ func (r *ReconcileCDI) getNamespacedArgs(cr *cdiv1alpha1.CDI) *cdinamespaced.Fac
 		if cr.Spec.ImagePullPolicy != "" {
 			result.PullPolicy = string(cr.Spec.ImagePullPolicy)
 		}
+		result.UploadProxyVirtualHostSecrets = cr.Spec.UploadProxyVirtualHostSecrets
+		result.UploadProxyTrustedProxies = cr.Spec.UploadProxyTrustedProxies
+		result.TokenSigningAlgorithm = cr.Spec.TokenSigningAlgorithm
 	}
 
 	return &result
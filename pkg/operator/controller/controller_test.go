@@ -281,6 +281,36 @@ var _ = Describe("Controller", func() {
 				Expect(route.Spec.TLS.DestinationCACertificate).Should(Equal(testCertData))
 			})
 
+			It("should expose the upload proxy with the Route the CR asks for", func() {
+				args := createArgs()
+				doReconcile(args)
+				Expect(setDeploymentsReady(args)).To(BeTrue())
+
+				args.cdi.Spec.UploadProxyIngress = &cdiviaplha1.CDIUploadProxyIngress{
+					Host:           "upload.example.com",
+					TLSTermination: cdiviaplha1.CDIUploadProxyTLSTerminationPassthrough,
+					Timeout:        &metav1.Duration{Duration: 2 * time.Hour},
+				}
+				err := args.client.Update(context.TODO(), args.cdi)
+				Expect(err).ToNot(HaveOccurred())
+				doReconcile(args)
+
+				route := &routev1.Route{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      uploadProxyRouteName,
+						Namespace: cdiNamespace,
+					},
+				}
+				obj, err := getObject(args.client, route)
+				Expect(err).ToNot(HaveOccurred())
+				route = obj.(*routev1.Route)
+
+				Expect(route.Spec.Host).Should(Equal("upload.example.com"))
+				Expect(route.Spec.TLS.Termination).Should(Equal(routev1.TLSTerminationPassthrough))
+				Expect(route.Spec.TLS.DestinationCACertificate).Should(BeEmpty())
+				Expect(route.Annotations[routeTimeoutAnnotation]).Should(Equal("7200s"))
+			})
+
 			It("can become become ready, un-ready, and ready again", func() {
 				var deployment *appsv1.Deployment
 
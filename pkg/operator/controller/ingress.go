@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	cdiv1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	uploadProxyIngressName = uploadProxyServiceName
+
+	ingressClassAnnotation = "kubernetes.io/ingress.class"
+)
+
+// ensureUploadProxyIngressExists exposes the upload proxy with an Ingress as ingress asks for, and deletes the
+// Ingress it created once ingress has no host
+func ensureUploadProxyIngressExists(logger logr.Logger, c client.Client, scheme *runtime.Scheme, owner metav1.Object, ingress *cdiv1alpha1.CDIUploadProxyIngress) error {
+	namespace := owner.GetNamespace()
+	currentIngress := &networkingv1beta1.Ingress{}
+	key := client.ObjectKey{Namespace: namespace, Name: uploadProxyIngressName}
+	err := c.Get(context.TODO(), key, currentIngress)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if ingress == nil || ingress.Host == "" {
+		if ingress != nil {
+			logger.Info("The upload proxy ingress has no host, not creating an Ingress")
+		}
+		if exists && metav1.IsControlledBy(currentIngress, owner) {
+			return c.Delete(context.TODO(), currentIngress)
+		}
+		return nil
+	}
+
+	desiredIngress := newUploadProxyIngress(namespace, ingress)
+	if exists {
+		if !reflect.DeepEqual(currentIngress.Spec, desiredIngress.Spec) ||
+			!reflect.DeepEqual(currentIngress.Annotations, desiredIngress.Annotations) {
+			currentIngress.Spec = desiredIngress.Spec
+			currentIngress.Annotations = desiredIngress.Annotations
+			return c.Update(context.TODO(), currentIngress)
+		}
+		return nil
+	}
+
+	if err = controllerutil.SetControllerReference(owner, desiredIngress, scheme); err != nil {
+		return err
+	}
+
+	return c.Create(context.TODO(), desiredIngress)
+}
+
+// newUploadProxyIngress returns the Ingress of the upload proxy in namespace. Its annotations configure the NGINX
+// Ingress controller, which buffers request bodies and closes connections after a minute unless told otherwise.
+func newUploadProxyIngress(namespace string, ingress *cdiv1alpha1.CDIUploadProxyIngress) *networkingv1beta1.Ingress {
+	timeout := strconv.FormatInt(int64(uploadProxyTimeout(ingress).Seconds()), 10)
+	annotations := map[string]string{
+		"nginx.ingress.kubernetes.io/proxy-body-size":         "0",
+		"nginx.ingress.kubernetes.io/proxy-request-buffering": "off",
+		"nginx.ingress.kubernetes.io/proxy-read-timeout":      timeout,
+		"nginx.ingress.kubernetes.io/proxy-send-timeout":      timeout,
+	}
+	tls := networkingv1beta1.IngressTLS{Hosts: []string{ingress.Host}}
+	if ingress.TLSTermination == cdiv1alpha1.CDIUploadProxyTLSTerminationPassthrough {
+		annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] = "true"
+	} else {
+		annotations["nginx.ingress.kubernetes.io/backend-protocol"] = "HTTPS"
+		tls.SecretName = ingress.TLSSecret
+	}
+	if ingress.IngressClass != "" {
+		annotations[ingressClassAnnotation] = ingress.IngressClass
+	}
+
+	return &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      uploadProxyIngressName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"cdi.kubevirt.io": "",
+			},
+			Annotations: annotations,
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			TLS: []networkingv1beta1.IngressTLS{tls},
+			Rules: []networkingv1beta1.IngressRule{
+				{
+					Host: ingress.Host,
+					IngressRuleValue: networkingv1beta1.IngressRuleValue{
+						HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+							Paths: []networkingv1beta1.HTTPIngressPath{
+								{
+									Backend: networkingv1beta1.IngressBackend{
+										ServiceName: uploadProxyServiceName,
+										ServicePort: intstr.FromInt(443),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *ReconcileCDI) watchIngresses() error {
+	return r.controller.Watch(
+		&source.Kind{Type: &networkingv1beta1.Ingress{}},
+		enqueueCDI(r.client),
+	)
+}
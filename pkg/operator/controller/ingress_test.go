@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	cdiv1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Upload proxy Ingress", func() {
+	It("should pass TLS through to the upload proxy", func() {
+		ingress := newUploadProxyIngress(cdiNamespace, &cdiv1alpha1.CDIUploadProxyIngress{
+			Host:           "upload.example.com",
+			TLSTermination: cdiv1alpha1.CDIUploadProxyTLSTerminationPassthrough,
+			IngressClass:   "nginx",
+		})
+		Expect(ingress.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/ssl-passthrough", "true"))
+		Expect(ingress.Annotations).To(HaveKeyWithValue(ingressClassAnnotation, "nginx"))
+		Expect(ingress.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/proxy-read-timeout", "3600"))
+		Expect(ingress.Spec.Rules[0].Host).To(Equal("upload.example.com"))
+		Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName).To(Equal(uploadProxyServiceName))
+		Expect(ingress.Spec.TLS[0].SecretName).To(BeEmpty())
+	})
+
+	It("should reencrypt with the certificate of the TLS secret", func() {
+		ingress := newUploadProxyIngress(cdiNamespace, &cdiv1alpha1.CDIUploadProxyIngress{
+			Host:      "upload.example.com",
+			TLSSecret: "upload-cert",
+			Timeout:   &metav1.Duration{Duration: 90 * time.Minute},
+		})
+		Expect(ingress.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/backend-protocol", "HTTPS"))
+		Expect(ingress.Annotations).To(HaveKeyWithValue("nginx.ingress.kubernetes.io/proxy-send-timeout", "5400"))
+		Expect(ingress.Annotations).ToNot(HaveKey(ingressClassAnnotation))
+		Expect(ingress.Spec.TLS[0].SecretName).To(Equal("upload-cert"))
+	})
+
+	It("should create, update and delete the Ingress as the CR asks for", func() {
+		owner := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "cdi-deployment", Namespace: cdiNamespace, UID: "uid"}}
+		c := createClient(owner)
+		logger := logf.Log.WithName("ingress-test")
+		key := client.ObjectKey{Namespace: cdiNamespace, Name: uploadProxyIngressName}
+
+		spec := &cdiv1alpha1.CDIUploadProxyIngress{Host: "upload.example.com"}
+		Expect(ensureUploadProxyIngressExists(logger, c, scheme.Scheme, owner, spec)).To(Succeed())
+		ingress := &networkingv1beta1.Ingress{}
+		Expect(c.Get(context.TODO(), key, ingress)).To(Succeed())
+		Expect(metav1.IsControlledBy(ingress, owner)).To(BeTrue())
+
+		spec.Host = "upload.example.org"
+		Expect(ensureUploadProxyIngressExists(logger, c, scheme.Scheme, owner, spec)).To(Succeed())
+		Expect(c.Get(context.TODO(), key, ingress)).To(Succeed())
+		Expect(ingress.Spec.Rules[0].Host).To(Equal("upload.example.org"))
+
+		Expect(ensureUploadProxyIngressExists(logger, c, scheme.Scheme, owner, nil)).To(Succeed())
+		Expect(errors.IsNotFound(c.Get(context.TODO(), key, ingress))).To(BeTrue())
+	})
+})
@@ -0,0 +1,166 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	cloneSourcePodMonitorName  = "cdi-clone-source-metrics"
+	transferPodMonitorName     = "cdi-transfer-pod-metrics"
+	metricsCABundle            = "cdi-metrics-signer-bundle"
+	metricsClientCertSecret    = "cdi-metrics-client-cert"
+	transferPodMetricsPortName = "metrics"
+)
+
+// podMonitorGVK is the PodMonitor of the prometheus operator, whose API is not vendored
+var podMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PodMonitor"}
+
+// ensurePodMonitorsExist has the prometheus operator scrape the metrics of the import, upload and clone pods. The
+// clone source pods present a certificate signed by the metrics CA, and ask for the metrics client certificate. The
+// other transfer pods present a self signed certificate. Without the prometheus operator it does nothing.
+func ensurePodMonitorsExist(logger logr.Logger, c client.Client, scheme *runtime.Scheme, owner metav1.Object) error {
+	namespace := owner.GetNamespace()
+	for _, desired := range []*unstructured.Unstructured{newCloneSourcePodMonitor(namespace), newTransferPodMonitor(namespace)} {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(podMonitorGVK)
+		key := client.ObjectKey{Namespace: namespace, Name: desired.GetName()}
+		err := c.Get(context.TODO(), key, current)
+		if err == nil {
+			if !reflect.DeepEqual(current.Object["spec"], desired.Object["spec"]) {
+				current.Object["spec"] = desired.Object["spec"]
+				if err := c.Update(context.TODO(), current); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if meta.IsNoMatchError(err) {
+			logger.V(3).Info("No match error for PodMonitor, prometheus operator must not be installed")
+			return nil
+		}
+
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		if err = controllerutil.SetControllerReference(owner, desired, scheme); err != nil {
+			return err
+		}
+
+		if err = c.Create(context.TODO(), desired); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCloneSourcePodMonitor returns the PodMonitor of the clone source pods in all namespaces, verified with the
+// metrics CA bundle in namespace
+func newCloneSourcePodMonitor(namespace string) *unstructured.Unstructured {
+	return newPodMonitor(namespace, cloneSourcePodMonitorName,
+		map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				common.CDIComponentLabel: common.ClonerSourcePodName,
+				common.PrometheusLabel:   "",
+			},
+		},
+		map[string]interface{}{
+			"ca": map[string]interface{}{
+				"configMap": map[string]interface{}{
+					"name": metricsCABundle,
+					"key":  "ca-bundle.crt",
+				},
+			},
+			"cert": map[string]interface{}{
+				"secret": map[string]interface{}{
+					"name": metricsClientCertSecret,
+					"key":  "tls.crt",
+				},
+			},
+			"keySecret": map[string]interface{}{
+				"name": metricsClientCertSecret,
+				"key":  "tls.key",
+			},
+			// the pods are scraped by IP, the certificate has the name of the pods
+			"serverName": common.ClonerSourcePodName,
+		})
+}
+
+// newTransferPodMonitor returns the PodMonitor of the transfer pods, other than the clone source pods, in all
+// namespaces
+func newTransferPodMonitor(namespace string) *unstructured.Unstructured {
+	return newPodMonitor(namespace, transferPodMonitorName,
+		map[string]interface{}{
+			"matchExpressions": []interface{}{
+				map[string]interface{}{
+					"key":      common.PrometheusLabel,
+					"operator": "Exists",
+				},
+				map[string]interface{}{
+					"key":      common.CDIComponentLabel,
+					"operator": "NotIn",
+					"values":   []interface{}{common.ClonerSourcePodName},
+				},
+			},
+		},
+		map[string]interface{}{
+			"insecureSkipVerify": true,
+		})
+}
+
+func newPodMonitor(namespace, name string, selector, tlsConfig map[string]interface{}) *unstructured.Unstructured {
+	podMonitor := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"selector": selector,
+				"namespaceSelector": map[string]interface{}{
+					"any": true,
+				},
+				"podMetricsEndpoints": []interface{}{
+					map[string]interface{}{
+						"port":      transferPodMetricsPortName,
+						"scheme":    "https",
+						"tlsConfig": tlsConfig,
+					},
+				},
+			},
+		},
+	}
+	podMonitor.SetGroupVersionKind(podMonitorGVK)
+	podMonitor.SetNamespace(namespace)
+	podMonitor.SetName(name)
+	podMonitor.SetLabels(map[string]string{
+		"cdi.kubevirt.io": "",
+	})
+	return podMonitor
+}
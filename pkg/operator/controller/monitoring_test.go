@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The CDI Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var _ = Describe("Transfer pod PodMonitors", func() {
+	getPodMonitor := func(c client.Client, name string) *unstructured.Unstructured {
+		podMonitor := &unstructured.Unstructured{}
+		podMonitor.SetGroupVersionKind(podMonitorGVK)
+		Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: cdiNamespace, Name: name}, podMonitor)).To(Succeed())
+		return podMonitor
+	}
+
+	It("should verify the clone source pods with the metrics CA and present the metrics client certificate", func() {
+		podMonitor := newCloneSourcePodMonitor(cdiNamespace)
+		endpoints, _, _ := unstructured.NestedSlice(podMonitor.Object, "spec", "podMetricsEndpoints")
+		Expect(endpoints).To(HaveLen(1))
+		endpoint := endpoints[0].(map[string]interface{})
+		Expect(endpoint).To(HaveKeyWithValue("port", "metrics"))
+		Expect(endpoint).To(HaveKeyWithValue("scheme", "https"))
+		ca, _, _ := unstructured.NestedString(endpoint, "tlsConfig", "ca", "configMap", "name")
+		Expect(ca).To(Equal("cdi-metrics-signer-bundle"))
+		cert, _, _ := unstructured.NestedString(endpoint, "tlsConfig", "cert", "secret", "name")
+		Expect(cert).To(Equal("cdi-metrics-client-cert"))
+		key, _, _ := unstructured.NestedString(endpoint, "tlsConfig", "keySecret", "name")
+		Expect(key).To(Equal("cdi-metrics-client-cert"))
+		serverName, _, _ := unstructured.NestedString(endpoint, "tlsConfig", "serverName")
+		Expect(serverName).To(Equal("cdi-clone-source"))
+		any, _, _ := unstructured.NestedBool(podMonitor.Object, "spec", "namespaceSelector", "any")
+		Expect(any).To(BeTrue())
+	})
+
+	It("should leave the clone source pods out of the PodMonitor of the other transfer pods", func() {
+		podMonitor := newTransferPodMonitor(cdiNamespace)
+		expressions, _, _ := unstructured.NestedSlice(podMonitor.Object, "spec", "selector", "matchExpressions")
+		Expect(expressions).To(ContainElement(map[string]interface{}{
+			"key":      "cdi.kubevirt.io",
+			"operator": "NotIn",
+			"values":   []interface{}{"cdi-clone-source"},
+		}))
+	})
+
+	It("should create and update the PodMonitors", func() {
+		owner := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "cdi-deployment", Namespace: cdiNamespace, UID: "uid"}}
+		c := createClient(owner)
+		logger := logf.Log.WithName("monitoring-test")
+
+		Expect(ensurePodMonitorsExist(logger, c, scheme.Scheme, owner)).To(Succeed())
+		for _, name := range []string{cloneSourcePodMonitorName, transferPodMonitorName} {
+			Expect(metav1.IsControlledBy(getPodMonitor(c, name), owner)).To(BeTrue())
+		}
+
+		podMonitor := getPodMonitor(c, transferPodMonitorName)
+		Expect(unstructured.SetNestedField(podMonitor.Object, false, "spec", "namespaceSelector", "any")).To(Succeed())
+		Expect(c.Update(context.TODO(), podMonitor)).To(Succeed())
+		Expect(ensurePodMonitorsExist(logger, c, scheme.Scheme, owner)).To(Succeed())
+		any, _, _ := unstructured.NestedBool(getPodMonitor(c, transferPodMonitorName).Object, "spec", "namespaceSelector", "any")
+		Expect(any).To(BeTrue())
+	})
+})
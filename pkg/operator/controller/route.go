@@ -19,6 +19,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	routev1 "github.com/openshift/api/route/v1"
@@ -30,15 +31,32 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	cdiv1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 )
 
 const (
 	uploadProxyServiceName = "cdi-uploadproxy"
 	uploadProxyRouteName   = uploadProxyServiceName
 	uploadProxyCABundle    = "cdi-uploadproxy-signer-bundle"
+
+	routeTimeoutAnnotation = "haproxy.router.openshift.io/timeout"
+
+	// long timeout here to make sure client conection doesn't die during qcow->raw conversion
+	defaultUploadProxyTimeout = 60 * time.Minute
 )
 
-func ensureUploadProxyRouteExists(logger logr.Logger, c client.Client, scheme *runtime.Scheme, owner metav1.Object) error {
+// uploadProxyTimeout returns how long the Route or Ingress of the upload proxy waits for it to answer
+func uploadProxyTimeout(ingress *cdiv1alpha1.CDIUploadProxyIngress) time.Duration {
+	if ingress != nil && ingress.Timeout != nil && ingress.Timeout.Duration > 0 {
+		return ingress.Timeout.Duration
+	}
+	return defaultUploadProxyTimeout
+}
+
+// ensureUploadProxyRouteExists exposes the upload proxy with a Route as ingress asks for, with a reencrypt Route with
+// a generated hostname if ingress is nil. Outside of OpenShift it exposes it with an Ingress instead.
+func ensureUploadProxyRouteExists(logger logr.Logger, c client.Client, scheme *runtime.Scheme, owner metav1.Object, ingress *cdiv1alpha1.CDIUploadProxyIngress) error {
 	namespace := owner.GetNamespace()
 	if namespace == "" {
 		return fmt.Errorf("cluster scoped owner not supported")
@@ -59,40 +77,29 @@ func ensureUploadProxyRouteExists(logger logr.Logger, c client.Client, scheme *r
 		return fmt.Errorf("unexpected ConfigMap format, 'ca-bundle.crt' key missing")
 	}
 
-	desiredRoute := &routev1.Route{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      uploadProxyRouteName,
-			Namespace: namespace,
-			Labels: map[string]string{
-				"cdi.kubevirt.io": "",
-			},
-			Annotations: map[string]string{
-				// long timeout here to make sure client conection doesn't die during qcow->raw conversion
-				"haproxy.router.openshift.io/timeout": "60m",
-			},
-		},
-		Spec: routev1.RouteSpec{
-			To: routev1.RouteTargetReference{
-				Kind: "Service",
-				Name: uploadProxyServiceName,
-			},
-			TLS: &routev1.TLSConfig{
-				Termination:              routev1.TLSTerminationReencrypt,
-				DestinationCACertificate: string(cert),
-			},
-		},
-	}
+	desiredRoute := newUploadProxyRoute(namespace, cert, ingress)
 
 	currentRoute := &routev1.Route{}
 	key = client.ObjectKey{Namespace: namespace, Name: uploadProxyRouteName}
 	err := c.Get(context.TODO(), key, currentRoute)
 	if err == nil {
+		if desiredRoute.Spec.Host == "" {
+			// keep the hostname generated for the route
+			desiredRoute.Spec.Host = currentRoute.Spec.Host
+		}
+		timeout := desiredRoute.Annotations[routeTimeoutAnnotation]
 		if currentRoute.Spec.To.Kind != desiredRoute.Spec.To.Kind ||
 			currentRoute.Spec.To.Name != desiredRoute.Spec.To.Name ||
+			currentRoute.Spec.Host != desiredRoute.Spec.Host ||
 			currentRoute.Spec.TLS == nil ||
 			currentRoute.Spec.TLS.Termination != desiredRoute.Spec.TLS.Termination ||
-			currentRoute.Spec.TLS.DestinationCACertificate != desiredRoute.Spec.TLS.DestinationCACertificate {
+			currentRoute.Spec.TLS.DestinationCACertificate != desiredRoute.Spec.TLS.DestinationCACertificate ||
+			currentRoute.Annotations[routeTimeoutAnnotation] != timeout {
 			currentRoute.Spec = desiredRoute.Spec
+			if currentRoute.Annotations == nil {
+				currentRoute.Annotations = make(map[string]string)
+			}
+			currentRoute.Annotations[routeTimeoutAnnotation] = timeout
 			return c.Update(context.TODO(), currentRoute)
 		}
 
@@ -102,7 +109,7 @@ func ensureUploadProxyRouteExists(logger logr.Logger, c client.Client, scheme *r
 	if meta.IsNoMatchError(err) {
 		// not in openshift
 		logger.V(3).Info("No match error for Route, must not be in openshift")
-		return nil
+		return ensureUploadProxyIngressExists(logger, c, scheme, owner, ingress)
 	}
 
 	if !errors.IsNotFound(err) {
@@ -116,6 +123,40 @@ func ensureUploadProxyRouteExists(logger logr.Logger, c client.Client, scheme *r
 	return c.Create(context.TODO(), desiredRoute)
 }
 
+// newUploadProxyRoute returns the Route of the upload proxy in namespace, cert is the CA certificate of the upload proxy
+func newUploadProxyRoute(namespace, cert string, ingress *cdiv1alpha1.CDIUploadProxyIngress) *routev1.Route {
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      uploadProxyRouteName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"cdi.kubevirt.io": "",
+			},
+			Annotations: map[string]string{
+				routeTimeoutAnnotation: fmt.Sprintf("%ds", int64(uploadProxyTimeout(ingress).Seconds())),
+			},
+		},
+		Spec: routev1.RouteSpec{
+			To: routev1.RouteTargetReference{
+				Kind: "Service",
+				Name: uploadProxyServiceName,
+			},
+			TLS: &routev1.TLSConfig{
+				Termination:              routev1.TLSTerminationReencrypt,
+				DestinationCACertificate: cert,
+			},
+		},
+	}
+	if ingress != nil {
+		route.Spec.Host = ingress.Host
+		if ingress.TLSTermination == cdiv1alpha1.CDIUploadProxyTLSTerminationPassthrough {
+			// the upload proxy presents its own certificate, or the one of a virtual host secret
+			route.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationPassthrough}
+		}
+	}
+	return route
+}
+
 func (r *ReconcileCDI) watchRoutes() error {
 	err := r.controller.Watch(
 		&source.Kind{Type: &routev1.Route{}},
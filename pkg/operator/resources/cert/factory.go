@@ -118,6 +118,22 @@ func createCertificateDefinitions() []CertificateDefinition {
 			TargetRefresh:       24 * time.Hour,
 			TargetUser:          &[]string{"client.upload-server.cdi.kubevirt.io"}[0],
 		},
+		{
+			SignerSecret:        createSecret("cdi-metrics-signer"),
+			SignerValidity:      10 * 365 * day,
+			SignerRefresh:       8 * 365 * day,
+			CertBundleConfigmap: createConfigMap("cdi-metrics-signer-bundle"),
+		},
+		{
+			SignerSecret:        createSecret("cdi-metrics-client-signer"),
+			SignerValidity:      10 * 365 * day,
+			SignerRefresh:       8 * 365 * day,
+			CertBundleConfigmap: createConfigMap("cdi-metrics-client-signer-bundle"),
+			TargetSecret:        createSecret("cdi-metrics-client-cert"),
+			TargetValidity:      48 * time.Hour,
+			TargetRefresh:       24 * time.Hour,
+			TargetUser:          &[]string{"client.metrics.cdi.kubevirt.io"}[0],
+		},
 	}
 }
 
@@ -88,6 +88,29 @@ func getAPIServerClusterPolicyRules() []rbacv1.PolicyRule {
 			Resources: []string{
 				"persistentvolumeclaims",
 			},
+			Verbs: []string{
+				"get",
+				"update",
+			},
+		},
+		{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"namespaces",
+			},
+			Verbs: []string{
+				"get",
+			},
+		},
+		{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"persistentvolumes",
+			},
 			Verbs: []string{
 				"get",
 			},
@@ -103,6 +126,17 @@ func getAPIServerClusterPolicyRules() []rbacv1.PolicyRule {
 				"list",
 			},
 		},
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"datavolumetemplates",
+			},
+			Verbs: []string{
+				"get",
+			},
+		},
 		{
 			APIGroups: []string{
 				"cdi.kubevirt.io",
@@ -48,6 +48,7 @@ func getControllerClusterPolicyRules() []rbacv1.PolicyRule {
 			},
 			Verbs: []string{
 				"create",
+				"list",
 				"patch",
 			},
 		},
@@ -68,6 +69,20 @@ func getControllerClusterPolicyRules() []rbacv1.PolicyRule {
 				"delete",
 			},
 		},
+		{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"persistentvolumes",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+				"update",
+			},
+		},
 		{
 			APIGroups: []string{
 				"",
@@ -97,6 +112,32 @@ func getControllerClusterPolicyRules() []rbacv1.PolicyRule {
 				"delete",
 			},
 		},
+		{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"pods/log",
+			},
+			Verbs: []string{
+				"get",
+			},
+		},
+		{
+			APIGroups: []string{
+				"policy",
+			},
+			Resources: []string{
+				"poddisruptionbudgets",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+				"create",
+				"delete",
+			},
+		},
 		{
 			APIGroups: []string{
 				"extensions",
@@ -116,11 +157,27 @@ func getControllerClusterPolicyRules() []rbacv1.PolicyRule {
 			},
 			Resources: []string{
 				"configmaps",
+				"serviceaccounts",
+				"namespaces",
 			},
 			Verbs: []string{
 				"get",
 			},
 		},
+		{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"nodes",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+				"update",
+			},
+		},
 		{
 			APIGroups: []string{
 				"storage.k8s.io",
@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	extv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kubevirt.io/containerized-data-importer/pkg/operator/resources/utils"
+)
+
+func createDataVolumeTemplateCRD() *extv1beta1.CustomResourceDefinition {
+	return &extv1beta1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apiextensions.k8s.io/v1beta1",
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "datavolumetemplates.cdi.kubevirt.io",
+			Labels: utils.WithCommonLabels(nil),
+		},
+		Spec: extv1beta1.CustomResourceDefinitionSpec{
+			Group: "cdi.kubevirt.io",
+			Names: extv1beta1.CustomResourceDefinitionNames{
+				Kind:   "DataVolumeTemplate",
+				Plural: "datavolumetemplates",
+				ShortNames: []string{
+					"dvt",
+					"dvts",
+				},
+				Singular: "datavolumetemplate",
+				Categories: []string{
+					"all",
+				},
+			},
+			Version: "v1alpha1",
+			Scope:   "Namespaced",
+		},
+	}
+}
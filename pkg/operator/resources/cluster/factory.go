@@ -44,6 +44,7 @@ var staticFactoryFunctions = factoryFuncMap{
 	"crd-resources":    createCRDResources,
 	"uploadproxy-rbac": createUploadProxyResources,
 	"aggregate-roles":  createAggregateClusterRoles,
+	"priority-classes": createPriorityClasses,
 }
 
 var dynamicFactoryFunctions = factoryFuncMap{
@@ -54,6 +55,9 @@ func createCRDResources(args *FactoryArgs) []runtime.Object {
 	return []runtime.Object{
 		createDataVolumeCRD(),
 		createCDIConfigCRD(),
+		createCDIQuotaCRD(),
+		createOperationHistoryCRD(),
+		createDataVolumeTemplateCRD(),
 	}
 }
 
@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/operator/resources/utils"
+)
+
+const (
+	// low priority transfers give way to the pods without a PriorityClass, which have priority 0
+	transferLowPriority = -1000
+	// high priority transfers preempt the pods of lower priority when the nodes are full
+	transferHighPriority = 1000
+)
+
+func createPriorityClasses(args *FactoryArgs) []runtime.Object {
+	preemptNever := corev1.PreemptNever
+	preemptLowerPriority := corev1.PreemptLowerPriority
+	return []runtime.Object{
+		createPriorityClass(common.TransferLowPriorityClass, transferLowPriority, &preemptNever,
+			"Priority of the transfer pods of CDI DataVolumes with low priority"),
+		createPriorityClass(common.TransferHighPriorityClass, transferHighPriority, &preemptLowerPriority,
+			"Priority of the transfer pods of CDI DataVolumes with high priority"),
+	}
+}
+
+func createPriorityClass(name string, value int32, preemptionPolicy *corev1.PreemptionPolicy, description string) *schedulingv1.PriorityClass {
+	return &schedulingv1.PriorityClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "scheduling.k8s.io/v1",
+			Kind:       "PriorityClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: utils.WithCommonLabels(nil),
+		},
+		Value:            value,
+		PreemptionPolicy: preemptionPolicy,
+		Description:      description,
+	}
+}
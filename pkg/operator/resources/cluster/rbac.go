@@ -87,6 +87,7 @@ func createAggregateClusterRoles(args *FactoryArgs) []runtime.Object {
 		createAggregateClusterRole("cdi.kubevirt.io:admin", "admin", getAdminPolicyRules()),
 		createAggregateClusterRole("cdi.kubevirt.io:edit", "edit", getEditPolicyRules()),
 		createAggregateClusterRole("cdi.kubevirt.io:view", "view", getViewPolicyRules()),
+		createCloneSourceClusterRole("cdi.kubevirt.io:clone-source"),
 		createConfigReaderClusterRole("cdi.kubevirt.io:config-reader"),
 		createConfigReaderClusterRoleBinding("cdi.kubevirt.io:config-reader"),
 	}
@@ -109,6 +110,7 @@ func getAdminPolicyRules() []rbacv1.PolicyRule {
 			},
 			Resources: []string{
 				"datavolumes",
+				"datavolumetemplates",
 			},
 			Verbs: []string{
 				"*",
@@ -123,6 +125,7 @@ func getAdminPolicyRules() []rbacv1.PolicyRule {
 			},
 			Verbs: []string{
 				"create",
+				"clone",
 			},
 		},
 		{
@@ -140,6 +143,20 @@ func getAdminPolicyRules() []rbacv1.PolicyRule {
 				"update",
 			},
 		},
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"cdiquotas",
+				"operationhistories",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+			},
+		},
 		{
 			APIGroups: []string{
 				"upload.cdi.kubevirt.io",
@@ -168,6 +185,7 @@ func getViewPolicyRules() []rbacv1.PolicyRule {
 			},
 			Resources: []string{
 				"datavolumes",
+				"datavolumetemplates",
 			},
 			Verbs: []string{
 				"get",
@@ -184,6 +202,7 @@ func getViewPolicyRules() []rbacv1.PolicyRule {
 			},
 			Verbs: []string{
 				"create",
+				"clone",
 			},
 		},
 		{
@@ -199,9 +218,44 @@ func getViewPolicyRules() []rbacv1.PolicyRule {
 				"watch",
 			},
 		},
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"cdiquotas",
+				"operationhistories",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+			},
+		},
 	}
 }
 
+// createCloneSourceClusterRole creates the role to bind in a namespace to allow cloning from it, and nothing else
+func createCloneSourceClusterRole(name string) *rbacv1.ClusterRole {
+	role := CreateClusterRole(name)
+
+	role.Rules = []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"datavolumes/source",
+			},
+			Verbs: []string{
+				"clone",
+			},
+		},
+	}
+
+	return role
+}
+
 func createConfigReaderClusterRole(name string) *rbacv1.ClusterRole {
 	role := CreateClusterRole(name)
 
@@ -41,6 +41,33 @@ func getUploadProxyClusterPolicyRules() []rbacv1.PolicyRule {
 			Resources: []string{
 				"persistentvolumeclaims",
 			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+				"update",
+			},
+		},
+		{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"namespaces",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+			},
+		},
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"cdiconfigs",
+			},
 			Verbs: []string{
 				"get",
 			},
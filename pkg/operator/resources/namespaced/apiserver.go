@@ -40,7 +40,7 @@ func createAPIServerResources(args *FactoryArgs) []runtime.Object {
 		createAPIServerRoleBinding(),
 		createAPIServerRole(),
 		createAPIServerService(),
-		createAPIServerDeployment(args.APIServerImage, args.Verbosity, args.PullPolicy),
+		createAPIServerDeployment(args.APIServerImage, args.Verbosity, args.PullPolicy, args.TokenSigningAlgorithm),
 	}
 }
 
@@ -86,9 +86,17 @@ func createAPIServerService() *corev1.Service {
 	return service
 }
 
-func createAPIServerDeployment(image, verbosity, pullPolicy string) *appsv1.Deployment {
+func createAPIServerDeployment(image, verbosity, pullPolicy, tokenSigningAlgorithm string) *appsv1.Deployment {
 	deployment := utils.CreateDeployment(apiServerRessouceName, cdiLabel, apiServerRessouceName, apiServerRessouceName, 1)
 	container := utils.CreateContainer(apiServerRessouceName, image, verbosity, corev1.PullPolicy(pullPolicy))
+	if tokenSigningAlgorithm != "" {
+		container.Env = []corev1.EnvVar{
+			{
+				Name:  common.TokenSigningAlgorithm,
+				Value: tokenSigningAlgorithm,
+			},
+		}
+	}
 	container.ReadinessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
 			HTTPGet: &corev1.HTTPGetAction{
@@ -46,6 +46,7 @@ func createControllerResources(args *FactoryArgs) []runtime.Object {
 			args.Verbosity,
 			args.PullPolicy),
 		createInsecureRegConfigMap(),
+		createRegistryMirrorConfigMap(),
 	}
 }
 
@@ -148,6 +149,14 @@ func createControllerDeployment(controllerImage, importerImage, clonerImage, upl
 			Name:      "uploadserver-client-ca-bundle",
 			MountPath: "/var/run/ca-bundle/cdi-uploadserver-client-signer-bundle",
 		},
+		{
+			Name:      "metrics-ca-cert",
+			MountPath: "/var/run/certs/cdi-metrics-signer",
+		},
+		{
+			Name:      "metrics-client-cert",
+			MountPath: "/var/run/certs/cdi-metrics-client-cert",
+		},
 	}
 	deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
 	deployment.Spec.Template.Spec.Volumes = []corev1.Volume{
@@ -233,6 +242,42 @@ func createControllerDeployment(controllerImage, importerImage, clonerImage, upl
 				},
 			},
 		},
+		{
+			Name: "metrics-ca-cert",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: "cdi-metrics-signer",
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "tls.crt",
+							Path: "tls.crt",
+						},
+						{
+							Key:  "tls.key",
+							Path: "tls.key",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "metrics-client-cert",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: "cdi-metrics-client-cert",
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "tls.crt",
+							Path: "tls.crt",
+						},
+						{
+							Key:  "tls.key",
+							Path: "tls.key",
+						},
+					},
+				},
+			},
+		},
 	}
 	return deployment
 }
@@ -249,3 +294,16 @@ func createInsecureRegConfigMap() *corev1.ConfigMap {
 		},
 	}
 }
+
+func createRegistryMirrorConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   common.RegistryMirrorConfigMap,
+			Labels: utils.WithCommonLabels(nil),
+		},
+	}
+}
@@ -36,6 +36,10 @@ type FactoryArgs struct {
 	Verbosity              string `required:"true"`
 	PullPolicy             string `required:"true" split_words:"true"`
 	Namespace              string
+
+	UploadProxyVirtualHostSecrets []string `ignored:"true"`
+	UploadProxyTrustedProxies     []string `ignored:"true"`
+	TokenSigningAlgorithm         string   `ignored:"true"`
 }
 
 type factoryFunc func(*FactoryArgs) []runtime.Object
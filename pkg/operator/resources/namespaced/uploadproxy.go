@@ -17,12 +17,16 @@ limitations under the License.
 package namespaced
 
 import (
+	"strings"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
 	utils "kubevirt.io/containerized-data-importer/pkg/operator/resources/utils"
 )
 
@@ -35,8 +39,8 @@ func createUploadProxyResources(args *FactoryArgs) []runtime.Object {
 		createUploadProxyServiceAccount(),
 		createUploadProxyService(),
 		createUploadProxyRoleBinding(),
-		createUploadProxyRole(),
-		createUploadProxyDeployment(args.UploadProxyImage, args.Verbosity, args.PullPolicy),
+		createUploadProxyRole(args.UploadProxyVirtualHostSecrets),
+		createUploadProxyDeployment(args.UploadProxyImage, args.Verbosity, args.PullPolicy, args.UploadProxyVirtualHostSecrets, args.UploadProxyTrustedProxies),
 	}
 }
 
@@ -63,7 +67,7 @@ func createUploadProxyRoleBinding() *rbacv1.RoleBinding {
 	return utils.CreateRoleBinding(uploadProxyResourceName, uploadProxyResourceName, uploadProxyResourceName, "")
 }
 
-func createUploadProxyRole() *rbacv1.Role {
+func createUploadProxyRole(virtualHostSecrets []string) *rbacv1.Role {
 	role := utils.CreateRole(uploadProxyResourceName)
 	role.Rules = []rbacv1.PolicyRule{
 		{
@@ -78,24 +82,38 @@ func createUploadProxyRole() *rbacv1.Role {
 			},
 		},
 	}
+	// Only the virtual host secrets, the CDI namespace also has the signing keys
+	if len(virtualHostSecrets) > 0 {
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{
+				"",
+			},
+			Resources: []string{
+				"secrets",
+			},
+			ResourceNames: virtualHostSecrets,
+			Verbs: []string{
+				"get",
+			},
+		})
+	}
 	return role
 }
 
-func createUploadProxyDeployment(image, verbosity, pullPolicy string) *appsv1.Deployment {
+func createUploadProxyDeployment(image, verbosity, pullPolicy string, virtualHostSecrets, trustedProxies []string) *appsv1.Deployment {
 	deployment := utils.CreateDeployment(uploadProxyResourceName, cdiLabel, uploadProxyResourceName, uploadProxyResourceName, int32(1))
 	container := utils.CreateContainer(uploadProxyResourceName, image, verbosity, corev1.PullPolicy(pullPolicy))
-	container.Env = []corev1.EnvVar{
-		{
-			Name: "APISERVER_PUBLIC_KEY",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: "cdi-api-signing-key",
-					},
-					Key: "id_rsa.pub",
-				},
-			},
-		},
+	if len(virtualHostSecrets) > 0 {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  common.UploadProxyVirtualHostSecrets,
+			Value: strings.Join(virtualHostSecrets, ","),
+		})
+	}
+	if len(trustedProxies) > 0 {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  common.UploadProxyTrustedProxies,
+			Value: strings.Join(trustedProxies, ","),
+		})
 	}
 	container.ReadinessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
@@ -122,6 +140,11 @@ func createUploadProxyDeployment(image, verbosity, pullPolicy string) *appsv1.De
 			MountPath: "/var/run/certs/cdi-uploadserver-client-cert",
 			ReadOnly:  true,
 		},
+		{
+			Name:      "cdi-api-signing-key",
+			MountPath: controller.APIServerPublicKeyDir,
+			ReadOnly:  true,
+		},
 	}
 	deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
 	deployment.Spec.Template.Spec.Volumes = []corev1.Volume{
@@ -161,6 +184,20 @@ func createUploadProxyDeployment(image, verbosity, pullPolicy string) *appsv1.De
 				},
 			},
 		},
+		{
+			Name: "cdi-api-signing-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: "cdi-api-signing-key",
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "id_rsa.pub",
+							Path: "id_rsa.pub",
+						},
+					},
+				},
+			},
+		},
 	}
 	return deployment
 }
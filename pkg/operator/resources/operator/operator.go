@@ -130,6 +130,17 @@ func getClusterPolicyRules() []rbacv1.PolicyRule {
 				"*",
 			},
 		},
+		{
+			APIGroups: []string{
+				"scheduling.k8s.io",
+			},
+			Resources: []string{
+				"priorityclasses",
+			},
+			Verbs: []string{
+				"*",
+			},
+		},
 	}
 	rules = append(rules, cluster.GetClusterRolePolicyRules()...)
 	return rules
@@ -205,6 +216,28 @@ func getNamespacedPolicyRules() []rbacv1.PolicyRule {
 				"*",
 			},
 		},
+		{
+			APIGroups: []string{
+				"networking.k8s.io",
+			},
+			Resources: []string{
+				"ingresses",
+			},
+			Verbs: []string{
+				"*",
+			},
+		},
+		{
+			APIGroups: []string{
+				"monitoring.coreos.com",
+			},
+			Resources: []string{
+				"podmonitors",
+			},
+			Verbs: []string{
+				"*",
+			},
+		},
 	}
 	return rules
 }
@@ -350,6 +383,61 @@ func createCDIListCRD() *extv1beta1.CustomResourceDefinition {
 										},
 									},
 								},
+								"uploadProxyVirtualHostSecrets": {
+									Type: "array",
+									Items: &extv1beta1.JSONSchemaPropsOrArray{
+										Schema: &extv1beta1.JSONSchemaProps{
+											Type: "string",
+										},
+									},
+								},
+								"uploadProxyIngress": {
+									Properties: map[string]extv1beta1.JSONSchemaProps{
+										"host": {
+											Type: "string",
+										},
+										"tlsTermination": {
+											Type: "string",
+											Enum: []extv1beta1.JSON{
+												{
+													Raw: []byte(`"reencrypt"`),
+												},
+												{
+													Raw: []byte(`"passthrough"`),
+												},
+											},
+										},
+										"tlsSecret": {
+											Type: "string",
+										},
+										"ingressClass": {
+											Type: "string",
+										},
+										"timeout": {
+											Type: "string",
+										},
+									},
+									Type: "object",
+								},
+								"uploadProxyTrustedProxies": {
+									Type: "array",
+									Items: &extv1beta1.JSONSchemaPropsOrArray{
+										Schema: &extv1beta1.JSONSchemaProps{
+											Type: "string",
+										},
+									},
+								},
+								"tokenSigningAlgorithm": {
+									Type: "string",
+									Enum: []extv1beta1.JSON{
+										{
+											Raw: []byte(`"PS256"`),
+										},
+										{
+											Raw: []byte(`"ES256"`),
+										},
+									},
+								},
 							},
 							Type: "object",
 						},
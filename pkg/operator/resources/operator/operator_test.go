@@ -17,6 +17,20 @@ var crdTypeMap = map[string]interface{}{
 	"operator-crd": &cdiv1alpha1.CDI{},
 }
 
+// durationPaths are the metav1.Duration fields, strings in JSON, which the schema check takes for objects
+var durationPaths = []string{
+	"/spec/uploadProxyIngress/timeout",
+}
+
+func isDurationPath(path string) bool {
+	for _, durationPath := range durationPaths {
+		if path == durationPath || strings.HasPrefix(path, durationPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func TestCRDSchemas(t *testing.T) {
 	for crdFileName, cdiObjType := range crdTypeMap {
 
@@ -25,6 +39,8 @@ func TestCRDSchemas(t *testing.T) {
 		for _, missing := range missingEntries {
 			if strings.HasPrefix(missing.Path, "/status") {
 				//Not using subresources, so status is not expected to appear in CRD
+			} else if isDurationPath(missing.Path) {
+				//Durations are validated as strings
 			} else {
 				assert.Fail(t, "Discrepancy between CRD and Struct",
 					"Missing or incorrect schema validation at %v, expected type %v  in CRD file %v", missing.Path, missing.Type, crdFileName)
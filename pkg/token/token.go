@@ -20,7 +20,11 @@
 package token
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
+	"encoding/base64"
 	"time"
 
 	"gopkg.in/square/go-jose.v2"
@@ -39,6 +43,14 @@ const (
 	OperationUpload Operation = "Upload"
 )
 
+const (
+	// AlgorithmPS256 is the algorithm of the tokens signed with an RSA key, the default
+	AlgorithmPS256 = string(jose.PS256)
+
+	// AlgorithmES256 is the algorithm of the tokens signed with an ECDSA P-256 key
+	AlgorithmES256 = string(jose.ES256)
+)
+
 // Operation is the type of the token
 type Operation string
 
@@ -56,18 +68,60 @@ type Validator interface {
 	Validate(string) (*Payload, error)
 }
 
+// PublicKeys provides the keys that may have signed a token, RSA or ECDSA public keys
+type PublicKeys interface {
+	PublicKeys() []crypto.PublicKey
+}
+
+// PublicKeyList is a fixed list of PublicKeys
+type PublicKeyList []crypto.PublicKey
+
+// PublicKeys returns the keys of the list
+func (l PublicKeyList) PublicKeys() []crypto.PublicKey {
+	return l
+}
+
+// Algorithm returns the algorithm of the tokens signed with key
+func Algorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return AlgorithmPS256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve == elliptic.P256() {
+			return AlgorithmES256, nil
+		}
+	}
+	return "", errors.Errorf("unsupported signing key %T", key)
+}
+
+// KeyID returns the ID of key in the tokens it signed, the base64url encoded SHA-256 thumbprint of its JSON Web Key
+func KeyID(key crypto.PublicKey) (string, error) {
+	thumbprint, err := (&jose.JSONWebKey{Key: key}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
 type validator struct {
 	issuer string
-	key    *rsa.PublicKey
+	keys   PublicKeys
 	leeway time.Duration
 }
 
 // NewValidator return a new Validator implementation
-func NewValidator(issuer string, key *rsa.PublicKey, leeway time.Duration) Validator {
-	return &validator{issuer: issuer, key: key, leeway: leeway}
+func NewValidator(issuer string, key crypto.PublicKey, leeway time.Duration) Validator {
+	return NewKeysValidator(issuer, PublicKeyList{key}, leeway)
+}
+
+// NewKeysValidator returns a Validator accepting tokens signed with any of keys. The keys are looked up for each
+// token, so they may change while the Validator is in use.
+func NewKeysValidator(issuer string, keys PublicKeys, leeway time.Duration) Validator {
+	return &validator{issuer: issuer, keys: keys, leeway: leeway}
 }
 
-// Validate checks the token signature and returns the contents
+// Validate checks the token signature and returns the contents. A token with a key ID is only checked with the key
+// of that ID, one without, signed before key IDs were added, with all the keys.
 func (v *validator) Validate(token string) (*Payload, error) {
 	tok, err := jwt.ParseSigned(token)
 	if err != nil {
@@ -77,7 +131,22 @@ func (v *validator) Validate(token string) (*Payload, error) {
 	public := &jwt.Claims{}
 	private := &Payload{}
 
-	if err = tok.Claims(v.key, public, private); err != nil {
+	keyID := ""
+	if len(tok.Headers) > 0 {
+		keyID = tok.Headers[0].KeyID
+	}
+	err = errors.Errorf("no key to verify the token with key ID %q", keyID)
+	for _, key := range v.keys.PublicKeys() {
+		if keyID != "" {
+			if id, idErr := KeyID(key); idErr != nil || id != keyID {
+				continue
+			}
+		}
+		if err = tok.Claims(key, public, private); err == nil {
+			break
+		}
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -93,6 +162,11 @@ func (v *validator) Validate(token string) (*Payload, error) {
 	return private, nil
 }
 
+// IsExpired tells whether err is the error of validating a token after it expired
+func IsExpired(err error) bool {
+	return errors.Cause(err) == jwt.ErrExpired
+}
+
 // Generator generates tokens
 type Generator interface {
 	Generate(*Payload) (string, error)
@@ -100,18 +174,32 @@ type Generator interface {
 
 type generator struct {
 	issuer   string
-	key      *rsa.PrivateKey
+	key      crypto.Signer
 	lifetime time.Duration
 }
 
-// NewGenerator returns a new Generator
-func NewGenerator(issuer string, key *rsa.PrivateKey, lifetime time.Duration) Generator {
+// NewGenerator returns a new Generator signing with key, an RSA or an ECDSA P-256 private key
+func NewGenerator(issuer string, key crypto.Signer, lifetime time.Duration) Generator {
 	return &generator{issuer: issuer, key: key, lifetime: lifetime}
 }
 
 // Generate generates a token from the given parameters
 func (g *generator) Generate(payload *Payload) (string, error) {
-	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.PS256, Key: g.key}, nil)
+	algorithm, err := Algorithm(g.key)
+	if err != nil {
+		return "", err
+	}
+
+	keyID, err := KeyID(g.key.Public())
+	if err != nil {
+		return "", errors.Wrap(err, "error computing key ID")
+	}
+
+	signingKey := jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(algorithm),
+		Key:       jose.JSONWebKey{Key: g.key, KeyID: keyID},
+	}
+	signer, err := jose.NewSigner(signingKey, nil)
 	if err != nil {
 		return "", errors.Wrap(err, "error creating JWT signer")
 	}
@@ -128,3 +216,23 @@ func (g *generator) Generate(payload *Payload) (string, error) {
 		}).
 		CompactSerialize()
 }
+
+type rotatingGenerator struct {
+	previous Generator
+	current  Generator
+	activeAt time.Time
+}
+
+// NewRotatingGenerator returns a Generator that keeps signing with previous until activeAt, and with current after
+// it, so that the validators can pick up the key of current first
+func NewRotatingGenerator(previous, current Generator, activeAt time.Time) Generator {
+	return &rotatingGenerator{previous: previous, current: current, activeAt: activeAt}
+}
+
+// Generate generates a token with the generator active now
+func (g *rotatingGenerator) Generate(payload *Payload) (string, error) {
+	if time.Now().Before(g.activeAt) {
+		return g.previous.Generate(payload)
+	}
+	return g.current.Generate(payload)
+}
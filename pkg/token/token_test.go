@@ -19,12 +19,16 @@
 package token
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2/jwt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -113,6 +117,10 @@ func TestTokenTimeout(t *testing.T) {
 	if err == nil {
 		t.Errorf("token did not time out: %v", err)
 	}
+
+	if !IsExpired(errors.Wrap(err, "error verifying token")) {
+		t.Errorf("timed out token not reported as expired: %v", err)
+	}
 }
 
 func TestWrongIssuer(t *testing.T) {
@@ -147,6 +155,10 @@ func TestWrongIssuer(t *testing.T) {
 	if err == nil {
 		t.Errorf("bad issuer: %v", err)
 	}
+
+	if IsExpired(err) {
+		t.Errorf("bad issuer reported as expired: %v", err)
+	}
 }
 
 func TestBadKey(t *testing.T) {
@@ -191,3 +203,137 @@ func TestBadKey(t *testing.T) {
 		t.Errorf("validated with bad key: %v", err)
 	}
 }
+
+func TestKeysValidator(t *testing.T) {
+	issuer := "issuer"
+
+	key, err := generateTestKey()
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	key2, err := generateTestKey()
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	signedToken, err := NewGenerator(issuer, key, 5*time.Minute).Generate(&Payload{Operation: OperationClone})
+	if err != nil {
+		t.Errorf("unable to generate token: %v", err)
+	}
+
+	keys := PublicKeyList{&key2.PublicKey, &key.PublicKey}
+	payload, err := NewKeysValidator(issuer, keys, 0).Validate(signedToken)
+	if err != nil {
+		t.Errorf("not validated with the second key: %v", err)
+	} else if payload.Operation != OperationClone {
+		t.Errorf("wrong operation: %v", payload.Operation)
+	}
+
+	if _, err = NewKeysValidator(issuer, keys[:1], 0).Validate(signedToken); err == nil {
+		t.Errorf("validated without the signing key")
+	}
+
+	if _, err = NewKeysValidator(issuer, PublicKeyList{}, 0).Validate(signedToken); err == nil {
+		t.Errorf("validated without keys")
+	}
+}
+
+func TestECDSAToken(t *testing.T) {
+	issuer := "issuer"
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	signedToken, err := NewGenerator(issuer, key, 5*time.Minute).Generate(&Payload{Operation: OperationUpload})
+	if err != nil {
+		t.Errorf("unable to generate token: %v", err)
+	}
+
+	tok, err := jwt.ParseSigned(signedToken)
+	if err != nil {
+		t.Errorf("unable to parse token: %v", err)
+	}
+	if tok.Headers[0].Algorithm != AlgorithmES256 {
+		t.Errorf("wrong algorithm: %s", tok.Headers[0].Algorithm)
+	}
+
+	payload, err := NewValidator(issuer, &key.PublicKey, 0).Validate(signedToken)
+	if err != nil {
+		t.Errorf("not validated: %v", err)
+	} else if payload.Operation != OperationUpload {
+		t.Errorf("wrong operation: %v", payload.Operation)
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	issuer := "issuer"
+
+	key, err := generateTestKey()
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	signedToken, err := NewGenerator(issuer, ecKey, 5*time.Minute).Generate(&Payload{Operation: OperationUpload})
+	if err != nil {
+		t.Errorf("unable to generate token: %v", err)
+	}
+
+	tok, err := jwt.ParseSigned(signedToken)
+	if err != nil {
+		t.Errorf("unable to parse token: %v", err)
+	}
+	keyID, err := KeyID(&ecKey.PublicKey)
+	if err != nil {
+		t.Errorf("unable to compute key ID: %v", err)
+	}
+	if tok.Headers[0].KeyID != keyID {
+		t.Errorf("wrong key ID: got %q want %q", tok.Headers[0].KeyID, keyID)
+	}
+
+	if _, err = NewKeysValidator(issuer, PublicKeyList{&key.PublicKey, &ecKey.PublicKey}, 0).Validate(signedToken); err != nil {
+		t.Errorf("not validated with the key of its key ID: %v", err)
+	}
+}
+
+func TestRotatingGenerator(t *testing.T) {
+	issuer := "issuer"
+
+	previous, err := generateTestKey()
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	current, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Errorf("error generating keys: %v", err)
+	}
+
+	previousValidator := NewValidator(issuer, &previous.PublicKey, 0)
+	currentValidator := NewValidator(issuer, &current.PublicKey, 0)
+
+	g := NewRotatingGenerator(NewGenerator(issuer, previous, time.Minute), NewGenerator(issuer, current, time.Minute), time.Now().Add(time.Hour))
+	signedToken, err := g.Generate(&Payload{})
+	if err != nil {
+		t.Errorf("unable to generate token: %v", err)
+	}
+	if _, err = previousValidator.Validate(signedToken); err != nil {
+		t.Errorf("not signed with the previous key before the rotation: %v", err)
+	}
+
+	g = NewRotatingGenerator(NewGenerator(issuer, previous, time.Minute), NewGenerator(issuer, current, time.Minute), time.Now())
+	signedToken, err = g.Generate(&Payload{})
+	if err != nil {
+		t.Errorf("unable to generate token: %v", err)
+	}
+	if _, err = currentValidator.Validate(signedToken); err != nil {
+		t.Errorf("not signed with the current key after the rotation: %v", err)
+	}
+}
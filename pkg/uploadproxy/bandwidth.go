@@ -0,0 +1,179 @@
+package uploadproxy
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+)
+
+const (
+	// AnnUploadBandwidth is the namespace annotation with the bytes per second all uploads to the namespace may use together
+	AnnUploadBandwidth = controller.AnnAPIGroup + "/uploadBandwidth"
+
+	// minBandwidthBurst is the least a bucket holds, so that low limits do not split the body into tiny reads
+	minBandwidthBurst = 32 * 1024
+)
+
+// replaced in tests
+var (
+	timeNow   = time.Now
+	timeSleep = time.Sleep
+)
+
+// tokenBucket allows rate bytes per second on average, and bursts of up to burst bytes
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   int64
+	burst  int64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	b := &tokenBucket{last: timeNow()}
+	b.setRate(rate)
+	b.tokens = float64(b.burst)
+	return b
+}
+
+func (b *tokenBucket) setRate(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.burst = rate
+	if b.burst < minBandwidthBurst {
+		b.burst = minBandwidthBurst
+	}
+}
+
+// take removes n tokens from the bucket, and returns how long to wait until they would have been available.
+// A bucket with a rate of 0 no longer limits.
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	now := timeNow()
+	b.tokens += now.Sub(b.last).Seconds() * float64(b.rate)
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
+
+// limitedReader reads from r no faster than its bucket allows, the bucket is shared by all uploads to a namespace
+type limitedReader struct {
+	r      io.Reader
+	bucket *tokenBucket
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	l.bucket.mu.Lock()
+	burst := l.bucket.burst
+	l.bucket.mu.Unlock()
+	if int64(len(p)) > burst {
+		p = p[:burst]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if wait := l.bucket.take(n); wait > 0 {
+			timeSleep(wait)
+		}
+	}
+	return n, err
+}
+
+// bandwidthLimiter keeps the token buckets of the namespaces with an upload bandwidth limit
+type bandwidthLimiter struct {
+	mu         sync.Mutex
+	namespaces corelisters.NamespaceLister
+	buckets    map[string]*tokenBucket
+}
+
+// newBandwidthLimiter returns a bandwidthLimiter reading the namespaces from an informer, a changed limit applies
+// to the uploads in progress right away.
+func newBandwidthLimiter(client kubernetes.Interface, stopCh <-chan struct{}) *bandwidthLimiter {
+	informerFactory := informers.NewSharedInformerFactory(client, common.DefaultResyncPeriod)
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+	l := &bandwidthLimiter{namespaces: namespaceInformer.Lister()}
+	namespaceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.updateBucket(obj.(*v1.Namespace), false)
+		},
+	})
+	go informerFactory.Start(stopCh)
+	klog.V(3).Infoln("Waiting for namespace cache sync")
+	cache.WaitForCacheSync(stopCh, namespaceInformer.Informer().HasSynced)
+	return l
+}
+
+// limit wraps body, an upload to namespace, so that it is read no faster than the AnnUploadBandwidth annotation
+// of namespace allows. body is returned as is if the namespace has no limit.
+func (l *bandwidthLimiter) limit(namespace string, body io.Reader) (io.Reader, error) {
+	ns, err := l.namespaces.Get(namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get namespace %s", namespace)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket := l.updateBucket(ns, true)
+	if bucket == nil {
+		return body, nil
+	}
+	return &limitedReader{r: body, bucket: bucket}, nil
+}
+
+// updateBucket sets the rate of the bucket of ns to its AnnUploadBandwidth annotation, and returns the bucket. The
+// bucket is only created if create is set. Without a valid annotation the bucket stops limiting the uploads still
+// reading from it and is removed. Called with mu held.
+func (l *bandwidthLimiter) updateBucket(ns *v1.Namespace, create bool) *tokenBucket {
+	bucket, ok := l.buckets[ns.Name]
+	value, annotated := ns.GetAnnotations()[AnnUploadBandwidth]
+	rate, err := resource.ParseQuantity(value)
+	if !annotated || err != nil || rate.Value() <= 0 {
+		if annotated {
+			klog.Errorf("Ignoring invalid %s annotation %q of namespace %s", AnnUploadBandwidth, value, ns.Name)
+		}
+		if ok {
+			bucket.setRate(0)
+			delete(l.buckets, ns.Name)
+		}
+		return nil
+	}
+
+	if !ok {
+		if !create {
+			return nil
+		}
+		if l.buckets == nil {
+			l.buckets = make(map[string]*tokenBucket)
+		}
+		bucket = newTokenBucket(rate.Value())
+		l.buckets[ns.Name] = bucket
+	} else {
+		bucket.setRate(rate.Value())
+	}
+	klog.V(3).Infof("Limiting uploads to namespace %s to %d bytes per second", ns.Name, rate.Value())
+	return bucket
+}
@@ -0,0 +1,226 @@
+package uploadproxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// fakeClock replaces timeNow and timeSleep, sleeping advances the clock
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func setupFakeClock() (*fakeClock, func()) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timeNow = func() time.Time { return clock.now }
+	timeSleep = func(d time.Duration) {
+		clock.now = clock.now.Add(d)
+		clock.slept += d
+	}
+	return clock, func() {
+		timeNow = time.Now
+		timeSleep = time.Sleep
+	}
+}
+
+func createNamespace(name, bandwidth string) *corev1.Namespace {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	if bandwidth != "" {
+		namespace.Annotations = map[string]string{AnnUploadBandwidth: bandwidth}
+	}
+	return namespace
+}
+
+// createBandwidthLimiter returns a bandwidthLimiter reading namespaces from an indexer instead of an informer
+func createBandwidthLimiter(namespaces ...*corev1.Namespace) (*bandwidthLimiter, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, namespace := range namespaces {
+		indexer.Add(namespace)
+	}
+	return &bandwidthLimiter{namespaces: corelisters.NewNamespaceLister(indexer)}, indexer
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock, restore := setupFakeClock()
+	defer restore()
+
+	bucket := newTokenBucket(64 * 1024)
+	if wait := bucket.take(64 * 1024); wait != 0 {
+		t.Errorf("burst should not wait, waited %v", wait)
+	}
+	if wait := bucket.take(32 * 1024); wait != 500*time.Millisecond {
+		t.Errorf("wrong wait after the burst: got %v want %v", wait, 500*time.Millisecond)
+	}
+	clock.now = clock.now.Add(time.Minute)
+	if wait := bucket.take(64 * 1024); wait != 0 {
+		t.Errorf("bucket should refill no further than the burst, waited %v", wait)
+	}
+	if wait := bucket.take(64 * 1024); wait != time.Second {
+		t.Errorf("wrong wait for the next burst: got %v want %v", wait, time.Second)
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		bandwidth string
+		limited   bool
+	}{
+		{
+			"No annotation",
+			"",
+			false,
+		},
+		{
+			"Invalid annotation",
+			"fast",
+			false,
+		},
+		{
+			"Zero annotation",
+			"0",
+			false,
+		},
+		{
+			"Valid annotation",
+			"32Ki",
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock, restore := setupFakeClock()
+			defer restore()
+
+			limiter, _ := createBandwidthLimiter(createNamespace("tenant", test.bandwidth))
+			data := make([]byte, 128*1024)
+			body, err := limiter.limit("tenant", bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(read) != len(data) {
+				t.Errorf("wrong length read: got %d want %d", len(read), len(data))
+			}
+			var expected time.Duration
+			if test.limited {
+				// the first 32Ki are the burst
+				expected = 3 * time.Second
+			}
+			if clock.slept != expected {
+				t.Errorf("wrong time waited: got %v want %v", clock.slept, expected)
+			}
+		})
+	}
+}
+
+func TestBandwidthLimitShared(t *testing.T) {
+	clock, restore := setupFakeClock()
+	defer restore()
+
+	limiter, _ := createBandwidthLimiter(createNamespace("tenant", "32Ki"), createNamespace("other", "32Ki"))
+	for _, namespace := range []string{"tenant", "tenant", "other"} {
+		body, err := limiter.limit(namespace, bytes.NewReader(make([]byte, 64*1024)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// tenant uploads 128Ki of which 32Ki are the burst, other has a bucket of its own and has refilled
+	if clock.slept != 4*time.Second {
+		t.Errorf("wrong time waited: got %v want %v", clock.slept, 4*time.Second)
+	}
+}
+
+func TestBandwidthLimitMissingNamespace(t *testing.T) {
+	limiter, _ := createBandwidthLimiter()
+	if _, err := limiter.limit("tenant", bytes.NewReader(nil)); err == nil {
+		t.Error("expected an error for a missing namespace")
+	}
+}
+
+func TestBandwidthLimitChanged(t *testing.T) {
+	clock, restore := setupFakeClock()
+	defer restore()
+
+	limiter, _ := createBandwidthLimiter(createNamespace("tenant", "32Ki"))
+	body, err := limiter.limit("tenant", bytes.NewReader(make([]byte, 192*1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first 32Ki are the burst, the next 32Ki wait a second
+	if _, err := body.Read(make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := body.Read(make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if clock.slept != time.Second {
+		t.Fatalf("wrong time waited: got %v want %v", clock.slept, time.Second)
+	}
+
+	limiter.updateBucket(createNamespace("tenant", "64Ki"), false)
+	if _, err := body.Read(make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if clock.slept != time.Second+500*time.Millisecond {
+		t.Errorf("the raised limit does not apply to the upload in progress: waited %v", clock.slept)
+	}
+
+	limiter.updateBucket(createNamespace("tenant", ""), false)
+	if _, err := ioutil.ReadAll(body); err != nil {
+		t.Fatal(err)
+	}
+	if clock.slept != time.Second+500*time.Millisecond {
+		t.Errorf("the removed limit still applies to the upload in progress: waited %v", clock.slept)
+	}
+	if _, ok := limiter.buckets["tenant"]; ok {
+		t.Error("the bucket of the namespace without a limit was not removed")
+	}
+}
+
+func TestProxyBandwidthLimit(t *testing.T) {
+	clock, restore := setupFakeClock()
+	defer restore()
+
+	var received int
+	app := setupProxyTests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		received = len(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	app.bandwidthLimiter, _ = createBandwidthLimiter(createNamespace("default", "32Ki"))
+
+	req, err := http.NewRequest("POST", common.UploadPathSync, bytes.NewReader(make([]byte, 64*1024)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer valid")
+	submitRequestAndCheckStatus(t, req, http.StatusOK, app)
+	if received != 64*1024 {
+		t.Errorf("wrong length proxied: got %d want %d", received, 64*1024)
+	}
+	if clock.slept != time.Second {
+		t.Errorf("wrong time waited: got %v want %v", clock.slept, time.Second)
+	}
+}
@@ -0,0 +1,84 @@
+package uploadproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// The codes of the ErrorResponse of a failed upload request
+const (
+	// ErrorCodeMissingToken is the code of a request without an Authorization header
+	ErrorCodeMissingToken = "MissingToken"
+	// ErrorCodeMalformedToken is the code of a request whose Authorization header is not a bearer token
+	ErrorCodeMalformedToken = "MalformedToken"
+	// ErrorCodeInvalidToken is the code of a request whose token could not be verified
+	ErrorCodeInvalidToken = "InvalidToken"
+	// ErrorCodeTokenExpired is the code of a request whose token expired
+	ErrorCodeTokenExpired = "TokenExpired"
+	// ErrorCodeWrongTokenOperation is the code of a request whose token is not for an upload to a PVC
+	ErrorCodeWrongTokenOperation = "WrongTokenOperation"
+	// ErrorCodePVCNotFound is the code of a request for a PVC that does not exist
+	ErrorCodePVCNotFound = "PVCNotFound"
+	// ErrorCodeUploadCompleted is the code of a request for a PVC that was uploaded already
+	ErrorCodeUploadCompleted = "UploadCompleted"
+	// ErrorCodeSessionNotFound is the code of a request for an upload session that was replaced by another session
+	ErrorCodeSessionNotFound = "SessionNotFound"
+	// ErrorCodeSessionExpired is the code of a request for an upload session that expired
+	ErrorCodeSessionExpired = "SessionExpired"
+	// ErrorCodeOperationNotFound is the code of a status request for an async upload the upload server does not know of
+	ErrorCodeOperationNotFound = "OperationNotFound"
+	// ErrorCodePVCNotReady is the code of a request for a PVC whose upload server is not ready yet
+	ErrorCodePVCNotReady = "PVCNotReady"
+	// ErrorCodeInternalError is the code of a request the proxy failed to handle or to pass to the upload server
+	ErrorCodeInternalError = "InternalError"
+)
+
+// ErrorResponse is the JSON body of the response to an upload request that failed, so that client tools can tell
+// the user what went wrong and whether to retry
+type ErrorResponse struct {
+	// Code identifies the failure, like PVCNotReady
+	Code string `json:"code"`
+	// Message describes the failure for the user
+	Message string `json:"message"`
+	// Retryable tells whether sending the same request again may succeed
+	Retryable bool `json:"retryable"`
+}
+
+// uploadError is the failure of an upload request, with the status and the body of its response
+type uploadError struct {
+	status   int
+	response ErrorResponse
+	// retryAfter is how long the client should wait before it retries, zero if it can not tell
+	retryAfter time.Duration
+}
+
+func (e *uploadError) Error() string {
+	return e.response.Message
+}
+
+func newUploadError(status int, code, message string, retryable bool) *uploadError {
+	return &uploadError{
+		status: status,
+		response: ErrorResponse{
+			Code:      code,
+			Message:   message,
+			Retryable: retryable,
+		},
+	}
+}
+
+// writeUploadError responds to a failed upload request with the status and the JSON body of err
+func writeUploadError(w http.ResponseWriter, err *uploadError) {
+	w.Header().Set("Content-Type", "application/json")
+	if err.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(err.retryAfter/time.Second)))
+	}
+	w.WriteHeader(err.status)
+	if err := json.NewEncoder(w).Encode(err.response); err != nil {
+		klog.Warningf("Error writing the error response: %v", err)
+	}
+}
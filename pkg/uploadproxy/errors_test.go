@@ -0,0 +1,102 @@
+package uploadproxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/token"
+)
+
+func submitRequestAndCheckError(t *testing.T, request *http.Request, expectedStatus int, expectedCode string, app *uploadProxyApp) {
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, request)
+
+	if rr.Code != expectedStatus {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, expectedStatus)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("handler returned wrong content type: got %q", contentType)
+	}
+	response := &ErrorResponse{}
+	if err := json.NewDecoder(rr.Body).Decode(response); err != nil {
+		t.Fatalf("handler returned a body that is not an error response: %v", err)
+	}
+	if response.Code != expectedCode {
+		t.Errorf("handler returned wrong error code: got %q want %q", response.Code, expectedCode)
+	}
+	if response.Message == "" {
+		t.Errorf("handler returned no error message")
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := token.NewGenerator("issuer", key, -time.Minute).Generate(&token.Payload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testpvc",
+			Namespace:   "default",
+			Annotations: map[string]string{controller.AnnPodPhase: string(corev1.PodSucceeded)},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		validator  token.Validator
+		client     *k8sfake.Clientset
+		status     int
+		code       string
+	}{
+		{"no token", "", &validateSuccess{}, k8sfake.NewSimpleClientset(), http.StatusBadRequest, ErrorCodeMissingToken},
+		{"malformed token", "Beereer valid", &validateSuccess{}, k8sfake.NewSimpleClientset(), http.StatusBadRequest, ErrorCodeMalformedToken},
+		{"invalid token", "Bearer valid", &validateFailure{}, k8sfake.NewSimpleClientset(), http.StatusUnauthorized, ErrorCodeInvalidToken},
+		{"expired token", "Bearer " + expired, token.NewValidator("issuer", &key.PublicKey, 0), k8sfake.NewSimpleClientset(), http.StatusUnauthorized, ErrorCodeTokenExpired},
+		{"missing pvc", "Bearer valid", &validateSuccess{}, k8sfake.NewSimpleClientset(), http.StatusServiceUnavailable, ErrorCodePVCNotFound},
+		{"uploaded pvc", "Bearer valid", &validateSuccess{}, k8sfake.NewSimpleClientset(uploaded), http.StatusServiceUnavailable, ErrorCodeUploadCompleted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := createApp()
+			app.tokenValidator = test.validator
+			app.client = test.client
+			app.uploadReadiness, _ = createUploadReadiness(test.client)
+			submitRequestAndCheckError(t, newProxyRequest(t, test.authHeader), test.status, test.code, app)
+		})
+	}
+}
+
+func TestRetryableErrorResponse(t *testing.T) {
+	notReady := newUploadError(http.StatusTooEarly, ErrorCodePVCNotReady, "PVC testpvc is not ready for the upload yet, retry in 10s", true)
+	notReady.retryAfter = 10 * time.Second
+	rr := httptest.NewRecorder()
+	writeUploadError(rr, notReady)
+
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "10" {
+		t.Errorf("wrong Retry-After header: got %q want %q", retryAfter, "10")
+	}
+	response := &ErrorResponse{}
+	if err := json.NewDecoder(rr.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.Retryable {
+		t.Errorf("error response not retryable")
+	}
+}
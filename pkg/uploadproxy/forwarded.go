@@ -0,0 +1,105 @@
+package uploadproxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	forwardedForHeader   = "X-Forwarded-For"
+	forwardedProtoHeader = "X-Forwarded-Proto"
+)
+
+// trustedProxies are the networks of the load balancers and proxies in front of the upload proxy, whose PROXY
+// protocol and X-Forwarded-* headers tell the address of the client and the scheme it used
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses CIDRs and single IP addresses
+func parseTrustedProxies(values []string) (trustedProxies, error) {
+	var result trustedProxies
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.Errorf("invalid trusted proxy %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy %q", value)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// contains returns true if ip is the address of a trusted proxy
+func (t trustedProxies) contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAddr returns true if addr is a TCP address of a trusted proxy
+func (t trustedProxies) containsAddr(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && t.contains(tcpAddr.IP)
+}
+
+// client returns the address of the client that sent r, and the scheme, http or https, it used. The X-Forwarded-*
+// headers are only used if the peer that sent r is a trusted proxy, X-Forwarded-For is read from the right, the
+// first address that is not a trusted proxy is the client.
+func (t trustedProxies) client(r *http.Request) (string, string) {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if !t.contains(net.ParseIP(client)) {
+		return client, scheme
+	}
+
+	var forwarded []string
+	for _, value := range r.Header[forwardedForHeader] {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			// anything further left was not added by a proxy we can tell apart from the client
+			break
+		}
+		client = ip.String()
+		if !t.contains(ip) {
+			break
+		}
+	}
+
+	// the first proxy, the one the client connected to, is leftmost
+	if proto := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get(forwardedProtoHeader), ",")[0])); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return client, scheme
+}
@@ -0,0 +1,88 @@
+package uploadproxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.1", "fd00::/8", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trusted) != 3 {
+		t.Fatalf("got %d networks want 3", len(trusted))
+	}
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("invalid CIDR accepted")
+	}
+	if _, err := parseTrustedProxies([]string{"proxy.example.com"}); err == nil {
+		t.Error("hostname accepted")
+	}
+}
+
+func TestForwardedClient(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		tls            bool
+		forwardedFor   []string
+		forwardedProto string
+		expectedClient string
+		expectedScheme string
+	}{
+		{"direct client", "203.0.113.5:40000", true, nil, "", "203.0.113.5", "https"},
+		{"headers of an untrusted peer", "203.0.113.5:40000", true, []string{"198.51.100.7"}, "http", "203.0.113.5", "https"},
+		{"trusted load balancer", "10.1.2.3:40000", false, []string{"198.51.100.7"}, "https", "198.51.100.7", "https"},
+		{"chain of trusted proxies", "10.1.2.3:40000", false, []string{"198.51.100.7, 10.4.5.6", "10.7.8.9"}, "https, http", "198.51.100.7", "https"},
+		{"spoofed entry left of the client", "10.1.2.3:40000", false, []string{"192.0.2.1, 198.51.100.7"}, "", "198.51.100.7", "http"},
+		{"IPv6 load balancer", "[fd00::1]:40000", false, []string{"2001:db8::7"}, "https", "2001:db8::7", "https"},
+		{"unknown proto", "10.1.2.3:40000", true, nil, "gopher", "10.1.2.3", "https"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := http.NewRequest("POST", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = test.remoteAddr
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for _, value := range test.forwardedFor {
+				r.Header.Add(forwardedForHeader, value)
+			}
+			if test.forwardedProto != "" {
+				r.Header.Set(forwardedProtoHeader, test.forwardedProto)
+			}
+			client, scheme := trusted.client(r)
+			if client != test.expectedClient || scheme != test.expectedScheme {
+				t.Errorf("got client %s scheme %s want %s %s", client, scheme, test.expectedClient, test.expectedScheme)
+			}
+		})
+	}
+}
+
+func TestProxyForwardsClient(t *testing.T) {
+	var forwardedFor, forwardedProto string
+	app := setupProxyTests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedFor, forwardedProto = r.Header.Get(forwardedForHeader), r.Header.Get(forwardedProtoHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	app.trustedProxies, _ = parseTrustedProxies([]string{"10.0.0.0/8"})
+
+	req := newProxyRequest(t, "Bearer valid")
+	req.RemoteAddr = "10.1.2.3:40000"
+	req.Header.Set(forwardedForHeader, "198.51.100.7")
+	req.Header.Set(forwardedProtoHeader, "https")
+	submitRequestAndCheckStatus(t, req, http.StatusOK, app)
+
+	if forwardedFor != "198.51.100.7" || forwardedProto != "https" {
+		t.Errorf("upload server got X-Forwarded-For %q X-Forwarded-Proto %q", forwardedFor, forwardedProto)
+	}
+}
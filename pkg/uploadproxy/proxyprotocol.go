@@ -0,0 +1,167 @@
+package uploadproxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// proxyProtocolHeaderTimeout is how long a trusted proxy has to send the PROXY protocol header
+	proxyProtocolHeaderTimeout = 10 * time.Second
+
+	// proxyProtocolV1MaxLength is the longest header of version 1, with the CRLF
+	proxyProtocolV1MaxLength = 107
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtocolListener accepts connections that may start with a PROXY protocol header of version 1 or 2. The
+// header of a connection from a trusted proxy sets the remote address of the connection to the one of the client,
+// connections from other peers are taken as they are, their headers are not read.
+type proxyProtocolListener struct {
+	net.Listener
+	trusted trustedProxies
+}
+
+func newProxyProtocolListener(listener net.Listener, trusted trustedProxies) net.Listener {
+	return &proxyProtocolListener{Listener: listener, trusted: trusted}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil || !l.trusted.containsAddr(conn.RemoteAddr()) {
+		return conn, err
+	}
+	return &proxyProtocolConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtocolConn reads the PROXY protocol header when it is first read, or asked for its remote address. The
+// header is read in the goroutine serving the connection, a slow proxy does not hold up accepting others.
+type proxyProtocolConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		c.remoteAddr, c.err = readProxyProtocolHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyProtocolHeader reads the PROXY protocol header at the start of r, if there is one, and returns the
+// address of the client it names. The address is nil without a header, and for headers that name no client, such as
+// the health checks of a load balancer.
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch first[0] {
+	case 'P':
+		return readProxyProtocolV1(r)
+	case proxyProtocolV2Signature[0]:
+		return readProxyProtocolV2(r)
+	}
+	return nil, nil
+}
+
+func readProxyProtocolV1(r *bufio.Reader) (net.Addr, error) {
+	if prefix, err := r.Peek(6); err != nil || string(prefix) != "PROXY " {
+		return nil, nil
+	}
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, errors.New("PROXY protocol header too long")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read PROXY protocol header")
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.Errorf("invalid PROXY protocol header %q", strings.TrimSpace(string(line)))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, errors.Errorf("invalid PROXY protocol header %q", strings.TrimSpace(string(line)))
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyProtocolV2(r *bufio.Reader) (net.Addr, error) {
+	if signature, err := r.Peek(len(proxyProtocolV2Signature)); err != nil || !bytes.Equal(signature, proxyProtocolV2Signature) {
+		return nil, nil
+	}
+	header := make([]byte, len(proxyProtocolV2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Wrap(err, "could not read PROXY protocol header")
+	}
+	versionCommand, family := header[12], header[13]
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, errors.Wrap(err, "could not read PROXY protocol header")
+	}
+	if versionCommand>>4 != 2 {
+		return nil, errors.Errorf("unsupported PROXY protocol version %d", versionCommand>>4)
+	}
+	if versionCommand&0xf == 0 {
+		// LOCAL, the proxy connected on its own behalf
+		return nil, nil
+	}
+
+	var ipLength int
+	switch family {
+	case 0x11:
+		ipLength = net.IPv4len
+	case 0x21:
+		ipLength = net.IPv6len
+	default:
+		// not TCP over IPv4 or IPv6, there is no client address to use
+		return nil, nil
+	}
+	if len(body) < 2*ipLength+4 {
+		return nil, errors.New("PROXY protocol header too short")
+	}
+	ip := net.IP(body[:ipLength])
+	port := binary.BigEndian.Uint16(body[2*ipLength:])
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
@@ -0,0 +1,106 @@
+package uploadproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func proxyProtocolV2Header(command, family byte, addresses []byte) string {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addresses)))
+	return string(append(header, addresses...))
+}
+
+func TestReadProxyProtocolHeader(t *testing.T) {
+	v4 := []byte{198, 51, 100, 7, 10, 0, 0, 1, 0x9c, 0x40, 0x01, 0xbb}
+	v6 := append(append(net.ParseIP("2001:db8::7").To16(), net.ParseIP("fd00::1").To16()...), 0x9c, 0x40, 0x01, 0xbb)
+	tests := []struct {
+		name         string
+		data         string
+		expectedAddr string
+		expectedErr  bool
+	}{
+		{"no header", "\x16\x03\x01rest", "", false},
+		{"plain http", "POST / HTTP/1.1\r\n", "", false},
+		{"version 1 TCP4", "PROXY TCP4 198.51.100.7 10.0.0.1 40000 443\r\nrest", "198.51.100.7:40000", false},
+		{"version 1 TCP6", "PROXY TCP6 2001:db8::7 fd00::1 40000 443\r\nrest", "[2001:db8::7]:40000", false},
+		{"version 1 UNKNOWN", "PROXY UNKNOWN\r\nrest", "", false},
+		{"version 1 mismatched family", "PROXY TCP4 2001:db8::7 fd00::1 40000 443\r\nrest", "", true},
+		{"version 1 too long", "PROXY " + strings.Repeat("x", 200) + "\r\n", "", true},
+		{"version 2 TCP4", proxyProtocolV2Header(1, 0x11, v4) + "rest", "198.51.100.7:40000", false},
+		{"version 2 TCP6", proxyProtocolV2Header(1, 0x21, v6) + "rest", "[2001:db8::7]:40000", false},
+		{"version 2 LOCAL", proxyProtocolV2Header(0, 0x00, nil) + "rest", "", false},
+		{"version 2 with TLVs", proxyProtocolV2Header(1, 0x11, append(v4, 0x04, 0x00, 0x01, 0x00)) + "rest", "198.51.100.7:40000", false},
+		{"version 2 truncated", proxyProtocolV2Header(1, 0x11, v4[:6]) + "rest", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(test.data))
+			addr, err := readProxyProtocolHeader(r)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("got error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if (addr == nil && test.expectedAddr != "") || (addr != nil && addr.String() != test.expectedAddr) {
+				t.Errorf("got address %v want %q", addr, test.expectedAddr)
+			}
+			rest, _ := ioutil.ReadAll(r)
+			if !strings.HasSuffix(test.data, string(rest)) || (test.expectedAddr != "" && string(rest) != "rest") {
+				t.Errorf("header not consumed, rest %q", rest)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	for _, test := range []struct {
+		name         string
+		trusted      string
+		expectedAddr string
+	}{
+		{"trusted proxy", "127.0.0.0/8", "198.51.100.7:40000"},
+		{"untrusted peer", "10.0.0.0/8", "127.0.0.1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			trusted, _ := parseTrustedProxies([]string{test.trusted})
+			l := newProxyProtocolListener(listener, trusted)
+			client, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			if _, err = client.Write([]byte("PROXY TCP4 198.51.100.7 10.0.0.1 40000 443\r\ndata")); err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := l.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if addr := conn.RemoteAddr().String(); !strings.HasPrefix(addr, test.expectedAddr) {
+				t.Errorf("got remote address %s want %s", addr, test.expectedAddr)
+			}
+			if test.expectedAddr == "127.0.0.1" {
+				return
+			}
+			data := make([]byte, 4)
+			if _, err := conn.Read(data); err != nil || string(data) != "data" {
+				t.Errorf("got %q %v want data", data, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package uploadproxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+)
+
+// readinessRetryAfter is when a client should retry an upload whose upload server runs but is not ready yet, the
+// period of its readiness probe
+const readinessRetryAfter = 5 * time.Second
+
+// pvcReadiness is what the proxy knows of the upload server of a PVC
+type pvcReadiness struct {
+	found     bool
+	ready     bool
+	completed bool
+	// running is true if the upload server runs, it becomes ready within a readiness probe period
+	running bool
+}
+
+func newPVCReadiness(pvc *v1.PersistentVolumeClaim) pvcReadiness {
+	phase := v1.PodPhase(pvc.Annotations[controller.AnnPodPhase])
+	ready, _ := strconv.ParseBool(pvc.Annotations[controller.AnnPodReady])
+	return pvcReadiness{
+		found:     true,
+		ready:     ready,
+		completed: phase == v1.PodSucceeded,
+		running:   phase == v1.PodRunning,
+	}
+}
+
+// uploadReadiness tells if the upload servers of PVCs are ready from a PVC informer, so requests waiting for an upload
+// server do not poll the apiserver, and wakes them up as soon as their PVC changes. It caches the readiness of the
+// PVCs uploads were sent to until their PVC changes, including the PVCs that do not exist, so repeated uploads to the
+// same PVC do not query the apiserver at all.
+type uploadReadiness struct {
+	client kubernetes.Interface
+	pvcs   corelisters.PersistentVolumeClaimLister
+
+	mu sync.Mutex
+	// states is the readiness of the PVCs uploads were sent to, by namespace/name
+	states map[string]pvcReadiness
+	// waiters are the channels of the requests waiting for a PVC, by namespace/name
+	waiters map[string][]chan struct{}
+}
+
+// newUploadReadiness returns an uploadReadiness reading the PVCs from an informer
+func newUploadReadiness(client kubernetes.Interface, stopCh <-chan struct{}) *uploadReadiness {
+	informerFactory := informers.NewSharedInformerFactory(client, common.DefaultResyncPeriod)
+	pvcInformer := informerFactory.Core().V1().PersistentVolumeClaims()
+	r := &uploadReadiness{client: client, pvcs: pvcInformer.Lister()}
+	pvcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: r.update,
+		UpdateFunc: func(_, obj interface{}) {
+			r.update(obj)
+		},
+		DeleteFunc: r.update,
+	})
+	go informerFactory.Start(stopCh)
+	klog.V(3).Infoln("Waiting for PVC cache sync")
+	cache.WaitForCacheSync(stopCh, pvcInformer.Informer().HasSynced)
+	return r
+}
+
+// update replaces the cached readiness of the PVC obj, if uploads were sent to it, and wakes up the requests waiting
+// for it
+func (r *uploadReadiness) update(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.states[key]; ok {
+		if pvc, ok := obj.(*v1.PersistentVolumeClaim); ok {
+			r.states[key] = newPVCReadiness(pvc)
+		} else {
+			// A tombstone of a deleted PVC
+			r.states[key] = pvcReadiness{}
+		}
+	}
+	for _, ch := range r.waiters[key] {
+		close(ch)
+	}
+	delete(r.waiters, key)
+}
+
+// changed returns a channel that is closed once the PVC with key changes
+func (r *uploadReadiness) changed(key string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.waiters == nil {
+		r.waiters = make(map[string][]chan struct{})
+	}
+	ch := make(chan struct{})
+	r.waiters[key] = append(r.waiters[key], ch)
+	return ch
+}
+
+// forget removes ch from the waiters of the PVC with key, once the request stopped waiting
+func (r *uploadReadiness) forget(key string, ch <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	waiters := r.waiters[key]
+	for i := range waiters {
+		if waiters[i] == ch {
+			r.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(r.waiters[key]) == 0 {
+		delete(r.waiters, key)
+	}
+}
+
+// readiness returns the cached readiness of the PVC, or reads it from the informer. A PVC the informer has not seen
+// yet, such as one created a moment ago, is read from the apiserver.
+func (r *uploadReadiness) readiness(namespace, name string) (pvcReadiness, error) {
+	key := namespace + "/" + name
+	r.mu.Lock()
+	state, ok := r.states[key]
+	r.mu.Unlock()
+	if ok {
+		return state, nil
+	}
+
+	pvc, err := r.pvcs.PersistentVolumeClaims(namespace).Get(name)
+	if k8serrors.IsNotFound(err) {
+		pvc, err = r.client.CoreV1().PersistentVolumeClaims(namespace).Get(name, metav1.GetOptions{})
+	}
+	switch {
+	case err == nil:
+		state = newPVCReadiness(pvc)
+	case k8serrors.IsNotFound(err):
+		state = pvcReadiness{}
+	default:
+		return state, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.states == nil {
+		r.states = make(map[string]pvcReadiness)
+	}
+	r.states[key] = state
+	return state, nil
+}
+
+// wait waits up to timeout for the upload server of the PVC to be ready, and tells why it is not
+func (r *uploadReadiness) wait(namespace, name string, timeout time.Duration) *uploadError {
+	key := namespace + "/" + name
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		// Waiting for a change before reading the PVC does not miss a change in between
+		changed := r.changed(key)
+		state, uploadErr := r.ready(namespace, name)
+		if state.ready || uploadErr != nil {
+			r.forget(key, changed)
+			return uploadErr
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			r.forget(key, changed)
+			retryAfter := timeout
+			if state.running {
+				retryAfter = readinessRetryAfter
+			}
+			notReady := newUploadError(http.StatusTooEarly, ErrorCodePVCNotReady,
+				fmt.Sprintf("PVC %s is not ready for the upload yet, retry in %s", name, retryAfter), true)
+			notReady.retryAfter = retryAfter
+			return notReady
+		}
+	}
+}
+
+// ready returns the readiness of the upload server of the PVC, and tells why the PVC can not be uploaded to
+func (r *uploadReadiness) ready(namespace, name string) (pvcReadiness, *uploadError) {
+	state, err := r.readiness(namespace, name)
+	if err != nil {
+		return state, newUploadError(http.StatusServiceUnavailable, ErrorCodeInternalError,
+			fmt.Sprintf("Unable to get PVC %s: %v", name, err), true)
+	}
+	if !state.found {
+		return state, newUploadError(http.StatusServiceUnavailable, ErrorCodePVCNotFound,
+			fmt.Sprintf("Rejecting Upload Request for PVC %s that doesn't exist", name), false)
+	}
+	if state.completed {
+		return state, newUploadError(http.StatusServiceUnavailable, ErrorCodeUploadCompleted,
+			fmt.Sprintf("Rejecting Upload Request for PVC %s that already finished uploading", name), false)
+	}
+	return state, nil
+}
@@ -0,0 +1,163 @@
+package uploadproxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+)
+
+// createUploadReadiness returns an uploadReadiness reading PVCs from an indexer instead of an informer, and from
+// client if the indexer does not have them
+func createUploadReadiness(client kubernetes.Interface, pvcs ...*corev1.PersistentVolumeClaim) (*uploadReadiness, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pvc := range pvcs {
+		indexer.Add(pvc)
+	}
+	return &uploadReadiness{client: client, pvcs: corelisters.NewPersistentVolumeClaimLister(indexer)}, indexer
+}
+
+func createUploadPVC(ready bool, phase corev1.PodPhase) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testpvc",
+			Namespace:   "default",
+			Annotations: map[string]string{controller.AnnPodPhase: string(phase)},
+		},
+	}
+	if ready {
+		pvc.Annotations[controller.AnnPodReady] = "true"
+	}
+	return pvc
+}
+
+func TestUploadReadiness(t *testing.T) {
+	tests := []struct {
+		name   string
+		pvc    *corev1.PersistentVolumeClaim
+		status int
+		code   string
+	}{
+		{"ready", createUploadPVC(true, corev1.PodRunning), http.StatusOK, ""},
+		{"completed", createUploadPVC(false, corev1.PodSucceeded), http.StatusServiceUnavailable, ErrorCodeUploadCompleted},
+		{"not ready", createUploadPVC(false, corev1.PodPending), http.StatusTooEarly, ErrorCodePVCNotReady},
+		{"missing", nil, http.StatusServiceUnavailable, ErrorCodePVCNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pvcs []*corev1.PersistentVolumeClaim
+			if test.pvc != nil {
+				pvcs = append(pvcs, test.pvc)
+			}
+			readiness, _ := createUploadReadiness(k8sfake.NewSimpleClientset(), pvcs...)
+			err := readiness.wait("default", "testpvc", 50*time.Millisecond)
+			if test.code == "" {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || err.status != test.status || err.response.Code != test.code {
+				t.Errorf("wrong error: got %+v want %d %s", err, test.status, test.code)
+			}
+		})
+	}
+}
+
+func TestUploadReadinessFromAPIServer(t *testing.T) {
+	readiness, _ := createUploadReadiness(k8sfake.NewSimpleClientset(createUploadPVC(true, corev1.PodRunning)))
+	if err := readiness.wait("default", "testpvc", 50*time.Millisecond); err != nil {
+		t.Errorf("PVC the informer has not seen yet not read from the apiserver: %v", err)
+	}
+}
+
+func TestUploadReadinessWakesUp(t *testing.T) {
+	pvc := createUploadPVC(false, corev1.PodPending)
+	readiness, indexer := createUploadReadiness(k8sfake.NewSimpleClientset(), pvc)
+	done := make(chan *uploadError)
+	start := time.Now()
+	go func() {
+		done <- readiness.wait("default", "testpvc", time.Minute)
+	}()
+
+	// Wait for the request to wait for a change
+	for {
+		readiness.mu.Lock()
+		waiting := len(readiness.waiters["default/testpvc"]) > 0
+		readiness.mu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ready := createUploadPVC(true, corev1.PodRunning)
+	indexer.Update(ready)
+	readiness.update(ready)
+
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("woke up after %v", elapsed)
+	}
+	if len(readiness.waiters) != 0 {
+		t.Errorf("waiters left behind: %v", readiness.waiters)
+	}
+}
+
+func TestUploadReadinessRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		phase      corev1.PodPhase
+		retryAfter time.Duration
+	}{
+		{"pending", corev1.PodPending, 50 * time.Millisecond},
+		{"running", corev1.PodRunning, readinessRetryAfter},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			readiness, _ := createUploadReadiness(k8sfake.NewSimpleClientset(), createUploadPVC(false, test.phase))
+			err := readiness.wait("default", "testpvc", 50*time.Millisecond)
+			if err == nil || err.retryAfter != test.retryAfter {
+				t.Errorf("wrong Retry-After: got %+v want %v", err, test.retryAfter)
+			}
+		})
+	}
+}
+
+func TestUploadReadinessCached(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	readiness, indexer := createUploadReadiness(client)
+	for i := 0; i < 3; i++ {
+		if err := readiness.wait("default", "testpvc", 50*time.Millisecond); err == nil || err.response.Code != ErrorCodePVCNotFound {
+			t.Errorf("wrong error: got %+v want %s", err, ErrorCodePVCNotFound)
+		}
+	}
+	if actions := len(client.Actions()); actions != 1 {
+		t.Errorf("missing PVC read from the apiserver %d times, want once", actions)
+	}
+
+	pvc := createUploadPVC(true, corev1.PodRunning)
+	indexer.Add(pvc)
+	readiness.update(pvc)
+	if err := readiness.wait("default", "testpvc", 50*time.Millisecond); err != nil {
+		t.Errorf("readiness not updated by the PVC event: %v", err)
+	}
+
+	indexer.Delete(pvc)
+	readiness.update(cache.DeletedFinalStateUnknown{Key: "default/testpvc", Obj: pvc})
+	if err := readiness.wait("default", "testpvc", 50*time.Millisecond); err == nil || err.response.Code != ErrorCodePVCNotFound {
+		t.Errorf("wrong error after the PVC was deleted: got %+v want %s", err, ErrorCodePVCNotFound)
+	}
+	if actions := len(client.Actions()); actions != 1 {
+		t.Errorf("PVCs read from the apiserver %d times, want once", actions)
+	}
+}
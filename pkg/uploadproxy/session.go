@@ -0,0 +1,125 @@
+package uploadproxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+// sessionRequestHeaders are the headers of a chunk of an upload session the proxy passes to the upload server
+var sessionRequestHeaders = []string{
+	common.UploadOffsetHeader,
+	common.UploadCompleteHeader,
+	checksum.ChunkHeader,
+}
+
+// handleSessionRequest passes a chunk of an upload session, or a HEAD request for its progress, to the upload server
+// of the PVC of the session, and records the progress the server reports on the PVC. Any proxy can take the chunks
+// of a session.
+func (app *uploadProxyApp) handleSessionRequest(w http.ResponseWriter, r *http.Request) {
+	clientAddress, scheme := app.trustedProxies.client(r)
+	tokenData, uploadErr := app.validateUploadToken(r, clientAddress)
+	if uploadErr != nil {
+		writeUploadError(w, uploadErr)
+		return
+	}
+	sessionID := tokenData.Params[controller.UploadSessionParam]
+	if sessionID == "" {
+		writeUploadError(w, newUploadError(http.StatusBadRequest, ErrorCodeWrongTokenOperation,
+			"The token is not for an upload session", false))
+		return
+	}
+
+	if err := app.uploadReady(tokenData.Name, tokenData.Namespace); err != nil {
+		klog.Error(err)
+		writeUploadError(w, err)
+		return
+	}
+	if err := app.sessionValid(tokenData.Namespace, tokenData.Name, sessionID); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	header := http.Header{}
+	for _, name := range sessionRequestHeaders {
+		if value := r.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	header.Set(common.UploadSessionHeader, sessionID)
+	response := app.proxyUploadRequest(tokenData.Namespace, tokenData.Name, clientAddress, scheme, header, w, r)
+	if response == nil || response.Header.Get(common.UploadOffsetHeader) == "" {
+		return
+	}
+
+	offset, err := strconv.ParseInt(response.Header.Get(common.UploadOffsetHeader), 10, 64)
+	if err != nil {
+		klog.Warningf("Invalid offset of upload session %s: %v", sessionID, err)
+		return
+	}
+	complete, _ := strconv.ParseBool(response.Header.Get(common.UploadCompleteHeader))
+	// The chunks of the session are taken concurrently, the response to a HEAD request tells where the server is
+	// after it restarted though
+	err = app.recordSessionProgress(tokenData.Namespace, tokenData.Name, sessionID, offset,
+		response.Header.Get(common.UploadDigestHeader), complete, r.Method == http.MethodHead)
+	if err != nil {
+		klog.Warningf("Error recording the progress of upload session %s: %v", sessionID, err)
+	}
+}
+
+// sessionValid tells why the session with id is not the upload session of pvc, or can no longer be used
+func (app *uploadProxyApp) sessionValid(namespace, pvcName, id string) *uploadError {
+	pvc, err := app.client.CoreV1().PersistentVolumeClaims(namespace).Get(pvcName, metav1.GetOptions{})
+	if err != nil {
+		return newUploadError(http.StatusServiceUnavailable, ErrorCodeInternalError,
+			fmt.Sprintf("Unable to get PVC %s: %v", pvcName, err), true)
+	}
+	session, err := controller.GetUploadSession(pvc)
+	if err != nil {
+		return newUploadError(http.StatusInternalServerError, ErrorCodeInternalError, err.Error(), false)
+	}
+	if session == nil || session.ID != id {
+		return newUploadError(http.StatusNotFound, ErrorCodeSessionNotFound,
+			fmt.Sprintf("The upload session to PVC %s was replaced, request a new one", pvcName), false)
+	}
+	if session.Expired() {
+		return newUploadError(http.StatusGone, ErrorCodeSessionExpired,
+			fmt.Sprintf("The upload session to PVC %s expired, request a new one", pvcName), false)
+	}
+	return nil
+}
+
+// recordSessionProgress records the offset and the digest of the session with id on pvc. The offset only moves ahead,
+// unless reset.
+func (app *uploadProxyApp) recordSessionProgress(namespace, pvcName, id string, offset int64, digest string, complete, reset bool) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		pvc, err := app.client.CoreV1().PersistentVolumeClaims(namespace).Get(pvcName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		session, err := controller.GetUploadSession(pvc)
+		if err != nil || session == nil || session.ID != id {
+			return err
+		}
+		if session.Offset == offset && session.Digest == digest && session.Complete == complete ||
+			session.Offset > offset && !reset {
+			return nil
+		}
+		session.Offset = offset
+		session.Digest = digest
+		session.Complete = complete
+		if err := controller.SetUploadSession(pvc, session); err != nil {
+			return err
+		}
+		_, err = app.client.CoreV1().PersistentVolumeClaims(namespace).Update(pvc)
+		return err
+	})
+}
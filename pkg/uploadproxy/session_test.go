@@ -0,0 +1,127 @@
+package uploadproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/token"
+)
+
+type validateSession struct {
+	id string
+}
+
+func (v *validateSession) Validate(string) (*token.Payload, error) {
+	payload, _ := (&validateSuccess{}).Validate("")
+	payload.Params = map[string]string{controller.UploadSessionParam: v.id}
+	return payload, nil
+}
+
+// setupSessionTests returns a proxy to handler for the upload session with id, which is the session of the PVC if
+// session is not nil
+func setupSessionTests(t *testing.T, handler http.HandlerFunc, id string, session *controller.UploadSession) *uploadProxyApp {
+	app := setupProxyTests(handler)
+	app.tokenValidator = &validateSession{id: id}
+	if session != nil {
+		pvc, err := app.client.CoreV1().PersistentVolumeClaims("default").Get("testpvc", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := controller.SetUploadSession(pvc, session); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.client.CoreV1().PersistentVolumeClaims("default").Update(pvc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return app
+}
+
+func newSessionRequest(t *testing.T, method string, offset string) *http.Request {
+	req, err := http.NewRequest(method, common.UploadPathSession, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer valid")
+	req.Header.Set(common.UploadOffsetHeader, offset)
+	return req
+}
+
+func getSession(t *testing.T, app *uploadProxyApp) *controller.UploadSession {
+	pvc, err := app.client.CoreV1().PersistentVolumeClaims("default").Get("testpvc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := controller.GetUploadSession(pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestSessionProgress(t *testing.T) {
+	var serverOffset string
+	app := setupSessionTests(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(common.UploadSessionHeader) != "s1" {
+			t.Errorf("unexpected upload session %q", r.Header.Get(common.UploadSessionHeader))
+		}
+		w.Header().Set(common.UploadOffsetHeader, serverOffset)
+		w.Header().Set(common.UploadDigestHeader, "sha256:"+serverOffset)
+	}, "s1", &controller.UploadSession{ID: "s1", Expires: metav1.NewTime(time.Now().Add(time.Hour))})
+
+	serverOffset = "4"
+	submitRequestAndCheckStatus(t, newSessionRequest(t, "POST", "0"), http.StatusOK, app)
+	if session := getSession(t, app); session.Offset != 4 || session.Digest != "sha256:4" {
+		t.Errorf("unexpected session progress %+v", session)
+	}
+
+	// A chunk answered before the one that moved the session ahead does not move it back
+	serverOffset = "2"
+	submitRequestAndCheckStatus(t, newSessionRequest(t, "POST", "0"), http.StatusOK, app)
+	if session := getSession(t, app); session.Offset != 4 {
+		t.Errorf("unexpected session offset %d", session.Offset)
+	}
+
+	// A restarted upload server starts the session over
+	serverOffset = "0"
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, newSessionRequest(t, "HEAD", ""))
+	if offset := rr.Header().Get(common.UploadOffsetHeader); offset != "0" {
+		t.Errorf("unexpected offset %q in the response", offset)
+	}
+	if session := getSession(t, app); session.Offset != 0 {
+		t.Errorf("unexpected session offset %d", session.Offset)
+	}
+}
+
+func TestSessionErrors(t *testing.T) {
+	valid := &controller.UploadSession{ID: "s1", Expires: metav1.NewTime(time.Now().Add(time.Hour))}
+	expired := &controller.UploadSession{ID: "s1", Expires: metav1.NewTime(time.Now().Add(-time.Minute))}
+	tests := []struct {
+		name           string
+		id             string
+		session        *controller.UploadSession
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"token without session", "", valid, http.StatusBadRequest, ErrorCodeWrongTokenOperation},
+		{"PVC without session", "s1", nil, http.StatusNotFound, ErrorCodeSessionNotFound},
+		{"replaced session", "s0", valid, http.StatusNotFound, ErrorCodeSessionNotFound},
+		{"expired session", "s1", expired, http.StatusGone, ErrorCodeSessionExpired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := setupSessionTests(t, func(w http.ResponseWriter, r *http.Request) {
+				t.Error("request passed to the upload server")
+			}, test.id, test.session)
+			submitRequestAndCheckError(t, newSessionRequest(t, "POST", "0"), test.expectedStatus, test.expectedCode, app)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package uploadproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// handleStatusRequest answers with the status of the async upload in the operation parameter. The upload server
+// exits once the upload is done, or failed, so the status of an upload it no longer knows of is told by the PVC.
+func (app *uploadProxyApp) handleStatusRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	clientAddress, _ := app.trustedProxies.client(r)
+	tokenData, uploadErr := app.validateUploadToken(r, clientAddress)
+	if uploadErr != nil {
+		writeUploadError(w, uploadErr)
+		return
+	}
+	operation := r.URL.Query().Get(common.UploadOperationParam)
+	if operation == "" {
+		writeUploadError(w, newUploadError(http.StatusBadRequest, ErrorCodeOperationNotFound,
+			"The request has no operation parameter with the ID of an async upload", false))
+		return
+	}
+
+	pvc, err := app.client.CoreV1().PersistentVolumeClaims(tokenData.Namespace).Get(tokenData.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			writeUploadError(w, newUploadError(http.StatusServiceUnavailable, ErrorCodePVCNotFound,
+				fmt.Sprintf("PVC %s doesn't exist", tokenData.Name), false))
+			return
+		}
+		writeUploadError(w, newUploadError(http.StatusServiceUnavailable, ErrorCodeInternalError,
+			fmt.Sprintf("Unable to get PVC %s: %v", tokenData.Name, err), true))
+		return
+	}
+	if v1.PodPhase(pvc.Annotations[controller.AnnPodPhase]) == v1.PodSucceeded {
+		writeUploadStatus(w, &util.UploadStatus{Operation: operation, Phase: util.UploadPhaseDone})
+		return
+	}
+
+	status, err := app.getUploadStatus(tokenData.Namespace, tokenData.Name, operation)
+	if err != nil {
+		klog.V(1).Infof("Unable to get the status of upload %s: %v", operation, err)
+	}
+	if status != nil {
+		writeUploadStatus(w, status)
+		return
+	}
+	if message, ok := pvc.Annotations[controller.AnnTransferFailure]; ok {
+		writeUploadStatus(w, &util.UploadStatus{Operation: operation, Phase: util.UploadPhaseError, Message: message})
+		return
+	}
+	if err != nil {
+		writeUploadError(w, newUploadError(http.StatusServiceUnavailable, ErrorCodeInternalError,
+			"Unable to get the status from the upload server", true))
+		return
+	}
+	writeUploadError(w, newUploadError(http.StatusNotFound, ErrorCodeOperationNotFound,
+		fmt.Sprintf("The upload server of PVC %s does not know of upload %s", tokenData.Name, operation), false))
+}
+
+// getUploadStatus returns the status of the async upload with operation from the upload server of pvc, nil if the
+// server does not know of it
+func (app *uploadProxyApp) getUploadStatus(namespace, pvc, operation string) (*util.UploadStatus, error) {
+	client, err := app.clientCreator.CreateClient(namespace, pvc)
+	if err != nil {
+		return nil, err
+	}
+	statusURL := app.urlResolver(namespace, pvc, common.UploadPathStatus) + "?" +
+		url.Values{common.UploadOperationParam: []string{operation}}.Encode()
+	response, err := client.Get(statusURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("upload server answered with %d", response.StatusCode)
+	}
+	status := &util.UploadStatus{}
+	if err := json.NewDecoder(response.Body).Decode(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func writeUploadStatus(w http.ResponseWriter, status *util.UploadStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		klog.Warningf("Error writing the upload status: %v", err)
+	}
+}
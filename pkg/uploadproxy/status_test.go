@@ -0,0 +1,96 @@
+package uploadproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// getStatus sends a status request for operation to app, and returns the status it answers with
+func getStatus(t *testing.T, app *uploadProxyApp, operation string, expectedCode int) *util.UploadStatus {
+	req, err := http.NewRequest("GET", common.UploadPathStatus+"?operation="+operation, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer valid")
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+	if rr.Code != expectedCode {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, expectedCode)
+	}
+	if rr.Code != http.StatusOK {
+		return nil
+	}
+	status := &util.UploadStatus{}
+	if err := json.NewDecoder(rr.Body).Decode(status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+// setPVCAnnotation sets the annotation key of the PVC of the proxy tests to value
+func setPVCAnnotation(t *testing.T, app *uploadProxyApp, key, value string) {
+	pvc, err := app.client.CoreV1().PersistentVolumeClaims("default").Get("testpvc", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvc.Annotations[key] = value
+	if _, err := app.client.CoreV1().PersistentVolumeClaims("default").Update(pvc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func uploadServerStatus(operation string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(common.UploadOperationParam) != operation {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&util.UploadStatus{Operation: operation, Phase: util.UploadPhaseConverting})
+	}
+}
+
+func TestStatusFromUploadServer(t *testing.T) {
+	app := setupProxyTests(uploadServerStatus("op1"))
+
+	status := getStatus(t, app, "op1", http.StatusOK)
+	if status.Operation != "op1" || status.Phase != util.UploadPhaseConverting {
+		t.Errorf("unexpected status %+v", status)
+	}
+	getStatus(t, app, "op2", http.StatusNotFound)
+}
+
+func TestStatusOfSucceededUpload(t *testing.T) {
+	app := setupProxyTests(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("upload server asked for the status of a succeeded upload")
+	})
+	setPVCAnnotation(t, app, controller.AnnPodPhase, "Succeeded")
+
+	status := getStatus(t, app, "op1", http.StatusOK)
+	if status.Operation != "op1" || status.Phase != util.UploadPhaseDone {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestStatusOfFailedUpload(t *testing.T) {
+	// The upload server restarted after the upload failed
+	app := setupProxyTests(uploadServerStatus("op2"))
+	setPVCAnnotation(t, app, controller.AnnTransferFailure, "Unable to convert source data to target format")
+
+	status := getStatus(t, app, "op1", http.StatusOK)
+	if status.Phase != util.UploadPhaseError || status.Message != "Unable to convert source data to target format" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestStatusWithoutOperation(t *testing.T) {
+	app := setupProxyTests(uploadServerStatus("op1"))
+	getStatus(t, app, "", http.StatusBadRequest)
+}
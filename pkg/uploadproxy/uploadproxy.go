@@ -3,37 +3,36 @@ package uploadproxy
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"time"
 
-	"k8s.io/apimachinery/pkg/util/wait"
-
 	"github.com/pkg/errors"
-	v1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/controller"
 	"kubevirt.io/containerized-data-importer/pkg/token"
+	"kubevirt.io/containerized-data-importer/pkg/util"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
 )
 
 const (
 	healthzPath = "/healthz"
 
-	waitReadyTime     = 10 * time.Second
-	waitReadyImterval = time.Second
-
-	proxyRequestTimeout = 24 * time.Hour
+	waitReadyTime = 10 * time.Second
 
 	uploadTokenLeeway = 10 * time.Second
+
+	// uploadTokenParam is the query parameter of a form upload with the upload token, browsers cannot set the
+	// Authorization header of a form
+	uploadTokenParam = "token"
 )
 
 // Server is the public interface to the upload proxy
@@ -48,7 +47,8 @@ type CertWatcher interface {
 
 // ClientCreator crates *http.Clients
 type ClientCreator interface {
-	CreateClient() (*http.Client, error)
+	// CreateClient returns a client for the upload server of pvc in namespace, which only accepts that server
+	CreateClient(namespace, pvc string) (*http.Client, error)
 }
 
 type urlLookupFunc func(string, string, string) string
@@ -67,6 +67,14 @@ type uploadProxyApp struct {
 
 	mux *http.ServeMux
 
+	bandwidthLimiter *bandwidthLimiter
+
+	uploadReadiness *uploadReadiness
+
+	trustedProxies trustedProxies
+
+	timeouts util.ServerTimeouts
+
 	// test hook
 	urlResolver urlLookupFunc
 }
@@ -74,6 +82,27 @@ type uploadProxyApp struct {
 type clientCreator struct {
 	certFetcher   fetcher.CertFetcher
 	bundleFetcher fetcher.CertBundleFetcher
+	timeout       time.Duration
+}
+
+// responseHeaders are the headers of the responses of the upload servers the proxy passes to the client: the
+// progress of an upload session, the ID of an async upload, the methods and Content-Encodings the server accepts and
+// when to retry a request the server is too busy for
+var responseHeaders = []string{
+	common.UploadOffsetHeader,
+	common.UploadCompleteHeader,
+	common.UploadDigestHeader,
+	common.UploadOperationHeader,
+	"Accept-Encoding",
+	"Allow",
+	"Retry-After",
+}
+
+// uploadRequestHeaders are the headers of an upload the proxy passes to the upload server
+var uploadRequestHeaders = []string{
+	"Content-Encoding",
+	"Content-Type",
+	common.UploadCallbackHeader,
 }
 
 var authHeaderMatcher = regexp.MustCompile(`(?i)^Bearer\s+([A-Za-z0-9\-\._~\+\/]+)$`)
@@ -81,24 +110,34 @@ var authHeaderMatcher = regexp.MustCompile(`(?i)^Bearer\s+([A-Za-z0-9\-\._~\+\/]
 // NewUploadProxy returns an initialized uploadProxyApp
 func NewUploadProxy(bindAddress string,
 	bindPort uint,
-	apiServerPublicKey string,
+	apiServerKeys token.PublicKeys,
 	certWatcher CertWatcher,
 	clientCertFetcher fetcher.CertFetcher,
 	serverCAFetcher fetcher.CertBundleFetcher,
-	client kubernetes.Interface) (Server, error) {
+	client kubernetes.Interface,
+	trustedProxies []string,
+	timeouts *cdiv1.UploadTimeouts,
+	stopCh <-chan struct{}) (Server, error) {
 	var err error
 	app := &uploadProxyApp{
-		bindAddress:   bindAddress,
-		bindPort:      bindPort,
-		certWatcher:   certWatcher,
-		clientCreator: &clientCreator{certFetcher: clientCertFetcher, bundleFetcher: serverCAFetcher},
-		client:        client,
-		urlResolver:   controller.GetUploadServerURL,
+		bindAddress: bindAddress,
+		bindPort:    bindPort,
+		certWatcher: certWatcher,
+		clientCreator: &clientCreator{
+			certFetcher:   clientCertFetcher,
+			bundleFetcher: serverCAFetcher,
+			timeout:       controller.UploadProxyRequestTimeout(timeouts),
+		},
+		client:         client,
+		tokenValidator: newUploadTokenValidator(apiServerKeys),
+		timeouts:       controller.UploadServerTimeouts(timeouts),
+		urlResolver:    controller.GetUploadServerURL,
 	}
-	// retrieve RSA key used by apiserver to sign tokens
-	err = app.getSigningKey(apiServerPublicKey)
+	app.bandwidthLimiter = newBandwidthLimiter(client, stopCh)
+	app.uploadReadiness = newUploadReadiness(client, stopCh)
+	app.trustedProxies, err = parseTrustedProxies(trustedProxies)
 	if err != nil {
-		return nil, errors.Errorf("unable to retrieve apiserver signing key: %v", errors.WithStack(err))
+		return nil, err
 	}
 
 	app.initHandlers()
@@ -106,7 +145,7 @@ func NewUploadProxy(bindAddress string,
 	return app, nil
 }
 
-func (c *clientCreator) CreateClient() (*http.Client, error) {
+func (c *clientCreator) CreateClient(namespace, pvc string) (*http.Client, error) {
 	clientCertBytes, err := c.certFetcher.CertBytes()
 	if err != nil {
 		return nil, err
@@ -132,14 +171,18 @@ func (c *clientCreator) CreateClient() (*http.Client, error) {
 		klog.Error("Error parsing uploadserver CA bundle")
 	}
 
+	identity := controller.UploadServerIdentity(namespace, pvc)
 	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{clientCert},
 		RootCAs:      caCertPool,
+		VerifyPeerCertificate: func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			return verifyUploadServerIdentity(verifiedChains[0][0], identity)
+		},
 	}
 	tlsConfig.BuildNameToCertificate()
 
 	transport := &http.Transport{TLSClientConfig: tlsConfig}
-	return &http.Client{Transport: transport, Timeout: proxyRequestTimeout}, nil
+	return &http.Client{Transport: transport, Timeout: c.timeout}, nil
 }
 
 func (app *uploadProxyApp) initHandlers() {
@@ -147,6 +190,10 @@ func (app *uploadProxyApp) initHandlers() {
 	app.mux.HandleFunc(healthzPath, app.handleHealthzRequest)
 	app.mux.HandleFunc(common.UploadPathSync, app.handleUploadRequest)
 	app.mux.HandleFunc(common.UploadPathAsync, app.handleUploadRequest)
+	app.mux.HandleFunc(common.UploadFormPathSync, app.handleUploadRequest)
+	app.mux.HandleFunc(common.UploadFormPathAsync, app.handleUploadRequest)
+	app.mux.HandleFunc(common.UploadPathSession, app.handleSessionRequest)
+	app.mux.HandleFunc(common.UploadPathStatus, app.handleStatusRequest)
 }
 
 func (app *uploadProxyApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -158,103 +205,159 @@ func (app *uploadProxyApp) handleHealthzRequest(w http.ResponseWriter, r *http.R
 }
 
 func (app *uploadProxyApp) handleUploadRequest(w http.ResponseWriter, r *http.Request) {
-	tokenHeader := r.Header.Get("Authorization")
-	if tokenHeader == "" {
-		w.WriteHeader(http.StatusBadRequest)
+	clientAddress, scheme := app.trustedProxies.client(r)
+	tokenData, uploadErr := app.validateUploadToken(r, clientAddress)
+	if uploadErr != nil {
+		writeUploadError(w, uploadErr)
 		return
 	}
 
-	match := authHeaderMatcher.FindStringSubmatch(tokenHeader)
-	if len(match) != 2 {
-		w.WriteHeader(http.StatusBadRequest)
+	klog.V(1).Infof("Received valid token: pvc: %s, namespace: %s, client: %s, scheme: %s", tokenData.Name, tokenData.Namespace, clientAddress, scheme)
+
+	if err := app.uploadReady(tokenData.Name, tokenData.Namespace); err != nil {
+		klog.Error(err)
+		writeUploadError(w, err)
 		return
 	}
 
-	tokenData, err := app.tokenValidator.Validate(match[1])
+	header := http.Header{}
+	for _, name := range uploadRequestHeaders {
+		if value := r.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	app.proxyUploadRequest(tokenData.Namespace, tokenData.Name, clientAddress, scheme, header, w, r)
+}
+
+// validateUploadToken returns the payload of the upload token of r, and tells why it is not accepted
+func (app *uploadProxyApp) validateUploadToken(r *http.Request, clientAddress string) (*token.Payload, *uploadError) {
+	encodedToken, uploadErr := uploadToken(r)
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+
+	tokenData, err := app.tokenValidator.Validate(encodedToken)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+		klog.V(1).Infof("Rejecting invalid token from %s", clientAddress)
+		if token.IsExpired(err) {
+			return nil, newUploadError(http.StatusUnauthorized, ErrorCodeTokenExpired,
+				"The upload token expired, request a new one", false)
+		}
+		return nil, newUploadError(http.StatusUnauthorized, ErrorCodeInvalidToken, "The upload token is invalid", false)
 	}
 
 	if tokenData.Operation != token.OperationUpload ||
 		tokenData.Name == "" ||
 		tokenData.Namespace == "" ||
 		tokenData.Resource.Resource != "persistentvolumeclaims" {
-		klog.Errorf("Bad token %+v", tokenData)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		klog.Errorf("Bad token %+v from %s", tokenData, clientAddress)
+		return nil, newUploadError(http.StatusBadRequest, ErrorCodeWrongTokenOperation,
+			"The token is not for an upload to a PVC", false)
 	}
-
-	klog.V(1).Infof("Received valid token: pvc: %s, namespace: %s", tokenData.Name, tokenData.Namespace)
-
-	err = app.uploadReady(tokenData.Name, tokenData.Namespace)
-	if err != nil {
-		klog.Error(err)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
-	}
-
-	app.proxyUploadRequest(tokenData.Namespace, tokenData.Name, w, r)
+	return tokenData, nil
 }
 
-func (app *uploadProxyApp) uploadReady(pvcName, pvcNamespace string) error {
-	return wait.PollImmediate(waitReadyImterval, waitReadyTime, func() (bool, error) {
-		pvc, err := app.client.CoreV1().PersistentVolumeClaims(pvcNamespace).Get(pvcName, metav1.GetOptions{})
-		if err != nil {
-			if k8serrors.IsNotFound(err) {
-				return false, fmt.Errorf("rejecting Upload Request for PVC %s that doesn't exist", pvcName)
-			}
-
-			return false, err
+// uploadToken returns the upload token in the Authorization header of r, or in the token parameter of a form upload
+// without the header
+func uploadToken(r *http.Request) (string, *uploadError) {
+	tokenHeader := r.Header.Get("Authorization")
+	if tokenHeader == "" {
+		isForm := r.URL.Path == common.UploadFormPathSync || r.URL.Path == common.UploadFormPathAsync
+		if encodedToken := r.URL.Query().Get(uploadTokenParam); isForm && encodedToken != "" {
+			return encodedToken, nil
 		}
+		return "", newUploadError(http.StatusBadRequest, ErrorCodeMissingToken,
+			"The request has no Authorization header with an upload token", false)
+	}
 
-		phase := v1.PodPhase(pvc.Annotations[controller.AnnPodPhase])
-		if phase == v1.PodSucceeded {
-			return false, fmt.Errorf("rejecting Upload Request for PVC %s that already finished uploading", pvcName)
-		}
+	match := authHeaderMatcher.FindStringSubmatch(tokenHeader)
+	if len(match) != 2 {
+		return "", newUploadError(http.StatusBadRequest, ErrorCodeMalformedToken,
+			"The Authorization header is not a bearer token, like \"Bearer <token>\"", false)
+	}
+	return match[1], nil
+}
 
-		ready, _ := strconv.ParseBool(pvc.Annotations[controller.AnnPodReady])
-		return ready, nil
-	})
+// uploadReady waits up to waitReadyTime for the upload server of pvcName to be ready, and tells why it is not
+func (app *uploadProxyApp) uploadReady(pvcName, pvcNamespace string) *uploadError {
+	return app.uploadReadiness.wait(pvcNamespace, pvcName, waitReadyTime)
 }
 
-func (app *uploadProxyApp) proxyUploadRequest(namespace, pvc string, w http.ResponseWriter, r *http.Request) {
+// proxyUploadRequest sends r to the upload server of pvc, telling it the address of the client that sent r and the
+// scheme it used, with the headers in header. It returns the response of the upload server, which is answered
+// already, nil if r could not be sent.
+func (app *uploadProxyApp) proxyUploadRequest(namespace, pvc, clientAddress, scheme string, header http.Header, w http.ResponseWriter, r *http.Request) *http.Response {
 	url := app.urlResolver(namespace, pvc, r.URL.Path)
 
-	req, _ := http.NewRequest(r.Method, url, r.Body)
+	body, err := app.bandwidthLimiter.limit(namespace, r.Body)
+	if err != nil {
+		klog.Errorf("Error limiting upload bandwidth %+v", err)
+		writeUploadError(w, newUploadError(http.StatusInternalServerError, ErrorCodeInternalError,
+			"Unable to limit the upload bandwidth", true))
+		return nil
+	}
+
+	req, _ := http.NewRequest(r.Method, url, body)
 	req.ContentLength = r.ContentLength
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set(forwardedForHeader, clientAddress)
+	req.Header.Set(forwardedProtoHeader, scheme)
 
 	klog.V(3).Infof("Method: %s to: %s", r.Method, url)
 
-	client, err := app.clientCreator.CreateClient()
+	client, err := app.clientCreator.CreateClient(namespace, pvc)
 	if err != nil {
-		klog.Error("Error creating http client")
+		klog.Errorf("Error creating http client %+v", err)
+		writeUploadError(w, newUploadError(http.StatusInternalServerError, ErrorCodeInternalError,
+			"Unable to connect to the upload server", true))
+		return nil
 	}
 
 	response, err := client.Do(req)
 	if err != nil {
 		klog.Errorf("Error proxying %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		writeUploadError(w, newUploadError(http.StatusInternalServerError, ErrorCodeInternalError,
+			"Unable to send the upload to the upload server", true))
+		return nil
 	}
 
+	defer response.Body.Close()
+
 	klog.V(3).Infof("Response status for url %s: %d", url, response.StatusCode)
 
+	for _, name := range responseHeaders {
+		if value := response.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
 	w.WriteHeader(response.StatusCode)
 	_, err = io.Copy(w, response.Body)
 	if err != nil {
 		klog.Warningf("Error proxying response from url %s", url)
 	}
+	return response
 }
 
-func (app *uploadProxyApp) getSigningKey(publicKeyPEM string) error {
-	publicKey, err := controller.DecodePublicKey([]byte(publicKeyPEM))
-	if err != nil {
-		return err
+// verifyUploadServerIdentity checks that the upload server with cert is the one with identity. The certificates of
+// upload servers started before the servers had identities have no URI SANs, their hostnames are checked only.
+func verifyUploadServerIdentity(cert *x509.Certificate, identity *url.URL) error {
+	if len(cert.URIs) == 0 {
+		klog.Warningf("Upload server certificate for %s has no identity", cert.Subject.CommonName)
+		return nil
 	}
+	for _, uri := range cert.URIs {
+		if uri.String() == identity.String() {
+			return nil
+		}
+	}
+	return errors.Errorf("upload server certificate for %s is not for %s", cert.Subject.CommonName, identity)
+}
 
-	app.tokenValidator = token.NewValidator(common.UploadTokenIssuer, publicKey, uploadTokenLeeway)
-	return nil
+// newUploadTokenValidator returns a validator of the upload tokens signed with one of the apiserver keys
+func newUploadTokenValidator(keys token.PublicKeys) token.Validator {
+	return token.NewKeysValidator(common.UploadTokenIssuer, keys, uploadTokenLeeway)
 }
 
 func (app *uploadProxyApp) Start() error {
@@ -263,12 +366,21 @@ func (app *uploadProxyApp) Start() error {
 
 func (app *uploadProxyApp) startTLS() error {
 	var serveFunc func() error
-	bindAddr := fmt.Sprintf("%s:%d", app.bindAddress, app.bindPort)
+	bindAddr := net.JoinHostPort(app.bindAddress, strconv.FormatUint(uint64(app.bindPort), 10))
 
 	server := &http.Server{
 		Addr:    bindAddr,
 		Handler: app,
 	}
+	app.timeouts.Apply(server)
+
+	listener, err := app.timeouts.Listen(bindAddr)
+	if err != nil {
+		return err
+	}
+	if len(app.trustedProxies) > 0 {
+		listener = newProxyProtocolListener(listener, app.trustedProxies)
+	}
 
 	if app.certWatcher != nil {
 		server.TLSConfig = &tls.Config{
@@ -276,11 +388,11 @@ func (app *uploadProxyApp) startTLS() error {
 		}
 
 		serveFunc = func() error {
-			return server.ListenAndServeTLS("", "")
+			return server.ServeTLS(listener, "", "")
 		}
 	} else {
 		serveFunc = func() error {
-			return server.ListenAndServe()
+			return server.Serve(listener)
 		}
 	}
 
@@ -3,11 +3,14 @@ package uploadproxy
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,9 +18,11 @@ import (
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/controller"
 	"kubevirt.io/containerized-data-importer/pkg/token"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/fetcher"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/generator"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/triple"
 )
 
@@ -48,19 +53,6 @@ func (*validateFailure) Validate(string) (*token.Payload, error) {
 	return nil, fmt.Errorf("Bad token")
 }
 
-func getPublicKeyEncoded(t *testing.T) string {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	publicKeyPem, err := cert.EncodePublicKeyPEM(&privateKey.PublicKey)
-	if err != nil {
-		t.Fatal(err)
-	}
-	return string(publicKeyPem)
-}
-
 func getHTTPClientConfig(t *testing.T) *httpClientConfig {
 	caKeyPair, err := triple.NewCA("myca")
 	if err != nil {
@@ -120,17 +112,24 @@ func createApp() *uploadProxyApp {
 	return app
 }
 
-func TestGetSigningKey(t *testing.T) {
-	publicKeyPEM := getPublicKeyEncoded(t)
-	app := createApp()
+func TestUploadTokenValidator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	err := app.getSigningKey(publicKeyPEM)
+	uploadToken, err := token.NewGenerator(common.UploadTokenIssuer, key, time.Minute).Generate(&token.Payload{Operation: token.OperationUpload})
 	if err != nil {
-		t.Errorf("Failed to parse public key pem")
+		t.Fatal(err)
 	}
 
-	if app.tokenValidator == nil {
-		t.Errorf("Failed to create token validator")
+	validator := newUploadTokenValidator(token.PublicKeyList{&rotatedKey.PublicKey, &key.PublicKey})
+	if _, err := validator.Validate(uploadToken); err != nil {
+		t.Errorf("Failed to validate the token of the previous key: %v", err)
 	}
 }
 
@@ -140,12 +139,57 @@ func TestGetUploadServerClient(t *testing.T) {
 	bundleFetcher := &fetcher.MemCertBundleFetcher{Bundle: certs.caCert}
 
 	cc := &clientCreator{certFetcher: certFetcher, bundleFetcher: bundleFetcher}
-	_, err := cc.CreateClient()
+	_, err := cc.CreateClient("default", "testpvc")
 	if err != nil {
 		t.Errorf("Failed to create http client")
 	}
 }
 
+func TestVerifyUploadServerIdentity(t *testing.T) {
+	caKeyPair, err := triple.NewCA("myca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certGenerator := &generator.FetchCertGenerator{Fetcher: &fetcher.MemCertFetcher{
+		Cert: cert.EncodeCertPEM(caKeyPair.Cert),
+		Key:  cert.EncodePrivateKeyPEM(caKeyPair.Key),
+	}}
+	makeCert := func(uris ...*url.URL) *x509.Certificate {
+		certBytes, _, err := certGenerator.MakeServerCert("default", "cdi-upload-testpvc", time.Hour, uris...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs, err := cert.ParseCertsPEM(certBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return certs[0]
+	}
+
+	identity := controller.UploadServerIdentity("default", "testpvc")
+	if identity.String() != "spiffe://upload-server.cdi.kubevirt.io/ns/default/pvc/testpvc" {
+		t.Errorf("Unexpected identity %s", identity)
+	}
+	tests := []struct {
+		name  string
+		cert  *x509.Certificate
+		valid bool
+	}{
+		{"same pvc", makeCert(identity), true},
+		{"other pvc", makeCert(controller.UploadServerIdentity("default", "otherpvc")), false},
+		{"other namespace", makeCert(controller.UploadServerIdentity("other", "testpvc")), false},
+		{"no identity", makeCert(), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyUploadServerIdentity(test.cert, identity)
+			if (err == nil) != test.valid {
+				t.Errorf("Expected valid %t, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
 func TestMalformedAuthHeader(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -168,7 +212,7 @@ type fakeClientCreator struct {
 	client *http.Client
 }
 
-func (fcc *fakeClientCreator) CreateClient() (*http.Client, error) {
+func (fcc *fakeClientCreator) CreateClient(namespace, pvc string) (*http.Client, error) {
 	return fcc.client, nil
 }
 
@@ -190,13 +234,21 @@ func setupProxyTests(handler http.HandlerFunc) *uploadProxyApp {
 		},
 	}
 
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
 	objects := []runtime.Object{}
-	objects = append(objects, pvc)
+	objects = append(objects, pvc, namespace)
 	app := createApp()
 	app.client = k8sfake.NewSimpleClientset(objects...)
 	app.tokenValidator = &validateSuccess{}
 	app.urlResolver = urlResolver
 	app.clientCreator = &fakeClientCreator{client: server.Client()}
+	app.bandwidthLimiter, _ = createBandwidthLimiter(namespace)
+	app.uploadReadiness, _ = createUploadReadiness(app.client)
 
 	return app
 }
@@ -274,3 +326,56 @@ func TestHealthz(t *testing.T) {
 
 	submitRequestAndCheckStatus(t, req, http.StatusOK, nil)
 }
+
+func TestContentEncodingProxy(t *testing.T) {
+	app := setupProxyTests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Accept-Encoding", "gzip, identity")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if encoding := r.Header.Get("Content-Encoding"); encoding != "gzip" {
+			t.Errorf("unexpected Content-Encoding %q", encoding)
+		}
+	}))
+
+	req, err := http.NewRequest("OPTIONS", common.UploadPathSync, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer valid")
+	rr := httptest.NewRecorder()
+	app.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if accepted := rr.Header().Get("Accept-Encoding"); accepted != "gzip, identity" {
+		t.Errorf("unexpected Accept-Encoding %q", accepted)
+	}
+
+	req = newProxyRequest(t, "Bearer valid")
+	req.Header.Set("Content-Encoding", "gzip")
+	submitRequestAndCheckStatus(t, req, http.StatusOK, app)
+}
+
+func TestFormProxy(t *testing.T) {
+	app := setupProxyTests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType := r.Header.Get("Content-Type"); contentType != "multipart/form-data; boundary=b" {
+			t.Errorf("unexpected Content-Type %q", contentType)
+		}
+	}))
+
+	req, err := http.NewRequest("POST", common.UploadFormPathSync+"?token=valid", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+	submitRequestAndCheckStatus(t, req, http.StatusOK, app)
+
+	// Only browsers sending forms cannot set the Authorization header
+	req, err = http.NewRequest("POST", common.UploadPathSync+"?token=valid", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitRequestAndCheckStatus(t, req, http.StatusBadRequest, app)
+}
@@ -0,0 +1,126 @@
+package uploadproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const virtualHostResyncInterval = 30 * time.Second
+
+// VirtualHostCertWatcher serves the certificate of the virtual host a client asks for with SNI, and the
+// certificate of the wrapped CertWatcher to clients asking for other hostnames
+type VirtualHostCertWatcher struct {
+	client       kubernetes.Interface
+	namespace    string
+	secretNames  []string
+	defaultCerts CertWatcher
+
+	mu    sync.RWMutex
+	certs map[string]*tls.Certificate
+}
+
+// NewVirtualHostCertWatcher returns a VirtualHostCertWatcher for the Secrets named secretNames in namespace. The
+// Secrets are read by name, so the upload proxy only needs to get these Secrets and not the others of namespace.
+func NewVirtualHostCertWatcher(client kubernetes.Interface, namespace string, secretNames []string, defaultCerts CertWatcher) *VirtualHostCertWatcher {
+	return &VirtualHostCertWatcher{
+		client:       client,
+		namespace:    namespace,
+		secretNames:  secretNames,
+		defaultCerts: defaultCerts,
+		certs:        make(map[string]*tls.Certificate),
+	}
+}
+
+// Start loads the virtual host Secrets until stopCh is closed
+func (w *VirtualHostCertWatcher) Start(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := w.sync(); err != nil {
+			klog.Errorf("Unable to load virtual host certificates: %+v", err)
+		}
+	}, virtualHostResyncInterval, stopCh)
+}
+
+// sync replaces the certificates with the ones of the Secrets that exist now, missing and invalid Secrets are skipped
+func (w *VirtualHostCertWatcher) sync() error {
+	certs := make(map[string]*tls.Certificate)
+	for _, secretName := range w.secretNames {
+		secret, err := w.client.CoreV1().Secrets(w.namespace).Get(secretName, metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				klog.Warningf("Virtual host secret %s does not exist", secretName)
+				continue
+			}
+			return errors.Wrapf(err, "could not get virtual host secret %s", secretName)
+		}
+		cert, names, err := parseVirtualHostSecret(secret)
+		if err != nil {
+			klog.Errorf("Skipping virtual host secret %s: %v", secret.Name, err)
+			continue
+		}
+		for _, name := range names {
+			if other, ok := certs[name]; ok && other != cert {
+				klog.Warningf("Hostname %s of virtual host secret %s is also in another secret", name, secret.Name)
+				continue
+			}
+			certs[name] = cert
+		}
+	}
+
+	klog.V(3).Infof("Loaded certificates for %d virtual hostnames", len(certs))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.certs = certs
+	return nil
+}
+
+// parseVirtualHostSecret returns the certificate of secret and the lowercase hostnames it is valid for
+func parseVirtualHostSecret(secret *v1.Secret) (*tls.Certificate, []string, error) {
+	cert, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	cert.Leaf = leaf
+	if len(leaf.DNSNames) == 0 {
+		return nil, nil, errors.New("certificate has no DNS names")
+	}
+	var names []string
+	for _, name := range leaf.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	return &cert, names, nil
+}
+
+// GetCertificate returns the certificate of the virtual host named by the client, a wildcard certificate
+// matching the name, or the default certificate
+func (w *VirtualHostCertWatcher) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		w.mu.RLock()
+		cert, ok := w.certs[name]
+		if !ok {
+			if i := strings.Index(name, "."); i > 0 {
+				cert, ok = w.certs["*"+name[i:]]
+			}
+		}
+		w.mu.RUnlock()
+		if ok {
+			return cert, nil
+		}
+	}
+	return w.defaultCerts.GetCertificate(hello)
+}
@@ -0,0 +1,146 @@
+package uploadproxy
+
+import (
+	"crypto/tls"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/triple"
+)
+
+type fakeCertWatcher struct {
+	cert *tls.Certificate
+}
+
+func (f *fakeCertWatcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return f.cert, nil
+}
+
+func createVirtualHostSecret(t *testing.T, name string, hostnames ...string) *corev1.Secret {
+	ca, err := triple.NewCA("myca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := triple.NewServerKeyPair(ca, name, name, "cdi", "cluster.local", nil, hostnames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "cdi",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       cert.EncodeCertPEM(keyPair.Cert),
+			corev1.TLSPrivateKeyKey: cert.EncodePrivateKeyPEM(keyPair.Key),
+		},
+	}
+	return secret
+}
+
+func TestVirtualHostCertificates(t *testing.T) {
+	tenantA := createVirtualHostSecret(t, "tenant-a", "upload.tenant-a.com")
+	tenantB := createVirtualHostSecret(t, "tenant-b", "*.tenant-b.com")
+	unlisted := createVirtualHostSecret(t, "unlisted", "upload.unlisted.com")
+	invalid := createVirtualHostSecret(t, "invalid", "upload.invalid.com")
+	invalid.Data[corev1.TLSPrivateKeyKey] = []byte("not a key")
+
+	defaultCert := &tls.Certificate{}
+	client := k8sfake.NewSimpleClientset(tenantA, tenantB, unlisted, invalid)
+	watcher := NewVirtualHostCertWatcher(client, "cdi", []string{"tenant-a", "tenant-b", "invalid", "missing"}, &fakeCertWatcher{cert: defaultCert})
+	if err := watcher.sync(); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" || action.(k8stesting.GetAction).GetName() == "unlisted" {
+			t.Errorf("the watcher may only get the listed secrets, got %s %v", action.GetVerb(), action)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		serverName string
+		expected   string
+	}{
+		{
+			"Exact hostname",
+			"upload.tenant-a.com",
+			"tenant-a",
+		},
+		{
+			"Hostname in another case",
+			"Upload.Tenant-A.com.",
+			"tenant-a",
+		},
+		{
+			"Wildcard hostname",
+			"proxy.tenant-b.com",
+			"tenant-b",
+		},
+		{
+			"Wildcard matches one label only",
+			"a.proxy.tenant-b.com",
+			"",
+		},
+		{
+			"Secret not listed",
+			"upload.unlisted.com",
+			"",
+		},
+		{
+			"Invalid secret",
+			"upload.invalid.com",
+			"",
+		},
+		{
+			"No SNI",
+			"",
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := watcher.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expected == "" {
+				if got != defaultCert {
+					t.Errorf("expected the default certificate, got %s", got.Leaf.Subject.CommonName)
+				}
+				return
+			}
+			if got == defaultCert || got.Leaf.Subject.CommonName != test.expected {
+				t.Errorf("expected the certificate of %s", test.expected)
+			}
+		})
+	}
+}
+
+func TestVirtualHostSecretRemoved(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(createVirtualHostSecret(t, "tenant-a", "upload.tenant-a.com"))
+	defaultCert := &tls.Certificate{}
+	watcher := NewVirtualHostCertWatcher(client, "cdi", []string{"tenant-a"}, &fakeCertWatcher{cert: defaultCert})
+	if err := watcher.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := watcher.GetCertificate(&tls.ClientHelloInfo{ServerName: "upload.tenant-a.com"}); got == defaultCert {
+		t.Fatal("expected the certificate of tenant-a")
+	}
+
+	if err := client.CoreV1().Secrets("cdi").Delete("tenant-a", &metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := watcher.GetCertificate(&tls.ClientHelloInfo{ServerName: "upload.tenant-a.com"}); got != defaultCert {
+		t.Error("expected the default certificate after the secret was removed")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util"
+)
+
+// callbackTimeout is how long the server waits for the callback of an async upload to answer
+const callbackTimeout = 10 * time.Second
+
+// nonPublicNetworks are the networks, besides the loopback, link-local, multicast and unspecified addresses, a callback
+// is not sent to. They hold the pod and service networks of the cluster.
+var nonPublicNetworks = parseCIDRs("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+var (
+	// callbackIPAllowed tells if a callback may be sent to ip, may be overridden in tests
+	callbackIPAllowed = isPublicIP
+	// callbackRootCAs verify the certificate of the callback server, nil for the system CAs, may be overridden in tests
+	callbackRootCAs *x509.CertPool
+)
+
+// asyncOperation is an async upload, whose status clients poll with its ID once the upload request returned
+type asyncOperation struct {
+	status util.UploadStatus
+	// callback is the URL the status is POSTed to once the upload is done or failed, empty for none
+	callback string
+}
+
+// uploadCallback returns the callback of the async upload r. It returns false, after writing the status to w, if the
+// callback is not a HTTPS URL, or its host resolves to an address of the cluster or the node.
+func uploadCallback(w http.ResponseWriter, r *http.Request) (string, bool) {
+	callback := r.Header.Get(common.UploadCallbackHeader)
+	if callback == "" {
+		return "", true
+	}
+	u, err := url.Parse(callback)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "The upload callback is not a HTTPS URL")
+		return "", false
+	}
+	if err := checkCallbackHost(r.Context(), u.Hostname()); err != nil {
+		klog.Warningf("Rejecting the upload callback %s: %v", callback, err)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "The upload callback is not a public address")
+		return "", false
+	}
+	return callback, true
+}
+
+// checkCallbackHost fails unless all the addresses host resolves to may be sent a callback
+func checkCallbackHost(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return errors.Wrapf(err, "could not resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !callbackIPAllowed(addr.IP) {
+			return errors.Errorf("%s resolves to %s", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// checkCallbackDial fails the connection to a callback, unless the address it dials may be sent a callback. The
+// address was resolved again since uploadCallback checked the host, and may have changed.
+func checkCallbackDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !callbackIPAllowed(ip) {
+		return errors.Errorf("the upload callback may not be sent to %s", host)
+	}
+	return nil
+}
+
+// isPublicIP tells if ip is neither an address of the cluster, of the node, nor a multicast or unspecified address
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// startOperation starts an async upload with callback, telling its ID in the response
+func (app *uploadServerApp) startOperation(w http.ResponseWriter, callback string) {
+	operation := &asyncOperation{
+		status: util.UploadStatus{
+			Operation: string(uuid.NewUUID()),
+			Phase:     util.UploadPhaseReceiving,
+		},
+		callback: callback,
+	}
+
+	app.mutex.Lock()
+	app.operation = operation
+	app.mutex.Unlock()
+
+	w.Header().Set(common.UploadOperationHeader, operation.status.Operation)
+}
+
+// setOperationPhase moves the async upload to phase, err is why it failed. The callback of the upload is sent once it
+// is done or failed.
+func (app *uploadServerApp) setOperationPhase(phase util.UploadPhase, err error) {
+	app.mutex.Lock()
+	operation := app.operation
+	if operation == nil {
+		app.mutex.Unlock()
+		return
+	}
+	operation.status.Phase = phase
+	if err != nil {
+		operation.status.Message = err.Error()
+	}
+	status := operation.status
+	app.mutex.Unlock()
+
+	klog.Infof("Async upload %s is %s", status.Operation, phase)
+	if operation.callback != "" && (phase == util.UploadPhaseDone || phase == util.UploadPhaseError) {
+		sendCallback(operation.callback, status)
+	}
+}
+
+// statusHandler answers with the status of the async upload in the operation parameter, 404 if the server does not
+// know of it
+func (app *uploadServerApp) statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !app.validClientCert(w, r) {
+		return
+	}
+
+	app.mutex.Lock()
+	operation := app.operation
+	var status util.UploadStatus
+	if operation != nil {
+		status = operation.status
+	}
+	app.mutex.Unlock()
+
+	if operation == nil || status.Operation != r.URL.Query().Get(common.UploadOperationParam) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		klog.Warningf("Error writing the upload status: %v", err)
+	}
+}
+
+// sendCallback POSTs status to the callback of an async upload, a callback that fails is not retried. The callback
+// is sent without a proxy, only to public addresses, and does not follow redirects.
+func sendCallback(callback string, status util.UploadStatus) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		klog.Errorf("Error encoding the upload status: %v", err)
+		return
+	}
+	dialer := &net.Dialer{Timeout: callbackTimeout, Control: checkCallbackDial}
+	client := &http.Client{
+		Timeout: callbackTimeout,
+		Transport: &http.Transport{
+			DialContext:     dialer.DialContext,
+			TLSClientConfig: &tls.Config{RootCAs: callbackRootCAs},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Post(callback, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Warningf("Error sending the upload callback: %v", err)
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode >= 300 {
+		klog.Warningf("Upload callback answered with %d", response.StatusCode)
+	}
+}
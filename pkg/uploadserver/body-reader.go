@@ -0,0 +1,70 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/importer"
+)
+
+// bodyReader counts the bytes read from the body of an upload request. The read that ends the body before its
+// Content-Length, or goes past it, fails with a ByteCountError, so an upload that lost data is not taken for complete.
+type bodyReader struct {
+	body     io.ReadCloser
+	expected int64
+	received int64
+}
+
+// newBodyReader returns a bodyReader of the body of r, which does not check the Content-Length with
+// DataVolumeVerificationNone
+func newBodyReader(r *http.Request, verification cdiv1.DataVolumeVerification) *bodyReader {
+	expected := r.ContentLength
+	if verification == cdiv1.DataVolumeVerificationNone {
+		expected = -1
+	}
+	return &bodyReader{body: r.Body, expected: expected}
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.received += int64(n)
+	if b.expected < 0 {
+		return n, err
+	}
+	if b.received > b.expected || ((err == io.EOF || err == io.ErrUnexpectedEOF) && b.received < b.expected) {
+		return n, &importer.ByteCountError{Counted: "received", Actual: b.received, Expected: b.expected}
+	}
+	return n, err
+}
+
+func (b *bodyReader) Close() error {
+	return b.body.Close()
+}
+
+// finish reads the rest of the body, which processing may leave unread, such as the other disks of a XVA export or
+// the end of a tar archive, and returns an error unless the whole body was received.
+func (b *bodyReader) finish() error {
+	_, err := io.Copy(ioutil.Discard, b)
+	return err
+}
@@ -0,0 +1,199 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/importer"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+// maxChunkSize is the size of the largest chunk the server accepts, chunks are held in memory until their checksum
+// is checked
+const maxChunkSize = 64 << 20
+
+// chunkedUpload is an upload a clone source sends in chunks, each with its own checksum, so that a chunk corrupted in
+// transit is sent again rather than the whole upload. The chunks are written, in order, to the stream the upload
+// processor reads.
+type chunkedUpload struct {
+	contentType string
+	// sourceSize is the size of the raw image the clone source declares with its first chunk
+	sourceSize int64
+	writer     *io.PipeWriter
+	// offset is where the next chunk starts in the stream
+	offset int64
+	// err is the result of processing the stream, set once done is closed
+	err   error
+	done  chan struct{}
+	mutex sync.Mutex
+}
+
+// chunkHandler takes a chunk at the offset and of the checksum in the request. A corrupted chunk is answered with
+// 422, so that the clone source sends it again, and a chunk that was taken already is answered with 200. The last
+// chunk is answered with the result of the upload.
+func (app *uploadServerApp) chunkHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.validateClient(w, r) || !validateCloneContentEncoding(w, r) {
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Invalid chunk offset")
+		return
+	}
+	last := r.URL.Query().Get("last") == "true"
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxChunkSize+1))
+	if err != nil {
+		klog.Errorf("Error reading chunk at offset %d: %v", offset, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxChunkSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if sum, expected := checksum.ChunkSum(data), r.Header.Get(checksum.ChunkHeader); sum != expected {
+		klog.Warningf("Chunk at offset %d has checksum %s instead of %s, asking for it again", offset, sum, expected)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	upload, status := app.startChunkedUpload(offset, r.Header.Get(UploadContentTypeHeader), declaredSourceSize(r))
+	if upload == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	upload.mutex.Lock()
+	defer upload.mutex.Unlock()
+
+	end := offset + int64(len(data))
+	if offset < upload.offset && end <= upload.offset {
+		klog.V(1).Infof("Chunk at offset %d was taken already", offset)
+		return
+	}
+	if offset != upload.offset {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Expected chunk at offset "+strconv.FormatInt(upload.offset, 10))
+		return
+	}
+	if _, err := upload.writer.Write(data); err != nil {
+		// Processing the stream failed, its error is the result of the upload
+		app.endChunkedUpload(w, upload, "")
+		return
+	}
+	upload.offset = end
+	klog.V(3).Infof("Took chunk of %d bytes at offset %d", len(data), offset)
+
+	if last {
+		app.endChunkedUpload(w, upload, r.Header.Get(checksum.Header))
+	}
+}
+
+// startChunkedUpload returns the chunked upload a chunk at offset belongs to, starting it with its first chunk. A
+// clone source that restarted sends its first chunk again, which starts the upload over. It returns the status to
+// answer the chunk with if it does not belong to an upload.
+func (app *uploadServerApp) startChunkedUpload(offset int64, contentType string, sourceSize int64) (*chunkedUpload, int) {
+	app.mutex.Lock()
+	previous := app.chunked
+	if previous != nil && offset != 0 {
+		app.mutex.Unlock()
+		return previous, 0
+	}
+	status := 0
+	switch {
+	case app.done:
+		status = http.StatusConflict
+	case previous == nil && (app.uploading || app.processing):
+		status = http.StatusServiceUnavailable
+	case offset != 0:
+		// The chunks before were taken by a server that is gone
+		status = http.StatusBadRequest
+	}
+	if status != 0 {
+		app.mutex.Unlock()
+		return nil, status
+	}
+	stream, writer := io.Pipe()
+	upload := &chunkedUpload{
+		contentType: contentType,
+		sourceSize:  sourceSize,
+		writer:      writer,
+		done:        make(chan struct{}),
+	}
+	app.chunked = upload
+	app.uploading = true
+	app.mutex.Unlock()
+
+	if previous != nil {
+		klog.Info("Clone source started over, dropping the chunks taken so far")
+		previous.writer.CloseWithError(errors.New("clone source started over"))
+		<-previous.done
+	}
+	go app.processChunks(upload, stream)
+	return upload, 0
+}
+
+// processChunks runs the upload processor on the stream of the chunks of upload
+func (app *uploadServerApp) processChunks(upload *chunkedUpload, stream *io.PipeReader) {
+	err := app.processUpload(stream, upload.contentType, importer.UploadSize{Source: upload.sourceSize})
+	if err == nil {
+		// Take what the processor left, such as the padding of an archive, so that no chunk is refused
+		_, err = io.Copy(ioutil.Discard, stream)
+	}
+	stream.CloseWithError(err)
+	upload.err = err
+	close(upload.done)
+}
+
+// endChunkedUpload waits for the processor of upload and answers with the result of the upload, verified against
+// checksums if the clone source sent any
+func (app *uploadServerApp) endChunkedUpload(w http.ResponseWriter, upload *chunkedUpload, checksums string) {
+	upload.writer.Close()
+	<-upload.done
+
+	app.mutex.Lock()
+	current := app.chunked == upload
+	if current {
+		app.chunked = nil
+	}
+	app.mutex.Unlock()
+	if !current {
+		// The clone source started over
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	err := upload.err
+	if err == nil {
+		err = app.verifyClone(checksums, upload.contentType)
+	}
+	app.finishUpload(w, err)
+}
@@ -0,0 +1,117 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+const (
+	// defaultMaxConcurrentRequests is how many requests with upload data the server takes at once if the limit is
+	// not set
+	defaultMaxConcurrentRequests = 4
+
+	// concurrencyRetryAfter is how long the client of a request over the limit is told to wait before it retries
+	concurrencyRetryAfter = 5 * time.Second
+)
+
+var (
+	requestsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "upload_server_requests_in_flight",
+			Help: "The requests with upload data the upload server is handling",
+		},
+		[]string{"ownerUID"},
+	)
+	maxRequestsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "upload_server_max_requests_in_flight",
+			Help: "The requests with upload data the upload server takes at once",
+		},
+		[]string{"ownerUID"},
+	)
+	requestsRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "upload_server_requests_rejected_total",
+			Help: "The requests with upload data the upload server answered with 429, because it was handling as many as it takes at once",
+		},
+		[]string{"ownerUID"},
+	)
+	ownerUID string
+)
+
+func init() {
+	for _, collector := range []prometheus.Collector{requestsInFlight, maxRequestsInFlight, requestsRejected} {
+		if err := prometheus.Register(collector); err != nil {
+			klog.Errorf("Unable to register the upload server metrics: %v", err)
+		}
+	}
+	ownerUID = os.Getenv(common.OwnerUID)
+}
+
+// concurrencyLimiter bounds the requests with upload data the server handles at once, each of which may hold a chunk
+// in memory
+type concurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// newConcurrencyLimiter returns a limiter to max requests, defaultMaxConcurrentRequests if max is not positive
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	if max <= 0 {
+		max = defaultMaxConcurrentRequests
+	}
+	maxRequestsInFlight.WithLabelValues(ownerUID).Set(float64(max))
+	return &concurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// limit returns handler, answering the POSTs over the limit with 429. The other requests carry no data.
+func (l *concurrencyLimiter) limit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handler(w, r)
+			return
+		}
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			klog.Warningf("Rejecting upload request, %d requests are in flight", cap(l.slots))
+			requestsRejected.WithLabelValues(ownerUID).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(concurrencyRetryAfter/time.Second)))
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, "Too many concurrent upload requests")
+			return
+		}
+		requestsInFlight.WithLabelValues(ownerUID).Inc()
+		defer func() {
+			requestsInFlight.WithLabelValues(ownerUID).Dec()
+			<-l.slots
+		}()
+		handler(w, r)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// contentEncodingGzip is the Content-Encoding of a gzip compressed upload
+	contentEncodingGzip = "gzip"
+	// contentEncodingIdentity is the Content-Encoding of an upload that is not compressed
+	contentEncodingIdentity = "identity"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// cloneContentEncodings are the Content-Encodings of the streams of clone sources the server takes, whichever uploads
+// it decompresses. A clone stream without a Content-Encoding is gzip compressed, clone sources that do not negotiate
+// the encoding always compress it.
+var cloneContentEncodings = []string{contentEncodingGzip, contentEncodingIdentity}
+
+// parseContentEncodings returns the Content-Encodings in the comma separated list value the server can decompress
+func parseContentEncodings(value string) []string {
+	var encodings []string
+	for _, encoding := range strings.Split(value, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		switch encoding {
+		case "", contentEncodingIdentity:
+		case contentEncodingGzip:
+			encodings = append(encodings, encoding)
+		default:
+			klog.Warningf("Ignoring unsupported content encoding %q", encoding)
+		}
+	}
+	return encodings
+}
+
+// acceptEncoding returns the value of the Accept-Encoding header with the Content-Encodings the server accepts
+func (app *uploadServerApp) acceptEncoding() string {
+	return strings.Join(append(append([]string{}, app.contentEncodings...), contentEncodingIdentity), ", ")
+}
+
+// validateContentEncoding checks that the server can decompress the upload of r, answering with 415 and the
+// encodings it accepts if not
+func (app *uploadServerApp) validateContentEncoding(w http.ResponseWriter, r *http.Request) bool {
+	encoding := strings.ToLower(r.Header.Get("Content-Encoding"))
+	if encoding == "" || encoding == contentEncodingIdentity {
+		return true
+	}
+	for _, accepted := range app.contentEncodings {
+		if encoding == accepted {
+			return true
+		}
+	}
+	w.Header().Set("Accept-Encoding", app.acceptEncoding())
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	io.WriteString(w, "Unsupported content encoding "+encoding)
+	return false
+}
+
+// validateCloneContentEncoding checks that the server takes the clone stream of r, answering with 415 and the
+// encodings it takes if not
+func validateCloneContentEncoding(w http.ResponseWriter, r *http.Request) bool {
+	encoding := strings.ToLower(r.Header.Get("Content-Encoding"))
+	if encoding == "" {
+		return true
+	}
+	for _, accepted := range cloneContentEncodings {
+		if encoding == accepted {
+			return true
+		}
+	}
+	w.Header().Set("Accept-Encoding", strings.Join(cloneContentEncodings, ", "))
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	io.WriteString(w, "Unsupported content encoding "+encoding)
+	return false
+}
+
+// optionsHandler answers an OPTIONS request for an upload path with the methods and the Content-Encodings the server
+// accepts
+func (app *uploadServerApp) optionsHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.validClientCert(w, r) {
+		return
+	}
+	w.Header().Set("Allow", "POST, HEAD, OPTIONS")
+	w.Header().Set("Accept-Encoding", app.acceptEncoding())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodedBody decompresses the body of an upload on the fly
+type decodedBody struct {
+	io.Reader
+	body *bodyReader
+}
+
+func (d *decodedBody) Close() error {
+	return d.body.Close()
+}
+
+// finish reads the rest of the decompressed data, which checks the trailer of a gzip stream, and the rest of the body
+func (d *decodedBody) finish() error {
+	if _, err := io.Copy(ioutil.Discard, d.Reader); err != nil {
+		return errors.Wrap(err, "Error decompressing the upload")
+	}
+	return d.body.finish()
+}
+
+// uploadStream is the data of an upload the processor reads, finish reads what the processor left
+type uploadStream interface {
+	io.ReadCloser
+	finish() error
+}
+
+// decodeBody returns the data of the upload in body with Content-Encoding encoding, which validateContentEncoding
+// accepted
+func decodeBody(body *bodyReader, encoding string) (uploadStream, error) {
+	switch strings.ToLower(encoding) {
+	case contentEncodingGzip:
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error decompressing the upload")
+		}
+		return &decodedBody{Reader: reader, body: body}, nil
+	}
+	return body, nil
+}
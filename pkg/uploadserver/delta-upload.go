@@ -0,0 +1,220 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/util"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+const (
+	// minDeltaBlockSize is the size of the smallest blocks the server sums for a delta clone
+	minDeltaBlockSize = 4096
+	// DestinationSizeHeader is the header of the block sums with the size of the destination block device
+	DestinationSizeHeader = "x-cdi-destination-size"
+)
+
+// isDeltaDestination returns true if the destination can be cloned by delta, a block device, may be overridden in tests
+var isDeltaDestination = isBlockDevice
+
+// deltaUpload is a clone of a block device whose destination holds an earlier copy of the source, so the clone source
+// sends only the blocks that differ. Each chunk is written at its offset in the destination, so a chunk sent again,
+// or a clone source that starts over, writes the same data again without harm.
+type deltaUpload struct {
+	file *os.File
+	// written is how many bytes the chunks wrote
+	written int64
+	mutex   sync.Mutex
+}
+
+// blockHashesHandler answers with the checksums of the blocks of the blockSize in the request of the destination
+// block device, one after the other, and its size in the DestinationSizeHeader. A destination that is not a block
+// device is answered with 404, so that the clone source sends the whole stream instead.
+func (app *uploadServerApp) blockHashesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !app.validClientCert(w, r) {
+		return
+	}
+	blockSize, err := strconv.Atoi(r.URL.Query().Get("blockSize"))
+	if err != nil || blockSize < minDeltaBlockSize || blockSize > maxChunkSize {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Invalid block size")
+		return
+	}
+	if !isDeltaDestination(app.destination) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Only block devices are cloned by delta")
+		return
+	}
+
+	app.mutex.Lock()
+	done, busy := app.done, app.uploading && app.delta == nil
+	app.mutex.Unlock()
+	if done {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, "Upload done")
+		return
+	}
+	if busy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "Upload in progress")
+		return
+	}
+
+	size, err := util.DestinationSize(app.destination)
+	if err != nil {
+		klog.Errorf("Unable to size %s: %v", app.destination, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(app.destination)
+	if err != nil {
+		klog.Errorf("Unable to open %s: %v", app.destination, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	sums, err := checksum.BlockSums(io.LimitReader(f, size), blockSize)
+	if err != nil {
+		klog.Errorf("Unable to sum the blocks of %s: %v", app.destination, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	klog.Infof("Summed %d blocks of %d bytes of %s", len(sums)/checksum.BlockSumSize, blockSize, app.destination)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(DestinationSizeHeader, strconv.FormatInt(size, 10))
+	w.Write(sums)
+}
+
+// deltaHandler writes a chunk of a delta clone at the offset in the request, once its checksum matches. A corrupted
+// chunk is answered with 422, so that the clone source sends it again. The last chunk, which may be empty, is
+// answered with the result of the clone, verified against the checksums of the source if it sent any.
+func (app *uploadServerApp) deltaHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.validateClient(w, r) {
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Invalid chunk offset")
+		return
+	}
+	last := r.URL.Query().Get("last") == "true"
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxChunkSize+1))
+	if err != nil {
+		klog.Errorf("Error reading chunk at offset %d: %v", offset, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxChunkSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if sum, expected := checksum.ChunkSum(data), r.Header.Get(checksum.ChunkHeader); sum != expected {
+		klog.Warningf("Chunk at offset %d has checksum %s instead of %s, asking for it again", offset, sum, expected)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	upload, status, err := app.startDeltaUpload()
+	if err != nil {
+		klog.Errorf("%v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, err.Error())
+		return
+	}
+	if upload == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	upload.mutex.Lock()
+	defer upload.mutex.Unlock()
+	if len(data) > 0 {
+		if _, err := upload.file.WriteAt(data, offset); err != nil {
+			klog.Errorf("Error writing chunk at offset %d: %v", offset, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, err.Error())
+			return
+		}
+		upload.written += int64(len(data))
+		klog.V(3).Infof("Wrote chunk of %d bytes at offset %d", len(data), offset)
+	}
+
+	if last {
+		app.endDeltaUpload(w, upload, r.Header.Get(checksum.Header))
+	}
+}
+
+// startDeltaUpload returns the delta upload a chunk belongs to, opening the destination with the first chunk. It
+// returns the status to answer the chunk with if it does not belong to a delta upload.
+func (app *uploadServerApp) startDeltaUpload() (*deltaUpload, int, error) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	if app.delta != nil {
+		return app.delta, 0, nil
+	}
+	if app.done {
+		return nil, http.StatusConflict, nil
+	}
+	if app.uploading || app.processing || !isDeltaDestination(app.destination) {
+		return nil, http.StatusServiceUnavailable, nil
+	}
+	f, err := os.OpenFile(app.destination, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not open %s", app.destination)
+	}
+	klog.Info("Starting delta clone")
+	app.delta = &deltaUpload{file: f}
+	app.uploading = true
+	return app.delta, 0, nil
+}
+
+// endDeltaUpload flushes the chunks of upload to the destination and answers with the result of the clone
+func (app *uploadServerApp) endDeltaUpload(w http.ResponseWriter, upload *deltaUpload, checksums string) {
+	err := upload.file.Sync()
+	if closeErr := upload.file.Close(); err == nil {
+		err = closeErr
+	}
+	app.mutex.Lock()
+	app.delta = nil
+	app.mutex.Unlock()
+
+	if err == nil {
+		klog.Infof("Delta clone wrote %d bytes", upload.written)
+		err = app.verifyClone(checksums, BlockdeviceCloneContentType)
+	}
+	app.finishUpload(w, err)
+}
@@ -0,0 +1,96 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"kubevirt.io/containerized-data-importer/pkg/importer"
+)
+
+// UploadFormFileField is the field of a multipart/form-data upload with the image
+const UploadFormFileField = "file"
+
+// InvalidFormError indicates that a form upload is not multipart/form-data, or has no image.
+type InvalidFormError struct {
+	Reason string
+}
+
+func (e *InvalidFormError) Error() string {
+	return fmt.Sprintf("Invalid form upload: %s", e.Reason)
+}
+
+// formReader reads the image in the file field of a multipart/form-data upload
+type formReader struct {
+	io.Reader
+	stream uploadStream
+}
+
+func (f *formReader) Close() error {
+	return f.stream.Close()
+}
+
+// finish reads the rest of the form, the fields after the file are ignored
+func (f *formReader) finish() error {
+	return f.stream.finish()
+}
+
+// newFormReader returns a reader of the image in the form in stream, whose Content-Type is contentType. The fields
+// before the file are skipped.
+func newFormReader(stream uploadStream, contentType string) (*formReader, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, &InvalidFormError{Reason: "the Content-Type is not multipart/form-data"}
+	}
+	reader := multipart.NewReader(stream, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, &InvalidFormError{Reason: fmt.Sprintf("the form has no %s field", UploadFormFileField)}
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading the form")
+		}
+		if part.FormName() == UploadFormFileField {
+			return &formReader{Reader: part, stream: stream}, nil
+		}
+	}
+}
+
+// formStream returns the image in the form of the upload in r
+func (app *uploadServerApp) formStream(r *http.Request) (uploadStream, importer.UploadSize, error) {
+	stream, size, err := app.bodyStream(r)
+	if err != nil {
+		return nil, size, err
+	}
+	// The length of the form is not the length of the image
+	size.ContentLength = 0
+	form, err := newFormReader(stream, r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, size, err
+	}
+	return form, size, nil
+}
@@ -0,0 +1,294 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+// maxPendingSessionBytes is how much data of the chunks that arrived ahead of the offset of an upload session is held
+// in memory, until the chunks before them arrive
+const maxPendingSessionBytes = 4 * maxChunkSize
+
+// sessionUpload is an upload session, whose chunks may be sent over several connections and through several upload
+// proxies, so that they may arrive out of order. The chunks are written, in order, to the stream the upload processor
+// reads, the ones ahead of the offset wait in pending.
+type sessionUpload struct {
+	*chunkedUpload
+	id string
+	// digest is the sha256 digest of the data written so far
+	digest hash.Hash
+	// pending are the chunks that arrived ahead of the offset, by their offset
+	pending      map[int64][]byte
+	pendingBytes int
+	// length is the length of the upload, -1 until the chunk completing it arrived
+	length int64
+	// ended is set once the stream was closed
+	ended bool
+}
+
+// sessionHandler takes a chunk of an upload session at the offset in the request, and answers a HEAD request with
+// the progress of the session. Every response tells the offset up to which the session was written and the digest of
+// the data so far, a chunk that could not be taken is sent again. The chunk that completes the session is answered
+// with the result of the upload.
+func (app *uploadServerApp) sessionHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(common.UploadSessionHeader)
+	if r.Method == http.MethodHead {
+		if app.validClientCert(w, r) {
+			app.sessionStatus(w, id)
+		}
+		return
+	}
+	if !app.validateClient(w, r) {
+		return
+	}
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Missing upload session")
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(common.UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "Invalid chunk offset")
+		return
+	}
+	complete, _ := strconv.ParseBool(r.Header.Get(common.UploadCompleteHeader))
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxChunkSize+1))
+	if err != nil {
+		klog.Errorf("Error reading chunk of session %s at offset %d: %v", id, offset, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxChunkSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if expected := r.Header.Get(checksum.ChunkHeader); expected != "" && checksum.ChunkSum(data) != expected {
+		klog.Warningf("Chunk of session %s at offset %d is corrupted, asking for it again", id, offset)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	session, status := app.startSession(id, r.Header.Get(UploadContentTypeHeader), declaredSourceSize(r))
+	if session == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if status, message := session.take(offset, data, complete); status != http.StatusOK {
+		writeSessionHeaders(w, session)
+		w.WriteHeader(status)
+		io.WriteString(w, message)
+		return
+	}
+	if session.ended {
+		if session.err != nil {
+			// The failed session was dropped
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		writeSessionHeaders(w, session)
+		return
+	}
+	if err := session.writePending(); err != nil {
+		// Processing the stream failed, its error is the result of the upload
+		app.endSession(w, session)
+		return
+	}
+	writeSessionHeaders(w, session)
+	if session.offset == session.length {
+		app.endSession(w, session)
+	}
+}
+
+// take adds the chunk data at offset to the pending chunks of session, it returns the status and the message to
+// answer a chunk that does not fit the session with
+func (session *sessionUpload) take(offset int64, data []byte, complete bool) (int, string) {
+	end := offset + int64(len(data))
+	if complete {
+		if session.length >= 0 && session.length != end {
+			return http.StatusBadRequest, "The upload session was completed at offset " + strconv.FormatInt(session.length, 10)
+		}
+		session.length = end
+	}
+	if session.length >= 0 && end > session.length {
+		return http.StatusBadRequest, "The chunk ends past the end of the upload session"
+	}
+	if end <= session.offset {
+		klog.V(1).Infof("Chunk of session %s at offset %d was taken already", session.id, offset)
+		return http.StatusOK, ""
+	}
+	if offset > session.offset && session.pendingBytes+len(data) > maxPendingSessionBytes {
+		return http.StatusServiceUnavailable, "Too many chunks ahead of offset " + strconv.FormatInt(session.offset, 10)
+	}
+	if previous, ok := session.pending[offset]; ok {
+		if len(previous) >= len(data) {
+			return http.StatusOK, ""
+		}
+		session.pendingBytes -= len(previous)
+	}
+	session.pending[offset] = data
+	session.pendingBytes += len(data)
+	return http.StatusOK, ""
+}
+
+// writePending writes the pending chunks that continue the data of session to the stream
+func (session *sessionUpload) writePending() error {
+	for {
+		var next []byte
+		for offset, data := range session.pending {
+			if offset > session.offset {
+				continue
+			}
+			delete(session.pending, offset)
+			session.pendingBytes -= len(data)
+			if end := offset + int64(len(data)); end > session.offset {
+				next = data[session.offset-offset:]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		if _, err := session.writer.Write(next); err != nil {
+			return err
+		}
+		session.digest.Write(next)
+		session.offset += int64(len(next))
+		klog.V(3).Infof("Wrote %d bytes of session %s, up to offset %d", len(next), session.id, session.offset)
+	}
+}
+
+// startSession returns the upload session with id, starting it with its first chunk. A new session replaces the
+// session before it, which starts the upload over. It returns the status to answer the chunk with if it does not
+// belong to a session.
+func (app *uploadServerApp) startSession(id, contentType string, sourceSize int64) (*sessionUpload, int) {
+	app.mutex.Lock()
+	previous := app.session
+	if previous != nil && previous.id == id {
+		app.mutex.Unlock()
+		return previous, 0
+	}
+	status := 0
+	switch {
+	case app.done:
+		status = http.StatusConflict
+	case previous == nil && (app.uploading || app.processing):
+		status = http.StatusServiceUnavailable
+	}
+	if status != 0 {
+		app.mutex.Unlock()
+		return nil, status
+	}
+	stream, writer := io.Pipe()
+	session := &sessionUpload{
+		chunkedUpload: &chunkedUpload{
+			contentType: contentType,
+			sourceSize:  sourceSize,
+			writer:      writer,
+			done:        make(chan struct{}),
+		},
+		id:      id,
+		digest:  sha256.New(),
+		pending: make(map[int64][]byte),
+		length:  -1,
+	}
+	app.session = session
+	app.uploading = true
+	app.mutex.Unlock()
+
+	if previous != nil {
+		klog.Infof("Upload session %s replaced by %s, dropping the data written so far", previous.id, id)
+		previous.writer.CloseWithError(errors.New("upload session replaced"))
+		<-previous.done
+	}
+	go app.processChunks(session.chunkedUpload, stream)
+	return session, 0
+}
+
+// endSession waits for the processor of session and answers with the result of the upload. A failed session is
+// dropped, so that it starts over from its first chunk.
+func (app *uploadServerApp) endSession(w http.ResponseWriter, session *sessionUpload) {
+	session.ended = true
+	session.writer.Close()
+	<-session.done
+
+	app.mutex.Lock()
+	current := app.session == session
+	if current && session.err != nil {
+		app.session = nil
+	}
+	app.mutex.Unlock()
+	if !current {
+		// The session was replaced
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	app.finishUpload(w, session.err)
+}
+
+// sessionStatus answers with the progress of the upload session with id, a session the server does not know about
+// has to start over
+func (app *uploadServerApp) sessionStatus(w http.ResponseWriter, id string) {
+	app.mutex.Lock()
+	session := app.session
+	done := app.done
+	app.mutex.Unlock()
+
+	if session == nil || session.id != id {
+		if done {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Header().Set(common.UploadOffsetHeader, "0")
+		w.Header().Set(common.UploadCompleteHeader, "false")
+		return
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	writeSessionHeaders(w, session)
+}
+
+// writeSessionHeaders tells the progress of session in the headers of a response
+func writeSessionHeaders(w http.ResponseWriter, session *sessionUpload) {
+	w.Header().Set(common.UploadOffsetHeader, strconv.FormatInt(session.offset, 10))
+	w.Header().Set(common.UploadCompleteHeader, strconv.FormatBool(session.offset == session.length))
+	w.Header().Set(common.UploadDigestHeader, "sha256:"+hex.EncodeToString(session.digest.Sum(nil)))
+}
@@ -0,0 +1,114 @@
+/*
+ * This file is part of the CDI project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2020 Red Hat, Inc.
+ *
+ */
+
+package uploadserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	"kubevirt.io/containerized-data-importer/pkg/importer"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
+)
+
+// StateChecksumError indicates that an uploaded state blob does not match the checksum of the DataVolume. The blob
+// is dropped, so the upload can be sent again.
+type StateChecksumError struct {
+	Err error
+}
+
+func (e *StateChecksumError) Error() string {
+	return fmt.Sprintf("State blob verification failed: %v", e.Err)
+}
+
+// may be overridden in tests
+var stateProcessorFunc = stateBlobProcessor
+
+// isStateUpload returns true if the server takes a blob of VM state, which is written to its destination as it is
+func (app *uploadServerApp) isStateUpload() bool {
+	return app.contentType == string(cdiv1.DataVolumeKubeVirtState)
+}
+
+// processUpload writes the data of an upload in stream to the destination. A state blob is written as it is, whatever
+// content type the client tells, everything else goes through the upload processor.
+func (app *uploadServerApp) processUpload(stream io.ReadCloser, contentType string, size importer.UploadSize) error {
+	if app.isStateUpload() {
+		return stateProcessorFunc(stream, app.destination, app.checksum)
+	}
+	return uploadProcessorFunc(stream, app.destination, app.imageSize, contentType, app.verification, app.blockWipe, size)
+}
+
+// stateBlobProcessor writes the state blob in stream to dest, verified against digest if it is set. The blob is
+// written to a temporary file next to dest first, so dest only ever holds a complete and verified blob.
+func stateBlobProcessor(stream io.ReadCloser, dest, digest string) error {
+	var h hash.Hash
+	var expected []byte
+	reader := io.Reader(stream)
+	if digest != "" {
+		var err error
+		if h, expected, err = checksum.ParseDigest(digest); err != nil {
+			return err
+		}
+		reader = io.TeeReader(stream, h)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(dest), ".cdi-state")
+	if err != nil {
+		return errors.Wrap(err, "error creating the state file")
+	}
+	defer os.Remove(f.Name())
+
+	// Temporary files are only readable by their owner, the blob is read like the images on the volume
+	err = f.Chmod(0644)
+	var written int64
+	if err == nil {
+		written, err = io.Copy(f, reader)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "error writing the state blob")
+	}
+	if h != nil {
+		if sum := h.Sum(nil); !bytes.Equal(sum, expected) {
+			return &StateChecksumError{Err: errors.Errorf("the %d bytes uploaded have digest %s:%s instead of %s",
+				written, strings.SplitN(digest, ":", 2)[0], hex.EncodeToString(sum), digest)}
+		}
+	}
+	if err := os.Rename(f.Name(), dest); err != nil {
+		return errors.Wrap(err, "error moving the state blob in place")
+	}
+	klog.Infof("Wrote state blob of %d bytes to %s", written, dest)
+	return nil
+}
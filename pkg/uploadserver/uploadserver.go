@@ -20,6 +20,8 @@
 package uploadserver
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/tls"
@@ -29,21 +31,30 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/importer"
 	"kubevirt.io/containerized-data-importer/pkg/util"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
 )
 
 const (
 	// UploadContentTypeHeader is the header upload clients may use to set the content type explicitly
 	UploadContentTypeHeader = "x-cdi-content-type"
 
+	// UploadSourceSizeHeader is the header upload clients may use to declare the size of the raw image, so that an
+	// image that does not fit the target block device is refused before it is transferred
+	UploadSourceSizeHeader = "x-cdi-source-size"
+
 	// FilesystemCloneContentType is the content type when cloning a filesystem
 	FilesystemCloneContentType = "filesystem-clone"
 
@@ -76,37 +87,98 @@ type uploadServerApp struct {
 	done        bool
 	doneChan    chan struct{}
 	errChan     chan error
+	chunked     *chunkedUpload
+	delta       *deltaUpload
+	session     *sessionUpload
 	mutex       sync.Mutex
+	// operation is the last async upload, whose status clients poll
+	operation *asyncOperation
+	// verifiedMessage is the result of verifying a clone, reported with the content digest after the upload
+	verifiedMessage string
+	// verification is how thoroughly the uploaded data is verified
+	verification cdiv1.DataVolumeVerification
+	// blockWipe is how a destination block device is wiped before the data is written, empty to not wipe it
+	blockWipe cdiv1.DataVolumeBlockWipe
+	// contentEncodings are the Content-Encodings of uploads the server decompresses
+	contentEncodings []string
+	// contentType is the content type of the DataVolume, only a kubevirt-state DataVolume overrides what clients tell
+	contentType string
+	// checksum is the digest a state blob is verified against, empty to not verify it
+	checksum string
+	// timeouts are the timeouts and keep-alive period of the upload connections
+	timeouts util.ServerTimeouts
+}
+
+// DestinationNotWritableError indicates that the upload server cannot write to its destination, such as a read-only mount.
+type DestinationNotWritableError struct {
+	Destination string
+	Err         error
+}
+
+func (e *DestinationNotWritableError) Error() string {
+	return fmt.Sprintf("Destination %s is not writable: %v", e.Destination, e.Err)
+}
+
+// CloneVerificationError indicates that the data a clone wrote does not match the checksums of the clone source.
+type CloneVerificationError struct {
+	Err error
+}
+
+func (e *CloneVerificationError) Error() string {
+	return fmt.Sprintf("Clone verification failed: %v", e.Err)
 }
 
 // may be overridden in tests
 var uploadProcessorFunc = newUploadStreamProcessor
 var uploadProcessorFuncAsync = newAsyncUploadStreamProcessor
+var checkDestinationFunc = checkDestination
+var verifyCloneFunc = checksum.Verify
+var writeTerminationMessageFunc = util.WriteTerminationMessage
 
 // NewUploadServer returns a new instance of uploadServerApp
-func NewUploadServer(bindAddress string, bindPort int, destination, tlsKey, tlsCert, clientCert, clientName, imageSize string) UploadServer {
+func NewUploadServer(bindAddress string, bindPort int, destination, tlsKey, tlsCert, clientCert, clientName, imageSize, verification, blockWipe, contentEncodings, contentType, checksum string, maxConcurrentRequests int, timeouts util.ServerTimeouts) UploadServer {
 	server := &uploadServerApp{
-		bindAddress: bindAddress,
-		bindPort:    bindPort,
-		destination: destination,
-		tlsKey:      tlsKey,
-		tlsCert:     tlsCert,
-		clientCert:  clientCert,
-		clientName:  clientName,
-		imageSize:   imageSize,
-		mux:         http.NewServeMux(),
-		uploading:   false,
-		done:        false,
-		doneChan:    make(chan struct{}),
-		errChan:     make(chan error),
+		bindAddress:      bindAddress,
+		bindPort:         bindPort,
+		destination:      destination,
+		tlsKey:           tlsKey,
+		tlsCert:          tlsCert,
+		clientCert:       clientCert,
+		clientName:       clientName,
+		imageSize:        imageSize,
+		verification:     cdiv1.DataVolumeVerification(verification),
+		blockWipe:        cdiv1.DataVolumeBlockWipe(blockWipe),
+		contentEncodings: parseContentEncodings(contentEncodings),
+		contentType:      contentType,
+		checksum:         checksum,
+		timeouts:         timeouts,
+		mux:              http.NewServeMux(),
+		uploading:        false,
+		done:             false,
+		doneChan:         make(chan struct{}),
+		errChan:          make(chan error),
 	}
 	server.mux.HandleFunc(healthzPath, server.healthzHandler)
-	server.mux.HandleFunc(common.UploadPathSync, server.uploadHandler)
-	server.mux.HandleFunc(common.UploadPathAsync, server.uploadHandlerAsync)
+	limiter := newConcurrencyLimiter(maxConcurrentRequests)
+	server.mux.HandleFunc(common.UploadPathSync, limiter.limit(server.uploadHandler(server.bodyStream)))
+	server.mux.HandleFunc(common.UploadPathAsync, limiter.limit(server.uploadHandlerAsync(server.bodyStream)))
+	server.mux.HandleFunc(common.UploadFormPathSync, limiter.limit(server.uploadHandler(server.formStream)))
+	server.mux.HandleFunc(common.UploadFormPathAsync, limiter.limit(server.uploadHandlerAsync(server.formStream)))
+	server.mux.HandleFunc(common.UploadPathChunked, limiter.limit(server.chunkHandler))
+	server.mux.HandleFunc(common.UploadPathDelta, limiter.limit(server.deltaHandler))
+	server.mux.HandleFunc(common.UploadPathBlockHashes, limiter.limit(server.blockHashesHandler))
+	server.mux.HandleFunc(common.UploadPathSession, limiter.limit(server.sessionHandler))
+	server.mux.HandleFunc(common.UploadPathReady, server.readyHandler)
+	server.mux.HandleFunc(common.UploadPathStatus, server.statusHandler)
 	return server
 }
 
 func (app *uploadServerApp) Run() error {
+	// Fail before accepting any data if the destination cannot be written, rather than in the middle of a transfer
+	if err := checkDestinationFunc(app.destination); err != nil {
+		return err
+	}
+
 	uploadServer, err := app.createUploadServer()
 	if err != nil {
 		return errors.Wrap(err, "Error creating upload http server")
@@ -117,7 +189,7 @@ func (app *uploadServerApp) Run() error {
 		return errors.Wrap(err, "Error creating healthz http server")
 	}
 
-	uploadListener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", app.bindAddress, app.bindPort))
+	uploadListener, err := app.timeouts.Listen(net.JoinHostPort(app.bindAddress, strconv.Itoa(app.bindPort)))
 	if err != nil {
 		return errors.Wrap(err, "Error creating upload listerner")
 	}
@@ -152,6 +224,10 @@ func (app *uploadServerApp) Run() error {
 	case err = <-app.errChan:
 		klog.Errorf("HTTP server returned error %s", err.Error())
 	case <-app.doneChan:
+		// Clients poll the status of an async upload until it is verified
+		app.setOperationPhase(util.UploadPhaseVerifying, nil)
+		app.writeTerminationMessage()
+		app.setOperationPhase(util.UploadPhaseDone, nil)
 		klog.Info("Shutting down http server after successful upload")
 		healthzServer.Shutdown(context.Background())
 		uploadServer.Shutdown(context.Background())
@@ -164,6 +240,7 @@ func (app *uploadServerApp) createUploadServer() (*http.Server, error) {
 	server := &http.Server{
 		Handler: app,
 	}
+	app.timeouts.Apply(server)
 
 	if app.tlsKey != "" && app.tlsCert != "" {
 		certDir, err := ioutil.TempDir("", "uploadserver-tls")
@@ -214,12 +291,53 @@ func (app *uploadServerApp) healthzHandler(w http.ResponseWriter, r *http.Reques
 	io.WriteString(w, "OK")
 }
 
-func (app *uploadServerApp) validateShouldHandleRequest(w http.ResponseWriter, r *http.Request) bool {
+// readyHandler tells clients whether the server can write to its destination and accept an upload, so that they
+// check before they start streaming. It tells clone sources the encodings of the streams it takes too.
+func (app *uploadServerApp) readyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept-Encoding", strings.Join(cloneContentEncodings, ", "))
+	if err := checkDestinationFunc(app.destination); err != nil {
+		klog.Errorf("%v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, err.Error())
+		return
+	}
+
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+
+	if app.chunked != nil || app.delta != nil {
+		// A clone source that restarted starts its chunked or delta upload over
+		io.WriteString(w, "OK")
+		return
+	}
+
+	if app.uploading || app.processing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "Upload in progress")
+		return
+	}
+
+	if app.done {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, "Upload done")
+		return
+	}
+
+	io.WriteString(w, "OK")
+}
+
+// validateClient returns false, after writing the status to w, if r is not a POST from the client of the server
+func (app *uploadServerApp) validateClient(w http.ResponseWriter, r *http.Request) bool {
 	if r.Method != "POST" {
 		w.WriteHeader(http.StatusNotFound)
 		return false
 	}
 
+	return app.validClientCert(w, r)
+}
+
+// validClientCert checks that a TLS request comes from the client the server expects, answering with 401 if not
+func (app *uploadServerApp) validClientCert(w http.ResponseWriter, r *http.Request) bool {
 	if r.TLS != nil {
 		found := false
 
@@ -237,6 +355,13 @@ func (app *uploadServerApp) validateShouldHandleRequest(w http.ResponseWriter, r
 	} else {
 		klog.V(3).Infof("Handling HTTP connection")
 	}
+	return true
+}
+
+func (app *uploadServerApp) validateShouldHandleRequest(w http.ResponseWriter, r *http.Request) bool {
+	if !app.validateClient(w, r) || !app.validateContentEncoding(w, r) {
+		return false
+	}
 
 	exit := func() bool {
 		app.mutex.Lock()
@@ -263,27 +388,62 @@ func (app *uploadServerApp) validateShouldHandleRequest(w http.ResponseWriter, r
 	return true
 }
 
-func (app *uploadServerApp) uploadHandlerAsync(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "HEAD" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
+// uploadStreamFunc returns the data of the upload in r, and what its client tells about the size of the image
+type uploadStreamFunc func(r *http.Request) (uploadStream, importer.UploadSize, error)
 
-	if !app.validateShouldHandleRequest(w, r) {
-		return
+func (app *uploadServerApp) uploadHandlerAsync(streamFunc uploadStreamFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method == http.MethodOptions {
+			app.optionsHandler(w, r)
+			return
+		}
+
+		callback, ok := uploadCallback(w, r)
+		if !ok || !app.validateShouldHandleRequest(w, r) {
+			return
+		}
+
+		app.uploadAsync(w, r, streamFunc, callback)
 	}
+}
+
+func (app *uploadServerApp) uploadAsync(w http.ResponseWriter, r *http.Request, streamFunc uploadStreamFunc, callback string) {
+	app.startOperation(w, callback)
 
 	cdiContentType := r.Header.Get(UploadContentTypeHeader)
 
 	klog.Infof("Content type header is %q\n", cdiContentType)
 
-	processor, err := uploadProcessorFuncAsync(r.Body, app.destination, app.imageSize, cdiContentType)
+	var processor *importer.DataProcessor
+	stream, size, err := streamFunc(r)
+	if err == nil {
+		if app.isStateUpload() {
+			// A state blob is written as it arrives, nothing is left to process after responding
+			err = app.processUpload(stream, cdiContentType, size)
+		} else {
+			processor, err = uploadProcessorFuncAsync(stream, app.destination, app.imageSize, cdiContentType, app.verification, app.blockWipe, size)
+		}
+	}
+	if err == nil {
+		// The data is in scratch space or the target, the rest is processed after responding
+		err = stream.finish()
+	}
+
+	if err != nil {
+		app.setOperationPhase(util.UploadPhaseError, err)
+	} else {
+		app.setOperationPhase(util.UploadPhaseConverting, nil)
+	}
 
 	app.mutex.Lock()
 
 	if err != nil {
 		klog.Errorf("Saving stream failed: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeUploadError(w, err)
 		app.uploading = false
 		app.mutex.Unlock()
 		return
@@ -296,9 +456,12 @@ func (app *uploadServerApp) uploadHandlerAsync(w http.ResponseWriter, r *http.Re
 	// Start processing.
 	go func() {
 		defer close(app.doneChan)
-		if err := processor.ProcessDataResume(); err != nil {
-			klog.Errorf("Error during resumed processing: %v", err)
-			app.errChan <- err
+		if processor != nil {
+			if err := processor.ProcessDataResume(); err != nil {
+				klog.Errorf("Error during resumed processing: %v", err)
+				app.setOperationPhase(util.UploadPhaseError, err)
+				app.errChan <- err
+			}
 		}
 		app.mutex.Lock()
 		defer app.mutex.Unlock()
@@ -309,24 +472,48 @@ func (app *uploadServerApp) uploadHandlerAsync(w http.ResponseWriter, r *http.Re
 	klog.Info("Returning success to caller, continue processing in background")
 }
 
-func (app *uploadServerApp) uploadHandler(w http.ResponseWriter, r *http.Request) {
-	if !app.validateShouldHandleRequest(w, r) {
-		return
-	}
+func (app *uploadServerApp) uploadHandler(streamFunc uploadStreamFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			app.optionsHandler(w, r)
+			return
+		}
+		if !app.validateShouldHandleRequest(w, r) {
+			return
+		}
 
-	cdiContentType := r.Header.Get(UploadContentTypeHeader)
+		cdiContentType := r.Header.Get(UploadContentTypeHeader)
 
-	klog.Infof("Content type header is %q\n", cdiContentType)
+		klog.Infof("Content type header is %q\n", cdiContentType)
 
-	err := uploadProcessorFunc(r.Body, app.destination, app.imageSize, cdiContentType)
+		stream, size, err := streamFunc(r)
+		if err == nil {
+			err = app.processUpload(stream, cdiContentType, size)
+		}
+		if err == nil {
+			err = stream.finish()
+		}
 
+		app.finishUpload(w, err)
+	}
+}
+
+// finishUpload responds to the request that ended an upload with its result err. The server shuts down after a
+// successful upload, and exits with the cause after a failed clone verification.
+func (app *uploadServerApp) finishUpload(w http.ResponseWriter, err error) {
 	app.mutex.Lock()
 	defer app.mutex.Unlock()
 
 	if err != nil {
 		klog.Errorf("Saving stream failed: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeUploadError(w, err)
 		app.uploading = false
+		if _, ok := err.(*CloneVerificationError); ok {
+			// Exit with the cause, the clone is retried with a new upload server
+			go func() {
+				app.errChan <- err
+			}()
+		}
 		return
 	}
 
@@ -338,33 +525,172 @@ func (app *uploadServerApp) uploadHandler(w http.ResponseWriter, r *http.Request
 	klog.Infof("Wrote data to %s", app.destination)
 }
 
-func newAsyncUploadStreamProcessor(stream io.ReadCloser, dest, imageSize, contentType string) (*importer.DataProcessor, error) {
+// writeUploadError responds to a failed upload. Clients are told how much of the data arrived, if that is why it
+// failed, and how much the target block device is short of an image that does not fit it.
+func writeUploadError(w http.ResponseWriter, err error) {
+	switch errors.Cause(err).(type) {
+	case *importer.ImageTooLargeError:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		io.WriteString(w, err.Error())
+	case *importer.ByteCountError:
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, err.Error())
+	case *InvalidFormError:
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, err.Error())
+	case *StateChecksumError:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		io.WriteString(w, err.Error())
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// bodyStream returns the body of the upload in r, decompressed
+func (app *uploadServerApp) bodyStream(r *http.Request) (uploadStream, importer.UploadSize, error) {
+	stream, err := decodeBody(newBodyReader(r, app.verification), r.Header.Get("Content-Encoding"))
+	return stream, uploadSize(r), err
+}
+
+// uploadSize returns what the client of the upload in r tells about the size of the image
+func uploadSize(r *http.Request) importer.UploadSize {
+	size := importer.UploadSize{Source: declaredSourceSize(r)}
+	// The length of a compressed upload is not the length of its data
+	if r.ContentLength > 0 && r.Header.Get("Content-Encoding") == "" {
+		size.ContentLength = r.ContentLength
+	}
+	return size
+}
+
+// declaredSourceSize returns the size of the raw image the client declares with the UploadSourceSizeHeader, 0 if it
+// does not declare a valid size
+func declaredSourceSize(r *http.Request) int64 {
+	size, err := strconv.ParseInt(r.Header.Get(UploadSourceSizeHeader), 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// verifyClone reads back the data a clone wrote and compares it to value, the checksums the clone source sent with
+// the last chunk, if it sent any. Only verified clones report the result in the termination message.
+func (app *uploadServerApp) verifyClone(value, contentType string) error {
+	if value == "" {
+		return nil
+	}
+	manifest, err := checksum.Parse(value)
+	if err != nil {
+		return err
+	}
+	root := app.destination
+	if contentType == FilesystemCloneContentType {
+		root = common.ImporterVolumePath
+	}
+	if err := verifyCloneFunc(manifest, root); err != nil {
+		if _, ok := err.(*checksum.MismatchError); ok {
+			return &CloneVerificationError{Err: err}
+		}
+		return err
+	}
+	app.verifiedMessage = fmt.Sprintf("Verified %d bytes in %d files against the checksums of the clone source", manifest.Size(), len(manifest))
+	klog.Info(app.verifiedMessage)
+	return nil
+}
+
+// writeTerminationMessage reports the result of a successful upload: the result of verifying the clone, if it was
+// verified, the size of the image written to the destination and its content digest, unless the verification is none
+// or fast.
+func (app *uploadServerApp) writeTerminationMessage() {
+	message := &util.TerminationMessage{Result: util.TerminationSucceeded, Message: app.verifiedMessage}
+	if app.verification == cdiv1.DataVolumeVerificationNone || app.verification == cdiv1.DataVolumeVerificationFast {
+		klog.V(1).Infof("Not computing the content digest with %s verification", app.verification)
+	} else if digest, err := checksum.ContentDigestFile(app.destination); err != nil {
+		klog.Errorf("Unable to compute the content digest: %+v", err)
+	} else if digest != "" {
+		klog.Infof("Content digest %s", digest)
+		message.Digest = digest
+	}
+	var err error
+	if message.Bytes, err = util.DestinationSize(app.destination); err != nil {
+		klog.Errorf("Unable to size the image: %+v", err)
+	}
+	if err := writeTerminationMessageFunc(message); err != nil {
+		klog.Errorf("%+v", err)
+	}
+}
+
+func newAsyncUploadStreamProcessor(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) (*importer.DataProcessor, error) {
+	if err := importer.ScrubScratchSpace(common.ScratchDataDir); err != nil {
+		return nil, err
+	}
 	uds := importer.NewAsyncUploadDataSource(stream)
+	uds.SetVerification(verification)
+	uds.SetUploadSize(size)
+	uds.SetStreamConversion(isBlockDevice(dest))
 	processor := importer.NewDataProcessor(uds, dest, common.ImporterVolumePath, common.ScratchDataDir, imageSize)
+	processor.SetVerification(verification)
+	processor.SetBlockWipe(blockWipe)
 	return processor, processor.ProcessDataWithPause()
 }
 
-func newUploadStreamProcessor(stream io.ReadCloser, dest, imageSize, contentType string) error {
+func newUploadStreamProcessor(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) error {
 	if contentType == FilesystemCloneContentType {
 		return filesystemCloneProcessor(stream, common.ImporterVolumePath)
 	}
 
 	uds := importer.NewUploadDataSource(stream)
+	uds.SetVerification(verification)
+	uds.SetUploadSize(size)
+	uds.SetStreamConversion(isBlockDevice(dest))
 	processor := importer.NewDataProcessor(uds, dest, common.ImporterVolumePath, common.ScratchDataDir, imageSize)
+	processor.SetVerification(verification)
+	processor.SetBlockWipe(blockWipe)
 	return processor.ProcessData()
 }
 
+// isBlockDevice returns true if destination is a block device, a qcow2 image is converted while it is uploaded to a
+// block device, block volumes get no scratch space
+func isBlockDevice(destination string) bool {
+	info, err := os.Stat(destination)
+	return err == nil && info.Mode()&os.ModeDevice != 0
+}
+
+// checkDestination returns a DestinationNotWritableError if the block device, or the directory of the file, at
+// destination cannot be written.
+func checkDestination(destination string) error {
+	if isBlockDevice(destination) {
+		f, err := os.OpenFile(destination, os.O_WRONLY, 0)
+		if err != nil {
+			return &DestinationNotWritableError{Destination: destination, Err: err}
+		}
+		return f.Close()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(destination), ".cdi-write-check")
+	if err != nil {
+		return &DestinationNotWritableError{Destination: destination, Err: err}
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
 func filesystemCloneProcessor(stream io.ReadCloser, destDir string) error {
 	if err := importer.CleanDir(destDir); err != nil {
 		return errors.Wrapf(err, "error removing contents of %s", destDir)
 	}
 
-	gzr, err := gzip.NewReader(stream)
-	if err != nil {
-		return errors.Wrap(err, "error creting gzip reader")
+	// The tar archive is gzip compressed unless the clone source sent it uncompressed
+	buffered := bufio.NewReader(stream)
+	var tarStream io.Reader = buffered
+	if magic, _ := buffered.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gzr, err := gzip.NewReader(buffered)
+		if err != nil {
+			return errors.Wrap(err, "error creting gzip reader")
+		}
+		tarStream = gzr
 	}
 
-	if err = util.UnArchiveTar(gzr, destDir); err != nil {
+	if err := util.UnArchiveTar(tarStream, destDir); err != nil {
 		return errors.Wrapf(err, "error unarchiving to %s", destDir)
 	}
 
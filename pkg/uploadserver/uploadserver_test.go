@@ -20,25 +20,42 @@
 package uploadserver
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	"kubevirt.io/containerized-data-importer/pkg/common"
 	"kubevirt.io/containerized-data-importer/pkg/importer"
+	"kubevirt.io/containerized-data-importer/pkg/util"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert"
 	"kubevirt.io/containerized-data-importer/pkg/util/cert/triple"
+	"kubevirt.io/containerized-data-importer/pkg/util/checksum"
 )
 
 func newServer() *uploadServerApp {
-	server := NewUploadServer("127.0.0.1", 0, "disk.img", "", "", "", "", "")
+	server := NewUploadServer("127.0.0.1", 0, "disk.img", "", "", "", "", "", "", "", "", "", "", 0, util.ServerTimeouts{})
 	return server.(*uploadServerApp)
 }
 
@@ -53,7 +70,7 @@ func newTLSServer(t *testing.T, clientCertName, expectedName string) (*uploadSer
 		t.Error("Error creating CA")
 	}
 
-	serverKeyPair, err := triple.NewServerKeyPair(serverCA, "localhost", "localhost", "default", "local", []string{"127.0.0.1"}, []string{"localhost"})
+	serverKeyPair, err := triple.NewServerKeyPair(serverCA, "localhost", "localhost", "default", "local", []string{"127.0.0.1", "::1"}, []string{"localhost"})
 	if err != nil {
 		t.Error("Error creating server cert")
 	}
@@ -62,7 +79,7 @@ func newTLSServer(t *testing.T, clientCertName, expectedName string) (*uploadSer
 	tlsCert := string(cert.EncodeCertPEM(serverKeyPair.Cert))
 	clientCert := string(cert.EncodeCertPEM(clientCA.Cert))
 
-	server := NewUploadServer("127.0.0.1", 0, "disk.img", tlsKey, tlsCert, clientCert, expectedName, "").(*uploadServerApp)
+	server := NewUploadServer("127.0.0.1", 0, "disk.img", tlsKey, tlsCert, clientCert, expectedName, "", "", "", "", "", "", 0, util.ServerTimeouts{}).(*uploadServerApp)
 
 	clientKeyPair, err := triple.NewClientKeyPair(clientCA, clientCertName, []string{})
 	if err != nil {
@@ -117,11 +134,11 @@ func newAsyncHeadRequest(t *testing.T) *http.Request {
 	return req
 }
 
-func saveProcessorSuccess(stream io.ReadCloser, dest, imageSize, contentType string) error {
+func saveProcessorSuccess(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) error {
 	return nil
 }
 
-func saveProcessorFailure(stream io.ReadCloser, dest, imageSize, contentType string) error {
+func saveProcessorFailure(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) error {
 	return fmt.Errorf("Error using datastream")
 }
 
@@ -133,7 +150,7 @@ func withProcessorFailure(f func()) {
 	replaceProcessorFunc(saveProcessorFailure, f)
 }
 
-func replaceProcessorFunc(replacement func(io.ReadCloser, string, string, string) error, f func()) {
+func replaceProcessorFunc(replacement func(io.ReadCloser, string, string, string, cdiv1.DataVolumeVerification, cdiv1.DataVolumeBlockWipe, importer.UploadSize) error, f func()) {
 	origProcessorFunc := uploadProcessorFunc
 	uploadProcessorFunc = replacement
 	defer func() {
@@ -180,11 +197,11 @@ func (amd *AsyncMockDataSource) GetResumePhase() importer.ProcessingPhase {
 	return importer.ProcessingPhaseComplete
 }
 
-func saveAsyncProcessorSuccess(stream io.ReadCloser, dest, imageSize, contentType string) (*importer.DataProcessor, error) {
+func saveAsyncProcessorSuccess(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) (*importer.DataProcessor, error) {
 	return importer.NewDataProcessor(&AsyncMockDataSource{}, "", "", "", ""), nil
 }
 
-func saveAsyncProcessorFailure(stream io.ReadCloser, dest, imageSize, contentType string) (*importer.DataProcessor, error) {
+func saveAsyncProcessorFailure(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) (*importer.DataProcessor, error) {
 	return importer.NewDataProcessor(&AsyncMockDataSource{}, "", "", "", ""), fmt.Errorf("Error using datastream")
 }
 
@@ -196,7 +213,7 @@ func withAsyncProcessorFailure(f func()) {
 	replaceAsyncProcessorFunc(saveAsyncProcessorFailure, f)
 }
 
-func replaceAsyncProcessorFunc(replacement func(io.ReadCloser, string, string, string) (*importer.DataProcessor, error), f func()) {
+func replaceAsyncProcessorFunc(replacement func(io.ReadCloser, string, string, string, cdiv1.DataVolumeVerification, cdiv1.DataVolumeBlockWipe, importer.UploadSize) (*importer.DataProcessor, error), f func()) {
 	origProcessorFuncAsync := uploadProcessorFuncAsync
 	uploadProcessorFuncAsync = replacement
 	defer func() {
@@ -241,6 +258,99 @@ func TestHealthz(t *testing.T) {
 	}
 }
 
+func withDestinationNotWritable(f func()) {
+	origCheckDestinationFunc := checkDestinationFunc
+	checkDestinationFunc = func(destination string) error {
+		return &DestinationNotWritableError{Destination: destination, Err: fmt.Errorf("read-only file system")}
+	}
+	defer func() {
+		checkDestinationFunc = origCheckDestinationFunc
+	}()
+	f()
+}
+
+func TestReady(t *testing.T) {
+	type testData struct {
+		uploading, done bool
+		expectedStatus  int
+	}
+	for _, data := range []testData{
+		{expectedStatus: http.StatusOK},
+		{uploading: true, expectedStatus: http.StatusServiceUnavailable},
+		{done: true, expectedStatus: http.StatusConflict},
+	} {
+		req, err := http.NewRequest("GET", common.UploadPathReady, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		server := newServer()
+		server.uploading = data.uploading
+		server.done = data.done
+		server.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != data.expectedStatus {
+			t.Errorf("handler returned wrong status code: got %v want %v",
+				status, data.expectedStatus)
+		}
+		if accepted := rr.Header().Get("Accept-Encoding"); accepted != "gzip, identity" {
+			t.Errorf("unexpected Accept-Encoding %q", accepted)
+		}
+	}
+}
+
+func TestReadyNotWritable(t *testing.T) {
+	withDestinationNotWritable(func() {
+		req, err := http.NewRequest("GET", common.UploadPathReady, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+
+		server := newServer()
+		server.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusServiceUnavailable {
+			t.Errorf("handler returned wrong status code: got %v want %v",
+				status, http.StatusServiceUnavailable)
+		}
+		if !strings.Contains(rr.Body.String(), "not writable") {
+			t.Errorf("handler returned unexpected body %q", rr.Body.String())
+		}
+	})
+}
+
+func TestRunNotWritable(t *testing.T) {
+	withDestinationNotWritable(func() {
+		server := newServer()
+		err := server.Run()
+		if _, ok := err.(*DestinationNotWritableError); !ok {
+			t.Errorf("Run returned %v, wanted a DestinationNotWritableError", err)
+		}
+	})
+}
+
+func TestCheckDestination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploadserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := checkDestination(filepath.Join(dir, "disk.img")); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Check left %d files behind", len(files))
+	}
+	if _, ok := checkDestination(filepath.Join(dir, "missing", "disk.img")).(*DestinationNotWritableError); !ok {
+		t.Error("Expected a DestinationNotWritableError for a missing directory")
+	}
+}
+
 func TestInProcessUnavailable(t *testing.T) {
 	withProcessorSuccess(func() {
 		req := newRequest(t)
@@ -386,6 +496,81 @@ func TestStreamFailAsync(t *testing.T) {
 		}
 	})
 }
+func TestShortBody(t *testing.T) {
+	withProcessorSuccess(func() {
+		req := newRequest(t)
+		// The client declared more than it sent
+		req.ContentLength = 10
+
+		rr := httptest.NewRecorder()
+
+		server := newServer()
+		server.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusInternalServerError {
+			t.Errorf("handler returned wrong status code: got %v want %v",
+				status, http.StatusInternalServerError)
+		}
+		if body := rr.Body.String(); body != "received 4 bytes, expected 10 bytes" {
+			t.Errorf("handler returned wrong body: got %q", body)
+		}
+	})
+}
+
+func TestShortBodyAsync(t *testing.T) {
+	withAsyncProcessorSuccess(func() {
+		req := newAsyncRequest(t)
+		req.ContentLength = 10
+
+		rr := httptest.NewRecorder()
+
+		server := newServer()
+		server.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusInternalServerError {
+			t.Errorf("handler returned wrong status code: got %v want %v",
+				status, http.StatusInternalServerError)
+		}
+		if body := rr.Body.String(); body != "received 4 bytes, expected 10 bytes" {
+			t.Errorf("handler returned wrong body: got %q", body)
+		}
+	})
+}
+
+func TestBodyReader(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		contentLength int64
+		processed     int
+		verification  cdiv1.DataVolumeVerification
+		err           string
+	}{
+		{name: "complete", contentLength: 4, processed: 4},
+		{name: "partly processed", contentLength: 4, processed: 2},
+		{name: "unknown length", contentLength: -1, processed: 2},
+		{name: "short", contentLength: 6, processed: 4, err: "received 4 bytes, expected 6 bytes"},
+		{name: "long", contentLength: 2, processed: 1, err: "received 4 bytes, expected 2 bytes"},
+		{name: "short without verification", contentLength: 6, processed: 4, verification: cdiv1.DataVolumeVerificationNone},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := newRequest(t)
+			req.ContentLength = test.contentLength
+			body := newBodyReader(req, test.verification)
+			buf := make([]byte, test.processed)
+			if _, err := io.ReadFull(body, buf); err != nil {
+				t.Fatalf("unexpected error processing the body: %v", err)
+			}
+			err := body.finish()
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
 func TestRealUploadWithClient(t *testing.T) {
 	type testData struct {
 		certName, expectedName string
@@ -446,3 +631,1092 @@ func TestRealUploadWithClient(t *testing.T) {
 		})
 	}
 }
+
+func TestRealUploadIPv6(t *testing.T) {
+	if listener, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("IPv6 loopback is not available")
+	} else {
+		listener.Close()
+	}
+	withProcessorSuccess(func() {
+		server, clientKeyPair, serverCACert := newTLSServer(t, "client", "client")
+		server.bindAddress = "::1"
+		client := newHTTPClient(t, clientKeyPair, serverCACert)
+
+		ch := make(chan struct{})
+		go func() {
+			server.Run()
+			close(ch)
+		}()
+
+		for i := 0; i < 10 && server.bindPort == 0; i++ {
+			time.Sleep(500 * time.Millisecond)
+		}
+		if server.bindPort == 0 {
+			t.Fatal("Couldn't start http server")
+		}
+
+		url := fmt.Sprintf("https://%s%s", net.JoinHostPort("::1", strconv.Itoa(server.bindPort)), common.UploadPathSync)
+		resp, err := client.Post(url, "application/x-www-form-urlencoded", strings.NewReader("nothing"))
+		if err != nil {
+			t.Fatalf("Request failed %+v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected status code %d wanted %d", resp.StatusCode, http.StatusOK)
+		}
+
+		if !server.done {
+			close(server.doneChan)
+		}
+
+		<-ch
+	})
+}
+
+// newChunkRequest returns a block device clone chunk of data at offset
+func newChunkRequest(t *testing.T, data string, offset int, last bool) *http.Request {
+	url := fmt.Sprintf("%s?offset=%d&last=%t", common.UploadPathChunked, offset, last)
+	req, err := http.NewRequest("POST", url, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(UploadContentTypeHeader, BlockdeviceCloneContentType)
+	req.Header.Set(checksum.ChunkHeader, checksum.ChunkSum([]byte(data)))
+	return req
+}
+
+// newCloneRequest returns the last chunk of a block device clone of data, with the checksums of sent
+func newCloneRequest(t *testing.T, data, sent string) *http.Request {
+	req := newChunkRequest(t, data, 0, true)
+	h := checksum.NewHasher()
+	h.Write([]byte(sent))
+	req.Header.Set(checksum.Header, checksum.Manifest{checksum.BlockDevice: h.File()}.String())
+	return req
+}
+
+// sendChunk sends req to server and fails the test unless it is answered with status
+func sendChunk(t *testing.T, server *uploadServerApp, req *http.Request, status int) {
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != status {
+		t.Fatalf("chunk at %s answered with status code %d want %d: %s", req.URL.RawQuery, rr.Code, status, rr.Body.String())
+	}
+}
+
+// readDestination returns what server wrote to its destination
+func readDestination(t *testing.T, server *uploadServerApp) string {
+	data, err := ioutil.ReadFile(server.destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// withCloneDestination runs f with an upload server that writes the data of a request to a temporary file
+func withCloneDestination(t *testing.T, f func(server *uploadServerApp, messages *[]*util.TerminationMessage)) {
+	dir, err := ioutil.TempDir("", "clone-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	destination := filepath.Join(dir, "disk.img")
+
+	var messages []*util.TerminationMessage
+	origWriteTerminationMessageFunc := writeTerminationMessageFunc
+	writeTerminationMessageFunc = func(message *util.TerminationMessage) error {
+		messages = append(messages, message)
+		return nil
+	}
+	defer func() {
+		writeTerminationMessageFunc = origWriteTerminationMessageFunc
+	}()
+
+	replaceProcessorFunc(func(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) error {
+		data, err := ioutil.ReadAll(stream)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dest, data, 0644)
+	}, func() {
+		server := NewUploadServer("127.0.0.1", 0, destination, "", "", "", "", "", "", "", "", "", "", 0, util.ServerTimeouts{}).(*uploadServerApp)
+		f(server, &messages)
+	})
+}
+
+func TestCloneVerified(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newCloneRequest(t, "data", "data"))
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		server.writeTerminationMessage()
+		if len(*messages) != 1 || !strings.HasPrefix((*messages)[0].Message, "Verified 4 bytes") {
+			t.Fatalf("unexpected termination messages %v", *messages)
+		}
+		expected, _ := checksum.ContentDigest(strings.NewReader("data"))
+		if digest := (*messages)[0].Digest; digest != expected {
+			t.Errorf("unexpected content digest %q want %q", digest, expected)
+		}
+		if bytes := (*messages)[0].Bytes; bytes != 4 {
+			t.Errorf("unexpected bytes %d want 4", bytes)
+		}
+	})
+}
+
+func TestNoContentDigestWithFastVerification(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		server.verification = cdiv1.DataVolumeVerificationFast
+		sendChunk(t, server, newChunkRequest(t, "abc", 0, true), http.StatusOK)
+
+		server.writeTerminationMessage()
+		if len(*messages) != 1 || (*messages)[0].Digest != "" || (*messages)[0].Bytes != 3 {
+			t.Errorf("unexpected termination messages %v", *messages)
+		}
+	})
+}
+
+func TestCloneVerificationFailed(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newCloneRequest(t, "data", "date"))
+
+		if status := rr.Code; status != http.StatusInternalServerError {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+		}
+		select {
+		case err := <-server.errChan:
+			if _, ok := err.(*CloneVerificationError); !ok {
+				t.Errorf("unexpected error %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("upload server did not exit")
+		}
+		if len(*messages) != 0 {
+			t.Errorf("unexpected termination messages %v", *messages)
+		}
+	})
+}
+
+func TestChunkedUpload(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newChunkRequest(t, "abc", 0, false), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "def", 3, false), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "", 6, true), http.StatusOK)
+
+		if data := readDestination(t, server); data != "abcdef" {
+			t.Errorf("unexpected data %q", data)
+		}
+		if !server.done {
+			t.Error("upload not done")
+		}
+		server.writeTerminationMessage()
+		expected, _ := checksum.ContentDigest(strings.NewReader("abcdef"))
+		if len(*messages) != 1 || (*messages)[0].Result != util.TerminationSucceeded || (*messages)[0].Digest != expected {
+			t.Errorf("unexpected termination messages %v", *messages)
+		}
+	})
+}
+
+func TestChunkCorrupted(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newChunkRequest(t, "abc", 0, false), http.StatusOK)
+		corrupted := newChunkRequest(t, "def", 3, false)
+		corrupted.Body = ioutil.NopCloser(strings.NewReader("deg"))
+		sendChunk(t, server, corrupted, http.StatusUnprocessableEntity)
+		sendChunk(t, server, newChunkRequest(t, "def", 3, true), http.StatusOK)
+
+		if data := readDestination(t, server); data != "abcdef" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestChunkTakenAlready(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newChunkRequest(t, "abc", 0, false), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "def", 3, false), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "def", 3, false), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "ghi", 9, false), http.StatusBadRequest)
+		sendChunk(t, server, newChunkRequest(t, "ghi", 6, true), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "ghi", 6, true), http.StatusConflict)
+
+		if data := readDestination(t, server); data != "abcdefghi" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestChunkedUploadStartedOver(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newChunkRequest(t, "abc", 0, false), http.StatusOK)
+		sendChunk(t, server, newChunkRequest(t, "def", 3, false), http.StatusOK)
+
+		// A restarted clone source checks that the server is ready before it starts over
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", common.UploadPathReady, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("ready answered with status code %d", rr.Code)
+		}
+
+		sendChunk(t, server, newChunkRequest(t, "xyz", 0, true), http.StatusOK)
+
+		if data := readDestination(t, server); data != "xyz" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestChunkWithoutUpload(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newChunkRequest(t, "def", 3, false), http.StatusBadRequest)
+		if server.uploading {
+			t.Error("upload started by a chunk that is not the first")
+		}
+	})
+}
+
+func TestChunkedUploadIdentity(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		req := newChunkRequest(t, "abc", 0, true)
+		req.Header.Set("Content-Encoding", "identity")
+		sendChunk(t, server, req, http.StatusOK)
+
+		if data := readDestination(t, server); data != "abc" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestChunkUnsupportedContentEncoding(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		req := newChunkRequest(t, "abc", 0, true)
+		req.Header.Set("Content-Encoding", "zstd")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("unexpected status code %d", rr.Code)
+		}
+		if accepted := rr.Header().Get("Accept-Encoding"); accepted != "gzip, identity" {
+			t.Errorf("unexpected Accept-Encoding %q", accepted)
+		}
+		if server.uploading {
+			t.Error("upload started by a chunk of an unsupported encoding")
+		}
+	})
+}
+
+func TestFilesystemCloneProcessor(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	content := []byte("cloned file")
+	if err := tw.WriteHeader(&tar.Header{Name: "disk.img", Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	var compressed bytes.Buffer
+	gzw := gzip.NewWriter(&compressed)
+	gzw.Write(archive.Bytes())
+	gzw.Close()
+
+	for name, stream := range map[string][]byte{"gzip": compressed.Bytes(), "identity": archive.Bytes()} {
+		dir, err := ioutil.TempDir("", "filesystem-clone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if err := filesystemCloneProcessor(ioutil.NopCloser(bytes.NewReader(stream)), dir); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, "disk.img"))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("%s: unexpected content %q", name, data)
+		}
+	}
+}
+
+func TestUploadSize(t *testing.T) {
+	var sizes []importer.UploadSize
+	replaceProcessorFunc(func(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) error {
+		sizes = append(sizes, size)
+		_, err := io.Copy(ioutil.Discard, stream)
+		return err
+	}, func() {
+		server := NewUploadServer("127.0.0.1", 0, "disk.img", "", "", "", "", "", "", "", "", "", "", 0, util.ServerTimeouts{}).(*uploadServerApp)
+		req := newRequest(t)
+		req.Header.Set(UploadSourceSizeHeader, "1024")
+		server.ServeHTTP(httptest.NewRecorder(), req)
+
+		server = NewUploadServer("127.0.0.1", 0, "disk.img", "", "", "", "", "", "", "", "", "", "", 0, util.ServerTimeouts{}).(*uploadServerApp)
+		req = newChunkRequest(t, "data", 0, true)
+		req.Header.Set(UploadSourceSizeHeader, "2048")
+		sendChunk(t, server, req, http.StatusOK)
+	})
+
+	expected := []importer.UploadSize{{Source: 1024, ContentLength: 4}, {Source: 2048}}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("unexpected upload sizes %v want %v", sizes, expected)
+	}
+}
+
+func TestImageTooLarge(t *testing.T) {
+	replaceProcessorFunc(func(stream io.ReadCloser, dest, imageSize, contentType string, verification cdiv1.DataVolumeVerification, blockWipe cdiv1.DataVolumeBlockWipe, size importer.UploadSize) error {
+		return errors.Wrap(&importer.ImageTooLargeError{Device: dest, Required: 2048, Available: 1024}, "Unable to obtain information about data source")
+	}, func() {
+		server := NewUploadServer("127.0.0.1", 0, "/dev/cdi-block-volume", "", "", "", "", "", "", "", "", "", "", 0, util.ServerTimeouts{}).(*uploadServerApp)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newRequest(t))
+
+		if status := rr.Code; status != http.StatusRequestEntityTooLarge {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusRequestEntityTooLarge)
+		}
+		if body := rr.Body.String(); !strings.Contains(body, "it is 1024 bytes short") {
+			t.Errorf("unexpected response %q", body)
+		}
+	})
+}
+
+// newSessionRequest returns a chunk of data at offset of the upload session id
+func newSessionRequest(t *testing.T, id, data string, offset int, complete bool) *http.Request {
+	req, err := http.NewRequest("POST", common.UploadPathSession, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(common.UploadSessionHeader, id)
+	req.Header.Set(common.UploadOffsetHeader, strconv.Itoa(offset))
+	req.Header.Set(common.UploadCompleteHeader, strconv.FormatBool(complete))
+	return req
+}
+
+// sendSessionRequest sends req to server, fails the test unless it is answered with status, and returns the offset
+// the response tells
+func sendSessionRequest(t *testing.T, server *uploadServerApp, req *http.Request, status int) string {
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != status {
+		t.Fatalf("%s at offset %s answered with status code %d want %d: %s", req.Method, req.Header.Get(common.UploadOffsetHeader), rr.Code, status, rr.Body.String())
+	}
+	return rr.Header().Get(common.UploadOffsetHeader)
+}
+
+func TestSessionUpload(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		if offset := sendSessionRequest(t, server, newSessionRequest(t, "s1", "abc", 0, false), http.StatusOK); offset != "3" {
+			t.Errorf("unexpected offset %s", offset)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newSessionRequest(t, "s1", "def", 3, true))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("last chunk answered with status code %d", rr.Code)
+		}
+		if complete := rr.Header().Get(common.UploadCompleteHeader); complete != "true" {
+			t.Errorf("session not complete")
+		}
+		if digest, expected := rr.Header().Get(common.UploadDigestHeader), fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("abcdef"))); digest != expected {
+			t.Errorf("unexpected digest %s want %s", digest, expected)
+		}
+
+		if data := readDestination(t, server); data != "abcdef" {
+			t.Errorf("unexpected data %q", data)
+		}
+		if !server.done {
+			t.Error("upload not done")
+		}
+		// The last chunk is sent again if its response was lost
+		sendSessionRequest(t, server, newSessionRequest(t, "s1", "def", 3, true), http.StatusOK)
+		sendSessionRequest(t, server, newSessionRequest(t, "s2", "abc", 0, false), http.StatusConflict)
+	})
+}
+
+func TestSessionChunksOutOfOrder(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		if offset := sendSessionRequest(t, server, newSessionRequest(t, "s1", "ghi", 6, true), http.StatusOK); offset != "0" {
+			t.Errorf("unexpected offset %s", offset)
+		}
+		if offset := sendSessionRequest(t, server, newSessionRequest(t, "s1", "def", 3, false), http.StatusOK); offset != "0" {
+			t.Errorf("unexpected offset %s", offset)
+		}
+		sendSessionRequest(t, server, newSessionRequest(t, "s1", "jkl", 9, false), http.StatusBadRequest)
+		if offset := sendSessionRequest(t, server, newSessionRequest(t, "s1", "abcd", 0, false), http.StatusOK); offset != "9" {
+			t.Errorf("unexpected offset %s", offset)
+		}
+
+		if data := readDestination(t, server); data != "abcdefghi" {
+			t.Errorf("unexpected data %q", data)
+		}
+		if !server.done {
+			t.Error("upload not done")
+		}
+	})
+}
+
+func TestSessionStatus(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		head := func(id string) string {
+			req := httptest.NewRequest("HEAD", common.UploadPathSession, nil)
+			req.Header.Set(common.UploadSessionHeader, id)
+			return sendSessionRequest(t, server, req, http.StatusOK)
+		}
+		if offset := head("s1"); offset != "0" {
+			t.Errorf("unexpected offset %s of a new session", offset)
+		}
+		sendSessionRequest(t, server, newSessionRequest(t, "s1", "abc", 0, false), http.StatusOK)
+		if offset := head("s1"); offset != "3" {
+			t.Errorf("unexpected offset %s", offset)
+		}
+		if offset := head("s2"); offset != "0" {
+			t.Errorf("unexpected offset %s of another session", offset)
+		}
+	})
+}
+
+func TestSessionReplaced(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendSessionRequest(t, server, newSessionRequest(t, "s1", "abc", 0, false), http.StatusOK)
+		sendSessionRequest(t, server, newSessionRequest(t, "s2", "xyz", 0, true), http.StatusOK)
+		sendSessionRequest(t, server, newSessionRequest(t, "s1", "def", 3, true), http.StatusConflict)
+
+		if data := readDestination(t, server); data != "xyz" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+// newGzipRequest returns an upload of data compressed with gzip
+func newGzipRequest(t *testing.T, data string) *http.Request {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", common.UploadPathSync, &compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	return req
+}
+
+func TestGzipUpload(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		server.contentEncodings = parseContentEncodings("gzip")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newGzipRequest(t, "uncompressed data"))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if data := readDestination(t, server); data != "uncompressed data" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestCorruptedGzipUpload(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		server.contentEncodings = parseContentEncodings("gzip")
+		req := newGzipRequest(t, "uncompressed data")
+		body, _ := ioutil.ReadAll(req.Body)
+		// Break the CRC of the gzip trailer
+		body[len(body)-8] ^= 0xff
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+		}
+		if server.done {
+			t.Error("corrupted upload is done")
+		}
+	})
+}
+
+func TestUnsupportedContentEncoding(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newGzipRequest(t, "uncompressed data"))
+
+		if rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnsupportedMediaType)
+		}
+		if accepted := rr.Header().Get("Accept-Encoding"); accepted != "identity" {
+			t.Errorf("unexpected Accept-Encoding %q", accepted)
+		}
+		if server.uploading {
+			t.Error("upload started with an unsupported content encoding")
+		}
+	})
+}
+
+func TestOptions(t *testing.T) {
+	server := NewUploadServer("127.0.0.1", 0, "disk.img", "", "", "", "", "", "", "", "gzip, zstd", "", "", 0, util.ServerTimeouts{}).(*uploadServerApp)
+	for _, path := range []string{common.UploadPathSync, common.UploadPathAsync} {
+		req, err := http.NewRequest("OPTIONS", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", path, rr.Code, http.StatusNoContent)
+		}
+		if accepted := rr.Header().Get("Accept-Encoding"); accepted != "gzip, identity" {
+			t.Errorf("%s: unexpected Accept-Encoding %q", path, accepted)
+		}
+	}
+}
+
+// newFormRequest returns a form upload of data, with a field before and after the file
+func newFormRequest(t *testing.T, path, data string) *http.Request {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	if err := writer.WriteField("name", "disk"); err != nil {
+		t.Fatal(err)
+	}
+	part, err := writer.CreateFormFile(UploadFormFileField, "disk.img")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteField("submit", "Upload"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", path, &form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestFormUpload(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newFormRequest(t, common.UploadFormPathSync, "form data"))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if data := readDestination(t, server); data != "form data" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestFormUploadAsync(t *testing.T) {
+	withAsyncProcessorSuccess(func() {
+		rr := httptest.NewRecorder()
+		server := newServer()
+		server.ServeHTTP(rr, newFormRequest(t, common.UploadFormPathAsync, "form data"))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestInvalidForm(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		for _, req := range []*http.Request{
+			newRequest(t),
+			func() *http.Request {
+				req, err := http.NewRequest("POST", common.UploadFormPathSync, strings.NewReader("--b--\r\n"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+				return req
+			}(),
+		} {
+			req.URL.Path = common.UploadFormPathSync
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+			}
+			if server.uploading || server.done {
+				t.Error("invalid form uploaded")
+			}
+		}
+	})
+}
+
+// getUploadStatus returns the status server answers for operation with, nil if it does not know of it
+func getUploadStatus(t *testing.T, server *uploadServerApp, operation string) *util.UploadStatus {
+	req, err := http.NewRequest("GET", common.UploadPathStatus+"?operation="+operation, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code == http.StatusNotFound {
+		return nil
+	}
+	status := &util.UploadStatus{}
+	if err := json.NewDecoder(rr.Body).Decode(status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestAsyncUploadStatus(t *testing.T) {
+	withAsyncProcessorSuccess(func() {
+		server := newServer()
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newAsyncRequest(t))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		operation := rr.Header().Get(common.UploadOperationHeader)
+		if operation == "" {
+			t.Fatal("no operation ID in the response")
+		}
+		<-server.doneChan
+		if status := getUploadStatus(t, server, operation); status == nil || status.Phase != util.UploadPhaseConverting {
+			t.Errorf("unexpected status %+v", status)
+		}
+		if status := getUploadStatus(t, server, "unknown"); status != nil {
+			t.Errorf("unexpected status of unknown upload %+v", status)
+		}
+	})
+}
+
+// withCallbackServer has callbacks sent to, and trusted by, the loopback server
+func withCallbackServer(server *httptest.Server, f func()) {
+	origIPAllowed, origRootCAs := callbackIPAllowed, callbackRootCAs
+	callbackIPAllowed = func(net.IP) bool { return true }
+	callbackRootCAs = x509.NewCertPool()
+	callbackRootCAs.AddCert(server.Certificate())
+	defer func() {
+		callbackIPAllowed, callbackRootCAs = origIPAllowed, origRootCAs
+	}()
+	f()
+}
+
+func TestAsyncUploadCallback(t *testing.T) {
+	callbacks := make(chan util.UploadStatus, 1)
+	callback := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var status util.UploadStatus
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			t.Error(err)
+		}
+		callbacks <- status
+	}))
+	defer callback.Close()
+
+	withCallbackServer(callback, func() {
+		withAsyncProcessorFailure(func() {
+			server := newServer()
+			req := newAsyncRequest(t)
+			req.Header.Set(common.UploadCallbackHeader, callback.URL)
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusInternalServerError {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+			}
+			operation := rr.Header().Get(common.UploadOperationHeader)
+			status := <-callbacks
+			if status.Operation != operation || status.Phase != util.UploadPhaseError || status.Message != "Error using datastream" {
+				t.Errorf("unexpected callback %+v", status)
+			}
+			if status := getUploadStatus(t, server, operation); status == nil || status.Phase != util.UploadPhaseError {
+				t.Errorf("unexpected status %+v", status)
+			}
+		})
+	})
+}
+
+func TestUploadCallbackRedirectNotFollowed(t *testing.T) {
+	redirected := make(chan struct{}, 1)
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected <- struct{}{}
+	}))
+	defer target.Close()
+	callback := httptest.NewTLSServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer callback.Close()
+
+	withCallbackServer(callback, func() {
+		sendCallback(callback.URL, util.UploadStatus{Phase: util.UploadPhaseDone})
+	})
+	select {
+	case <-redirected:
+		t.Error("the callback followed a redirect")
+	default:
+	}
+}
+
+func TestUploadCallbackNotSentToLoopback(t *testing.T) {
+	received := make(chan struct{}, 1)
+	callback := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+	}))
+	defer callback.Close()
+
+	// The host of a callback may resolve to another address when it is sent than when it was checked
+	origRootCAs := callbackRootCAs
+	callbackRootCAs = x509.NewCertPool()
+	callbackRootCAs.AddCert(callback.Certificate())
+	defer func() {
+		callbackRootCAs = origRootCAs
+	}()
+	sendCallback(callback.URL, util.UploadStatus{Phase: util.UploadPhaseDone})
+	select {
+	case <-received:
+		t.Error("the callback was sent to a loopback address")
+	default:
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for address, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"2001:4860::8888": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"10.96.0.1":       false,
+		"172.20.0.1":      false,
+		"192.168.1.1":     false,
+		"100.64.0.1":      false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if isPublicIP(net.ParseIP(address)) != public {
+			t.Errorf("isPublicIP(%s) should be %t", address, public)
+		}
+	}
+}
+
+func TestInvalidUploadCallback(t *testing.T) {
+	for _, callback := range []string{
+		"file:///etc/passwd",
+		"http://example.com/callback",
+		"https://127.0.0.1/callback",
+		"https://localhost:8443/callback",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.96.0.1/api",
+		"https://[::1]/callback",
+	} {
+		server := newServer()
+		req := newAsyncRequest(t)
+		req.Header.Set(common.UploadCallbackHeader, callback)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v", callback, rr.Code, http.StatusBadRequest)
+		}
+		if server.uploading {
+			t.Errorf("upload started with the invalid callback %s", callback)
+		}
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	limiter := newConcurrencyLimiter(1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := limiter.limit(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	done := make(chan struct{})
+	first := newRequest(t)
+	go func() {
+		defer close(done)
+		handler(httptest.NewRecorder(), first)
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	handler(rr, newRequest(t))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "5" {
+		t.Errorf("unexpected Retry-After %q", retryAfter)
+	}
+
+	// Requests without data are not limited
+	req, err := http.NewRequest("HEAD", common.UploadPathAsync, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler = limiter.limit(func(w http.ResponseWriter, r *http.Request) {})
+	handler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	close(release)
+	<-done
+	rr = httptest.NewRecorder()
+	handler(rr, newRequest(t))
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code after the request finished: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// newDeltaRequest returns a delta clone chunk of data at offset
+func newDeltaRequest(t *testing.T, data string, offset int, last bool) *http.Request {
+	url := fmt.Sprintf("%s?offset=%d&last=%t", common.UploadPathDelta, offset, last)
+	req, err := http.NewRequest("POST", url, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(checksum.ChunkHeader, checksum.ChunkSum([]byte(data)))
+	return req
+}
+
+// withDeltaDestination runs f with an upload server whose destination holds base and is taken for a block device
+func withDeltaDestination(t *testing.T, base string, f func(server *uploadServerApp, messages *[]*util.TerminationMessage)) {
+	origIsDeltaDestination := isDeltaDestination
+	isDeltaDestination = func(string) bool {
+		return true
+	}
+	defer func() {
+		isDeltaDestination = origIsDeltaDestination
+	}()
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		if err := ioutil.WriteFile(server.destination, []byte(base), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f(server, messages)
+	})
+}
+
+func TestBlockHashes(t *testing.T) {
+	base := strings.Repeat("a", minDeltaBlockSize) + "b"
+	withDeltaDestination(t, base, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", fmt.Sprintf("%s?blockSize=%d", common.UploadPathBlockHashes, minDeltaBlockSize), nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("block hashes answered with status code %d", rr.Code)
+		}
+		expected, _ := checksum.BlockSums(strings.NewReader(base), minDeltaBlockSize)
+		if !bytes.Equal(rr.Body.Bytes(), expected) {
+			t.Errorf("unexpected block sums of %d bytes", rr.Body.Len())
+		}
+		if size := rr.Header().Get(DestinationSizeHeader); size != strconv.Itoa(len(base)) {
+			t.Errorf("unexpected destination size %q", size)
+		}
+	})
+}
+
+func TestBlockHashesInvalidBlockSize(t *testing.T) {
+	withDeltaDestination(t, "base", func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		for _, blockSize := range []string{"", "512", "block"} {
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, httptest.NewRequest("GET", common.UploadPathBlockHashes+"?blockSize="+blockSize, nil))
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("block size %q answered with status code %d", blockSize, rr.Code)
+			}
+		}
+	})
+}
+
+func TestBlockHashesNotBlockDevice(t *testing.T) {
+	withCloneDestination(t, func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", fmt.Sprintf("%s?blockSize=%d", common.UploadPathBlockHashes, minDeltaBlockSize), nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("unexpected status code %d", rr.Code)
+		}
+	})
+}
+
+func TestDeltaUpload(t *testing.T) {
+	withDeltaDestination(t, "abcdefghi", func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newDeltaRequest(t, "xyz", 3, false), http.StatusOK)
+		// A chunk sent again is written again
+		sendChunk(t, server, newDeltaRequest(t, "xyz", 3, false), http.StatusOK)
+		last := newDeltaRequest(t, "", 9, true)
+		h := checksum.NewHasher()
+		h.Write([]byte("abcxyzghi"))
+		last.Header.Set(checksum.Header, checksum.Manifest{checksum.BlockDevice: h.File()}.String())
+		sendChunk(t, server, last, http.StatusOK)
+
+		if data := readDestination(t, server); data != "abcxyzghi" {
+			t.Errorf("unexpected data %q", data)
+		}
+		if !server.done || server.delta != nil {
+			t.Error("delta upload not done")
+		}
+		sendChunk(t, server, newDeltaRequest(t, "xyz", 3, false), http.StatusConflict)
+		server.writeTerminationMessage()
+		if len(*messages) != 1 || !strings.HasPrefix((*messages)[0].Message, "Verified 9 bytes") {
+			t.Errorf("unexpected termination messages %v", *messages)
+		}
+	})
+}
+
+func TestDeltaChunkCorrupted(t *testing.T) {
+	withDeltaDestination(t, "abcdef", func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		corrupted := newDeltaRequest(t, "xyz", 3, false)
+		corrupted.Body = ioutil.NopCloser(strings.NewReader("xyw"))
+		sendChunk(t, server, corrupted, http.StatusUnprocessableEntity)
+		if server.uploading {
+			t.Error("upload started by a corrupted chunk")
+		}
+		sendChunk(t, server, newDeltaRequest(t, "xyz", 3, true), http.StatusOK)
+
+		if data := readDestination(t, server); data != "abcxyz" {
+			t.Errorf("unexpected data %q", data)
+		}
+	})
+}
+
+func TestDeltaUploadVerificationFailed(t *testing.T) {
+	withDeltaDestination(t, "abcdef", func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		last := newDeltaRequest(t, "xyz", 3, true)
+		h := checksum.NewHasher()
+		h.Write([]byte("abcxyw"))
+		last.Header.Set(checksum.Header, checksum.Manifest{checksum.BlockDevice: h.File()}.String())
+		sendChunk(t, server, last, http.StatusInternalServerError)
+
+		select {
+		case err := <-server.errChan:
+			if _, ok := err.(*CloneVerificationError); !ok {
+				t.Errorf("unexpected error %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("upload server did not exit")
+		}
+	})
+}
+
+func TestDeltaChunkDuringUpload(t *testing.T) {
+	withDeltaDestination(t, "abcdef", func(server *uploadServerApp, messages *[]*util.TerminationMessage) {
+		sendChunk(t, server, newChunkRequest(t, "abc", 0, false), http.StatusOK)
+		sendChunk(t, server, newDeltaRequest(t, "xyz", 3, false), http.StatusServiceUnavailable)
+	})
+}
+
+// newStateServer returns a server taking a state blob to a file in a new directory, verified against digest
+func newStateServer(t *testing.T, digest string) (*uploadServerApp, string) {
+	dir, err := ioutil.TempDir("", "state-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(dir, "memory.dump")
+	server := NewUploadServer("127.0.0.1", 0, destination, "", "", "", "", "", "", "", "", string(cdiv1.DataVolumeKubeVirtState), digest, 0, util.ServerTimeouts{}).(*uploadServerApp)
+	return server, destination
+}
+
+// stateDigest returns the sha256 digest of data in the form the checksum of a DataVolume has
+func stateDigest(data string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(data)))
+}
+
+func TestStateBlobProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state-blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "memory.dump")
+
+	if err := stateBlobProcessor(ioutil.NopCloser(strings.NewReader("vm state")), dest, stateDigest("vm state")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "vm state" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if info, err := os.Stat(dest); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("unexpected mode of the state blob: %v %v", info.Mode(), err)
+	}
+
+	err = stateBlobProcessor(ioutil.NopCloser(strings.NewReader("other state")), dest, stateDigest("vm state"))
+	if _, ok := err.(*StateChecksumError); !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if data, _ := ioutil.ReadFile(dest); string(data) != "vm state" {
+		t.Errorf("blob that does not match replaced the state blob: %q", data)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("temporary files left behind: %v", files)
+	}
+}
+
+func TestStateUpload(t *testing.T) {
+	withProcessorFailure(func() {
+		server, destination := newStateServer(t, stateDigest("data"))
+		defer os.RemoveAll(filepath.Dir(destination))
+		req := newRequest(t)
+		// The content type of the DataVolume wins over the one of the client
+		req.Header.Set(UploadContentTypeHeader, string(cdiv1.DataVolumeKubeVirt))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if data, err := ioutil.ReadFile(destination); err != nil || string(data) != "data" {
+			t.Errorf("unexpected state blob %q: %v", data, err)
+		}
+	})
+}
+
+func TestStateUploadChecksumMismatch(t *testing.T) {
+	server, destination := newStateServer(t, stateDigest("other data"))
+	defer os.RemoveAll(filepath.Dir(destination))
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, newRequest(t))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+	if server.uploading || server.done {
+		t.Error("upload that does not match the checksum was taken")
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("state blob written: %v", err)
+	}
+}
+
+func TestStateUploadAsync(t *testing.T) {
+	withAsyncProcessorFailure(func() {
+		server, destination := newStateServer(t, "")
+		defer os.RemoveAll(filepath.Dir(destination))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, newAsyncRequest(t))
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		<-server.doneChan
+		if !server.done {
+			t.Error("async state upload not done")
+		}
+		if data, err := ioutil.ReadFile(destination); err != nil || string(data) != "data" {
+			t.Errorf("unexpected state blob %q: %v", data, err)
+		}
+	})
+}
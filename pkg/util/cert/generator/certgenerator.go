@@ -1,7 +1,9 @@
 package generator
 
 import (
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/openshift/library-go/pkg/crypto"
@@ -14,7 +16,7 @@ import (
 // CertGenerator is an interface for creating certs
 type CertGenerator interface {
 	MakeClientCert(name string, groups []string, duration time.Duration) ([]byte, []byte, error)
-	MakeServerCert(namespace, service string, duration time.Duration) ([]byte, []byte, error)
+	MakeServerCert(namespace, service string, duration time.Duration, uris ...*url.URL) ([]byte, []byte, error)
 }
 
 // FetchCertGenerator fetches and generates certs
@@ -38,15 +40,19 @@ func (cg *FetchCertGenerator) MakeClientCert(name string, groups []string, durat
 	return certKeyPair.GetPEMBytes()
 }
 
-// MakeServerCert generates a server cert
-func (cg *FetchCertGenerator) MakeServerCert(namespace, service string, duration time.Duration) ([]byte, []byte, error) {
+// MakeServerCert generates a server cert for the hostnames of service, and uris as URI SANs, which identify the
+// server beyond its hostnames
+func (cg *FetchCertGenerator) MakeServerCert(namespace, service string, duration time.Duration, uris ...*url.URL) ([]byte, []byte, error) {
 	ca, err := cg.getCA()
 	if err != nil {
 		return nil, nil, err
 	}
 
 	hostnames := sets.NewString(serviceToHostnames(namespace, service)...)
-	certKeyPair, err := ca.MakeServerCertForDuration(hostnames, duration)
+	certKeyPair, err := ca.MakeServerCertForDuration(hostnames, duration, func(cert *x509.Certificate) error {
+		cert.URIs = uris
+		return nil
+	})
 	if err != nil {
 		return nil, nil, err
 	}
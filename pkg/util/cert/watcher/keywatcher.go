@@ -0,0 +1,193 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/fsnotify.v1"
+	"k8s.io/klog"
+
+	"kubevirt.io/containerized-data-importer/pkg/keys"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+)
+
+// RetiredKeyLifetime is how long a key is still accepted after it was removed from the key file. It outlasts the
+// tokens signed with the key before it was rotated, and the leeway of their validation.
+const RetiredKeyLifetime = keys.MaxTokenLifetime + keys.TokenLeewayMargin
+
+// KeyWatcher watches a file of PEM encoded RSA or ECDSA public keys for changes, like the public key of the apiserver
+// mounted from its secret. The file may hold several keys, so that the tokens signed with either key are accepted
+// while the signing key is rotated.
+type KeyWatcher struct {
+	sync.Mutex
+
+	currentKeys []crypto.PublicKey
+	// retiredKeys are the keys removed from the file, by the time they were removed
+	retiredKeys map[crypto.PublicKey]time.Time
+	watcher     *fsnotify.Watcher
+
+	keyPath string
+
+	// nowFunc is the clock of the watcher, may be overridden in tests
+	nowFunc func() time.Time
+}
+
+// NewKeyWatcher returns a new KeyWatcher watching the given key file.
+func NewKeyWatcher(keyPath string) (*KeyWatcher, error) {
+	var err error
+
+	kw := &KeyWatcher{
+		keyPath:     keyPath,
+		retiredKeys: make(map[crypto.PublicKey]time.Time),
+		nowFunc:     time.Now,
+	}
+
+	// Initial read of the keys.
+	if err := kw.ReadKeys(); err != nil {
+		return nil, err
+	}
+
+	kw.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return kw, nil
+}
+
+// PublicKeys returns the keys of the file, followed by the keys removed from it less than RetiredKeyLifetime ago.
+func (kw *KeyWatcher) PublicKeys() []crypto.PublicKey {
+	kw.Lock()
+	defer kw.Unlock()
+
+	keys := append([]crypto.PublicKey{}, kw.currentKeys...)
+	for key, retired := range kw.retiredKeys {
+		if kw.nowFunc().Sub(retired) < RetiredKeyLifetime {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Start starts the watch on the key file.
+func (kw *KeyWatcher) Start(stopCh <-chan struct{}) error {
+	if err := kw.watcher.Add(kw.keyPath); err != nil {
+		return err
+	}
+
+	go kw.Watch()
+
+	klog.Info("Starting key watcher")
+
+	// Block until the stop channel is closed.
+	<-stopCh
+
+	return kw.watcher.Close()
+}
+
+// Watch reads events from the watcher's channel and reacts to changes.
+func (kw *KeyWatcher) Watch() {
+	for {
+		select {
+		case event, ok := <-kw.watcher.Events:
+			// Channel is closed.
+			if !ok {
+				return
+			}
+
+			kw.handleEvent(event)
+
+		case err, ok := <-kw.watcher.Errors:
+			// Channel is closed.
+			if !ok {
+				return
+			}
+
+			klog.Error(err, "key watch error")
+		}
+	}
+}
+
+// ReadKeys reads the key file from disk, parses it, and updates the current keys on the watcher. The keys that are
+// no longer in the file are retired, the ones back in the file are current again.
+func (kw *KeyWatcher) ReadKeys() error {
+	keyBytes, err := ioutil.ReadFile(kw.keyPath)
+	if err != nil {
+		return err
+	}
+	parsedKeys, err := cert.ParsePublicKeysPEM(keyBytes)
+	if err != nil {
+		return err
+	}
+	var keys []crypto.PublicKey
+	for _, parsedKey := range parsedKeys {
+		switch parsedKey.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys = append(keys, parsedKey)
+		}
+	}
+	if len(keys) == 0 {
+		return errors.Errorf("%s does not contain RSA or ECDSA keys", kw.keyPath)
+	}
+
+	kw.Lock()
+	defer kw.Unlock()
+
+	now := kw.nowFunc()
+	for _, current := range kw.currentKeys {
+		if indexOfKey(keys, current) < 0 {
+			kw.retiredKeys[current] = now
+		}
+	}
+	for retired, since := range kw.retiredKeys {
+		if indexOfKey(keys, retired) >= 0 || now.Sub(since) >= RetiredKeyLifetime {
+			delete(kw.retiredKeys, retired)
+		}
+	}
+	kw.currentKeys = keys
+
+	klog.Infof("Updated current keys, %d current and %d retired", len(kw.currentKeys), len(kw.retiredKeys))
+
+	return nil
+}
+
+func (kw *KeyWatcher) handleEvent(event fsnotify.Event) {
+	// Only care about events which may modify the contents of the file.
+	if !(isWrite(event) || isRemove(event) || isCreate(event)) {
+		return
+	}
+
+	klog.V(1).Info("key event", "event", event)
+
+	// If the file was removed, re-add the watch.
+	if isRemove(event) {
+		if err := kw.watcher.Add(event.Name); err != nil {
+			klog.Error(err, "error re-watching file")
+		}
+	}
+
+	if err := kw.ReadKeys(); err != nil {
+		klog.Error(err, "error re-reading keys")
+	}
+}
+
+// indexOfKey returns the index of the key equal to key in keys, -1 if there is none
+func indexOfKey(keys []crypto.PublicKey, key crypto.PublicKey) int {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return -1
+	}
+	for i, k := range keys {
+		if kDer, err := x509.MarshalPKIXPublicKey(k); err == nil && bytes.Equal(kDer, der) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+)
+
+var _ = Describe("Key watcher", func() {
+	var (
+		dir, keyPath string
+		key1, key2   *rsa.PublicKey
+	)
+
+	generateKey := func() *rsa.PublicKey {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		return &key.PublicKey
+	}
+
+	writeKeys := func(keys ...crypto.PublicKey) {
+		var keyBytes []byte
+		for _, key := range keys {
+			encoded, err := cert.EncodePublicKeyPEM(key)
+			Expect(err).ToNot(HaveOccurred())
+			keyBytes = append(keyBytes, encoded...)
+		}
+		Expect(ioutil.WriteFile(keyPath, keyBytes, 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "keywatcher")
+		Expect(err).ToNot(HaveOccurred())
+		keyPath = filepath.Join(dir, "id_rsa.pub")
+		key1 = generateKey()
+		key2 = generateKey()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should fail without a key file", func() {
+		_, err := NewKeyWatcher(keyPath)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should fail without keys in the key file", func() {
+		Expect(ioutil.WriteFile(keyPath, []byte("no keys"), 0600)).To(Succeed())
+		_, err := NewKeyWatcher(keyPath)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should read all the keys of the key file", func() {
+		writeKeys(key1, key2)
+		kw, err := NewKeyWatcher(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kw.PublicKeys()).To(Equal([]crypto.PublicKey{key1, key2}))
+	})
+
+	It("Should read ECDSA keys", func() {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		writeKeys(&ecKey.PublicKey, key1)
+		kw, err := NewKeyWatcher(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kw.PublicKeys()).To(Equal([]crypto.PublicKey{&ecKey.PublicKey, key1}))
+	})
+
+	It("Should accept a replaced key until it expires", func() {
+		writeKeys(key1)
+		kw, err := NewKeyWatcher(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+		now := time.Now()
+		kw.nowFunc = func() time.Time {
+			return now
+		}
+
+		writeKeys(key2)
+		Expect(kw.ReadKeys()).To(Succeed())
+		Expect(kw.PublicKeys()).To(Equal([]crypto.PublicKey{key2, key1}))
+
+		now = now.Add(RetiredKeyLifetime)
+		Expect(kw.PublicKeys()).To(Equal([]crypto.PublicKey{key2}))
+	})
+
+	It("Should make a replaced key current when it is back", func() {
+		writeKeys(key1)
+		kw, err := NewKeyWatcher(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeKeys(key2)
+		Expect(kw.ReadKeys()).To(Succeed())
+		writeKeys(key1)
+		Expect(kw.ReadKeys()).To(Succeed())
+		Expect(kw.PublicKeys()).To(Equal([]crypto.PublicKey{key1, key2}))
+		Expect(kw.retiredKeys).To(HaveLen(1))
+	})
+
+	It("Should keep the keys when the key file is invalid", func() {
+		writeKeys(key1)
+		kw, err := NewKeyWatcher(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(ioutil.WriteFile(keyPath, []byte("no keys"), 0600)).To(Succeed())
+		Expect(kw.ReadKeys()).ToNot(Succeed())
+		Expect(kw.PublicKeys()).To(Equal([]crypto.PublicKey{key1}))
+	})
+
+	It("Should reload the keys when the key file changes", func() {
+		writeKeys(key1)
+		kw, err := NewKeyWatcher(keyPath)
+		Expect(err).ToNot(HaveOccurred())
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go kw.Start(stopCh)
+
+		Eventually(func() []crypto.PublicKey {
+			writeKeys(key2)
+			return kw.PublicKeys()
+		}, 10*time.Second, 100*time.Millisecond).Should(Equal([]crypto.PublicKey{key2, key1}))
+	})
+})
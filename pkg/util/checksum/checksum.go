@@ -0,0 +1,330 @@
+package checksum
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Header is the HTTP header of the last chunk a clone source sends the checksums of the cloned data in
+const Header = "X-Cdi-Clone-Checksums"
+
+// ChunkHeader is the HTTP header with the CRC-32C of the data of a chunk
+const ChunkHeader = "X-Cdi-Chunk-Crc32c"
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// BlockDevice is the path of the content of a block device in a manifest
+const BlockDevice = ""
+
+// File is the size and SHA-256 of the content of a cloned file
+type File struct {
+	Size int64
+	Sum  []byte
+}
+
+// Manifest maps the paths of the cloned files, relative to the root of the volume, to their checksums
+type Manifest map[string]File
+
+// MismatchError indicates that the data written to a volume does not match its manifest
+type MismatchError struct {
+	Path   string
+	Reason string
+}
+
+func (e *MismatchError) Error() string {
+	if e.Path == BlockDevice {
+		return fmt.Sprintf("Block device content does not match the clone source: %s", e.Reason)
+	}
+	return fmt.Sprintf("File %s does not match the clone source: %s", e.Path, e.Reason)
+}
+
+// ParseDigest returns a hash of the algorithm of digest, in the form sha256:hex or sha512:hex, and the sum of digest
+func ParseDigest(digest string) (hash.Hash, []byte, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.Errorf("invalid digest %q, expected sha256:<hex> or sha512:<hex>", digest)
+	}
+	var h hash.Hash
+	switch parts[0] {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, nil, errors.Errorf("unsupported digest algorithm %q, expected sha256 or sha512", parts[0])
+	}
+	sum, err := hex.DecodeString(parts[1])
+	if err != nil || len(sum) != h.Size() {
+		return nil, nil, errors.Errorf("invalid %s digest %q", parts[0], parts[1])
+	}
+	return h, sum, nil
+}
+
+// ChunkSum returns the CRC-32C of the data of a chunk as a ChunkHeader value
+func ChunkSum(data []byte) string {
+	return fmt.Sprintf("%08x", crc32.Checksum(data, crc32c))
+}
+
+// BlockSumSize is the size of the checksum of a block of a delta clone, BlockSums returns them one after the other
+const BlockSumSize = sha256.Size
+
+// BlockSums returns the SHA-256 of each block of blockSize of the data r reads, the last block may be shorter. A
+// delta clone compares the block sums of its source and destination, and sends only the blocks that differ.
+func BlockSums(r io.Reader, blockSize int) ([]byte, error) {
+	var sums []byte
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			sums = append(sums, sum[:]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sums, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// String encodes the manifest as a header value, with entries like path=size:sha256 separated by commas
+func (m Manifest) String() string {
+	var entries []string
+	for p, f := range m {
+		entries = append(entries, fmt.Sprintf("%s=%d:%s", url.PathEscape(p), f.Size, hex.EncodeToString(f.Sum)))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// Size returns the total size of the files in the manifest
+func (m Manifest) Size() int64 {
+	var size int64
+	for _, f := range m {
+		size += f.Size
+	}
+	return size
+}
+
+// Parse decodes a manifest encoded by String
+func Parse(value string) (Manifest, error) {
+	m := make(Manifest)
+	if value == "" {
+		return m, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		eq := strings.LastIndex(entry, "=")
+		colon := strings.LastIndex(entry, ":")
+		if eq < 0 || colon < eq {
+			return nil, errors.Errorf("invalid checksum entry %q", entry)
+		}
+		p, err := url.PathUnescape(entry[:eq])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid checksum entry %q", entry)
+		}
+		size, err := strconv.ParseInt(entry[eq+1:colon], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid checksum entry %q", entry)
+		}
+		sum, err := hex.DecodeString(entry[colon+1:])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, errors.Errorf("invalid checksum entry %q", entry)
+		}
+		m[p] = File{Size: size, Sum: sum}
+	}
+	return m, nil
+}
+
+// Hasher computes the File of the data written to it
+type Hasher struct {
+	hash hash.Hash
+	size int64
+}
+
+// NewHasher returns a new Hasher
+func NewHasher() *Hasher {
+	return &Hasher{hash: sha256.New()}
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+	return h.hash.Write(p)
+}
+
+// File returns the size and checksum of the data written so far
+func (h *Hasher) File() File {
+	return File{Size: h.size, Sum: h.hash.Sum(nil)}
+}
+
+// TarManifest returns the checksums of the regular files in the tar stream r, it reads r to the end
+func TarManifest(r io.Reader) (Manifest, error) {
+	m := make(Manifest)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading tar stream")
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA && header.Typeflag != tar.TypeGNUSparse {
+			continue
+		}
+		h := NewHasher()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, errors.Wrapf(err, "error reading %s from tar stream", header.Name)
+		}
+		m[path.Clean(header.Name)] = h.File()
+	}
+	// Drain the padding after the end of the archive
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, errors.Wrap(err, "error reading tar stream")
+	}
+	return m, nil
+}
+
+// Verify reads back the files of the manifest and returns a MismatchError for the first one that does not match. The
+// files are relative to the directory root, and the content of a block device is compared to the start of the device
+// at root.
+func Verify(m Manifest, root string) error {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := verifyFile(p, m[p], root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyFile(p string, expected File, root string) error {
+	name := root
+	if p != BlockDevice {
+		name = filepath.Join(root, filepath.FromSlash(p))
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &MismatchError{Path: p, Reason: "missing"}
+		}
+		return errors.Wrapf(err, "error opening %s", name)
+	}
+	defer f.Close()
+	h := NewHasher()
+	if _, err := io.Copy(h, io.LimitReader(f, expected.Size)); err != nil {
+		return errors.Wrapf(err, "error reading %s", name)
+	}
+	actual := h.File()
+	if actual.Size != expected.Size {
+		return &MismatchError{Path: p, Reason: fmt.Sprintf("%d of %d bytes written", actual.Size, expected.Size)}
+	}
+	if p != BlockDevice {
+		// A file must not be longer either, a block device may be larger than its source
+		if info, err := f.Stat(); err == nil && info.Size() != expected.Size {
+			return &MismatchError{Path: p, Reason: fmt.Sprintf("%d bytes written, %d expected", info.Size(), expected.Size)}
+		}
+	}
+	if !bytes.Equal(actual.Sum, expected.Sum) {
+		return &MismatchError{Path: p, Reason: "checksum mismatch"}
+	}
+	return nil
+}
+
+// contentHasher hashes the data written to it without its trailing zeros
+type contentHasher struct {
+	hash  hash.Hash
+	zeros int64 // zeros held back until non-zero data follows them
+}
+
+var zeroBlock = make([]byte, 64*1024)
+
+func (h *contentHasher) Write(p []byte) (int, error) {
+	last := len(p) - 1
+	for last >= 0 && p[last] == 0 {
+		last--
+	}
+	if last < 0 {
+		h.zeros += int64(len(p))
+		return len(p), nil
+	}
+	for h.zeros > 0 {
+		n := int64(len(zeroBlock))
+		if h.zeros < n {
+			n = h.zeros
+		}
+		h.hash.Write(zeroBlock[:n])
+		h.zeros -= n
+	}
+	h.hash.Write(p[:last+1])
+	h.zeros = int64(len(p) - last - 1)
+	return len(p), nil
+}
+
+// ContentDigest returns the digest, in the form sha256:hex, of the raw image read from r. Trailing zeros are not part
+// of the digest, so that it does not change when the image is resized or written to a larger volume.
+func ContentDigest(r io.Reader) (string, error) {
+	h := &contentHasher{hash: sha256.New()}
+	if _, err := io.CopyBuffer(h, r, make([]byte, 1024*1024)); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.hash.Sum(nil)), nil
+}
+
+// ContentDigestFile returns the ContentDigest of the raw image in the file or block device at path, or an empty
+// digest if there is neither at path, like after the import of an archive.
+func ContentDigestFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && !info.Mode().IsRegular() && info.Mode()&os.ModeDevice == 0) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading %s", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "error opening %s", path)
+	}
+	defer f.Close()
+	digest, err := ContentDigest(f)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading %s", path)
+	}
+	return digest, nil
+}
+
+// ContentDigestSection returns the ContentDigest of the size bytes at offset of the block device at path, the region
+// an image was written to.
+func ContentDigestSection(path string, offset, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "error opening %s", path)
+	}
+	defer f.Close()
+	digest, err := ContentDigest(io.NewSectionReader(f, offset, size))
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading %s", path)
+	}
+	return digest, nil
+}
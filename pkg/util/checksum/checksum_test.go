@@ -0,0 +1,215 @@
+package checksum
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// checksumOf returns the File of data
+func checksumOf(data string) File {
+	h := NewHasher()
+	h.Write([]byte(data))
+	return h.File()
+}
+
+var _ = Describe("Manifest", func() {
+	It("Should survive encoding as a header value", func() {
+		m := Manifest{
+			"disk.img":        checksumOf("disk"),
+			"dir/a,b=c:d.img": checksumOf("odd name"),
+			BlockDevice:       checksumOf("device"),
+		}
+		parsed, err := Parse(m.String())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed).To(Equal(m))
+	})
+
+	It("Should reject malformed header values", func() {
+		for _, value := range []string{"disk.img", "disk.img=1", "disk.img=x:00", "disk.img=1:00"} {
+			_, err := Parse(value)
+			Expect(err).To(HaveOccurred(), value)
+		}
+	})
+
+	It("Should checksum the regular files of a tar stream", func() {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		Expect(tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "./disk.img", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})).To(Succeed())
+		_, err := tw.Write([]byte("disk"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tw.Close()).To(Succeed())
+		buf.Write(make([]byte, 1024))
+
+		m, err := TarManifest(&buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m).To(Equal(Manifest{"disk.img": checksumOf("disk")}))
+		Expect(buf.Len()).To(BeZero())
+	})
+})
+
+var _ = Describe("Verify", func() {
+	var root string
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "checksum")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	writeFile := func(name, data string) {
+		Expect(ioutil.WriteFile(filepath.Join(root, name), []byte(data), 0644)).To(Succeed())
+	}
+
+	It("Should accept files that match", func() {
+		writeFile("disk.img", "disk")
+		Expect(Verify(Manifest{"disk.img": checksumOf("disk")}, root)).To(Succeed())
+	})
+
+	It("Should accept a block device larger than its source", func() {
+		writeFile("device", "device and more")
+		Expect(Verify(Manifest{BlockDevice: checksumOf("device")}, filepath.Join(root, "device"))).To(Succeed())
+	})
+
+	It("Should report a missing file", func() {
+		err := Verify(Manifest{"disk.img": checksumOf("disk")}, root)
+		Expect(err).To(BeAssignableToTypeOf(&MismatchError{}))
+		Expect(err.Error()).To(ContainSubstring("missing"))
+	})
+
+	It("Should report a truncated file", func() {
+		writeFile("disk.img", "dis")
+		err := Verify(Manifest{"disk.img": checksumOf("disk")}, root)
+		Expect(err).To(BeAssignableToTypeOf(&MismatchError{}))
+		Expect(err.Error()).To(ContainSubstring("3 of 4 bytes"))
+	})
+
+	It("Should report a file that is too long", func() {
+		writeFile("disk.img", "disks")
+		Expect(Verify(Manifest{"disk.img": checksumOf("disk")}, root)).To(BeAssignableToTypeOf(&MismatchError{}))
+	})
+
+	It("Should report corrupted content", func() {
+		writeFile("disk.img", "dusk")
+		err := Verify(Manifest{"disk.img": checksumOf("disk")}, root)
+		Expect(err).To(BeAssignableToTypeOf(&MismatchError{}))
+		Expect(err.Error()).To(ContainSubstring("checksum mismatch"))
+	})
+})
+
+var _ = Describe("ChunkSum", func() {
+	It("Should return the CRC-32C of the data", func() {
+		// The check value of CRC-32C
+		Expect(ChunkSum([]byte("123456789"))).To(Equal("e3069283"))
+		Expect(ChunkSum(nil)).To(Equal("00000000"))
+	})
+})
+
+var _ = Describe("ParseDigest", func() {
+	It("Should parse sha256 and sha512 digests", func() {
+		h, sum, err := ParseDigest("sha256:" + strings.Repeat("ab", 32))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(h.Size()).To(Equal(32))
+		Expect(sum).To(HaveLen(32))
+		h, _, err = ParseDigest("sha512:" + strings.Repeat("AB", 64))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(h.Size()).To(Equal(64))
+	})
+
+	table.DescribeTable("Should reject invalid digests", func(digest string) {
+		_, _, err := ParseDigest(digest)
+		Expect(err).To(HaveOccurred())
+	},
+		table.Entry("without algorithm", strings.Repeat("ab", 32)),
+		table.Entry("with unsupported algorithm", "md5:"+strings.Repeat("ab", 16)),
+		table.Entry("with invalid hex", "sha256:"+strings.Repeat("xy", 32)),
+		table.Entry("with the length of another algorithm", "sha256:"+strings.Repeat("ab", 64)),
+	)
+})
+
+var _ = Describe("ContentDigest", func() {
+	digestOf := func(data string) string {
+		digest, err := ContentDigest(strings.NewReader(data))
+		Expect(err).ToNot(HaveOccurred())
+		return digest
+	}
+
+	It("Should return the sha256 of the image", func() {
+		Expect(digestOf("data")).To(Equal("sha256:3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"))
+		Expect(digestOf("")).To(Equal("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+	})
+
+	It("Should not change when the image is resized", func() {
+		image := "data\x00\x00data"
+		Expect(digestOf(image + strings.Repeat("\x00", 100*1024))).To(Equal(digestOf(image)))
+		Expect(digestOf(strings.Repeat("\x00", 100*1024))).To(Equal(digestOf("")))
+	})
+
+	It("Should keep zeros followed by data", func() {
+		image := "data" + strings.Repeat("\x00", 100*1024) + "data"
+		Expect(digestOf(image)).ToNot(Equal(digestOf("datadata")))
+		h := &contentHasher{hash: sha256.New()}
+		for _, part := range []string{"data", strings.Repeat("\x00", 50*1024), strings.Repeat("\x00", 50*1024), "data\x00"} {
+			h.Write([]byte(part))
+		}
+		Expect("sha256:" + hex.EncodeToString(h.hash.Sum(nil))).To(Equal(digestOf(image)))
+	})
+
+	It("Should digest files and skip directories", func() {
+		dir, err := ioutil.TempDir("", "content-digest")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(ioutil.WriteFile(filepath.Join(dir, "disk.img"), []byte("data\x00"), 0644)).To(Succeed())
+		digest, err := ContentDigestFile(filepath.Join(dir, "disk.img"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(Equal(digestOf("data")))
+		digest, err = ContentDigestFile(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(BeEmpty())
+		digest, err = ContentDigestFile(filepath.Join(dir, "missing.img"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(BeEmpty())
+	})
+
+	It("Should digest a region of a file", func() {
+		dir, err := ioutil.TempDir("", "digest")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(ioutil.WriteFile(filepath.Join(dir, "disk.img"), []byte("partdata\x00table"), 0644)).To(Succeed())
+		digest, err := ContentDigestSection(filepath.Join(dir, "disk.img"), 4, 5)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(Equal(digestOf("data")))
+	})
+})
+
+var _ = Describe("Block sums", func() {
+	It("Should sum each block, the last may be shorter", func() {
+		sums, err := BlockSums(strings.NewReader("aaaabbbbcc"), 4)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sums).To(HaveLen(3 * BlockSumSize))
+		for i, block := range []string{"aaaa", "bbbb", "cc"} {
+			sum := sha256.Sum256([]byte(block))
+			Expect(sums[i*BlockSumSize : (i+1)*BlockSumSize]).To(Equal(sum[:]))
+		}
+	})
+
+	It("Should have no sums for no data", func() {
+		sums, err := BlockSums(strings.NewReader(""), 4)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sums).To(BeEmpty())
+	})
+})
@@ -0,0 +1,65 @@
+package diagnostics
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// DefaultPort is the port the diagnostic endpoints are served on if the CDIConfig sets none
+const DefaultPort = 6060
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// NewHandler returns the handler of the diagnostic endpoints, the pprof profiles under /debug/pprof/ and the expvar
+// variables, with the memory statistics of the runtime, at /debug/vars
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Start serves the diagnostic endpoints on localhost if config enables them, until stopCh is closed. They are only
+// reachable from within the pod, with kubectl port-forward, so they need no authentication.
+func Start(config *cdiv1.Diagnostics, stopCh <-chan struct{}) error {
+	if config == nil {
+		return nil
+	}
+	port := int32(DefaultPort)
+	if config.Port > 0 {
+		port = config.Port
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(int(port))))
+	if err != nil {
+		return errors.Wrap(err, "could not listen for the diagnostic endpoints")
+	}
+	server := &http.Server{Handler: NewHandler()}
+	go func() {
+		<-stopCh
+		server.Close()
+	}()
+	go func() {
+		klog.Infof("Serving diagnostic endpoints on %s", listener.Addr())
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			klog.Errorf("Diagnostic endpoints failed: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+var _ = Describe("Diagnostic endpoints", func() {
+	var stopCh chan struct{}
+
+	BeforeEach(func() {
+		stopCh = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		close(stopCh)
+	})
+
+	freePort := func() int32 {
+		listener, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+		return int32(listener.Addr().(*net.TCPAddr).Port)
+	}
+
+	get := func(port int32, path string) (int, []byte) {
+		resp, err := http.Get("http://localhost:" + strconv.Itoa(int(port)) + path)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return resp.StatusCode, body
+	}
+
+	It("Should not serve the endpoints if they are not enabled", func() {
+		Expect(Start(nil, stopCh)).To(Succeed())
+	})
+
+	It("Should serve the runtime metrics and profiles on localhost", func() {
+		port := freePort()
+		Expect(Start(&cdiv1.Diagnostics{Port: port}, stopCh)).To(Succeed())
+
+		status, body := get(port, "/debug/vars")
+		Expect(status).To(Equal(http.StatusOK))
+		vars := map[string]interface{}{}
+		Expect(json.Unmarshal(body, &vars)).To(Succeed())
+		Expect(vars).To(HaveKey("memstats"))
+		Expect(vars).To(HaveKey("goroutines"))
+
+		status, body = get(port, "/debug/pprof/")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(string(body)).To(ContainSubstring("goroutine"))
+		status, _ = get(port, "/debug/pprof/heap")
+		Expect(status).To(Equal(http.StatusOK))
+	})
+
+	It("Should not serve other paths", func() {
+		port := freePort()
+		Expect(Start(&cdiv1.Diagnostics{Port: port}, stopCh)).To(Succeed())
+		status, _ := get(port, "/metrics")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("Should fail if the port is taken", func() {
+		listener, err := net.Listen("tcp", "localhost:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+		Expect(Start(&cdiv1.Diagnostics{Port: int32(listener.Addr().(*net.TCPAddr).Port)}, stopCh)).ToNot(Succeed())
+	})
+})
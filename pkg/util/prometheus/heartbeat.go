@@ -0,0 +1,80 @@
+package prometheus
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+// HeartbeatInterval is how often a transfer pod updates its heartbeat
+const HeartbeatInterval = 10 * time.Second
+
+var (
+	// transferredBytes counts the bytes read by all the HeartbeatReaders of the process
+	transferredBytes uint64
+
+	heartbeatTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transfer_heartbeat_timestamp_seconds",
+			Help: "The time of the last heartbeat of the transfer, in seconds since the epoch",
+		},
+		[]string{"ownerUID"},
+	)
+	heartbeatBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transfer_heartbeat_bytes",
+			Help: "The bytes transferred at the last heartbeat of the transfer",
+		},
+		[]string{"ownerUID"},
+	)
+
+	heartbeatOnce sync.Once
+	// nowFunc is the clock of the heartbeat, may be overridden in tests
+	nowFunc = time.Now
+)
+
+// HeartbeatReader is a reader that counts the bytes it reads towards the heartbeat of the pod.
+type HeartbeatReader struct {
+	io.ReadCloser
+}
+
+// NewHeartbeatReader creates a new instance of a heartbeat counting reader.
+func NewHeartbeatReader(r io.ReadCloser) *HeartbeatReader {
+	return &HeartbeatReader{ReadCloser: r}
+}
+
+// Read reads from the wrapped reader and counts the bytes read.
+func (r *HeartbeatReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddUint64(&transferredBytes, uint64(n))
+	return n, err
+}
+
+// StartHeartbeat registers the heartbeat metrics of the transfer for ownerUID and updates them every
+// HeartbeatInterval, for as long as the process runs. The controllers relay the heartbeat to the PVC, a heartbeat
+// that moves without the bytes is a transfer that is alive but stuck, one that stops is a hung pod.
+func StartHeartbeat(ownerUID string) {
+	heartbeatOnce.Do(func() {
+		for _, gauge := range []prometheus.Collector{heartbeatTimestamp, heartbeatBytes} {
+			if err := prometheus.Register(gauge); err != nil {
+				klog.Errorf("Unable to register the heartbeat metrics: %v", err)
+				return
+			}
+		}
+		go func() {
+			for {
+				beat(ownerUID)
+				time.Sleep(HeartbeatInterval)
+			}
+		}()
+	})
+}
+
+func beat(ownerUID string) {
+	heartbeatTimestamp.WithLabelValues(ownerUID).Set(float64(nowFunc().Unix()))
+	heartbeatBytes.WithLabelValues(ownerUID).Set(float64(atomic.LoadUint64(&transferredBytes)))
+}
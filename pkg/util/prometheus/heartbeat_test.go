@@ -0,0 +1,40 @@
+package prometheus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var _ = Describe("Heartbeat", func() {
+	AfterEach(func() {
+		nowFunc = time.Now
+		atomic.StoreUint64(&transferredBytes, 0)
+	})
+
+	It("Should count the bytes read", func() {
+		r := NewHeartbeatReader(ioutil.NopCloser(bytes.NewReader([]byte("hello world"))))
+		_, err := ioutil.ReadAll(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(atomic.LoadUint64(&transferredBytes)).To(Equal(uint64(11)))
+	})
+
+	It("Should report the time and the bytes transferred", func() {
+		nowFunc = func() time.Time {
+			return time.Unix(1602842400, 0)
+		}
+		atomic.StoreUint64(&transferredBytes, 1048576)
+		beat(ownerUID)
+		metric := &dto.Metric{}
+		Expect(heartbeatTimestamp.WithLabelValues(ownerUID).Write(metric)).To(Succeed())
+		Expect(*metric.Gauge.Value).To(Equal(float64(1602842400)))
+		Expect(heartbeatBytes.WithLabelValues(ownerUID).Write(metric)).To(Succeed())
+		Expect(*metric.Gauge.Value).To(Equal(float64(1048576)))
+	})
+})
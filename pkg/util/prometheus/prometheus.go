@@ -1,6 +1,8 @@
 package prometheus
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +10,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	dto "github.com/prometheus/client_model/go"
@@ -76,28 +79,76 @@ func (r *ProgressReader) updateProgress() bool {
 // in directory to store the self signed certificates that will be generated before starting the
 // http server.
 func StartPrometheusEndpoint(certsDirectory string) {
+	StartPrometheusEndpointOnPort(certsDirectory, 8443)
+}
+
+// StartPrometheusEndpointOnPort starts the prometheus endpoint like StartPrometheusEndpoint, on the passed in port,
+// for pods that serve something else on the default one.
+func StartPrometheusEndpointOnPort(certsDirectory string, port int) {
 	certBytes, keyBytes, err := cert.GenerateSelfSignedCertKey("cloner_target", nil, nil)
 	if err != nil {
 		klog.Error("Error generating cert for prometheus")
 		return
 	}
 
-	certFile := path.Join(certsDirectory, "tls.crt")
-	if err = ioutil.WriteFile(certFile, certBytes, 0600); err != nil {
-		klog.Error("Error writing cert file")
-		return
-	}
-
-	keyFile := path.Join(certsDirectory, "tls.key")
-	if err = ioutil.WriteFile(keyFile, keyBytes, 0600); err != nil {
-		klog.Error("Error writing key file")
+	certFile, keyFile, err := writeCertFiles(certsDirectory, certBytes, keyBytes)
+	if err != nil {
+		klog.Errorf("Error writing cert files: %v", err)
 		return
 	}
 
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
-		if err := http.ListenAndServeTLS(":8443", certFile, keyFile, nil); err != nil {
+		if err := http.ListenAndServeTLS(fmt.Sprintf(":%d", port), certFile, keyFile, nil); err != nil {
 			return
 		}
 	}()
 }
+
+// StartMutualTLSPrometheusEndpoint starts the prometheus endpoint on the default port, serving with the passed in
+// cert and key instead of self signed ones, and only to clients presenting a certificate signed by clientCA.
+func StartMutualTLSPrometheusEndpoint(certsDirectory string, certBytes, keyBytes, clientCA []byte) error {
+	return startMutualTLSPrometheusEndpointOnPort(certsDirectory, certBytes, keyBytes, clientCA, 8443)
+}
+
+func startMutualTLSPrometheusEndpointOnPort(certsDirectory string, certBytes, keyBytes, clientCA []byte, port int) error {
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(clientCA) {
+		return errors.New("no certificates in the client CA bundle")
+	}
+
+	certFile, keyFile, err := writeCertFiles(certsDirectory, certBytes, keyBytes)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		},
+	}
+	go func() {
+		if err := server.ListenAndServeTLS(certFile, keyFile); err != nil {
+			klog.Errorf("Error serving prometheus endpoint: %v", err)
+		}
+	}()
+	return nil
+}
+
+func writeCertFiles(certsDirectory string, certBytes, keyBytes []byte) (string, string, error) {
+	certFile := path.Join(certsDirectory, "tls.crt")
+	if err := ioutil.WriteFile(certFile, certBytes, 0600); err != nil {
+		return "", "", errors.Wrap(err, "error writing cert file")
+	}
+
+	keyFile := path.Join(certsDirectory, "tls.key")
+	if err := ioutil.WriteFile(keyFile, keyBytes, 0600); err != nil {
+		return "", "", errors.Wrap(err, "error writing key file")
+	}
+	return certFile, keyFile, nil
+}
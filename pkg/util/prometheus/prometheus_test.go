@@ -2,7 +2,14 @@ package prometheus
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -10,6 +17,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"kubevirt.io/containerized-data-importer/pkg/util"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert"
+	"kubevirt.io/containerized-data-importer/pkg/util/cert/triple"
 )
 
 var (
@@ -107,3 +116,88 @@ var _ = Describe("Update Progress", func() {
 	})
 
 })
+
+var _ = Describe("Mutual TLS endpoint", func() {
+	var (
+		certsDirectory string
+		serverCA       *triple.KeyPair
+		clientCA       *triple.KeyPair
+		metricsURL     string
+	)
+
+	newClient := func(clientKeyPair *triple.KeyPair) *http.Client {
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(serverCA.Cert)
+		tlsConfig := &tls.Config{RootCAs: rootCAs}
+		if clientKeyPair != nil {
+			clientCert, err := tls.X509KeyPair(cert.EncodeCertPEM(clientKeyPair.Cert), cert.EncodePrivateKeyPEM(clientKeyPair.Key))
+			Expect(err).ToNot(HaveOccurred())
+			tlsConfig.Certificates = []tls.Certificate{clientCert}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+
+	BeforeEach(func() {
+		var err error
+		certsDirectory, err = ioutil.TempDir("", "certsdir")
+		Expect(err).ToNot(HaveOccurred())
+		serverCA, err = triple.NewCA("metrics")
+		Expect(err).ToNot(HaveOccurred())
+		clientCA, err = triple.NewCA("metrics-client")
+		Expect(err).ToNot(HaveOccurred())
+		serverKeyPair, err := triple.NewServerKeyPair(serverCA, "localhost", "localhost", "default", "local", []string{"127.0.0.1"}, []string{"localhost"})
+		Expect(err).ToNot(HaveOccurred())
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+		metricsURL = fmt.Sprintf("https://127.0.0.1:%d/metrics", port)
+
+		err = startMutualTLSPrometheusEndpointOnPort(certsDirectory, cert.EncodeCertPEM(serverKeyPair.Cert),
+			cert.EncodePrivateKeyPEM(serverKeyPair.Key), cert.EncodeCertPEM(clientCA.Cert), port)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(certsDirectory)
+	})
+
+	It("Should serve clients with a certificate of the client CA", func() {
+		clientKeyPair, err := triple.NewClientKeyPair(clientCA, "client.metrics.cdi.kubevirt.io", nil)
+		Expect(err).ToNot(HaveOccurred())
+		client := newClient(clientKeyPair)
+		Eventually(func() error {
+			resp, err := client.Get(metricsURL)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+			return nil
+		}, 5*time.Second, 100*time.Millisecond).Should(Succeed())
+	})
+
+	It("Should not serve clients without a certificate of the client CA", func() {
+		otherCA, err := triple.NewCA("other")
+		Expect(err).ToNot(HaveOccurred())
+		otherKeyPair, err := triple.NewClientKeyPair(otherCA, "client.metrics.cdi.kubevirt.io", nil)
+		Expect(err).ToNot(HaveOccurred())
+		for _, client := range []*http.Client{newClient(nil), newClient(otherKeyPair)} {
+			Consistently(func() error {
+				resp, err := client.Get(metricsURL)
+				if err == nil {
+					resp.Body.Close()
+				}
+				return err
+			}, time.Second, 100*time.Millisecond).Should(HaveOccurred())
+		}
+	})
+
+	It("Should fail without a client CA", func() {
+		err := StartMutualTLSPrometheusEndpoint(certsDirectory, nil, nil, []byte("not a certificate"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServerTimeouts are the timeouts of the connections of an HTTP server, zero for no limit, and their TCP keep-alive
+// period, zero for the period of Go
+type ServerTimeouts struct {
+	Read, Write, Idle, KeepAlive time.Duration
+}
+
+// Apply sets the timeouts of server
+func (t ServerTimeouts) Apply(server *http.Server) {
+	server.ReadTimeout = t.Read
+	server.WriteTimeout = t.Write
+	server.IdleTimeout = t.Idle
+}
+
+// Listen listens on the TCP address, the accepted connections send keep-alive probes with the period of t
+func (t ServerTimeouts) Listen(address string) (net.Listener, error) {
+	config := &net.ListenConfig{KeepAlive: t.KeepAlive}
+	return config.Listen(context.Background(), "tcp", address)
+}
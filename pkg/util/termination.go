@@ -0,0 +1,140 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"kubevirt.io/containerized-data-importer/pkg/common"
+)
+
+// TerminationResult is the result of a transfer pod in its termination message
+type TerminationResult string
+
+const (
+	// TerminationSucceeded is the result of a transfer pod that populated its destination
+	TerminationSucceeded TerminationResult = "Succeeded"
+	// TerminationFailed is the result of a transfer pod that failed
+	TerminationFailed TerminationResult = "Failed"
+)
+
+// Error codes of a failed transfer pod, besides the reasons of permanent errors such as PermanentErrorNotFound
+const (
+	// TerminationErrorScratchSpaceExhausted is the error code of an importer that ran out of scratch space
+	TerminationErrorScratchSpaceExhausted = "ScratchSpaceExhausted"
+	// TerminationErrorDestinationNotWritable is the error code of an upload server that can not write to its PVC
+	TerminationErrorDestinationNotWritable = "DestinationNotWritable"
+	// TerminationErrorCloneVerificationFailed is the error code of an upload server that wrote data that does not
+	// match the checksums of the clone source
+	TerminationErrorCloneVerificationFailed = "CloneVerificationFailed"
+)
+
+// terminationMessageMaxBytes is how much of a termination message the kubelet keeps
+const terminationMessageMaxBytes = 4096
+
+// TerminationMessage is the termination message every transfer pod writes, as a single line of json, for the
+// controllers to read the result of the transfer from
+type TerminationMessage struct {
+	// Result tells whether the transfer succeeded
+	Result TerminationResult `json:"result"`
+	// Message describes the result, the error of a failure or the result of verifying a clone
+	Message string `json:"message,omitempty"`
+	// Bytes is the size of the image written to the destination, or of the source of a clone estimate
+	Bytes int64 `json:"bytes,omitempty"`
+	// Digest is the content digest of the image written to the destination
+	Digest string `json:"digest,omitempty"`
+	// ErrorCode tells the controllers why the transfer failed, the reason of a permanent error or one of the
+	// TerminationError codes
+	ErrorCode string `json:"errorCode,omitempty"`
+	// Retryable is true if retrying a failed transfer may succeed
+	Retryable bool `json:"retryable"`
+	// ImageCheck is the result of checking the image with qemu-img check
+	ImageCheck string `json:"imageCheck,omitempty"`
+	// BlockTargetLayout is the region of the block device the image was written to, like "offset=1048576,size=1048576"
+	BlockTargetLayout string `json:"blockTargetLayout,omitempty"`
+	// StageDurations is how long the stages of an import took, like "download=1m4s,convert=12.5s"
+	StageDurations string `json:"stageDurations,omitempty"`
+	// ETag is the ETag of the HTTP response the image was imported from
+	ETag string `json:"etag,omitempty"`
+	// LastModified is the Last-Modified time of the HTTP response the image was imported from
+	LastModified string `json:"lastModified,omitempty"`
+	// AllocatedBytes is how much of the source of a clone estimate is allocated, the data a clone reads
+	AllocatedBytes int64 `json:"allocatedBytes,omitempty"`
+	// TransferBytes is how much data a clone of the source of a clone estimate is estimated to send, compressed
+	TransferBytes int64 `json:"transferBytes,omitempty"`
+	// CompressRate is how many bytes per second the source of a clone estimate compresses at
+	CompressRate int64 `json:"compressRate,omitempty"`
+}
+
+// NewTerminationFailure returns the termination message of a transfer pod that failed with err, prefixed with
+// message. Errors retrying does not resolve are not retryable and have their reason as the error code.
+func NewTerminationFailure(message string, err error) *TerminationMessage {
+	result := &TerminationMessage{
+		Result:    TerminationFailed,
+		Message:   fmt.Sprintf("%s: %v", message, err),
+		Retryable: true,
+	}
+	if permanentErr, ok := AsPermanentError(err); ok {
+		result.ErrorCode, result.Retryable = permanentErr.Reason, false
+	}
+	return result
+}
+
+// WriteTerminationMessage writes message as json to the default termination message file
+func WriteTerminationMessage(message *TerminationMessage) error {
+	encoded, err := encodeTerminationMessage(message)
+	if err != nil {
+		return err
+	}
+	return WriteTerminationMessageToFile(common.PodTerminationMessageFile, encoded)
+}
+
+// encodeTerminationMessage returns message as json. A message that does not fit the termination message is cut at
+// the front, the end of an error tells most.
+func encodeTerminationMessage(message *TerminationMessage) (string, error) {
+	b, err := json.Marshal(message)
+	if err != nil {
+		return "", errors.Wrap(err, "could not encode termination message")
+	}
+	if excess := len(b) - terminationMessageMaxBytes; excess > 0 && excess+len("...") < len(message.Message) {
+		cut := *message
+		start := excess + len("...")
+		for start < len(cut.Message) && !utf8.RuneStart(cut.Message[start]) {
+			start++
+		}
+		cut.Message = "..." + cut.Message[start:]
+		if b, err = json.Marshal(&cut); err != nil {
+			return "", errors.Wrap(err, "could not encode termination message")
+		}
+	}
+	return string(b), nil
+}
+
+// ParseTerminationMessage returns the TerminationMessage of the termination message of a transfer pod, false if it
+// is not one, such as a message that fell back to the log of the pod
+func ParseTerminationMessage(message string) (*TerminationMessage, bool) {
+	result := &TerminationMessage{}
+	if err := json.Unmarshal([]byte(message), result); err != nil || result.Result == "" {
+		return nil, false
+	}
+	return result, true
+}
+
+// DestinationSize returns the size in bytes of the file or block device at path
+func DestinationSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not open %s", path)
+	}
+	defer f.Close()
+	// Seeking works for block devices, whose size Stat does not tell
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get the size of %s", path)
+	}
+	return size, nil
+}
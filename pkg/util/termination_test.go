@@ -0,0 +1,62 @@
+package util
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("TerminationMessage", func() {
+	It("Should survive the termination message", func() {
+		message := &TerminationMessage{
+			Result:            TerminationSucceeded,
+			Message:           "Import Complete",
+			Bytes:             1048576,
+			Digest:            "sha256:00",
+			ImageCheck:        "no errors found",
+			BlockTargetLayout: "offset=1048576,size=1048576",
+			StageDurations:    "download=1m4s,convert=12.5s",
+		}
+		encoded, err := encodeTerminationMessage(message)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoded).ToNot(ContainSubstring("\n"))
+		parsed, ok := ParseTerminationMessage(encoded)
+		Expect(ok).To(BeTrue())
+		Expect(parsed).To(Equal(message))
+	})
+
+	table.DescribeTable("Should not parse", func(message string) {
+		_, ok := ParseTerminationMessage(message)
+		Expect(ok).To(BeFalse())
+	},
+		table.Entry("a message that fell back to the log", "panic: runtime error: index out of range"),
+		table.Entry("json without a result", `{"message": "Import Complete"}`),
+		table.Entry("an empty message", ""),
+	)
+
+	table.DescribeTable("Should describe a failure", func(err error, errorCode string, retryable bool) {
+		message := NewTerminationFailure("Unable to process data", err)
+		Expect(message.Result).To(Equal(TerminationFailed))
+		Expect(message.Message).To(Equal("Unable to process data: " + err.Error()))
+		Expect(message.ErrorCode).To(Equal(errorCode))
+		Expect(message.Retryable).To(Equal(retryable))
+	},
+		table.Entry("that retrying may resolve", errors.New("connection reset"), "", true),
+		table.Entry("that retrying does not resolve", errors.Wrap(NewPermanentError(PermanentErrorNotFound, errors.New("gone")), "import"), PermanentErrorNotFound, false),
+	)
+
+	It("Should keep the end of a message that does not fit", func() {
+		message := &TerminationMessage{Result: TerminationFailed, Message: strings.Repeat("a", 2*terminationMessageMaxBytes) + "the cause", Retryable: true}
+		encoded, err := encodeTerminationMessage(message)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(encoded)).To(BeNumerically("<=", terminationMessageMaxBytes))
+		parsed, ok := ParseTerminationMessage(encoded)
+		Expect(ok).To(BeTrue())
+		Expect(parsed.Message).To(HavePrefix("..."))
+		Expect(parsed.Message).To(HaveSuffix("the cause"))
+		Expect(parsed.Retryable).To(BeTrue())
+	})
+})
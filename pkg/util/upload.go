@@ -0,0 +1,29 @@
+package util
+
+// UploadPhase is the phase of an async upload
+type UploadPhase string
+
+const (
+	// UploadPhaseReceiving is the phase of an async upload while its data is received
+	UploadPhaseReceiving UploadPhase = "receiving"
+	// UploadPhaseConverting is the phase of an async upload while the image is converted and resized, after the
+	// upload request returned
+	UploadPhaseConverting UploadPhase = "converting"
+	// UploadPhaseVerifying is the phase of an async upload while the content digest of the image is computed
+	UploadPhaseVerifying UploadPhase = "verifying"
+	// UploadPhaseDone is the phase of an async upload that wrote the image
+	UploadPhaseDone UploadPhase = "done"
+	// UploadPhaseError is the phase of an async upload that failed
+	UploadPhaseError UploadPhase = "error"
+)
+
+// UploadStatus is the status of an async upload, the body of the responses of the upload status path and of the
+// completion callback of the upload
+type UploadStatus struct {
+	// Operation is the ID of the upload
+	Operation string `json:"operation"`
+	// Phase is the phase of the upload
+	Phase UploadPhase `json:"phase"`
+	// Message tells why the upload failed
+	Message string `json:"message,omitempty"`
+}
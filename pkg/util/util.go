@@ -3,11 +3,14 @@ package util
 import (
 	"bufio"
 	"bytes"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/rand"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -127,6 +130,37 @@ func MinQuantity(availableSpace, imageSize *resource.Quantity) resource.Quantity
 	return *imageSize
 }
 
+const (
+	// DiskImageAlignment is the alignment of the size of the disk images CDI writes
+	DiskImageAlignment = int64(1024 * 1024)
+	// FilesystemOverhead is the share of a file system volume that the file system takes for itself
+	FilesystemOverhead = 0.055
+	// MinimumFilesystemVolumeSize is the size of the smallest file system volume CDI writes to, the metadata of the
+	// file system fills smaller ones
+	MinimumFilesystemVolumeSize = int64(16 * 1024 * 1024)
+)
+
+// MinimumTargetSize returns the size of the smallest volume that holds a disk image of virtualSize, the virtual size
+// aligned to DiskImageAlignment, plus the FilesystemOverhead on a file system volume
+func MinimumTargetSize(virtualSize int64, filesystem bool) int64 {
+	size := alignUp(virtualSize, DiskImageAlignment)
+	if size < DiskImageAlignment {
+		size = DiskImageAlignment
+	}
+	if !filesystem {
+		return size
+	}
+	size = alignUp(int64(math.Ceil(float64(size)/(1-FilesystemOverhead))), DiskImageAlignment)
+	if size < MinimumFilesystemVolumeSize {
+		size = MinimumFilesystemVolumeSize
+	}
+	return size
+}
+
+func alignUp(size, alignment int64) int64 {
+	return (size + alignment - 1) / alignment * alignment
+}
+
 // StreamDataToFile provides a function to stream the specified io.Reader to the specified local file
 func StreamDataToFile(r io.Reader, fileName string) error {
 	var outFile *os.File
@@ -211,11 +245,6 @@ func CopyFile(src, dst string) error {
 	return out.Close()
 }
 
-// WriteTerminationMessage writes the passed in message to the default termination message file
-func WriteTerminationMessage(message string) error {
-	return WriteTerminationMessageToFile(common.PodTerminationMessageFile, message)
-}
-
 // WriteTerminationMessageToFile writes the passed in message to the passed in message file
 func WriteTerminationMessageToFile(file, message string) error {
 	// Only write the first line of the message.
@@ -266,3 +295,56 @@ func CopyDir(source string, dest string) (err error) {
 	}
 	return
 }
+
+// Reasons of permanent errors
+const (
+	// PermanentErrorUnauthorized is the reason of a source that rejects the credentials
+	PermanentErrorUnauthorized = "Unauthorized"
+	// PermanentErrorNotFound is the reason of a source that does not exist
+	PermanentErrorNotFound = "NotFound"
+	// PermanentErrorUnsupportedFormat is the reason of an image in a format that cannot be imported
+	PermanentErrorUnsupportedFormat = "UnsupportedFormat"
+	// PermanentErrorCertificateInvalid is the reason of a source whose certificate cannot be verified
+	PermanentErrorCertificateInvalid = "CertificateInvalid"
+	// PermanentErrorChecksumMismatch is the reason of a source that does not match the digest it is pinned to
+	PermanentErrorChecksumMismatch = "ChecksumMismatch"
+	// PermanentErrorImageCorrupt is the reason of an image that qemu-img check found corruptions on
+	PermanentErrorImageCorrupt = "ImageCorrupt"
+	// PermanentErrorTargetTooSmall is the reason of an image whose virtual size is larger than the target volume
+	PermanentErrorTargetTooSmall = "TargetTooSmall"
+	// PermanentErrorImageEncrypted is the reason of an encrypted image without a passphrase, or with one that does not
+	// decrypt it
+	PermanentErrorImageEncrypted = "ImageEncrypted"
+)
+
+// PermanentError is an error that retrying does not resolve, such as a source that rejects the credentials
+type PermanentError struct {
+	// Reason tells why retrying does not help
+	Reason string
+	Err    error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// NewPermanentError returns err as a PermanentError with reason
+func NewPermanentError(reason string, err error) error {
+	return &PermanentError{Reason: reason, Err: err}
+}
+
+// AsPermanentError returns the PermanentError that caused err. Errors verifying the certificate of a source are
+// permanent errors too.
+func AsPermanentError(err error) (*PermanentError, bool) {
+	cause := errors.Cause(err)
+	if urlErr, ok := cause.(*url.Error); ok {
+		cause = urlErr.Err
+	}
+	switch e := cause.(type) {
+	case *PermanentError:
+		return e, true
+	case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError:
+		return &PermanentError{Reason: PermanentErrorCertificateInvalid, Err: err}, true
+	}
+	return nil, false
+}
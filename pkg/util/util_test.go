@@ -2,10 +2,12 @@ package util
 
 import (
 	"crypto/md5"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -13,6 +15,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -88,6 +91,20 @@ var _ = Describe("Compare quantities", func() {
 	})
 })
 
+var _ = Describe("MinimumTargetSize", func() {
+	const mi = int64(1024 * 1024)
+
+	table.DescribeTable("should align the virtual size and add the file system overhead", func(virtualSize int64, filesystem bool, expected int64) {
+		Expect(MinimumTargetSize(virtualSize, filesystem)).To(Equal(expected))
+	},
+		table.Entry("empty block volume", int64(0), false, mi),
+		table.Entry("aligned block volume", 10*mi, false, 10*mi),
+		table.Entry("unaligned block volume", 10*mi+1, false, 11*mi),
+		table.Entry("small file system volume", mi, true, MinimumFilesystemVolumeSize),
+		table.Entry("file system volume", 1024*mi, true, 1084*mi),
+	)
+})
+
 var _ = Describe("Copy files", func() {
 	var destTmp string
 	var err error
@@ -144,3 +161,20 @@ func md5sum(filePath string) (string, error) {
 
 	return returnMD5String, nil
 }
+
+var _ = Describe("AsPermanentError", func() {
+	table.DescribeTable("Should classify", func(err error, expectedReason string) {
+		permanentErr, ok := AsPermanentError(err)
+		if expectedReason == "" {
+			Expect(ok).To(BeFalse())
+			return
+		}
+		Expect(ok).To(BeTrue())
+		Expect(permanentErr.Reason).To(Equal(expectedReason))
+	},
+		table.Entry("a wrapped permanent error", errors.Wrap(NewPermanentError(PermanentErrorNotFound, errors.New("gone")), "import"), PermanentErrorNotFound),
+		table.Entry("an unknown certificate authority", &url.Error{Op: "Get", URL: "https://source", Err: x509.UnknownAuthorityError{}}, PermanentErrorCertificateInvalid),
+		table.Entry("a certificate for another host", errors.Wrap(&url.Error{Op: "Get", URL: "https://source", Err: x509.HostnameError{Host: "source"}}, "HTTP request errored"), PermanentErrorCertificateInvalid),
+		table.Entry("not other errors", errors.New("connection reset"), ""),
+	)
+})
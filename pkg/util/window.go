@@ -0,0 +1,66 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// weekdays are the days of the week by the names windows use for them
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// ParseTimeOfDay returns the minutes after midnight of value, a time of day as HH:MM
+func ParseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWeekday returns the day of the week of value, one of Mon, Tue, Wed, Thu, Fri, Sat or Sun
+func ParseWeekday(value string) (time.Weekday, error) {
+	day, ok := weekdays[value]
+	if !ok {
+		return 0, fmt.Errorf("invalid day of the week %q, expected one of Mon, Tue, Wed, Thu, Fri, Sat, Sun", value)
+	}
+	return day, nil
+}
+
+// InWeeklyWindow returns true if t is in the window from start to end, in minutes after midnight in UTC, that starts on
+// one of days, or on any day if there are none. A window that ends before it starts ends the next day, one that ends
+// when it starts lasts the whole day.
+func InWeeklyWindow(t time.Time, days []time.Weekday, start, end int) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	startsOn := func(d time.Weekday) bool {
+		if len(days) == 0 {
+			return true
+		}
+		for _, wd := range days {
+			if wd == d {
+				return true
+			}
+		}
+		return false
+	}
+	if start == end {
+		return startsOn(day)
+	}
+	if start < end {
+		return minute >= start && minute < end && startsOn(day)
+	}
+	if minute >= start {
+		return startsOn(day)
+	}
+	// Past midnight, in a window that started the day before
+	return minute < end && startsOn((day+6)%7)
+}
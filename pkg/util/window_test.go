@@ -0,0 +1,55 @@
+package util
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Weekly windows", func() {
+	table.DescribeTable("Should parse the time of day", func(value string, expected int, valid bool) {
+		minutes, err := ParseTimeOfDay(value)
+		if !valid {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(minutes).To(Equal(expected))
+	},
+		table.Entry("at midnight", "00:00", 0, true),
+		table.Entry("in the evening", "18:30", 18*60+30, true),
+		table.Entry("but not past the end of the day", "24:00", 0, false),
+		table.Entry("but not without minutes", "8", 0, false),
+	)
+
+	It("Should parse the days of the week", func() {
+		day, err := ParseWeekday("Sat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(day).To(Equal(time.Saturday))
+		_, err = ParseWeekday("Saturday")
+		Expect(err).To(HaveOccurred())
+	})
+
+	// 2020-06-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+	table.DescribeTable("Should tell if the time is in the window", func(t time.Time, days []time.Weekday, start, end int, expected bool) {
+		Expect(InWeeklyWindow(t, days, start, end)).To(Equal(expected))
+	},
+		table.Entry("during business hours", at(1, 10, 0), weekdays, 8*60, 18*60, true),
+		table.Entry("not before they start", at(1, 7, 59), weekdays, 8*60, 18*60, false),
+		table.Entry("not once they end", at(1, 18, 0), weekdays, 8*60, 18*60, false),
+		table.Entry("not on the weekend", at(6, 10, 0), weekdays, 8*60, 18*60, false),
+		table.Entry("on any day without days", at(6, 10, 0), nil, 8*60, 18*60, true),
+		table.Entry("in the night the window starts", at(5, 23, 0), weekdays, 22*60, 6*60, true),
+		table.Entry("past midnight of the day the window starts", at(6, 5, 0), weekdays, 22*60, 6*60, true),
+		table.Entry("not past midnight of a day the window does not start", at(1, 5, 0), weekdays, 22*60, 6*60, false),
+		table.Entry("all day if it ends when it starts", at(1, 23, 59), weekdays, 0, 0, true),
+		table.Entry("in UTC", at(1, 10, 0).In(time.FixedZone("UTC+12", 12*3600)), weekdays, 8*60, 18*60, true),
+	)
+})
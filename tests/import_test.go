@@ -4,9 +4,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -211,6 +213,35 @@ var _ = Describe("[rfe_id:1118][crit:high][vendor:cnv-qe@redhat.com][level:compo
 	})
 })
 
+var _ = Describe("Importer Test Suite-IP literal", func() {
+	f := framework.NewFrameworkOrDie(namespacePrefix)
+
+	It("Should import from the cluster IP of the file host, bracketed on IPv6 clusters", func() {
+		svc := utils.GetServiceInNamespaceOrDie(f.K8sClient, f.CdiInstallNs, utils.FileHostName)
+		httpEp := "http://" + net.JoinHostPort(svc.Spec.ClusterIP, strconv.Itoa(utils.HTTPNoAuthPort))
+		pvcAnn := map[string]string{
+			controller.AnnEndpoint: httpEp + "/tinyCore.iso",
+		}
+
+		By(fmt.Sprintf("Creating PVC with endpoint annotation %q", httpEp+"/tinyCore.iso"))
+		pvc, err := f.CreatePVCFromDefinition(utils.NewPVCDefinition("import-ip-literal", "500Mi", pvcAnn, nil))
+		Expect(err).ToNot(HaveOccurred())
+
+		By("Verify the pod status is succeeded on the target PVC")
+		Eventually(func() string {
+			status, phaseAnnotation, err := utils.WaitForPVCAnnotation(f.K8sClient, f.Namespace.Name, pvc, controller.AnnPodPhase)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(phaseAnnotation).To(BeTrue())
+			return status
+		}, CompletionTimeout, assertionPollInterval).Should(BeEquivalentTo(v1.PodSucceeded))
+
+		By("Verify content")
+		same, err := f.VerifyTargetPVCContentMD5(f.Namespace, pvc, utils.DefaultImagePath, utils.UploadFileMD5, utils.UploadFileSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(same).To(BeTrue())
+	})
+})
+
 func startPrometheusPortForward(f *framework.Framework) (string, *exec.Cmd, error) {
 	lp := "28443"
 	pm := lp + ":8443"
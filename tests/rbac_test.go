@@ -168,6 +168,7 @@ var _ = Describe("Aggregated role definition tests", func() {
 			},
 			Resources: []string{
 				"datavolumes",
+				"datavolumetemplates",
 			},
 			Verbs: []string{
 				"*",
@@ -182,6 +183,7 @@ var _ = Describe("Aggregated role definition tests", func() {
 			},
 			Verbs: []string{
 				"create",
+				"clone",
 			},
 		},
 		{
@@ -199,6 +201,20 @@ var _ = Describe("Aggregated role definition tests", func() {
 				"update",
 			},
 		},
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"cdiquotas",
+				"operationhistories",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+			},
+		},
 		{
 			APIGroups: []string{
 				"upload.cdi.kubevirt.io",
@@ -221,6 +237,7 @@ var _ = Describe("Aggregated role definition tests", func() {
 			},
 			Resources: []string{
 				"datavolumes",
+				"datavolumetemplates",
 			},
 			Verbs: []string{
 				"get",
@@ -237,6 +254,7 @@ var _ = Describe("Aggregated role definition tests", func() {
 			},
 			Verbs: []string{
 				"create",
+				"clone",
 			},
 		},
 		{
@@ -252,6 +270,34 @@ var _ = Describe("Aggregated role definition tests", func() {
 				"watch",
 			},
 		},
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"cdiquotas",
+				"operationhistories",
+			},
+			Verbs: []string{
+				"get",
+				"list",
+				"watch",
+			},
+		},
+	}
+
+	var cloneSourceRules = []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{
+				"cdi.kubevirt.io",
+			},
+			Resources: []string{
+				"datavolumes/source",
+			},
+			Verbs: []string{
+				"clone",
+			},
+		},
 	}
 
 	f := framework.NewFrameworkOrDie("aggregated-role-definition-tests")
@@ -274,5 +320,6 @@ var _ = Describe("Aggregated role definition tests", func() {
 		Entry("for admin", "admin", adminRules),
 		Entry("for edit", "edit", editRules),
 		Entry("for view", "view", viewRules),
+		Entry("for clone-source", "cdi.kubevirt.io:clone-source", cloneSourceRules),
 	)
 })
@@ -65,6 +65,24 @@ func CoreAPI() []*restful.WebService {
 		Resource: "cdiconfigs",
 	}
 
+	cdiQuotaGVR := schema.GroupVersionResource{
+		Group:    cdiv1alpha1.SchemeGroupVersion.Group,
+		Version:  cdiv1alpha1.SchemeGroupVersion.Version,
+		Resource: "cdiquotas",
+	}
+
+	operationHistoryGVR := schema.GroupVersionResource{
+		Group:    cdiv1alpha1.SchemeGroupVersion.Group,
+		Version:  cdiv1alpha1.SchemeGroupVersion.Version,
+		Resource: "operationhistories",
+	}
+
+	dvTemplateGVR := schema.GroupVersionResource{
+		Group:    cdiv1alpha1.SchemeGroupVersion.Group,
+		Version:  cdiv1alpha1.SchemeGroupVersion.Version,
+		Resource: "datavolumetemplates",
+	}
+
 	ws, err := groupVersionProxyBase(cdiv1alpha1.SchemeGroupVersion)
 	if err != nil {
 		panic(err)
@@ -85,6 +103,21 @@ func CoreAPI() []*restful.WebService {
 		panic(err)
 	}
 
+	ws, err = genericResourceProxy(ws, cdiQuotaGVR, &cdiv1alpha1.CDIQuota{}, "CDIQuota", &cdiv1alpha1.CDIQuotaList{})
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err = genericResourceProxy(ws, operationHistoryGVR, &cdiv1alpha1.OperationHistory{}, "OperationHistory", &cdiv1alpha1.OperationHistoryList{})
+	if err != nil {
+		panic(err)
+	}
+
+	ws, err = genericResourceProxy(ws, dvTemplateGVR, &cdiv1alpha1.DataVolumeTemplate{}, "DataVolumeTemplate", &cdiv1alpha1.DataVolumeTemplateList{})
+	if err != nil {
+		panic(err)
+	}
+
 	ws1, err := resourceProxyAutodiscovery(dvGVR)
 	if err != nil {
 		panic(err)